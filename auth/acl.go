@@ -1,15 +1,122 @@
 package auth
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ARGOeu/argo-messaging/events"
+	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	log "github.com/sirupsen/logrus"
 )
 
 // ACL holds the authorized users for a resource (topic/subscription)
 type ACL struct {
 	AuthUsers []string `json:"authorized_users"`
+	// Version counts the modifications of the acl, it is exchanged with the clients as the acl's etag
+	Version int64 `json:"-"`
+}
+
+// ErrACLVersionConflict is returned when an acl got modified since the version a modification was based on
+var ErrACLVersionConflict = errors.New("acl version conflict")
+
+// ACLDiff holds the users that an acl modification would add to or remove from a resource
+type ACLDiff struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// ACL history operations, telling how a change modified the acl
+const (
+	ACLOpModify   = "modify"
+	ACLOpAppend   = "append"
+	ACLOpRemove   = "remove"
+	ACLOpRollback = "rollback"
+	ACLOpDefault  = "default"
+)
+
+// ACLChange is an entry of a resource's acl history
+type ACLChange struct {
+	AuthUsers []string `json:"authorized_users"`
+	Operation string   `json:"operation"`
+	ChangedBy string   `json:"changed_by"`
+	ChangedOn string   `json:"changed_on"`
+}
+
+// ACLHistory holds the changes of a resource's acl, oldest first
+type ACLHistory struct {
+	Changes []ACLChange `json:"history"`
+}
+
+// ExportJSON export acl history body to json for use in http response
+func (h *ACLHistory) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(h, "", "   ")
+	return string(output[:]), err
+}
+
+// aclHistoryRetention is how long the acl changes are kept, zero keeps them forever
+var aclHistoryRetention time.Duration
+
+// SetACLHistoryRetention sets how long the acl changes are kept, zero keeps them forever
+func SetACLHistoryRetention(retention time.Duration) {
+	aclHistoryRetention = retention
+}
+
+// ProjectACLs holds the acls of a project's topics and subscriptions, keyed by the resource name
+type ProjectACLs struct {
+	Topics        map[string][]string `json:"topics"`
+	Subscriptions map[string][]string `json:"subscriptions"`
+}
+
+// ExportJSON export project acls body to json for use in http response
+func (pacl *ProjectACLs) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(pacl, "", "   ")
+	return string(output[:]), err
+}
+
+// Users returns all the distinct users referenced by the project acls
+func (pacl *ProjectACLs) Users() []string {
+	users := []string{}
+	seen := make(map[string]bool)
+	for _, acls := range []map[string][]string{pacl.Topics, pacl.Subscriptions} {
+		for _, acl := range acls {
+			for _, username := range acl {
+				if !seen[username] {
+					seen[username] = true
+					users = append(users, username)
+				}
+			}
+		}
+	}
+	sort.Strings(users)
+	return users
+}
+
+// GetProjectACLsFromJSON retrieves the acls of a project's resources from JSON
+func GetProjectACLsFromJSON(input []byte) (ProjectACLs, error) {
+	pacl := ProjectACLs{}
+	err := json.Unmarshal([]byte(input), &pacl)
+	if err != nil {
+		return pacl, err
+	}
+	if pacl.Topics == nil && pacl.Subscriptions == nil {
+		return pacl, errors.New("wrong argument")
+	}
+	return pacl, nil
+}
+
+// ExportJSON export acl diff body to json for use in http response
+func (diff *ACLDiff) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(diff, "", "   ")
+	return string(output[:]), err
 }
 
 // ExportJSON export topic acl body to json for use in http response
@@ -31,43 +138,193 @@ func GetACLFromJSON(input []byte) (ACL, error) {
 	return acl, err
 }
 
-// ModACL is called to modify an acl
-func ModACL(projectUUID string, resourceType string, resourceName string, acl []string, store stores.Store) error {
+// ModACL is called to modify an acl, recording the change on behalf of the actor
+func ModACL(projectUUID string, resourceType string, resourceName string, acl []string, actor string, store stores.Store) error {
+	return ModACLVersion(projectUUID, resourceType, resourceName, acl, -1, actor, store)
+}
+
+// ModACLVersion modifies an acl like ModACL, as long as the acl is still at the given version, a negative version
+// modifying it unconditionally. An acl that got modified in the meantime is left untouched and ErrACLVersionConflict is returned
+func ModACLVersion(projectUUID string, resourceType string, resourceName string, acl []string, version int64, actor string, store stores.Store) error {
 	// Transform user name to user uuid
 
 	userUUIDs := []string{}
 	for _, username := range acl {
-		userUUID := GetUUIDByName(username, store)
+		userUUID := aclUserUUID(projectUUID, username, store)
 		userUUIDs = append(userUUIDs, userUUID)
 	}
 
-	return store.ModACL(projectUUID, resourceType, resourceName, userUUIDs)
+	defer InvalidateACL(projectUUID, resourceType, resourceName)
+	if version < 0 {
+		if err := store.ModACL(projectUUID, resourceType, resourceName, userUUIDs); err != nil {
+			return err
+		}
+	} else if err := store.ModACLVersion(projectUUID, resourceType, resourceName, userUUIDs, version); err != nil {
+		if err.Error() == "conflict" {
+			return ErrACLVersionConflict
+		}
+		return err
+	}
+
+	RecordACLChange(projectUUID, resourceType, resourceName, ACLOpModify, actor, store)
+	return nil
 }
 
 // AppendToACL is used to append unique users to a topic's or sub's ACL
-func AppendToACL(projectUUID string, resourceType string, resourceName string, acl []string, store stores.Store) error {
+func AppendToACL(projectUUID string, resourceType string, resourceName string, acl []string, actor string, store stores.Store) error {
 
 	// Transform user name to user uuid
 	userUUIDs := []string{}
 	for _, username := range acl {
-		userUUID := GetUUIDByName(username, store)
+		userUUID := aclUserUUID(projectUUID, username, store)
 		userUUIDs = append(userUUIDs, userUUID)
 	}
 
-	return store.AppendToACL(projectUUID, resourceType, resourceName, userUUIDs)
+	defer InvalidateACL(projectUUID, resourceType, resourceName)
+	if err := store.AppendToACL(projectUUID, resourceType, resourceName, userUUIDs); err != nil {
+		return err
+	}
+
+	RecordACLChange(projectUUID, resourceType, resourceName, ACLOpAppend, actor, store)
+	return nil
 }
 
-// AppendToACL is used to remove users from a topic's or sub's acl
-func RemoveFromACL(projectUUID string, resourceType string, resourceName string, acl []string, store stores.Store) error {
+// RemoveFromACL is used to remove users from a topic's or sub's acl
+func RemoveFromACL(projectUUID string, resourceType string, resourceName string, acl []string, actor string, store stores.Store) error {
 
 	// Transform user name to user uuid
 	userUUIDs := []string{}
 	for _, username := range acl {
-		userUUID := GetUUIDByName(username, store)
+		userUUID := aclUserUUID(projectUUID, username, store)
 		userUUIDs = append(userUUIDs, userUUID)
 	}
 
-	return store.RemoveFromACL(projectUUID, resourceType, resourceName, userUUIDs)
+	defer InvalidateACL(projectUUID, resourceType, resourceName)
+	if err := store.RemoveFromACL(projectUUID, resourceType, resourceName, userUUIDs); err != nil {
+		return err
+	}
+
+	RecordACLChange(projectUUID, resourceType, resourceName, ACLOpRemove, actor, store)
+	return nil
+}
+
+// RecordACLChange appends the current acl of a resource to its history, as left by the actor's change,
+// and drops the changes that are older than the retention. The acl has already been modified,
+// so a failure to record it is logged instead of failing the modification. The change is also emitted as an event
+func RecordACLChange(projectUUID string, resourceType string, resourceName string, operation string, actor string, store stores.Store) {
+
+	now := time.Now().UTC()
+
+	acl, err := store.QueryACL(projectUUID, resourceType, resourceName)
+	if err == nil {
+		err = store.InsertACLChange(projectUUID, resourceType, resourceName, operation, acl.ACL, actor, now)
+	}
+	if err == nil && aclHistoryRetention > 0 {
+		err = store.RemoveACLHistory(now.Add(-aclHistoryRetention))
+	}
+
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":          "service_log",
+				"project_uuid":  projectUUID,
+				"resource_type": resourceType,
+				"resource_name": resourceName,
+				"error":         err.Error(),
+			},
+		).Error("Could not record acl change")
+	}
+
+	if events.Enabled() {
+		resource := "/projects/" + projects.GetNameByUUID(projectUUID, store) + "/" + resourceType + "/" + resourceName
+		events.Emit(events.ACLModified, resource, GetNameByUUID(actor, store))
+	}
+}
+
+// GetACLHistory returns the changes of a resource's acl, oldest first. Users that no longer exist are reported by their uuid
+func GetACLHistory(projectUUID string, resourceType string, resourceName string, store stores.Store) (ACLHistory, error) {
+	result := ACLHistory{Changes: []ACLChange{}}
+
+	changes, err := store.QueryACLHistory(projectUUID, resourceType, resourceName)
+	if err != nil {
+		return result, err
+	}
+
+	names := map[string]string{}
+	nameOf := func(uuid string) string {
+		if _, found := names[uuid]; !found {
+			names[uuid] = GetNameByUUID(uuid, store)
+		}
+		if names[uuid] == "" {
+			return uuid
+		}
+		return names[uuid]
+	}
+
+	for _, item := range changes {
+		change := ACLChange{
+			AuthUsers: []string{},
+			Operation: item.Operation,
+			ChangedBy: nameOf(item.Actor),
+			ChangedOn: timestamp.FormatNano(item.ChangedOn),
+		}
+		for _, userUUID := range item.ACL {
+			change.AuthUsers = append(change.AuthUsers, nameOf(userUUID))
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	return result, nil
+}
+
+// ApplyDefaultACL grants the users of the project's default acl access to a newly created topic or subscription,
+// recording the change on behalf of the actor that created the resource
+func ApplyDefaultACL(projectUUID string, resourceType string, resourceName string, actor string, store stores.Store) error {
+
+	qProjects, err := store.QueryProjects(projectUUID, "")
+	if err != nil || len(qProjects) == 0 || qProjects[0].DefaultACL == nil {
+		return nil
+	}
+
+	userUUIDs := qProjects[0].DefaultACL.Topics
+	if resourceType == "subscriptions" {
+		userUUIDs = qProjects[0].DefaultACL.Subscriptions
+	}
+
+	if len(userUUIDs) == 0 {
+		return nil
+	}
+
+	defer InvalidateACL(projectUUID, resourceType, resourceName)
+	if err := store.AppendToACL(projectUUID, resourceType, resourceName, userUUIDs); err != nil {
+		return err
+	}
+
+	RecordACLChange(projectUUID, resourceType, resourceName, ACLOpDefault, actor, store)
+	return nil
+}
+
+// aclUserUUID resolves the uuid of an acl's user. When usernames are case insensitive, a username
+// without an exact match resolves to the project's user whose name differs only in its case
+func aclUserUUID(projectUUID string, username string, store stores.Store) string {
+
+	userUUID := GetUUIDByName(username, store)
+	if userUUID != "" || !caseInsensitiveUsernames {
+		return userUUID
+	}
+
+	users, err := store.QueryUsers(projectUUID, "", "")
+	if err != nil {
+		return ""
+	}
+
+	for _, user := range users {
+		if strings.EqualFold(user.Name, username) {
+			return user.UUID
+		}
+	}
+
+	return ""
 }
 
 // GetACL returns an authorized list of user for the resource (topic or subscription)
@@ -77,6 +334,7 @@ func GetACL(projectUUID string, resourceType string, resourceName string, store
 	if err != nil {
 		return result, err
 	}
+	result.Version = acl.Version
 	for _, item := range acl.ACL {
 
 		// Get Username from user uuid
@@ -92,3 +350,395 @@ func GetACL(projectUUID string, resourceType string, resourceName string, store
 
 	return result, err
 }
+
+// DiffACL computes the changes that replacing a topic's or sub's acl with the given users would bring, without applying them
+func DiffACL(projectUUID string, resourceType string, resourceName string, acl []string, store stores.Store) (ACLDiff, error) {
+	result := ACLDiff{Added: []string{}, Removed: []string{}}
+
+	current, err := GetACL(projectUUID, resourceType, resourceName, store)
+	if err != nil {
+		return result, err
+	}
+
+	existing := make(map[string]bool)
+	for _, username := range current.AuthUsers {
+		existing[username] = true
+	}
+
+	requested := make(map[string]bool)
+	for _, username := range acl {
+		if !existing[username] && !requested[username] {
+			result.Added = append(result.Added, username)
+		}
+		requested[username] = true
+	}
+
+	for _, username := range current.AuthUsers {
+		if !requested[username] {
+			result.Removed = append(result.Removed, username)
+		}
+	}
+
+	return result, nil
+}
+
+// ExportProjectACLs collects the acls of all the topics and subscriptions of a project
+func ExportProjectACLs(projectUUID string, store stores.Store) (ProjectACLs, error) {
+	result := ProjectACLs{Topics: map[string][]string{}, Subscriptions: map[string][]string{}}
+
+	qTopics, _, _, err := store.QueryTopics(projectUUID, "", "", "", 0, false, nil, "")
+	if err != nil {
+		return result, err
+	}
+
+	for _, item := range qTopics {
+		acl, err := GetACL(projectUUID, "topics", item.Name, store)
+		if err != nil {
+			// the resource was removed while exporting
+			if err.Error() == "not found" {
+				continue
+			}
+			return result, err
+		}
+		result.Topics[item.Name] = append([]string{}, acl.AuthUsers...)
+	}
+
+	qSubs, _, _, err := store.QuerySubs(projectUUID, "", "", "", 0, nil, "")
+	if err != nil {
+		return result, err
+	}
+
+	for _, item := range qSubs {
+		acl, err := GetACL(projectUUID, "subscriptions", item.Name, store)
+		if err != nil {
+			// the resource was removed while exporting
+			if err.Error() == "not found" {
+				continue
+			}
+			return result, err
+		}
+		result.Subscriptions[item.Name] = append([]string{}, acl.AuthUsers...)
+	}
+
+	return result, nil
+}
+
+// ACLMembership is a topic or subscription whose acl includes a user
+type ACLMembership struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// PaginatedACLMemberships holds a page of the topics and subscriptions whose acls include a user
+type PaginatedACLMemberships struct {
+	Memberships   []ACLMembership `json:"acls"`
+	NextPageToken string          `json:"nextPageToken"`
+	TotalSize     int32           `json:"totalSize"`
+}
+
+// ExportJSON exports the acl memberships to json format
+func (pm *PaginatedACLMemberships) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(pm, "", "   ")
+	return string(output[:]), err
+}
+
+// FindACLMemberships returns a page of the project's topics and subscriptions whose acls include the user,
+// the topics first, each ordered by name. The page token holds the position that the page starts from
+func FindACLMemberships(projectUUID string, userUUID string, pageToken string, pageSize int32, store stores.Store) (PaginatedACLMemberships, error) {
+
+	result := PaginatedACLMemberships{Memberships: []ACLMembership{}}
+
+	start := 0
+	if pageToken != "" {
+		tokenBytes, err := base64.StdEncoding.DecodeString(pageToken)
+		if err != nil {
+			log.Errorf("Page token %v produced an error while being decoded to base64: %v", pageToken, err.Error())
+			return result, errors.New("invalid page token")
+		}
+		if start, err = strconv.Atoi(string(tokenBytes)); err != nil || start < 0 {
+			return result, errors.New("invalid page token")
+		}
+	}
+
+	qTopics, _, _, err := store.QueryTopics(projectUUID, "", "", "", 0, false, nil, "")
+	if err != nil {
+		return result, err
+	}
+
+	qSubs, _, _, err := store.QuerySubs(projectUUID, "", "", "", 0, nil, "")
+	if err != nil {
+		return result, err
+	}
+
+	topicNames := []string{}
+	for _, item := range qTopics {
+		topicNames = append(topicNames, item.Name)
+	}
+	subNames := []string{}
+	for _, item := range qSubs {
+		subNames = append(subNames, item.Name)
+	}
+	sort.Strings(topicNames)
+	sort.Strings(subNames)
+
+	memberships := []ACLMembership{}
+	collect := func(resourceType string, names []string) error {
+		for _, name := range names {
+			acl, err := store.QueryACL(projectUUID, resourceType, name)
+			if err != nil {
+				// the resource was removed while scanning
+				if err.Error() == "not found" {
+					continue
+				}
+				return err
+			}
+			for _, item := range acl.ACL {
+				if item == userUUID {
+					memberships = append(memberships, ACLMembership{Type: resourceType, Name: name})
+					break
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := collect("topics", topicNames); err != nil {
+		return result, err
+	}
+	if err := collect("subscriptions", subNames); err != nil {
+		return result, err
+	}
+
+	result.TotalSize = int32(len(memberships))
+	if start > len(memberships) {
+		return result, errors.New("invalid page token")
+	}
+
+	end := len(memberships)
+	if pageSize > 0 && start+int(pageSize) < end {
+		end = start + int(pageSize)
+		result.NextPageToken = base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(end)))
+	}
+	result.Memberships = append(result.Memberships, memberships[start:end]...)
+
+	return result, nil
+}
+
+// ImportProjectACLs replaces the acls of the given topics and subscriptions on behalf of the actor.
+// The store has no transactions, so if any modification fails the acls that have
+// already been replaced are restored to their previous state
+func ImportProjectACLs(projectUUID string, pacl ProjectACLs, actor string, store stores.Store) error {
+
+	type change struct {
+		resourceType string
+		name         string
+		previous     []string
+		acl          []string
+	}
+
+	changes := []change{}
+	for _, resourceType := range []string{"topics", "subscriptions"} {
+
+		acls := pacl.Topics
+		if resourceType == "subscriptions" {
+			acls = pacl.Subscriptions
+		}
+
+		names := []string{}
+		for name := range acls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			previous, err := store.QueryACL(projectUUID, resourceType, name)
+			if err != nil {
+				return err
+			}
+			changes = append(changes, change{resourceType: resourceType, name: name, previous: previous.ACL, acl: acls[name]})
+		}
+	}
+
+	for i, c := range changes {
+		err := ModACL(projectUUID, c.resourceType, c.name, c.acl, actor, store)
+		if err != nil {
+			for _, applied := range changes[:i] {
+				store.ModACL(projectUUID, applied.resourceType, applied.name, applied.previous)
+				InvalidateACL(projectUUID, applied.resourceType, applied.name)
+				RecordACLChange(projectUUID, applied.resourceType, applied.name, ACLOpRollback, actor, store)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// maxACLCacheEntries bounds the number of per resource authorization decisions kept in the cache
+const maxACLCacheEntries = 10000
+
+// aclCacheKey identifies a per resource authorization decision
+type aclCacheKey struct {
+	project  string
+	resType  string
+	resName  string
+	userUUID string
+}
+
+// aclDecision is a cached per resource authorization decision
+type aclDecision struct {
+	allowed bool
+	expires time.Time
+}
+
+// aclCache keeps the per resource authorization decisions for a limited time,
+// so that the hot publish and pull paths don't query the store on every request
+type aclCache struct {
+	sync.Mutex
+	ttl       time.Duration
+	decisions map[aclCacheKey]aclDecision
+	now       func() time.Time
+}
+
+var perResourceCache = &aclCache{decisions: map[aclCacheKey]aclDecision{}, now: time.Now}
+
+// SetACLCacheTTL sets how long the per resource authorization decisions are cached, zero disables the caching.
+// Acl modifications made through this node apply immediately, any other change applies once the ttl passes
+func SetACLCacheTTL(ttl time.Duration) {
+	perResourceCache.Lock()
+	defer perResourceCache.Unlock()
+	perResourceCache.ttl = ttl
+	perResourceCache.decisions = map[aclCacheKey]aclDecision{}
+}
+
+// InvalidateACL drops the cached authorization decisions about a resource, so that a change of its acl applies right away
+func InvalidateACL(project string, resType string, resName string) {
+	perResourceCache.Lock()
+	defer perResourceCache.Unlock()
+	for key := range perResourceCache.decisions {
+		if key.project == project && key.resType == resType && key.resName == resName {
+			delete(perResourceCache.decisions, key)
+		}
+	}
+}
+
+// InvalidateProjectACL drops the cached authorization decisions about all the resources of a project,
+// so that a change of its pattern grants applies right away
+func InvalidateProjectACL(project string) {
+	perResourceCache.Lock()
+	defer perResourceCache.Unlock()
+	for key := range perResourceCache.decisions {
+		if key.project == project {
+			delete(perResourceCache.decisions, key)
+		}
+	}
+}
+
+// patternCache keeps the compiled patterns of the pattern grants, keyed by their expression
+var patternCache = struct {
+	sync.Mutex
+	compiled map[string]*regexp.Regexp
+}{compiled: map[string]*regexp.Regexp{}}
+
+// compilePattern returns the compiled pattern, compiling it only the first time it is seen
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	patternCache.Lock()
+	defer patternCache.Unlock()
+
+	if re, found := patternCache.compiled[expr]; found {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patternCache.compiled) >= maxACLCacheEntries {
+		patternCache.compiled = map[string]*regexp.Regexp{}
+	}
+	patternCache.compiled[expr] = re
+
+	return re, nil
+}
+
+// matchesPatternACL returns true if the user is granted access to the resource by a pattern grant of the project
+func matchesPatternACL(project string, resType string, resName string, userUUID string, store stores.Store) bool {
+
+	qProjects, err := store.QueryProjects(project, "")
+	if err != nil || len(qProjects) == 0 || qProjects[0].PatternACL == nil {
+		return false
+	}
+
+	grants := qProjects[0].PatternACL.Topics
+	if resType == "subscriptions" {
+		grants = qProjects[0].PatternACL.Subscriptions
+	}
+
+	for _, grant := range grants {
+
+		granted := false
+		for _, u := range grant.Users {
+			if u == userUUID {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			continue
+		}
+
+		re, err := compilePattern(grant.Pattern)
+		if err != nil {
+			// patterns are validated when they are set, a stored invalid one grants nothing
+			continue
+		}
+
+		if re.MatchString(resName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// get returns the cached decision for the key, if caching is enabled and the decision hasn't expired
+func (c *aclCache) get(key aclCacheKey) (bool, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.ttl <= 0 {
+		return false, false
+	}
+
+	d, found := c.decisions[key]
+	if !found || !c.now().Before(d.expires) {
+		return false, false
+	}
+
+	return d.allowed, true
+}
+
+// set caches the decision for the key, making room by dropping the expired decisions once the cache is full
+func (c *aclCache) set(key aclCacheKey, allowed bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.ttl <= 0 {
+		return
+	}
+
+	now := c.now()
+	if len(c.decisions) >= maxACLCacheEntries {
+		for k, d := range c.decisions {
+			if !now.Before(d.expires) {
+				delete(c.decisions, k)
+			}
+		}
+		if len(c.decisions) >= maxACLCacheEntries {
+			c.decisions = map[aclCacheKey]aclDecision{}
+		}
+	}
+
+	c.decisions[key] = aclDecision{allowed: allowed, expires: now.Add(c.ttl)}
+}
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"testing"
@@ -135,6 +136,11 @@ func (suite *AuthTestSuite) TestAuth() {
 	suite.Equal(true, v)
 	suite.Equal(nil, err)
 
+	// duplicated usernames are reported once
+	v, err = AreValidUsers("ARGO", []string{"foo", "UserA", "foo", "bar", "usera"}, store)
+	suite.Equal(false, v)
+	suite.Equal("User(s): foo, bar, usera do not exist", err.Error())
+
 	// Test Find Method
 	expUserList := `{
    "users": [
@@ -649,6 +655,23 @@ func (suite *AuthTestSuite) TestAppendToUserProjects() {
 
 }
 
+func (suite *AuthTestSuite) TestUserMissingRoles() {
+
+	store := stores.NewMockStore("", "")
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	store.InsertUser("uuid_nr", []stores.QProjectRoles{{ProjectUUID: "argo_uuid"}}, "UserNoRoles", "", "", "", "", "T0K3N", "", nil, tm, tm, "")
+
+	// users stored without roles list them as empty instead of missing
+	usrs, _ := FindUsers("", "uuid_nr", "", true, store)
+	usrJSON, _ := json.Marshal(usrs.One())
+	suite.Contains(string(usrJSON), `"roles":[]`)
+	suite.Contains(string(usrJSON), `"service_roles":[]`)
+
+	usr, _ := GetUserByToken("T0K3N", store)
+	suite.Equal([]string{}, usr.ServiceRoles)
+	suite.Equal([]string{}, usr.Projects[0].Roles)
+}
+
 func (suite *AuthTestSuite) TestSubACL() {
 	expJSON01 := `{
    "authorized_users": [
@@ -787,39 +810,146 @@ func (suite *AuthTestSuite) TestModACL() {
 
 	store := stores.NewMockStore("", "")
 
-	e1 := ModACL("argo_uuid", "topics", "topic1", []string{"UserX", "UserZ"}, store)
+	e1 := ModACL("argo_uuid", "topics", "topic1", []string{"UserX", "UserZ"}, "uuid1", store)
 	suite.Nil(e1)
 
 	tACL1, _ := store.TopicsACL["topic1"]
 	suite.Equal([]string{"uuid3", "uuid4"}, tACL1.ACL)
 
-	e2 := ModACL("argo_uuid", "subscriptions", "sub1", []string{"UserX", "UserZ"}, store)
+	e2 := ModACL("argo_uuid", "subscriptions", "sub1", []string{"UserX", "UserZ"}, "uuid1", store)
 	suite.Nil(e2)
 
 	sACL1, _ := store.SubsACL["sub1"]
 	suite.Equal([]string{"uuid3", "uuid4"}, sACL1.ACL)
 
-	e3 := ModACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, store)
+	e3 := ModACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, "uuid1", store)
 	suite.Equal("wrong resource type", e3.Error())
 }
 
+func (suite *AuthTestSuite) TestDiffACL() {
+
+	store := stores.NewMockStore("", "")
+
+	d1, e1 := DiffACL("argo_uuid", "topics", "topic1", []string{"UserB", "UserX", "UserX"}, store)
+	suite.Nil(e1)
+	suite.Equal(ACLDiff{Added: []string{"UserX"}, Removed: []string{"UserA"}}, d1)
+
+	// nothing should have been persisted
+	tACL1, _ := store.TopicsACL["topic1"]
+	suite.Equal([]string{"uuid1", "uuid2"}, tACL1.ACL)
+
+	d2, e2 := DiffACL("argo_uuid", "subscriptions", "sub1", []string{"UserA", "UserB"}, store)
+	suite.Nil(e2)
+	suite.Equal(ACLDiff{Added: []string{}, Removed: []string{}}, d2)
+
+	_, e3 := DiffACL("argo_uuid", "topics", "unknown", []string{"UserA"}, store)
+	suite.Equal("not found", e3.Error())
+}
+
+func (suite *AuthTestSuite) TestProjectACLs() {
+
+	store := stores.NewMockStore("", "")
+
+	_, err := GetProjectACLsFromJSON([]byte(`{}`))
+	suite.Equal("wrong argument", err.Error())
+
+	pacl, err := GetProjectACLsFromJSON([]byte(`{"topics":{"topic1":["UserZ","UserA"]},"subscriptions":{"sub1":["UserA"],"unknown":["UserX"]}}`))
+	suite.Nil(err)
+	suite.Equal([]string{"UserA", "UserX", "UserZ"}, pacl.Users())
+
+	// a missing resource aborts the import before anything gets modified
+	suite.Equal("not found", ImportProjectACLs("argo_uuid", pacl, "uuid1", store).Error())
+	suite.Equal([]string{"uuid1", "uuid2"}, store.TopicsACL["topic1"].ACL)
+
+	delete(pacl.Subscriptions, "unknown")
+	suite.Nil(ImportProjectACLs("argo_uuid", pacl, "uuid1", store))
+
+	exported, err := ExportProjectACLs("argo_uuid", store)
+	suite.Nil(err)
+	suite.Equal([]string{"UserZ", "UserA"}, exported.Topics["topic1"])
+	suite.Equal([]string{"UserA"}, exported.Subscriptions["sub1"])
+	suite.Equal([]string{"UserX"}, exported.Topics["topic3"])
+}
+
+func (suite *AuthTestSuite) TestCaseInsensitiveUsernames() {
+
+	store := stores.NewMockStore("whatever", "argo_mgs")
+
+	v, err := AreValidUsers("argo_uuid", []string{"usera", "USERB", "UserB"}, store)
+	suite.False(v)
+	suite.Equal("User(s): usera, USERB do not exist", err.Error())
+
+	SetCaseInsensitiveUsernames(true)
+	defer SetCaseInsensitiveUsernames(false)
+
+	v, err = AreValidUsers("argo_uuid", []string{"usera", "USERB", "UserB"}, store)
+	suite.True(v)
+	suite.Nil(err)
+
+	// differently cased usernames are duplicates of each other, only the first one is reported
+	v, err = AreValidUsers("argo_uuid", []string{"usera", "Foo", "FOO", "foo"}, store)
+	suite.False(v)
+	suite.Equal("User(s): Foo do not exist", err.Error())
+
+	// the acl holds the users whose names differ only in case
+	suite.Nil(ModACL("argo_uuid", "topics", "topic1", []string{"usera", "UserB"}, "uuid1", store))
+	acl, _ := GetACL("argo_uuid", "topics", "topic1", store)
+	suite.Equal([]string{"UserA", "UserB"}, acl.AuthUsers)
+}
+
+func (suite *AuthTestSuite) TestACLHistory() {
+
+	store := stores.NewMockStore("whatever", "argo_mgs")
+
+	suite.Nil(ModACL("argo_uuid", "topics", "topic1", []string{"UserX", "UserZ"}, "uuid1", store))
+	suite.Nil(AppendToACL("argo_uuid", "topics", "topic1", []string{"UserB"}, "uuid1", store))
+	suite.Nil(RemoveFromACL("argo_uuid", "topics", "topic1", []string{"UserX"}, "uuid2", store))
+	// a failed modification isn't recorded
+	suite.NotNil(ModACL("argo_uuid", "topics", "unknown", []string{"UserX"}, "uuid1", store))
+
+	history, err := GetACLHistory("argo_uuid", "topics", "topic1", store)
+	suite.Nil(err)
+	suite.Equal(3, len(history.Changes))
+	suite.Equal(ACLChange{AuthUsers: []string{"UserX", "UserZ"}, Operation: ACLOpModify, ChangedBy: "UserA", ChangedOn: history.Changes[0].ChangedOn}, history.Changes[0])
+	suite.Equal([]string{"UserX", "UserZ", "UserB"}, history.Changes[1].AuthUsers)
+	suite.Equal(ACLOpAppend, history.Changes[1].Operation)
+	suite.Equal([]string{"UserZ", "UserB"}, history.Changes[2].AuthUsers)
+	suite.Equal(ACLOpRemove, history.Changes[2].Operation)
+	suite.Equal("UserB", history.Changes[2].ChangedBy)
+
+	// users that no longer exist are reported by their uuid
+	store.InsertACLChange("argo_uuid", "topics", "topic2", ACLOpModify, []string{"uuid_gone"}, "uuid_gone", time.Now())
+	history, _ = GetACLHistory("argo_uuid", "topics", "topic2", store)
+	suite.Equal([]string{"uuid_gone"}, history.Changes[0].AuthUsers)
+	suite.Equal("uuid_gone", history.Changes[0].ChangedBy)
+
+	// changes older than the retention are dropped once a new one gets recorded
+	SetACLHistoryRetention(24 * time.Hour)
+	defer SetACLHistoryRetention(0)
+	store.InsertACLChange("argo_uuid", "topics", "topic1", ACLOpModify, []string{}, "uuid1", time.Now().Add(-48*time.Hour))
+	suite.Nil(ModACL("argo_uuid", "topics", "topic1", []string{"UserA"}, "uuid1", store))
+	stored, _ := store.QueryACLHistory("argo_uuid", "topics", "topic1")
+	suite.Equal(4, len(stored))
+	suite.Equal([]string{"uuid1"}, stored[3].ACL)
+}
+
 func (suite *AuthTestSuite) TestAppendToACL() {
 
 	store := stores.NewMockStore("", "")
 
-	e1 := AppendToACL("argo_uuid", "topics", "topic1", []string{"UserX", "UserZ", "UserZ"}, store)
+	e1 := AppendToACL("argo_uuid", "topics", "topic1", []string{"UserX", "UserZ", "UserZ"}, "uuid1", store)
 	suite.Nil(e1)
 
 	tACL1, _ := store.TopicsACL["topic1"]
 	suite.Equal([]string{"uuid1", "uuid2", "uuid3", "uuid4"}, tACL1.ACL)
 
-	e2 := AppendToACL("argo_uuid", "subscriptions", "sub1", []string{"UserX", "UserZ", "UserZ"}, store)
+	e2 := AppendToACL("argo_uuid", "subscriptions", "sub1", []string{"UserX", "UserZ", "UserZ"}, "uuid1", store)
 	suite.Nil(e2)
 
 	sACL1, _ := store.SubsACL["sub1"]
 	suite.Equal([]string{"uuid1", "uuid2", "uuid3", "uuid4"}, sACL1.ACL)
 
-	e3 := AppendToACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, store)
+	e3 := AppendToACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, "uuid1", store)
 	suite.Equal("wrong resource type", e3.Error())
 }
 
@@ -827,19 +957,19 @@ func (suite *AuthTestSuite) TestRemoveFromACL() {
 
 	store := stores.NewMockStore("", "")
 
-	e1 := RemoveFromACL("argo_uuid", "topics", "topic1", []string{"UserA", "UserK"}, store)
+	e1 := RemoveFromACL("argo_uuid", "topics", "topic1", []string{"UserA", "UserK"}, "uuid1", store)
 	suite.Nil(e1)
 
 	tACL1, _ := store.TopicsACL["topic1"]
 	suite.Equal([]string{"uuid2"}, tACL1.ACL)
 
-	e2 := RemoveFromACL("argo_uuid", "subscriptions", "sub1", []string{"UserA", "UserK"}, store)
+	e2 := RemoveFromACL("argo_uuid", "subscriptions", "sub1", []string{"UserA", "UserK"}, "uuid1", store)
 	suite.Nil(e2)
 
 	sACL1, _ := store.SubsACL["sub1"]
 	suite.Equal([]string{"uuid2"}, sACL1.ACL)
 
-	e3 := RemoveFromACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, store)
+	e3 := RemoveFromACL("argo_uuid", "mistype", "sub1", []string{"UserX", "UserZ"}, "uuid1", store)
 	suite.Equal("wrong resource type", e3.Error())
 }
 
@@ -962,6 +1092,161 @@ func (suite *AuthTestSuite) TestFindUserRegistrations() {
 
 }
 
+func (suite *AuthTestSuite) TestFindRoles() {
+
+	store := stores.NewMockStore("", "")
+
+	expRoles := RoleList{Roles: []Role{
+		{Name: "admin", Operations: []string{"topics:list_all", "topics:publish"}},
+		{Name: "publisher", Operations: []string{"topics:list_all", "topics:publish"}},
+		{Name: "reader", Operations: []string{"topics:list_all"}},
+	}}
+
+	roles, err := FindRoles(store)
+	suite.Nil(err)
+	suite.Equal(expRoles, roles)
+
+	opRoles, err := FindOperationRoles("topics:list_all", store)
+	suite.Nil(err)
+	suite.Equal(OperationRoles{Operation: "topics:list_all", Roles: []string{"admin", "publisher", "reader"}}, opRoles)
+
+	_, err = FindOperationRoles("topics:unknown", store)
+	suite.Equal("not found", err.Error())
+}
+
+func (suite *AuthTestSuite) TestManageOperationRoles() {
+
+	store := stores.NewMockStore("", "")
+
+	opRoles, err := CreateOperationRoles("topics:show", []string{"reader", "custom_role"}, store)
+	suite.Nil(err)
+	suite.Equal(OperationRoles{Operation: "topics:show", Roles: []string{"custom_role", "reader"}}, opRoles)
+	suite.True(Authorize("topics:show", []string{"custom_role"}, store))
+
+	_, err = CreateOperationRoles("topics:show", []string{"reader"}, store)
+	suite.Equal("exists", err.Error())
+
+	opRoles, err = UpdateOperationRoles("topics:show", []string{"admin"}, store)
+	suite.Nil(err)
+	suite.Equal(OperationRoles{Operation: "topics:show", Roles: []string{"admin"}}, opRoles)
+	suite.False(Authorize("topics:show", []string{"custom_role"}, store))
+
+	_, err = UpdateOperationRoles("topics:unknown", []string{"admin"}, store)
+	suite.Equal("not found", err.Error())
+
+	suite.Nil(RemoveOperationRoles("topics:show", store))
+	suite.False(Authorize("topics:show", []string{"admin"}, store))
+	suite.Equal("not found", RemoveOperationRoles("topics:show", store).Error())
+}
+
+// aclCountingStore counts the acl lookups that reach the store
+type aclCountingStore struct {
+	*stores.MockStore
+	lookups int
+	err     error
+}
+
+func (s *aclCountingStore) ExistsInACL(projectUUID string, resource string, resourceName string, userUUID string) error {
+	s.lookups++
+	if s.err != nil {
+		return s.err
+	}
+	return s.MockStore.ExistsInACL(projectUUID, resource, resourceName, userUUID)
+}
+
+func (suite *AuthTestSuite) TestPerResourceCache() {
+
+	store := &aclCountingStore{MockStore: stores.NewMockStore("mockhost", "mockbase")}
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	perResourceCache.now = func() time.Time { return now }
+	SetACLCacheTTL(10 * time.Second)
+	defer func() {
+		SetACLCacheTTL(0)
+		perResourceCache.now = time.Now
+	}()
+
+	// repeated decisions are served from the cache, denials included
+	suite.True(PerResource("argo_uuid", "topics", "topic1", "uuid1", store))
+	suite.True(PerResource("argo_uuid", "topics", "topic1", "uuid1", store))
+	suite.False(PerResource("argo_uuid", "topics", "topic3", "uuid1", store))
+	suite.False(PerResource("argo_uuid", "topics", "topic3", "uuid1", store))
+	suite.Equal(2, store.lookups)
+
+	// an acl modification applies right away
+	suite.Nil(ModACL("argo_uuid", "topics", "topic1", []string{"UserB"}, "uuid1", store))
+	suite.False(PerResource("argo_uuid", "topics", "topic1", "uuid1", store))
+	suite.Equal(3, store.lookups)
+
+	suite.Nil(AppendToACL("argo_uuid", "topics", "topic3", []string{"UserA"}, "uuid1", store))
+	suite.True(PerResource("argo_uuid", "topics", "topic3", "uuid1", store))
+	suite.Equal(4, store.lookups)
+
+	// changes made behind the cache apply once the ttl passes
+	store.ModACL("argo_uuid", "topics", "topic3", []string{})
+	suite.True(PerResource("argo_uuid", "topics", "topic3", "uuid1", store))
+	now = now.Add(10 * time.Second)
+	suite.False(PerResource("argo_uuid", "topics", "topic3", "uuid1", store))
+	suite.Equal(5, store.lookups)
+
+	// a failing store denies the request without caching the denial
+	store.err = errors.New("backend error")
+	suite.False(PerResource("argo_uuid", "topics", "topic2", "uuid1", store))
+	store.err = nil
+	suite.True(PerResource("argo_uuid", "topics", "topic2", "uuid1", store))
+	suite.Equal(7, store.lookups)
+
+	// without a ttl every decision reaches the store
+	SetACLCacheTTL(0)
+	PerResource("argo_uuid", "topics", "topic1", "uuid2", store)
+	PerResource("argo_uuid", "topics", "topic1", "uuid2", store)
+	suite.Equal(9, store.lookups)
+}
+
+func (suite *AuthTestSuite) TestPatternACL() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	tm := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// uuid3 is only in the acl of topic3
+	suite.False(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+
+	store.UpdateProjectPatternACL("argo_uuid", &stores.QPatternACL{
+		Topics: []stores.QPatternGrant{
+			{Pattern: "^topic[12]$", Users: []string{"uuid3"}},
+			{Pattern: "4", Users: []string{"uuid2"}},
+		},
+		Subscriptions: []stores.QPatternGrant{
+			{Pattern: "^sub", Users: []string{"uuid3"}},
+		},
+	}, tm)
+
+	suite.True(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+	suite.True(PerResource("argo_uuid", "topics", "topic2", "uuid3", store))
+	suite.False(PerResource("argo_uuid", "topics", "topic4", "uuid3", store))
+	// exact matches keep applying
+	suite.True(PerResource("argo_uuid", "topics", "topic3", "uuid3", store))
+	// patterns are not anchored unless they say so
+	suite.True(PerResource("argo_uuid", "topics", "topic4", "uuid2", store))
+	// grants are kept per resource type
+	suite.True(PerResource("argo_uuid", "subscriptions", "sub1", "uuid3", store))
+	suite.False(PerResource("argo_uuid", "subscriptions", "sub1", "uuid4", store))
+	suite.False(PerResource("other_uuid", "topics", "topic1", "uuid3", store))
+
+	// a stored pattern that doesn't compile grants nothing
+	store.UpdateProjectPatternACL("argo_uuid", &stores.QPatternACL{Topics: []stores.QPatternGrant{{Pattern: "(", Users: []string{"uuid3"}}}}, tm)
+	suite.False(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+
+	// updating the pattern grants drops the cached decisions of the project
+	SetACLCacheTTL(10 * time.Second)
+	defer SetACLCacheTTL(0)
+	suite.False(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+	store.UpdateProjectPatternACL("argo_uuid", &stores.QPatternACL{Topics: []stores.QPatternGrant{{Pattern: "^topic", Users: []string{"uuid3"}}}}, tm)
+	suite.False(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+	InvalidateProjectACL("argo_uuid")
+	suite.True(PerResource("argo_uuid", "topics", "topic1", "uuid3", store))
+}
+
 func TestAuthTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthTestSuite))
 }
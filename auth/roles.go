@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// Role holds a role and the operations it grants access to
+type Role struct {
+	Name       string   `json:"name"`
+	Operations []string `json:"operations"`
+}
+
+// RoleList holds a list of roles
+type RoleList struct {
+	Roles []Role `json:"roles"`
+}
+
+// OperationRoles holds an operation and the roles that grant access to it
+type OperationRoles struct {
+	Operation string   `json:"operation"`
+	Roles     []string `json:"roles"`
+}
+
+// OperationList holds the operations that roles can grant access to
+type OperationList struct {
+	Operations []string `json:"operations"`
+}
+
+// NewOperationList returns the given operations sorted by name
+func NewOperationList(operations []string) OperationList {
+	ops := append([]string{}, operations...)
+	sort.Strings(ops)
+	return OperationList{Operations: ops}
+}
+
+// ExportJSON exports the operation list to json for use in http response
+func (ol *OperationList) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(ol, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports the role list to json for use in http response
+func (rl *RoleList) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(rl, "", "   ")
+	return string(output[:]), err
+}
+
+// ExportJSON exports the operation roles to json for use in http response
+func (or *OperationRoles) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(or, "", "   ")
+	return string(output[:]), err
+}
+
+// FindRoles returns all the roles along with the operations that each role grants access to
+func FindRoles(store stores.Store) (RoleList, error) {
+
+	result := RoleList{Roles: []Role{}}
+
+	qRoles, err := store.QueryRoles("")
+	if err != nil {
+		return result, err
+	}
+
+	operations := map[string][]string{}
+	for _, qRole := range qRoles {
+		for _, role := range qRole.Roles {
+			operations[role] = append(operations[role], qRole.Name)
+		}
+	}
+
+	for role, ops := range operations {
+		sort.Strings(ops)
+		result.Roles = append(result.Roles, Role{Name: role, Operations: ops})
+	}
+
+	sort.Slice(result.Roles, func(i, j int) bool {
+		return result.Roles[i].Name < result.Roles[j].Name
+	})
+
+	return result, nil
+}
+
+// FindOperationRoles returns the roles that grant access to the given operation
+func FindOperationRoles(operation string, store stores.Store) (OperationRoles, error) {
+
+	result := OperationRoles{Operation: operation, Roles: []string{}}
+
+	qRoles, err := store.QueryRoles(operation)
+	if err != nil {
+		return result, err
+	}
+
+	if len(qRoles) == 0 {
+		return result, errors.New("not found")
+	}
+
+	for _, qRole := range qRoles {
+		result.Roles = append(result.Roles, qRole.Roles...)
+	}
+
+	sort.Strings(result.Roles)
+
+	return result, nil
+}
+
+// GetOperationRolesFromJSON retrieves the roles of an operation from JSON
+func GetOperationRolesFromJSON(input []byte) (OperationRoles, error) {
+	or := OperationRoles{}
+	err := json.Unmarshal(input, &or)
+	if err != nil {
+		return or, err
+	}
+	if len(or.Roles) == 0 {
+		return or, errors.New("wrong argument")
+	}
+	return or, nil
+}
+
+// CreateOperationRoles maps a new operation to the roles that grant access to it
+func CreateOperationRoles(operation string, roles []string, store stores.Store) (OperationRoles, error) {
+
+	qRoles, err := store.QueryRoles(operation)
+	if err != nil {
+		return OperationRoles{}, err
+	}
+
+	if len(qRoles) > 0 {
+		return OperationRoles{}, errors.New("exists")
+	}
+
+	if err := store.InsertRole(operation, roles); err != nil {
+		return OperationRoles{}, err
+	}
+
+	return FindOperationRoles(operation, store)
+}
+
+// UpdateOperationRoles replaces the roles that grant access to an operation
+func UpdateOperationRoles(operation string, roles []string, store stores.Store) (OperationRoles, error) {
+
+	if err := store.UpdateRole(operation, roles); err != nil {
+		return OperationRoles{}, err
+	}
+
+	return FindOperationRoles(operation, store)
+}
+
+// RemoveOperationRoles removes the mapping of an operation to its roles
+func RemoveOperationRoles(operation string, store stores.Store) error {
+	return store.RemoveRole(operation)
+}
@@ -12,6 +12,7 @@ import (
 
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -224,12 +225,20 @@ func FindUserRegistrations(status, activationToken, name, email, org string, str
 }
 
 func UpdateUserRegistration(regUUID, status, modifiedBy string, modifiedAt time.Time, refStr stores.Store) error {
-	return refStr.UpdateRegistration(regUUID, status, modifiedBy, modifiedAt.Format("2006-01-02T15:04:05Z"))
+	return refStr.UpdateRegistration(regUUID, status, modifiedBy, timestamp.Format(modifiedAt))
 }
 
-// NewUser accepts parameters and creates a new user
+// NewUser accepts parameters and creates a new user.
+// Missing roles are listed as empty, so that users always have the same shape regardless of how they were stored
 func NewUser(uuid string, projects []ProjectRoles, name string, fname string, lname string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string) User {
-	zuluForm := "2006-01-02T15:04:05Z"
+	if serviceRoles == nil {
+		serviceRoles = []string{}
+	}
+	for i := range projects {
+		if projects[i].Roles == nil {
+			projects[i].Roles = []string{}
+		}
+	}
 	return User{
 		UUID:         uuid,
 		Projects:     projects,
@@ -241,8 +250,8 @@ func NewUser(uuid string, projects []ProjectRoles, name string, fname string, ln
 		Token:        token,
 		Email:        email,
 		ServiceRoles: serviceRoles,
-		CreatedOn:    createdOn.Format(zuluForm),
-		ModifiedOn:   modifiedOn.Format(zuluForm),
+		CreatedOn:    timestamp.Format(createdOn),
+		ModifiedOn:   timestamp.Format(modifiedOn),
 		CreatedBy:    createdBy}
 }
 
@@ -378,8 +387,21 @@ func FindUsers(projectUUID string, uuid string, name string, priviledged bool, s
 	return result, err
 }
 
-// PaginatedFindUsers returns a page of users
+// usersPageQuery queries the store for a page of users
+type usersPageQuery func(pageToken string, pageSize int32, projectUUID string) ([]stores.QUser, int32, string, error)
+
+// PaginatedFindUsers returns a page of users, the most recently created first
 func PaginatedFindUsers(pageToken string, pageSize int32, projectUUID string, privileged, detailedView bool, store stores.Store) (PaginatedUsers, error) {
+	return paginatedFindUsers(store.PaginatedQueryUsers, pageToken, pageSize, projectUUID, privileged, detailedView, store)
+}
+
+// PaginatedFindUsersByName returns a page of users ordered by name
+func PaginatedFindUsersByName(pageToken string, pageSize int32, projectUUID string, privileged, detailedView bool, store stores.Store) (PaginatedUsers, error) {
+	return paginatedFindUsers(store.PaginatedQueryUsersByName, pageToken, pageSize, projectUUID, privileged, detailedView, store)
+}
+
+// paginatedFindUsers returns the page of users that the query selects
+func paginatedFindUsers(query usersPageQuery, pageToken string, pageSize int32, projectUUID string, privileged, detailedView bool, store stores.Store) (PaginatedUsers, error) {
 
 	var totalSize int32
 	var nextPageToken string
@@ -395,7 +417,7 @@ func PaginatedFindUsers(pageToken string, pageSize int32, projectUUID string, pr
 
 	result := PaginatedUsers{Users: []User{}}
 
-	if users, totalSize, nextPageToken, err = store.PaginatedQueryUsers(string(pageTokenBytes), pageSize, projectUUID); err != nil {
+	if users, totalSize, nextPageToken, err = query(string(pageTokenBytes), pageSize, projectUUID); err != nil {
 		return result, err
 	}
 
@@ -829,9 +851,19 @@ func IsRoleValid(role string, validRoles []string) bool {
 	return false
 }
 
-// AreValidUsers accepts a user array of usernames and checks if users exist in the store
+// caseInsensitiveUsernames makes the usernames given for acls and project members match users regardless of their case
+var caseInsensitiveUsernames bool
+
+// SetCaseInsensitiveUsernames sets whether the usernames given for acls and project members match users regardless of their case.
+// By default the matching is case sensitive
+func SetCaseInsensitiveUsernames(enabled bool) {
+	caseInsensitiveUsernames = enabled
+}
+
+// AreValidUsers accepts a user array of usernames and checks if users exist in the store.
+// The missing usernames are reported once each, in the order they were given
 func AreValidUsers(projectUUID string, users []string, store stores.Store) (bool, error) {
-	found, notFound := store.HasUsers(projectUUID, users)
+	found, notFound := store.HasUsers(projectUUID, users, !caseInsensitiveUsernames)
 	if found {
 		return true, nil
 	}
@@ -851,15 +883,27 @@ func AreValidUsers(projectUUID string, users []string, store stores.Store) (bool
 }
 
 // PerResource  (for topics and subscriptions)
+// The acl of the resource is checked first, then the pattern grants of the project in their order.
+// Decisions are cached for the ttl set through SetACLCacheTTL
 func PerResource(project string, resType string, resName string, userUUID string, store stores.Store) bool {
 
 	if resType == "topics" || resType == "subscriptions" {
+		key := aclCacheKey{project: project, resType: resType, resName: resName, userUUID: userUUID}
+		if allowed, found := perResourceCache.get(key); found {
+			return allowed
+		}
+
 		err := store.ExistsInACL(project, resType, resName, userUUID)
-		if err != nil {
+		if err != nil && !matchesPatternACL(project, resType, resName, userUUID, store) {
 			log.Errorln(err.Error())
+			// only a user missing from the acl is a decision, a failing store is asked again by the next request
+			if err.Error() == "not found" {
+				perResourceCache.set(key, false)
+			}
 			return false
 		}
 
+		perResourceCache.set(key, true)
 		return true
 
 	}
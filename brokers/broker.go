@@ -13,12 +13,83 @@ type Broker interface {
 	InitConfig()
 	Initialize(peers []string)
 	CloseConnections()
-	Publish(topic string, payload messages.Message) (string, string, int, int64, error)
+	Publish(topic string, payload messages.Message, acks string, key string) (string, string, int, int64, error)
+	PublishBatch(topic string, msgs []messages.Message, keys []string) ([]string, string, int, int64, error)
 	GetMinOffset(topic string) int64
 	GetMaxOffset(topic string) int64
-	Consume(ctx context.Context, topic string, offset int64, imm bool, max int64) ([]string, error)
+	Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error)
 	DeleteTopic(topic string) error
 	TimeToOffset(topic string, time time.Time) (int64, error)
+	DescribeTopic(topic string) (TopicConfig, error)
+	CreateTopic(topic string, partitions int, replicationFactor int) (TopicConfig, error)
+	ConsumeRange(ctx context.Context, topic string, from int64, to int64) ([]string, error)
+	DeleteRecordsBefore(topic string, offset int64) error
+	SupportsIsolationLevel(level string) bool
+	SupportsTimeIndex() bool
+	Type() string
+	Version() string
 }
 
 var ErrOffsetOff = errors.New("Offset is off")
+
+// ErrIntrospectionUnsupported is returned when the broker can't report a topic's configuration
+var ErrIntrospectionUnsupported = errors.New("Topic introspection is not supported by the broker")
+
+// ErrTimeIndexUnsupported is returned when the broker can't look up offsets by timestamp
+var ErrTimeIndexUnsupported = errors.New("Time indexed offset lookups are not supported by the broker")
+
+// ErrDeleteRecordsUnsupported is returned when the broker can't delete the records of a topic on demand
+var ErrDeleteRecordsUnsupported = errors.New("Deleting records is not supported by the broker")
+
+// ErrTransactionsUnsupported is returned when the broker can't publish a batch of messages atomically
+var ErrTransactionsUnsupported = errors.New("Transactional publishing is not supported by the broker")
+
+// ErrTopicNotFound is returned when the topic doesn't exist on the broker
+var ErrTopicNotFound = errors.New("topic not found on the broker")
+
+// ErrInvalidTopicConfig is returned when the requested topic settings exceed the broker limits
+var ErrInvalidTopicConfig = errors.New("Invalid topic configuration")
+
+// Defaults used when creating a topic without specifying its settings
+const (
+	DefaultPartitions        = 1
+	DefaultReplicationFactor = 3
+)
+
+// TopicConfig holds the broker side metadata of a topic
+type TopicConfig struct {
+	Partitions        int   `json:"partitions"`
+	ReplicationFactor int   `json:"replication_factor"`
+	RetentionMs       int64 `json:"retention_ms"`
+	RetentionBytes    int64 `json:"retention_bytes"`
+}
+
+// Publish acknowledgement levels, defining how many broker replicas have to persist a message
+// before a publish is considered successful
+const (
+	// AcksNone doesn't wait for any acknowledgement
+	AcksNone = "0"
+	// AcksLeader waits only for the leader of the partition
+	AcksLeader = "1"
+	// AcksAll waits for all the in-sync replicas
+	AcksAll = "all"
+)
+
+// ValidAcksLevel checks whether the given publish acknowledgement level is supported
+func ValidAcksLevel(level string) bool {
+	return level == AcksNone || level == AcksLeader || level == AcksAll
+}
+
+// Consumer isolation levels, defining whether the messages of transactions that haven't been committed yet
+// are delivered to the consumers
+const (
+	// IsolationReadCommitted delivers only the messages of committed transactions, along with the non transactional ones
+	IsolationReadCommitted = "read_committed"
+	// IsolationReadUncommitted delivers every message, regardless of the state of the transaction it was published in
+	IsolationReadUncommitted = "read_uncommitted"
+)
+
+// ValidIsolationLevel checks whether the given consumer isolation level is supported
+func ValidIsolationLevel(level string) bool {
+	return level == IsolationReadCommitted || level == IsolationReadUncommitted
+}
@@ -2,7 +2,9 @@ package brokers
 
 import (
 	"context"
+	"fmt"
 	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	"github.com/Shopify/sarama"
 	log "github.com/sirupsen/logrus"
 	"strconv"
@@ -25,8 +27,12 @@ type KafkaBroker struct {
 	consumeLock     map[string]*topicLock
 	Config          *sarama.Config
 	Producer        sarama.SyncProducer
+	producers       map[string]sarama.SyncProducer
+	producersLock   sync.Mutex
 	Client          sarama.Client
 	Consumer        sarama.Consumer
+	consumers       map[string]sarama.Consumer
+	consumersLock   sync.Mutex
 	Servers         []string
 }
 
@@ -58,28 +64,45 @@ func (b *KafkaBroker) unlockForTopic(topic string) {
 
 }
 
+// Type returns the type of the broker backend
+func (b *KafkaBroker) Type() string {
+	return "kafka"
+}
+
+// Version returns the kafka protocol version the broker clients have been configured with
+func (b *KafkaBroker) Version() string {
+	if b.Config == nil {
+		return ""
+	}
+	return b.Config.Version.String()
+}
+
 // CloseConnections closes open producer, consumer and client
 func (b *KafkaBroker) CloseConnections() {
-	// Close Producer
-	if err := b.Producer.Close(); err != nil {
-		log.WithFields(
-			log.Fields{
-				"type":            "backend_log",
-				"backend_service": "kafka",
-				"backend_hosts":   b.Servers,
-			},
-		).Fatal(err.Error())
+	// Close Producers, the default one is also registered under the acks=all level
+	for _, producer := range b.producers {
+		if err := producer.Close(); err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"backend_service": "kafka",
+					"backend_hosts":   b.Servers,
+				},
+			).Fatal(err.Error())
+		}
 	}
 
-	// Close Consumer
-	if err := b.Consumer.Close(); err != nil {
-		log.WithFields(
-			log.Fields{
-				"type":            "backend_log",
-				"backend_service": "kafka",
-				"backend_hosts":   b.Servers,
-			},
-		).Fatal(err.Error())
+	// Close Consumers, the default one is also registered under the read_committed level
+	for _, consumer := range b.consumers {
+		if err := consumer.Close(); err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"backend_service": "kafka",
+					"backend_hosts":   b.Servers,
+				},
+			).Fatal(err.Error())
+		}
 	}
 
 	// Close Client
@@ -149,9 +172,13 @@ func (b *KafkaBroker) init(peers []string) error {
 	b.Config = sarama.NewConfig()
 	b.Config.Admin.Timeout = 30 * time.Second
 	b.Config.Consumer.Fetch.Default = 1000000
+	b.Config.Consumer.IsolationLevel = sarama.ReadCommitted
 	b.Config.Producer.RequiredAcks = sarama.WaitForAll
 	b.Config.Producer.Retry.Max = 5
 	b.Config.Producer.Return.Successes = true
+	// messages are consumed only from the first partition of a topic,
+	// so they should always be published there regardless of the topic's partition count
+	b.Config.Producer.Partitioner = sarama.NewManualPartitioner
 	b.Config.Version = sarama.V2_1_0_0
 	b.Servers = peers
 
@@ -167,34 +194,138 @@ func (b *KafkaBroker) init(peers []string) error {
 		return err
 	}
 
+	b.producers = map[string]sarama.SyncProducer{AcksAll: b.Producer}
+
 	b.Consumer, err = sarama.NewConsumer(b.Servers, b.Config)
 	if err != nil {
 		return err
 	}
 
+	b.consumers = map[string]sarama.Consumer{IsolationReadCommitted: b.Consumer}
+
 	return nil
 }
 
-// Publish function publish a message to the broker
-func (b *KafkaBroker) Publish(topic string, msg messages.Message) (string, string, int, int64, error) {
+// producerFor returns the producer that waits for the given acknowledgement level,
+// creating it on first use since sarama configures acks per producer
+func (b *KafkaBroker) producerFor(acks string) (sarama.SyncProducer, error) {
+
+	if acks == "" {
+		acks = AcksAll
+	}
+
+	b.producersLock.Lock()
+	defer b.producersLock.Unlock()
+
+	if producer, ok := b.producers[acks]; ok {
+		return producer, nil
+	}
+
+	cfg := *b.Config
+	switch acks {
+	case AcksNone:
+		cfg.Producer.RequiredAcks = sarama.NoResponse
+	case AcksLeader:
+		cfg.Producer.RequiredAcks = sarama.WaitForLocal
+	default:
+		return nil, fmt.Errorf("unsupported acks level %v", acks)
+	}
+
+	producer, err := sarama.NewSyncProducer(b.Servers, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b.producers[acks] = producer
+	return producer, nil
+}
+
+// consumerFor returns the consumer that reads with the given isolation level,
+// creating it on first use since sarama configures the isolation level per consumer
+func (b *KafkaBroker) consumerFor(isolation string) (sarama.Consumer, error) {
+
+	if isolation == "" {
+		isolation = IsolationReadCommitted
+	}
+
+	b.consumersLock.Lock()
+	defer b.consumersLock.Unlock()
+
+	if consumer, ok := b.consumers[isolation]; ok {
+		return consumer, nil
+	}
+
+	cfg := *b.Config
+	switch isolation {
+	case IsolationReadUncommitted:
+		cfg.Consumer.IsolationLevel = sarama.ReadUncommitted
+	default:
+		return nil, fmt.Errorf("unsupported isolation level %v", isolation)
+	}
+
+	consumer, err := sarama.NewConsumer(b.Servers, &cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	b.consumers[isolation] = consumer
+	return consumer, nil
+}
+
+// SupportsIsolationLevel checks whether the kafka version the broker speaks can consume with the isolation level,
+// reading only committed messages requires the transactions introduced in kafka 0.11
+func (b *KafkaBroker) SupportsIsolationLevel(level string) bool {
+	switch level {
+	case IsolationReadUncommitted:
+		return true
+	case IsolationReadCommitted:
+		return b.Config.Version.IsAtLeast(sarama.V0_11_0_0)
+	}
+	return false
+}
+
+// Publish function publish a message to the broker, waiting for the given acknowledgement level.
+// A non empty key is stored as the key of the kafka record, which is what compacted topics keep the latest record of.
+// The record is still written to the consumed partition. The returned offset is -1 when no acknowledgement is waited for,
+// since kafka reports the offsets only along with the acknowledgements
+func (b *KafkaBroker) Publish(topic string, msg messages.Message, acks string, key string) (string, string, int, int64, error) {
+
+	producer, err := b.producerFor(acks)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "kafka",
+				"topic":           topic,
+				"acks":            acks,
+				"error":           err.Error(),
+			},
+		).Errorf("Could not create producer for topic")
+
+		return msg.ID, topic, 0, 0, err
+	}
 
 	off := b.GetMaxOffset(topic)
 	msg.ID = strconv.FormatInt(off, 10)
-	// Stamp time to UTC Z to nanoseconds
-	zNano := "2006-01-02T15:04:05.999999999Z"
-	// Timestamp on publish time -- should be in UTC
-	t := time.Now().UTC()
-	msg.PubTime = t.Format(zNano)
+	// Timestamp on publish time in UTC with nanoseconds, unless the message already carries one
+	if msg.PubTime == "" {
+		msg.PubTime = timestamp.FormatNano(time.Now())
+	}
 
 	// Publish the message
 	payload, _ := msg.ExportJSON()
 
 	msgFinal := &sarama.ProducerMessage{
-		Topic: topic,
-		Value: sarama.StringEncoder(payload),
+		Topic:     topic,
+		Partition: 0,
+		Value:     sarama.StringEncoder(payload),
+	}
+
+	if key != "" {
+		msgFinal.Key = sarama.StringEncoder(key)
 	}
 
-	partition, offset, err := b.Producer.SendMessage(msgFinal)
+	partition, offset, err := producer.SendMessage(msgFinal)
 	if err != nil {
 		log.WithFields(
 			log.Fields{
@@ -208,10 +339,104 @@ func (b *KafkaBroker) Publish(topic string, msg messages.Message) (string, strin
 		return msg.ID, topic, int(partition), offset, err
 	}
 
+	if acks == AcksNone {
+		offset = -1
+	}
+
 	return msg.ID, topic, int(partition), offset, nil
 
 }
 
+// PublishBatch publishes the messages as a single record batch, which kafka appends to the partition atomically,
+// so either all of the messages get published and become visible to the consumers or none of them does.
+// The batch always waits for all the in-sync replicas, since its outcome has to be known. The keys are those of
+// the messages at the same position, empty keys leaving the records without one.
+// Along with the ids it returns the partition and the offset that the batch's first message was written to
+func (b *KafkaBroker) PublishBatch(topic string, msgs []messages.Message, keys []string) ([]string, string, int, int64, error) {
+
+	// record batches were introduced in kafka 0.11
+	if !b.Config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, topic, 0, 0, ErrTransactionsUnsupported
+	}
+
+	if len(msgs) == 0 {
+		return []string{}, topic, 0, 0, nil
+	}
+
+	leader, err := b.Client.Leader(topic, 0)
+	if err != nil {
+		return nil, topic, 0, 0, err
+	}
+
+	now := time.Now()
+	batch := &sarama.RecordBatch{
+		Version:         2,
+		FirstTimestamp:  now,
+		MaxTimestamp:    now,
+		ProducerID:      -1,
+		ProducerEpoch:   -1,
+		LastOffsetDelta: int32(len(msgs) - 1),
+	}
+
+	// the offsets of the messages are only known once kafka has appended the batch, so their records are
+	// stored without an id and the consumers use the offset of the record instead
+	for i, msg := range msgs {
+		msg.ID = ""
+		// Timestamp on publish time in UTC with nanoseconds, unless the message already carries one
+		if msg.PubTime == "" {
+			msg.PubTime = timestamp.FormatNano(now)
+		}
+		payload, _ := msg.ExportJSON()
+
+		record := &sarama.Record{OffsetDelta: int64(i), Value: []byte(payload)}
+		if i < len(keys) && keys[i] != "" {
+			record.Key = []byte(keys[i])
+		}
+		batch.Records = append(batch.Records, record)
+	}
+
+	req := &sarama.ProduceRequest{
+		RequiredAcks: sarama.WaitForAll,
+		Timeout:      int32(b.Config.Producer.Timeout / time.Millisecond),
+		Version:      3,
+	}
+	req.AddBatch(topic, 0, batch)
+
+	var baseOffset int64
+	resp, err := leader.Produce(req)
+	if err == nil {
+		if block := resp.GetBlock(topic, 0); block == nil {
+			err = fmt.Errorf("no produce response for topic %v", topic)
+		} else if block.Err != sarama.ErrNoError {
+			err = block.Err
+		} else {
+			baseOffset = block.Offset
+		}
+	}
+
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "kafka",
+				"topic":           topic,
+				"messages":        len(msgs),
+				"error":           err.Error(),
+			},
+		).Errorf("Could not publish batch to topic, none of its messages got published")
+
+		return nil, topic, 0, 0, err
+	}
+
+	// the batch was appended as a whole, so its messages follow the offset kafka assigned to the first one
+	ids := make([]string, 0, len(msgs))
+	for i := range msgs {
+		ids = append(ids, strconv.FormatInt(baseOffset+int64(i), 10))
+	}
+
+	return ids, topic, 0, baseOffset, nil
+}
+
 // GetOffset returns a current topic's offset
 func (b *KafkaBroker) GetMaxOffset(topic string) int64 {
 	// Fetch offset
@@ -246,9 +471,18 @@ func (b *KafkaBroker) GetMinOffset(topic string) int64 {
 	return loff
 }
 
+// SupportsTimeIndex checks whether the kafka version the broker speaks can look offsets up by timestamp,
+// message timestamps were introduced in kafka 0.10.1
+func (b *KafkaBroker) SupportsTimeIndex() bool {
+	return b.Config.Version.IsAtLeast(sarama.V0_10_1_0)
+}
+
 // TimeToOffset returns the offset of the first message with a timestamp equal or
 // greater than the time given.
 func (b *KafkaBroker) TimeToOffset(topic string, t time.Time) (int64, error) {
+	if !b.SupportsTimeIndex() {
+		return -1, ErrTimeIndexUnsupported
+	}
 	return b.Client.GetOffset(topic, 0, t.UnixNano()/int64(time.Millisecond))
 }
 
@@ -272,8 +506,217 @@ func (b *KafkaBroker) DeleteTopic(topic string) error {
 	return clusterAdmin.DeleteTopic(topic)
 }
 
-// Consume function to consume a message from the broker
-func (b *KafkaBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64) ([]string, error) {
+// DeleteRecordsBefore makes the Kafka cluster delete the records of the topic's partition
+// that precede the given offset, ahead of the topic's retention
+func (b *KafkaBroker) DeleteRecordsBefore(topic string, offset int64) error {
+
+	// deleting records requires kafka >= 0.11
+	if !b.Config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return ErrDeleteRecordsUnsupported
+	}
+
+	clusterAdmin, err := sarama.NewClusterAdmin(b.Servers, b.Config)
+	if err != nil {
+		return err
+	}
+
+	defer clusterAdmin.Close()
+
+	err = clusterAdmin.DeleteRecords(topic, map[int32]int64{0: offset})
+	if err == sarama.ErrUnsupportedVersion {
+		return ErrDeleteRecordsUnsupported
+	}
+
+	return err
+}
+
+// DescribeTopic retrieves the partition count, replication factor and retention settings
+// of a topic from the Kafka cluster. Clusters that can't describe configs, i.e. older than kafka 0.11,
+// are reported through ErrIntrospectionUnsupported
+func (b *KafkaBroker) DescribeTopic(topic string) (TopicConfig, error) {
+
+	clusterAdmin, err := sarama.NewClusterAdmin(b.Servers, b.Config)
+	if err != nil {
+		return TopicConfig{}, err
+	}
+
+	defer clusterAdmin.Close()
+
+	metadata, err := clusterAdmin.DescribeTopics([]string{topic})
+	if err != nil {
+		if err == sarama.ErrUnsupportedVersion {
+			return TopicConfig{}, ErrIntrospectionUnsupported
+		}
+		return TopicConfig{}, err
+	}
+
+	if len(metadata) == 0 || metadata[0].Err == sarama.ErrUnknownTopicOrPartition {
+		return TopicConfig{}, ErrTopicNotFound
+	}
+
+	if metadata[0].Err != sarama.ErrNoError {
+		return TopicConfig{}, metadata[0].Err
+	}
+
+	topicCfg := TopicConfig{
+		Partitions: len(metadata[0].Partitions),
+	}
+
+	if len(metadata[0].Partitions) > 0 {
+		topicCfg.ReplicationFactor = len(metadata[0].Partitions[0].Replicas)
+	}
+
+	entries, err := clusterAdmin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        topic,
+		ConfigNames: []string{"retention.ms", "retention.bytes"},
+	})
+	if err != nil {
+		if err == sarama.ErrUnsupportedVersion {
+			return TopicConfig{}, ErrIntrospectionUnsupported
+		}
+		return TopicConfig{}, err
+	}
+
+	for _, entry := range entries {
+		value, err := strconv.ParseInt(entry.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch entry.Name {
+		case "retention.ms":
+			topicCfg.RetentionMs = value
+		case "retention.bytes":
+			topicCfg.RetentionBytes = value
+		}
+	}
+
+	return topicCfg, nil
+}
+
+// CreateTopic creates the topic on the Kafka cluster with the given partition count and replication factor,
+// zero values fall back to the defaults. If the topic already exists its current configuration is returned.
+func (b *KafkaBroker) CreateTopic(topic string, partitions int, replicationFactor int) (TopicConfig, error) {
+
+	availableBrokers := len(b.Client.Brokers())
+
+	if partitions == 0 {
+		partitions = DefaultPartitions
+	}
+
+	if replicationFactor == 0 {
+		replicationFactor = DefaultReplicationFactor
+		if replicationFactor > availableBrokers {
+			replicationFactor = availableBrokers
+		}
+	}
+
+	if replicationFactor > availableBrokers {
+		return TopicConfig{}, fmt.Errorf("%w: replication factor %v is larger than the %v available brokers",
+			ErrInvalidTopicConfig, replicationFactor, availableBrokers)
+	}
+
+	clusterAdmin, err := sarama.NewClusterAdmin(b.Servers, b.Config)
+	if err != nil {
+		return TopicConfig{}, err
+	}
+
+	b.lockForTopic(topic)
+
+	err = clusterAdmin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     int32(partitions),
+		ReplicationFactor: int16(replicationFactor),
+	}, false)
+
+	b.unlockForTopic(topic)
+	clusterAdmin.Close()
+
+	if err != nil {
+		if topicErr, ok := err.(*sarama.TopicError); !ok || topicErr.Err != sarama.ErrTopicAlreadyExists {
+			return TopicConfig{}, err
+		}
+	}
+
+	topicCfg, err := b.DescribeTopic(topic)
+	if err != nil {
+		// the topic has been created, report at least the requested settings
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "kafka",
+				"topic":           topic,
+				"error":           err.Error(),
+			},
+		).Warning("Could not describe the newly created topic")
+		return TopicConfig{Partitions: partitions, ReplicationFactor: replicationFactor}, nil
+	}
+
+	return topicCfg, nil
+}
+
+// ConsumeRange reads the messages of a topic with offsets in the [from, to) range, without waiting for new ones.
+// The range is limited to the offsets currently available on the broker.
+func (b *KafkaBroker) ConsumeRange(ctx context.Context, topic string, from int64, to int64) ([]string, error) {
+
+	b.lockForTopic(topic)
+
+	defer b.unlockForTopic(topic)
+
+	loff, err := b.Client.GetOffset(topic, 0, sarama.OffsetNewest)
+	if err != nil {
+		return []string{}, err
+	}
+
+	oldOff, err := b.Client.GetOffset(topic, 0, sarama.OffsetOldest)
+	if err != nil {
+		return []string{}, err
+	}
+
+	if from < oldOff {
+		from = oldOff
+	}
+
+	if to > loff {
+		to = loff
+	}
+
+	if from >= to {
+		return []string{}, nil
+	}
+
+	partitionConsumer, err := b.Consumer.ConsumePartition(topic, 0, from)
+	if err != nil {
+		return []string{}, err
+	}
+
+	defer partitionConsumer.Close()
+
+	messages := []string{}
+	timeout := time.After(5 * time.Second)
+
+	for int64(len(messages)) < to-from {
+		select {
+		case <-ctx.Done():
+			return messages, nil
+		case <-timeout:
+			return messages, nil
+		case msg := <-partitionConsumer.Messages():
+			if msg.Offset >= to {
+				return messages, nil
+			}
+			messages = append(messages, string(msg.Value[:]))
+			// compaction may have removed records of the range, so fewer than requested can be left
+			if msg.Offset >= to-1 {
+				return messages, nil
+			}
+		}
+	}
+
+	return messages, nil
+}
+
+// Consume function to consume a message from the broker, reading with the given isolation level
+func (b *KafkaBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
 
 	b.lockForTopic(topic)
 
@@ -320,7 +763,21 @@ func (b *KafkaBroker) Consume(ctx context.Context, topic string, offset int64, i
 		return []string{}, ErrOffsetOff
 	}
 
-	partitionConsumer, err := b.Consumer.ConsumePartition(topic, 0, offset)
+	consumer, err := b.consumerFor(isolation)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "kafka",
+				"topic":           topic,
+				"isolation":       isolation,
+				"error":           err.Error(),
+			},
+		).Errorf("Could not create consumer for topic")
+		return []string{}, err
+	}
+
+	partitionConsumer, err := consumer.ConsumePartition(topic, 0, offset)
 
 	if err != nil {
 		log.WithFields(
@@ -388,7 +845,8 @@ ConsumerLoop:
 				break ConsumerLoop
 			}
 
-			if offset+consumed > loff-1 {
+			// compacted topics may have gaps between their offsets, so the last read one tells whether the end was reached
+			if msg.Offset >= loff-1 {
 				// if returnImmediately is set don't wait for more
 				if imm {
 					break ConsumerLoop
@@ -1,8 +1,13 @@
 package brokers
 
 import (
+	"context"
 	"testing"
+	"time"
 
+	"github.com/ARGOeu/argo-messaging/messages"
+
+	"github.com/Shopify/sarama"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -23,6 +28,138 @@ func (suite *BrokerTestSuite) TestPublish() {
 
 }
 
+func (suite *BrokerTestSuite) TestValidAcksLevel() {
+	suite.True(ValidAcksLevel("0"))
+	suite.True(ValidAcksLevel("1"))
+	suite.True(ValidAcksLevel("all"))
+	suite.False(ValidAcksLevel(""))
+	suite.False(ValidAcksLevel("-1"))
+	suite.False(ValidAcksLevel("ALL"))
+}
+
+func (suite *BrokerTestSuite) TestValidIsolationLevel() {
+	suite.True(ValidIsolationLevel("read_committed"))
+	suite.True(ValidIsolationLevel("read_uncommitted"))
+	suite.False(ValidIsolationLevel(""))
+	suite.False(ValidIsolationLevel("READ_COMMITTED"))
+}
+
+func (suite *BrokerTestSuite) TestSupportsIsolationLevel() {
+
+	brk := KafkaBroker{Config: sarama.NewConfig()}
+	brk.Config.Version = sarama.V2_1_0_0
+	suite.True(brk.SupportsIsolationLevel(IsolationReadCommitted))
+	suite.True(brk.SupportsIsolationLevel(IsolationReadUncommitted))
+	suite.False(brk.SupportsIsolationLevel(""))
+
+	// transactions were introduced in kafka 0.11
+	brk.Config.Version = sarama.V0_10_2_0
+	suite.False(brk.SupportsIsolationLevel(IsolationReadCommitted))
+	suite.True(brk.SupportsIsolationLevel(IsolationReadUncommitted))
+}
+
+func (suite *BrokerTestSuite) TestPool() {
+
+	created := 0
+	pool := NewPool(2, func() Broker {
+		created++
+		brk := &MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		return brk
+	})
+
+	// sequential requests reuse the same broker instead of connecting new ones
+	for i := 0; i < 10; i++ {
+		brk, release, err := Acquire(context.Background(), pool)
+		suite.Nil(err)
+		_, _, _, _, err = brk.Publish("argo_uuid.topic", messages.New("ZGF0YQ=="), AcksAll, "")
+		suite.Nil(err)
+		release()
+	}
+	for i := 0; i < 10; i++ {
+		pool.Publish("argo_uuid.topic", messages.New("ZGF0YQ=="), AcksAll, "")
+	}
+	suite.Equal(1, created)
+	suite.Equal(PoolStats{Size: 2, Created: 1, Active: 0, Idle: 1}, pool.Stats())
+
+	// a second broker is connected only while the first one is in use
+	b1, err := pool.Acquire(context.Background())
+	suite.Nil(err)
+	b2, err := pool.Acquire(context.Background())
+	suite.Nil(err)
+	suite.NotEqual(b1, b2)
+	suite.Equal(2, created)
+	suite.Equal(2, pool.Stats().Active)
+	suite.Equal(0, pool.Stats().Idle)
+
+	// an exhausted pool makes acquisitions wait until the context expires
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Acquire(ctx)
+	suite.Equal(context.DeadlineExceeded, err)
+
+	// or a broker gets released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		pool.Release(b1)
+	}()
+	b3, err := pool.Acquire(context.Background())
+	suite.Nil(err)
+	suite.Equal(b1, b3)
+	suite.Equal(2, created)
+
+	stats := pool.Stats()
+	suite.Equal(int64(1), stats.Waits)
+	suite.True(stats.WaitTime > 0)
+
+	pool.Release(b2)
+	pool.Release(b3)
+	suite.Equal(0, pool.Stats().Active)
+	suite.Equal(2, pool.Stats().Idle)
+
+	// brokers that aren't pools are handed out as they are
+	mock := &MockBroker{}
+	brk, release, err := Acquire(context.Background(), mock)
+	suite.Nil(err)
+	suite.Equal(mock, brk)
+	release()
+}
+
+func (suite *BrokerTestSuite) TestTopicName() {
+
+	suite.Equal("", TopicPrefix())
+	suite.Equal("argo_uuid.topic1", TopicName("argo_uuid", "topic1"))
+
+	suite.Nil(SetTopicPrefix("devel.cluster-1"))
+	defer SetTopicPrefix("")
+	suite.Equal("devel.cluster-1", TopicPrefix())
+	suite.Equal("devel.cluster-1.argo_uuid.topic1", TopicName("argo_uuid", "topic1"))
+
+	// invalid prefixes leave the current one in place
+	for _, prefix := range []string{"devel/", ".devel", "devel.", "dev el", "devel..1"} {
+		suite.NotNil(SetTopicPrefix(prefix), prefix)
+	}
+	suite.Equal("devel.cluster-1", TopicPrefix())
+
+	suite.Nil(SetTopicPrefix(""))
+	suite.Equal("argo_uuid.topic1", TopicName("argo_uuid", "topic1"))
+}
+
+func (suite *BrokerTestSuite) TestMockPublishTopic() {
+
+	brk := MockBroker{}
+	brk.Initialize([]string{"localhost"})
+
+	suite.Nil(SetTopicPrefix("devel"))
+	defer SetTopicPrefix("")
+
+	// the mock reports back the broker topic it was given, prefix included
+	topic := TopicName("argo_uuid", "topic1")
+	_, rTop, _, _, err := brk.Publish(topic, messages.Message{Data: "dGVzdA=="}, "all", "")
+	suite.Nil(err)
+	suite.Equal("devel.argo_uuid.topic1", rTop)
+}
+
 func TestBrokersTestSuite(t *testing.T) {
 	suite.Run(t, new(BrokerTestSuite))
 }
@@ -7,7 +7,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/messages"
-	"strings"
 	"time"
 )
 
@@ -16,6 +15,29 @@ type MockBroker struct {
 	MsgList          []string
 	Topics           map[string]string
 	TopicTimeIndices map[string][]TimeToOffset
+	// PublishAcks records the acknowledgement level requested by each publish
+	PublishAcks []string
+	// PublishKeys records the partition key of each publish
+	PublishKeys []string
+	// ConsumeIsolations records the isolation level requested by each consume
+	ConsumeIsolations []string
+	// NoTransactions makes SupportsIsolationLevel and PublishBatch behave like a broker without transactions
+	NoTransactions bool
+	// FailBatchAt makes PublishBatch reject a batch at the message of the given position, counting from 1
+	FailBatchAt int
+	// TopicConfigs holds the configuration reported for each topic by DescribeTopic
+	TopicConfigs map[string]TopicConfig
+	// NoIntrospection makes DescribeTopic behave like a broker without introspection support
+	NoIntrospection bool
+	// NoTimeIndex makes TimeToOffset behave like a broker without timestamp lookups
+	NoTimeIndex bool
+	// DeletedBefore records the offset before which each topic's records were deleted
+	DeletedBefore map[string]int64
+	// NoDeleteRecords makes DeleteRecordsBefore behave like a broker without on demand deletions
+	NoDeleteRecords bool
+	// AvailableBrokers is the number of brokers that CreateTopic validates the replication factor against,
+	// defaults to 1 if not set
+	AvailableBrokers int
 }
 
 type TimeToOffset struct {
@@ -87,20 +109,64 @@ func (b *MockBroker) InitConfig() {
 
 }
 
+// Type returns the type of the mock broker
+func (b *MockBroker) Type() string {
+	return "mock"
+}
+
+// Version returns the version of the mock broker
+func (b *MockBroker) Version() string {
+	return "mock"
+}
+
 // Initialize the broker struct
 func (b *MockBroker) Initialize(peers []string) {
 	b.MsgList = make([]string, 0)
 }
 
 // Publish function publish a message to the broker
-func (b *MockBroker) Publish(topic string, msg messages.Message) (string, string, int, int64, error) {
+func (b *MockBroker) Publish(topic string, msg messages.Message, acks string, key string) (string, string, int, int64, error) {
 	payload, _ := msg.ExportJSON()
+	b.PublishAcks = append(b.PublishAcks, acks)
+	b.PublishKeys = append(b.PublishKeys, key)
 	b.MsgList = append(b.MsgList, payload)
 	off := b.GetMaxOffset(topic) - 1
 	msgID := strconv.FormatInt(off, 10)
-	// split the name that SHOULD come in the form of project_uuid.topic_name
-	s := strings.Split(topic, ".")
-	return msgID, fmt.Sprintf("%s.%s", s[0], s[1]), 0, int64(len(b.MsgList)), nil
+	// report the broker topic as given, like kafka does, names built by TopicName may carry a prefix
+	return msgID, topic, 0, int64(len(b.MsgList)), nil
+}
+
+// PublishBatch publishes the messages to the broker all at once, or none of them if the batch gets rejected
+func (b *MockBroker) PublishBatch(topic string, msgs []messages.Message, keys []string) ([]string, string, int, int64, error) {
+
+	if b.NoTransactions {
+		return nil, topic, 0, 0, ErrTransactionsUnsupported
+	}
+
+	ids := []string{}
+	payloads := []string{}
+	// the ids match the ones that publishing the messages one by one would give
+	off := b.GetMaxOffset(topic)
+	for i, msg := range msgs {
+		if i+1 == b.FailBatchAt {
+			return nil, topic, 0, 0, errors.New("kafka server: Message was too large, server rejected it to avoid allocation error.")
+		}
+		payload, _ := msg.ExportJSON()
+		payloads = append(payloads, payload)
+		ids = append(ids, strconv.FormatInt(off+int64(i), 10))
+	}
+
+	b.MsgList = append(b.MsgList, payloads...)
+	for i := range msgs {
+		b.PublishAcks = append(b.PublishAcks, AcksAll)
+		key := ""
+		if i < len(keys) {
+			key = keys[i]
+		}
+		b.PublishKeys = append(b.PublishKeys, key)
+	}
+
+	return ids, topic, 0, off, nil
 }
 
 // GetOffset returns a current topic's offset
@@ -114,10 +180,24 @@ func (b *MockBroker) GetMinOffset(topic string) int64 {
 }
 
 // Consume function to consume a message from the broker
-func (b *MockBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64) ([]string, error) {
+func (b *MockBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	b.ConsumeIsolations = append(b.ConsumeIsolations, isolation)
 	return b.MsgList, nil
 }
 
+// SupportsIsolationLevel checks whether the mock broker can consume with the isolation level
+func (b *MockBroker) SupportsIsolationLevel(level string) bool {
+	if level == IsolationReadCommitted {
+		return !b.NoTransactions
+	}
+	return ValidIsolationLevel(level)
+}
+
+// SupportsTimeIndex checks whether the mock broker can look offsets up by timestamp
+func (b *MockBroker) SupportsTimeIndex() bool {
+	return !b.NoTimeIndex
+}
+
 // Delete topic from the broker
 func (b *MockBroker) DeleteTopic(topic string) error {
 
@@ -132,6 +212,10 @@ func (b *MockBroker) DeleteTopic(topic string) error {
 
 func (b *MockBroker) TimeToOffset(topic string, time time.Time) (int64, error) {
 
+	if b.NoTimeIndex {
+		return -1, ErrTimeIndexUnsupported
+	}
+
 	topicTimeIndices, ok := b.TopicTimeIndices[topic]
 
 	if !ok {
@@ -146,3 +230,100 @@ func (b *MockBroker) TimeToOffset(topic string, time time.Time) (int64, error) {
 
 	return -1, nil
 }
+
+// DeleteRecordsBefore records the offset before which the topic's records got deleted
+func (b *MockBroker) DeleteRecordsBefore(topic string, offset int64) error {
+
+	if b.NoDeleteRecords {
+		return ErrDeleteRecordsUnsupported
+	}
+
+	if b.DeletedBefore == nil {
+		b.DeletedBefore = map[string]int64{}
+	}
+
+	b.DeletedBefore[topic] = offset
+	return nil
+}
+
+// DescribeTopic returns the configured metadata of a topic
+func (b *MockBroker) DescribeTopic(topic string) (TopicConfig, error) {
+
+	if b.NoIntrospection {
+		return TopicConfig{}, ErrIntrospectionUnsupported
+	}
+
+	cfg, ok := b.TopicConfigs[topic]
+	if !ok {
+		return TopicConfig{}, ErrTopicNotFound
+	}
+
+	return cfg, nil
+}
+
+// CreateTopic creates a topic with the given settings and registers its configuration
+func (b *MockBroker) CreateTopic(topic string, partitions int, replicationFactor int) (TopicConfig, error) {
+
+	availableBrokers := b.AvailableBrokers
+	if availableBrokers == 0 {
+		availableBrokers = 1
+	}
+
+	if partitions == 0 {
+		partitions = DefaultPartitions
+	}
+
+	if replicationFactor == 0 {
+		replicationFactor = DefaultReplicationFactor
+		if replicationFactor > availableBrokers {
+			replicationFactor = availableBrokers
+		}
+	}
+
+	if replicationFactor > availableBrokers {
+		return TopicConfig{}, fmt.Errorf("%w: replication factor %v is larger than the %v available brokers",
+			ErrInvalidTopicConfig, replicationFactor, availableBrokers)
+	}
+
+	if b.Topics == nil {
+		b.Topics = make(map[string]string)
+	}
+
+	if b.TopicConfigs == nil {
+		b.TopicConfigs = make(map[string]TopicConfig)
+	}
+
+	if cfg, ok := b.TopicConfigs[topic]; ok {
+		return cfg, nil
+	}
+
+	cfg := TopicConfig{
+		Partitions:        partitions,
+		ReplicationFactor: replicationFactor,
+		RetentionMs:       604800000,
+		RetentionBytes:    -1,
+	}
+
+	b.Topics[topic] = ""
+	b.TopicConfigs[topic] = cfg
+
+	return cfg, nil
+}
+
+// ConsumeRange returns the queued messages whose position is in the [from, to) range
+func (b *MockBroker) ConsumeRange(ctx context.Context, topic string, from int64, to int64) ([]string, error) {
+
+	if from < 0 {
+		from = 0
+	}
+
+	if to > int64(len(b.MsgList)) {
+		to = int64(len(b.MsgList))
+	}
+
+	if from >= to {
+		return []string{}, nil
+	}
+
+	return b.MsgList[from:to], nil
+}
@@ -0,0 +1,316 @@
+package brokers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/messages"
+	log "github.com/sirupsen/logrus"
+)
+
+// PoolStats reports the utilization of a broker pool
+type PoolStats struct {
+	// Size is the max number of brokers the pool holds
+	Size int
+	// Created is the number of brokers the pool has connected so far
+	Created int
+	// Active is the number of brokers currently in use
+	Active int
+	// Idle is the number of connected brokers waiting to be reused
+	Idle int
+	// Waits is the number of acquisitions that had to wait for a broker to be released
+	Waits int64
+	// WaitTime is the total time acquisitions spent waiting for a broker to be released
+	WaitTime time.Duration
+}
+
+// Pool hands out brokers from a bounded set of connected ones, reusing the released brokers
+// instead of connecting new ones. New brokers are connected through the factory only while
+// all the connected ones are in use and the pool hasn't reached its size.
+// The pool is a broker itself, each of its operations runs on a broker acquired for its duration
+type Pool struct {
+	factory func() Broker
+	slots   chan struct{}
+	first   Broker
+
+	mu       sync.Mutex
+	idle     []Broker
+	created  int
+	waits    int64
+	waitTime time.Duration
+}
+
+// NewPool creates a pool of at most size brokers, connecting its first broker right away
+func NewPool(size int, factory func() Broker) *Pool {
+
+	if size < 1 {
+		size = 1
+	}
+
+	p := &Pool{
+		factory: factory,
+		slots:   make(chan struct{}, size),
+	}
+
+	p.first = factory()
+	p.idle = []Broker{p.first}
+	p.created = 1
+
+	return p
+}
+
+// Acquire takes a broker out of the pool, waiting for one to be released if all of them are in use.
+// The broker should be released as soon as it isn't needed
+func (p *Pool) Acquire(ctx context.Context) (Broker, error) {
+
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		start := time.Now()
+		select {
+		case p.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		p.mu.Lock()
+		p.waits++
+		p.waitTime += time.Since(start)
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		brk := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return brk, nil
+	}
+	p.created++
+	p.mu.Unlock()
+
+	return p.factory(), nil
+}
+
+// Release returns an acquired broker to the pool
+func (p *Pool) Release(brk Broker) {
+	p.mu.Lock()
+	p.idle = append(p.idle, brk)
+	p.mu.Unlock()
+	<-p.slots
+}
+
+// Stats reports the current utilization of the pool
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Size:     cap(p.slots),
+		Created:  p.created,
+		Active:   len(p.slots),
+		Idle:     len(p.idle),
+		Waits:    p.waits,
+		WaitTime: p.waitTime,
+	}
+}
+
+// Acquire takes a broker out of the given broker, if it is a pool, otherwise the broker itself is returned.
+// The returned function releases the broker and should be called as soon as the broker isn't needed
+func Acquire(ctx context.Context, brk Broker) (Broker, func(), error) {
+
+	pool, ok := brk.(*Pool)
+	if !ok {
+		return brk, func() {}, nil
+	}
+
+	acquired, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return acquired, func() { pool.Release(acquired) }, nil
+}
+
+// with runs the operation on a broker acquired for its duration
+func (p *Pool) with(ctx context.Context, op func(brk Broker) error) error {
+
+	brk, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Release(brk)
+
+	return op(brk)
+}
+
+// InitConfig is a no-op, the brokers of the pool are configured by its factory
+func (p *Pool) InitConfig() {}
+
+// Initialize is a no-op, the brokers of the pool are connected by its factory
+func (p *Pool) Initialize(peers []string) {}
+
+// CloseConnections closes the connections of the idle brokers, the pool shouldn't be used afterwards
+func (p *Pool) CloseConnections() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if active := len(p.slots); active > 0 {
+		log.WithFields(
+			log.Fields{
+				"type":   "backend_log",
+				"active": active,
+			},
+		).Warning("Closing broker pool with brokers still in use")
+	}
+
+	for _, brk := range p.idle {
+		brk.CloseConnections()
+	}
+	p.idle = nil
+}
+
+// Publish publishes the message through a broker of the pool
+func (p *Pool) Publish(topic string, payload messages.Message, acks string, key string) (string, string, int, int64, error) {
+
+	var (
+		id, rTopic string
+		partition  int
+		offset     int64
+	)
+
+	err := p.with(context.Background(), func(brk Broker) error {
+		var err error
+		id, rTopic, partition, offset, err = brk.Publish(topic, payload, acks, key)
+		return err
+	})
+
+	return id, rTopic, partition, offset, err
+}
+
+// GetMinOffset returns the min offset of the topic through a broker of the pool
+func (p *Pool) GetMinOffset(topic string) int64 {
+	var off int64
+	p.with(context.Background(), func(brk Broker) error {
+		off = brk.GetMinOffset(topic)
+		return nil
+	})
+	return off
+}
+
+// PublishBatch publishes the messages atomically through a broker of the pool
+func (p *Pool) PublishBatch(topic string, msgs []messages.Message, keys []string) ([]string, string, int, int64, error) {
+
+	var (
+		ids        []string
+		rTopic     string
+		partition  int
+		baseOffset int64
+	)
+
+	err := p.with(context.Background(), func(brk Broker) error {
+		var err error
+		ids, rTopic, partition, baseOffset, err = brk.PublishBatch(topic, msgs, keys)
+		return err
+	})
+
+	return ids, rTopic, partition, baseOffset, err
+}
+
+// GetMaxOffset returns the max offset of the topic through a broker of the pool
+func (p *Pool) GetMaxOffset(topic string) int64 {
+	var off int64
+	p.with(context.Background(), func(brk Broker) error {
+		off = brk.GetMaxOffset(topic)
+		return nil
+	})
+	return off
+}
+
+// Consume consumes messages through a broker of the pool
+func (p *Pool) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	msgs := []string{}
+	err := p.with(ctx, func(brk Broker) error {
+		var err error
+		msgs, err = brk.Consume(ctx, topic, offset, imm, max, isolation)
+		return err
+	})
+	return msgs, err
+}
+
+// ConsumeRange consumes a range of messages through a broker of the pool
+func (p *Pool) ConsumeRange(ctx context.Context, topic string, from int64, to int64) ([]string, error) {
+	msgs := []string{}
+	err := p.with(ctx, func(brk Broker) error {
+		var err error
+		msgs, err = brk.ConsumeRange(ctx, topic, from, to)
+		return err
+	})
+	return msgs, err
+}
+
+// DeleteTopic deletes the topic through a broker of the pool
+func (p *Pool) DeleteTopic(topic string) error {
+	return p.with(context.Background(), func(brk Broker) error {
+		return brk.DeleteTopic(topic)
+	})
+}
+
+// TimeToOffset looks up the offset of the topic at the given time through a broker of the pool
+func (p *Pool) TimeToOffset(topic string, t time.Time) (int64, error) {
+	var off int64
+	err := p.with(context.Background(), func(brk Broker) error {
+		var err error
+		off, err = brk.TimeToOffset(topic, t)
+		return err
+	})
+	return off, err
+}
+
+// DeleteRecordsBefore deletes the records of the topic before the given offset through a broker of the pool
+func (p *Pool) DeleteRecordsBefore(topic string, offset int64) error {
+	return p.with(context.Background(), func(brk Broker) error {
+		return brk.DeleteRecordsBefore(topic, offset)
+	})
+}
+
+// DescribeTopic describes the topic through a broker of the pool
+func (p *Pool) DescribeTopic(topic string) (TopicConfig, error) {
+	var cfg TopicConfig
+	err := p.with(context.Background(), func(brk Broker) error {
+		var err error
+		cfg, err = brk.DescribeTopic(topic)
+		return err
+	})
+	return cfg, err
+}
+
+// CreateTopic creates the topic through a broker of the pool
+func (p *Pool) CreateTopic(topic string, partitions int, replicationFactor int) (TopicConfig, error) {
+	var cfg TopicConfig
+	err := p.with(context.Background(), func(brk Broker) error {
+		var err error
+		cfg, err = brk.CreateTopic(topic, partitions, replicationFactor)
+		return err
+	})
+	return cfg, err
+}
+
+// SupportsIsolationLevel checks whether the pooled brokers can consume with the isolation level
+func (p *Pool) SupportsIsolationLevel(level string) bool {
+	return p.first.SupportsIsolationLevel(level)
+}
+
+// SupportsTimeIndex checks whether the pooled brokers can look offsets up by timestamp
+func (p *Pool) SupportsTimeIndex() bool {
+	return p.first.SupportsTimeIndex()
+}
+
+// Type returns the type of the pooled brokers
+func (p *Pool) Type() string {
+	return p.first.Type()
+}
+
+// Version returns the version of the pooled brokers
+func (p *Pool) Version() string {
+	return p.first.Version()
+}
@@ -0,0 +1,37 @@
+package brokers
+
+import (
+	"errors"
+	"regexp"
+)
+
+// validTopicPrefix matches the prefixes that keep the broker topic names valid for kafka
+var validTopicPrefix = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)*$`)
+
+// topicPrefix namespaces the broker topics of a deployment that shares its broker cluster with others
+var topicPrefix string
+
+// SetTopicPrefix sets the prefix of the broker topics of the deployment, e.g. the name of its environment.
+// An empty prefix leaves the broker topics named after their project and topic only
+func SetTopicPrefix(prefix string) error {
+	if prefix != "" && !validTopicPrefix.MatchString(prefix) {
+		return errors.New("invalid broker topic prefix " + prefix + ", it should only contain letters, digits, '_', '-' and inner dots")
+	}
+	topicPrefix = prefix
+	return nil
+}
+
+// TopicPrefix returns the prefix of the broker topics of the deployment
+func TopicPrefix() string {
+	return topicPrefix
+}
+
+// TopicName returns the name that a project's topic is created with in the broker, project_uuid.topic,
+// preceded by the deployment's prefix if there is one
+func TopicName(projectUUID string, topic string) string {
+	name := projectUUID + "." + topic
+	if topicPrefix != "" {
+		return topicPrefix + "." + name
+	}
+	return name
+}
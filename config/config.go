@@ -5,11 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"crypto/x509"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/naming"
 	"github.com/samuel/go-zookeeper/zk"
 	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
 	"github.com/spf13/pflag"
@@ -68,11 +71,91 @@ type APICfg struct {
 	VerifyPushServer bool
 	// The token that corresponds to the registered push worker user
 	PushWorkerToken string
+	// PushSQSRegion is the aws region of the sqs queues that push subscriptions deliver to, empty extracts it from each queue's host
+	PushSQSRegion string
+	// PushSQSAccessKeyID is the aws access key of the credentials the sqs queues are delivered to with, empty disables the sqs endpoints
+	PushSQSAccessKeyID string
+	// PushSQSSecretAccessKey is the aws secret key of the credentials the sqs queues are delivered to with
+	PushSQSSecretAccessKey string
+	// PushSQSAllowedQueues are the sqs queues each project may push to, declared as project_name=account_id or project_name=queue_arn
+	PushSQSAllowedQueues []string
 	// Logging output(console,file,syslog etc)
 	LogFacilities []string
+	// Format of the log entries, TEXT or JSON
+	LogFormat string
+	// Disable auto offset advance makes the service move a subscription's offset only through explicit acknowledgements
+	DisableAutoOffsetAdvance bool
+	// Seconds a soft-deleted topic can be restored before the reaper purges it, zero disables soft-delete
+	TopicDeleteGracePeriod int
+	// The acknowledgement level the broker has to reach for a publish to succeed, 0, 1 or all
+	PublishAcks string
+	// Seconds a request can take before the service responds with a timeout, zero disables it.
+	// Only the response is bounded, the request's store operations and publishes still complete
+	RequestTimeout int
+	// Seconds between the periodic reconciliations of the subscription offsets, zero reconciles them only on start up
+	OffsetReconcileInterval int
+	// Seconds between the snapshots of the subscription offsets that they can be restored to, zero disables the snapshots
+	OffsetSnapshotInterval int
+	// OffsetSnapshotRetentionDays is the number of days the offset snapshots are kept, 0 keeps them forever
+	OffsetSnapshotRetentionDays int
+	// Seconds between the samples of the topic counters that the throughput metrics are computed from, zero disables the sampling
+	TopicSampleInterval int
+	// Windows, e.g. 1m or 1h, over which the throughput of the topics is computed
+	TopicThroughputWindows []string
+	// Projects pinned to a store server and database of their own, declared as project_uuid=database or project_uuid=server/database
+	StoreProjectRoutes []string
+	// DedupWindow is the number of acknowledged message ids remembered for each deduplicating subscription
+	DedupWindow int
+	// AckIDSecret is the secret that the ack ids handed to consumers are signed with, an empty secret disables signing
+	AckIDSecret string
+	// BrokerPoolSize is the max number of pooled broker connections, 0 shares a single connection among all requests
+	BrokerPoolSize int
+	// ACLCacheTTL is the number of seconds the per resource authorization decisions are cached, 0 disables the caching
+	ACLCacheTTL int
+	// ACLHistoryRetentionDays is the number of days the acl changes are kept in the acl history, 0 keeps them forever
+	ACLHistoryRetentionDays int
+	// CaseInsensitiveUsernames makes the usernames given for acls and project members match users regardless of their case
+	CaseInsensitiveUsernames bool
+	// PullLeaseTTL is the number of seconds a pull holds on to its lease on a subscription that limits its concurrent pulls
+	PullLeaseTTL int
+	// DefaultPageSize is the page size of list requests that don't declare one
+	DefaultPageSize int
+	// MaxPageSize bounds the page size of list requests, 0 meaning no bound
+	MaxPageSize int
+	// RejectOversizedPages rejects list requests exceeding the max page size, instead of clamping them to it
+	RejectOversizedPages bool
+	// PublishTimeMaxSkew is the number of seconds a producer supplied publish time may be ahead of the service's clock
+	PublishTimeMaxSkew int
+	// PayloadMaxDepth bounds the nesting of the json payloads of the published messages, 0 meaning no bound
+	PayloadMaxDepth int
+	// PayloadMaxFields bounds the total number of fields of the json payloads of the published messages, 0 meaning no bound
+	PayloadMaxFields int
+	// MaxMessageSize bounds the size in bytes that the data of compressed messages may decompress to when pulled
+	MaxMessageSize int
+	// TopicPublishMaxMessagesRate bounds the messages per second published to each topic, 0 meaning no bound
+	TopicPublishMaxMessagesRate float64
+	// TopicPublishMaxBytesRate bounds the bytes of message data per second published to each topic, 0 meaning no bound
+	TopicPublishMaxBytesRate float64
+	// VerifyAckOffsets checks every acknowledged offset against the range of offsets the broker holds for the topic
+	VerifyAckOffsets bool
+	// JSONNaming is the naming convention of the fields of the topics, subscriptions and messages exchanged with the clients
+	JSONNaming string
+	// DrainSink is the url of the sink the messages of drained subscriptions are exported to, empty disables draining
+	DrainSink string
+	// BrokerTopicPrefix namespaces the broker topics of the deployment, so that deployments sharing a broker cluster don't collide
+	BrokerTopicPrefix string
+	// EventSink is the webhook url or the topic://name broker topic the lifecycle events are emitted to, empty disables the events
+	EventSink string
+	// DefaultMaxMessages is the number of messages returned by the pulls that don't declare maxMessages, on subscriptions without a default of their own
+	DefaultMaxMessages int
 	// AuthOption defines how the service will handle authentication/authorization
 	// KEY, HEADER or BOTH are the available values for where the auth token should reside
 	authOption AuthOption
+	// Maintenance mode freezes all mutating operations of this node while keeping reads available.
+	// Accessed atomically since it can be toggled at runtime, it isn't shared with the other nodes
+	maintenanceMode int32
+	// clock provides the time used for pull leases, ack deadlines and publish times, the system time when unset
+	clock func() time.Time
 }
 
 // NewAPICfg creates a new kafka configuration object
@@ -253,6 +336,21 @@ func setLogLevel(logLvl string) {
 
 }
 
+// setLogFormat sets the formatter of the log entries, plain text is used unless JSON is requested
+func setLogFormat(logFormat string) {
+
+	switch strings.ToUpper(logFormat) {
+	case "JSON":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		log.SetFormatter(
+			&log.TextFormatter{
+				FullTimestamp: true,
+				DisableColors: true},
+		)
+	}
+}
+
 func setLogFacilities(facilities []string) {
 
 	if len(facilities) == 0 {
@@ -298,11 +396,82 @@ func (cfg *APICfg) setAuthOption(authOpt string) {
 	}
 }
 
+// setPublishAcks validates the configured publish acknowledgement level,
+// falling back to the most durable one when the value isn't supported
+func (cfg *APICfg) setPublishAcks(acks string) {
+
+	acks = strings.ToLower(acks)
+
+	if acks == "" {
+		acks = brokers.AcksAll
+	}
+
+	if !brokers.ValidAcksLevel(acks) {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Errorf("Invalid publish_acks value %v, falling back to %v", acks, brokers.AcksAll)
+		acks = brokers.AcksAll
+	}
+
+	cfg.PublishAcks = acks
+}
+
+// setJSONNaming validates the configured json naming convention,
+// falling back to the declared field names when the convention isn't supported
+func (cfg *APICfg) setJSONNaming(convention string) {
+
+	convention = strings.ToLower(convention)
+
+	if convention == "" {
+		convention = naming.Default
+	}
+
+	if !naming.Valid(convention) {
+		log.WithFields(
+			log.Fields{
+				"type": "service_log",
+			},
+		).Errorf("Invalid json_naming value %v, falling back to %v", convention, naming.Default)
+		convention = naming.Default
+	}
+
+	cfg.JSONNaming = convention
+}
+
 // AuthOption returns the value of the config for auth_option
 func (cfg *APICfg) AuthOption() AuthOption {
 	return cfg.authOption
 }
 
+// MaintenanceMode returns whether or not the service is currently in maintenance mode
+func (cfg *APICfg) MaintenanceMode() bool {
+	return atomic.LoadInt32(&cfg.maintenanceMode) == 1
+}
+
+// SetMaintenanceMode enables or disables maintenance mode at runtime
+func (cfg *APICfg) SetMaintenanceMode(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&cfg.maintenanceMode, v)
+}
+
+// Now returns the current time of the service's clock
+func (cfg *APICfg) Now() time.Time {
+	if cfg.clock == nil {
+		return time.Now()
+	}
+	return cfg.clock()
+}
+
+// SetClock replaces the clock of the service, allowing tests to control how time passes
+func (cfg *APICfg) SetClock(clock func() time.Time) {
+	cfg.clock = clock
+}
+
 // LoadTest the configuration
 func (cfg *APICfg) LoadTest() {
 
@@ -335,6 +504,14 @@ func (cfg *APICfg) LoadTest() {
 	).Infof("Parameter Loaded - log_facilities: %v", cfg.LogFacilities)
 	setLogFacilities(cfg.LogFacilities)
 
+	cfg.LogFormat = viper.GetString("log_format")
+	setLogFormat(cfg.LogFormat)
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_format: %v", cfg.LogFormat)
+
 	// Then load rest of the parameters
 	cfg.setAuthOption(viper.GetString("auth_option"))
 	log.WithFields(
@@ -478,411 +655,1413 @@ func (cfg *APICfg) LoadTest() {
 			"type": "service_log",
 		},
 	).Info("Parameter Loaded - push_worker_token")
-}
-
-// Load the configuration
-func (cfg *APICfg) Load() {
-	// Set Flags
-	var configPath *string
-
-	if pflag.Parsed() == false {
-
-		pflag.String("log-level", "INFO", "set the desired log level")
-		viper.BindPFlag("log_level", pflag.Lookup("log-level"))
-
-		pflag.String("bind-ip", "localhost", "ip address to listen to")
-		viper.BindPFlag("bind_ip", pflag.Lookup("bind-ip"))
-
-		pflag.Int("port", 8080, "port number to listen to")
-		viper.BindPFlag("port", pflag.Lookup("port"))
-
-		pflag.StringSlice("zookeeper-hosts", []string{"localhost"}, "list of zookeeper hosts to connect to")
-		viper.BindPFlag("zookeeper_hosts", pflag.Lookup("zookeeper-hosts"))
-
-		pflag.String("kafka-znode", "", "kafka zookeeper node name")
-		viper.BindPFlag("kafka_znode", pflag.Lookup("kafka-znode"))
-
-		pflag.String("store-host", "localhost", "datastore (mongodb) host")
-		viper.BindPFlag("store_host", pflag.Lookup("store-host"))
-
-		pflag.String("store-db", "argo_msg", "datastore (mongodb) database name")
-		viper.BindPFlag("store_db", pflag.Lookup("store-db"))
-
-		pflag.String("certificate", "/etc/pki/tls/certs/localhost.crt", "certificate file *.crt")
-		viper.BindPFlag("certificate", pflag.Lookup("certificate"))
-
-		pflag.String("certificate-key", "/etc/pki/tls/private/localhost.key", "certificate key file *.key")
-		viper.BindPFlag("certificate_key", pflag.Lookup("certificate-key"))
-
-		pflag.String("ca-dir", "/etc/grid-security/certificates", "directory containing the ca files *.pem")
-		viper.BindPFlag("certificate_authorities_dir", pflag.Lookup("ca-dir"))
-
-		pflag.Bool("per-resource-auth", true, "enable per resource authentication")
-		viper.BindPFlag("per_resource_auth", pflag.Lookup("per-resource-auth"))
-
-		pflag.String("service-key", "", "service token definition for immediate full api access")
-		viper.BindPFlag("service_key", pflag.Lookup("service-key"))
-
-		pflag.String("push-enabled", "", "enable automatic handling of push subscriptions at start-up")
-		viper.BindPFlag("push_enabled", pflag.Lookup("push-enabled"))
-
-		pflag.Bool("push-tls", true, "enable tls for communicating withe ams push server")
-		viper.BindPFlag("push_tls_enabled", pflag.Lookup("push-tls"))
-
-		pflag.String("push-host", "", "push server hostname")
-		viper.BindPFlag("push_server_host", pflag.Lookup("push-host"))
-
-		pflag.Int("push-port", 0, "push server port")
-		viper.BindPFlag("push_server_port", pflag.Lookup("push-port"))
-
-		pflag.Bool("push-verify", true, "verify push server's certificate if tls is enabled")
-		viper.BindPFlag("verify_push_server", pflag.Lookup("push-verify"))
-
-		pflag.String("push-worker-token", "", "token corresponding to the registered push worker user")
-		viper.BindPFlag("push_worker_token", pflag.Lookup("push-worker-token"))
-
-		pflag.String("log-facilities", "", "logging output(s)")
-		viper.BindPFlag("log_facilities", pflag.Lookup("log-facilities"))
-
-		pflag.String("auth-option", "", "where the auth token should reside")
-		viper.BindPFlag("auth_option", pflag.Lookup("auth-option"))
-
-		configPath = pflag.String("config-dir", "", "directory path to an alternative json config file")
-
-		pflag.Parse()
-
-	}
-
-	viper.SetConfigName("config")
-	if configPath != nil {
-		viper.AddConfigPath(*configPath)
-	}
-	viper.AddConfigPath("/etc/argo-messaging")
-	viper.AddConfigPath(".")
-
-	// Find and read the configuration file
-	err := viper.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("Errod trying to read the configuration file: %s \n", err))
-	}
 
-	// First check log level parameter and set logger
-	cfg.LogLevel = viper.GetString("log_level")
-	setLogLevel(cfg.LogLevel)
+	// push sqs region
+	cfg.PushSQSRegion = viper.GetString("push_sqs_region")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - log_level: %v", cfg.LogLevel)
+	).Infof("Parameter Loaded - push_sqs_region: %v", cfg.PushSQSRegion)
 
-	cfg.LogFacilities = viper.GetStringSlice("log_facilities")
-	setLogFacilities(cfg.LogFacilities)
+	// push sqs access key id
+	cfg.PushSQSAccessKeyID = viper.GetString("push_sqs_access_key_id")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - log_facilities: %v", cfg.LogFacilities)
-
-	// Then load rest of the parameters
+	).Infof("Parameter Loaded - push_sqs_access_key_id: %v", cfg.PushSQSAccessKeyID)
 
-	cfg.setAuthOption(viper.GetString("auth_option"))
+	// push sqs secret access key
+	cfg.PushSQSSecretAccessKey = viper.GetString("push_sqs_secret_access_key")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - auth_option: %v", cfg.AuthOption())
+	).Info("Parameter Loaded - push_sqs_secret_access_key")
 
-	// bind ip
-	cfg.BindIP = viper.GetString("bind_ip")
+	// push sqs allowed queues
+	cfg.PushSQSAllowedQueues = viper.GetStringSlice("push_sqs_allowed_queues")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - bind_ip: %v", cfg.BindIP)
+	).Infof("Parameter Loaded - push_sqs_allowed_queues: %v", cfg.PushSQSAllowedQueues)
 
-	// service port
-	cfg.Port = viper.GetInt("port")
+	// maintenance mode
+	cfg.SetMaintenanceMode(viper.GetBool("maintenance_mode"))
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - port: %v", cfg.Port)
+	).Infof("Parameter Loaded - maintenance_mode: %v", cfg.MaintenanceMode())
 
-	// zookeeper hosts
-	cfg.ZooHosts = viper.GetStringSlice("zookeeper_hosts")
+	// disable auto offset advance
+	cfg.DisableAutoOffsetAdvance = viper.GetBool("disable_auto_offset_advance")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - zookeeper_hosts: %v", cfg.ZooHosts)
+	).Infof("Parameter Loaded - disable_auto_offset_advance: %v", cfg.DisableAutoOffsetAdvance)
 
-	// kafka_znode
-	cfg.KafkaZnode = viper.GetString("kafka_znode")
+	// topic delete grace period
+	cfg.TopicDeleteGracePeriod = viper.GetInt("topic_delete_grace_period")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - kafka_znode: %v", cfg.KafkaZnode)
+	).Infof("Parameter Loaded - topic_delete_grace_period: %v", cfg.TopicDeleteGracePeriod)
 
-	// store host
-	cfg.StoreHost = viper.GetString("store_host")
+	// publish acks level
+	cfg.setPublishAcks(viper.GetString("publish_acks"))
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - store_host: %v", cfg.StoreHost)
+	).Infof("Parameter Loaded - publish_acks: %v", cfg.PublishAcks)
 
-	// store name
-	cfg.StoreDB = viper.GetString("store_db")
+	// request timeout
+	cfg.RequestTimeout = viper.GetInt("request_timeout")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - store_db: %v", cfg.StoreDB)
+	).Infof("Parameter Loaded - request_timeout: %v", cfg.RequestTimeout)
 
-	// service certificate
-	cfg.Cert = viper.GetString("certificate")
+	// offset reconcile interval
+	cfg.OffsetReconcileInterval = viper.GetInt("offset_reconcile_interval")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate: %v", cfg.Cert)
+	).Infof("Parameter Loaded - offset_reconcile_interval: %v", cfg.OffsetReconcileInterval)
 
-	// service certificate key
-	cfg.CertKey = viper.GetString("certificate_key")
+	// offset snapshots
+	cfg.OffsetSnapshotInterval = viper.GetInt("offset_snapshot_interval")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate_key: %v", cfg.CertKey)
+	).Infof("Parameter Loaded - offset_snapshot_interval: %v", cfg.OffsetSnapshotInterval)
 
-	// certificate authorities directory
-	cfg.CertificateAuthoritiesDir = viper.GetString("certificate_authorities_dir")
+	cfg.OffsetSnapshotRetentionDays = viper.GetInt("offset_snapshot_retention_days")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate_authorities_dir: %v", cfg.CertificateAuthoritiesDir)
+	).Infof("Parameter Loaded - offset_snapshot_retention_days: %v", cfg.OffsetSnapshotRetentionDays)
 
-	// per resource authorisation
-	cfg.ResAuth = viper.GetBool("per_resource_auth")
+	// topic sample interval
+	cfg.TopicSampleInterval = viper.GetInt("topic_sample_interval")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - per_resource_auth: %v", cfg.ResAuth)
+	).Infof("Parameter Loaded - topic_sample_interval: %v", cfg.TopicSampleInterval)
 
-	// service token
-	cfg.ServiceToken = viper.GetString("service_token")
+	// topic throughput windows
+	cfg.TopicThroughputWindows = viper.GetStringSlice("topic_throughput_windows")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Info("Parameter Loaded - service_token")
+	).Infof("Parameter Loaded - topic_throughput_windows: %v", cfg.TopicThroughputWindows)
 
-	// push enabled true or false
-	cfg.PushEnabled = viper.GetBool("push_enabled")
+	// store project routes
+	cfg.StoreProjectRoutes = viper.GetStringSlice("store_project_routes")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_enabled: %v", cfg.PushEnabled)
+	).Infof("Parameter Loaded - store_project_routes: %v", cfg.StoreProjectRoutes)
 
-	// push TLS enabled true or false
-	cfg.PushTlsEnabled = viper.GetBool("push_tls_enabled")
+	// dedup window
+	cfg.DedupWindow = viper.GetInt("dedup_window")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_tls_enabled: %v", cfg.PushTlsEnabled)
+	).Infof("Parameter Loaded - dedup_window: %v", cfg.DedupWindow)
 
-	// push server host
-	cfg.PushServerHost = viper.GetString("push_server_host")
+	// ack id secret
+	cfg.AckIDSecret = viper.GetString("ack_id_secret")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_server_host: %v", cfg.PushServerHost)
+	).Info("Parameter Loaded - ack_id_secret")
 
-	// push server port
-	cfg.PushServerPort = viper.GetInt("push_server_port")
+	// broker pool size
+	cfg.BrokerPoolSize = viper.GetInt("broker_pool_size")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_server_port: %v", cfg.PushServerPort)
+	).Infof("Parameter Loaded - broker_pool_size: %v", cfg.BrokerPoolSize)
 
-	// verify push server
-	cfg.VerifyPushServer = viper.GetBool("verify_push_server")
+	// acl cache ttl
+	cfg.ACLCacheTTL = viper.GetInt("acl_cache_ttl")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - verify_push_server: %v", cfg.VerifyPushServer)
+	).Infof("Parameter Loaded - acl_cache_ttl: %v", cfg.ACLCacheTTL)
 
-	// push worker token
-	cfg.PushWorkerToken = viper.GetString("push_worker_token")
+	// acl history retention days
+	cfg.ACLHistoryRetentionDays = viper.GetInt("acl_history_retention_days")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Info("Parameter Loaded - push_worker_token")
-
-}
+	).Infof("Parameter Loaded - acl_history_retention_days: %v", cfg.ACLHistoryRetentionDays)
 
-// LoadStrJSON Loads configuration from a JSON string
-func (cfg *APICfg) LoadStrJSON(input string) {
-	viper.SetConfigType("json")
-	viper.ReadConfig(strings.NewReader(input))
-	// Load Kafka configuration
-	// bind ip
-	cfg.BindIP = viper.GetString("bind_ip")
+	// case insensitive usernames
+	cfg.CaseInsensitiveUsernames = viper.GetBool("case_insensitive_usernames")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - bind_ip: %v", cfg.BindIP)
+	).Infof("Parameter Loaded - case_insensitive_usernames: %v", cfg.CaseInsensitiveUsernames)
 
-	// service port
-	cfg.Port = viper.GetInt("port")
+	// pull lease ttl
+	cfg.PullLeaseTTL = viper.GetInt("pull_lease_ttl")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - port: %v", cfg.Port)
+	).Infof("Parameter Loaded - pull_lease_ttl: %v", cfg.PullLeaseTTL)
 
-	// zookeeper hosts
-	cfg.ZooHosts = viper.GetStringSlice("zookeeper_hosts")
+	// default page size
+	cfg.DefaultPageSize = viper.GetInt("default_page_size")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - zookeeper_hosts: %v", cfg.ZooHosts)
+	).Infof("Parameter Loaded - default_page_size: %v", cfg.DefaultPageSize)
 
-	// kafka_znode
-	cfg.KafkaZnode = viper.GetString("kafka_znode")
+	// max page size
+	cfg.MaxPageSize = viper.GetInt("max_page_size")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - kafka_znode: %v", cfg.KafkaZnode)
+	).Infof("Parameter Loaded - max_page_size: %v", cfg.MaxPageSize)
 
-	// store host
-	cfg.StoreHost = viper.GetString("store_host")
+	// reject oversized pages
+	cfg.RejectOversizedPages = viper.GetBool("reject_oversized_pages")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - store_host: %v", cfg.StoreHost)
+	).Infof("Parameter Loaded - reject_oversized_pages: %v", cfg.RejectOversizedPages)
 
-	// store name
-	cfg.StoreDB = viper.GetString("store_db")
+	// publish time max skew
+	cfg.PublishTimeMaxSkew = viper.GetInt("publish_time_max_skew")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - store_db: %v", cfg.StoreDB)
+	).Infof("Parameter Loaded - publish_time_max_skew: %v", cfg.PublishTimeMaxSkew)
 
-	// service certificate
-	cfg.Cert = viper.GetString("certificate")
+	// payload limits
+	cfg.PayloadMaxDepth = viper.GetInt("payload_max_depth")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate: %v", cfg.Cert)
+	).Infof("Parameter Loaded - payload_max_depth: %v", cfg.PayloadMaxDepth)
 
-	// service certificate key
-	cfg.CertKey = viper.GetString("certificate_key")
+	cfg.PayloadMaxFields = viper.GetInt("payload_max_fields")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate_key: %v", cfg.CertKey)
+	).Infof("Parameter Loaded - payload_max_fields: %v", cfg.PayloadMaxFields)
 
-	// certificate authorities directory
-	cfg.CertificateAuthoritiesDir = viper.GetString("certificate_authorities_dir")
+	cfg.MaxMessageSize = viper.GetInt("max_message_size")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - certificate_authorities_dir: %v", cfg.CertificateAuthoritiesDir)
+	).Infof("Parameter Loaded - max_message_size: %v", cfg.MaxMessageSize)
 
-	// per resource authorisation
-	cfg.ResAuth = viper.GetBool("per_resource_auth")
+	cfg.TopicPublishMaxMessagesRate = viper.GetFloat64("topic_publish_max_messages_rate")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - per_resource_auth: %v", cfg.ResAuth)
+	).Infof("Parameter Loaded - topic_publish_max_messages_rate: %v", cfg.TopicPublishMaxMessagesRate)
 
-	// service token
-	cfg.ServiceToken = viper.GetString("service_token")
+	cfg.TopicPublishMaxBytesRate = viper.GetFloat64("topic_publish_max_bytes_rate")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Info("Parameter Loaded - service_token:")
+	).Infof("Parameter Loaded - topic_publish_max_bytes_rate: %v", cfg.TopicPublishMaxBytesRate)
 
-	// push enabled true or false
-	cfg.PushEnabled = viper.GetBool("push_enabled")
+	cfg.VerifyAckOffsets = viper.GetBool("verify_ack_offsets")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_enabled: %v", cfg.PushEnabled)
+	).Infof("Parameter Loaded - verify_ack_offsets: %v", cfg.VerifyAckOffsets)
 
-	// push TLS enabled true or false
-	cfg.PushTlsEnabled = viper.GetBool("push_tls_enabled")
+	// json naming
+	cfg.setJSONNaming(viper.GetString("json_naming"))
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_tls_enabled: %v", cfg.PushTlsEnabled)
+	).Infof("Parameter Loaded - json_naming: %v", cfg.JSONNaming)
 
-	// push server host
-	cfg.PushServerHost = viper.GetString("push_server_host")
+	// drain sink
+	cfg.DrainSink = viper.GetString("drain_sink")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_server_host: %v", cfg.PushServerHost)
+	).Infof("Parameter Loaded - drain_sink: %v", cfg.DrainSink)
 
-	// push server port
-	cfg.PushServerPort = viper.GetInt("push_server_port")
+	// broker topic prefix
+	cfg.BrokerTopicPrefix = viper.GetString("broker_topic_prefix")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - push_server_port: %v", cfg.PushServerPort)
+	).Infof("Parameter Loaded - broker_topic_prefix: %v", cfg.BrokerTopicPrefix)
 
-	// verify push server
-	cfg.VerifyPushServer = viper.GetBool("verify_push_server")
+	// event sink
+	cfg.EventSink = viper.GetString("event_sink")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Infof("Parameter Loaded - verify_push_server: %v", cfg.VerifyPushServer)
+	).Infof("Parameter Loaded - event_sink: %v", cfg.EventSink)
 
-	// push worker token
-	cfg.PushWorkerToken = viper.GetString("push_worker_token")
+	// default max messages of pulls
+	cfg.DefaultMaxMessages = viper.GetInt("default_max_messages")
 	log.WithFields(
 		log.Fields{
 			"type": "service_log",
 		},
-	).Info("Parameter Loaded - push_worker_token")
+	).Infof("Parameter Loaded - default_max_messages: %v", cfg.DefaultMaxMessages)
 
-	cfg.LogFacilities = viper.GetStringSlice("log_facilities")
-	log.WithFields(
-		log.Fields{
-			"type": "service_log",
-		},
-	).Infof("Parameter Loaded - log_facilities: %v", cfg.LogFacilities)
+}
 
-	// auth option
-	cfg.setAuthOption(viper.GetString("auth_option"))
-	log.WithFields(
-		log.Fields{
-			"type": "service_log",
-		},
+// Load the configuration
+func (cfg *APICfg) Load() {
+	// Set Flags
+	var configPath *string
+
+	if pflag.Parsed() == false {
+
+		pflag.String("log-level", "INFO", "set the desired log level")
+		viper.BindPFlag("log_level", pflag.Lookup("log-level"))
+
+		pflag.String("bind-ip", "localhost", "ip address to listen to")
+		viper.BindPFlag("bind_ip", pflag.Lookup("bind-ip"))
+
+		pflag.Int("port", 8080, "port number to listen to")
+		viper.BindPFlag("port", pflag.Lookup("port"))
+
+		pflag.StringSlice("zookeeper-hosts", []string{"localhost"}, "list of zookeeper hosts to connect to")
+		viper.BindPFlag("zookeeper_hosts", pflag.Lookup("zookeeper-hosts"))
+
+		pflag.String("kafka-znode", "", "kafka zookeeper node name")
+		viper.BindPFlag("kafka_znode", pflag.Lookup("kafka-znode"))
+
+		pflag.String("store-host", "localhost", "datastore (mongodb) host")
+		viper.BindPFlag("store_host", pflag.Lookup("store-host"))
+
+		pflag.String("store-db", "argo_msg", "datastore (mongodb) database name")
+		viper.BindPFlag("store_db", pflag.Lookup("store-db"))
+
+		pflag.String("certificate", "/etc/pki/tls/certs/localhost.crt", "certificate file *.crt")
+		viper.BindPFlag("certificate", pflag.Lookup("certificate"))
+
+		pflag.String("certificate-key", "/etc/pki/tls/private/localhost.key", "certificate key file *.key")
+		viper.BindPFlag("certificate_key", pflag.Lookup("certificate-key"))
+
+		pflag.String("ca-dir", "/etc/grid-security/certificates", "directory containing the ca files *.pem")
+		viper.BindPFlag("certificate_authorities_dir", pflag.Lookup("ca-dir"))
+
+		pflag.Bool("per-resource-auth", true, "enable per resource authentication")
+		viper.BindPFlag("per_resource_auth", pflag.Lookup("per-resource-auth"))
+
+		pflag.String("service-key", "", "service token definition for immediate full api access")
+		viper.BindPFlag("service_key", pflag.Lookup("service-key"))
+
+		pflag.String("push-enabled", "", "enable automatic handling of push subscriptions at start-up")
+		viper.BindPFlag("push_enabled", pflag.Lookup("push-enabled"))
+
+		pflag.Bool("push-tls", true, "enable tls for communicating withe ams push server")
+		viper.BindPFlag("push_tls_enabled", pflag.Lookup("push-tls"))
+
+		pflag.String("push-host", "", "push server hostname")
+		viper.BindPFlag("push_server_host", pflag.Lookup("push-host"))
+
+		pflag.Int("push-port", 0, "push server port")
+		viper.BindPFlag("push_server_port", pflag.Lookup("push-port"))
+
+		pflag.Bool("push-verify", true, "verify push server's certificate if tls is enabled")
+		viper.BindPFlag("verify_push_server", pflag.Lookup("push-verify"))
+
+		pflag.String("push-worker-token", "", "token corresponding to the registered push worker user")
+		viper.BindPFlag("push_worker_token", pflag.Lookup("push-worker-token"))
+
+		pflag.String("push-sqs-region", "", "aws region of the sqs queues push subscriptions deliver to, extracted from each queue's host if empty")
+		viper.BindPFlag("push_sqs_region", pflag.Lookup("push-sqs-region"))
+
+		pflag.String("push-sqs-access-key-id", "", "aws access key id used to deliver to sqs queues, sqs push endpoints are disabled if empty")
+		viper.BindPFlag("push_sqs_access_key_id", pflag.Lookup("push-sqs-access-key-id"))
+
+		pflag.String("push-sqs-secret-access-key", "", "aws secret access key used to deliver to sqs queues")
+		viper.BindPFlag("push_sqs_secret_access_key", pflag.Lookup("push-sqs-secret-access-key"))
+
+		pflag.StringSlice("push-sqs-allowed-queues", []string{}, "sqs queues each project may push to, declared as project_name=account_id or project_name=queue_arn")
+		viper.BindPFlag("push_sqs_allowed_queues", pflag.Lookup("push-sqs-allowed-queues"))
+
+		pflag.String("log-facilities", "", "logging output(s)")
+		viper.BindPFlag("log_facilities", pflag.Lookup("log-facilities"))
+
+		pflag.String("log-format", "TEXT", "format of the log entries, TEXT or JSON")
+		viper.BindPFlag("log_format", pflag.Lookup("log-format"))
+
+		pflag.String("auth-option", "", "where the auth token should reside")
+		viper.BindPFlag("auth_option", pflag.Lookup("auth-option"))
+
+		pflag.Bool("maintenance-mode", false, "start the service in maintenance mode, rejecting all mutating operations")
+		viper.BindPFlag("maintenance_mode", pflag.Lookup("maintenance-mode"))
+
+		pflag.Bool("disable-auto-offset-advance", false, "never advance subscription offsets unless messages are explicitly acknowledged")
+		viper.BindPFlag("disable_auto_offset_advance", pflag.Lookup("disable-auto-offset-advance"))
+
+		pflag.Int("topic-delete-grace-period", 0, "seconds a deleted topic stays restorable before being purged, 0 deletes topics immediately")
+		viper.BindPFlag("topic_delete_grace_period", pflag.Lookup("topic-delete-grace-period"))
+
+		pflag.String("publish-acks", "all", "acknowledgement level required for publishing to the broker (0, 1 or all)")
+		viper.BindPFlag("publish_acks", pflag.Lookup("publish-acks"))
+
+		pflag.Int("request-timeout", 0, "Seconds a request can take before it gets a 504 response, 0 disables it. The request's store operations and publishes still complete")
+		viper.BindPFlag("request_timeout", pflag.Lookup("request-timeout"))
+
+		pflag.Int("offset-reconcile-interval", 0, "seconds between the reconciliations of subscription offsets with the broker, 0 reconciles only on start up")
+		viper.BindPFlag("offset_reconcile_interval", pflag.Lookup("offset-reconcile-interval"))
+
+		pflag.Int("offset-snapshot-interval", 0, "seconds between the snapshots of the subscription offsets, 0 disables them")
+		viper.BindPFlag("offset_snapshot_interval", pflag.Lookup("offset-snapshot-interval"))
+
+		pflag.Int("offset-snapshot-retention-days", 7, "days the snapshots of the subscription offsets are kept, 0 keeps them forever")
+		viper.BindPFlag("offset_snapshot_retention_days", pflag.Lookup("offset-snapshot-retention-days"))
+
+		pflag.Int("topic-sample-interval", 30, "seconds between the samples of the topic counters used to compute their throughput, 0 disables the sampling")
+		viper.BindPFlag("topic_sample_interval", pflag.Lookup("topic-sample-interval"))
+
+		pflag.StringSlice("topic-throughput-windows", []string{"1m", "5m", "1h"}, "windows, e.g. 1m, over which the throughput of topics is computed")
+		viper.BindPFlag("topic_throughput_windows", pflag.Lookup("topic-throughput-windows"))
+
+		pflag.StringSlice("store-project-routes", []string{}, "pin projects to stores of their own, declared as project_uuid=database or project_uuid=server/database")
+		viper.BindPFlag("store_project_routes", pflag.Lookup("store-project-routes"))
+
+		pflag.Int("dedup-window", 10000, "Number of acknowledged message ids remembered for each deduplicating subscription")
+		viper.BindPFlag("dedup_window", pflag.Lookup("dedup-window"))
+
+		pflag.String("ack-id-secret", "", "Secret that ack ids are signed with, ack ids are not signed if empty")
+		viper.BindPFlag("ack_id_secret", pflag.Lookup("ack-id-secret"))
+
+		pflag.Int("broker-pool-size", 0, "Max number of broker connections pooled for publishing and pulling, 0 shares a single connection")
+		viper.BindPFlag("broker_pool_size", pflag.Lookup("broker-pool-size"))
+
+		pflag.Int("acl-cache-ttl", 0, "Seconds the per resource authorization decisions are cached, 0 disables the caching")
+		viper.BindPFlag("acl_cache_ttl", pflag.Lookup("acl-cache-ttl"))
+
+		pflag.Int("acl-history-retention-days", 0, "days the acl changes are kept in the acl history, 0 keeps them forever")
+		viper.BindPFlag("acl_history_retention_days", pflag.Lookup("acl-history-retention-days"))
+
+		pflag.Bool("case-insensitive-usernames", false, "match the usernames given for acls and project members regardless of their case")
+		viper.BindPFlag("case_insensitive_usernames", pflag.Lookup("case-insensitive-usernames"))
+
+		pflag.Int("pull-lease-ttl", 30, "Seconds a pull holds on to its lease on a subscription with a concurrent pull limit")
+		viper.BindPFlag("pull_lease_ttl", pflag.Lookup("pull-lease-ttl"))
+
+		pflag.Int("default-page-size", 0, "Page size of list requests that don't declare one, 0 leaves them unpaginated unless a max page size is set")
+		viper.BindPFlag("default_page_size", pflag.Lookup("default-page-size"))
+
+		pflag.Int("max-page-size", 0, "Max page size of list requests, 0 doesn't bound the page size")
+		viper.BindPFlag("max_page_size", pflag.Lookup("max-page-size"))
+
+		pflag.Bool("reject-oversized-pages", false, "Reject list requests exceeding the max page size instead of clamping their page size")
+		viper.BindPFlag("reject_oversized_pages", pflag.Lookup("reject-oversized-pages"))
+
+		pflag.Int("publish-time-max-skew", 60, "Seconds a producer supplied publish time may be ahead of the service's clock")
+		viper.BindPFlag("publish_time_max_skew", pflag.Lookup("publish-time-max-skew"))
+
+		pflag.Int("payload-max-depth", 0, "Deepest nesting of the json payloads of the published messages, 0 for no bound")
+		viper.BindPFlag("payload_max_depth", pflag.Lookup("payload-max-depth"))
+
+		pflag.Int("payload-max-fields", 0, "Total number of fields of the json payloads of the published messages, 0 for no bound")
+		viper.BindPFlag("payload_max_fields", pflag.Lookup("payload-max-fields"))
+
+		pflag.Int("max-message-size", 1048576, "Bytes the data of a compressed message may decompress to when pulled")
+		viper.BindPFlag("max_message_size", pflag.Lookup("max-message-size"))
+
+		pflag.Float64("topic-publish-max-messages-rate", 0, "Messages per second that may be published to each topic, 0 for no bound")
+		viper.BindPFlag("topic_publish_max_messages_rate", pflag.Lookup("topic-publish-max-messages-rate"))
+
+		pflag.Float64("topic-publish-max-bytes-rate", 0, "Bytes of message data per second that may be published to each topic, 0 for no bound")
+		viper.BindPFlag("topic_publish_max_bytes_rate", pflag.Lookup("topic-publish-max-bytes-rate"))
+
+		pflag.Bool("verify-ack-offsets", false, "Verify that acknowledged offsets are within the range of offsets held by the broker")
+		viper.BindPFlag("verify_ack_offsets", pflag.Lookup("verify-ack-offsets"))
+
+		pflag.String("json-naming", naming.Default, "Naming convention of the fields of the json responses, default, snake_case or camel_case")
+		viper.BindPFlag("json_naming", pflag.Lookup("json-naming"))
+
+		pflag.String("drain-sink", "", "Url of the sink the drained subscriptions are exported to, e.g. file:///var/lib/argo-messaging/drains")
+		viper.BindPFlag("drain_sink", pflag.Lookup("drain-sink"))
+
+		pflag.String("broker-topic-prefix", "", "Prefix of the broker topics of the deployment, for deployments that share a broker cluster")
+		viper.BindPFlag("broker_topic_prefix", pflag.Lookup("broker-topic-prefix"))
+
+		pflag.String("event-sink", "", "Webhook url or topic://name broker topic the resource lifecycle events are emitted to")
+		viper.BindPFlag("event_sink", pflag.Lookup("event-sink"))
+
+		pflag.Int("default-max-messages", 0, "Number of messages returned by the pulls that don't declare maxMessages, 0 returning a single message")
+		viper.BindPFlag("default_max_messages", pflag.Lookup("default-max-messages"))
+
+		configPath = pflag.String("config-dir", "", "directory path to an alternative json config file")
+
+		pflag.Parse()
+
+	}
+
+	viper.SetConfigName("config")
+	if configPath != nil {
+		viper.AddConfigPath(*configPath)
+	}
+	viper.AddConfigPath("/etc/argo-messaging")
+	viper.AddConfigPath(".")
+
+	// Find and read the configuration file
+	err := viper.ReadInConfig()
+	if err != nil {
+		panic(fmt.Errorf("Errod trying to read the configuration file: %s \n", err))
+	}
+
+	// First check log level parameter and set logger
+	cfg.LogLevel = viper.GetString("log_level")
+	setLogLevel(cfg.LogLevel)
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_level: %v", cfg.LogLevel)
+
+	cfg.LogFacilities = viper.GetStringSlice("log_facilities")
+	setLogFacilities(cfg.LogFacilities)
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_facilities: %v", cfg.LogFacilities)
+
+	cfg.LogFormat = viper.GetString("log_format")
+	setLogFormat(cfg.LogFormat)
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_format: %v", cfg.LogFormat)
+
+	// Then load rest of the parameters
+
+	cfg.setAuthOption(viper.GetString("auth_option"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - auth_option: %v", cfg.AuthOption())
+
+	// bind ip
+	cfg.BindIP = viper.GetString("bind_ip")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - bind_ip: %v", cfg.BindIP)
+
+	// service port
+	cfg.Port = viper.GetInt("port")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - port: %v", cfg.Port)
+
+	// zookeeper hosts
+	cfg.ZooHosts = viper.GetStringSlice("zookeeper_hosts")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - zookeeper_hosts: %v", cfg.ZooHosts)
+
+	// kafka_znode
+	cfg.KafkaZnode = viper.GetString("kafka_znode")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - kafka_znode: %v", cfg.KafkaZnode)
+
+	// store host
+	cfg.StoreHost = viper.GetString("store_host")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_host: %v", cfg.StoreHost)
+
+	// store name
+	cfg.StoreDB = viper.GetString("store_db")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_db: %v", cfg.StoreDB)
+
+	// service certificate
+	cfg.Cert = viper.GetString("certificate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate: %v", cfg.Cert)
+
+	// service certificate key
+	cfg.CertKey = viper.GetString("certificate_key")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate_key: %v", cfg.CertKey)
+
+	// certificate authorities directory
+	cfg.CertificateAuthoritiesDir = viper.GetString("certificate_authorities_dir")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate_authorities_dir: %v", cfg.CertificateAuthoritiesDir)
+
+	// per resource authorisation
+	cfg.ResAuth = viper.GetBool("per_resource_auth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - per_resource_auth: %v", cfg.ResAuth)
+
+	// service token
+	cfg.ServiceToken = viper.GetString("service_token")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - service_token")
+
+	// push enabled true or false
+	cfg.PushEnabled = viper.GetBool("push_enabled")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_enabled: %v", cfg.PushEnabled)
+
+	// push TLS enabled true or false
+	cfg.PushTlsEnabled = viper.GetBool("push_tls_enabled")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_tls_enabled: %v", cfg.PushTlsEnabled)
+
+	// push server host
+	cfg.PushServerHost = viper.GetString("push_server_host")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_server_host: %v", cfg.PushServerHost)
+
+	// push server port
+	cfg.PushServerPort = viper.GetInt("push_server_port")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_server_port: %v", cfg.PushServerPort)
+
+	// verify push server
+	cfg.VerifyPushServer = viper.GetBool("verify_push_server")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - verify_push_server: %v", cfg.VerifyPushServer)
+
+	// push worker token
+	cfg.PushWorkerToken = viper.GetString("push_worker_token")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - push_worker_token")
+
+	// push sqs region
+	cfg.PushSQSRegion = viper.GetString("push_sqs_region")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_region: %v", cfg.PushSQSRegion)
+
+	// push sqs access key id
+	cfg.PushSQSAccessKeyID = viper.GetString("push_sqs_access_key_id")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_access_key_id: %v", cfg.PushSQSAccessKeyID)
+
+	// push sqs secret access key
+	cfg.PushSQSSecretAccessKey = viper.GetString("push_sqs_secret_access_key")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - push_sqs_secret_access_key")
+
+	// push sqs allowed queues
+	cfg.PushSQSAllowedQueues = viper.GetStringSlice("push_sqs_allowed_queues")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_allowed_queues: %v", cfg.PushSQSAllowedQueues)
+
+	// maintenance mode
+	cfg.SetMaintenanceMode(viper.GetBool("maintenance_mode"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - maintenance_mode: %v", cfg.MaintenanceMode())
+
+	// disable auto offset advance
+	cfg.DisableAutoOffsetAdvance = viper.GetBool("disable_auto_offset_advance")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - disable_auto_offset_advance: %v", cfg.DisableAutoOffsetAdvance)
+
+	// topic delete grace period
+	cfg.TopicDeleteGracePeriod = viper.GetInt("topic_delete_grace_period")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_delete_grace_period: %v", cfg.TopicDeleteGracePeriod)
+
+	// publish acks level
+	cfg.setPublishAcks(viper.GetString("publish_acks"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - publish_acks: %v", cfg.PublishAcks)
+
+	// request timeout
+	cfg.RequestTimeout = viper.GetInt("request_timeout")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - request_timeout: %v", cfg.RequestTimeout)
+
+	// offset reconcile interval
+	cfg.OffsetReconcileInterval = viper.GetInt("offset_reconcile_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_reconcile_interval: %v", cfg.OffsetReconcileInterval)
+
+	// offset snapshots
+	cfg.OffsetSnapshotInterval = viper.GetInt("offset_snapshot_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_snapshot_interval: %v", cfg.OffsetSnapshotInterval)
+
+	cfg.OffsetSnapshotRetentionDays = viper.GetInt("offset_snapshot_retention_days")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_snapshot_retention_days: %v", cfg.OffsetSnapshotRetentionDays)
+
+	// topic sample interval
+	cfg.TopicSampleInterval = viper.GetInt("topic_sample_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_sample_interval: %v", cfg.TopicSampleInterval)
+
+	// topic throughput windows
+	cfg.TopicThroughputWindows = viper.GetStringSlice("topic_throughput_windows")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_throughput_windows: %v", cfg.TopicThroughputWindows)
+
+	// store project routes
+	cfg.StoreProjectRoutes = viper.GetStringSlice("store_project_routes")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_project_routes: %v", cfg.StoreProjectRoutes)
+
+	// dedup window
+	cfg.DedupWindow = viper.GetInt("dedup_window")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - dedup_window: %v", cfg.DedupWindow)
+
+	// ack id secret
+	cfg.AckIDSecret = viper.GetString("ack_id_secret")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - ack_id_secret")
+
+	// broker pool size
+	cfg.BrokerPoolSize = viper.GetInt("broker_pool_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - broker_pool_size: %v", cfg.BrokerPoolSize)
+
+	// acl cache ttl
+	cfg.ACLCacheTTL = viper.GetInt("acl_cache_ttl")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - acl_cache_ttl: %v", cfg.ACLCacheTTL)
+
+	// acl history retention days
+	cfg.ACLHistoryRetentionDays = viper.GetInt("acl_history_retention_days")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - acl_history_retention_days: %v", cfg.ACLHistoryRetentionDays)
+
+	// case insensitive usernames
+	cfg.CaseInsensitiveUsernames = viper.GetBool("case_insensitive_usernames")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - case_insensitive_usernames: %v", cfg.CaseInsensitiveUsernames)
+
+	// pull lease ttl
+	cfg.PullLeaseTTL = viper.GetInt("pull_lease_ttl")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - pull_lease_ttl: %v", cfg.PullLeaseTTL)
+
+	// default page size
+	cfg.DefaultPageSize = viper.GetInt("default_page_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - default_page_size: %v", cfg.DefaultPageSize)
+
+	// max page size
+	cfg.MaxPageSize = viper.GetInt("max_page_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - max_page_size: %v", cfg.MaxPageSize)
+
+	// reject oversized pages
+	cfg.RejectOversizedPages = viper.GetBool("reject_oversized_pages")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - reject_oversized_pages: %v", cfg.RejectOversizedPages)
+
+	// publish time max skew
+	cfg.PublishTimeMaxSkew = viper.GetInt("publish_time_max_skew")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - publish_time_max_skew: %v", cfg.PublishTimeMaxSkew)
+
+	// payload limits
+	cfg.PayloadMaxDepth = viper.GetInt("payload_max_depth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - payload_max_depth: %v", cfg.PayloadMaxDepth)
+
+	cfg.PayloadMaxFields = viper.GetInt("payload_max_fields")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - payload_max_fields: %v", cfg.PayloadMaxFields)
+
+	cfg.MaxMessageSize = viper.GetInt("max_message_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - max_message_size: %v", cfg.MaxMessageSize)
+
+	cfg.TopicPublishMaxMessagesRate = viper.GetFloat64("topic_publish_max_messages_rate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_publish_max_messages_rate: %v", cfg.TopicPublishMaxMessagesRate)
+
+	cfg.TopicPublishMaxBytesRate = viper.GetFloat64("topic_publish_max_bytes_rate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_publish_max_bytes_rate: %v", cfg.TopicPublishMaxBytesRate)
+
+	cfg.VerifyAckOffsets = viper.GetBool("verify_ack_offsets")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - verify_ack_offsets: %v", cfg.VerifyAckOffsets)
+
+	// json naming
+	cfg.setJSONNaming(viper.GetString("json_naming"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - json_naming: %v", cfg.JSONNaming)
+
+	// drain sink
+	cfg.DrainSink = viper.GetString("drain_sink")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - drain_sink: %v", cfg.DrainSink)
+
+	// broker topic prefix
+	cfg.BrokerTopicPrefix = viper.GetString("broker_topic_prefix")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - broker_topic_prefix: %v", cfg.BrokerTopicPrefix)
+
+	// event sink
+	cfg.EventSink = viper.GetString("event_sink")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - event_sink: %v", cfg.EventSink)
+
+	// default max messages of pulls
+	cfg.DefaultMaxMessages = viper.GetInt("default_max_messages")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - default_max_messages: %v", cfg.DefaultMaxMessages)
+
+}
+
+// LoadStrJSON Loads configuration from a JSON string
+func (cfg *APICfg) LoadStrJSON(input string) {
+	viper.SetConfigType("json")
+	viper.ReadConfig(strings.NewReader(input))
+	// Load Kafka configuration
+	// bind ip
+	cfg.BindIP = viper.GetString("bind_ip")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - bind_ip: %v", cfg.BindIP)
+
+	// service port
+	cfg.Port = viper.GetInt("port")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - port: %v", cfg.Port)
+
+	// zookeeper hosts
+	cfg.ZooHosts = viper.GetStringSlice("zookeeper_hosts")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - zookeeper_hosts: %v", cfg.ZooHosts)
+
+	// kafka_znode
+	cfg.KafkaZnode = viper.GetString("kafka_znode")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - kafka_znode: %v", cfg.KafkaZnode)
+
+	// store host
+	cfg.StoreHost = viper.GetString("store_host")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_host: %v", cfg.StoreHost)
+
+	// store name
+	cfg.StoreDB = viper.GetString("store_db")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_db: %v", cfg.StoreDB)
+
+	// service certificate
+	cfg.Cert = viper.GetString("certificate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate: %v", cfg.Cert)
+
+	// service certificate key
+	cfg.CertKey = viper.GetString("certificate_key")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate_key: %v", cfg.CertKey)
+
+	// certificate authorities directory
+	cfg.CertificateAuthoritiesDir = viper.GetString("certificate_authorities_dir")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - certificate_authorities_dir: %v", cfg.CertificateAuthoritiesDir)
+
+	// per resource authorisation
+	cfg.ResAuth = viper.GetBool("per_resource_auth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - per_resource_auth: %v", cfg.ResAuth)
+
+	// service token
+	cfg.ServiceToken = viper.GetString("service_token")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - service_token:")
+
+	// push enabled true or false
+	cfg.PushEnabled = viper.GetBool("push_enabled")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_enabled: %v", cfg.PushEnabled)
+
+	// push TLS enabled true or false
+	cfg.PushTlsEnabled = viper.GetBool("push_tls_enabled")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_tls_enabled: %v", cfg.PushTlsEnabled)
+
+	// push server host
+	cfg.PushServerHost = viper.GetString("push_server_host")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_server_host: %v", cfg.PushServerHost)
+
+	// push server port
+	cfg.PushServerPort = viper.GetInt("push_server_port")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_server_port: %v", cfg.PushServerPort)
+
+	// verify push server
+	cfg.VerifyPushServer = viper.GetBool("verify_push_server")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - verify_push_server: %v", cfg.VerifyPushServer)
+
+	// push worker token
+	cfg.PushWorkerToken = viper.GetString("push_worker_token")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - push_worker_token")
+
+	// push sqs region
+	cfg.PushSQSRegion = viper.GetString("push_sqs_region")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_region: %v", cfg.PushSQSRegion)
+
+	// push sqs access key id
+	cfg.PushSQSAccessKeyID = viper.GetString("push_sqs_access_key_id")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_access_key_id: %v", cfg.PushSQSAccessKeyID)
+
+	// push sqs secret access key
+	cfg.PushSQSSecretAccessKey = viper.GetString("push_sqs_secret_access_key")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - push_sqs_secret_access_key")
+
+	// push sqs allowed queues
+	cfg.PushSQSAllowedQueues = viper.GetStringSlice("push_sqs_allowed_queues")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - push_sqs_allowed_queues: %v", cfg.PushSQSAllowedQueues)
+
+	cfg.LogFacilities = viper.GetStringSlice("log_facilities")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_facilities: %v", cfg.LogFacilities)
+
+	cfg.LogFormat = viper.GetString("log_format")
+	setLogFormat(cfg.LogFormat)
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - log_format: %v", cfg.LogFormat)
+
+	// auth option
+	cfg.setAuthOption(viper.GetString("auth_option"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
 	).Infof("Parameter Loaded - auth_option: %v", cfg.AuthOption())
+
+	// maintenance mode
+	cfg.SetMaintenanceMode(viper.GetBool("maintenance_mode"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - maintenance_mode: %v", cfg.MaintenanceMode())
+
+	// disable auto offset advance
+	cfg.DisableAutoOffsetAdvance = viper.GetBool("disable_auto_offset_advance")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - disable_auto_offset_advance: %v", cfg.DisableAutoOffsetAdvance)
+
+	// topic delete grace period
+	cfg.TopicDeleteGracePeriod = viper.GetInt("topic_delete_grace_period")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_delete_grace_period: %v", cfg.TopicDeleteGracePeriod)
+
+	// publish acks level
+	cfg.setPublishAcks(viper.GetString("publish_acks"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - publish_acks: %v", cfg.PublishAcks)
+
+	// request timeout
+	cfg.RequestTimeout = viper.GetInt("request_timeout")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - request_timeout: %v", cfg.RequestTimeout)
+
+	// offset reconcile interval
+	cfg.OffsetReconcileInterval = viper.GetInt("offset_reconcile_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_reconcile_interval: %v", cfg.OffsetReconcileInterval)
+
+	// offset snapshots
+	cfg.OffsetSnapshotInterval = viper.GetInt("offset_snapshot_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_snapshot_interval: %v", cfg.OffsetSnapshotInterval)
+
+	cfg.OffsetSnapshotRetentionDays = viper.GetInt("offset_snapshot_retention_days")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - offset_snapshot_retention_days: %v", cfg.OffsetSnapshotRetentionDays)
+
+	// topic sample interval
+	cfg.TopicSampleInterval = viper.GetInt("topic_sample_interval")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_sample_interval: %v", cfg.TopicSampleInterval)
+
+	// topic throughput windows
+	cfg.TopicThroughputWindows = viper.GetStringSlice("topic_throughput_windows")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_throughput_windows: %v", cfg.TopicThroughputWindows)
+
+	// store project routes
+	cfg.StoreProjectRoutes = viper.GetStringSlice("store_project_routes")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - store_project_routes: %v", cfg.StoreProjectRoutes)
+
+	// dedup window
+	cfg.DedupWindow = viper.GetInt("dedup_window")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - dedup_window: %v", cfg.DedupWindow)
+
+	// ack id secret
+	cfg.AckIDSecret = viper.GetString("ack_id_secret")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Info("Parameter Loaded - ack_id_secret")
+
+	// broker pool size
+	cfg.BrokerPoolSize = viper.GetInt("broker_pool_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - broker_pool_size: %v", cfg.BrokerPoolSize)
+
+	// acl cache ttl
+	cfg.ACLCacheTTL = viper.GetInt("acl_cache_ttl")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - acl_cache_ttl: %v", cfg.ACLCacheTTL)
+
+	// acl history retention days
+	cfg.ACLHistoryRetentionDays = viper.GetInt("acl_history_retention_days")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - acl_history_retention_days: %v", cfg.ACLHistoryRetentionDays)
+
+	// case insensitive usernames
+	cfg.CaseInsensitiveUsernames = viper.GetBool("case_insensitive_usernames")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - case_insensitive_usernames: %v", cfg.CaseInsensitiveUsernames)
+
+	// pull lease ttl
+	cfg.PullLeaseTTL = viper.GetInt("pull_lease_ttl")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - pull_lease_ttl: %v", cfg.PullLeaseTTL)
+
+	// default page size
+	cfg.DefaultPageSize = viper.GetInt("default_page_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - default_page_size: %v", cfg.DefaultPageSize)
+
+	// max page size
+	cfg.MaxPageSize = viper.GetInt("max_page_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - max_page_size: %v", cfg.MaxPageSize)
+
+	// reject oversized pages
+	cfg.RejectOversizedPages = viper.GetBool("reject_oversized_pages")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - reject_oversized_pages: %v", cfg.RejectOversizedPages)
+
+	// publish time max skew
+	cfg.PublishTimeMaxSkew = viper.GetInt("publish_time_max_skew")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - publish_time_max_skew: %v", cfg.PublishTimeMaxSkew)
+
+	// payload limits
+	cfg.PayloadMaxDepth = viper.GetInt("payload_max_depth")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - payload_max_depth: %v", cfg.PayloadMaxDepth)
+
+	cfg.PayloadMaxFields = viper.GetInt("payload_max_fields")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - payload_max_fields: %v", cfg.PayloadMaxFields)
+
+	cfg.MaxMessageSize = viper.GetInt("max_message_size")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - max_message_size: %v", cfg.MaxMessageSize)
+
+	cfg.TopicPublishMaxMessagesRate = viper.GetFloat64("topic_publish_max_messages_rate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_publish_max_messages_rate: %v", cfg.TopicPublishMaxMessagesRate)
+
+	cfg.TopicPublishMaxBytesRate = viper.GetFloat64("topic_publish_max_bytes_rate")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - topic_publish_max_bytes_rate: %v", cfg.TopicPublishMaxBytesRate)
+
+	cfg.VerifyAckOffsets = viper.GetBool("verify_ack_offsets")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - verify_ack_offsets: %v", cfg.VerifyAckOffsets)
+
+	// json naming
+	cfg.setJSONNaming(viper.GetString("json_naming"))
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - json_naming: %v", cfg.JSONNaming)
+
+	// drain sink
+	cfg.DrainSink = viper.GetString("drain_sink")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - drain_sink: %v", cfg.DrainSink)
+
+	// broker topic prefix
+	cfg.BrokerTopicPrefix = viper.GetString("broker_topic_prefix")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - broker_topic_prefix: %v", cfg.BrokerTopicPrefix)
+
+	// event sink
+	cfg.EventSink = viper.GetString("event_sink")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - event_sink: %v", cfg.EventSink)
+
+	// default max messages of pulls
+	cfg.DefaultMaxMessages = viper.GetInt("default_max_messages")
+	log.WithFields(
+		log.Fields{
+			"type": "service_log",
+		},
+	).Infof("Parameter Loaded - default_max_messages: %v", cfg.DefaultMaxMessages)
+
 }
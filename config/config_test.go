@@ -3,6 +3,7 @@ package config
 import (
 	"io/ioutil"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -32,8 +33,46 @@ func (suite *ConfigTestSuite) SetupTest() {
 		"push_server_port": 5555,
 		"verify_push_server": "true",
         "push_worker_token": "pw-token",
+		"push_sqs_region": "eu-west-1",
+		"push_sqs_access_key_id": "AKIDEXAMPLE",
+		"push_sqs_secret_access_key": "sqs-s3cr3t",
+		"push_sqs_allowed_queues": ["ARGO=123456789012"],
 		"log_facilities": ["SYSLOG", "CONSOLE"],
-        "auth_option": "header"
+		"log_format": "TEXT",
+        "auth_option": "header",
+		"maintenance_mode": true,
+		"disable_auto_offset_advance": true,
+		"topic_delete_grace_period": 86400,
+		"publish_acks": "1",
+		"request_timeout": 60,
+		"offset_reconcile_interval": 3600,
+		"offset_snapshot_interval": 900,
+		"offset_snapshot_retention_days": 3,
+		"topic_sample_interval": 60,
+		"topic_throughput_windows": ["5m", "1h"],
+		"store_project_routes": ["argo_uuid=argo_msgs_argo"],
+		"dedup_window": 500,
+		"ack_id_secret": "s3cr3t",
+		"broker_pool_size": 4,
+		"acl_cache_ttl": 5,
+		"acl_history_retention_days": 90,
+		"case_insensitive_usernames": true,
+		"pull_lease_ttl": 20,
+		"default_page_size": 50,
+		"max_page_size": 200,
+		"reject_oversized_pages": true,
+		"publish_time_max_skew": 30,
+		"payload_max_depth": 32,
+		"payload_max_fields": 10000,
+		"max_message_size": 2097152,
+		"topic_publish_max_messages_rate": 500,
+		"topic_publish_max_bytes_rate": 1048576,
+		"verify_ack_offsets": true,
+		"json_naming": "snake_case",
+		"drain_sink": "file:///var/lib/argo-messaging/drains",
+		"broker_topic_prefix": "devel",
+		"event_sink": "https://hooks.example.com/ams",
+		"default_max_messages": 50
 	}`
 }
 
@@ -69,6 +108,8 @@ func (suite *ConfigTestSuite) TestLoadConfiguration() {
 }
 
 func (suite *ConfigTestSuite) TestLoadStringJSON() {
+	// the loaded log format replaces the one in use
+	setLogFormat("json")
 	APIcfg := NewAPICfg()
 	APIcfg.LoadStrJSON(suite.cfgStr)
 	suite.Equal([]string{"localhost"}, APIcfg.ZooHosts)
@@ -86,8 +127,59 @@ func (suite *ConfigTestSuite) TestLoadStringJSON() {
 	suite.Equal(5555, APIcfg.PushServerPort)
 	suite.True(APIcfg.VerifyPushServer)
 	suite.Equal("pw-token", APIcfg.PushWorkerToken)
+	suite.Equal("eu-west-1", APIcfg.PushSQSRegion)
+	suite.Equal("AKIDEXAMPLE", APIcfg.PushSQSAccessKeyID)
+	suite.Equal("sqs-s3cr3t", APIcfg.PushSQSSecretAccessKey)
+	suite.Equal([]string{"ARGO=123456789012"}, APIcfg.PushSQSAllowedQueues)
 	suite.Equal([]string{"SYSLOG", "CONSOLE"}, APIcfg.LogFacilities)
+	suite.Equal("TEXT", APIcfg.LogFormat)
+	_, isText := log.StandardLogger().Formatter.(*log.TextFormatter)
+	suite.True(isText)
 	suite.Equal(HeaderKey, int(APIcfg.AuthOption()))
+	suite.True(APIcfg.MaintenanceMode())
+	suite.True(APIcfg.DisableAutoOffsetAdvance)
+	suite.Equal(86400, APIcfg.TopicDeleteGracePeriod)
+	suite.Equal("1", APIcfg.PublishAcks)
+	suite.Equal(60, APIcfg.RequestTimeout)
+	suite.Equal(3600, APIcfg.OffsetReconcileInterval)
+	suite.Equal(900, APIcfg.OffsetSnapshotInterval)
+	suite.Equal(3, APIcfg.OffsetSnapshotRetentionDays)
+	suite.Equal(60, APIcfg.TopicSampleInterval)
+	suite.Equal([]string{"5m", "1h"}, APIcfg.TopicThroughputWindows)
+	suite.Equal([]string{"argo_uuid=argo_msgs_argo"}, APIcfg.StoreProjectRoutes)
+	suite.Equal(500, APIcfg.DedupWindow)
+	suite.Equal("s3cr3t", APIcfg.AckIDSecret)
+	suite.Equal(4, APIcfg.BrokerPoolSize)
+	suite.Equal(5, APIcfg.ACLCacheTTL)
+	suite.Equal(90, APIcfg.ACLHistoryRetentionDays)
+	suite.True(APIcfg.CaseInsensitiveUsernames)
+	suite.Equal(20, APIcfg.PullLeaseTTL)
+	suite.Equal(50, APIcfg.DefaultPageSize)
+	suite.Equal(200, APIcfg.MaxPageSize)
+	suite.True(APIcfg.RejectOversizedPages)
+	suite.Equal(30, APIcfg.PublishTimeMaxSkew)
+	suite.Equal(32, APIcfg.PayloadMaxDepth)
+	suite.Equal(10000, APIcfg.PayloadMaxFields)
+	suite.Equal(2097152, APIcfg.MaxMessageSize)
+	suite.Equal(500.0, APIcfg.TopicPublishMaxMessagesRate)
+	suite.Equal(1048576.0, APIcfg.TopicPublishMaxBytesRate)
+	suite.True(APIcfg.VerifyAckOffsets)
+	suite.Equal("snake_case", APIcfg.JSONNaming)
+	suite.Equal("file:///var/lib/argo-messaging/drains", APIcfg.DrainSink)
+	suite.Equal("devel", APIcfg.BrokerTopicPrefix)
+	suite.Equal("https://hooks.example.com/ams", APIcfg.EventSink)
+	suite.Equal(50, APIcfg.DefaultMaxMessages)
+}
+
+func (suite *ConfigTestSuite) TestSetMaintenanceMode() {
+	cfg := APICfg{}
+	suite.False(cfg.MaintenanceMode())
+
+	cfg.SetMaintenanceMode(true)
+	suite.True(cfg.MaintenanceMode())
+
+	cfg.SetMaintenanceMode(false)
+	suite.False(cfg.MaintenanceMode())
 }
 
 func (suite *ConfigTestSuite) TestSetAuthOption() {
@@ -107,6 +199,49 @@ func (suite *ConfigTestSuite) TestSetAuthOption() {
 	suite.Equal(UrlKey, int(cfg.authOption))
 }
 
+func (suite *ConfigTestSuite) TestSetPublishAcks() {
+	cfg := APICfg{}
+
+	cfg.setPublishAcks("0")
+	suite.Equal("0", cfg.PublishAcks)
+
+	cfg.setPublishAcks("ALL")
+	suite.Equal("all", cfg.PublishAcks)
+
+	cfg.setPublishAcks("")
+	suite.Equal("all", cfg.PublishAcks)
+
+	// unsupported values fall back to the most durable level
+	cfg.setPublishAcks("2")
+	suite.Equal("all", cfg.PublishAcks)
+}
+
+func (suite *ConfigTestSuite) TestSetJSONNaming() {
+	cfg := APICfg{}
+
+	cfg.setJSONNaming("CAMEL_CASE")
+	suite.Equal("camel_case", cfg.JSONNaming)
+
+	cfg.setJSONNaming("")
+	suite.Equal("default", cfg.JSONNaming)
+
+	// unsupported conventions fall back to the declared field names
+	cfg.setJSONNaming("kebab-case")
+	suite.Equal("default", cfg.JSONNaming)
+}
+
+func (suite *ConfigTestSuite) TestSetLogFormat() {
+
+	setLogFormat("json")
+	_, isJSON := log.StandardLogger().Formatter.(*log.JSONFormatter)
+	suite.True(isJSON)
+
+	// anything else falls back to plain text
+	setLogFormat("xml")
+	_, isText := log.StandardLogger().Formatter.(*log.TextFormatter)
+	suite.True(isText)
+}
+
 func (suite *ConfigTestSuite) TestAuthOption() {
 
 	a1 := AuthOption(UrlKey)
@@ -119,6 +254,16 @@ func (suite *ConfigTestSuite) TestAuthOption() {
 	suite.Equal("both", a3.String())
 }
 
+func (suite *ConfigTestSuite) TestSetClock() {
+
+	cfg := NewAPICfg()
+	suite.WithinDuration(time.Now(), cfg.Now(), time.Minute)
+
+	fixed := time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)
+	cfg.SetClock(func() time.Time { return fixed })
+	suite.Equal(fixed, cfg.Now())
+}
+
 func TestConfigTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(ConfigTestSuite))
@@ -0,0 +1,224 @@
+package events
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	log "github.com/sirupsen/logrus"
+)
+
+// The types of the lifecycle events emitted for the resources of the service
+const (
+	TopicCreated        = "topic.created"
+	TopicDeleted        = "topic.deleted"
+	SubscriptionCreated = "subscription.created"
+	SubscriptionDeleted = "subscription.deleted"
+	ACLModified         = "acl.modified"
+)
+
+// queueSize is the number of events waiting to be emitted, after which new events are dropped
+const queueSize = 1000
+
+// webhookTimeout bounds the delivery of an event to a webhook
+const webhookTimeout = 10 * time.Second
+
+// Event describes a change of a resource, e.g. a topic that got created
+type Event struct {
+	Type string `json:"type"`
+	// Resource is the full name of the resource, e.g. /projects/ARGO/topics/topic1
+	Resource  string `json:"resource"`
+	Actor     string `json:"actor"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Emitter delivers the events to the system that consumes them
+type Emitter interface {
+	Emit(evt Event) error
+}
+
+// WebhookEmitter posts the events as json to an http endpoint
+type WebhookEmitter struct {
+	URL    string
+	Client http.Client
+}
+
+// Emit posts the event to the webhook, failing unless the endpoint accepts it
+func (we *WebhookEmitter) Emit(evt Event) error {
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	resp, err := we.Client.Post(we.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %v", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// TopicEmitter publishes the events as messages to a broker topic of the service's own,
+// the message data holding the json event and the type attribute its type
+type TopicEmitter struct {
+	Broker brokers.Broker
+	Topic  string
+}
+
+// Emit publishes the event to the broker topic
+func (te *TopicEmitter) Emit(evt Event) error {
+
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+
+	msg := messages.Message{
+		Attr:    messages.Attributes{"type": evt.Type},
+		Data:    base64.StdEncoding.EncodeToString(body),
+		PubTime: evt.Timestamp,
+	}
+
+	_, _, _, _, err = te.Broker.Publish(te.Topic, msg, "", "")
+	return err
+}
+
+// OpenEmitter creates the emitter that the sink url points to, either an http(s) webhook
+// or a broker topic given as topic://name
+func OpenEmitter(rawURL string, broker brokers.Broker) (Emitter, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return &WebhookEmitter{URL: rawURL, Client: http.Client{Timeout: webhookTimeout}}, nil
+	case "topic":
+		if u.Host == "" {
+			return nil, errors.New("the event topic is missing")
+		}
+		return &TopicEmitter{Broker: broker, Topic: u.Host}, nil
+	}
+
+	return nil, errors.New("unsupported event sink " + rawURL + ", it should be an http(s) url or topic://name")
+}
+
+// Publisher queues the events and emits them in the background, so that the requests
+// that caused them neither wait for nor fail because of their delivery
+type Publisher struct {
+	emitter Emitter
+	queue   chan Event
+	done    chan struct{}
+}
+
+// NewPublisher creates a publisher that emits its events through the emitter
+func NewPublisher(emitter Emitter) *Publisher {
+
+	p := &Publisher{
+		emitter: emitter,
+		queue:   make(chan Event, queueSize),
+		done:    make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+func (p *Publisher) run() {
+
+	defer close(p.done)
+
+	for evt := range p.queue {
+		if err := p.emitter.Emit(evt); err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":     "service_log",
+					"event":    evt.Type,
+					"resource": evt.Resource,
+					"error":    err.Error(),
+				},
+			).Error("Could not emit event")
+		}
+	}
+}
+
+// Publish queues the event, dropping it if the queue is full. It returns whether the event was queued
+func (p *Publisher) Publish(evt Event) bool {
+	select {
+	case p.queue <- evt:
+		return true
+	default:
+		log.WithFields(
+			log.Fields{
+				"type":     "service_log",
+				"event":    evt.Type,
+				"resource": evt.Resource,
+			},
+		).Warning("Event queue is full, dropping event")
+		return false
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be emitted
+func (p *Publisher) Close() {
+	close(p.queue)
+	<-p.done
+}
+
+var (
+	publisherMu sync.RWMutex
+	publisher   *Publisher
+)
+
+// SetPublisher sets the publisher that the events of the service go through, nil disables the events
+func SetPublisher(p *Publisher) {
+	publisherMu.Lock()
+	defer publisherMu.Unlock()
+	publisher = p
+}
+
+// Enabled returns whether or not the events of the service are emitted
+func Enabled() bool {
+	publisherMu.RLock()
+	defer publisherMu.RUnlock()
+	return publisher != nil
+}
+
+// Emit queues an event of the given type for the resource, stamped with the current time.
+// It does nothing if the events are disabled
+func Emit(eventType string, resource string, actor string) {
+
+	publisherMu.RLock()
+	p := publisher
+	publisherMu.RUnlock()
+
+	if p == nil {
+		return
+	}
+
+	p.Publish(Event{
+		Type:      eventType,
+		Resource:  resource,
+		Actor:     actor,
+		Timestamp: timestamp.FormatNano(time.Now()),
+	})
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+}
+
+// recordingEmitter keeps the events it emits, failing them if told to
+type recordingEmitter struct {
+	mu      sync.Mutex
+	emitted []Event
+	fail    bool
+	// block holds the emission of the events until it gets closed
+	block chan struct{}
+}
+
+func (re *recordingEmitter) Emit(evt Event) error {
+	if re.block != nil {
+		<-re.block
+	}
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.emitted = append(re.emitted, evt)
+	if re.fail {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (suite *EventsTestSuite) TestEmit() {
+
+	// disabled events are not emitted
+	SetPublisher(nil)
+	suite.False(Enabled())
+	Emit(TopicCreated, "/projects/ARGO/topics/topic1", "UserA")
+
+	emitter := &recordingEmitter{}
+	p := NewPublisher(emitter)
+	SetPublisher(p)
+	suite.True(Enabled())
+
+	Emit(TopicCreated, "/projects/ARGO/topics/topic1", "UserA")
+	Emit(ACLModified, "/projects/ARGO/subscriptions/sub1", "UserB")
+
+	SetPublisher(nil)
+	p.Close()
+
+	suite.Equal(2, len(emitter.emitted))
+	suite.Equal(TopicCreated, emitter.emitted[0].Type)
+	suite.Equal("/projects/ARGO/topics/topic1", emitter.emitted[0].Resource)
+	suite.Equal("UserA", emitter.emitted[0].Actor)
+	suite.NotEqual("", emitter.emitted[0].Timestamp)
+	suite.Equal(ACLModified, emitter.emitted[1].Type)
+	suite.Equal("UserB", emitter.emitted[1].Actor)
+
+	// failed emissions don't stop the ones that follow
+	emitter = &recordingEmitter{fail: true}
+	p = NewPublisher(emitter)
+	suite.True(p.Publish(Event{Type: TopicDeleted}))
+	suite.True(p.Publish(Event{Type: SubscriptionDeleted}))
+	p.Close()
+	suite.Equal(2, len(emitter.emitted))
+}
+
+func (suite *EventsTestSuite) TestPublishFullQueue() {
+
+	emitter := &recordingEmitter{block: make(chan struct{})}
+	p := NewPublisher(emitter)
+
+	// one event is held by the emitter while the rest fill the queue
+	queued := 0
+	for i := 0; i < queueSize+2; i++ {
+		if p.Publish(Event{Type: SubscriptionCreated}) {
+			queued++
+		}
+	}
+	suite.True(queued <= queueSize+1)
+	suite.True(queued >= queueSize)
+
+	close(emitter.block)
+	p.Close()
+	suite.Equal(queued, len(emitter.emitted))
+}
+
+func (suite *EventsTestSuite) TestWebhookEmitter() {
+
+	received := Event{}
+	status := http.StatusNoContent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	emitter, err := OpenEmitter(ts.URL, nil)
+	suite.Nil(err)
+
+	evt := Event{Type: TopicCreated, Resource: "/projects/ARGO/topics/topic1", Actor: "UserA", Timestamp: "2020-11-19T00:00:00Z"}
+	suite.Nil(emitter.Emit(evt))
+	suite.Equal(evt, received)
+
+	status = http.StatusInternalServerError
+	suite.Equal("webhook responded with 500", emitter.Emit(evt).Error())
+}
+
+func (suite *EventsTestSuite) TestTopicEmitter() {
+
+	brk := &brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+
+	emitter, err := OpenEmitter("topic://ams_events", brk)
+	suite.Nil(err)
+	suite.Equal("ams_events", emitter.(*TopicEmitter).Topic)
+
+	evt := Event{Type: SubscriptionDeleted, Resource: "/projects/ARGO/subscriptions/sub1", Actor: "UserA", Timestamp: "2020-11-19T00:00:00Z"}
+	suite.Nil(emitter.Emit(evt))
+
+	msg, _ := messages.LoadMsgJSON([]byte(brk.MsgList[len(brk.MsgList)-1]))
+	suite.Equal("subscription.deleted", msg.Attr["type"])
+	suite.Equal("2020-11-19T00:00:00Z", msg.PubTime)
+	suite.Equal(`{"type":"subscription.deleted","resource":"/projects/ARGO/subscriptions/sub1","actor":"UserA","timestamp":"2020-11-19T00:00:00Z"}`, msg.GetDecoded())
+}
+
+func (suite *EventsTestSuite) TestOpenEmitter() {
+
+	_, err := OpenEmitter("topic://", nil)
+	suite.Equal("the event topic is missing", err.Error())
+
+	_, err = OpenEmitter("s3://bucket", nil)
+	suite.Equal("unsupported event sink s3://bucket, it should be an http(s) url or topic://name", err.Error())
+}
+
+func TestEventsTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
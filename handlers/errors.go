@@ -16,6 +16,8 @@ type APIErrorBody struct {
 	Message string     `json:"message"`
 	ErrList []APIError `json:"errors,omitempty"`
 	Status  string     `json:"status"`
+	// RetryAfter is the number of seconds a rate limited client should wait before retrying
+	RetryAfter int `json:"retryAfter,omitempty"`
 }
 
 // APIError represents array items for error list array
@@ -310,3 +312,87 @@ var APIErrInternalPush = func() APIErrorRoot {
 		Body: apiErrBody,
 	}
 }
+
+// api error to be used when the service is in maintenance mode and the requested operation is not permitted
+var APIErrorMaintenance = func() APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusServiceUnavailable,
+		Message: "Service is under maintenance, write operations are temporarily disabled",
+		Status:  "UNAVAILABLE",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// api error to be used when the broker doesn't support the requested operation
+var APIErrorNotImplemented = func(msg string) APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusNotImplemented,
+		Message: msg,
+		Status:  "NOT_IMPLEMENTED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// api error to be used when a request body is declared with a content type that isn't accepted
+var APIErrorUnsupportedMediaType = func(contentType string) APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusUnsupportedMediaType,
+		Message: fmt.Sprintf("Unsupported content type %v, request bodies should be application/json", contentType),
+		Status:  "UNSUPPORTED_MEDIA_TYPE",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// api error to be used when a request doesn't complete before the configured deadline
+var APIErrorDeadlineExceeded = func() APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusGatewayTimeout,
+		Message: "Request deadline exceeded",
+		Status:  "DEADLINE_EXCEEDED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// api error to be used when a topic is published to faster than its publish rate limits allow
+var APIErrorPublishRateLimited = func(topic string) APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusTooManyRequests,
+		Message: fmt.Sprintf("Publish rate limit of topic %v has been reached, please retry", topic),
+		Status:  "RESOURCE_EXHAUSTED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
+
+// api error to be used when a subscription already has as many pulls in progress as it allows
+var APIErrorTooManyPulls = func() APIErrorRoot {
+
+	apiErrBody := APIErrorBody{
+		Code:    http.StatusTooManyRequests,
+		Message: "Subscription has reached its max concurrent pulls, please retry",
+		Status:  "RESOURCE_EXHAUSTED",
+	}
+
+	return APIErrorRoot{
+		Body: apiErrBody,
+	}
+}
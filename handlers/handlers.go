@@ -1,23 +1,36 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/events"
+	"github.com/ARGOeu/argo-messaging/naming"
 	"github.com/ARGOeu/argo-messaging/projects"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	"github.com/ARGOeu/argo-messaging/validation"
 	"github.com/ARGOeu/argo-messaging/version"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -36,6 +49,15 @@ func WrapValidate(hfn http.HandlerFunc) http.HandlerFunc {
 
 		// Iterate alphabetically
 		for _, key := range keys {
+			// operations are named after the routes they refer to, e.g. topics:publish
+			if key == "operation" {
+				if validation.ValidOperationName(urlVars[key]) == false {
+					err := APIErrorInvalidName(key)
+					respondErr(w, err)
+					return
+				}
+				continue
+			}
 			if validation.ValidName(urlVars[key]) == false {
 				err := APIErrorInvalidName(key)
 				respondErr(w, err)
@@ -73,13 +95,16 @@ func WrapMockAuthConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Br
 		gorillaContext.Set(r, "auth_roles", userRoles)
 		gorillaContext.Set(r, "push_worker_token", cfg.PushWorkerToken)
 		gorillaContext.Set(r, "push_enabled", cfg.PushEnabled)
+		gorillaContext.Set(r, "disable_auto_offset_advance", cfg.DisableAutoOffsetAdvance)
+		gorillaContext.Set(r, "cfg", cfg)
 		hfn.ServeHTTP(w, r)
 
 	})
 }
 
 // WrapConfig handle wrapper to retrieve kafka configuration
-func WrapConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *oldPush.Manager, c push.Client) http.HandlerFunc {
+// operations holds the names of all the operations(routes) that the service supports
+func WrapConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *oldPush.Manager, c push.Client, operations []string) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		nStr := str.Clone()
@@ -90,8 +115,11 @@ func WrapConfig(hfn http.HandlerFunc, cfg *config.APICfg, brk brokers.Broker, st
 		gorillaContext.Set(r, "apsc", c)
 		gorillaContext.Set(r, "auth_resource", cfg.ResAuth)
 		gorillaContext.Set(r, "auth_service_token", cfg.ServiceToken)
+		gorillaContext.Set(r, "operations", operations)
 		gorillaContext.Set(r, "push_worker_token", cfg.PushWorkerToken)
 		gorillaContext.Set(r, "push_enabled", cfg.PushEnabled)
+		gorillaContext.Set(r, "disable_auto_offset_advance", cfg.DisableAutoOffsetAdvance)
+		gorillaContext.Set(r, "cfg", cfg)
 		hfn.ServeHTTP(w, r)
 
 	})
@@ -139,8 +167,9 @@ func WrapAuthenticate(hfn http.Handler, extractToken RequestTokenExtractStrategy
 		projectName := urlVars["project"]
 		projectUUID := projects.GetUUIDByName(urlVars["project"], refStr)
 
-		// In all cases instead of project create
-		if "projects:create" != mux.CurrentRoute(r).GetName() {
+		// In all cases instead of project create and an idempotent project delete
+		routeName := mux.CurrentRoute(r).GetName()
+		if "projects:create" != routeName && !("projects:delete" == routeName && ignoreNotFound(r)) {
 			// Check if given a project name the project wasn't found
 			if projectName != "" && projectUUID == "" {
 				apiErr := APIErrorNotFound("project")
@@ -191,7 +220,7 @@ func WrapAuthorize(hfn http.Handler, routeName string, extractToken RequestToken
 			return
 		}
 
-		if auth.Authorize(routeName, refRoles, refStr) {
+		if auth.Authorize(routeName, refRoles, refStr) || isSelfServed(r, routeName) {
 			hfn.ServeHTTP(w, r)
 		} else {
 			err := APIErrorForbidden()
@@ -200,6 +229,240 @@ func WrapAuthorize(hfn http.Handler, routeName string, extractToken RequestToken
 	})
 }
 
+// selfServiceRoutes holds the routes that users are always authorized to call for themselves,
+// regardless of the roles that grant access to them
+var selfServiceRoutes = map[string]bool{
+	"users:refreshToken": true,
+}
+
+// isSelfServed returns true if the route is a self service one and the user it targets is the authenticated user
+func isSelfServed(r *http.Request, routeName string) bool {
+
+	if !selfServiceRoutes[routeName] {
+		return false
+	}
+
+	user, _ := gorillaContext.Get(r, "auth_user").(string)
+	return user != "" && user == mux.Vars(r)["user"]
+}
+
+// readOnlyWriteRoutes holds the routes that use a non GET http method
+// but do not modify any state, so they remain available during maintenance
+var readOnlyWriteRoutes = map[string]bool{
+	"ams:maintenance":         true,
+	"subscriptions:pull":      true,
+	"schemas:validateMessage": true,
+}
+
+// IsMutatingOperation classifies a route, based on its http method and name,
+// as an operation that modifies the state of the service
+func IsMutatingOperation(method string, routeName string) bool {
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+
+	return !readOnlyWriteRoutes[routeName]
+}
+
+// WrapMaintenance handle wrapper that rejects mutating operations while the service is in maintenance mode
+func WrapMaintenance(hfn http.Handler, cfg *config.APICfg, method string, routeName string) http.HandlerFunc {
+
+	mutating := IsMutatingOperation(method, routeName)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if mutating && cfg.MaintenanceMode() {
+			respondErr(w, APIErrorMaintenance())
+			return
+		}
+
+		hfn.ServeHTTP(w, r)
+	})
+}
+
+// acceptedContentTypes are the media types that request bodies can be declared with
+var acceptedContentTypes = map[string]bool{
+	"application/json": true,
+}
+
+// WrapContentType handle wrapper that rejects request bodies declared with a content type other than json,
+// before they get read. Requests that don't declare a content type are let through, since existing clients rely on it
+func WrapContentType(hfn http.Handler, method string) http.HandlerFunc {
+
+	checked := method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if checked {
+			if contentType := r.Header.Get("Content-Type"); contentType != "" {
+				mediaType, _, err := mime.ParseMediaType(contentType)
+				if err != nil || !acceptedContentTypes[mediaType] {
+					respondErr(w, APIErrorUnsupportedMediaType(contentType))
+					return
+				}
+			}
+		}
+
+		hfn.ServeHTTP(w, r)
+	})
+}
+
+// timeoutWriter buffers a handler's response so that it can be discarded if the request deadline passes first
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// WrapTimeout bounds the time the service takes to respond to a request. If the handler hasn't finished
+// by the configured timeout, the request gets a deadline exceeded error and whatever the handler writes afterwards
+// is discarded. The handler itself keeps running, only the operations that take the request's context, i.e. the
+// broker consumes and the push endpoint verifications, get cancelled, while the store operations and publishes
+// still complete, so the response doesn't tell whether the request took effect.
+// Event streams are long lived by design, so they are left without a timeout.
+func WrapTimeout(hfn http.Handler, cfg *config.APICfg, routeName string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if cfg.RequestTimeout <= 0 || routeName == "subscriptions:stream" {
+			hfn.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(cfg.RequestTimeout)*time.Second)
+		defer cancel()
+
+		// gorilla context values, including the mux url vars, are keyed by the request itself
+		ctxR := r.WithContext(ctx)
+		for k, v := range gorillaContext.GetAll(r) {
+			gorillaContext.Set(ctxR, k, v)
+		}
+
+		tw := &timeoutWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+
+		go func() {
+			defer func() {
+				gorillaContext.Clear(ctxR)
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			hfn.ServeHTTP(tw, ctxR)
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			for k, v := range tw.header {
+				w.Header()[k] = v
+			}
+			if tw.code == 0 {
+				tw.code = http.StatusOK
+			}
+			w.WriteHeader(tw.code)
+			w.Write(tw.buf.Bytes())
+		case <-ctx.Done():
+			tw.mu.Lock()
+			tw.timedOut = true
+			tw.mu.Unlock()
+			log.WithFields(
+				log.Fields{
+					"type":    "request_log",
+					"handler": routeName,
+					"timeout": cfg.RequestTimeout,
+				},
+			).Warning("Request deadline exceeded")
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			respondErr(w, APIErrorDeadlineExceeded())
+		}
+	})
+}
+
+// MaintenanceToggle (POST) enables or disables the maintenance mode of the node serving the request.
+// The mode isn't shared, every node of a deployment has to be toggled on its own
+func MaintenanceToggle(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	if !auth.IsServiceAdmin(refRoles) {
+		err := APIErrorForbidden()
+		respondErr(w, err)
+		return
+	}
+
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	m := MaintenanceStatus{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	cfg.SetMaintenanceMode(m.Enabled)
+
+	log.WithFields(
+		log.Fields{
+			"type":      "service_log",
+			"requester": gorillaContext.Get(r, "auth_user_uuid"),
+		},
+	).Infof("Maintenance mode set to %v", m.Enabled)
+
+	output, err := json.MarshalIndent(MaintenanceStatus{Enabled: cfg.MaintenanceMode()}, "", " ")
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
 // HealthCheck returns an ok message to make sure the service is up and running
 func HealthCheck(w http.ResponseWriter, r *http.Request) {
 
@@ -245,6 +508,14 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 		detailedStatus = true
 	}
 
+	mgr, ok := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+	if !ok || mgr == nil {
+		mgr = &oldPush.Manager{}
+	}
+
+	workers := []oldPush.WorkerStatus{}
+	reportWorkers := false
+
 	if pushEnabled {
 		_, err := auth.GetPushWorker(pwToken, refStr)
 		if err != nil {
@@ -258,10 +529,26 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 			},
 		}
 
+		workers = append(workers, pushServerWorkers(apsc, mgr, refStr)...)
+		reportWorkers = true
+
 	} else {
 		healthMsg.PushFunctionality = "disabled"
 	}
 
+	// the pushers run in process only when the push manager has been set up
+	if mgr.IsActive() {
+		workers = append(workers, mgr.Workers(time.Now())...)
+		reportWorkers = true
+	}
+
+	if reportWorkers {
+		healthMsg.PushWorkers = pushWorkersHealth(workers, detailedStatus, refStr)
+		if healthMsg.PushWorkers.Running != healthMsg.PushWorkers.Expected {
+			healthMsg.Status = "degraded"
+		}
+	}
+
 	if bytes, err = json.MarshalIndent(healthMsg, "", " "); err != nil {
 		err := APIErrGenericInternal(err.Error())
 		respondErr(w, err)
@@ -279,12 +566,20 @@ func ListVersion(w http.ResponseWriter, r *http.Request) {
 	charset := "utf-8"
 	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
 
+	// Grab context references
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
 	v := version.Model{
+		Release:   version.Release,
+		Commit:    version.Commit,
 		BuildTime: version.BuildTime,
 		GO:        version.GO,
 		Compiler:  version.Compiler,
 		OS:        version.OS,
 		Arch:      version.Arch,
+		Broker:    version.Backend{Type: refBrk.Type(), Version: refBrk.Version()},
+		Store:     version.Backend{Type: refStr.Type()},
 	}
 
 	output, err := json.MarshalIndent(v, "", " ")
@@ -297,15 +592,180 @@ func ListVersion(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
+// ignoreNotFound returns true when a delete request asked to succeed even if the resource is already gone
+func ignoreNotFound(r *http.Request) bool {
+	return r.URL.Query().Get("ignoreNotFound") == "true"
+}
+
+// emitEvent emits a lifecycle event for one of the project's resources, e.g. topics/topic1, on behalf of the requester.
+// The requester's name is only looked up when the events are enabled
+func emitEvent(r *http.Request, eventType string, resource string) {
+
+	if !events.Enabled() {
+		return
+	}
+
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	userUUID, _ := gorillaContext.Get(r, "auth_user_uuid").(string)
+
+	events.Emit(eventType, "/projects/"+mux.Vars(r)["project"]+"/"+resource, auth.GetNameByUUID(userUUID, refStr))
+}
+
+// listPageSize resolves the page size of a list request, falling back to the configured default page size.
+// Once a max page size is configured, pages are never unbounded and larger page sizes get clamped to it,
+// or rejected if the service is configured to do so
+func listPageSize(r *http.Request) (int, error) {
+
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	requested := 0
+	if strPageSize := r.URL.Query().Get("pageSize"); strPageSize != "" {
+		var err error
+		if requested, err = strconv.Atoi(strPageSize); err != nil || requested < 0 {
+			return 0, errors.New("Invalid page size")
+		}
+	}
+
+	size := requested
+	if size == 0 {
+		size = cfg.DefaultPageSize
+	}
+
+	if cfg.MaxPageSize <= 0 {
+		return size, nil
+	}
+
+	if requested > cfg.MaxPageSize && cfg.RejectOversizedPages {
+		return 0, fmt.Errorf("Invalid page size, it should not exceed %v", cfg.MaxPageSize)
+	}
+
+	if size == 0 || size > cfg.MaxPageSize {
+		return cfg.MaxPageSize, nil
+	}
+
+	return size, nil
+}
+
+// jsonExporter is implemented by the resources the handlers respond with
+type jsonExporter interface {
+	ExportJSON() (string, error)
+}
+
+// exportJSON exports the resource, naming its fields after the configured json naming convention
+func exportJSON(r *http.Request, res jsonExporter) (string, error) {
+	output, err := res.ExportJSON()
+	if err != nil {
+		return output, err
+	}
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	named, err := naming.Export([]byte(output), res, cfg.JSONNaming)
+	return string(named), err
+}
+
+// importJSON renames the fields of a request body named after the configured json naming convention
+// to the ones declared by v. Bodies that aren't valid json are returned as they are, for their parsers to reject them
+func importJSON(r *http.Request, body []byte, v interface{}) []byte {
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	named, err := naming.Import(body, v, cfg.JSONNaming)
+	if err != nil {
+		return body
+	}
+	return named
+}
+
 // respondOK is used to finalize response writer with proper code and output
 func respondOK(w http.ResponseWriter, output []byte) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(output)
 }
 
+// etagOf derives a strong entity tag from the content of a response, so that it changes whenever any of the returned fields does
+func etagOf(output []byte) string {
+	sum := sha256.Sum256(output)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// matchesETag returns true if the If-None-Match header lists the entity tag or is *.
+// If-None-Match compares the tags weakly, so a weak tag of the same value matches as well
+func matchesETag(ifNoneMatch string, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// aclETag is the entity tag of an acl at the given version
+func aclETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// aclIfMatch returns the acl version that the If-Match header of an acl modification expects,
+// or -1 if the modification is unconditional because the header is missing or *
+func aclIfMatch(r *http.Request) (int64, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return -1, nil
+	}
+	version, err := strconv.ParseInt(strings.Trim(ifMatch, `"`), 10, 64)
+	if err != nil || version < 0 || ifMatch != aclETag(version) {
+		return -1, errors.New("Invalid If-Match header, it should hold the etag of the acl")
+	}
+	return version, nil
+}
+
+// respondCacheable finalizes the response writer with the output and its ETag, or with a 304 and no body
+// if the client's If-None-Match shows it already holds the same output
+func respondCacheable(w http.ResponseWriter, r *http.Request, output []byte) {
+	etag := etagOf(output)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchesETag(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondOK(w, output)
+}
+
+// defaultRetryAfter is the backoff suggested to rate limited clients when there is no better estimate
+const defaultRetryAfter = time.Second
+
+// respondRateLimited finalizes the response writer with a 429 api error, suggesting the backoff
+// through both the Retry-After header and the error body, rounded up to whole seconds
+func respondRateLimited(w http.ResponseWriter, apiErr APIErrorRoot, retryAfter time.Duration) {
+
+	seconds := int((retryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	apiErr.Body.Code = http.StatusTooManyRequests
+	apiErr.Body.RetryAfter = seconds
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondErr(w, apiErr)
+}
+
 // respondErr is used to finalize response writer with proper error codes and error output
+// Client errors are logged at info level, so that only the failures of the service itself show up as errors
 func respondErr(w http.ResponseWriter, apiErr APIErrorRoot) {
-	log.Error(apiErr.Body.Code, "\t", apiErr.Body.Message)
+	// rate limited clients are always told when to retry
+	if apiErr.Body.Code == http.StatusTooManyRequests && apiErr.Body.RetryAfter == 0 {
+		respondRateLimited(w, apiErr, defaultRetryAfter)
+		return
+	}
+	entry := log.WithFields(
+		log.Fields{
+			"type":   "request_log",
+			"code":   apiErr.Body.Code,
+			"status": apiErr.Body.Status,
+		},
+	)
+	if apiErr.Body.Code >= http.StatusInternalServerError {
+		entry.Error(apiErr.Body.Message)
+	} else {
+		entry.Info(apiErr.Body.Message)
+	}
 	// set the response code
 	w.WriteHeader(apiErr.Body.Code)
 	// Output API Erorr object to JSON
@@ -356,9 +816,90 @@ func GetRequestTokenExtractStrategy(authOpt config.AuthOption) RequestTokenExtra
 }
 
 type HealthStatus struct {
-	Status            string           `json:"status,omitempty"`
-	PushServers       []PushServerInfo `json:"push_servers,omitempty"`
-	PushFunctionality string           `json:"push_functionality,omitempty"`
+	Status            string             `json:"status,omitempty"`
+	PushServers       []PushServerInfo   `json:"push_servers,omitempty"`
+	PushFunctionality string             `json:"push_functionality,omitempty"`
+	PushWorkers       *PushWorkersHealth `json:"push_workers,omitempty"`
+}
+
+// PushWorkersHealth holds how many of the push subscriptions have a running pusher,
+// along with the status of each one of them when a detailed status is requested
+type PushWorkersHealth struct {
+	Expected      int                `json:"expected"`
+	Running       int                `json:"running"`
+	Subscriptions []PushWorkerStatus `json:"subscriptions,omitempty"`
+}
+
+// PushWorkerStatus is the status of the pusher of a push subscription
+type PushWorkerStatus struct {
+	Subscription string `json:"subscription"`
+	Status       string `json:"status"`
+	LastActive   string `json:"last_active,omitempty"`
+}
+
+// pushServerWorkers reports the liveness of the workers of the ams push server for the verified push subscriptions
+// that the push manager leaves to it. Subscriptions the push server holds no worker for are reported as missing
+func pushServerWorkers(apsc push.Client, mgr *oldPush.Manager, store stores.Store) []oldPush.WorkerStatus {
+
+	live := map[string]push.WorkerStatus{}
+
+	workers, err := apsc.Workers(context.TODO())
+	if err != nil {
+		log.Errorf("Couldn't retrieve the workers of the push server, %v", err.Error())
+	}
+
+	for _, ws := range workers {
+		live[ws.FullName] = ws
+	}
+
+	results := []oldPush.WorkerStatus{}
+
+	for _, qSub := range store.QueryPushSubs() {
+		if !qSub.Verified || mgr.Serves(subscriptions.PushConfig{Pend: qSub.PushEndpoint, Fanout: qSub.FanoutEndpoints}) {
+			continue
+		}
+
+		ws := oldPush.WorkerStatus{ProjectUUID: qSub.ProjectUUID, Subscription: qSub.Name, Status: oldPush.WorkerMissing}
+		fullName := fmt.Sprintf("/projects/%v/subscriptions/%v", projects.GetNameByUUID(qSub.ProjectUUID, store), qSub.Name)
+		if pws, ok := live[fullName]; ok {
+			ws.Status = pws.Status
+			ws.LastActive = pws.LastActive
+		}
+		results = append(results, ws)
+	}
+
+	return results
+}
+
+// pushWorkersHealth summarizes the liveness of the pushers, listing each push subscription only in detailed reports
+func pushWorkersHealth(workers []oldPush.WorkerStatus, detailed bool, store stores.Store) *PushWorkersHealth {
+
+	res := &PushWorkersHealth{Expected: len(workers)}
+
+	for _, ws := range workers {
+		if ws.Status == oldPush.WorkerRunning {
+			res.Running++
+		}
+
+		if !detailed {
+			continue
+		}
+
+		pws := PushWorkerStatus{
+			Subscription: fmt.Sprintf("/projects/%v/subscriptions/%v", projects.GetNameByUUID(ws.ProjectUUID, store), ws.Subscription),
+			Status:       ws.Status,
+		}
+		if !ws.LastActive.IsZero() {
+			pws.LastActive = timestamp.Format(ws.LastActive)
+		}
+		res.Subscriptions = append(res.Subscriptions, pws)
+	}
+
+	return res
+}
+
+type MaintenanceStatus struct {
+	Enabled bool `json:"maintenance_mode"`
 }
 
 type PushServerInfo struct {
@@ -4,17 +4,21 @@ import (
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/version"
 	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/suite"
 )
@@ -54,7 +58,11 @@ func (suite *HandlerTestSuite) TestHealthCheck() {
    "endpoint": "localhost:5555",
    "status": "SERVING"
   }
- ]
+ ],
+ "push_workers": {
+  "expected": 1,
+  "running": 1
+ }
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -88,7 +96,18 @@ func (suite *HandlerTestSuite) TestHealthCheckDetails() {
    "endpoint": "localhost:5555",
    "status": "SERVING"
   }
- ]
+ ],
+ "push_workers": {
+  "expected": 1,
+  "running": 1,
+  "subscriptions": [
+   {
+    "subscription": "/projects/ARGO/subscriptions/sub4",
+    "status": "running",
+    "last_active": "2020-11-19T10:15:05Z"
+   }
+  ]
+ }
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -142,6 +161,116 @@ func (suite *HandlerTestSuite) TestHealthCheckPushDisabled() {
 	suite.Equal(expResp, w.Body.String())
 }
 
+func (suite *HandlerTestSuite) TestHealthCheckPushWorkers() {
+
+	expResp := `{
+ "status": "degraded",
+ "push_functionality": "disabled",
+ "push_workers": {
+  "expected": 1,
+  "running": 0
+ }
+}`
+
+	expRespDetails := `{
+ "status": "degraded",
+ "push_functionality": "disabled",
+ "push_workers": {
+  "expected": 1,
+  "running": 0,
+  "subscriptions": [
+   {
+    "subscription": "/projects/ARGO/subscriptions/sub4",
+    "status": "missing"
+   }
+  ]
+ }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PushEnabled = false
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UserList = append(str.UserList, stores.QUser{
+		UUID:         "admin-viewer-id",
+		Name:         "admin-viewer",
+		Token:        "admin-viewer-token",
+		ServiceRoles: []string{"admin_viewer"},
+	})
+	router := mux.NewRouter().StrictSlash(true)
+	// the push subscription sub4 has no pusher running
+	mgr := oldPush.NewManager(&brk, str, nil)
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/status", WrapMockAuthConfig(HealthCheck, cfgKafka, &brk, str, mgr, pc))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/status", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/status?details=true&key=admin-viewer-token", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expRespDetails, w.Body.String())
+}
+
+func (suite *HandlerTestSuite) TestHealthCheckPushServerWorkers() {
+
+	expResp := `{
+ "status": "degraded",
+ "push_servers": [
+  {
+   "endpoint": "localhost:5555",
+   "status": "SERVING"
+  }
+ ],
+ "push_workers": {
+  "expected": 2,
+  "running": 1,
+  "subscriptions": [
+   {
+    "subscription": "/projects/ARGO/subscriptions/sub1",
+    "status": "missing"
+   },
+   {
+    "subscription": "/projects/ARGO/subscriptions/sub4",
+    "status": "running",
+    "last_active": "2020-11-19T10:15:05Z"
+   }
+  ]
+ }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PushEnabled = true
+	cfgKafka.PushWorkerToken = "push_token"
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UserList = append(str.UserList, stores.QUser{
+		UUID:         "admin-viewer-id",
+		Name:         "admin-viewer",
+		Token:        "admin-viewer-token",
+		ServiceRoles: []string{"admin_viewer"},
+	})
+	// sub1 is a verified push subscription that the push server has no worker for
+	str.SubList[0].PushEndpoint = "https://www.example.com"
+	str.SubList[0].Verified = true
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/status", WrapMockAuthConfig(HealthCheck, cfgKafka, &brk, str, &mgr, pc))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/status?details=true&key=admin-viewer-token", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
 func (suite *HandlerTestSuite) TestHealthCheckPushWorkerMissing() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/status", nil)
@@ -156,7 +285,11 @@ func (suite *HandlerTestSuite) TestHealthCheckPushWorkerMissing() {
    "endpoint": "localhost:5555",
    "status": "SERVING"
   }
- ]
+ ],
+ "push_workers": {
+  "expected": 1,
+  "running": 1
+ }
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -222,13 +355,22 @@ func (suite *HandlerTestSuite) TestListVersion() {
 	}
 
 	expResp := `{
+ "release": "%v",
+ "commit": "%v",
  "build_time": "%v",
  "golang": "%v",
  "compiler": "%v",
  "os": "%v",
- "architecture": "%v"
+ "architecture": "%v",
+ "broker": {
+  "type": "mock",
+  "version": "mock"
+ },
+ "store": {
+  "type": "mock"
+ }
 }`
-	expResp = fmt.Sprintf(expResp, version.BuildTime, version.GO, version.Compiler, version.OS, version.Arch)
+	expResp = fmt.Sprintf(expResp, version.Release, version.Commit, version.BuildTime, version.GO, version.Compiler, version.OS, version.Arch)
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
@@ -247,6 +389,338 @@ func (suite *HandlerTestSuite) TestListVersion() {
 	suite.Equal(expResp, w.Body.String())
 }
 
+func (suite *HandlerTestSuite) TestRespondErrLogLevel() {
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	respondErr(httptest.NewRecorder(), APIErrorNotFound("Topic"))
+	suite.Equal(log.InfoLevel, hook.LastEntry().Level)
+	suite.Equal("Topic doesn't exist", hook.LastEntry().Message)
+	suite.Equal(404, hook.LastEntry().Data["code"])
+
+	respondErr(httptest.NewRecorder(), APIErrGenericBackend())
+	suite.Equal(log.ErrorLevel, hook.LastEntry().Level)
+	suite.Equal("INTERNAL_SERVER_ERROR", hook.LastEntry().Data["status"])
+}
+
+func (suite *HandlerTestSuite) TestRespondRateLimited() {
+
+	// the backoff is rounded up to whole seconds
+	w := httptest.NewRecorder()
+	respondRateLimited(w, APIErrorTooManyPulls(), 2500*time.Millisecond)
+	suite.Equal(429, w.Code)
+	suite.Equal("3", w.Header().Get("Retry-After"))
+	suite.Contains(w.Body.String(), `"retryAfter": 3`)
+
+	w = httptest.NewRecorder()
+	respondRateLimited(w, APIErrorTooManyPulls(), 0)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+
+	// 429 errors that are not given a backoff get the default one
+	w = httptest.NewRecorder()
+	respondErr(w, APIErrorTooManyPulls())
+	suite.Equal(429, w.Code)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+	suite.Contains(w.Body.String(), `"retryAfter": 1`)
+
+	// other errors carry no backoff
+	w = httptest.NewRecorder()
+	respondErr(w, APIErrorNotFound("Topic"))
+	suite.Equal("", w.Header().Get("Retry-After"))
+	suite.NotContains(w.Body.String(), "retryAfter")
+}
+
+func (suite *HandlerTestSuite) TestIsMutatingOperation() {
+
+	suite.False(IsMutatingOperation("GET", "topics:list"))
+	suite.False(IsMutatingOperation("GET", "subscriptions:show"))
+	suite.False(IsMutatingOperation("POST", "subscriptions:pull"))
+	suite.False(IsMutatingOperation("POST", "schemas:validateMessage"))
+	suite.False(IsMutatingOperation("POST", "ams:maintenance"))
+	suite.True(IsMutatingOperation("PUT", "topics:create"))
+	suite.True(IsMutatingOperation("DELETE", "subscriptions:delete"))
+	suite.True(IsMutatingOperation("POST", "topics:publish"))
+	suite.True(IsMutatingOperation("POST", "subscriptions:acknowledge"))
+	suite.True(IsMutatingOperation("POST", "subscriptions:modifyAcl"))
+}
+
+func (suite *HandlerTestSuite) TestWrapMaintenance() {
+
+	expResp := `{
+   "error": {
+      "code": 503,
+      "message": "Service is under maintenance, write operations are temporarily disabled",
+      "status": "UNAVAILABLE"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.SetMaintenanceMode(true)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		respondOK(w, []byte("ok"))
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMaintenance(http.HandlerFunc(okHandler), cfgKafka, "PUT", "topics:create")).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMaintenance(http.HandlerFunc(okHandler), cfgKafka, "GET", "topics:show")).Methods("GET")
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMaintenance(http.HandlerFunc(okHandler), cfgKafka, "POST", "subscriptions:pull")).Methods("POST")
+
+	// mutating operation is rejected
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(503, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// reads continue to work
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+
+	req3, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", nil)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(200, w3.Code)
+
+	// disable maintenance and retry the mutating operation
+	cfgKafka.SetMaintenanceMode(false)
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, req)
+	suite.Equal(200, w4.Code)
+}
+
+func (suite *HandlerTestSuite) TestWrapContentType() {
+
+	expResp := `{
+   "error": {
+      "code": 415,
+      "message": "Unsupported content type text/plain, request bodies should be application/json",
+      "status": "UNSUPPORTED_MEDIA_TYPE"
+   }
+}`
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		respondOK(w, []byte("ok"))
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapContentType(http.HandlerFunc(okHandler), "PUT")).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapContentType(http.HandlerFunc(okHandler), "DELETE")).Methods("DELETE")
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "text/plain")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(415, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	for _, contentType := range []string{"", "application/json", "application/json; charset=utf-8", "Application/JSON"} {
+		req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", strings.NewReader("{}"))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, contentType)
+	}
+
+	// malformed declarations are rejected as well
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json;;")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(415, w.Code)
+
+	// requests without a body aren't checked
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func (suite *HandlerTestSuite) TestWrapAuthorizeSelfService() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		respondOK(w, []byte("ok"))
+	}
+	authorized := func(w http.ResponseWriter, r *http.Request) {
+		gorillaContext.Set(r, "auth_service_token", "")
+		WrapAuthorize(http.HandlerFunc(okHandler), mux.CurrentRoute(r).GetName(), GetRequestTokenExtractStrategy(config.URLKeyAndHeaderKey)).ServeHTTP(w, r)
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/users/{user}:refreshToken", WrapMockAuthConfig(authorized, cfgKafka, &brk, str, &mgr, nil, "consumer")).Name("users:refreshToken")
+	router.HandleFunc("/v1/users/{user}", WrapMockAuthConfig(authorized, cfgKafka, &brk, str, &mgr, nil, "consumer")).Name("users:show")
+
+	// the authenticated user, UserA, can refresh its own token without being granted the route
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/users/UserA:refreshToken", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// but not the token of another user
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/UserB:refreshToken", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(403, w.Code)
+
+	// other routes aren't self served
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/users/UserA", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(403, w.Code)
+}
+
+func (suite *HandlerTestSuite) TestWrapTimeout() {
+
+	expResp := `{
+   "error": {
+      "code": 504,
+      "message": "Request deadline exceeded",
+      "status": "DEADLINE_EXCEEDED"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.RequestTimeout = 1
+
+	// blocks until the request's context gets cancelled
+	stuckHandler := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		respondOK(w, []byte("too late"))
+	}
+
+	// the url vars should still be available to the handler
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Topic", mux.Vars(r)["topic"])
+		respondOK(w, []byte("ok"))
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapTimeout(http.HandlerFunc(okHandler), cfgKafka, "topics:show"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapTimeout(http.HandlerFunc(stuckHandler), cfgKafka, "subscriptions:pull"))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("ok", w.Body.String())
+	suite.Equal("topic1", w.Header().Get("X-Topic"))
+
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(504, w2.Code)
+	suite.Equal(expResp, w2.Body.String())
+}
+
+func (suite *HandlerTestSuite) TestMaintenanceToggle() {
+
+	postJSON := `{"maintenance_mode": true}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/maintenance", strings.NewReader(postJSON))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+ "maintenance_mode": true
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/maintenance", WrapMockAuthConfig(MaintenanceToggle, cfgKafka, &brk, str, &mgr, pc, "service_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	suite.True(cfgKafka.MaintenanceMode())
+
+	// invalid request body
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/maintenance", strings.NewReader("{"))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(400, w2.Code)
+	suite.True(cfgKafka.MaintenanceMode())
+
+	// only service admins can toggle the maintenance mode
+	router2 := mux.NewRouter().StrictSlash(true)
+	router2.HandleFunc("/v1/maintenance", WrapMockAuthConfig(MaintenanceToggle, cfgKafka, &brk, str, &mgr, pc, "project_admin"))
+	req3, _ := http.NewRequest("POST", "http://localhost:8080/v1/maintenance", strings.NewReader(`{"maintenance_mode": false}`))
+	w3 := httptest.NewRecorder()
+	router2.ServeHTTP(w3, req3)
+	suite.Equal(403, w3.Code)
+	suite.True(cfgKafka.MaintenanceMode())
+}
+
+func (suite *HandlerTestSuite) TestListPageSize() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	type td struct {
+		defaultSize int
+		maxSize     int
+		reject      bool
+		query       string
+		expected    int
+		err         string
+	}
+
+	testData := []td{
+		// without any configuration the requested page size applies, and no page size means no pagination
+		{query: "", expected: 0},
+		{query: "?pageSize=500", expected: 500},
+		{defaultSize: 20, query: "", expected: 20},
+		{defaultSize: 20, query: "?pageSize=0", expected: 20},
+		{defaultSize: 20, query: "?pageSize=5", expected: 5},
+		// once a max is set, pages are never unbounded
+		{maxSize: 100, query: "", expected: 100},
+		{maxSize: 100, query: "?pageSize=50", expected: 50},
+		{maxSize: 100, query: "?pageSize=500", expected: 100},
+		{defaultSize: 200, maxSize: 100, query: "", expected: 100},
+		{maxSize: 100, reject: true, query: "?pageSize=500", err: "Invalid page size, it should not exceed 100"},
+		{defaultSize: 200, maxSize: 100, reject: true, query: "", expected: 100},
+		{maxSize: 100, reject: true, query: "?pageSize=100", expected: 100},
+		{query: "?pageSize=abc", err: "Invalid page size"},
+		{query: "?pageSize=-1", err: "Invalid page size"},
+	}
+
+	for _, t := range testData {
+		cfgKafka.DefaultPageSize = t.defaultSize
+		cfgKafka.MaxPageSize = t.maxSize
+		cfgKafka.RejectOversizedPages = t.reject
+
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics"+t.query, nil)
+		gorillaContext.Set(req, "cfg", cfgKafka)
+
+		size, err := listPageSize(req)
+		if t.err != "" {
+			suite.Equal(t.err, err.Error(), t.query)
+			continue
+		}
+		suite.Nil(err, t.query)
+		suite.Equal(t.expected, size, t.query)
+	}
+}
+
 func TestHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(HandlerTestSuite))
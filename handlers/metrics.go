@@ -4,9 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
 	"github.com/ARGOeu/argo-messaging/metrics"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	"github.com/ARGOeu/argo-messaging/topics"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
@@ -38,6 +41,11 @@ func OpMetrics(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// nodes that pool their broker connections also report the pool's utilization
+	if pool, ok := gorillaContext.Get(r, "brk").(*brokers.Pool); ok {
+		res.Metrics = append(res.Metrics, metrics.GetBrokerPoolUsage(pool.Stats()).Metrics...)
+	}
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 
@@ -240,6 +248,7 @@ func TopicMetrics(w http.ResponseWriter, r *http.Request) {
 	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
 	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
 	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 
 	urlTopic := urlVars["topic"]
 
@@ -301,10 +310,27 @@ func TopicMetrics(w http.ResponseWriter, r *http.Request) {
 	m2 := metrics.NewTopicMsgs(urlTopic, numMsg, metrics.GetTimeNowZulu())
 	m3 := metrics.NewTopicBytes(urlTopic, numBytes, metrics.GetTimeNowZulu())
 	m4 := metrics.NewDailyTopicMsgCount(urlTopic, timePoints)
-	m5 := metrics.NewTopicRate(urlTopic, resultsMsg.PublishRate, resultsMsg.LatestPublish.Format("2006-01-02T15:04:05Z"))
+	m5 := metrics.NewTopicRate(urlTopic, resultsMsg.PublishRate, timestamp.Format(resultsMsg.LatestPublish))
 
 	res.Metrics = append(res.Metrics, m2, m3, m4, m5)
 
+	// throughput of the topic over each of the configured windows
+	now := time.Now().UTC()
+	windows := topics.ParseThroughputWindows(cfg.TopicThroughputWindows)
+	throughput, err := topics.ComputeThroughput(projectUUID, urlTopic, resultsMsg, now, windows, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	tstamp := timestamp.Format(now)
+	for _, t := range throughput {
+		res.Metrics = append(res.Metrics,
+			metrics.NewTopicMsgsPerSec(urlTopic, t.Window.Label, t.MessagesPerSecond, tstamp),
+			metrics.NewTopicBytesPerSec(urlTopic, t.Window.Label, t.BytesPerSecond, tstamp))
+	}
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 	if err != nil {
@@ -373,9 +399,10 @@ func SubMetrics(w http.ResponseWriter, r *http.Request) {
 	m1 := metrics.NewSubMsgs(urlSub, numMsg, metrics.GetTimeNowZulu())
 	res := metrics.NewMetricList(m1)
 	m2 := metrics.NewSubBytes(urlSub, numBytes, metrics.GetTimeNowZulu())
-	m3 := metrics.NewSubRate(urlSub, resultMsg.ConsumeRate, resultMsg.LatestConsume.Format("2006-01-02T15:04:05Z"))
+	m3 := metrics.NewSubRate(urlSub, resultMsg.ConsumeRate, timestamp.Format(resultMsg.LatestConsume))
+	m4 := metrics.NewSubAckedMsgs(urlSub, resultMsg.AckedMsgs, metrics.GetTimeNowZulu())
 
-	res.Metrics = append(res.Metrics, m2, m3)
+	res.Metrics = append(res.Metrics, m2, m3, m4)
 
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
 	"github.com/ARGOeu/argo-messaging/metrics"
@@ -241,6 +242,20 @@ func (suite *MetricsHandlersTestSuite) TestSubMetrics() {
             }
          ],
          "description": "A rate that displays how many messages were consumed per second between the last two consume events"
+      },
+      {
+         "metric": "subscription.number_of_acked_messages",
+         "metric_type": "counter",
+         "value_type": "int64",
+         "resource_type": "subscription",
+         "resource_name": "sub1",
+         "timeseries": [
+            {
+               "timestamp": "{{TS3}}",
+               "value": 0
+            }
+         ],
+         "description": "Counter that displays the number of messages acknowledged through the specific subscription since its last reset"
       }
    ]
 }`
@@ -259,12 +274,56 @@ func (suite *MetricsHandlersTestSuite) TestSubMetrics() {
 	metricOut, _ := metrics.GetMetricsFromJSON([]byte(w.Body.String()))
 	ts1 := metricOut.Metrics[0].Timeseries[0].Timestamp
 	ts2 := metricOut.Metrics[1].Timeseries[0].Timestamp
+	ts3 := metricOut.Metrics[3].Timeseries[0].Timestamp
 	expResp = strings.Replace(expResp, "{{TS1}}", ts1, -1)
 	expResp = strings.Replace(expResp, "{{TS2}}", ts2, -1)
+	expResp = strings.Replace(expResp, "{{TS3}}", ts3, -1)
 	suite.Equal(expResp, w.Body.String())
 
 }
 
+func (suite *MetricsHandlersTestSuite) TestSubMetricsAckedMessages() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:metrics", WrapMockAuthConfig(SubMetrics, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:resetAckedMessages", WrapMockAuthConfig(SubResetAckedMessages, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// acknowledge the first three messages of the subscription
+	sub := str.SubList[0]
+	str.UpdateSubPull(sub.ProjectUUID, sub.Name, 3, "2019-05-06T00:00:00Z", sub.Version)
+	suite.Nil(str.UpdateSubOffsetAck(sub.ProjectUUID, sub.Name, 3, "2019-05-06T00:00:01Z", sub.Version+1))
+
+	ackedMsgs := func() float64 {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:metrics", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		metricOut, _ := metrics.GetMetricsFromJSON([]byte(w.Body.String()))
+		suite.Equal("subscription.number_of_acked_messages", metricOut.Metrics[3].Metric)
+		return metricOut.Metrics[3].Timeseries[0].Value.(float64)
+	}
+
+	suite.Equal(float64(3), ackedMsgs())
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:resetAckedMessages", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+
+	suite.Equal(float64(0), ackedMsgs())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:resetAckedMessages", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
 func (suite *MetricsHandlersTestSuite) TestSubMetricsNotFound() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown_sub:metrics", nil)
@@ -570,6 +629,36 @@ func (suite *MetricsHandlersTestSuite) TestOpMetrics() {
 
 }
 
+func (suite *MetricsHandlersTestSuite) TestOpMetricsBrokerPool() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/metrics", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	pool := brokers.NewPool(2, func() brokers.Broker { return &brokers.MockBroker{} })
+	acquired, _ := pool.Acquire(context.Background())
+	defer pool.Release(acquired)
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/metrics", WrapMockAuthConfig(OpMetrics, cfgKafka, pool, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	metricOut, _ := metrics.GetMetricsFromJSON([]byte(w.Body.String()))
+	suite.Equal(5, len(metricOut.Metrics))
+	suite.Equal(metrics.NameOpPoolActive, metricOut.Metrics[2].Metric)
+	suite.Equal(float64(1), metricOut.Metrics[2].Timeseries[0].Value)
+	suite.Equal(metrics.NameOpPoolIdle, metricOut.Metrics[3].Metric)
+	suite.Equal(float64(0), metricOut.Metrics[3].Timeseries[0].Value)
+	suite.Equal(metrics.NameOpPoolWaitTime, metricOut.Metrics[4].Metric)
+	suite.Equal(float64(0), metricOut.Metrics[4].Timeseries[0].Value)
+}
+
 func (suite *MetricsHandlersTestSuite) TestTopicMetrics() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:metrics", nil)
@@ -652,12 +741,42 @@ func (suite *MetricsHandlersTestSuite) TestTopicMetrics() {
             }
          ],
          "description": "A rate that displays how many messages were published per second between the last two publish events"
+      },
+      {
+         "metric": "topic.messages_per_second.5m",
+         "metric_type": "rate",
+         "value_type": "float64",
+         "resource_type": "topic",
+         "resource_name": "topic1",
+         "timeseries": [
+            {
+               "timestamp": "{{TIMESTAMP6}}",
+               "value": null
+            }
+         ],
+         "description": "A rate that displays how many messages were published per second to the specific topic during the window"
+      },
+      {
+         "metric": "topic.bytes_per_second.5m",
+         "metric_type": "rate",
+         "value_type": "float64",
+         "resource_type": "topic",
+         "resource_name": "topic1",
+         "timeseries": [
+            {
+               "timestamp": "{{TIMESTAMP6}}",
+               "value": null
+            }
+         ],
+         "description": "A rate that displays how many bytes were published per second to the specific topic during the window"
       }
    ]
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	// no samples have been taken, so there are no data to compute the throughput from
+	cfgKafka.TopicThroughputWindows = []string{"5m"}
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
@@ -672,11 +791,13 @@ func (suite *MetricsHandlersTestSuite) TestTopicMetrics() {
 	ts3 := metricOut.Metrics[2].Timeseries[0].Timestamp
 	ts4 := metricOut.Metrics[3].Timeseries[0].Timestamp
 	ts5 := metricOut.Metrics[3].Timeseries[1].Timestamp
+	ts6 := metricOut.Metrics[5].Timeseries[0].Timestamp
 	expResp = strings.Replace(expResp, "{{TIMESTAMP1}}", ts1, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP2}}", ts2, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP3}}", ts3, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP4}}", ts4, -1)
 	expResp = strings.Replace(expResp, "{{TIMESTAMP5}}", ts5, -1)
+	expResp = strings.Replace(expResp, "{{TIMESTAMP6}}", ts6, -1)
 
 	suite.Equal(expResp, w.Body.String())
 
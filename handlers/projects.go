@@ -4,15 +4,18 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/manifests"
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/topics"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"github.com/twinj/uuid"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -34,10 +37,21 @@ func ProjectDelete(w http.ResponseWriter, r *http.Request) {
 	// Get Result Object
 	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// the project is already gone, which is fine when the deletion should be idempotent
+	if projectUUID == "" && ignoreNotFound(r) {
+		respondOK(w, output)
+		return
+	}
+
 	// RemoveProject removes also attached subs and topics from the datastore
 	err := projects.RemoveProject(projectUUID, refStr)
 	if err != nil {
 		if err.Error() == "not found" {
+			if ignoreNotFound(r) {
+				respondOK(w, output)
+				return
+			}
 			err := APIErrorNotFound("ProjectUUID")
 			respondErr(w, err)
 			return
@@ -82,6 +96,22 @@ func ProjectUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the users of a default acl should exist before the project gets modified
+	if postBody.DefaultACL != nil {
+		if err := postBody.DefaultACL.Validate(refStr); err != nil {
+			err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+			respondErr(w, err)
+			return
+		}
+	}
+
+	if postBody.PatternACL != nil {
+		if err := postBody.PatternACL.Validate(refStr); err != nil {
+			respondErr(w, patternACLError(err))
+			return
+		}
+	}
+
 	modified := time.Now().UTC()
 	// Get Result Object
 
@@ -105,6 +135,25 @@ func ProjectUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if postBody.DefaultACL != nil {
+		res, err = projects.UpdateDefaultACL(projectUUID, *postBody.DefaultACL, modified, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+	}
+
+	if postBody.PatternACL != nil {
+		res, err = projects.UpdatePatternACL(projectUUID, *postBody.PatternACL, modified, refStr)
+		auth.InvalidateProjectACL(projectUUID)
+		if err != nil {
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+	}
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 	if err != nil {
@@ -153,6 +202,22 @@ func ProjectCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the users of a default acl should exist before the project gets created
+	if postBody.DefaultACL != nil {
+		if err := postBody.DefaultACL.Validate(refStr); err != nil {
+			err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+			respondErr(w, err)
+			return
+		}
+	}
+
+	if postBody.PatternACL != nil {
+		if err := postBody.PatternACL.Validate(refStr); err != nil {
+			respondErr(w, patternACLError(err))
+			return
+		}
+	}
+
 	uuid := uuid.NewV4().String() // generate a new uuid to attach to the new project
 	created := time.Now().UTC()
 	// Get Result Object
@@ -170,6 +235,24 @@ func ProjectCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if postBody.DefaultACL != nil {
+		res, err = projects.UpdateDefaultACL(uuid, *postBody.DefaultACL, created, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+	}
+
+	if postBody.PatternACL != nil {
+		res, err = projects.UpdatePatternACL(uuid, *postBody.PatternACL, created, refStr)
+		if err != nil {
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+	}
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 	if err != nil {
@@ -781,7 +864,6 @@ func ProjectUserAdd(w http.ResponseWriter, r *http.Request) {
 
 	// Write response
 	privileged := auth.IsServiceAdmin(refRoles)
-	fmt.Println(privileged)
 	results, err := auth.FindUsers(refProjUUID, "", urlUser, privileged, refStr)
 
 	if err != nil {
@@ -815,7 +897,6 @@ func ProjectUserAdd(w http.ResponseWriter, r *http.Request) {
 func ProjectListUsers(w http.ResponseWriter, r *http.Request) {
 
 	var err error
-	var pageSize int
 	var paginatedUsers auth.PaginatedUsers
 
 	// Init output
@@ -834,7 +915,6 @@ func ProjectListUsers(w http.ResponseWriter, r *http.Request) {
 	// Grab url path variables
 	urlValues := r.URL.Query()
 	pageToken := urlValues.Get("pageToken")
-	strPageSize := urlValues.Get("pageSize")
 	details := urlValues.Get("details")
 	usersDetailedView := false
 
@@ -842,20 +922,30 @@ func ProjectListUsers(w http.ResponseWriter, r *http.Request) {
 		usersDetailedView = true
 	}
 
-	if strPageSize != "" {
-		if pageSize, err = strconv.Atoi(strPageSize); err != nil {
-			log.Errorf("Pagesize %v produced an error  while being converted to int: %v", strPageSize, err.Error())
-			err := APIErrorInvalidData("Invalid page size")
-			respondErr(w, err)
-			return
-		}
+	pageSize, err := listPageSize(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// users are listed by creation, most recent first, unless ordered by name
+	findUsers := auth.PaginatedFindUsers
+	switch urlValues.Get("orderBy") {
+	case "":
+	case "name":
+		findUsers = auth.PaginatedFindUsersByName
+	default:
+		err := APIErrorInvalidData("Invalid orderBy value, it should be name")
+		respondErr(w, err)
+		return
 	}
 
 	// check that user is indeed a service admin in order to be priviledged to see full user info
 	priviledged := auth.IsServiceAdmin(refRoles)
 
 	// Get Results Object - call is always priviledged because this handler is only accessible by service admins
-	if paginatedUsers, err = auth.PaginatedFindUsers(pageToken, int32(pageSize), projectUUID, priviledged, usersDetailedView, refStr); err != nil {
+	if paginatedUsers, err = findUsers(pageToken, int32(pageSize), projectUUID, priviledged, usersDetailedView, refStr); err != nil {
 		err := APIErrorInvalidData("Invalid page token")
 		respondErr(w, err)
 		return
@@ -874,3 +964,287 @@ func ProjectListUsers(w http.ResponseWriter, r *http.Request) {
 	output = []byte(resJSON)
 	respondOK(w, output)
 }
+
+// ProjectExportACLs (GET) returns the acls of all the topics and subscriptions of a project as a single document
+func ProjectExportACLs(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	res, err := auth.ExportProjectACLs(projectUUID, refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// ProjectUserACLs (GET) lists the project's topics and subscriptions whose acls include the user
+func ProjectUserACLs(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlUser := urlVars["user"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	pageSize, err := listPageSize(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	users, err := auth.FindUsers(projectUUID, "", urlUser, true, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("User")
+			respondErr(w, err)
+			return
+		}
+
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	res, err := auth.FindACLMemberships(projectUUID, users.One().UUID, r.URL.Query().Get("pageToken"), int32(pageSize), refStr)
+	if err != nil {
+		if err.Error() == "invalid page token" {
+			err := APIErrorInvalidData("Invalid page token")
+			respondErr(w, err)
+			return
+		}
+
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// ProjectImportACLs (POST) replaces the acls of a project's topics and subscriptions from a single document,
+// nothing is applied unless all the referenced users and resources exist
+func ProjectImportACLs(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	postBody, err := auth.GetProjectACLsFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Project ACLs")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// check if the acls contain valid users for the given project
+	_, err = auth.AreValidUsers(projectUUID, postBody.Users(), refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(w, err)
+		return
+	}
+
+	for name := range postBody.Topics {
+		if !topics.HasTopic(projectUUID, name, refStr) {
+			err := APIErrorNotFound("Topic " + name)
+			respondErr(w, err)
+			return
+		}
+	}
+
+	for name := range postBody.Subscriptions {
+		if !subscriptions.HasSub(projectUUID, name, refStr) {
+			err := APIErrorNotFound("Subscription " + name)
+			respondErr(w, err)
+			return
+		}
+	}
+
+	err = auth.ImportProjectACLs(projectUUID, postBody, refUserUUID, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// ProjectExport (GET) returns a manifest of all the users, topics and subscriptions of a project,
+// the offsets of the subscriptions are included only when requested with offsets=true
+func ProjectExport(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	withOffsets := r.URL.Query().Get("offsets") == "true"
+
+	res, err := manifests.Export(projectUUID, withOffsets, time.Now().UTC(), refBrk, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("ProjectUUID")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := res.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// ProjectImport (POST) recreates the users, topics and subscriptions of a manifest under a project and reports
+// the outcome of every resource. Nothing is applied unless the whole manifest is valid
+func ProjectImport(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	postBody, err := manifests.GetFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Manifest")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+
+	opts := manifests.ImportOptions{
+		PushEnabled: pushEnabled,
+		Actor:       refUserUUID,
+		CreatedOn:   time.Now().UTC(),
+	}
+
+	report := manifests.Import(projectUUID, postBody, opts, refBrk, refStr)
+
+	// Output result to JSON
+	resJSON, err := report.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// an invalid manifest is rejected as a whole, while a failure to apply it has already been rolled back
+	if !report.Applied {
+		code := http.StatusBadRequest
+		for _, res := range report.Results {
+			if res.Status == manifests.StatusFailed {
+				code = http.StatusInternalServerError
+			}
+		}
+		w.WriteHeader(code)
+		w.Write([]byte(resJSON))
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// patternACLError maps the failed validation of a pattern acl to an api error,
+// invalid patterns are bad requests while unknown users are not found
+func patternACLError(err error) APIErrorRoot {
+	if strings.HasPrefix(err.Error(), "invalid") {
+		return APIErrorInvalidData(err.Error())
+	}
+	return APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+}
@@ -6,10 +6,12 @@ import (
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/manifests"
 	"github.com/ARGOeu/argo-messaging/projects"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/topics"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
@@ -1204,6 +1206,66 @@ func (suite *ProjectsHandlersTestSuite) TestProjectDelete() {
 	suite.Equal(expResp, w.Body.String())
 }
 
+func (suite *ProjectsHandlersTestSuite) TestProjectDeleteIgnoreNotFound() {
+
+	type td struct {
+		url                string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			url: "http://localhost:8080/v1/projects/UNKNOWN?key=S3CR3T",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "project doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing project without ignoreNotFound",
+		},
+		{
+			url:                "http://localhost:8080/v1/projects/UNKNOWN?key=S3CR3T&ignoreNotFound=true",
+			expectedResponse:   "",
+			expectedStatusCode: 200,
+			msg:                "Missing project with ignoreNotFound",
+		},
+		{
+			url: "http://localhost:8080/v1/projects/UNKNOWN?key=unknown-key&ignoreNotFound=true",
+			expectedResponse: `{
+   "error": {
+      "code": 401,
+      "message": "Unauthorized",
+      "status": "UNAUTHORIZED"
+   }
+}`,
+			expectedStatusCode: 401,
+			msg:                "Missing project with ignoreNotFound still requires authentication",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.ServiceToken = "S3CR3T"
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}", WrapConfig(WrapAuthenticate(http.HandlerFunc(ProjectDelete), UrlKeyExtract), cfgKafka, &brk, str, &mgr, nil, nil)).Name("projects:delete")
+
+	for _, t := range testData {
+		req, _ := http.NewRequest("DELETE", t.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
 func (suite *ProjectsHandlersTestSuite) TestProjectUpdate() {
 
 	postJSON := `{
@@ -1261,6 +1323,149 @@ func (suite *ProjectsHandlersTestSuite) TestProjectCreate() {
 	suite.Equal("This is a newly created project", projOut.Description)
 }
 
+func (suite *ProjectsHandlersTestSuite) TestProjectCreateDefaultACL() {
+
+	postJSON := `{
+	"description":"This is a newly created project",
+	"default_acl": {
+		"topics": ["UserA"],
+		"subscriptions": ["UserB", "UserA"]
+	}
+}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGONEW", bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}", WrapMockAuthConfig(ProjectCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	projOut, _ := projects.GetFromJSON([]byte(w.Body.String()))
+	suite.Equal("ARGONEW", projOut.Name)
+	suite.Equal(&projects.DefaultACL{Topics: []string{"UserA"}, Subscriptions: []string{"UserB", "UserA"}}, projOut.DefaultACL)
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectCreateDefaultACLUnknownUser() {
+
+	postJSON := `{
+	"description":"This is a newly created project",
+	"default_acl": {
+		"topics": ["UserA", "UserUnknown"]
+	}
+}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGONEW", bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "error": {
+      "code": 404,
+      "message": "User(s): UserUnknown do not exist",
+      "status": "NOT_FOUND"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}", WrapMockAuthConfig(ProjectCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	// the project should not have been created
+	qp, _ := str.QueryProjects("", "ARGONEW")
+	suite.Equal(0, len(qp))
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectUpdateDefaultACL() {
+
+	postJSON := `{
+	"default_acl": {
+		"topics": ["UserB"]
+	}
+}`
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO", bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}", WrapMockAuthConfig(ProjectUpdate, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	projOut, _ := projects.GetFromJSON([]byte(w.Body.String()))
+	suite.Equal("ARGO", projOut.Name)
+	suite.Equal(&projects.DefaultACL{Topics: []string{"UserB"}, Subscriptions: []string{}}, projOut.DefaultACL)
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectUpdatePatternACL() {
+
+	postJSON := `{
+	"pattern_acl": {
+		"topics": [{"pattern": "^topic[12]$", "authorized_users": ["UserZ"]}]
+	}
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}", WrapMockAuthConfig(ProjectUpdate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO", bytes.NewBuffer([]byte(postJSON)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	projOut, _ := projects.GetFromJSON([]byte(w.Body.String()))
+	suite.Equal(&projects.PatternACL{Topics: []projects.PatternGrant{{Pattern: "^topic[12]$", AuthUsers: []string{"UserZ"}}}, Subscriptions: []projects.PatternGrant{}}, projOut.PatternACL)
+	suite.True(auth.PerResource("argo_uuid", "topics", "topic2", "uuid4", str))
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "invalid pattern ^topic[12, error parsing regexp: missing closing ]: ` + "`[12`" + `",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	// a pattern that doesn't compile leaves the grants as they are
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO", bytes.NewBuffer([]byte(`{"pattern_acl": {"topics": [{"pattern": "^topic[12", "authorized_users": ["UserA"]}]}}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	qp, _ := str.QueryProjects("argo_uuid", "")
+	suite.Equal("^topic[12]$", qp[0].PatternACL.Topics[0].Pattern)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO", bytes.NewBuffer([]byte(`{"pattern_acl": {"subscriptions": [{"pattern": "^sub", "authorized_users": ["UserUnknown"]}]}}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
 func (suite *ProjectsHandlersTestSuite) TestProjectListAll() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects", nil)
@@ -1304,6 +1509,35 @@ func (suite *ProjectsHandlersTestSuite) TestProjectListAll() {
 
 }
 
+func (suite *ProjectsHandlersTestSuite) TestProjectListAllEmpty() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "projects": []
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// empty the store
+	str.ProjectList = []stores.QProject{}
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+
+	router.HandleFunc("/v1/projects", WrapMockAuthConfig(ProjectListAll, cfgKafka, &brk, str, &mgr, nil))
+
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
 func (suite *ProjectsHandlersTestSuite) TestProjectListOneNotFound() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGONAUFTS", nil)
@@ -1364,6 +1598,316 @@ func (suite *ProjectsHandlersTestSuite) TestProjectListOne() {
 
 }
 
+func (suite *ProjectsHandlersTestSuite) TestProjectExportACLs() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:exportAcls", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": {
+      "topic1": [
+         "UserA",
+         "UserB"
+      ],
+      "topic2": [
+         "UserA",
+         "UserB",
+         "UserZ"
+      ],
+      "topic3": [
+         "UserX"
+      ]
+   },
+   "subscriptions": {
+      "sub1": [
+         "UserA",
+         "UserB"
+      ],
+      "sub2": [
+         "UserA",
+         "UserX"
+      ],
+      "sub3": [
+         "UserZ",
+         "UserB",
+         "UserA"
+      ],
+      "sub4": [
+         "UserB",
+         "UserZ",
+         "push_worker_0"
+      ]
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}:exportAcls", WrapMockAuthConfig(ProjectExportACLs, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectUserACLs() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/users/{user}:acls", WrapMockAuthConfig(ProjectUserACLs, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	expAll := `{
+   "acls": [
+      {
+         "type": "topics",
+         "name": "topic1"
+      },
+      {
+         "type": "topics",
+         "name": "topic2"
+      },
+      {
+         "type": "subscriptions",
+         "name": "sub1"
+      },
+      {
+         "type": "subscriptions",
+         "name": "sub2"
+      },
+      {
+         "type": "subscriptions",
+         "name": "sub3"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 5
+}`
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/users/UserA:acls", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expAll, w.Body.String())
+
+	expPage1 := `{
+   "acls": [
+      {
+         "type": "topics",
+         "name": "topic1"
+      },
+      {
+         "type": "topics",
+         "name": "topic2"
+      }
+   ],
+   "nextPageToken": "Mg==",
+   "totalSize": 5
+}`
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/users/UserA:acls?pageSize=2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expPage1, w.Body.String())
+
+	expPage3 := `{
+   "acls": [
+      {
+         "type": "subscriptions",
+         "name": "sub3"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 5
+}`
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/users/UserA:acls?pageSize=2&pageToken=NA==", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expPage3, w.Body.String())
+
+	// a page token past the memberships
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/users/UserA:acls?pageToken=OQ==", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid page token")
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/users/UserUnknown:acls", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "User doesn't exist")
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectImportACLs() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}:importAcls", WrapMockAuthConfig(ProjectImportACLs, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// an unknown user should prevent any modification
+	postJSON := `{"topics":{"topic1":["UserX"]},"subscriptions":{"sub1":["UserFoo"]}}`
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:importAcls", bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 404,
+      "message": "User(s): UserFoo do not exist",
+      "status": "NOT_FOUND"
+   }
+}`, w.Body.String())
+	suite.Equal([]string{"uuid1", "uuid2"}, str.TopicsACL["topic1"].ACL)
+
+	// so should an unknown resource
+	postJSON = `{"topics":{"topic1":["UserX"],"unknown":["UserZ"]}}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:importAcls", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "Topic unknown doesn't exist")
+	suite.Equal([]string{"uuid1", "uuid2"}, str.TopicsACL["topic1"].ACL)
+
+	postJSON = `{"topics":{"topic1":["UserX"]},"subscriptions":{"sub1":["UserZ","UserA"]}}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:importAcls", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+	suite.Equal([]string{"uuid3"}, str.TopicsACL["topic1"].ACL)
+	suite.Equal([]string{"uuid4", "uuid1"}, str.SubsACL["sub1"].ACL)
+	// resources missing from the document are left untouched
+	suite.Equal([]string{"uuid1", "uuid3"}, str.SubsACL["sub2"].ACL)
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectExport() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}:export", WrapMockAuthConfig(ProjectExport, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:export", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	m, _ := manifests.GetFromJSON(w.Body.Bytes())
+	suite.Equal("ARGO", m.Project)
+	suite.Equal(4, len(m.Topics))
+	suite.Equal(4, len(m.Subscriptions))
+	suite.Nil(m.Subscriptions[0].Offset)
+	// the push worker isn't a member of the project and is left out of the acls
+	suite.Equal([]string{"UserB", "UserZ"}, m.Subscriptions[0].ACL)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO:export?offsets=true", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	m, _ = manifests.GetFromJSON(w.Body.Bytes())
+	suite.NotNil(m.Subscriptions[0].Offset)
+}
+
+func (suite *ProjectsHandlersTestSuite) TestProjectImport() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}:import", WrapMockAuthConfig(ProjectImport, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// an invalid resource should prevent any modification
+	postJSON := `{
+	"project": "ARGO",
+	"topics": [{"name": "topicNew", "acl": ["UserA"]}],
+	"subscriptions": [{"name": "sub1", "topic": "projects/ARGO/topics/topicNew", "acl": []}]
+}`
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:import", bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(`{
+   "applied": false,
+   "results": [
+      {
+         "resource": "topics",
+         "name": "topicNew",
+         "status": "skipped"
+      },
+      {
+         "resource": "subscriptions",
+         "name": "sub1",
+         "status": "invalid",
+         "message": "subscription already exists"
+      }
+   ]
+}`, w.Body.String())
+	suite.False(topics.HasTopic("argo_uuid", "topicNew", str))
+
+	postJSON = `{
+	"project": "ARGO",
+	"topics": [{"name": "topicNew", "acl": ["UserA"]}],
+	"subscriptions": [{"name": "subNew", "topic": "projects/ARGO/topics/topicNew", "acl": ["UserB"]}]
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:import", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "applied": true,
+   "results": [
+      {
+         "resource": "topics",
+         "name": "topicNew",
+         "status": "created"
+      },
+      {
+         "resource": "subscriptions",
+         "name": "subNew",
+         "status": "created"
+      }
+   ]
+}`, w.Body.String())
+	suite.Equal([]string{"uuid1"}, str.TopicsACL["topicNew"].ACL)
+	suite.Equal([]string{"uuid2"}, str.SubsACL["subNew"].ACL)
+
+	// the broker topic got created along with the topic
+	_, err = brk.DescribeTopic("argo_uuid.topicNew")
+	suite.Nil(err)
+}
+
 func TestProjectsHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(ProjectsHandlersTestSuite))
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -55,7 +56,7 @@ func RegisterUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	uuid := uuid.NewV4().String()
-	registered := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+	registered := timestamp.Format(time.Now())
 	tkn, err := auth.GenToken()
 	if err != nil {
 		err := APIErrGenericInternal("")
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/validation"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"io/ioutil"
+	"net/http"
+)
+
+// RoleListAll (GET) lists all the roles and the operations each one of them grants access to
+func RoleListAll(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	roles, err := auth.FindRoles(refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := roles.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// OperationListAll (GET) lists the operations of the service that roles grant access to
+func OperationListAll(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refOperations := gorillaContext.Get(r, "operations").([]string)
+
+	ops := auth.NewOperationList(refOperations)
+
+	// Output result to JSON
+	resJSON, err := ops.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// RoleListByOperation (GET) lists the roles that grant access to a specific operation
+func RoleListByOperation(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	opRoles, err := auth.FindOperationRoles(urlVars["operation"], refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Operation")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := opRoles.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// RoleCreate (POST) maps an operation to the roles that grant access to it
+func RoleCreate(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	operation := urlVars["operation"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refOperations := gorillaContext.Get(r, "operations").([]string)
+
+	postBody, apiErr, ok := parseOperationRoles(r, operation, refOperations)
+	if !ok {
+		respondErr(w, apiErr)
+		return
+	}
+
+	opRoles, err := auth.CreateOperationRoles(operation, postBody.Roles, refStr)
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorConflict("Operation")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := opRoles.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// RoleUpdate (PUT) replaces the roles that grant access to an operation
+func RoleUpdate(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	operation := urlVars["operation"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refOperations := gorillaContext.Get(r, "operations").([]string)
+
+	postBody, apiErr, ok := parseOperationRoles(r, operation, refOperations)
+	if !ok {
+		respondErr(w, apiErr)
+		return
+	}
+
+	opRoles, err := auth.UpdateOperationRoles(operation, postBody.Roles, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Operation")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := opRoles.ExportJSON()
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte(resJSON))
+}
+
+// RoleDelete (DELETE) removes the mapping of an operation to its roles
+func RoleDelete(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	err := auth.RemoveOperationRoles(urlVars["operation"], refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Operation")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// Write empty response if anything ok
+	respondOK(w, output)
+}
+
+// parseOperationRoles reads and validates the roles of an operation from the request body.
+// The operation should be one of the operations known to the service
+func parseOperationRoles(r *http.Request, operation string, operations []string) (auth.OperationRoles, APIErrorRoot, bool) {
+
+	knownOperation := false
+	for _, op := range operations {
+		if op == operation {
+			knownOperation = true
+			break
+		}
+	}
+
+	if !knownOperation {
+		return auth.OperationRoles{}, APIErrorInvalidData("Unknown operation"), false
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return auth.OperationRoles{}, APIErrorInvalidRequestBody(), false
+	}
+
+	postBody, err := auth.GetOperationRolesFromJSON(body)
+	if err != nil {
+		return auth.OperationRoles{}, APIErrorInvalidArgument("Role"), false
+	}
+
+	for _, role := range postBody.Roles {
+		if !validation.ValidName(role) {
+			return auth.OperationRoles{}, APIErrorInvalidName("role"), false
+		}
+	}
+
+	return postBody, APIErrorRoot{}, true
+}
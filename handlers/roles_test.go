@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
+	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
+	"github.com/ARGOeu/argo-messaging/stores"
+	gorillaContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/suite"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type RolesHandlersTestSuite struct {
+	suite.Suite
+	cfgStr string
+}
+
+func (suite *RolesHandlersTestSuite) SetupTest() {
+	suite.cfgStr = `{
+	"bind_ip":"",
+	"port":8080,
+	"zookeeper_hosts":["localhost"],
+	"kafka_znode":"",
+	"store_host":"localhost",
+	"store_db":"argo_msg",
+	"certificate":"/etc/pki/tls/certs/localhost.crt",
+	"certificate_key":"/etc/pki/tls/private/localhost.key",
+	"per_resource_auth":"true",
+	"push_enabled": "true",
+	"push_worker_token": "push_token"
+	}`
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleListAll() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/roles", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "roles": [
+      {
+         "name": "admin",
+         "operations": [
+            "topics:list_all",
+            "topics:publish"
+         ]
+      },
+      {
+         "name": "publisher",
+         "operations": [
+            "topics:list_all",
+            "topics:publish"
+         ]
+      },
+      {
+         "name": "reader",
+         "operations": [
+            "topics:list_all"
+         ]
+      }
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/roles", WrapMockAuthConfig(RoleListAll, cfgKafka, &brk, str, &mgr, pc))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleListByOperation() {
+
+	type td struct {
+		operation          string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			operation: "topics:publish",
+			expectedResponse: `{
+   "operation": "topics:publish",
+   "roles": [
+      "admin",
+      "publisher"
+   ]
+}`,
+			expectedStatusCode: 200,
+			msg:                "List the roles of an operation",
+		},
+		{
+			operation: "topics:unknown",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Operation doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Operation doesn't exist",
+		},
+		{
+			operation: "topics.publish",
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid operation name",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Invalid operation name",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	for _, t := range testData {
+
+		req, err := http.NewRequest("GET", "http://localhost:8080/v1/roles/"+t.operation, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/roles/{operation}", WrapValidate(WrapMockAuthConfig(RoleListByOperation, cfgKafka, &brk, str, &mgr, pc)))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
+// wrapMockOperations sets the known operations of the service in the request context
+func wrapMockOperations(hfn http.HandlerFunc, operations ...string) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gorillaContext.Set(r, "operations", operations)
+		hfn.ServeHTTP(w, r)
+	})
+}
+
+func (suite *RolesHandlersTestSuite) TestOperationListAll() {
+
+	expResp := `{
+   "operations": [
+      "operations:list",
+      "roles:list",
+      "topics:publish"
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/operations", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/operations", WrapMockAuthConfig(wrapMockOperations(OperationListAll, "topics:publish", "roles:list", "operations:list"), cfgKafka, &brk, str, &mgr, pc))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleCreate() {
+
+	type td struct {
+		operation          string
+		postBody           string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			operation: "topics:list",
+			postBody:  `{"roles": ["custom_role", "admin"]}`,
+			expectedResponse: `{
+   "operation": "topics:list",
+   "roles": [
+      "admin",
+      "custom_role"
+   ]
+}`,
+			expectedStatusCode: 200,
+			msg:                "Create a new role mapping",
+		},
+		{
+			operation: "topics:publish",
+			postBody:  `{"roles": ["custom_role"]}`,
+			expectedResponse: `{
+   "error": {
+      "code": 409,
+      "message": "Operation already exists",
+      "status": "ALREADY_EXISTS"
+   }
+}`,
+			expectedStatusCode: 409,
+			msg:                "Role mapping already exists",
+		},
+		{
+			operation: "topics:unknown",
+			postBody:  `{"roles": ["custom_role"]}`,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Unknown operation",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Operation is not known to the service",
+		},
+		{
+			operation: "topics:show",
+			postBody:  `{"roles": []}`,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid Role Arguments",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Empty list of roles",
+		},
+		{
+			operation: "topics:show",
+			postBody:  `{"roles": ["invalid role"]}`,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid role name",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Invalid role name",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	// the custom role shouldn't have access before the mapping gets created
+	suite.False(auth.Authorize("topics:list", []string{"custom_role"}, str))
+
+	for _, t := range testData {
+
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/roles/"+t.operation, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/roles/{operation}", wrapMockOperations(WrapMockAuthConfig(RoleCreate, cfgKafka, &brk, str, &mgr, pc), "topics:list", "topics:show", "topics:publish"))
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+
+	// the newly created role mapping should immediately grant access
+	suite.True(auth.Authorize("topics:list", []string{"custom_role"}, str))
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleUpdate() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/roles/{operation}", wrapMockOperations(WrapMockAuthConfig(RoleUpdate, cfgKafka, &brk, str, &mgr, pc), "topics:list", "topics:publish"))
+
+	expResp := `{
+   "operation": "topics:publish",
+   "roles": [
+      "custom_role"
+   ]
+}`
+
+	suite.True(auth.Authorize("topics:publish", []string{"publisher"}, str))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/roles/topics:publish", strings.NewReader(`{"roles": ["custom_role"]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	suite.True(auth.Authorize("topics:publish", []string{"custom_role"}, str))
+	suite.False(auth.Authorize("topics:publish", []string{"publisher"}, str))
+
+	// operation without a role mapping
+	expResp2 := `{
+   "error": {
+      "code": 404,
+      "message": "Operation doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+	req2, _ := http.NewRequest("PUT", "http://localhost:8080/v1/roles/topics:list", strings.NewReader(`{"roles": ["custom_role"]}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(404, w2.Code)
+	suite.Equal(expResp2, w2.Body.String())
+}
+
+func (suite *RolesHandlersTestSuite) TestRoleDelete() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/roles/{operation}", WrapMockAuthConfig(RoleDelete, cfgKafka, &brk, str, &mgr, pc))
+
+	req, _ := http.NewRequest("DELETE", "http://localhost:8080/v1/roles/topics:publish", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+	suite.False(auth.Authorize("topics:publish", []string{"publisher"}, str))
+
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req)
+	suite.Equal(404, w2.Code)
+}
+
+func TestRolesHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(RolesHandlersTestSuite))
+}
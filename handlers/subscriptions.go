@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/events"
+	"github.com/ARGOeu/argo-messaging/labels"
 	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
+	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	"github.com/ARGOeu/argo-messaging/topics"
 	"github.com/ARGOeu/argo-messaging/validation"
 	gorillaContext "github.com/gorilla/context"
@@ -39,6 +44,8 @@ func SubAck(w http.ResponseWriter, r *http.Request) {
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	disableAutoOffsetAdvance := gorillaContext.Get(r, "disable_auto_offset_advance").(bool)
 	// Read POST JSON body
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -48,9 +55,10 @@ func SubAck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pull options
+	body = importJSON(r, body, subscriptions.AckIDs{})
 	postBody, err := subscriptions.GetAckFromJSON(body)
 	if err != nil {
-		err := APIErrorInvalidData("Invalid ack parameter")
+		err := APIErrorInvalidData(err.Error())
 		respondErr(w, err)
 		return
 	}
@@ -74,42 +82,107 @@ func SubAck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get list of AckIDs
-	if postBody.IDs == nil {
+	if len(postBody.IDs) == 0 && postBody.AckOffset == nil {
 		err := APIErrorInvalidData("Invalid ack id")
 		respondErr(w, err)
 		return
 	}
 
-	// Check if each AckID is valid
-	for _, ackID := range postBody.IDs {
-		if validation.ValidAckID(projectName, subName, ackID) == false {
+	// a bare offset carries no signature, so when ack ids are signed only the ack ids the service issued can acknowledge messages
+	if postBody.AckOffset != nil && cfg.AckIDSecret != "" {
+		err := APIErrorInvalidData("Acknowledging by offset is not allowed when ack ids are signed, acknowledge the ack id of the latest message instead")
+		respondErr(w, err)
+		return
+	}
+
+	// Check if each AckID is valid and keep track of the max offset,
+	// unless the offset to acknowledge up to has been declared directly
+	var off int64
+	if postBody.AckOffset != nil {
+		off = *postBody.AckOffset
+	}
+	for i, rawAckID := range postBody.IDs {
+
+		ackID, err := subscriptions.ParseAckID(rawAckID)
+		if err != nil || !ackID.BelongsTo(projectName, subName) {
+			err := APIErrorInvalidData("Invalid ack id")
+			respondErr(w, err)
+			return
+		}
+
+		// when ack ids are signed, only the ones issued by the service are accepted
+		if cfg.AckIDSecret != "" && !ackID.Verify(cfg.AckIDSecret) {
 			err := APIErrorInvalidData("Invalid ack id")
 			respondErr(w, err)
 			return
 		}
+
+		if ackID.Version == subscriptions.AckIDVersionLegacy {
+			log.WithFields(
+				log.Fields{
+					"type":         "service_log",
+					"subscription": subName,
+					"ack_id":       rawAckID,
+				},
+			).Warning("Deprecated unversioned ack id format used")
+		}
+
+		if i == 0 || ackID.Offset > off {
+			off = ackID.Offset
+		}
 	}
 
-	// Get Max ackID
-	maxAckID, err := subscriptions.GetMaxAckID(postBody.IDs)
-	if err != nil {
-		err := APIErrHandlingAcknowledgement()
-		respondErr(w, err)
+	// the messages of at most once subscriptions were committed when pulled, so there is nothing left to acknowledge
+	if cur_sub.Subscriptions[0].AtMostOnce && !disableAutoOffsetAdvance {
+		respondOK(w, output)
 		return
 	}
-	// Extract offset from max ackID
-	off, err := subscriptions.GetOffsetFromAckID(maxAckID)
 
-	if err != nil {
-		err := APIErrorInvalidData("Invalid ack id")
+	if cfg.VerifyAckOffsets {
+		refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+		brkTopic := cur_sub.Subscriptions[0].BrokerTopic
+		maxOffset := refBrk.GetMaxOffset(brkTopic)
+
+		// the broker never held the acknowledged message, the stored offsets and the broker's have diverged
+		if off >= maxOffset {
+			log.WithFields(
+				log.Fields{
+					"type":         "service_log",
+					"subscription": subName,
+					"ack_offset":   off,
+					"max_offset":   maxOffset,
+				},
+			).Error("Acknowledged offset is beyond the latest offset of the broker topic")
+			err := APIErrorGenericConflict(fmt.Sprintf("Acknowledged offset %v is beyond the latest offset %v of the topic", off, maxOffset))
+			respondErr(w, err)
+			return
+		}
+
+		// the acknowledged message has already been removed by the broker's retention or compaction,
+		// acking it is still harmless since the next pull starts from the earliest message left
+		if minOffset := refBrk.GetMinOffset(brkTopic); off < minOffset {
+			log.WithFields(
+				log.Fields{
+					"type":         "service_log",
+					"subscription": subName,
+					"ack_offset":   off,
+					"min_offset":   minOffset,
+				},
+			).Warning("Acknowledged offset is no longer held by the broker topic")
+		}
+	}
+
+	// the offsets don't move once the request's deadline has passed, the client can't tell whether the ack took effect
+	if r.Context().Err() != nil {
+		err := APIErrorDeadlineExceeded()
 		respondErr(w, err)
 		return
 	}
 
-	zSec := "2006-01-02T15:04:05Z"
-	t := time.Now().UTC()
-	ts := t.Format(zSec)
+	// the ack time keeps its sub-second precision, so that the ack deadline is checked accurately
+	ts := timestamp.FormatNano(cfg.Now())
 
-	err = refStr.UpdateSubOffsetAck(projectUUID, urlVars["subscription"], int64(off+1), ts)
+	err = refStr.UpdateSubOffsetAck(projectUUID, urlVars["subscription"], off+1, ts, cur_sub.Subscriptions[0].Version)
 	if err != nil {
 
 		if err.Error() == "ack timeout" {
@@ -118,11 +191,34 @@ func SubAck(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		// the offsets were moved by another request in the meantime, the client should retry
+		if err.Error() == "conflict" {
+			err := APIErrorGenericConflict("Subscription offsets were modified concurrently, please retry")
+			respondErr(w, err)
+			return
+		}
+
 		err := APIErrGenericInternal(err.Error())
 		respondErr(w, err)
 		return
 	}
 
+	if err := refStr.UpdateSubLastProgress(projectUUID, subName, cfg.Now().UTC()); err != nil {
+		log.Errorf("Couldn't update the last progress of subscription %v, %v", subName, err.Error())
+	}
+
+	// every message between the previous offset and the acknowledged one counts as acknowledged
+	ackedSub := cur_sub.Subscriptions[0]
+	if err := ackedSub.RecordAcked(ackedSub.Offset, off, cfg.DedupWindow, refStr); err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":         "service_log",
+				"subscription": subName,
+				"error":        err.Error(),
+			},
+		).Error("Could not record the acknowledged message ids")
+	}
+
 	// Output result to JSON
 	resJSON := "{}"
 
@@ -166,16 +262,143 @@ func SubListOne(w http.ResponseWriter, r *http.Request) {
 
 	// if its a push enabled sub and it has a verified endpoint
 	// call the push server to find its real time push status
-	if results.Subscriptions[0].PushCfg != (subscriptions.PushConfig{}) {
+	if !results.Subscriptions[0].PushCfg.IsEmpty() {
 		if results.Subscriptions[0].PushCfg.Verified {
-			apsc := gorillaContext.Get(r, "apsc").(push.Client)
-			results.Subscriptions[0].PushStatus = apsc.SubscriptionStatus(context.TODO(), results.Subscriptions[0].FullName).Result(false)
+			refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+			if refMgr.Serves(results.Subscriptions[0].PushCfg) {
+				ws := refMgr.Status(projectUUID, results.Subscriptions[0].Name, time.Now())
+				results.Subscriptions[0].PushStatus = fmt.Sprintf("Subscription %v is %v on the push manager of the service", results.Subscriptions[0].FullName, ws.Status)
+			} else {
+				apsc := gorillaContext.Get(r, "apsc").(push.Client)
+				results.Subscriptions[0].PushStatus = apsc.SubscriptionStatus(context.TODO(), results.Subscriptions[0].FullName).Result(false)
+			}
 		}
 	}
 
+	// secrets should never be exposed when listing subscriptions
+	results.Subscriptions[0].MaskSecrets()
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &results.Subscriptions[0])
+
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondCacheable(w, r, output)
+}
+
+// SubConfig (GET) the effective configuration of a subscription, labeling each value as explicit or default
+func SubConfig(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	results, err := subscriptions.Find(projectUUID, "", urlVars["subscription"], "", 0, refStr)
+
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// If not found
+	if results.Empty() {
+		err := APIErrorNotFound("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	cfg := results.Subscriptions[0].EffectiveConfig()
+
 	// Output result to JSON
-	resJSON, err := results.Subscriptions[0].ExportJSON()
+	resJSON, err := exportJSON(r, &cfg)
+
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// SubCompare (GET) reports the differences between the configurations of a subscription and the one given with the with parameter
+func SubCompare(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	withName := r.URL.Query().Get("with")
+	if withName == "" {
+		err := APIErrorInvalidData("The subscription to compare with should be given with the with parameter")
+		respondErr(w, err)
+		return
+	}
+
+	compared := []subscriptions.Subscription{}
+	acls := [][]string{}
+	for _, name := range []string{urlVars["subscription"], withName} {
+
+		results, err := subscriptions.Find(projectUUID, "", name, "", 0, refStr)
+		if err != nil {
+			err := APIErrGenericBackend()
+			respondErr(w, err)
+			return
+		}
+
+		// If not found
+		if results.Empty() {
+			err := APIErrorNotFound("Subscription " + name)
+			respondErr(w, err)
+			return
+		}
+
+		acl, err := auth.GetACL(projectUUID, "subscriptions", name, refStr)
+		if err != nil {
+			err := APIErrQueryDatastore()
+			respondErr(w, err)
+			return
+		}
+
+		compared = append(compared, results.Subscriptions[0])
+		acls = append(acls, acl.AuthUsers)
+	}
+
+	res := subscriptions.Compare(compared[0], acls[0], compared[1], acls[1])
 
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -212,6 +435,7 @@ func SubSetOffset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pull options
+	body = importJSON(r, body, subscriptions.SetOffset{})
 	postBody, err := subscriptions.GetSetOffsetJSON(body)
 	if err != nil {
 		err := APIErrorInvalidArgument("Offset")
@@ -240,7 +464,7 @@ func SubSetOffset(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, err)
 		return
 	}
-	brk_topic := projectUUID + "." + results.Subscriptions[0].Topic
+	brk_topic := results.Subscriptions[0].BrokerTopic
 	min_offset := refBrk.GetMinOffset(brk_topic)
 	max_offset := refBrk.GetMaxOffset(brk_topic)
 
@@ -250,6 +474,13 @@ func SubSetOffset(w http.ResponseWriter, r *http.Request) {
 		respondErr(w, err)
 	}
 
+	// the offset doesn't move once the request's deadline has passed
+	if r.Context().Err() != nil {
+		err := APIErrorDeadlineExceeded()
+		respondErr(w, err)
+		return
+	}
+
 	// Get subscription offsets
 	refStr.UpdateSubOffset(projectUUID, urlSub, postBody.Offset)
 
@@ -292,7 +523,7 @@ func SubGetOffsets(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Output result to JSON
-	brkTopic := projectUUID + "." + results.Subscriptions[0].Topic
+	brkTopic := results.Subscriptions[0].BrokerTopic
 	curOffset := results.Subscriptions[0].Offset
 	minOffset := refBrk.GetMinOffset(brkTopic)
 	maxOffset := refBrk.GetMaxOffset(brkTopic)
@@ -311,7 +542,7 @@ func SubGetOffsets(w http.ResponseWriter, r *http.Request) {
 		Max:     maxOffset,
 	}
 
-	resJSON, err := offResult.ExportJSON()
+	resJSON, err := exportJSON(r, &offResult)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -366,8 +597,7 @@ func SubTimeToOffset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Output result to JSON
-	brkTopic := projectUUID + "." + results.Subscriptions[0].Topic
-	off, err := refBrk.TimeToOffset(brkTopic, t.Local())
+	off, err := topics.OffsetAt(projectUUID, results.Subscriptions[0].Topic, t, refStr, refBrk)
 
 	if err != nil {
 		log.Errorf(err.Error())
@@ -393,8 +623,8 @@ func SubTimeToOffset(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
-// SubDelete (DEL) deletes an existing subscription
-func SubDelete(w http.ResponseWriter, r *http.Request) {
+// SubOutstanding (GET) lists the messages of a subscription that have been pulled but not acknowledged yet
+func SubOutstanding(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -409,9 +639,12 @@ func SubDelete(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Get Result Object
 	results, err := subscriptions.Find(projectUUID, "", urlVars["subscription"], "", 0, refStr)
 	if err != nil {
 		err := APIErrGenericBackend()
@@ -426,33 +659,35 @@ func SubDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = subscriptions.RemoveSub(projectUUID, urlVars["subscription"], refStr)
-	if err != nil {
-		if err.Error() == "not found" {
-			err := APIErrorNotFound("Subscription")
+	// Check Authorization per subscription
+	// - if enabled in config
+	// - if user has only consumer role
+	if refAuthResource && auth.IsConsumer(refRoles) {
+		if auth.PerResource(projectUUID, "subscriptions", urlVars["subscription"], refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
 			respondErr(w, err)
 			return
 		}
-		err := APIErrGenericInternal(err.Error())
+	}
+
+	res := results.Subscriptions[0].Outstanding(urlVars["project"], cfg.AckIDSecret, cfg.Now().UTC())
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
 		respondErr(w, err)
 		return
 	}
 
-	// if it is a push sub and it is also has a verified push endpoint, deactivate it
-	if results.Subscriptions[0].PushCfg != (subscriptions.PushConfig{}) {
-		if results.Subscriptions[0].PushCfg.Verified {
-			pr := make(map[string]string)
-			apsc := gorillaContext.Get(r, "apsc").(push.Client)
-			pr["message"] = apsc.DeactivateSubscription(context.TODO(), results.Subscriptions[0].FullName).Result(false)
-			b, _ := json.Marshal(pr)
-			output = b
-		}
-	}
+	// Write response
+	output = []byte(resJSON)
 	respondOK(w, output)
 }
 
-// SubModACL (POST) modifies the ACL
-func SubModACL(w http.ResponseWriter, r *http.Request) {
+// SubDrain (POST) exports all the messages available to a subscription to the configured drain sink,
+// advancing the subscription's offset past them
+func SubDrain(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -464,58 +699,78 @@ func SubModACL(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
-	// Get Result Object
-	urlSub := urlVars["subscription"]
 
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	results, err := subscriptions.Find(projectUUID, "", urlVars["subscription"], "", 0, refStr)
 	if err != nil {
-		err := APIErrorInvalidRequestBody()
+		err := APIErrGenericBackend()
 		respondErr(w, err)
 		return
 	}
 
-	// Parse pull options
-	postBody, err := auth.GetACLFromJSON(body)
-	if err != nil {
-		err := APIErrorInvalidArgument("Subscription ACL")
+	// If not found
+	if results.Empty() {
+		err := APIErrorNotFound("Subscription")
 		respondErr(w, err)
-		log.Error(string(body[:]))
 		return
 	}
 
-	// Grab context references
-	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	// Get project UUID First to use as reference
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	// Check Authorization per subscription
+	// - if enabled in config
+	// - if user has only consumer role
+	if refAuthResource && auth.IsConsumer(refRoles) {
+		if auth.PerResource(projectUUID, "subscriptions", urlVars["subscription"], refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
+			respondErr(w, err)
+			return
+		}
+	}
 
-	// check if user list contain valid users for the given project
-	_, err = auth.AreValidUsers(projectUUID, postBody.AuthUsers, refStr)
-	if err != nil {
-		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+	if cfg.DrainSink == "" {
+		err := APIErrorGenericConflict("Draining is not enabled, there is no drain sink configured")
 		respondErr(w, err)
 		return
 	}
 
-	err = auth.ModACL(projectUUID, "subscriptions", urlSub, postBody.AuthUsers, refStr)
+	sink, err := subscriptions.OpenSink(cfg.DrainSink)
+	if err != nil {
+		log.Errorf("Could not open the drain sink %v, %v", cfg.DrainSink, err.Error())
+		err := APIErrGenericInternal("Could not open the drain sink")
+		respondErr(w, err)
+		return
+	}
 
+	res, err := subscriptions.Drain(r.Context(), projectUUID, results.Subscriptions[0], sink, cfg.Now(), refStr, refBrk)
 	if err != nil {
+		log.Errorf("Could not drain subscription %v, exported %v messages up to offset %v, %v", urlVars["subscription"], res.Messages, res.NewOffset, err.Error())
+		err := APIErrGenericInternal("Could not drain the subscription")
+		respondErr(w, err)
+		return
+	}
 
-		if err.Error() == "not found" {
-			err := APIErrorNotFound("Subscription")
-			respondErr(w, err)
-			return
-		}
-		err := APIErrGenericInternal(err.Error())
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
 		respondErr(w, err)
 		return
 	}
 
+	// Write response
+	output = []byte(resJSON)
 	respondOK(w, output)
 }
 
-// SubModPush (POST) modifies the push configuration
-func SubModPush(w http.ResponseWriter, r *http.Request) {
+// SubDelete (DEL) deletes an existing subscription
+func SubDelete(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -527,33 +782,204 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
-	subName := urlVars["subscription"]
-
-	// Get project UUID First to use as reference
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
+	// Get Result Object
+	results, err := subscriptions.Find(projectUUID, "", urlVars["subscription"], "", 0, refStr)
 	if err != nil {
-		err := APIErrorInvalidRequestBody()
+		err := APIErrGenericBackend()
 		respondErr(w, err)
 		return
 	}
 
-	// Parse pull options
-	postBody, err := subscriptions.GetFromJSON(body)
-	if err != nil {
-		err := APIErrorInvalidArgument("Subscription")
+	// If not found
+	if results.Empty() {
+		if ignoreNotFound(r) {
+			respondOK(w, output)
+			return
+		}
+		err := APIErrorNotFound("Subscription")
 		respondErr(w, err)
 		return
 	}
 
-	// Get Result Object
-	res, err := subscriptions.Find(projectUUID, "", subName, "", 0, refStr)
-
+	err = subscriptions.RemoveSub(projectUUID, urlVars["subscription"], refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			if ignoreNotFound(r) {
+				respondOK(w, output)
+				return
+			}
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	emitEvent(r, events.SubscriptionDeleted, "subscriptions/"+urlVars["subscription"])
+
+	// if it is a push sub and it is also has a verified push endpoint, deactivate it
+	if !results.Subscriptions[0].PushCfg.IsEmpty() {
+		if results.Subscriptions[0].PushCfg.Verified {
+			pr := make(map[string]string)
+			pr["message"] = deactivatePush(r, results.Subscriptions[0])
+			b, _ := json.Marshal(pr)
+			output = b
+		}
+	}
+	respondOK(w, output)
+}
+
+// SubModACL (POST) modifies the ACL
+func SubModACL(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	// Get Result Object
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Parse pull options
+	postBody, err := auth.GetACLFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Subscription ACL")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// check if user list contain valid users for the given project
+	_, err = auth.AreValidUsers(projectUUID, postBody.AuthUsers, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(w, err)
+		return
+	}
+
+	// a validate only request reports what would change without persisting anything
+	if r.URL.Query().Get("validateOnly") == "true" {
+		diff, err := auth.DiffACL(projectUUID, "subscriptions", urlSub, postBody.AuthUsers, refStr)
+		if err != nil {
+			if err.Error() == "not found" {
+				err := APIErrorNotFound("Subscription")
+				respondErr(w, err)
+				return
+			}
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+
+		resJSON, err := exportJSON(r, &diff)
+		if err != nil {
+			err := APIErrExportJSON()
+			respondErr(w, err)
+			return
+		}
+
+		respondOK(w, []byte(resJSON))
+		return
+	}
+
+	// a modification based on a stale read of the acl would silently undo the changes made since
+	version, err := aclIfMatch(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	err = auth.ModACLVersion(projectUUID, "subscriptions", urlSub, postBody.AuthUsers, version, refUserUUID, refStr)
+
+	if err != nil {
+
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		if err == auth.ErrACLVersionConflict {
+			err := APIErrorGenericConflict("The subscription's acl has been modified since it was read, read it again and retry")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubModPush (POST) modifies the push configuration
+func SubModPush(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	subName := urlVars["subscription"]
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Parse pull options
+	body = importJSON(r, body, subscriptions.Subscription{})
+	postBody, err := subscriptions.GetFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	// Get Result Object
+	res, err := subscriptions.Find(projectUUID, "", subName, "", 0, refStr)
+
 	if err != nil {
 		err := APIErrGenericBackend()
 		respondErr(w, err)
@@ -576,10 +1002,14 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 	authzType := subscriptions.AutoGenerationAuthorizationHeader
 	authzHeaderValue := ""
 	maxMessages := int64(0)
+	fanout := []string(nil)
+	maxConcurrentDeliveries := 0
+	maxRetryDuration := 0
+	maxRetries := 0
 	pushWorker := auth.User{}
 	pwToken := gorillaContext.Get(r, "push_worker_token").(string)
 
-	if postBody.PushCfg != (subscriptions.PushConfig{}) {
+	if !postBody.PushCfg.IsEmpty() {
 
 		pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
 
@@ -598,13 +1028,41 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 		}
 
 		pushEnd = postBody.PushCfg.Pend
-		// Check if push endpoint is not a valid https:// endpoint
-		if !(validation.IsValidHTTPS(pushEnd)) {
+		// Check if push endpoint is not a valid https:// endpoint, or an endpoint over a scheme the service has a transport for
+		if !subscriptions.IsValidPushEndpoint(pushEnd) {
 			err := APIErrorInvalidData("Push endpoint should be addressed by a valid https url")
 			respondErr(w, err)
 			return
 		}
 
+		fanout = postBody.PushCfg.Fanout
+		if !subscriptions.ValidFanout(pushEnd, fanout) {
+			err := APIErrorInvalidData(subscriptions.InvalidFanoutEndpointsError)
+			respondErr(w, err)
+			return
+		}
+
+		if !postBody.PushCfg.ValidMaxConcurrentDeliveries() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxConcurrentDeliveries)
+			respondErr(w, err)
+			return
+		}
+		maxConcurrentDeliveries = postBody.PushCfg.MaxConcurrentDeliveries
+
+		if !postBody.PushCfg.ValidMaxRetryDuration() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxRetryDuration)
+			respondErr(w, err)
+			return
+		}
+		maxRetryDuration = postBody.PushCfg.MaxRetryDuration
+
+		if !postBody.PushCfg.ValidMaxRetries() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxRetries)
+			respondErr(w, err)
+			return
+		}
+		maxRetries = postBody.PushCfg.MaxRetries
+
 		rPolicy = postBody.PushCfg.RetPol.PolicyType
 		rPeriod = postBody.PushCfg.RetPol.Period
 		maxMessages = postBody.PushCfg.MaxMessages
@@ -613,7 +1071,7 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 			rPolicy = subscriptions.LinearRetryPolicyType
 		}
 		if rPeriod <= 0 {
-			rPeriod = 3000
+			rPeriod = subscriptions.DefaultRetryPeriod
 		}
 
 		if !subscriptions.IsRetryPolicySupported(rPolicy) {
@@ -635,7 +1093,7 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 		// if the subscription was not push enabled before
 		// and no authorization_header has been specified
 		// use autogen
-		if authzType == "" && (existingSub.PushCfg == subscriptions.PushConfig{}) {
+		if authzType == "" && existingSub.PushCfg.IsEmpty() {
 			authzType = subscriptions.AutoGenerationAuthorizationHeader
 		}
 
@@ -687,10 +1145,10 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 
 	// if the request wants to transform a pull subscription to a push one
 	// we need to begin the verification process
-	if postBody.PushCfg != (subscriptions.PushConfig{}) {
+	if !postBody.PushCfg.IsEmpty() {
 
-		// if the endpoint in not the same with the old one, we need to verify it again
-		if postBody.PushCfg.Pend != existingSub.PushCfg.Pend {
+		// if the endpoints are not the same with the old ones, we need to verify them again
+		if !postBody.PushCfg.SameEndpoints(existingSub.PushCfg) {
 			vhash, err = auth.GenToken()
 			if err != nil {
 				log.Errorf("Could not generate verification hash for subscription %v, %v", urlVars["subscription"], err.Error())
@@ -705,7 +1163,10 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = subscriptions.ModSubPush(projectUUID, subName, pushEnd, authzType, authzHeaderValue, maxMessages, rPolicy, rPeriod, vhash, verified, refStr)
+	// the fanout endpoints and the delivery limits follow the push configuration,
+	// so a deactivation clears them as well
+	err = subscriptions.ModSubPush(projectUUID, subName, pushEnd, authzType, authzHeaderValue, maxMessages, rPolicy, rPeriod, vhash, verified,
+		fanout, maxConcurrentDeliveries, maxRetryDuration, maxRetries, refStr)
 
 	if err != nil {
 		if err.Error() == "not found" {
@@ -719,20 +1180,19 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// if this is an deactivate request, try to retrieve the push worker in order to remove him from the sub's acl
-	if existingSub.PushCfg != (subscriptions.PushConfig{}) && postBody.PushCfg == (subscriptions.PushConfig{}) {
+	if !existingSub.PushCfg.IsEmpty() && postBody.PushCfg.IsEmpty() {
 		pushWorker, _ = auth.GetPushWorker(pwToken, refStr)
 	}
 
 	// if the sub, was push enabled before the update and the endpoint was verified
 	// we need to deactivate it on the push server
-	if existingSub.PushCfg != (subscriptions.PushConfig{}) {
+	if !existingSub.PushCfg.IsEmpty() {
 		if existingSub.PushCfg.Verified {
 			// deactivate the subscription on the push backend
-			apsc := gorillaContext.Get(r, "apsc").(push.Client)
-			apsc.DeactivateSubscription(context.TODO(), existingSub.FullName).Result(false)
+			deactivatePush(r, existingSub)
 
 			// remove the push worker user from the sub's acl
-			err = auth.RemoveFromACL(projectUUID, "subscriptions", existingSub.Name, []string{pushWorker.Name}, refStr)
+			err = auth.RemoveFromACL(projectUUID, "subscriptions", existingSub.Name, []string{pushWorker.Name}, refUserUUID, refStr)
 			if err != nil {
 				err := APIErrGenericInternal(err.Error())
 				respondErr(w, err)
@@ -742,19 +1202,30 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 	}
 	// if the update on push configuration is not intended to stop the push functionality
 	// activate the subscription with the new values
-	if postBody.PushCfg != (subscriptions.PushConfig{}) {
+	if !postBody.PushCfg.IsEmpty() {
 
-		// reactivate only if the push endpoint hasn't changed and it wes already verified
+		// reactivate only if the push endpoints haven't changed and they were already verified
 		// otherwise we need to verify the ownership again before wee activate it
-		if postBody.PushCfg.Pend == existingSub.PushCfg.Pend && existingSub.PushCfg.Verified {
-
-			// activate the subscription on the push backend
-			apsc := gorillaContext.Get(r, "apsc").(push.Client)
-			apsc.ActivateSubscription(context.TODO(), existingSub.FullName, existingSub.FullTopic,
-				pushEnd, rPolicy, uint32(rPeriod), maxMessages, authzHeaderValue).Result(false)
+		if postBody.PushCfg.SameEndpoints(existingSub.PushCfg) && existingSub.PushCfg.Verified {
+
+			// activate the subscription on the push backend with the new values
+			activated := existingSub
+			activated.PushCfg = subscriptions.PushConfig{
+				Pend:                    pushEnd,
+				MaxMessages:             maxMessages,
+				AuthorizationHeader:     subscriptions.AuthorizationHeader{Type: authzType, Value: authzHeaderValue},
+				RetPol:                  subscriptions.RetryPolicy{PolicyType: rPolicy, Period: rPeriod},
+				VerificationHash:        vhash,
+				Verified:                verified,
+				Fanout:                  fanout,
+				MaxConcurrentDeliveries: maxConcurrentDeliveries,
+				MaxRetryDuration:        maxRetryDuration,
+				MaxRetries:              maxRetries,
+			}
+			activatePush(r, activated)
 
 			// modify the sub's acl with the push worker's uuid
-			err = auth.AppendToACL(projectUUID, "subscriptions", existingSub.Name, []string{pushWorker.Name}, refStr)
+			err = auth.AppendToACL(projectUUID, "subscriptions", existingSub.Name, []string{pushWorker.Name}, refUserUUID, refStr)
 			if err != nil {
 				err := APIErrGenericInternal(err.Error())
 				respondErr(w, err)
@@ -775,8 +1246,62 @@ func SubModPush(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
-// SubVerifyPushEndpoint (POST) verifies the ownership of a push endpoint registered in a push enabled subscription
-func SubVerifyPushEndpoint(w http.ResponseWriter, r *http.Request) {
+// activatePush starts the deliveries of a verified push subscription. The push manager of the service serves the
+// subscriptions with endpoints that the ams push server can't reach, e.g. sqs queues, the rest go to the push server
+func activatePush(r *http.Request, sub subscriptions.Subscription) string {
+
+	refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+	if refMgr.Serves(sub.PushCfg) {
+		// a pusher left from an earlier activation gets replaced, so that it picks up the new push configuration
+		deactivatePush(r, sub)
+		if err := refMgr.Add(sub.ProjectUUID, sub.Name); err != nil {
+			return err.Error()
+		}
+		if err := refMgr.Launch(sub.ProjectUUID, sub.Name); err != nil {
+			return err.Error()
+		}
+		return "Subscription " + sub.FullName + " activated"
+	}
+
+	apsc := gorillaContext.Get(r, "apsc").(push.Client)
+	return apsc.ActivateSubscription(context.TODO(), sub.FullName, sub.FullTopic, sub.PushCfg.Pend,
+		sub.PushCfg.RetPol.PolicyType, uint32(sub.PushCfg.RetPol.Period),
+		sub.PushCfg.MaxMessages, sub.PushCfg.AuthorizationHeader.Value, sub.PushCfg.Fanout,
+		uint32(sub.PushCfg.EffectiveMaxRetries()), uint32(sub.PushCfg.EffectiveMaxConcurrentDeliveries()),
+		uint32(sub.PushCfg.MaxRetryDuration)).Result(false)
+}
+
+// deactivatePush stops the deliveries of a push subscription on the push backend that serves it
+func deactivatePush(r *http.Request, sub subscriptions.Subscription) string {
+
+	refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+	if _, err := refMgr.Get(sub.ProjectUUID + "/" + sub.Name); err == nil {
+		refMgr.Stop(sub.ProjectUUID, sub.Name)
+		refMgr.Remove(sub.ProjectUUID, sub.Name)
+		return "Subscription " + sub.FullName + " deactivated"
+	}
+
+	apsc := gorillaContext.Get(r, "apsc").(push.Client)
+	return apsc.DeactivateSubscription(context.TODO(), sub.FullName).Result(false)
+}
+
+// TestPushResult reports how an endpoint of a push subscription responded to a test push
+type TestPushResult struct {
+	Endpoint   string `json:"endpoint"`
+	Delivered  bool   `json:"delivered"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMs  int64  `json:"latency_ms"`
+	Body       string `json:"body,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// TestPushResults holds the results of a test push to each endpoint of a subscription
+type TestPushResults struct {
+	Results []TestPushResult `json:"results"`
+}
+
+// SubTestPush (POST) delivers a synthetic message to the endpoints of a push subscription on demand
+func SubTestPush(w http.ResponseWriter, r *http.Request) {
 
 	// Add content type header to the response
 	contentType := "application/json"
@@ -792,30 +1317,9 @@ func SubVerifyPushEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
 
-	pwToken := gorillaContext.Get(r, "push_worker_token").(string)
-
-	pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
-
-	pushW := auth.User{}
-
-	// check the state of the push functionality
-	if !pushEnabled {
-		err := APIErrorPushConflict()
-		respondErr(w, err)
-		return
-	}
-
-	pushW, err := auth.GetPushWorker(pwToken, refStr)
-	if err != nil {
-		err := APIErrInternalPush()
-		respondErr(w, err)
-		return
-	}
-
-	// Get Result Object
 	res, err := subscriptions.Find(projectUUID, "", subName, "", 0, refStr)
-
 	if err != nil {
 		err := APIErrGenericBackend()
 		respondErr(w, err)
@@ -830,59 +1334,47 @@ func SubVerifyPushEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	sub := res.Subscriptions[0]
 
-	// check that the subscription is push enabled
-	if sub.PushCfg == (subscriptions.PushConfig{}) {
+	if sub.PushCfg.IsEmpty() {
 		err := APIErrorGenericConflict("Subscription is not in push mode")
 		respondErr(w, err)
 		return
 	}
 
-	// check that the endpoint isn't already verified
-	if sub.PushCfg.Verified {
-		err := APIErrorGenericConflict("Push endpoint is already verified")
-		respondErr(w, err)
-		return
-	}
-
-	// verify the push endpoint
-	c := new(http.Client)
-	err = subscriptions.VerifyPushEndpoint(sub, c, refStr)
-	if err != nil {
-		err := APIErrPushVerification(err.Error())
-		respondErr(w, err)
-		return
-	}
-
-	// activate the subscription on the push backend
-	apsc := gorillaContext.Get(r, "apsc").(push.Client)
-	apsc.ActivateSubscription(context.TODO(), sub.FullName, sub.FullTopic, sub.PushCfg.Pend,
-		sub.PushCfg.RetPol.PolicyType, uint32(sub.PushCfg.RetPol.Period),
-		sub.PushCfg.MaxMessages, sub.PushCfg.AuthorizationHeader.Value).Result(false)
-
-	// modify the sub's acl with the push worker's uuid
-	err = auth.AppendToACL(projectUUID, "subscriptions", sub.Name, []string{pushW.Name}, refStr)
-	if err != nil {
-		err := APIErrGenericInternal(err.Error())
-		respondErr(w, err)
-		return
+	results := TestPushResults{Results: []TestPushResult{}}
+	for _, report := range refMgr.TestPush(sub, time.Now().UTC()) {
+		result := TestPushResult{
+			Endpoint:   report.Endpoint,
+			Delivered:  report.Err == nil,
+			StatusCode: report.StatusCode,
+			LatencyMs:  report.Latency.Milliseconds(),
+			Body:       report.Body,
+		}
+		if report.Err != nil {
+			result.Error = report.Err.Error()
+		}
+		results.Results = append(results.Results, result)
 	}
 
-	// link the sub's project with the push worker
-	err = auth.AppendToUserProjects(pushW.UUID, projectUUID, refStr)
+	output, err := json.MarshalIndent(results, "", "   ")
 	if err != nil {
-		err := APIErrGenericInternal(err.Error())
+		err := APIErrExportJSON()
 		respondErr(w, err)
 		return
 	}
 
-	respondOK(w, []byte{})
+	respondOK(w, output)
 }
 
-// SubModAck (POST) modifies the Ack deadline of the subscription
-func SubModAck(w http.ResponseWriter, r *http.Request) {
+// NextRetryInfo reports when the failed deliveries of a push subscription are attempted again
+type NextRetryInfo struct {
+	// NextRetry is null while the subscription isn't backing off any of its endpoints
+	NextRetry           *string                   `json:"nextRetry"`
+	ConsecutiveFailures int                       `json:"consecutiveFailures"`
+	RetryPolicy         subscriptions.RetryPolicy `json:"retryPolicy"`
+}
 
-	// Init output
-	output := []byte("")
+// SubNextRetry (GET) reports when a push subscription that is backing off its endpoints retries its deliveries
+func SubNextRetry(w http.ResponseWriter, r *http.Request) {
 
 	// Add content type header to the response
 	contentType := "application/json"
@@ -891,11 +1383,198 @@ func SubModAck(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
-	// Get Result Object
-	urlSub := urlVars["subscription"]
+	subName := urlVars["subscription"]
 
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+
+	res, err := subscriptions.Find(projectUUID, "", subName, "", 0, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	if res.Empty() {
+		err := APIErrorNotFound("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	sub := res.Subscriptions[0]
+
+	if sub.PushCfg.IsEmpty() {
+		err := APIErrorGenericConflict("Subscription is not in push mode")
+		respondErr(w, err)
+		return
+	}
+
+	info := NextRetryInfo{RetryPolicy: sub.PushCfg.RetPol}
+
+	if refMgr.Serves(sub.PushCfg) {
+		// subscriptions without a pusher of the service aren't retrying anything
+		if rs, err := refMgr.RetryStatus(projectUUID, subName); err == nil && !rs.NextRetry.IsZero() {
+			nextRetry := timestamp.FormatNano(rs.NextRetry)
+			info.NextRetry = &nextRetry
+			info.ConsecutiveFailures = rs.Failures
+		}
+	} else {
+		// the rest of the subscriptions back off on the ams push server
+		apsc := gorillaContext.Get(r, "apsc").(push.Client)
+		rs, err := apsc.RetryStatus(context.TODO(), sub.FullName)
+		if err != nil {
+			log.Errorf("Couldn't retrieve the retry status of subscription %v from the push server, %v", sub.FullName, err.Error())
+			err := APIErrInternalPush()
+			respondErr(w, err)
+			return
+		}
+		if !rs.NextRetry.IsZero() {
+			nextRetry := timestamp.FormatNano(rs.NextRetry)
+			info.NextRetry = &nextRetry
+			info.ConsecutiveFailures = rs.Failures
+		}
+	}
+
+	output, err := json.MarshalIndent(info, "", "   ")
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubVerifyPushEndpoint (POST) verifies the ownership of a push endpoint registered in a push enabled subscription
+func SubVerifyPushEndpoint(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	subName := urlVars["subscription"]
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+
+	pwToken := gorillaContext.Get(r, "push_worker_token").(string)
+
+	pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+
+	pushW := auth.User{}
+
+	// check the state of the push functionality
+	if !pushEnabled {
+		err := APIErrorPushConflict()
+		respondErr(w, err)
+		return
+	}
+
+	pushW, err := auth.GetPushWorker(pwToken, refStr)
+	if err != nil {
+		err := APIErrInternalPush()
+		respondErr(w, err)
+		return
+	}
+
+	// Get Result Object
+	res, err := subscriptions.Find(projectUUID, "", subName, "", 0, refStr)
+
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	if res.Empty() {
+		err := APIErrorNotFound("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	sub := res.Subscriptions[0]
+
+	// check that the subscription is push enabled
+	if sub.PushCfg.IsEmpty() {
+		err := APIErrorGenericConflict("Subscription is not in push mode")
+		respondErr(w, err)
+		return
+	}
+
+	// check that the endpoint isn't already verified
+	if sub.PushCfg.Verified {
+		err := APIErrorGenericConflict("Push endpoint is already verified")
+		respondErr(w, err)
+		return
+	}
+
+	// verify the push endpoint
+	c := new(http.Client)
+	refMgr := gorillaContext.Get(r, "mgr").(*oldPush.Manager)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	// queues are delivered to with the credentials of the service, so a project can only verify the queues granted to it
+	verifier := oldPush.SQSAllowList{
+		Allowed:  oldPush.ParseSQSAllowedQueues(cfg.PushSQSAllowedQueues)[urlVars["project"]],
+		Verifier: refMgr,
+	}
+	err = subscriptions.VerifyPushEndpoint(r.Context(), sub, c, verifier, refStr)
+	if err != nil {
+		err := APIErrPushVerification(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// activate the subscription on the push backend
+	activatePush(r, sub)
+
+	// modify the sub's acl with the push worker's uuid
+	err = auth.AppendToACL(projectUUID, "subscriptions", sub.Name, []string{pushW.Name}, refUserUUID, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// link the sub's project with the push worker
+	err = auth.AppendToUserProjects(pushW.UUID, projectUUID, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, []byte{})
+}
+
+// SubModAck (POST) modifies the Ack deadline of the subscription
+func SubModAck(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	// Get Result Object
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		err := APIErrorInvalidRequestBody()
 		respondErr(w, err)
@@ -903,50 +1582,670 @@ func SubModAck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pull options
+	body = importJSON(r, body, subscriptions.AckDeadline{})
 	postBody, err := subscriptions.GetAckDeadlineFromJSON(body)
 	if err != nil {
-		err := APIErrorInvalidArgument("ackDeadlineSeconds(needs value between 0 and 600)")
+		err := APIErrorInvalidArgument("ackDeadlineSeconds(needs value between 0 and 600)")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = subscriptions.ModAck(projectUUID, urlSub, postBody.AckDeadline, refStr)
+
+	if err != nil {
+		if err.Error() == "wrong value" {
+			respondErr(w, APIErrorInvalidArgument("ackDeadlineSeconds(needs value between 0 and 600)"))
+			return
+		}
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubModMaxConcurrentPulls (POST) modifies the number of pulls allowed to proceed at once on a subscription
+func SubModMaxConcurrentPulls(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	body = importJSON(r, body, subscriptions.MaxConcurrentPulls{})
+	postBody, err := subscriptions.GetMaxConcurrentPullsFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("maxConcurrentPulls")
+		respondErr(w, err)
+		return
+	}
+
+	sub := subscriptions.Subscription{MaxConcurrentPulls: postBody.MaxConcurrentPulls}
+	if !sub.ValidMaxConcurrentPulls() {
+		err := APIErrorInvalidData(subscriptions.InvalidMaxConcurrentPulls)
+		respondErr(w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = subscriptions.ModSubMaxConcurrentPulls(projectUUID, urlSub, postBody.MaxConcurrentPulls, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubModDefaultMaxMessages (POST) modifies the number of messages returned by the pulls of a subscription that don't declare maxMessages
+func SubModDefaultMaxMessages(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	body = importJSON(r, body, subscriptions.SubDefaultMaxMessages{})
+	postBody, err := subscriptions.GetDefaultMaxMessagesFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("defaultMaxMessages")
+		respondErr(w, err)
+		return
+	}
+
+	sub := subscriptions.Subscription{DefaultMaxMessages: postBody.DefaultMaxMessages}
+	if !sub.ValidDefaultMaxMessages() {
+		err := APIErrorInvalidData(subscriptions.InvalidDefaultMaxMessages)
+		respondErr(w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = subscriptions.ModSubDefaultMaxMessages(projectUUID, urlSub, postBody.DefaultMaxMessages, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubModIsolationLevel (POST) modifies the broker isolation level a subscription's messages are consumed with
+func SubModIsolationLevel(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	body = importJSON(r, body, subscriptions.IsolationLevel{})
+	postBody, err := subscriptions.GetIsolationLevelFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("isolationLevel")
+		respondErr(w, err)
+		return
+	}
+
+	// an empty isolation level isn't a declaration, the default one has to be declared explicitly
+	if !brokers.ValidIsolationLevel(postBody.IsolationLevel) {
+		err := APIErrorInvalidData(subscriptions.InvalidIsolationLevel)
+		respondErr(w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	if !refBrk.SupportsIsolationLevel(postBody.IsolationLevel) {
+		err := APIErrorInvalidData(subscriptions.UnsupportedIsolationLevel)
+		respondErr(w, err)
+		return
+	}
+
+	err = subscriptions.ModSubIsolationLevel(projectUUID, urlSub, postBody.IsolationLevel, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubResetAckedMessages (POST) sets the number of messages acknowledged through a subscription back to zero
+func SubResetAckedMessages(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err := subscriptions.ResetAckedMsgs(projectUUID, urlSub, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubUpdateLabels (POST) replaces the labels of a subscription, an empty set of labels removes them
+func SubUpdateLabels(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	postBody, err := labels.GetUpdateFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Labels")
+		respondErr(w, err)
+		return
+	}
+
+	if err := labels.Validate(postBody.Labels); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	err = subscriptions.ModSubLabels(projectUUID, urlSub, postBody.Labels, refStr)
+	if err != nil {
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// SubCreate (PUT) creates a new subscription
+func SubCreate(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Parse pull options
+	body = importJSON(r, body, subscriptions.Subscription{})
+	postBody, err := subscriptions.GetFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Subscription")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	tProject, tName, err := subscriptions.ExtractFullTopicRef(postBody.FullTopic)
+
+	if err != nil {
+		err := APIErrorInvalidName("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	if topics.HasTopic(projectUUID, tName, refStr) == false {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	// only the allow-listed transforms can be declared
+	if postBody.Transform != nil {
+		if err := postBody.Transform.Validate(); err != nil {
+			err := APIErrorInvalidData(err.Error())
+			respondErr(w, err)
+			return
+		}
+	}
+
+	if err := labels.Validate(postBody.Labels); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	if !postBody.ValidMaxConcurrentPulls() {
+		err := APIErrorInvalidData(subscriptions.InvalidMaxConcurrentPulls)
+		respondErr(w, err)
+		return
+	}
+
+	if !postBody.ValidIsolationLevel() {
+		err := APIErrorInvalidData(subscriptions.InvalidIsolationLevel)
+		respondErr(w, err)
+		return
+	}
+
+	if !postBody.ValidDefaultMaxMessages() {
+		err := APIErrorInvalidData(subscriptions.InvalidDefaultMaxMessages)
+		respondErr(w, err)
+		return
+	}
+
+	if !postBody.ValidSamplingRate() {
+		err := APIErrorInvalidData(subscriptions.InvalidSamplingRate)
+		respondErr(w, err)
+		return
+	}
+
+	if !refBrk.SupportsIsolationLevel(postBody.ReadIsolation()) {
+		err := APIErrorInvalidData(subscriptions.UnsupportedIsolationLevel)
+		respondErr(w, err)
+		return
+	}
+
+	// Get current topic offset
+	tProjectUUID := projects.GetUUIDByName(tProject, refStr)
+	fullTopic := topics.BrokerTopic(tProjectUUID, tName, refStr)
+	curOff := refBrk.GetMaxOffset(fullTopic)
+
+	pushEnd := ""
+	authzType := ""
+	authzHeaderValue := ""
+	rPolicy := ""
+	rPeriod := 0
+	maxMessages := int64(1)
+	fanout := []string(nil)
+	maxConcurrentDeliveries := 0
+	maxRetryDuration := 0
+	maxRetries := 0
+
+	//pushWorker := auth.User{}
+	verifyHash := ""
+
+	if !postBody.PushCfg.IsEmpty() {
+
+		// check the state of the push functionality
+		pwToken := gorillaContext.Get(r, "push_worker_token").(string)
+		pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+
+		if !pushEnabled {
+			err := APIErrorPushConflict()
+			respondErr(w, err)
+			return
+		}
+
+		_, err = auth.GetPushWorker(pwToken, refStr)
+		if err != nil {
+			err := APIErrInternalPush()
+			respondErr(w, err)
+			return
+		}
+
+		pushEnd = postBody.PushCfg.Pend
+		// Check if push endpoint is not a valid https:// endpoint, or an endpoint over a scheme the service has a transport for
+		if !subscriptions.IsValidPushEndpoint(pushEnd) {
+			err := APIErrorInvalidData("Push endpoint should be addressed by a valid https url")
+			respondErr(w, err)
+			return
+		}
+
+		fanout = postBody.PushCfg.Fanout
+		if !subscriptions.ValidFanout(pushEnd, fanout) {
+			err := APIErrorInvalidData(subscriptions.InvalidFanoutEndpointsError)
+			respondErr(w, err)
+			return
+		}
+
+		if !postBody.PushCfg.ValidMaxConcurrentDeliveries() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxConcurrentDeliveries)
+			respondErr(w, err)
+			return
+		}
+		maxConcurrentDeliveries = postBody.PushCfg.MaxConcurrentDeliveries
+
+		if !postBody.PushCfg.ValidMaxRetryDuration() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxRetryDuration)
+			respondErr(w, err)
+			return
+		}
+		maxRetryDuration = postBody.PushCfg.MaxRetryDuration
+
+		if !postBody.PushCfg.ValidMaxRetries() {
+			err := APIErrorInvalidData(subscriptions.InvalidMaxRetries)
+			respondErr(w, err)
+			return
+		}
+		maxRetries = postBody.PushCfg.MaxRetries
+		rPolicy = postBody.PushCfg.RetPol.PolicyType
+		rPeriod = postBody.PushCfg.RetPol.Period
+		maxMessages = postBody.PushCfg.MaxMessages
+
+		authzType = postBody.PushCfg.AuthorizationHeader.Type
+		if authzType == "" {
+			authzType = subscriptions.AutoGenerationAuthorizationHeader
+		}
+
+		if !subscriptions.IsAuthorizationHeaderTypeSupported(authzType) {
+			err := APIErrorInvalidData(subscriptions.UnSupportedAuthorizationHeader)
+			respondErr(w, err)
+			return
+		}
+
+		switch authzType {
+		case subscriptions.AutoGenerationAuthorizationHeader:
+			authzHeaderValue, err = auth.GenToken()
+			if err != nil {
+				log.Errorf("Could not generate authorization header for subscription %v, %v", urlVars["subscription"], err.Error())
+				err := APIErrGenericInternal("Could not generate authorization header")
+				respondErr(w, err)
+				return
+			}
+		case subscriptions.DisabledAuthorizationHeader:
+			authzHeaderValue = ""
+		}
+
+		if rPolicy == "" {
+			rPolicy = subscriptions.LinearRetryPolicyType
+		}
+
+		if maxMessages == 0 {
+			maxMessages = int64(1)
+		}
+
+		if rPeriod <= 0 {
+			rPeriod = subscriptions.DefaultRetryPeriod
+		}
+
+		if !subscriptions.IsRetryPolicySupported(rPolicy) {
+			err := APIErrorInvalidData(subscriptions.UnSupportedRetryPolicyError)
+			respondErr(w, err)
+			return
+		}
+
+		verifyHash, err = auth.GenToken()
+		if err != nil {
+			log.Errorf("Could not generate verification hash for subscription %v, %v", urlVars["subscription"], err.Error())
+			err := APIErrGenericInternal("Could not generate verification hash")
+			respondErr(w, err)
+			return
+		}
+
+	}
+
+	created := time.Now().UTC()
+
+	settings := subscriptions.Settings{
+		Fanout:                  fanout,
+		MaxConcurrentDeliveries: maxConcurrentDeliveries,
+		MaxRetryDuration:        maxRetryDuration,
+		MaxRetries:              maxRetries,
+		Transform:               postBody.Transform,
+		NewMessagesOnly:         postBody.NewMessagesOnly,
+		Deduplicate:             postBody.Deduplicate,
+		Prioritize:              postBody.Prioritize,
+		AtMostOnce:              postBody.AtMostOnce,
+		IsolationLevel:          postBody.IsolationLevel,
+		DefaultMaxMessages:      postBody.DefaultMaxMessages,
+		MaxConcurrentPulls:      postBody.MaxConcurrentPulls,
+		Labels:                  postBody.Labels,
+	}
+	if postBody.Sampled() {
+		settings.SamplingRate = *postBody.SamplingRate
+	}
+
+	// Get Result Object
+	res, err := subscriptions.CreateSub(projectUUID, urlVars["subscription"], tName, pushEnd, curOff, maxMessages, authzType, authzHeaderValue, postBody.Ack, rPolicy, rPeriod, verifyHash, false, created, settings, refStr)
+
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorConflict("Subscription")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// the users of the project's default acl are granted access to the new subscription
+	err = auth.ApplyDefaultACL(projectUUID, "subscriptions", urlVars["subscription"], refUserUUID, refStr)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	emitEvent(r, events.SubscriptionCreated, "subscriptions/"+urlVars["subscription"])
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+
+}
+
+// SubReplay (POST) creates a new subscription on the same topic as an existing one,
+// starting from a given offset or timestamp
+func SubReplay(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Parse replay options
+	body = importJSON(r, body, subscriptions.ReplayOptions{})
+	postBody, err := subscriptions.GetReplayOptionsJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Replay options")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	copySub(w, r, postBody)
+}
+
+// SubClone (POST) creates a new subscription with the configuration and acl of an existing one,
+// starting from the source's current offset unless an offset or timestamp is given
+func SubClone(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
 		respondErr(w, err)
-		log.Error(string(body[:]))
 		return
 	}
 
-	// Grab context references
-	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	// Get project UUID First to use as reference
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
-
-	err = subscriptions.ModAck(projectUUID, urlSub, postBody.AckDeadline, refStr)
-
+	// Parse clone options
+	body = importJSON(r, body, subscriptions.CloneOptions{})
+	postBody, err := subscriptions.GetCloneOptionsJSON(body)
 	if err != nil {
-		if err.Error() == "wrong value" {
-			respondErr(w, APIErrorInvalidArgument("ackDeadlineSeconds(needs value between 0 and 600)"))
-			return
-		}
-		if err.Error() == "not found" {
-			err := APIErrorNotFound("Subscription")
-			respondErr(w, err)
-			return
-		}
-		err := APIErrGenericInternal(err.Error())
+		err := APIErrorInvalidArgument("Clone options")
 		respondErr(w, err)
+		log.Error(string(body[:]))
 		return
 	}
 
-	respondOK(w, output)
+	copySub(w, r, subscriptions.ReplayOptions{
+		Subscription:   postBody.Subscription,
+		Offset:         postBody.Offset,
+		Timestamp:      postBody.Timestamp,
+		CopyACL:        true,
+		CopyPushConfig: true,
+	})
 }
 
-// SubCreate (PUT) creates a new subscription
-func SubCreate(w http.ResponseWriter, r *http.Request) {
+// copySub creates the subscription described by the options on the topic of the subscription in the request's path,
+// copying its settings. Without an offset or a timestamp the new subscription starts from the source's current offset
+func copySub(w http.ResponseWriter, r *http.Request, postBody subscriptions.ReplayOptions) {
 
 	// Init output
 	output := []byte("")
 
-	// Add content type header to the response
-	contentType := "application/json"
-	charset := "utf-8"
-	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
-
 	// Grab url path variables
 	urlVars := mux.Vars(r)
 
@@ -955,139 +2254,139 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		err := APIErrorInvalidRequestBody()
+	if !validation.ValidName(postBody.Subscription) {
+		err := APIErrorInvalidName("Subscription")
 		respondErr(w, err)
 		return
 	}
 
-	// Parse pull options
-	postBody, err := subscriptions.GetFromJSON(body)
+	// Find the source subscription
+	results, err := subscriptions.Find(projectUUID, "", urlVars["subscription"], "", 0, refStr)
 	if err != nil {
-		err := APIErrorInvalidArgument("Subscription")
+		err := APIErrGenericBackend()
 		respondErr(w, err)
-		log.Error(string(body[:]))
 		return
 	}
 
-	tProject, tName, err := subscriptions.ExtractFullTopicRef(postBody.FullTopic)
-
-	if err != nil {
-		err := APIErrorInvalidName("Topic")
+	if results.Empty() {
+		err := APIErrorNotFound("Subscription")
 		respondErr(w, err)
 		return
 	}
 
-	if topics.HasTopic(projectUUID, tName, refStr) == false {
+	srcSub := results.Subscriptions[0]
+
+	if !topics.HasTopic(projectUUID, srcSub.Topic, refStr) {
 		err := APIErrorNotFound("Topic")
 		respondErr(w, err)
 		return
 	}
 
-	// Get current topic offset
-	tProjectUUID := projects.GetUUIDByName(tProject, refStr)
-	fullTopic := tProjectUUID + "." + tName
-	curOff := refBrk.GetMaxOffset(fullTopic)
-
-	pushEnd := ""
-	authzType := ""
-	authzHeaderValue := ""
-	rPolicy := ""
-	rPeriod := 0
-	maxMessages := int64(1)
-
-	//pushWorker := auth.User{}
-	verifyHash := ""
-
-	if postBody.PushCfg != (subscriptions.PushConfig{}) {
-
-		// check the state of the push functionality
-		pwToken := gorillaContext.Get(r, "push_worker_token").(string)
-		pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+	// Resolve the starting position of the new subscription
+	brkTopic := srcSub.BrokerTopic
+	startOff := srcSub.Offset
 
-		if !pushEnabled {
-			err := APIErrorPushConflict()
+	if postBody.Offset != nil {
+		startOff = *postBody.Offset
+		if startOff < refBrk.GetMinOffset(brkTopic) || startOff > refBrk.GetMaxOffset(brkTopic) {
+			err := APIErrorInvalidData("Offset out of bounds")
 			respondErr(w, err)
 			return
 		}
-
-		_, err = auth.GetPushWorker(pwToken, refStr)
+	} else if postBody.Timestamp != "" {
+		t, err := time.Parse("2006-01-02T15:04:05.000Z", postBody.Timestamp)
 		if err != nil {
-			err := APIErrInternalPush()
+			err := APIErrorInvalidData("Timestamp is not in valid Zulu format.")
 			respondErr(w, err)
 			return
 		}
 
-		pushEnd = postBody.PushCfg.Pend
-		// Check if push endpoint is not a valid https:// endpoint
-		if !(validation.IsValidHTTPS(pushEnd)) {
-			err := APIErrorInvalidData("Push endpoint should be addressed by a valid https url")
+		startOff, err = topics.OffsetAt(projectUUID, srcSub.Topic, t, refStr, refBrk)
+		if err != nil {
+			log.Errorf(err.Error())
+			err := APIErrGenericBackend()
 			respondErr(w, err)
 			return
 		}
-		rPolicy = postBody.PushCfg.RetPol.PolicyType
-		rPeriod = postBody.PushCfg.RetPol.Period
-		maxMessages = postBody.PushCfg.MaxMessages
 
-		authzType = postBody.PushCfg.AuthorizationHeader.Type
-		if authzType == "" {
-			authzType = subscriptions.AutoGenerationAuthorizationHeader
+		if startOff < 0 {
+			err := APIErrorGenericConflict("Timestamp is out of bounds for the subscription's topic/partition")
+			respondErr(w, err)
+			return
 		}
+	}
 
-		if !subscriptions.IsAuthorizationHeaderTypeSupported(authzType) {
-			err := APIErrorInvalidData(subscriptions.UnSupportedAuthorizationHeader)
+	pushEnd := ""
+	authzType := ""
+	authzHeaderValue := ""
+	rPolicy := ""
+	rPeriod := 0
+	maxMessages := int64(1)
+	verifyHash := ""
+
+	// the copied push configuration has to be verified again
+	// since the new subscription gets its own verification hash and authorization header
+	if postBody.CopyPushConfig && srcSub.PushCfg.Pend != "" {
+
+		pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+		if !pushEnabled {
+			err := APIErrorPushConflict()
 			respondErr(w, err)
 			return
 		}
 
-		switch authzType {
-		case subscriptions.AutoGenerationAuthorizationHeader:
+		pushEnd = srcSub.PushCfg.Pend
+		maxMessages = srcSub.PushCfg.MaxMessages
+		rPolicy = srcSub.PushCfg.RetPol.PolicyType
+		rPeriod = srcSub.PushCfg.RetPol.Period
+		authzType = srcSub.PushCfg.AuthorizationHeader.Type
+
+		if authzType == subscriptions.AutoGenerationAuthorizationHeader {
 			authzHeaderValue, err = auth.GenToken()
 			if err != nil {
-				log.Errorf("Could not generate authorization header for subscription %v, %v", urlVars["subscription"], err.Error())
+				log.Errorf("Could not generate authorization header for subscription %v, %v", postBody.Subscription, err.Error())
 				err := APIErrGenericInternal("Could not generate authorization header")
 				respondErr(w, err)
 				return
 			}
-		case subscriptions.DisabledAuthorizationHeader:
-			authzHeaderValue = ""
-		}
-
-		if rPolicy == "" {
-			rPolicy = subscriptions.LinearRetryPolicyType
-		}
-
-		if maxMessages == 0 {
-			maxMessages = int64(1)
-		}
-
-		if rPeriod <= 0 {
-			rPeriod = 3000
-		}
-
-		if !subscriptions.IsRetryPolicySupported(rPolicy) {
-			err := APIErrorInvalidData(subscriptions.UnSupportedRetryPolicyError)
-			respondErr(w, err)
-			return
 		}
 
 		verifyHash, err = auth.GenToken()
 		if err != nil {
-			log.Errorf("Could not generate verification hash for subscription %v, %v", urlVars["subscription"], err.Error())
+			log.Errorf("Could not generate verification hash for subscription %v, %v", postBody.Subscription, err.Error())
 			err := APIErrGenericInternal("Could not generate verification hash")
 			respondErr(w, err)
 			return
 		}
-
 	}
 
 	created := time.Now().UTC()
 
-	// Get Result Object
-	res, err := subscriptions.CreateSub(projectUUID, urlVars["subscription"], tName, pushEnd, curOff, maxMessages, authzType, authzHeaderValue, postBody.Ack, rPolicy, rPeriod, verifyHash, false, created, refStr)
+	// the messages are delivered the same way as in the source subscription,
+	// while the copy starts without any acknowledged ids, so replayed messages get delivered again
+	settings := subscriptions.Settings{
+		Transform:          srcSub.Transform,
+		Deduplicate:        srcSub.Deduplicate,
+		Prioritize:         srcSub.Prioritize,
+		AtMostOnce:         srcSub.AtMostOnce,
+		IsolationLevel:     srcSub.IsolationLevel,
+		DefaultMaxMessages: srcSub.DefaultMaxMessages,
+		MaxConcurrentPulls: srcSub.MaxConcurrentPulls,
+		Labels:             srcSub.Labels,
+	}
+	if srcSub.Sampled() {
+		settings.SamplingRate = *srcSub.SamplingRate
+	}
+	if len(pushEnd) > 0 {
+		settings.Fanout = srcSub.PushCfg.Fanout
+		settings.MaxRetryDuration = srcSub.PushCfg.MaxRetryDuration
+		settings.MaxRetries = srcSub.PushCfg.MaxRetries
+		if srcSub.PushCfg.MaxConcurrentDeliveries > subscriptions.DefaultMaxConcurrentDeliveries {
+			settings.MaxConcurrentDeliveries = srcSub.PushCfg.MaxConcurrentDeliveries
+		}
+	}
 
+	res, err := subscriptions.CreateSub(projectUUID, postBody.Subscription, srcSub.Topic, pushEnd, startOff, maxMessages, authzType, authzHeaderValue, srcSub.Ack, rPolicy, rPeriod, verifyHash, false, created, settings, refStr)
 	if err != nil {
 		if err.Error() == "exists" {
 			err := APIErrorConflict("Subscription")
@@ -1099,8 +2398,28 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if postBody.CopyACL {
+		srcACL, err := refStr.QueryACL(projectUUID, "subscriptions", srcSub.Name)
+		if err == nil && len(srcACL.ACL) > 0 {
+			err = refStr.ModACL(projectUUID, "subscriptions", postBody.Subscription, srcACL.ACL)
+			auth.InvalidateACL(projectUUID, "subscriptions", postBody.Subscription)
+			if err == nil {
+				refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+				auth.RecordACLChange(projectUUID, "subscriptions", postBody.Subscription, auth.ACLOpModify, refUserUUID, refStr)
+			}
+		}
+		if err != nil {
+			log.Errorf("Could not copy acl of subscription %v to %v, %v", srcSub.Name, postBody.Subscription, err.Error())
+			err := APIErrGenericInternal("Could not copy the subscription's acl")
+			respondErr(w, err)
+			return
+		}
+	}
+
+	emitEvent(r, events.SubscriptionCreated, "subscriptions/"+postBody.Subscription)
+
 	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -1110,7 +2429,6 @@ func SubCreate(w http.ResponseWriter, r *http.Request) {
 	// Write response
 	output = []byte(resJSON)
 	respondOK(w, output)
-
 }
 
 // SubACL (GET) one sub's authorized users
@@ -1143,25 +2461,185 @@ func SubACL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// the etag lets a modification of the acl be based on this very read
+	w.Header().Set("ETag", aclETag(res.Version))
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// SubACLHistory (GET) the history of one sub's acl changes
+func SubACLHistory(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlSub := urlVars["subscription"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	if !subscriptions.HasSub(projectUUID, urlSub, refStr) {
+		err := APIErrorNotFound("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	res, err := auth.GetACLHistory(projectUUID, "subscriptions", urlSub, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// backlogOrderLimit is the max number of subscriptions evaluated when listing them ordered by backlog
+const backlogOrderLimit = 500
+
+//SubListAll (GET) all subscriptions
+func SubListAll(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	var res subscriptions.PaginatedSubscriptions
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	roles := gorillaContext.Get(r, "auth_roles").([]string)
+
+	urlValues := r.URL.Query()
+	pageToken := urlValues.Get("pageToken")
+
+	// if this route is used by a user who only  has a consumer role
+	// return all subscriptions that he has access to
+	userUUID := ""
+	if !auth.IsProjectAdmin(roles) && !auth.IsServiceAdmin(roles) && auth.IsConsumer(roles) {
+		userUUID = gorillaContext.Get(r, "auth_user_uuid").(string)
+	}
+
+	pageSize, err := listPageSize(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	selector, err := labels.ParseSelector(urlValues.Get("labelSelector"))
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// the prefix is matched against stored names, so it can only consist of characters that are valid in a name
+	namePrefix := urlValues.Get("namePrefix")
+	if namePrefix != "" && !validation.ValidName(namePrefix) {
+		err := APIErrorInvalidData("Invalid namePrefix, it should only contain alphanumeric characters, underscores and dashes")
+		respondErr(w, err)
+		return
+	}
+
+	orderBy := urlValues.Get("orderBy")
+	if orderBy != "" && orderBy != "backlog" {
+		err := APIErrorInvalidData("Invalid orderBy value, it should be backlog")
+		respondErr(w, err)
+		return
+	}
+
+	// the push endpoints are matched against their host after the subscriptions are retrieved
+	endpointHost := urlValues.Get("endpointHost")
+
+	// ordering by backlog needs a broker call per topic, so only a bounded number of subscriptions
+	// is evaluated, and they are returned in a single page, as are the subscriptions that push to a host
+	if orderBy == "backlog" || endpointHost != "" {
+
+		var limit int32
+		if orderBy == "backlog" {
+			limit = backlogOrderLimit
+		}
+
+		if res, err = subscriptions.FindByLabels(projectUUID, userUUID, "", "", limit, selector, namePrefix, refStr); err != nil {
+			err := APIErrGenericBackend()
+			respondErr(w, err)
+			return
+		}
+
+		if endpointHost != "" {
+			res.FilterByEndpointHost(endpointHost)
+		}
+
+		if orderBy == "backlog" {
+			refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+			res.OrderByBacklog(refBrk)
+		}
+		res.NextPageToken = ""
+
+		if pageSize > 0 && len(res.Subscriptions) > pageSize {
+			res.Subscriptions = res.Subscriptions[:pageSize]
+		}
+
+	} else if res, err = subscriptions.FindByLabels(projectUUID, userUUID, "", pageToken, int32(pageSize), selector, namePrefix, refStr); err != nil {
+		err := APIErrorInvalidData("Invalid page token")
+		respondErr(w, err)
+		return
+	}
+
+	// secrets should never be exposed when listing subscriptions
+	res.MaskSecrets()
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
 		return
 	}
 
-	// Write response
+	// Write Response
 	output = []byte(resJSON)
 	respondOK(w, output)
 }
 
-//SubListAll (GET) all subscriptions
-func SubListAll(w http.ResponseWriter, r *http.Request) {
-
-	var err error
-	var strPageSize string
-	var pageSize int
-	var res subscriptions.PaginatedSubscriptions
+// SubListStalled (GET) lists the subscriptions of a project that have a backlog but made no progress for the given duration
+func SubListStalled(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -1173,37 +2651,33 @@ func SubListAll(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 	roles := gorillaContext.Get(r, "auth_roles").([]string)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 
-	urlValues := r.URL.Query()
-	pageToken := urlValues.Get("pageToken")
-	strPageSize = urlValues.Get("pageSize")
+	stalledFor, err := time.ParseDuration(r.URL.Query().Get("for"))
+	if err != nil || stalledFor <= 0 {
+		err := APIErrorInvalidData("Invalid for value, it should be a positive duration, e.g. 1h")
+		respondErr(w, err)
+		return
+	}
 
-	// if this route is used by a user who only  has a consumer role
-	// return all subscriptions that he has access to
+	// users with only a consumer role get the stalled subscriptions they have access to
 	userUUID := ""
 	if !auth.IsProjectAdmin(roles) && !auth.IsServiceAdmin(roles) && auth.IsConsumer(roles) {
 		userUUID = gorillaContext.Get(r, "auth_user_uuid").(string)
 	}
 
-	if strPageSize != "" {
-		if pageSize, err = strconv.Atoi(strPageSize); err != nil {
-			log.Errorf("Pagesize %v produced an error  while being converted to int: %v", strPageSize, err.Error())
-			err := APIErrorInvalidData("Invalid page size")
-			respondErr(w, err)
-			return
-		}
-	}
-
-	if res, err = subscriptions.Find(projectUUID, userUUID, "", pageToken, int32(pageSize), refStr); err != nil {
-		err := APIErrorInvalidData("Invalid page token")
+	res, err := subscriptions.FindStalled(projectUUID, userUUID, cfg.Now().Add(-stalledFor), refStr, refBrk)
+	if err != nil {
+		err := APIErrGenericBackend()
 		respondErr(w, err)
 		return
 	}
 
 	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -1237,6 +2711,8 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
 	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
 	pushEnabled := gorillaContext.Get(r, "push_enabled").(bool)
+	disableAutoOffsetAdvance := gorillaContext.Get(r, "disable_auto_offset_advance").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 
 	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
@@ -1256,19 +2732,18 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	}
 
 	targetSub := results.Subscriptions[0]
-	fullTopic := targetSub.ProjectUUID + "." + targetSub.Topic
+	fullTopic := targetSub.BrokerTopic
 	retImm := true
-	max := 1
 
 	// if the subscription is push enabled but push enabled is false, don't allow push worker user to consume
-	if targetSub.PushCfg != (subscriptions.PushConfig{}) && !pushEnabled && auth.IsPushWorker(refRoles) {
+	if !targetSub.PushCfg.IsEmpty() && !pushEnabled && auth.IsPushWorker(refRoles) {
 		err := APIErrorPushConflict()
 		respondErr(w, err)
 		return
 	}
 
 	// if the subscription is push enabled, allow only push worker and service_admin users to pull from it
-	if targetSub.PushCfg != (subscriptions.PushConfig{}) && !auth.IsPushWorker(refRoles) && !auth.IsServiceAdmin(refRoles) {
+	if !targetSub.PushCfg.IsEmpty() && !auth.IsPushWorker(refRoles) && !auth.IsServiceAdmin(refRoles) {
 		err := APIErrorForbidden()
 		respondErr(w, err)
 		return
@@ -1301,63 +2776,120 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Parse pull options
+	body = importJSON(r, body, subscriptions.SubPullOptions{})
 	pullInfo, err := subscriptions.GetPullOptionsJSON(body)
 	if err != nil {
-		err := APIErrorInvalidArgument("Pull Parameters")
+		err := APIErrorInvalidData(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	if pullInfo.MaxMsg != "" {
-		max, err = strconv.Atoi(pullInfo.MaxMsg)
-		if err != nil {
-			max = 1
-		}
-	}
+	// an omitted maxMessages falls back to the subscription's default, then to the service's one
+	max := pullInfo.MaxMessagesOr(targetSub.PullMaxMessages())
 
 	if pullInfo.RetImm == "false" {
 		retImm = false
 	}
 
+	retCompressed := pullInfo.RetCompressed == "true"
+
+	// only as many pulls as the subscription allows proceed at once, the rest should retry
+	lease, acquired, err := targetSub.AcquirePullLease(cfg.Now(), time.Duration(cfg.PullLeaseTTL)*time.Second, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	if !acquired {
+		err := APIErrorTooManyPulls()
+		respondRateLimited(w, err, defaultRetryAfter)
+		return
+	}
+
+	defer lease.Release(refStr)
+
 	// Init Received Message List
-	recList := messages.RecList{}
+	recList := messages.RecList{DeliverySemantics: messages.DeliveryAtLeastOnce}
+
+	// at most once subscriptions commit the offset before handing out the messages,
+	// unless offsets should only be advanced through explicit acks
+	atMostOnce := targetSub.AtMostOnce && !disableAutoOffsetAdvance
+	if atMostOnce {
+		recList.DeliverySemantics = messages.DeliveryAtMostOnce
+	}
+
+	// a pooled broker is held only while consuming the messages
+	pullBrk, releaseBrk, err := brokers.Acquire(r.Context(), refBrk)
+	if err != nil {
+		respondErr(w, APIErrorDeadlineExceeded())
+		return
+	}
 
-	msgs, err := refBrk.Consume(r.Context(), fullTopic, targetSub.Offset, retImm, int64(max))
+	msgs, err := pullBrk.Consume(r.Context(), fullTopic, targetSub.Offset, retImm, int64(max), targetSub.ReadIsolation())
 	if err != nil {
 		// If tracked offset is off
 		if err == brokers.ErrOffsetOff {
-			log.Debug("Will increment now...")
-			// Increment tracked offset to current min offset
-			targetSub.Offset = refBrk.GetMinOffset(fullTopic)
-			refStr.UpdateSubOffset(projectUUID, targetSub.Name, targetSub.Offset)
+			// Consume from the current min offset
+			targetSub.Offset = pullBrk.GetMinOffset(fullTopic)
+			// Persist the new tracked offset, unless offsets should only be advanced through explicit acks,
+			// in which case the stored offset will be updated once the consumed messages get acknowledged
+			if !disableAutoOffsetAdvance {
+				log.Debug("Will increment now...")
+				refStr.UpdateSubOffset(projectUUID, targetSub.Name, targetSub.Offset)
+				// keep track of our own offset update, so that it doesn't count as a concurrent one
+				targetSub.Version++
+			}
 			// Try again to consume
-			msgs, err = refBrk.Consume(r.Context(), fullTopic, targetSub.Offset, retImm, int64(max))
+			msgs, err = pullBrk.Consume(r.Context(), fullTopic, targetSub.Offset, retImm, int64(max), targetSub.ReadIsolation())
 			// If still error respond and return
 			if err != nil {
 				log.Errorf("Couldn't consume messages for subscription %v, %v", targetSub.FullName, err.Error())
+				releaseBrk()
 				err := APIErrGenericBackend()
 				respondErr(w, err)
 				return
 			}
 		} else {
 			log.Errorf("Couldn't consume messages for subscription %v, %v", targetSub.FullName, err.Error())
+			releaseBrk()
 			err := APIErrGenericBackend()
 			respondErr(w, err)
 			return
 		}
 	}
-	var limit int
-	limit, err = strconv.Atoi(pullInfo.MaxMsg)
+
+	// the topic's next offset tells whether messages remain beyond the consumed ones
+	maxOffset := pullBrk.GetMaxOffset(fullTopic)
+
+	releaseBrk()
+
+	// messages that have already been acknowledged are skipped by deduplicating subscriptions
+	acked, err := targetSub.AckedIDs(refStr)
 	if err != nil {
-		limit = 0
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
 	}
 
-	ackPrefix := "projects/" + urlProject + "/subscriptions/" + urlSub + ":"
+	// number of messages read from the broker, including the ones the subscription doesn't admit
+	var consumed int64
+	// number of messages at the head of the batch that the subscription doesn't admit
+	var skipped int64
+
+	// the pull time is taken before the messages are handed out, so that the deadline they carry never exceeds the one
+	// the acks are checked against
+	pullTime := cfg.Now()
+	ackDeadline := ""
+	if !atMostOnce {
+		ackDeadline = timestamp.Format(targetSub.PullAckDeadline(pullTime))
+	}
 
 	for i, msg := range msgs {
-		if limit > 0 && i >= limit {
+		if i >= max {
 			break // max messages left
 		}
+		consumed++
 		curMsg, err := messages.LoadMsgJSON([]byte(msg))
 		if err != nil {
 			err := APIErrGenericInternal("Message retrieved from broker network has invalid JSON Structure")
@@ -1366,16 +2898,62 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 		}
 		// calc the message id = message's kafka offset (read offst + msg position)
 		idOff := targetSub.Offset + int64(i)
+		if !targetSub.Admits(curMsg) || acked.Has(idOff) || !targetSub.Samples(idOff) {
+			if len(recList.RecMsgs) == 0 {
+				skipped++
+			}
+			continue
+		}
+		// compressed messages are delivered decompressed, unless the consumer asked for the compressed data
+		if !retCompressed && curMsg.IsCompressed() {
+			if err := curMsg.Decompress(cfg.MaxMessageSize); err != nil {
+				log.Errorf("Couldn't decompress message %v of subscription %v, delivering it compressed, %v", idOff, targetSub.FullName, err.Error())
+			}
+		}
+		if targetSub.Transform != nil {
+			if err := targetSub.Transform.Apply(&curMsg); err != nil {
+				log.Errorf("Couldn't transform message %v of subscription %v, delivering it as is, %v", idOff, targetSub.FullName, err.Error())
+			}
+		}
+		// the requested fields are projected from the data the subscription would otherwise deliver
+		unprojected := false
+		if len(pullInfo.Fields) > 0 {
+			unprojected = pullInfo.Fields.Apply(&curMsg) != nil
+		}
 		curMsg.ID = strconv.FormatInt(idOff, 10)
-		curRec := messages.RecMsg{AckID: ackPrefix + curMsg.ID, Msg: curMsg}
+		curRec := messages.RecMsg{AckID: subscriptions.NewAckID(urlProject, urlSub, idOff).Signed(cfg.AckIDSecret).String(), Msg: curMsg, Unprojected: unprojected, AckDeadline: ackDeadline}
 		recList.RecMsgs = append(recList.RecMsgs, curRec)
 	}
 
+	// the messages consumed after the request's deadline has passed are never handed out, so neither the offsets nor the metrics move
+	if r.Context().Err() != nil {
+		err := APIErrorDeadlineExceeded()
+		respondErr(w, err)
+		return
+	}
+
+	// messages published before the subscription's creation, already acknowledged or left out of the sample will never be delivered, so the
+	// offset moves past them even when offsets are only advanced through acks, otherwise the subscription would keep reading them
+	if skipped > 0 {
+		refStr.UpdateSubOffset(projectUUID, targetSub.Name, targetSub.Offset+skipped)
+		targetSub.Offset += skipped
+		consumed -= skipped
+		targetSub.Version++
+	}
+
+	// the priorities are only honored within the pulled batch, since the offset covers the whole of it
+	if targetSub.Prioritize {
+		recList.OrderByPriority()
+	}
+
+	recList.MessageCount = len(recList.RecMsgs)
+	recList.MoreAvailable = int64(len(msgs)) > consumed+skipped || maxOffset > targetSub.Offset+consumed
+
 	// amount of messages consumed
 	msgCount := int64(len(msgs))
 
 	// consumption time
-	consumeTime := time.Now().UTC()
+	consumeTime := cfg.Now().UTC()
 
 	// increment subscription number of message metric
 	refStr.IncrementSubMsgNum(projectUUID, urlSub, msgCount)
@@ -1392,7 +2970,7 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 
 	refStr.UpdateSubConsumeRate(projectUUID, targetSub.Name, float64(msgCount)/dt)
 
-	resJSON, err := recList.ExportJSON()
+	resJSON, err := exportJSON(r, &recList)
 
 	if err != nil {
 		err := APIErrExportJSON()
@@ -1400,12 +2978,330 @@ func SubPull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Stamp time to UTC Z to seconds
-	zSec := "2006-01-02T15:04:05Z"
-	t := time.Now().UTC()
-	ts := t.Format(zSec)
-	refStr.UpdateSubPull(targetSub.ProjectUUID, targetSub.Name, int64(len(recList.RecMsgs))+targetSub.Offset, ts)
+	if atMostOnce {
+		err = refStr.UpdateSubPullCommit(targetSub.ProjectUUID, targetSub.Name, consumed+targetSub.Offset, targetSub.Version)
+		if err != nil {
+			if err.Error() == "conflict" {
+				err := APIErrorGenericConflict("Subscription offsets were modified concurrently, please retry")
+				respondErr(w, err)
+				return
+			}
+			err := APIErrGenericBackend()
+			respondErr(w, err)
+			return
+		}
+
+		if consumed > 0 {
+			refStr.UpdateSubLastProgress(projectUUID, targetSub.Name, consumeTime)
+			if err := targetSub.RecordAcked(targetSub.Offset, targetSub.Offset+consumed-1, cfg.DedupWindow, refStr); err != nil {
+				log.Errorf("Couldn't record the acknowledged message ids of subscription %v, %v", targetSub.FullName, err.Error())
+			}
+		}
+
+		output = []byte(resJSON)
+		respondOK(w, output)
+		return
+	}
+
+	// Stamp the pull time in UTC with sub-second precision
+	ts := timestamp.FormatNano(pullTime)
+	err = refStr.UpdateSubPull(targetSub.ProjectUUID, targetSub.Name, consumed+targetSub.Offset, ts, targetSub.Version)
+	// another pull or an ack moved the offsets while consuming, the consumed messages are not handed out
+	// so that they don't get tracked against stale offsets, and the client should retry
+	if err != nil && err.Error() == "conflict" {
+		err := APIErrorGenericConflict("Subscription offsets were modified concurrently, please retry")
+		respondErr(w, err)
+		return
+	}
 
 	output = []byte(resJSON)
 	respondOK(w, output)
 }
+
+// sseHeartbeatInterval is how often a comment line is sent to keep an idle event stream alive
+var sseHeartbeatInterval = 15 * time.Second
+
+// ssePollInterval is how long an event stream waits before polling the broker again when no messages are available
+var ssePollInterval = time.Second
+
+// sseMaxBatch is the maximum number of messages consumed from the broker in a single poll of an event stream
+const sseMaxBatch = 100
+
+// SubStream (GET) streams the messages of a subscription as server-sent events
+func SubStream(w http.ResponseWriter, r *http.Request) {
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	urlProject := urlVars["project"]
+	urlSub := urlVars["subscription"]
+
+	// Grab context references
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	disableAutoOffsetAdvance := gorillaContext.Get(r, "disable_auto_offset_advance").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// errors are still reported as json, before the stream gets established
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		err := APIErrGenericInternal("Streaming is not supported")
+		respondErr(w, err)
+		return
+	}
+
+	// Get the subscription
+	results, err := subscriptions.Find(projectUUID, "", urlSub, "", 0, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	if results.Empty() {
+		err := APIErrorNotFound("Subscription")
+		respondErr(w, err)
+		return
+	}
+
+	targetSub := results.Subscriptions[0]
+	fullTopic := targetSub.BrokerTopic
+
+	// push enabled subscriptions are consumed by the push server only
+	if !targetSub.PushCfg.IsEmpty() {
+		err := APIErrorForbidden()
+		respondErr(w, err)
+		return
+	}
+
+	// Check Authorization per subscription
+	// - if enabled in config
+	// - if user has only consumer role
+	if refAuthResource && auth.IsConsumer(refRoles) {
+		if auth.PerResource(projectUUID, "subscriptions", targetSub.Name, refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
+			respondErr(w, err)
+			return
+		}
+	}
+
+	// check if the subscription's topic exists
+	if !topics.HasTopic(projectUUID, targetSub.Topic, refStr) {
+		err := APIErrorPullNoTopic()
+		respondErr(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// messages that have already been acknowledged are skipped by deduplicating subscriptions
+	acked, err := targetSub.AckedIDs(refStr)
+	if err != nil {
+		log.Errorf("Couldn't query the acknowledged message ids of subscription %v, %v", targetSub.FullName, err.Error())
+		fmt.Fprintf(w, "event: error\ndata: %v\n\n", APIErrGenericBackend().Body.Message)
+		flusher.Flush()
+		return
+	}
+
+	ctx := r.Context()
+	offset := targetSub.Offset
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	// whether the tracked offset was just moved to the broker's min offset
+	offsetReset := false
+
+	for {
+
+		msgs, err := refBrk.Consume(ctx, fullTopic, offset, true, sseMaxBatch, targetSub.ReadIsolation())
+		if err == brokers.ErrOffsetOff && !offsetReset {
+			offset = refBrk.GetMinOffset(fullTopic)
+			if !disableAutoOffsetAdvance {
+				refStr.UpdateSubOffset(projectUUID, targetSub.Name, offset)
+			}
+			offsetReset = true
+			continue
+		}
+		offsetReset = false
+
+		if err != nil {
+			// the client went away while the broker was being polled
+			if ctx.Err() != nil {
+				return
+			}
+			log.Errorf("Couldn't consume messages for subscription %v, %v", targetSub.FullName, err.Error())
+			fmt.Fprintf(w, "event: error\ndata: %v\n\n", APIErrGenericBackend().Body.Message)
+			flusher.Flush()
+			return
+		}
+
+		if len(msgs) > 0 {
+
+			recList := messages.RecList{}
+
+			for i, msg := range msgs {
+				curMsg, err := messages.LoadMsgJSON([]byte(msg))
+				if err != nil {
+					log.Errorf("Message at offset %v of subscription %v has invalid JSON Structure", offset+int64(i), targetSub.FullName)
+					continue
+				}
+				idOff := offset + int64(i)
+				if !targetSub.Admits(curMsg) || acked.Has(idOff) || !targetSub.Samples(idOff) {
+					continue
+				}
+				// events carry the same data a pull would deliver, decompressed and transformed
+				if curMsg.IsCompressed() {
+					if err := curMsg.Decompress(cfg.MaxMessageSize); err != nil {
+						log.Errorf("Couldn't decompress message %v of subscription %v, delivering it compressed, %v", idOff, targetSub.FullName, err.Error())
+					}
+				}
+				if targetSub.Transform != nil {
+					if err := targetSub.Transform.Apply(&curMsg); err != nil {
+						log.Errorf("Couldn't transform message %v of subscription %v, delivering it as is, %v", idOff, targetSub.FullName, err.Error())
+					}
+				}
+				curMsg.ID = strconv.FormatInt(idOff, 10)
+				curRec := messages.RecMsg{AckID: subscriptions.NewAckID(urlProject, urlSub, idOff).Signed(cfg.AckIDSecret).String(), Msg: curMsg}
+				data, _ := json.Marshal(curRec)
+				fmt.Fprintf(w, "id: %v\nevent: message\ndata: %s\n\n", curMsg.ID, data)
+				recList.RecMsgs = append(recList.RecMsgs, curRec)
+			}
+			flusher.Flush()
+
+			from := offset
+			offset = offset + int64(len(msgs))
+
+			// delivered events count as consumed and acknowledged,
+			// unless offsets should only move through explicit acknowledgements
+			if !disableAutoOffsetAdvance {
+				refStr.UpdateSubOffset(projectUUID, targetSub.Name, offset)
+				refStr.UpdateSubLastProgress(projectUUID, targetSub.Name, cfg.Now().UTC())
+				if err := targetSub.RecordAcked(from, offset-1, cfg.DedupWindow, refStr); err != nil {
+					log.Errorf("Couldn't record the acknowledged message ids of subscription %v, %v", targetSub.FullName, err.Error())
+				}
+			}
+
+			refStr.IncrementSubMsgNum(projectUUID, urlSub, int64(len(msgs)))
+			refStr.IncrementSubBytes(projectUUID, urlSub, recList.TotalSize())
+			refStr.UpdateSubLatestConsume(projectUUID, targetSub.Name, cfg.Now().UTC())
+
+			// keep draining while there are messages available
+			select {
+			case <-ctx.Done():
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-time.After(ssePollInterval):
+		}
+	}
+}
+
+// OffsetReconciliation (GET) returns the result of the latest reconciliation of the subscription offsets with the broker
+func OffsetReconciliation(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	res, found := subscriptions.LastReconciliation()
+	if !found {
+		err := APIErrorNotFound("Offset reconciliation")
+		respondErr(w, err)
+		return
+	}
+
+	output, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// OffsetSnapshots (GET) lists the snapshots of the subscription offsets that are kept
+func OffsetSnapshots(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	res, err := subscriptions.ListSnapshots(refStr)
+	if err != nil {
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	output, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// OffsetSnapshotRestore (POST) moves the subscriptions back to the offsets recorded by a snapshot
+func OffsetSnapshotRestore(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+
+	res, err := subscriptions.RestoreSnapshot(urlVars["snapshot"], refStr, refBrk)
+	if err != nil {
+		if err == subscriptions.ErrSnapshotNotFound {
+			err := APIErrorNotFound("Offset snapshot")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrQueryDatastore()
+		respondErr(w, err)
+		return
+	}
+
+	output, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	b64 "encoding/base64"
+	"encoding/json"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/naming"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
@@ -15,6 +22,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -194,6 +202,63 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubModPushConfigToInactive() {
 	suite.Equal([]string{"uuid2", "uuid4"}, a1.ACL)
 }
 
+// TestSubModPushConfigFanoutVerification tests that a change of the fanout endpoints
+// requires the ownership of the endpoints to be verified again
+func (suite *SubscriptionsHandlersTestSuite) TestSubModPushConfigFanoutVerification() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].PushEndpoint = "https://www.example.com"
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyPushConfig", WrapMockAuthConfig(SubModPush, cfgKafka, &brk, str, &mgr, pc))
+
+	postJSON := `{
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "fanoutEndpoints": ["https://fan.example.com"],
+		 "maxRetries": 5
+	}
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:modifyPushConfig", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	sub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(200, w.Code)
+	suite.Equal([]string{"https://fan.example.com"}, sub.FanoutEndpoints)
+	suite.Equal(5, sub.MaxRetries)
+	suite.False(sub.Verified)
+	suite.NotEqual("push-id-1", sub.VerificationHash)
+
+	// the same endpoints keep their verification
+	str.SubList[3].Verified = true
+	vhash := sub.VerificationHash
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:modifyPushConfig", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	sub, _ = str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(200, w.Code)
+	suite.True(sub.Verified)
+	suite.Equal(vhash, sub.VerificationHash)
+
+	// retries per endpoint are bounded
+	postJSON = `{
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "maxRetries": 101
+	}
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:modifyPushConfig", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidMaxRetries)
+}
+
 // TestSubModPushConfigToInactivePushDisabled tests the use case where the user modifies the push configuration
 // in order to deactivate the subscription on the push server
 // the push configuration has values before the call and turns into an empty one by the end of the call
@@ -493,6 +558,72 @@ func (suite *SubscriptionsHandlersTestSuite) TestVerifyPushEndpoint() {
 	suite.Equal([]string{"uuid7"}, a1.ACL)
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestVerifyPushEndpointSQS() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	queue := "sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"
+
+	// sqs endpoints are valid once the service has a transport for them
+	subscriptions.AllowPushScheme(oldPush.SQSScheme, oldPush.SQSHosts("eu-west-1"))
+	router := mux.NewRouter().StrictSlash(true)
+	nopMgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &nopMgr, pc))
+	postJSON := `{"topic":"projects/ARGO/topics/topic1", "pushConfig": {"pushEndpoint": "` + queue + `"}}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/push-sqs", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	sub, _ := str.QueryOneSub("argo_uuid", "push-sqs")
+	suite.Equal(queue, sub.PushEndpoint)
+	suite.False(sub.Verified)
+
+	// queues of other hosts are rejected
+	postJSON = `{"topic":"projects/ARGO/topics/topic1", "pushConfig": {"pushEndpoint": "sqs://queues.example.com/123456789012/ams"}}`
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/push-sqs-other", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+
+	sqs := oldPush.NewMockSender(false)
+	mgr := oldPush.NewManager(&brk, str, nil)
+	mgr.DelegateSchemes("http", "https")
+	mgr.RegisterTransport(oldPush.SQSScheme, sqs)
+	router = mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:verifyPushEndpoint", WrapMockAuthConfig(SubVerifyPushEndpoint, cfgKafka, &brk, str, mgr, pc))
+
+	// a queue that hasn't been granted to the project can't be verified, even if the service can deliver to it
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/push-sqs:verifyPushEndpoint", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(401, w.Code)
+	suite.Contains(w.Body.String(), "The queue is not in the allowed queues of the project")
+	suite.Equal("", sqs.LastMsg)
+	sub, _ = str.QueryOneSub("argo_uuid", "push-sqs")
+	suite.False(sub.Verified)
+
+	// once granted, the queue gets verified through the transport and the subscription is served by the push manager of the service
+	cfgKafka.PushSQSAllowedQueues = []string{"OTHER=123456789012", "ARGO=123456789012"}
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/push-sqs:verifyPushEndpoint", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	sub, _ = str.QueryOneSub("argo_uuid", "push-sqs")
+	suite.True(sub.Verified)
+	suite.True(strings.Contains(sqs.LastMsg, sub.VerificationHash))
+
+	_, err := mgr.Get("argo_uuid/push-sqs")
+	suite.Nil(err)
+	mgr.Stop("argo_uuid", "push-sqs")
+
+	// https only subscriptions are left to the push server
+	suite.False(mgr.Serves(subscriptions.PushConfig{Pend: "https://www.example.com"}))
+	suite.True(mgr.Serves(subscriptions.PushConfig{Pend: "https://www.example.com", Fanout: []string{queue}}))
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestVerifyPushEndpointHashMisMatch() {
 
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -722,6 +853,155 @@ func (suite *SubscriptionsHandlersTestSuite) TestVerifyPushEndpointNotPushEnable
 	suite.Equal(expResp, w.Body.String())
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubTestPush() {
+
+	expResp := `{
+   "results": [
+      {
+         "endpoint": "endpoint.foo",
+         "delivered": true,
+         "status_code": 200,
+         "latency_ms": 0
+      }
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	sndr := oldPush.NewMockSender(false)
+	mgr := oldPush.NewManager(&brk, str, sndr)
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:testPush", WrapMockAuthConfig(SubTestPush, cfgKafka, &brk, str, mgr, nil))
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:testPush", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+	suite.Equal("auth-header-1", sndr.LastAuthorization)
+
+	// the test delivery doesn't move the subscription's offset
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(0), qSub.Offset)
+
+	// a failing endpoint is reported as not delivered
+	sndr.FailEndpoints = map[string]bool{"endpoint.foo": true}
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:testPush", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"delivered": false`)
+	suite.Contains(w.Body.String(), `"error": "endpoint not reachable"`)
+
+	// nothing is delivered to an endpoint that hasn't been verified
+	sndr.FailEndpoints = nil
+	str.SubList[3].Verified = false
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:testPush", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"error": "endpoint has not been verified"`)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+
+	// a pull subscription can't be test pushed
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:testPush", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), "Subscription is not in push mode")
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:testPush", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubNextRetry() {
+
+	expResp := `{
+   "nextRetry": null,
+   "consecutiveFailures": 0,
+   "retryPolicy": {
+      "type": "linear",
+      "period": 300
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.NewManager(&brk, str, oldPush.NewMockSender(false))
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:nextRetry", WrapMockAuthConfig(SubNextRetry, cfgKafka, &brk, str, mgr, nil))
+
+	// a push subscription without failed deliveries isn't backing off
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:nextRetry", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// the same goes for a push subscription whose pusher was added but never launched
+	mgr.Add("argo_uuid", "sub4")
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:nextRetry", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:nextRetry", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), "Subscription is not in push mode")
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:nextRetry", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubNextRetryPushServer() {
+
+	expResp := `{
+   "nextRetry": "2020-11-19T10:15:05.123456789Z",
+   "consecutiveFailures": 3,
+   "retryPolicy": {
+      "type": "linear",
+      "period": 300
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// the push manager isn't set up, so the subscription backs off on the ams push server
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:nextRetry", WrapMockAuthConfig(SubNextRetry, cfgKafka, &brk, str, &mgr, pc))
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:nextRetry", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfig() {
 
 	postJSON := `{
@@ -740,6 +1020,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfig() {
 	expResp := `{
    "name": "/projects/ARGO/subscriptions/subNew",
    "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
    "pushConfig": {
       "pushEndpoint": "https://www.example.com",
       "maxMessages": 1,
@@ -752,7 +1033,8 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfig() {
          "period": 3000
       },
       "verification_hash": "{{VHASH}}",
-      "verified": false
+      "verified": false,
+      "maxConcurrentDeliveries": 1
    },
    "ackDeadlineSeconds": 10,
    "created_on": "{{CON}}"
@@ -799,6 +1081,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigSlowStart()
 	expResp := `{
    "name": "/projects/ARGO/subscriptions/subNew",
    "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
    "pushConfig": {
       "pushEndpoint": "https://www.example.com",
       "maxMessages": 1,
@@ -809,7 +1092,8 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigSlowStart()
          "type": "slowstart"
       },
       "verification_hash": "{{VHASH}}",
-      "verified": false
+      "verified": false,
+      "maxConcurrentDeliveries": 1
    },
    "ackDeadlineSeconds": 10,
    "created_on": "{{CON}}"
@@ -992,10 +1276,15 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigError() {
 	suite.Equal(expResp, w.Body.String())
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubCreate() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigFanout() {
 
 	postJSON := `{
-	"topic":"projects/ARGO/topics/topic1"
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "fanoutEndpoints": ["https://one.example.com", "https://two.example.com"],
+		 "retryPolicy": {}
+	}
 }`
 
 	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(postJSON)))
@@ -1006,13 +1295,25 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreate() {
 	expResp := `{
    "name": "/projects/ARGO/subscriptions/subNew",
    "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
    "pushConfig": {
-      "pushEndpoint": "",
-      "maxMessages": 0,
-      "authorization_header": {},
-      "retryPolicy": {},
-      "verification_hash": "",
-      "verified": false
+      "pushEndpoint": "https://www.example.com",
+      "maxMessages": 1,
+      "authorization_header": {
+         "type": "autogen",
+         "value": "{{AUTHZV}}"
+      },
+      "retryPolicy": {
+         "type": "linear",
+         "period": 3000
+      },
+      "verification_hash": "{{VHASH}}",
+      "verified": false,
+      "fanoutEndpoints": [
+         "https://one.example.com",
+         "https://two.example.com"
+      ],
+      "maxConcurrentDeliveries": 1
    },
    "ackDeadlineSeconds": 10,
    "created_on": "{{CON}}"
@@ -1024,480 +1325,1054 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubCreate() {
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, pc))
 	router.ServeHTTP(w, req)
 	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
-	fmt.Println(sub)
+	expResp = strings.Replace(expResp, "{{VHASH}}", sub.VerificationHash, 1)
+	expResp = strings.Replace(expResp, "{{AUTHZV}}", sub.AuthorizationHeader, 1)
 	expResp = strings.Replace(expResp, "{{CON}}", sub.CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
+	suite.Equal([]string{"https://one.example.com", "https://two.example.com"}, sub.FanoutEndpoints)
 
-}
-
-func (suite *SubscriptionsHandlersTestSuite) TestSubCreateExists() {
-
-	postJSON := `{
-	"topic":"projects/ARGO/topics/topic1"
+	// an endpoint declared twice should be rejected
+	postJSON = `{
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "fanoutEndpoints": ["https://www.example.com"]
+	}
 }`
 
-	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
-	}
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew2", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 
-	expResp := `{
+	expResp = `{
    "error": {
-      "code": 409,
-      "message": "Subscription already exists",
-      "status": "ALREADY_EXISTS"
+      "code": 400,
+      "message": "Fanout endpoints should be valid https urls, distinct from each other and from the push endpoint",
+      "status": "INVALID_ARGUMENT"
    }
 }`
-
-	cfgKafka := config.NewAPICfg()
-	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
-	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
-	router.ServeHTTP(w, req)
-	suite.Equal(409, w.Code)
+	suite.Equal(400, w.Code)
 	suite.Equal(expResp, w.Body.String())
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubCreateErrorTopic() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigMaxConcurrentDeliveries() {
 
 	postJSON := `{
-	"topic":"projects/ARGO/topics/topicFoo"
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "maxConcurrentDeliveries": 5,
+		 "retryPolicy": {}
+	}
 }`
 
-	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", bytes.NewBuffer([]byte(postJSON)))
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(postJSON)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "error": {
-      "code": 404,
-      "message": "Topic doesn't exist",
-      "status": "NOT_FOUND"
-   }
+   "name": "/projects/ARGO/subscriptions/subNew",
+   "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
+   "pushConfig": {
+      "pushEndpoint": "https://www.example.com",
+      "maxMessages": 1,
+      "authorization_header": {
+         "type": "autogen",
+         "value": "{{AUTHZV}}"
+      },
+      "retryPolicy": {
+         "type": "linear",
+         "period": 3000
+      },
+      "verification_hash": "{{VHASH}}",
+      "verified": false,
+      "maxConcurrentDeliveries": 5
+   },
+   "ackDeadlineSeconds": 10,
+   "created_on": "{{CON}}"
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, pc))
 	router.ServeHTTP(w, req)
-	suite.Equal(404, w.Code)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	expResp = strings.Replace(expResp, "{{VHASH}}", sub.VerificationHash, 1)
+	expResp = strings.Replace(expResp, "{{AUTHZV}}", sub.AuthorizationHeader, 1)
+	expResp = strings.Replace(expResp, "{{CON}}", sub.CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
+	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-}
+	suite.Equal(5, sub.MaxConcurrentDeliveries)
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubDelete() {
-
-	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
-	if err != nil {
-		log.Fatal(err)
+	// a value above the limit should be rejected
+	postJSON = `{
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "maxConcurrentDeliveries": 101
 	}
+}`
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew2", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expResp = `{
+   "error": {
+      "code": 400,
+      "message": "Max concurrent deliveries should be between 1 and 100",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreatePushConfigMaxRetryDuration() {
 
-	expResp := ""
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, pc))
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "maxRetryDuration": 3600
+	}
+}`
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(postJSON)))
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"maxRetryDuration": 3600`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal(3600, sub.MaxRetryDuration)
+
+	// messages can't be retried for longer than the default retention of a topic
+	postJSON = `{
+	"topic":"projects/ARGO/topics/topic1",
+	"pushConfig": {
+		 "pushEndpoint": "https://www.example.com",
+		 "maxRetryDuration": 604801
+	}
+}`
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew2", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Max retry duration should be between 0 and 604800 seconds",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+	suite.Equal(400, w.Code)
 	suite.Equal(expResp, w.Body.String())
+	suite.False(subscriptions.HasSub("argo_uuid", "subNew2", str))
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubWithPushConfigDelete() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreate() {
 
-	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4", nil)
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1"
+}`
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(postJSON)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{"message":"Subscription /projects/ARGO/subscriptions/sub4 deactivated"}`
+	expResp := `{
+   "name": "/projects/ARGO/subscriptions/subNew",
+   "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
+   "pushConfig": {
+      "pushEndpoint": "",
+      "maxMessages": 0,
+      "authorization_header": {},
+      "retryPolicy": {},
+      "verification_hash": "",
+      "verified": false
+   },
+   "ackDeadlineSeconds": 10,
+   "created_on": "{{CON}}"
+}`
+
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
-	pc := new(push.MockClient)
+	mgr := oldPush.Manager{}
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	fmt.Println(sub)
+	expResp = strings.Replace(expResp, "{{CON}}", sub.CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
+
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubWithPushConfigDeletePushServerError() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateJSONNaming() {
 
-	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/errorSub", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
 
-	expResp := `{"message":"Subscription /projects/ARGO/subscriptions/errorSub is not active"}`
 	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.JSONNaming = naming.SnakeCase
 	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "attributes": {"someKey": "v"},
+  "data": "aGVsbG8=",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	str.SubList = append(str.SubList, stores.QSub{
-		Name:         "errorSub",
-		ProjectUUID:  "argo_uuid",
-		PushEndpoint: "example.com",
-		// sub needs to be verified in order to perform the call to the push server
-		Verified: true,
-	})
 	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
-	pc := new(push.MockClient)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	// the body is accepted in the configured convention, while the keys of the labels are kept as they are
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"ack_deadline_seconds": 30,
+	"labels": {"teamName": "alerts"}
+}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", strings.NewReader(postJSON))
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, pc))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal(30, sub.Ack)
+	suite.Equal(map[string]string{"teamName": "alerts"}, sub.Labels)
+
+	expResp := `{
+   "name": "/projects/ARGO/subscriptions/subNew",
+   "topic": "/projects/ARGO/topics/topic1",
+   "topic_name": "topic1",
+   "push_config": {
+      "push_endpoint": "",
+      "max_messages": 0,
+      "authorization_header": {},
+      "retry_policy": {},
+      "verification_hash": "",
+      "verified": false
+   },
+   "ack_deadline_seconds": 30,
+   "created_on": "{{CON}}",
+   "labels": {
+      "teamName": "alerts"
+   }
+}`
+	suite.Equal(strings.Replace(expResp, "{{CON}}", sub.CreatedOn.Format("2006-01-02T15:04:05Z"), 1), w.Body.String())
+
+	// the pulled messages are named after the convention too, apart from their attributes
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"max_messages":"1"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "received_messages": [
+      {
+         "ack_id": "v1/projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "message_id": "0",
+            "attributes": {
+               "someKey": "v"
+            },
+            "data": "aGVsbG8=",
+            "publish_time": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ack_deadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "message_count": 1,
+   "more_available": true,
+   "delivery_semantics": "atLeastOnce"
+}`, w.Body.String())
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubGetOffsets() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateDefaultACL() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub2:offsets", nil)
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1"
+}`
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(postJSON)))
 	if err != nil {
 		log.Fatal(err)
 	}
-	expResp := `{
-   "max": 2,
-   "min": 1,
-   "current": 1
-}`
+
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateProjectDefaultACL("argo_uuid", &stores.QDefaultACL{Topics: []string{"uuid1"}, Subscriptions: []string{"uuid2"}}, time.Now())
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal([]string{"uuid2"}, str.SubsACL["subNew"].ACL)
+	history, _ := str.QueryACLHistory("argo_uuid", "subscriptions", "subNew")
+	suite.Equal(1, len(history))
+	suite.Equal("default", history[0].Operation)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateIsolationLevel() {
 
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
-	// append a msg to the broker to cause the min topic from the offset to be at 1 while the sub's current is at 0
-	brk.MsgList = append(brk.MsgList, "msg1")
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","isolationLevel":"read_uncommitted"}`)))
 	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"isolationLevel": "read_uncommitted"`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal("read_uncommitted", sub.IsolationLevel)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subInvalid", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","isolationLevel":"serializable"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidIsolationLevel)
+
+	// brokers without transactions can't serve the default isolation level
+	brk.NoTransactions = true
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subUnsupported", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.UnsupportedIsolationLevel)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subUncommitted", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","isolationLevel":"read_uncommitted"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateDefaultMaxMessages() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:offsets", WrapMockAuthConfig(SubGetOffsets, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","defaultMaxMessages":20}`)))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Contains(w.Body.String(), `"defaultMaxMessages": 20`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal(20, sub.DefaultMaxMessages)
 
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subInvalid", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","defaultMaxMessages":-5}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidDefaultMaxMessages)
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListOne() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateSamplingRate() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","samplingRate":0.25}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"samplingRate": 0.25`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal(0.25, sub.SamplingRate)
+
+	// a rate of one samples every message, like no rate at all
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subAll", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","samplingRate":1}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), "samplingRate")
+
+	for _, rate := range []string{"-0.1", "0", "0.0", "1.5"} {
+		req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subInvalid", bytes.NewBuffer([]byte(`{"topic":"projects/ARGO/topics/topic1","samplingRate":`+rate+`}`)))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(400, w.Code, rate)
+		suite.Contains(w.Body.String(), subscriptions.InvalidSamplingRate)
+	}
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateExists() {
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1"
+}`
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", bytes.NewBuffer([]byte(postJSON)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "name": "/projects/ARGO/subscriptions/sub1",
-   "topic": "/projects/ARGO/topics/topic1",
-   "pushConfig": {
-      "pushEndpoint": "",
-      "maxMessages": 0,
-      "authorization_header": {},
-      "retryPolicy": {},
-      "verification_hash": "",
-      "verified": false
-   },
-   "ackDeadlineSeconds": 10,
-   "created_on": "2020-11-19T00:00:00Z"
+   "error": {
+      "code": 409,
+      "message": "Subscription already exists",
+      "status": "ALREADY_EXISTS"
+   }
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
-	suite.Equal(200, w.Code)
+	suite.Equal(409, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAll() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateErrorTopic() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topicFoo"
+}`
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", bytes.NewBuffer([]byte(postJSON)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "subscriptions": [
-      {
-         "name": "/projects/ARGO/subscriptions/sub4",
-         "topic": "/projects/ARGO/topics/topic4",
-         "pushConfig": {
-            "pushEndpoint": "endpoint.foo",
-            "maxMessages": 1,
-            "authorization_header": {
-               "type": "autogen",
-               "value": "auth-header-1"
-            },
-            "retryPolicy": {
-               "type": "linear",
-               "period": 300
-            },
-            "verification_hash": "push-id-1",
-            "verified": true
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-22T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/subscriptions/sub3",
-         "topic": "/projects/ARGO/topics/topic3",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-21T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/subscriptions/sub2",
-         "topic": "/projects/ARGO/topics/topic2",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-20T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/subscriptions/sub1",
-         "topic": "/projects/ARGO/topics/topic1",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-19T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "",
-   "totalSize": 4
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubDelete() {
+
+	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := ""
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
 	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubDeleteIgnoreNotFound() {
+
+	type td struct {
+		url                string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/subscriptions/subFoo",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing subscription without ignoreNotFound",
+		},
+		{
+			url:                "http://localhost:8080/v1/projects/ARGO/subscriptions/subFoo?ignoreNotFound=true",
+			expectedResponse:   "",
+			expectedStatusCode: 200,
+			msg:                "Missing subscription with ignoreNotFound",
+		},
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/subscriptions/subFoo?ignoreNotFound=false",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing subscription with ignoreNotFound disabled",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, nil))
 
+	for _, t := range testData {
+		req, _ := http.NewRequest("DELETE", t.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllFirstPage() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubWithPushConfigDelete() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=2", nil)
+	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{
-   "subscriptions": [
-      {
-         "name": "/projects/ARGO/subscriptions/sub4",
-         "topic": "/projects/ARGO/topics/topic4",
-         "pushConfig": {
-            "pushEndpoint": "endpoint.foo",
-            "maxMessages": 1,
-            "authorization_header": {
-               "type": "autogen",
-               "value": "auth-header-1"
-            },
-            "retryPolicy": {
-               "type": "linear",
-               "period": 300
-            },
-            "verification_hash": "push-id-1",
-            "verified": true
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-22T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/subscriptions/sub3",
-         "topic": "/projects/ARGO/topics/topic3",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-21T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "MQ==",
-   "totalSize": 4
-}`
-
+	expResp := `{"message":"Subscription /projects/ARGO/subscriptions/sub4 deactivated"}`
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	pc := new(push.MockClient)
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, pc))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllNextPage() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubWithPushConfigDeletePushServerError() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=2&pageToken=MQ==", nil)
+	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/subscriptions/errorSub", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{
-   "subscriptions": [
-      {
-         "name": "/projects/ARGO/subscriptions/sub2",
-         "topic": "/projects/ARGO/topics/topic2",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-20T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/subscriptions/sub1",
-         "topic": "/projects/ARGO/topics/topic1",
-         "pushConfig": {
-            "pushEndpoint": "",
-            "maxMessages": 0,
-            "authorization_header": {},
-            "retryPolicy": {},
-            "verification_hash": "",
-            "verified": false
-         },
-         "ackDeadlineSeconds": 10,
-         "created_on": "2020-11-19T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "",
-   "totalSize": 4
-}`
-
+	expResp := `{"message":"Subscription /projects/ARGO/subscriptions/errorSub is not active"}`
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
+	str.SubList = append(str.SubList, stores.QSub{
+		Name:         "errorSub",
+		ProjectUUID:  "argo_uuid",
+		PushEndpoint: "example.com",
+		// sub needs to be verified in order to perform the call to the push server
+		Verified: true,
+	})
 	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	pc := new(push.MockClient)
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubDelete, cfgKafka, &brk, str, &mgr, pc))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllEmpty() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubGetOffsets() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub2:offsets", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
-
 	expResp := `{
-   "subscriptions": [],
-   "nextPageToken": "",
-   "totalSize": 0
+   "max": 2,
+   "min": 1,
+   "current": 1
 }`
-
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+
 	brk := brokers.MockBroker{}
+	// append a msg to the broker to cause the min topic from the offset to be at 1 while the sub's current is at 0
+	brk.MsgList = append(brk.MsgList, "msg1")
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	// empty the store
-	str.SubList = []stores.QSub{}
 	router := mux.NewRouter().StrictSlash(true)
-	mgr := oldPush.Manager{}
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:offsets", WrapMockAuthConfig(SubGetOffsets, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
 
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubListOne() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "subscriptions": [
-      {
-         "name": "/projects/ARGO/subscriptions/sub4",
-         "topic": "/projects/ARGO/topics/topic4",
-         "pushConfig": {
-            "pushEndpoint": "endpoint.foo",
-            "maxMessages": 1,
-            "authorization_header": {
-               "type": "autogen",
-               "value": "auth-header-1"
-            },
-            "retryPolicy": {
-               "type": "linear",
-               "period": 300
-            },
-            "verification_hash": "push-id-1",
-            "verified": true
+   "name": "/projects/ARGO/subscriptions/sub1",
+   "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
+   "pushConfig": {
+      "pushEndpoint": "",
+      "maxMessages": 0,
+      "authorization_header": {},
+      "retryPolicy": {},
+      "verification_hash": "",
+      "verified": false
+   },
+   "ackDeadlineSeconds": 10,
+   "created_on": "2020-11-19T00:00:00Z"
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListOneETag() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	etag := w.Header().Get("ETag")
+	suite.NotEqual("", etag)
+
+	// any of the listed tags, weak or not, and * match the subscription
+	for _, ifNoneMatch := range []string{etag, `"other", ` + etag, "W/" + etag, "*"} {
+		req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(304, w.Code)
+	}
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+	req.Header.Set("If-None-Match", `"other"`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	str.ModAck("argo_uuid", "sub1", 30)
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotEqual(etag, w.Header().Get("ETag"))
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubConfig() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].MaxConcurrentDeliveries = 4
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:config", WrapMockAuthConfig(SubConfig, cfgKafka, &brk, str, &mgr, nil))
+
+	// push subscription that declares some of its values
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:config", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "name": "/projects/ARGO/subscriptions/sub4",
+   "topic": "/projects/ARGO/topics/topic4",
+   "ackDeadlineSeconds": {
+      "value": 10,
+      "source": "default"
+   },
+   "newMessagesOnly": {
+      "value": false,
+      "source": "default"
+   },
+   "deduplicate": {
+      "value": false,
+      "source": "default"
+   },
+   "transform": {
+      "value": null,
+      "source": "default"
+   },
+   "maxConcurrentPulls": {
+      "value": 0,
+      "source": "default"
+   },
+   "prioritize": {
+      "value": false,
+      "source": "default"
+   },
+   "atMostOnce": {
+      "value": false,
+      "source": "default"
+   },
+   "isolationLevel": {
+      "value": "read_committed",
+      "source": "default"
+   },
+   "defaultMaxMessages": {
+      "value": 1,
+      "source": "default"
+   },
+   "samplingRate": {
+      "value": 1,
+      "source": "default"
+   },
+   "pushConfig": {
+      "pushEndpoint": {
+         "value": "endpoint.foo",
+         "source": "explicit"
+      },
+      "maxMessages": {
+         "value": 1,
+         "source": "default"
+      },
+      "authorizationHeaderType": {
+         "value": "autogen",
+         "source": "default"
+      },
+      "retryPolicyType": {
+         "value": "linear",
+         "source": "default"
+      },
+      "retryPolicyPeriod": {
+         "value": 300,
+         "source": "explicit"
+      },
+      "fanoutEndpoints": {
+         "value": [],
+         "source": "default"
+      },
+      "maxConcurrentDeliveries": {
+         "value": 4,
+         "source": "explicit"
+      },
+      "maxRetryDuration": {
+         "value": 0,
+         "source": "default"
+      },
+      "maxRetries": {
+         "value": 0,
+         "source": "default"
+      },
+      "verified": true
+   }
+}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// pull subscription
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:config", nil)
+
+	expResp = `{
+   "name": "/projects/ARGO/subscriptions/sub1",
+   "topic": "/projects/ARGO/topics/topic1",
+   "ackDeadlineSeconds": {
+      "value": 10,
+      "source": "default"
+   },
+   "newMessagesOnly": {
+      "value": false,
+      "source": "default"
+   },
+   "deduplicate": {
+      "value": false,
+      "source": "default"
+   },
+   "transform": {
+      "value": null,
+      "source": "default"
+   },
+   "maxConcurrentPulls": {
+      "value": 0,
+      "source": "default"
+   },
+   "prioritize": {
+      "value": false,
+      "source": "default"
+   },
+   "atMostOnce": {
+      "value": false,
+      "source": "default"
+   },
+   "isolationLevel": {
+      "value": "read_committed",
+      "source": "default"
+   },
+   "defaultMaxMessages": {
+      "value": 1,
+      "source": "default"
+   },
+   "samplingRate": {
+      "value": 1,
+      "source": "default"
+   }
+}`
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// unknown subscription
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:config", nil)
+
+	expResp = `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCompare() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:compare", WrapMockAuthConfig(SubCompare, cfgKafka, &brk, str, &mgr, nil))
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:compare?with=sub2", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "subscription": "/projects/ARGO/subscriptions/sub1",
+   "with": "/projects/ARGO/subscriptions/sub2",
+   "identical": false,
+   "differences": [
+      {
+         "field": "topic",
+         "value": "/projects/ARGO/topics/topic1",
+         "with": "/projects/ARGO/topics/topic2"
+      },
+      {
+         "field": "acl",
+         "value": [
+            "UserA",
+            "UserB"
+         ],
+         "with": [
+            "UserA",
+            "UserX"
+         ]
+      }
+   ]
+}`
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// a subscription compared with itself is identical
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:compare?with=sub4", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"identical": true`)
+
+	// either one of the subscriptions missing
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:compare?with=unknown", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "Subscription unknown doesn't exist")
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:compare?with=sub1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	// the subscription to compare with is required
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:compare", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListStalled() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 21, 12, 0, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions:stalled", WrapMockAuthConfig(SubListStalled, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// sub2 made progress within the duration, sub3 and sub4 are more recent than it
+	str.UpdateSubLastProgress("argo_uuid", "sub2", time.Date(2020, 11, 21, 0, 0, 0, 0, time.UTC))
+
+	expResp := `{
+   "subscriptions": [
+      {
+         "name": "/projects/ARGO/subscriptions/sub1",
+         "topic": "/projects/ARGO/topics/topic1",
+         "backlog": 1,
+         "last_progress": "2020-11-19T00:00:00Z",
+         "latest_consume": "2019-05-06T00:00:00Z"
+      }
+   ]
+}`
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions:stalled?for=24h", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// without a backlog subscriptions are idle rather than stalled
+	str.UpdateSubOffset("argo_uuid", "sub1", 1)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{\n   \"subscriptions\": []\n}", w.Body.String())
+
+	for _, value := range []string{"", "1", "-1h", "0s"} {
+		req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions:stalled?for="+value, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(400, w.Code)
+		suite.Contains(w.Body.String(), "Invalid for value, it should be a positive duration, e.g. 1h")
+	}
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAll() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "subscriptions": [
+      {
+         "name": "/projects/ARGO/subscriptions/sub4",
+         "topic": "/projects/ARGO/topics/topic4",
+         "topicName": "topic4",
+         "pushConfig": {
+            "pushEndpoint": "endpoint.foo",
+            "maxMessages": 1,
+            "authorization_header": {
+               "type": "autogen",
+               "value": "***"
+            },
+            "retryPolicy": {
+               "type": "linear",
+               "period": 300
+            },
+            "verification_hash": "push-id-1",
+            "verified": true,
+            "maxConcurrentDeliveries": 1
          },
          "ackDeadlineSeconds": 10,
          "created_on": "2020-11-22T00:00:00Z"
@@ -1505,6 +2380,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
       {
          "name": "/projects/ARGO/subscriptions/sub3",
          "topic": "/projects/ARGO/topics/topic3",
+         "topicName": "topic3",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -1519,6 +2395,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
       {
          "name": "/projects/ARGO/subscriptions/sub2",
          "topic": "/projects/ARGO/topics/topic2",
+         "topicName": "topic2",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -1529,10 +2406,25 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
          },
          "ackDeadlineSeconds": 10,
          "created_on": "2020-11-20T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/subscriptions/sub1",
+         "topic": "/projects/ARGO/topics/topic1",
+         "topicName": "topic1",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-19T00:00:00Z"
       }
    ],
    "nextPageToken": "",
-   "totalSize": 3
+   "totalSize": 4
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -1542,14 +2434,14 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
 	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
 
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPagination() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllFirstPage() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=2", nil)
 	if err != nil {
@@ -1561,19 +2453,21 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPaginatio
       {
          "name": "/projects/ARGO/subscriptions/sub4",
          "topic": "/projects/ARGO/topics/topic4",
+         "topicName": "topic4",
          "pushConfig": {
             "pushEndpoint": "endpoint.foo",
             "maxMessages": 1,
             "authorization_header": {
                "type": "autogen",
-               "value": "auth-header-1"
+               "value": "***"
             },
             "retryPolicy": {
                "type": "linear",
                "period": 300
             },
             "verification_hash": "push-id-1",
-            "verified": true
+            "verified": true,
+            "maxConcurrentDeliveries": 1
          },
          "ackDeadlineSeconds": 10,
          "created_on": "2020-11-22T00:00:00Z"
@@ -1581,6 +2475,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPaginatio
       {
          "name": "/projects/ARGO/subscriptions/sub3",
          "topic": "/projects/ARGO/topics/topic3",
+         "topicName": "topic3",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -1594,7 +2489,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPaginatio
       }
    ],
    "nextPageToken": "MQ==",
-   "totalSize": 3
+   "totalSize": 4
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -1604,31 +2499,263 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPaginatio
 	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
 	w := httptest.NewRecorder()
-	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
 
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubListAllInvalidPageSize() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllNextPage() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=invalid", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=2&pageToken=MQ==", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "error": {
-      "code": 400,
-      "message": "Invalid page size",
-      "status": "INVALID_ARGUMENT"
-   }
-}`
-
-	cfgKafka := config.NewAPICfg()
-	cfgKafka.LoadStrJSON(suite.cfgStr)
-
+   "subscriptions": [
+      {
+         "name": "/projects/ARGO/subscriptions/sub2",
+         "topic": "/projects/ARGO/topics/topic2",
+         "topicName": "topic2",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-20T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/subscriptions/sub1",
+         "topic": "/projects/ARGO/topics/topic1",
+         "topicName": "topic1",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-19T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 4
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllEmpty() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "subscriptions": [],
+   "nextPageToken": "",
+   "totalSize": 0
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// empty the store
+	str.SubList = []stores.QSub{}
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumer() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "subscriptions": [
+      {
+         "name": "/projects/ARGO/subscriptions/sub4",
+         "topic": "/projects/ARGO/topics/topic4",
+         "topicName": "topic4",
+         "pushConfig": {
+            "pushEndpoint": "endpoint.foo",
+            "maxMessages": 1,
+            "authorization_header": {
+               "type": "autogen",
+               "value": "***"
+            },
+            "retryPolicy": {
+               "type": "linear",
+               "period": 300
+            },
+            "verification_hash": "push-id-1",
+            "verified": true,
+            "maxConcurrentDeliveries": 1
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-22T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/subscriptions/sub3",
+         "topic": "/projects/ARGO/topics/topic3",
+         "topicName": "topic3",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-21T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/subscriptions/sub2",
+         "topic": "/projects/ARGO/topics/topic2",
+         "topicName": "topic2",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-20T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 3
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllConsumerWithPagination() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=2", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "subscriptions": [
+      {
+         "name": "/projects/ARGO/subscriptions/sub4",
+         "topic": "/projects/ARGO/topics/topic4",
+         "topicName": "topic4",
+         "pushConfig": {
+            "pushEndpoint": "endpoint.foo",
+            "maxMessages": 1,
+            "authorization_header": {
+               "type": "autogen",
+               "value": "***"
+            },
+            "retryPolicy": {
+               "type": "linear",
+               "period": 300
+            },
+            "verification_hash": "push-id-1",
+            "verified": true,
+            "maxConcurrentDeliveries": 1
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-22T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/subscriptions/sub3",
+         "topic": "/projects/ARGO/topics/topic3",
+         "topicName": "topic3",
+         "pushConfig": {
+            "pushEndpoint": "",
+            "maxMessages": 0,
+            "authorization_header": {},
+            "retryPolicy": {},
+            "verification_hash": "",
+            "verified": false
+         },
+         "ackDeadlineSeconds": 10,
+         "created_on": "2020-11-21T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "MQ==",
+   "totalSize": 3
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllInvalidPageSize() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?pageSize=invalid", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid page size",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
@@ -1727,6 +2854,32 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubTimeToOffset() {
 	suite.Equal(expResp, w.Body.String())
 }
 
+func (suite *SubscriptionsHandlersTestSuite) TestSubTimeToOffsetStoreFallback() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1?time=2019-06-10T09:38:30.500Z", nil)
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{"offset":5}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{NoTimeIndex: true}
+
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.InsertOffsetTime("argo_uuid", "topic1", 4, time.Date(2019, 6, 10, 9, 0, 0, 0, time.UTC))
+	str.InsertOffsetTime("argo_uuid", "topic1", 5, time.Date(2019, 6, 10, 10, 0, 0, 0, time.UTC))
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubTimeToOffset, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
 func (suite *SubscriptionsHandlersTestSuite) TestSubTimeToOffsetOutOfBounds() {
 
 	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1?time=2020-06-10T9:38:30.500Z", nil)
@@ -1820,19 +2973,110 @@ func (suite *SubscriptionsHandlersTestSuite) TestModSubACLWrong() {
 
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestModSubACL01() {
+func (suite *SubscriptionsHandlersTestSuite) TestModSubACLValidateOnly() {
 
-	postExp := `{"authorized_users":["UserX","UserZ"]}`
+	postExp := `{"authorized_users":["UserA","UserB","UserZ"]}`
 
-	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscription/sub1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modAcl?validateOnly=true", bytes.NewBuffer([]byte(postExp)))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cfgKafka := config.NewAPICfg()
-	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
-	str := stores.NewMockStore("whatever", "argo_mgs")
+	expResp := `{
+   "added": [
+      "UserZ"
+   ],
+   "removed": []
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modAcl", WrapMockAuthConfig(SubModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	suite.Equal([]string{"uuid1", "uuid2"}, str.SubsACL["sub1"].ACL)
+
+	// a missing subscription is reported as in the actual modification
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:modAcl?validateOnly=true", bytes.NewBuffer([]byte(postExp)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "Subscription doesn't exist")
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestModSubACLIfMatch() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acl", WrapMockAuthConfig(SubACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAcl", WrapMockAuthConfig(SubModACL, cfgKafka, &brk, str, &mgr, nil))
+
+	modify := func(ifMatch string, users string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyAcl", strings.NewReader(`{"authorized_users":[`+users+`]}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acl", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	etag := w.Header().Get("ETag")
+	suite.Equal(`"0"`, etag)
+
+	// two pipelines read the same acl, the first modification bumps its version
+	suite.Equal(200, modify(etag, `"UserX"`).Code)
+
+	// and the second one, based on the stale read, is rejected without touching the acl
+	w = modify(etag, `"UserZ"`)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), "The subscription's acl has been modified since it was read, read it again and retry")
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acl", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(`"1"`, w.Header().Get("ETag"))
+	suite.Contains(w.Body.String(), "UserX")
+	suite.NotContains(w.Body.String(), "UserZ")
+
+	suite.Equal(200, modify(`"1"`, `"UserZ"`).Code)
+
+	// modifications without If-Match, or with *, are unconditional
+	suite.Equal(200, modify("", `"UserX"`).Code)
+	suite.Equal(200, modify("*", `"UserZ"`).Code)
+
+	w = modify(`W/"4"`, `"UserX"`)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid If-Match header, it should hold the etag of the acl")
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestModSubACL01() {
+
+	postExp := `{"authorized_users":["UserX","UserZ"]}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscription/sub1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
@@ -1920,6 +3164,8 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubACL02() {
 
 func (suite *SubscriptionsHandlersTestSuite) TestSubPullOne() {
 
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
 	postJSON := `{
   "maxMessages":"1"
 }`
@@ -1932,7 +3178,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullOne() {
 	expJSON := `{
    "receivedMessages": [
       {
-         "ackId": "projects/ARGO/subscriptions/sub1:0",
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
          "message": {
             "messageId": "0",
             "attributes": {
@@ -1940,13 +3186,17 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullOne() {
             },
             "data": "YmFzZTY0ZW5jb2RlZA==",
             "publishTime": "2016-02-24T11:55:09.786127994Z"
-         }
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
       }
-   ]
+   ],
+   "messageCount": 1,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
 }`
-	tn := time.Now().UTC()
 
 	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	brk.Initialize([]string{"localhost"})
@@ -1959,336 +3209,534 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullOne() {
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expJSON, w.Body.String())
-	spc, _, _, _ := str.QuerySubs("argo_uuid", "", "sub1", "", 0)
-	suite.True(tn.Before(spc[0].LatestConsume))
+	spc, _, _, _ := str.QuerySubs("argo_uuid", "", "sub1", "", 0, nil, "")
+	suite.Equal(time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC), spc[0].LatestConsume)
 	suite.NotEqual(spc[0].ConsumeRate, 10)
 
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorker() {
-
-	postJSON := `{
-  "maxMessages":"1"
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
-	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
-	if err != nil {
-		log.Fatal(err)
-	}
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullMaxConcurrentPulls() {
 
-	expJSON := `{
-   "receivedMessages": [
-      {
-         "ackId": "projects/ARGO/subscriptions/sub4:0",
-         "message": {
-            "messageId": "0",
-            "attributes": {
-               "foo": "bar"
-            },
-            "data": "YmFzZTY0ZW5jb2RlZA==",
-            "publishTime": "2016-02-24T11:55:09.786127994Z"
-         }
-      }
-   ]
-}`
+	fc := &fakeClock{now: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)}
 
 	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PullLeaseTTL = 30
 	brk := brokers.MockBroker{}
 	brk.Initialize([]string{"localhost"})
-	brk.PopulateThree() // Add three messages to the broker queue
+	brk.PopulateThree()
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModSubMaxConcurrentPulls("argo_uuid", "sub1", 1)
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
-	router.ServeHTTP(w, req)
-	suite.Equal(200, w.Code)
-	suite.Equal(expJSON, w.Body.String())
-}
-
-func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorkerDISABLED() {
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
 
-	postJSON := `{
-  "maxMessages":"1"
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
-	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
-	if err != nil {
-		log.Fatal(err)
-	}
+	// another pull holds the only lease of the subscription
+	acquired, _ := str.AcquirePullLease("argo_uuid", "sub1", "lease-1", 1, fc.Now(), fc.Now().Add(30*time.Second))
+	suite.True(acquired)
 
-	expJSON := `{
+	expResp := `{
    "error": {
-      "code": 409,
-      "message": "Push functionality is currently disabled",
-      "status": "CONFLICT"
+      "code": 429,
+      "message": "Subscription has reached its max concurrent pulls, please retry",
+      "status": "RESOURCE_EXHAUSTED",
+      "retryAfter": 1
    }
 }`
 
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"1"}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Equal("1", w.Header().Get("Retry-After"))
+	suite.Equal(expResp, w.Body.String())
+
+	// subscriptions without a limit are not affected
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub2:pull", bytes.NewBuffer([]byte(`{"maxMessages":"1"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the lease of a pull that never completed expires
+	fc.Advance(31 * time.Second)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"1"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the completed pull released its lease
+	suite.Equal([]stores.QPullLease{}, str.PullLeases[0].Leases)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"1"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubModMaxConcurrentPulls() {
+
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	// disable push functionality
-	cfgKafka.PushEnabled = false
 	brk := brokers.MockBroker{}
-	brk.Initialize([]string{"localhost"})
-	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyMaxConcurrentPulls", WrapMockAuthConfig(SubModMaxConcurrentPulls, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyMaxConcurrentPulls", bytes.NewBuffer([]byte(`{"maxConcurrentPulls": 2}`)))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	suite.Equal(409, w.Code)
-	suite.Equal(expJSON, w.Body.String())
-}
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+	sub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(2, sub.MaxConcurrentPulls)
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsServiceAdmin() {
+	// out of bounds
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyMaxConcurrentPulls", bytes.NewBuffer([]byte(`{"maxConcurrentPulls": 101}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidMaxConcurrentPulls)
 
-	postJSON := `{
-  "maxMessages":"1"
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
-	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
-	if err != nil {
-		log.Fatal(err)
-	}
+	// zero lifts the limit
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyMaxConcurrentPulls", bytes.NewBuffer([]byte(`{"maxConcurrentPulls": 0}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	sub, _ = str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(0, sub.MaxConcurrentPulls)
 
-	expJSON := `{
-   "receivedMessages": [
-      {
-         "ackId": "projects/ARGO/subscriptions/sub4:0",
-         "message": {
-            "messageId": "0",
-            "attributes": {
-               "foo": "bar"
-            },
-            "data": "YmFzZTY0ZW5jb2RlZA==",
-            "publishTime": "2016-02-24T11:55:09.786127994Z"
-         }
-      }
-   ]
-}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:modifyMaxConcurrentPulls", bytes.NewBuffer([]byte(`{"maxConcurrentPulls": 1}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubModIsolationLevel() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
-	brk.Initialize([]string{"localhost"})
-	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "service_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyIsolationLevel", WrapMockAuthConfig(SubModIsolationLevel, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyIsolationLevel", bytes.NewBuffer([]byte(`{"isolationLevel": "read_uncommitted"}`)))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expJSON, w.Body.String())
-}
-
-func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledNoPushWorker() {
+	suite.Equal("", w.Body.String())
+	sub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal("read_uncommitted", sub.IsolationLevel)
 
-	postJSON := `{
-  "maxMessages":"1"
-}`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
-	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
-	if err != nil {
-		log.Fatal(err)
+	for _, level := range []string{"", "READ_COMMITTED", "serializable"} {
+		req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyIsolationLevel", bytes.NewBuffer([]byte(`{"isolationLevel": "`+level+`"}`)))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(400, w.Code, level)
+		suite.Contains(w.Body.String(), subscriptions.InvalidIsolationLevel)
 	}
 
-	expJSON := `{
-   "error": {
-      "code": 403,
-      "message": "Access to this resource is forbidden",
-      "status": "FORBIDDEN"
-   }
-}`
+	brk.NoTransactions = true
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyIsolationLevel", bytes.NewBuffer([]byte(`{"isolationLevel": "read_committed"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.UnsupportedIsolationLevel)
+	sub, _ = str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal("read_uncommitted", sub.IsolationLevel)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:modifyIsolationLevel", bytes.NewBuffer([]byte(`{"isolationLevel": "read_uncommitted"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubModDefaultMaxMessages() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
-	brk.Initialize([]string{"localhost"})
-	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
-	router.ServeHTTP(w, req)
-	suite.Equal(403, w.Code)
-	suite.Equal(expJSON, w.Body.String())
-}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyDefaultMaxMessages", WrapMockAuthConfig(SubModDefaultMaxMessages, cfgKafka, &brk, str, &mgr, nil))
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubModAck() {
-
-	postJSON := `{
-  "ackDeadlineSeconds":33
-}`
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyDefaultMaxMessages", bytes.NewBuffer([]byte(`{"defaultMaxMessages": 100}`)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+	sub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(100, sub.DefaultMaxMessages)
 
-	postJSON2 := `{
-  "ackDeadlineSeconds":700
-}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyDefaultMaxMessages", bytes.NewBuffer([]byte(`{"defaultMaxMessages": -1}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidDefaultMaxMessages)
 
-	postJSON3 := `{
-  "ackDeadlineSeconds":-22
-}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyDefaultMaxMessages", bytes.NewBuffer([]byte(`{"defaultMaxMessages": "10"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
 
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyAckDeadline"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
-	}
+	// zero falls back to the service's default
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyDefaultMaxMessages", bytes.NewBuffer([]byte(`{"defaultMaxMessages": 0}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	sub, _ = str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(0, sub.DefaultMaxMessages)
 
-	expJSON1 := ``
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:modifyDefaultMaxMessages", bytes.NewBuffer([]byte(`{"defaultMaxMessages": 10}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
 
-	expJSON2 := `{
-   "error": {
-      "code": 400,
-      "message": "Invalid ackDeadlineSeconds(needs value between 0 and 600) Arguments",
-      "status": "INVALID_ARGUMENT"
-   }
-}`
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullPrioritized() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	brk.Initialize([]string{"localhost"})
-	brk.PopulateThree() // Add three messages to the broker queue
+	brk.MsgList = []string{
+		`{"messageId": "0", "attributes": {"priority": "low"}, "data": "YmFzZTY0ZW5jb2RlZA=="}`,
+		`{"messageId": "1", "data": "YmFzZTY0ZW5jb2RlZA=="}`,
+		`{"messageId": "2", "attributes": {"priority": "high"}, "data": "YmFzZTY0ZW5jb2RlZA=="}`,
+	}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	pulledIDs := func() []string {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"3"}`)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		recList := messages.RecList{}
+		json.Unmarshal(w.Body.Bytes(), &recList)
+		ids := []string{}
+		for _, rec := range recList.RecMsgs {
+			ids = append(ids, rec.Msg.ID)
+		}
+		return ids
+	}
+
+	// subscriptions that don't prioritize deliver the messages in the order they were published
+	suite.Equal([]string{"0", "1", "2"}, pulledIDs())
+
+	str.SubList[0].Prioritize = true
+	str.UpdateSubOffset("argo_uuid", "sub1", 0)
+	suite.Equal([]string{"2", "1", "0"}, pulledIDs())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullAtMostOnce() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	pull := func() messages.RecList {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"2"}`)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		recList := messages.RecList{}
+		json.Unmarshal(w.Body.Bytes(), &recList)
+		return recList
+	}
+
+	str.SubList[0].AtMostOnce = true
+
+	// the offset is committed along with the pull, leaving no ack pending
+	recList := pull()
+	suite.Equal(messages.DeliveryAtMostOnce, recList.DeliverySemantics)
+	suite.Equal(2, recList.MessageCount)
+	// and no deadline to acknowledge the messages by
+	suite.Equal("", recList.RecMsgs[0].AckDeadline)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(2), qSub.Offset)
+	suite.Equal(int64(0), qSub.NextOffset)
+	suite.Equal("", qSub.PendingAck)
+	suite.False(qSub.LastProgress.IsZero())
+
+	// acknowledging the committed messages has no effect
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(fmt.Sprintf(`{"ackIds":["%s"]}`, recList.RecMsgs[1].AckID)))
 	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	qSub, _ = str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(2), qSub.Offset)
+
+	// offsets that only advance through acks take precedence over the subscription's mode
+	cfgKafka.DisableAutoOffsetAdvance = true
+	str.UpdateSubOffset("argo_uuid", "sub1", 0)
+	recList = pull()
+	suite.Equal(messages.DeliveryAtLeastOnce, recList.DeliverySemantics)
+	qSub, _ = str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(0), qSub.Offset)
+	suite.Equal(int64(2), qSub.NextOffset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullAckDeadline() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModAck("argo_uuid", "sub1", 30)
+	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expJSON1, w.Body.String())
+	recList := messages.RecList{}
+	json.Unmarshal(w.Body.Bytes(), &recList)
+
+	// every message carries the deadline of the pull, derived from the subscription's ack deadline
+	suite.Equal(2, recList.MessageCount)
+	suite.Equal("2020-11-25T14:30:30Z", recList.RecMsgs[0].AckDeadline)
+	suite.Equal("2020-11-25T14:30:30Z", recList.RecMsgs[1].AckDeadline)
+
+	// acks past the deadline are rejected
+	fc.Advance(31 * time.Second)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(fmt.Sprintf(`{"ackIds":["%s"]}`, recList.RecMsgs[1].AckID)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(408, w.Code)
+}
 
-	subRes, err := str.QueryOneSub("argo_uuid", "sub1")
-	suite.Equal(33, subRes.Ack)
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullIsolationLevel() {
 
-	req2, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON2)))
-	router2 := mux.NewRouter().StrictSlash(true)
-	w2 := httptest.NewRecorder()
-	mgr = oldPush.Manager{}
-	router2.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
-	router2.ServeHTTP(w2, req2)
-	suite.Equal(400, w2.Code)
-	suite.Equal(expJSON2, w2.Body.String())
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
 
-	req3, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON3)))
-	router3 := mux.NewRouter().StrictSlash(true)
-	w3 := httptest.NewRecorder()
-	mgr = oldPush.Manager{}
-	router3.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
-	router3.ServeHTTP(w3, req3)
-	suite.Equal(400, w3.Code)
-	suite.Equal(expJSON2, w3.Body.String())
+	pull := func() {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(`{"maxMessages":"1"}`)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+	}
 
+	// subscriptions that don't declare an isolation level read only committed messages
+	pull()
+	str.ModSubIsolationLevel("argo_uuid", "sub1", "read_uncommitted")
+	pull()
+	suite.Equal([]string{"read_committed", "read_uncommitted"}, brk.ConsumeIsolations)
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubAck() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullMoreAvailable() {
 
-	postJSON := `{
-  "ackIds":["projects/ARGO/subscriptions/sub2:1"]
-}`
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := tailBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	mgr := oldPush.Manager{}
 
-	postJSON2 := `{
-"ackIds":["projects/ARGO/subscriptions/sub1:2"]
-}`
+	pull := func(postJSON string) messages.RecList {
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		router := mux.NewRouter().StrictSlash(true)
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		recList := messages.RecList{}
+		json.Unmarshal(w.Body.Bytes(), &recList)
+		return recList
+	}
 
-	postJSON3 := `{
-"ackIds":["projects/ARGO/subscriptions/sub1:2"]
-}`
+	// the topic holds messages beyond the requested ones
+	recList := pull(`{"maxMessages":"1"}`)
+	suite.Equal(1, recList.MessageCount)
+	suite.True(recList.MoreAvailable)
 
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
+	// all the topic's messages have been returned
+	recList = pull(`{"maxMessages":"10"}`)
+	suite.Equal(3, recList.MessageCount)
+	suite.False(recList.MoreAvailable)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubSecretsMasked() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, pc, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acl", WrapMockAuthConfig(SubACL, cfgKafka, &brk, str, &mgr, pc))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, pc))
+
+	urls := []string{
+		"http://localhost:8080/v1/projects/ARGO/subscriptions",
+		"http://localhost:8080/v1/projects/ARGO/subscriptions/sub4",
+		"http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acl",
 	}
 
-	expJSON1 := `{
-   "error": {
-      "code": 400,
-      "message": "Invalid ack id",
-      "status": "INVALID_ARGUMENT"
-   }
-}`
+	for _, u := range urls {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, u)
+		suite.NotContains(w.Body.String(), "auth-header-1", u)
+	}
 
-	expJSON2 := `{
-   "error": {
-      "code": 408,
-      "message": "ack timeout",
-      "status": "TIMEOUT"
-   }
+	// the secret is still available internally
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal("auth-header-1", qSub.AuthorizationHeader)
+}
+
+// offsetOffBroker is a mock broker that reports the tracked offset as off on the first consume attempt
+type offsetOffBroker struct {
+	brokers.MockBroker
+	consumed bool
+}
+
+func (b *offsetOffBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	if !b.consumed {
+		b.consumed = true
+		return []string{}, brokers.ErrOffsetOff
+	}
+	return b.MockBroker.Consume(ctx, topic, offset, imm, max, isolation)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullOffsetOff() {
+
+	postJSON := `{
+  "maxMessages":"1"
 }`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
+	brk := offsetOffBroker{}
 	brk.Initialize([]string{"localhost"})
 	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
-	suite.Equal(400, w.Code)
-	suite.Equal(expJSON1, w.Body.String())
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:3"`)
+	// the tracked offset has been advanced to the broker's min offset
+	suite.Equal(int64(3), str.SubList[0].Offset)
+}
 
-	// grab sub1
-	zSec := "2006-01-02T15:04:05Z"
-	t := time.Now().UTC()
-	ts := t.Format(zSec)
-	str.SubList[0].PendingAck = ts
-	str.SubList[0].NextOffset = 3
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullOffsetOffAutoAdvanceDisabled() {
 
-	req2, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON2)))
-	router2 := mux.NewRouter().StrictSlash(true)
-	w2 := httptest.NewRecorder()
-	mgr = oldPush.Manager{}
-	router2.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
-	router2.ServeHTTP(w2, req2)
-	suite.Equal(200, w2.Code)
-	suite.Equal("{}", w2.Body.String())
+	postJSON := `{
+  "maxMessages":"1"
+}`
 
-	// mess with the timeout
-	t2 := time.Now().UTC().Add(-11 * time.Second)
-	ts2 := t2.Format(zSec)
-	str.SubList[0].PendingAck = ts2
-	str.SubList[0].NextOffset = 4
+	ackJSON := `{
+  "ackIds":["v1/projects/ARGO/subscriptions/sub1:3"]
+}`
 
-	req3, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON3)))
-	router3 := mux.NewRouter().StrictSlash(true)
-	w3 := httptest.NewRecorder()
-	mgr = oldPush.Manager{}
-	router3.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
-	router3.ServeHTTP(w3, req3)
-	suite.Equal(408, w3.Code)
-	suite.Equal(expJSON2, w3.Body.String())
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.DisableAutoOffsetAdvance = true
+	brk := offsetOffBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", bytes.NewBuffer([]byte(postJSON)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:3"`)
+	// the tracked offset should remain intact until the messages get acknowledged
+	suite.Equal(int64(0), str.SubList[0].Offset)
 
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", bytes.NewBuffer([]byte(ackJSON)))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.Equal(int64(4), str.SubList[0].Offset)
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubError() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorker() {
 
-	postJSON := `{
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
 
+	postJSON := `{
+  "maxMessages":"1"
 }`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/foo:pull"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
+	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expJSON := `{
-   "error": {
-      "code": 404,
-      "message": "Subscription doesn't exist",
-      "status": "NOT_FOUND"
-   }
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub4:0",
+         "message": {
+            "messageId": "0",
+            "attributes": {
+               "foo": "bar"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 1,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
 }`
 
 	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	brk.Initialize([]string{"localhost"})
@@ -2297,20 +3745,19 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubError() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
 	router.ServeHTTP(w, req)
-	suite.Equal(404, w.Code)
+	suite.Equal(200, w.Code)
 	suite.Equal(expJSON, w.Body.String())
-
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubNoTopic() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsPushWorkerDISABLED() {
 
 	postJSON := `{
-
+  "maxMessages":"1"
 }`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/no_topic_sub:pull"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
+	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -2318,37 +3765,37 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubNoTopic() {
 	expJSON := `{
    "error": {
       "code": 409,
-      "message": "Subscription's topic doesn't exist",
+      "message": "Push functionality is currently disabled",
       "status": "CONFLICT"
    }
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	// disable push functionality
+	cfgKafka.PushEnabled = false
 	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	// add a mock sub that is linked to a non existent topic
-	str.SubList = append(str.SubList, stores.QSub{
-		Name:        "no_topic_sub",
-		ProjectUUID: "argo_uuid",
-		Topic:       "unknown_topic"},
-	)
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
 	router.ServeHTTP(w, req)
 	suite.Equal(409, w.Code)
 	suite.Equal(expJSON, w.Body.String())
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestSubPullAll() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledAsServiceAdmin() {
 
-	postJSON := `{
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
 
+	postJSON := `{
+  "maxMessages":"1"
 }`
-	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
+	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -2356,7 +3803,7 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullAll() {
 	expJSON := `{
    "receivedMessages": [
       {
-         "ackId": "projects/ARGO/subscriptions/sub1:0",
+         "ackId": "v1/projects/ARGO/subscriptions/sub4:0",
          "message": {
             "messageId": "0",
             "attributes": {
@@ -2364,34 +3811,17 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullAll() {
             },
             "data": "YmFzZTY0ZW5jb2RlZA==",
             "publishTime": "2016-02-24T11:55:09.786127994Z"
-         }
-      },
-      {
-         "ackId": "projects/ARGO/subscriptions/sub1:1",
-         "message": {
-            "messageId": "1",
-            "attributes": {
-               "foo2": "bar2"
-            },
-            "data": "YmFzZTY0ZW5jb2RlZA==",
-            "publishTime": "2016-02-24T11:55:09.827678754Z"
-         }
-      },
-      {
-         "ackId": "projects/ARGO/subscriptions/sub1:2",
-         "message": {
-            "messageId": "2",
-            "attributes": {
-               "foo2": "bar2"
-            },
-            "data": "YmFzZTY0ZW5jb2RlZA==",
-            "publishTime": "2016-02-24T11:55:09.830417467Z"
-         }
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
       }
-   ]
+   ],
+   "messageCount": 1,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
 }`
 
 	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	brk.Initialize([]string{"localhost"})
@@ -2400,13 +3830,30 @@ func (suite *SubscriptionsHandlersTestSuite) TestSubPullAll() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "service_admin"))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expJSON, w.Body.String())
 }
 
-func (suite *SubscriptionsHandlersTestSuite) TestValidationInSubs() {
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullFromPushEnabledNoPushWorker() {
+
+	postJSON := `{
+  "maxMessages":"1"
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull"
+	req, err := http.NewRequest("POST", url, strings.NewReader(postJSON))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "error": {
+      "code": 403,
+      "message": "Access to this resource is forbidden",
+      "status": "FORBIDDEN"
+   }
+}`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
@@ -2414,84 +3861,2278 @@ func (suite *SubscriptionsHandlersTestSuite) TestValidationInSubs() {
 	brk.Initialize([]string{"localhost"})
 	brk.PopulateThree() // Add three messages to the broker queue
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(403, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+}
 
-	okResp := `{
-   "name": "/projects/ARGO/subscriptions/sub1",
-   "topic": "/projects/ARGO/topics/topic1",
-   "pushConfig": {
-      "pushEndpoint": "",
-      "maxMessages": 0,
-      "authorization_header": {},
-      "retryPolicy": {},
-      "verification_hash": "",
-      "verified": false
-   },
-   "ackDeadlineSeconds": 10,
-   "created_on": "2020-11-19T00:00:00Z"
+func (suite *SubscriptionsHandlersTestSuite) TestSubModAck() {
+
+	postJSON := `{
+  "ackDeadlineSeconds":33
 }`
 
-	invProject := `{
+	postJSON2 := `{
+  "ackDeadlineSeconds":700
+}`
+
+	postJSON3 := `{
+  "ackDeadlineSeconds":-22
+}`
+
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:modifyAckDeadline"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON1 := ``
+
+	expJSON2 := `{
    "error": {
       "code": 400,
-      "message": "Invalid project name",
+      "message": "Invalid ackDeadlineSeconds(needs value between 0 and 600) Arguments",
       "status": "INVALID_ARGUMENT"
    }
 }`
 
-	invSub := `{
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON1, w.Body.String())
+
+	subRes, err := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(33, subRes.Ack)
+
+	req2, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON2)))
+	router2 := mux.NewRouter().StrictSlash(true)
+	w2 := httptest.NewRecorder()
+	mgr = oldPush.Manager{}
+	router2.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
+	router2.ServeHTTP(w2, req2)
+	suite.Equal(400, w2.Code)
+	suite.Equal(expJSON2, w2.Body.String())
+
+	req3, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON3)))
+	router3 := mux.NewRouter().StrictSlash(true)
+	w3 := httptest.NewRecorder()
+	mgr = oldPush.Manager{}
+	router3.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", WrapMockAuthConfig(SubModAck, cfgKafka, &brk, str, &mgr, nil))
+	router3.ServeHTTP(w3, req3)
+	suite.Equal(400, w3.Code)
+	suite.Equal(expJSON2, w3.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubAck() {
+
+	postJSON := `{
+  "ackIds":["projects/ARGO/subscriptions/sub2:1"]
+}`
+
+	postJSON2 := `{
+"ackIds":["projects/ARGO/subscriptions/sub1:2"]
+}`
+
+	postJSON3 := `{
+"ackIds":["projects/ARGO/subscriptions/sub1:2"]
+}`
+
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON1 := `{
    "error": {
       "code": 400,
-      "message": "Invalid subscription name",
+      "message": "Invalid ack id",
       "status": "INVALID_ARGUMENT"
    }
 }`
 
-	urls := []string{
-		"http://localhost:8080/v1/projects/ARGO/subscriptions/sub1",
-		"http://localhost:8080/v1/projects/AR:GO/subscriptions/sub1",
-		"http://localhost:8080/v1/projects/ARGO/subscriptions/s,ub1",
-		"http://localhost:8080/v1/projects/AR,GO/subscriptions/s:ub1",
-	}
+	expJSON2 := `{
+   "error": {
+      "code": 408,
+      "message": "ack timeout",
+      "status": "TIMEOUT"
+   }
+}`
 
-	codes := []int(nil)
-	responses := []string(nil)
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expJSON1, w.Body.String())
 
-	for _, url := range urls {
-		w := httptest.NewRecorder()
-		req, err := http.NewRequest("GET", url, bytes.NewBuffer([]byte("")))
-		router := mux.NewRouter().StrictSlash(true)
-		mgr := oldPush.Manager{}
-		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapValidate(WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, nil)))
+	// grab sub1
+	zSec := "2006-01-02T15:04:05Z"
+	t := time.Now().UTC()
+	ts := t.Format(zSec)
+	str.SubList[0].PendingAck = ts
+	str.SubList[0].NextOffset = 3
 
-		if err != nil {
-			log.Fatal(err)
-		}
+	req2, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON2)))
+	router2 := mux.NewRouter().StrictSlash(true)
+	w2 := httptest.NewRecorder()
+	mgr = oldPush.Manager{}
+	router2.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router2.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.Equal("{}", w2.Body.String())
 
-		router.ServeHTTP(w, req)
-		codes = append(codes, w.Code)
-		responses = append(responses, w.Body.String())
+	// mess with the timeout
+	t2 := time.Now().UTC().Add(-11 * time.Second)
+	ts2 := t2.Format(zSec)
+	str.SubList[0].PendingAck = ts2
+	str.SubList[0].NextOffset = 4
 
-	}
+	req3, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON3)))
+	router3 := mux.NewRouter().StrictSlash(true)
+	w3 := httptest.NewRecorder()
+	mgr = oldPush.Manager{}
+	router3.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router3.ServeHTTP(w3, req3)
+	suite.Equal(408, w3.Code)
+	suite.Equal(expJSON2, w3.Body.String())
 
-	// First request is valid so response is ok
-	suite.Equal(200, codes[0])
-	suite.Equal(okResp, responses[0])
+}
 
-	// Second request has invalid project name
-	suite.Equal(400, codes[1])
-	suite.Equal(invProject, responses[1])
+func (suite *SubscriptionsHandlersTestSuite) TestSubDeadlineExceeded() {
 
-	// Third  request has invalid subscription name
-	suite.Equal(400, codes[2])
-	suite.Equal(invSub, responses[2])
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:modifyOffset", WrapMockAuthConfig(SubSetOffset, cfgKafka, &brk, str, &mgr, nil))
 
-	// Fourth request has invalid project and subscription name, but project is caught first
-	suite.Equal(400, codes[3])
-	suite.Equal(invProject, responses[3])
+	// the requests' deadline has already passed, none of them moves the offsets of the subscription
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
+	requests := map[string]string{
+		"pull":         `{"maxMessages":"1"}`,
+		"acknowledge":  `{"ackIds":["projects/ARGO/subscriptions/sub1:0"]}`,
+		"modifyOffset": `{"offset":4}`,
+	}
+
+	for verb, body := range requests {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:"+verb, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req.WithContext(ctx))
+		suite.Equal(504, w.Code, verb)
+		suite.Contains(w.Body.String(), "DEADLINE_EXCEEDED", verb)
+	}
+
+	suite.Equal(int64(0), str.SubList[0].Offset)
+	suite.Equal("", str.SubList[0].PendingAck)
+	suite.Equal(int64(0), str.SubList[0].MsgNum)
 }
 
-func TestSubscriptionsHandlersTestSuite(t *testing.T) {
-	log.SetOutput(ioutil.Discard)
-	suite.Run(t, new(SubscriptionsHandlersTestSuite))
+func (suite *SubscriptionsHandlersTestSuite) TestSubAckOffset() {
+
+	expJSON1 := `{
+   "error": {
+      "code": 500,
+      "message": "wrong ack",
+      "status": "INTERNAL_SERVER_ERROR"
+   }
+}`
+
+	expJSON2 := `{
+   "error": {
+      "code": 408,
+      "message": "ack timeout",
+      "status": "TIMEOUT"
+   }
+}`
+
+	expJSON3 := `{
+   "error": {
+      "code": 400,
+      "message": "ackIds and ackOffset can't be declared together",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	zSec := "2006-01-02T15:04:05Z"
+	str.SubList[0].PendingAck = time.Now().UTC().Format(zSec)
+	str.SubList[0].NextOffset = 3
+
+	// an offset past the pulled messages
+	req, _ := http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":5}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(500, w.Code)
+	suite.Equal(expJSON1, w.Body.String())
+
+	// both forms together
+	req, _ = http.NewRequest("POST", url, strings.NewReader(`{"ackIds":["projects/ARGO/subscriptions/sub1:2"],"ackOffset":2}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expJSON3, w.Body.String())
+
+	// acks everything up to and including offset 2
+	req, _ = http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":2}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{}", w.Body.String())
+	suite.Equal(int64(3), str.SubList[0].Offset)
+	suite.Equal(int64(0), str.SubList[0].NextOffset)
+
+	// an expired ack
+	str.SubList[0].PendingAck = time.Now().UTC().Add(-11 * time.Second).Format(zSec)
+	str.SubList[0].NextOffset = 4
+
+	req, _ = http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":3}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(408, w.Code)
+	suite.Equal(expJSON2, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubAckVerifyOffsets() {
+
+	expJSON := `{
+   "error": {
+      "code": 409,
+      "message": "Acknowledged offset 3 is beyond the latest offset 3 of the topic",
+      "status": "CONFLICT"
+   }
+}`
+
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.VerifyAckOffsets = true
+	// the broker holds the offsets 0 to 2
+	brk := retainingBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	zSec := "2006-01-02T15:04:05Z"
+	str.SubList[0].PendingAck = time.Now().UTC().Format(zSec)
+	str.SubList[0].NextOffset = 5
+
+	// an offset the broker never held is rejected, leaving the stored offsets untouched
+	req, _ := http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":3}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	suite.Equal(int64(0), str.SubList[0].Offset)
+	suite.Equal(int64(5), str.SubList[0].NextOffset)
+
+	req, _ = http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":2}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(int64(3), str.SubList[0].Offset)
+
+	// the broker has removed the offsets up to 2, acknowledging them is still accepted
+	retBrk := brokers.MockBroker{}
+	retBrk.Initialize([]string{"localhost"})
+	retBrk.PopulateThree()
+	str = stores.NewMockStore("whatever", "argo_mgs")
+	router = mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &retBrk, str, &mgr, nil))
+
+	str.SubList[0].PendingAck = time.Now().UTC().Format(zSec)
+	str.SubList[0].NextOffset = 3
+
+	req, _ = http.NewRequest("POST", url, strings.NewReader(`{"ackOffset":2}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(int64(3), str.SubList[0].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubAckVersioned() {
+
+	postJSON := `{
+"ackIds":["v1/projects/ARGO/subscriptions/sub1:1", "v1/projects/ARGO/subscriptions/sub1:2"]
+}`
+
+	postJSON2 := `{
+"ackIds":["v7/projects/ARGO/subscriptions/sub1:2"]
+}`
+
+	expJSON2 := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid ack id",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge"
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	zSec := "2006-01-02T15:04:05Z"
+	str.SubList[0].PendingAck = time.Now().UTC().Format(zSec)
+	str.SubList[0].NextOffset = 3
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{}", w.Body.String())
+
+	// unknown ack id version
+	req2, _ := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON2)))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(400, w2.Code)
+	suite.Equal(expJSON2, w2.Body.String())
+}
+
+// concurrentOffsetBroker moves the offset of the consumed subscription while consuming,
+// as a concurrent request would do
+type concurrentOffsetBroker struct {
+	brokers.MockBroker
+	str *stores.MockStore
+}
+
+func (b *concurrentOffsetBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	b.str.UpdateSubOffset("argo_uuid", "sub1", 0)
+	return b.MockBroker.Consume(ctx, topic, offset, imm, max, isolation)
+}
+
+// concurrentPullStore records a pull right before each ack, as a concurrent request would do
+type concurrentPullStore struct {
+	*stores.MockStore
+}
+
+func (s concurrentPullStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string, version int64) error {
+	s.UpdateSubPull(projectUUID, name, offset+1, ts, version)
+	return s.MockStore.UpdateSubOffsetAck(projectUUID, name, offset, ts, version)
+}
+
+func (s concurrentPullStore) Clone() stores.Store {
+	return s
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullAckConflict() {
+
+	expJSON := `{
+   "error": {
+      "code": 409,
+      "message": "Subscription offsets were modified concurrently, please retry",
+      "status": "CONFLICT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	brk := concurrentOffsetBroker{str: str}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"3"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	// the pull didn't get tracked
+	suite.Equal(int64(0), str.SubList[0].NextOffset)
+
+	// an ack racing with a pull
+	zSec := "2006-01-02T15:04:05Z"
+	str.SubList[0].PendingAck = time.Now().UTC().Format(zSec)
+	str.SubList[0].NextOffset = 3
+
+	ackStr := concurrentPullStore{str}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, ackStr, &mgr, nil))
+
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["v1/projects/ARGO/subscriptions/sub1:2"]}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(409, w2.Code)
+	suite.Equal(expJSON, w2.Body.String())
+	// the ack didn't overwrite the offsets of the pull
+	suite.Equal(int64(0), str.SubList[0].Offset)
+	suite.Equal(int64(4), str.SubList[0].NextOffset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubError() {
+
+	postJSON := `{
+
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/foo:pull"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubNoTopic() {
+
+	postJSON := `{
+
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/no_topic_sub:pull"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "error": {
+      "code": 409,
+      "message": "Subscription's topic doesn't exist",
+      "status": "CONFLICT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// add a mock sub that is linked to a non existent topic
+	str.SubList = append(str.SubList, stores.QSub{
+		Name:        "no_topic_sub",
+		ProjectUUID: "argo_uuid",
+		Topic:       "unknown_topic"},
+	)
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullAll() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	postJSON := `{
+  "maxMessages":"3"
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "messageId": "0",
+            "attributes": {
+               "foo": "bar"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      },
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:1",
+         "message": {
+            "messageId": "1",
+            "attributes": {
+               "foo2": "bar2"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.827678754Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      },
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:2",
+         "message": {
+            "messageId": "2",
+            "attributes": {
+               "foo2": "bar2"
+            },
+            "data": "YmFzZTY0ZW5jb2RlZA==",
+            "publishTime": "2016-02-24T11:55:09.830417467Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 3,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestValidationInSubs() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree() // Add three messages to the broker queue
+	str := stores.NewMockStore("whatever", "argo_mgs")
+
+	okResp := `{
+   "name": "/projects/ARGO/subscriptions/sub1",
+   "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
+   "pushConfig": {
+      "pushEndpoint": "",
+      "maxMessages": 0,
+      "authorization_header": {},
+      "retryPolicy": {},
+      "verification_hash": "",
+      "verified": false
+   },
+   "ackDeadlineSeconds": 10,
+   "created_on": "2020-11-19T00:00:00Z"
+}`
+
+	invProject := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid project name",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	invSub := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid subscription name",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	urls := []string{
+		"http://localhost:8080/v1/projects/ARGO/subscriptions/sub1",
+		"http://localhost:8080/v1/projects/AR:GO/subscriptions/sub1",
+		"http://localhost:8080/v1/projects/ARGO/subscriptions/s,ub1",
+		"http://localhost:8080/v1/projects/AR,GO/subscriptions/s:ub1",
+	}
+
+	codes := []int(nil)
+	responses := []string(nil)
+
+	for _, url := range urls {
+		w := httptest.NewRecorder()
+		req, err := http.NewRequest("GET", url, bytes.NewBuffer([]byte("")))
+		router := mux.NewRouter().StrictSlash(true)
+		mgr := oldPush.Manager{}
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapValidate(WrapMockAuthConfig(SubListOne, cfgKafka, &brk, str, &mgr, nil)))
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+		responses = append(responses, w.Body.String())
+
+	}
+
+	// First request is valid so response is ok
+	suite.Equal(200, codes[0])
+	suite.Equal(okResp, responses[0])
+
+	// Second request has invalid project name
+	suite.Equal(400, codes[1])
+	suite.Equal(invProject, responses[1])
+
+	// Third  request has invalid subscription name
+	suite.Equal(400, codes[2])
+	suite.Equal(invSub, responses[2])
+
+	// Fourth request has invalid project and subscription name, but project is caught first
+	suite.Equal(400, codes[3])
+	suite.Equal(invProject, responses[3])
+
+}
+
+func TestSubscriptionsHandlersTestSuite(t *testing.T) {
+	log.SetOutput(ioutil.Discard)
+	suite.Run(t, new(SubscriptionsHandlersTestSuite))
+}
+
+// retainingBroker is a mock broker whose offsets span all the messages of its list
+type retainingBroker struct {
+	brokers.MockBroker
+}
+
+func (b *retainingBroker) GetMinOffset(topic string) int64 {
+	return 0
+}
+
+func (b *retainingBroker) GetMaxOffset(topic string) int64 {
+	return int64(len(b.MsgList))
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubDrain() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := retainingBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	dir, _ := ioutil.TempDir("", "drains")
+	defer os.RemoveAll(dir)
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:drain", WrapMockAuthConfig(SubDrain, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// draining needs a sink
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:drain", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Equal(`{
+   "error": {
+      "code": 409,
+      "message": "Draining is not enabled, there is no drain sink configured",
+      "status": "CONFLICT"
+   }
+}`, w.Body.String())
+
+	cfgKafka.DrainSink = "file://" + dir
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:drain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	res := subscriptions.DrainResult{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal("/projects/ARGO/subscriptions/sub1", res.Subscription)
+	suite.Equal(int64(3), res.Messages)
+	suite.Equal(int64(0), res.OldOffset)
+	suite.Equal(int64(3), res.NewOffset)
+	content, _ := ioutil.ReadFile(res.Location)
+	suite.Equal(res.Bytes, int64(len(content)))
+	suite.Equal(3, strings.Count(string(content), "\n"))
+
+	sub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(3), sub.Offset)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:drain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	// sinks of schemes that haven't been registered can't be opened
+	cfgKafka.DrainSink = "unknown://bucket"
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub2:drain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(500, w.Code)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubOutstanding() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:outstanding", WrapMockAuthConfig(SubOutstanding, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	outstandingReq, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:outstanding", nil)
+
+	// nothing pulled yet
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, outstandingReq)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "outstandingMessages": []
+}`, w.Body.String())
+
+	// pull two messages
+	pullReq, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, pullReq)
+	suite.Equal(200, w2.Code)
+
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, outstandingReq)
+	suite.Equal(200, w3.Code)
+	deadline := str.SubList[0].PendingAck
+	pendingOn, _ := time.Parse("2006-01-02T15:04:05Z", deadline)
+	expDeadline := pendingOn.Add(10 * time.Second).Format("2006-01-02T15:04:05Z")
+	expResp := `{
+   "outstandingMessages": [
+      {
+         "messageId": "0",
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
+         "pullTime": "{{PT}}",
+         "ackDeadline": "{{DL}}"
+      },
+      {
+         "messageId": "1",
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:1",
+         "pullTime": "{{PT}}",
+         "ackDeadline": "{{DL}}"
+      }
+   ]
+}`
+	expResp = strings.Replace(expResp, "{{PT}}", pendingOn.Format("2006-01-02T15:04:05Z"), -1)
+	suite.Equal(strings.Replace(expResp, "{{DL}}", expDeadline, -1), w3.Body.String())
+
+	// acknowledge them
+	ackReq, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["v1/projects/ARGO/subscriptions/sub1:1"]}`))
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, ackReq)
+	suite.Equal(200, w4.Code)
+
+	w5 := httptest.NewRecorder()
+	router.ServeHTTP(w5, outstandingReq)
+	suite.Equal(200, w5.Code)
+	suite.Equal(`{
+   "outstandingMessages": []
+}`, w5.Body.String())
+
+	// unknown subscription
+	notFoundReq, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:outstanding", nil)
+	w6 := httptest.NewRecorder()
+	router.ServeHTTP(w6, notFoundReq)
+	suite.Equal(404, w6.Code)
+}
+
+// offsetBroker is a mock broker that serves its queued messages starting from the requested offset
+type offsetBroker struct {
+	brokers.MockBroker
+}
+
+func (b *offsetBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	if offset >= int64(len(b.MsgList)) {
+		return []string{}, nil
+	}
+	return b.MsgList[offset:], nil
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubStream() {
+
+	defaultHeartbeat, defaultPoll := sseHeartbeatInterval, ssePollInterval
+	sseHeartbeatInterval, ssePollInterval = 20*time.Millisecond, 5*time.Millisecond
+	defer func() {
+		sseHeartbeatInterval, ssePollInterval = defaultHeartbeat, defaultPoll
+	}()
+
+	type td struct {
+		disableAutoOffsetAdvance bool
+		expectedOffset           int64
+		msg                      string
+	}
+
+	testData := []td{
+		{
+			disableAutoOffsetAdvance: false,
+			expectedOffset:           3,
+			msg:                      "Streamed events advance the subscription's offset",
+		},
+		{
+			disableAutoOffsetAdvance: true,
+			expectedOffset:           0,
+			msg:                      "Streaming is peek-only when auto offset advance is disabled",
+		},
+	}
+
+	for _, t := range testData {
+
+		cfgKafka := config.NewAPICfg()
+		cfgKafka.LoadStrJSON(suite.cfgStr)
+		cfgKafka.DisableAutoOffsetAdvance = t.disableAutoOffsetAdvance
+		brk := offsetBroker{}
+		brk.Initialize([]string{"localhost"})
+		brk.PopulateThree()
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		mgr := oldPush.Manager{}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:stream", nil)
+		req = req.WithContext(ctx)
+		router := mux.NewRouter().StrictSlash(true)
+		w := httptest.NewRecorder()
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:stream", WrapMockAuthConfig(SubStream, cfgKafka, &brk, str, &mgr, nil))
+
+		done := make(chan struct{})
+		go func() {
+			router.ServeHTTP(w, req)
+			close(done)
+		}()
+
+		// let the stream deliver the queued messages and emit a heartbeat, then disconnect
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+		<-done
+
+		body := w.Body.String()
+		suite.Equal(200, w.Code, t.msg)
+		suite.Equal("text/event-stream", w.Header().Get("Content-Type"), t.msg)
+		suite.Equal("no-cache", w.Header().Get("Cache-Control"), t.msg)
+		suite.Contains(body, "id: 0\nevent: message\ndata: {\"ackId\":\"v1/projects/ARGO/subscriptions/sub1:0\"", t.msg)
+		suite.Contains(body, "id: 2\nevent: message\ndata: {\"ackId\":\"v1/projects/ARGO/subscriptions/sub1:2\"", t.msg)
+		suite.Equal(3, strings.Count(body, "event: message"), t.msg)
+		suite.Contains(body, ": heartbeat\n\n", t.msg)
+		suite.Equal(t.expectedOffset, str.SubList[0].Offset, t.msg)
+	}
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubStreamTransform() {
+
+	defaultHeartbeat, defaultPoll := sseHeartbeatInterval, ssePollInterval
+	sseHeartbeatInterval, ssePollInterval = 20*time.Millisecond, 5*time.Millisecond
+	defer func() {
+		sseHeartbeatInterval, ssePollInterval = defaultHeartbeat, defaultPoll
+	}()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"envelope":{"body":"hello world!"}}`))
+	zw.Close()
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := offsetBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "data": "` + b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello world!"}}`)) + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`, `{
+  "messageId": "1",
+  "attributes": {"compression": "gzip"},
+  "data": "` + b64.StdEncoding.EncodeToString(buf.Bytes()) + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[0].Transform = &stores.QTransform{Type: "extract_field", Field: "envelope.body"}
+	mgr := oldPush.Manager{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:stream", nil)
+	req = req.WithContext(ctx)
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:stream", WrapMockAuthConfig(SubStream, cfgKafka, &brk, str, &mgr, nil))
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	// events are decompressed and transformed the same way pulled messages are
+	body := w.Body.String()
+	suite.Equal(200, w.Code)
+	suite.Equal(2, strings.Count(body, "event: message"))
+	suite.Equal(2, strings.Count(body, `"data":"aGVsbG8gd29ybGQh"`))
+	suite.NotContains(body, "compression")
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubStreamNotFound() {
+
+	expResp := `{
+   "error": {
+      "code": 404,
+      "message": "Subscription doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:stream", nil)
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:stream", WrapMockAuthConfig(SubStream, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal("application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubReplay() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	brk.TopicTimeIndices = map[string][]brokers.TimeToOffset{}
+	brk.TopicTimeIndices["argo_uuid.topic4"] = []brokers.TimeToOffset{
+		{Timestamp: time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC), Offset: 2},
+		{Timestamp: time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC), Offset: 3},
+	}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:replay", WrapMockAuthConfig(SubReplay, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	type td struct {
+		sub          string
+		body         string
+		expectedCode int
+		msg          string
+	}
+
+	testData := []td{
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"replay1","offset":3}`,
+			expectedCode: 200,
+			msg:          "Replay from a valid offset",
+		},
+		{
+			sub:          "sub4",
+			body:         `{"subscription":"replay4","timestamp":"2019-06-11T12:00:00.000Z","copyAcl":true,"copyPushConfig":true}`,
+			expectedCode: 200,
+			msg:          "Replay from a timestamp copying acl and push config",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"replay5","offset":10}`,
+			expectedCode: 400,
+			msg:          "Offset out of bounds",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"replay5","offset":3,"timestamp":"2019-06-11T12:00:00.000Z"}`,
+			expectedCode: 400,
+			msg:          "Both offset and timestamp provided",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"replay5"}`,
+			expectedCode: 400,
+			msg:          "No starting position provided",
+		},
+		{
+			sub:          "sub4",
+			body:         `{"subscription":"replay5","timestamp":"2019-06-13T00:00:00.000Z"}`,
+			expectedCode: 409,
+			msg:          "Timestamp out of bounds",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"sub2","offset":3}`,
+			expectedCode: 409,
+			msg:          "Target subscription exists",
+		},
+		{
+			sub:          "unknown",
+			body:         `{"subscription":"replay5","offset":3}`,
+			expectedCode: 404,
+			msg:          "Source subscription doesn't exist",
+		},
+	}
+
+	for _, t := range testData {
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/"+t.sub+":replay", strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedCode, w.Code, t.msg)
+	}
+
+	replay1, _ := str.QueryOneSub("argo_uuid", "replay1")
+	suite.Equal("topic1", replay1.Topic)
+	suite.Equal(int64(3), replay1.Offset)
+	suite.Equal("", replay1.PushEndpoint)
+	replay1ACL, _ := str.QueryACL("argo_uuid", "subscriptions", "replay1")
+	suite.Equal(0, len(replay1ACL.ACL))
+
+	replay4, _ := str.QueryOneSub("argo_uuid", "replay4")
+	suite.Equal("topic4", replay4.Topic)
+	suite.Equal(int64(3), replay4.Offset)
+	suite.Equal("endpoint.foo", replay4.PushEndpoint)
+	suite.Equal("autogen", replay4.AuthorizationType)
+	suite.NotEqual("auth-header-1", replay4.AuthorizationHeader)
+	suite.NotEqual("push-id-1", replay4.VerificationHash)
+	suite.False(replay4.Verified)
+	srcACL, _ := str.QueryACL("argo_uuid", "subscriptions", "sub4")
+	replay4ACL, _ := str.QueryACL("argo_uuid", "subscriptions", "replay4")
+	suite.Equal(srcACL.ACL, replay4ACL.ACL)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubClone() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateSubOffset("argo_uuid", "sub4", 2)
+	str.ModSubLabels("argo_uuid", "sub4", map[string]string{"team": "alerts"})
+	mgr := oldPush.Manager{}
+
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:clone", WrapMockAuthConfig(SubClone, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	type td struct {
+		sub          string
+		body         string
+		expectedCode int
+		msg          string
+	}
+
+	testData := []td{
+		{
+			sub:          "sub4",
+			body:         `{"subscription":"clone4"}`,
+			expectedCode: 200,
+			msg:          "Clone from the current offset",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"clone1","offset":3}`,
+			expectedCode: 200,
+			msg:          "Clone from a valid offset",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"clone5","offset":10}`,
+			expectedCode: 400,
+			msg:          "Offset out of bounds",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"clone5","offset":3,"timestamp":"2019-06-11T12:00:00.000Z"}`,
+			expectedCode: 400,
+			msg:          "Both offset and timestamp provided",
+		},
+		{
+			sub:          "sub1",
+			body:         `{}`,
+			expectedCode: 400,
+			msg:          "No target subscription provided",
+		},
+		{
+			sub:          "sub1",
+			body:         `{"subscription":"sub2"}`,
+			expectedCode: 409,
+			msg:          "Target subscription exists",
+		},
+		{
+			sub:          "unknown",
+			body:         `{"subscription":"clone5"}`,
+			expectedCode: 404,
+			msg:          "Source subscription doesn't exist",
+		},
+	}
+
+	for _, t := range testData {
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/"+t.sub+":clone", strings.NewReader(t.body))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedCode, w.Code, t.msg)
+	}
+
+	clone4, _ := str.QueryOneSub("argo_uuid", "clone4")
+	suite.Equal("topic4", clone4.Topic)
+	suite.Equal(int64(2), clone4.Offset)
+	suite.Equal("endpoint.foo", clone4.PushEndpoint)
+	suite.Equal("autogen", clone4.AuthorizationType)
+	suite.NotEqual("auth-header-1", clone4.AuthorizationHeader)
+	suite.NotEqual("push-id-1", clone4.VerificationHash)
+	suite.False(clone4.Verified)
+	suite.Equal(map[string]string{"team": "alerts"}, clone4.Labels)
+	srcACL, _ := str.QueryACL("argo_uuid", "subscriptions", "sub4")
+	clone4ACL, _ := str.QueryACL("argo_uuid", "subscriptions", "clone4")
+	suite.Equal(srcACL.ACL, clone4ACL.ACL)
+
+	clone1, _ := str.QueryOneSub("argo_uuid", "clone1")
+	suite.Equal("topic1", clone1.Topic)
+	suite.Equal(int64(3), clone1.Offset)
+	src1ACL, _ := str.QueryACL("argo_uuid", "subscriptions", "sub1")
+	clone1ACL, _ := str.QueryACL("argo_uuid", "subscriptions", "clone1")
+	suite.Equal(src1ACL.ACL, clone1ACL.ACL)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullCompressed() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello world!"))
+	zw.Close()
+	compressedData := b64.StdEncoding.EncodeToString(buf.Bytes())
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "attributes": {"compression": "gzip", "foo": "bar"},
+  "data": "` + compressedData + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	expResp := `{
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "messageId": "0",
+            "attributes": {
+               {{ATTR}}
+            },
+            "data": "{{DATA}}",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 1,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
+}`
+
+	// messages are decompressed by default
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"1"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	decResp := strings.Replace(expResp, "{{ATTR}}", `"foo": "bar"`, 1)
+	decResp = strings.Replace(decResp, "{{DATA}}", "aGVsbG8gd29ybGQh", 1)
+	suite.Equal(decResp, w.Body.String())
+
+	// pass through the compressed data
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"1", "returnCompressed":"true"}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	compResp := strings.Replace(expResp, "{{ATTR}}", `"compression": "gzip",
+               "foo": "bar"`, 1)
+	compResp = strings.Replace(compResp, "{{DATA}}", compressedData, 1)
+	suite.Equal(compResp, w2.Body.String())
+
+	// messages that decompress beyond the max message size are delivered compressed
+	cfgKafka.MaxMessageSize = 5
+	req3, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"1"}`))
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(200, w3.Code)
+	suite.Equal(compResp, w3.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullTransform() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "data": "` + b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello world!"}}`)) + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`, `{
+  "messageId": "1",
+  "data": "bm90IGpzb24=",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[0].Transform = &stores.QTransform{Type: "extract_field", Field: "envelope.body"}
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	// the payload that can't be transformed is delivered as is
+	expResp := `{
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "messageId": "0",
+            "data": "aGVsbG8gd29ybGQh",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      },
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:1",
+         "message": {
+            "messageId": "1",
+            "data": "bm90IGpzb24=",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 2,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullTransformAsPushWorker() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "data": "` + b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello world!"}}`)) + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// sub4 is a push subscription, the push server consumes it through the pull requests of the push worker
+	str.SubList[3].Transform = &stores.QTransform{Type: "extract_field", Field: "envelope.body"}
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
+
+	expResp := `{
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub4:0",
+         "message": {
+            "messageId": "0",
+            "data": "aGVsbG8gd29ybGQh",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 1,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull", strings.NewReader(`{"maxMessages":"1"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullProjection() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.MsgList = []string{`{
+  "messageId": "0",
+  "data": "` + b64.StdEncoding.EncodeToString([]byte(`{"id":"m0","envelope":{"body":"hello world!","size":12}}`)) + `",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`, `{
+  "messageId": "1",
+  "data": "bm90IGpzb24=",
+  "publishTime": "2016-02-24T11:55:09.786127994Z"
+}`}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	// the payload that isn't json is delivered as is and flagged
+	expResp := `{
+   "receivedMessages": [
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:0",
+         "message": {
+            "messageId": "0",
+            "data": "` + b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello world!"},"id":"m0"}`)) + `",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      },
+      {
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:1",
+         "message": {
+            "messageId": "1",
+            "data": "bm90IGpzb24=",
+            "publishTime": "2016-02-24T11:55:09.786127994Z"
+         },
+         "unprojected": true,
+         "ackDeadline": "2020-11-25T14:30:10Z"
+      }
+   ],
+   "messageCount": 2,
+   "moreAvailable": true,
+   "deliverySemantics": "atLeastOnce"
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2","fields":["id","envelope.body"]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// malformed projections are rejected
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"fields":["envelope..body"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidFieldsError)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"fields":"id"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), subscriptions.InvalidFieldsError)
+}
+
+// maxRecordingBroker keeps track of the max number of messages requested by each consume call
+type maxRecordingBroker struct {
+	brokers.MockBroker
+	max []int64
+}
+
+func (b *maxRecordingBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	b.max = append(b.max, max)
+	return b.MockBroker.Consume(ctx, topic, offset, imm, max, isolation)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullMaxMessages() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := maxRecordingBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	expErr := `{
+   "error": {
+      "code": 400,
+      "message": "maxMessages must be a non-negative integer",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	tests := []struct {
+		body    string
+		code    int
+		expMax  int64
+		expResp string
+	}{
+		{body: `{}`, code: 200, expMax: 1},
+		{body: `{"maxMessages":""}`, code: 200, expMax: 1},
+		{body: `{"maxMessages":"0"}`, code: 200, expMax: 1},
+		{body: `{"maxMessages":"3"}`, code: 200, expMax: 3},
+		{body: `{"maxMessages":"-1"}`, code: 400, expResp: expErr},
+		{body: `{"maxMessages":"all"}`, code: 400, expResp: expErr},
+	}
+
+	for _, t := range tests {
+		brk.max = nil
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(t.body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.code, w.Code, t.body)
+		if t.code == 200 {
+			suite.Equal([]int64{t.expMax}, brk.max, t.body)
+			// the mock broker returns all of its messages, which should be trimmed down to the requested amount
+			suite.Equal(int(t.expMax), strings.Count(w.Body.String(), `"ackId"`), t.body)
+		} else {
+			suite.Equal(t.expResp, w.Body.String(), t.body)
+			// invalid requests never reach the broker
+			suite.Nil(brk.max, t.body)
+		}
+	}
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullSampling() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	pull := func() []string {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"3"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		recList := messages.RecList{}
+		json.Unmarshal(w.Body.Bytes(), &recList)
+		ids := []string{}
+		for _, rec := range recList.RecMsgs {
+			ids = append(ids, rec.Msg.ID)
+		}
+		return ids
+	}
+
+	// of the messages 0 to 2 only the first one is part of the sample
+	str.SubList[0].SamplingRate = 0.4
+	suite.Equal([]string{"0"}, pull())
+	suite.Equal(int64(0), str.SubList[0].Offset)
+
+	// the same messages are sampled however many times they get read
+	str.SubList[0].PendingAck = ""
+	suite.Equal([]string{"0"}, pull())
+
+	// the messages 1 and 2 are left out of the sample, so the offset moves past them without an ack
+	str.UpdateSubOffset("argo_uuid", "sub1", 1)
+	suite.Equal([]string{"3"}, pull())
+	suite.Equal(int64(3), str.SubList[0].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullDefaultMaxMessages() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := maxRecordingBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+
+	subscriptions.SetDefaultMaxMessages(2)
+	defer subscriptions.SetDefaultMaxMessages(0)
+
+	pull := func(sub string, body string) []int64 {
+		brk.max = nil
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/"+sub+":pull", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code, body)
+		return brk.max
+	}
+
+	// the service's default applies to subscriptions without a default of their own
+	suite.Equal([]int64{2}, pull("sub1", `{}`))
+
+	// the subscription's default takes precedence over the service's one
+	str.ModSubDefaultMaxMessages("argo_uuid", "sub1", 3)
+	suite.Equal([]int64{3}, pull("sub1", `{}`))
+	suite.Equal([]int64{3}, pull("sub1", `{"maxMessages":"0"}`))
+	suite.Equal([]int64{2}, pull("sub2", `{}`))
+
+	// while the request's maxMessages takes precedence over both
+	suite.Equal([]int64{1}, pull("sub1", `{"maxMessages":"1"}`))
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullInvalidOptions() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "maxMessages must be a non-negative integer",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"many"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	// nothing should have been consumed
+	suite.Equal(int64(0), str.SubList[0].Offset)
+
+	expResp2 := `{
+   "error": {
+      "code": 400,
+      "message": "ackIds must be a list of ack ids",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"AckIds":"v1/projects/ARGO/subscriptions/sub1:0"}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(400, w2.Code)
+	suite.Equal(expResp2, w2.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateTransform() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"transform": {"type": "extract_field", "field": "envelope.body"}
+}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"transform": {
+      "type": "extract_field",
+      "field": "envelope.body"
+   }`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.Equal(&stores.QTransform{Type: "extract_field", Field: "envelope.body"}, sub.Transform)
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Transform can only be of 'extract_field' type",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+	postJSON = `{
+	"topic":"projects/ARGO/topics/topic1",
+	"transform": {"type": "script", "field": "os.exit"}
+}`
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew2", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	suite.False(subscriptions.HasSub("argo_uuid", "subNew2", str))
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateNewMessagesOnly() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"newMessagesOnly": true
+}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"newMessagesOnly": true`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.True(sub.NewMessagesOnly)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullNewMessagesOnly() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// the first of the three messages was published before the subscription got created
+	str.SubList[0].CreatedOn = time.Date(2016, 2, 24, 11, 55, 9, 800000000, time.UTC)
+	str.SubList[0].NewMessagesOnly = true
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"3"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:0"`)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:1"`)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:2"`)
+	// the offset moved past the skipped message, while the delivered ones await their acknowledgement
+	suite.Equal(int64(1), str.SubList[0].Offset)
+	suite.Equal(int64(3), str.SubList[0].NextOffset)
+
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["v1/projects/ARGO/subscriptions/sub1:2"]}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.Equal(int64(3), str.SubList[0].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullNewMessagesOnlyAsPushWorker() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// sub4 is a push subscription, the push server consumes it through the pull requests of the push worker
+	str.SubList[3].CreatedOn = time.Date(2016, 2, 24, 11, 55, 9, 800000000, time.UTC)
+	str.SubList[3].NewMessagesOnly = true
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "push_worker"))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub4:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub4:0"`)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub4:1"`)
+	suite.Equal(int64(1), str.SubList[3].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubAckSignedAckIDs() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.AckIDSecret = "s3cr3t"
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	pulled := messages.RecList{}
+	json.Unmarshal(w.Body.Bytes(), &pulled)
+	suite.Equal(2, len(pulled.RecMsgs))
+	ackID, _ := subscriptions.ParseAckID(pulled.RecMsgs[1].AckID)
+	suite.True(ackID.Verify("s3cr3t"))
+
+	// ack ids that weren't issued by the service are rejected
+	forged := subscriptions.NewAckID("ARGO", "sub1", 2)
+	forged.Signature = ackID.Signature
+	for _, id := range []string{forged.String(), "v1/projects/ARGO/subscriptions/sub1:1"} {
+		req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["`+id+`"]}`))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(400, w.Code, id)
+		suite.Contains(w.Body.String(), "INVALID_ARGUMENT")
+	}
+	suite.Equal(int64(0), str.SubList[0].Offset)
+
+	// a bare offset can't prove that the messages were pulled from the service
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackOffset":1}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Acknowledging by offset is not allowed when ack ids are signed")
+	suite.Equal(int64(0), str.SubList[0].Offset)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["`+pulled.RecMsgs[1].AckID+`"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(int64(2), str.SubList[0].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubCreateDeduplicate() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"deduplicate": true
+}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"deduplicate": true`)
+	sub, _ := str.QueryOneSub("argo_uuid", "subNew")
+	suite.True(sub.Deduplicate)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubPullDeduplicate() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[0].Deduplicate = true
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"2"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:1"`)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["projects/ARGO/subscriptions/sub1:1"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	ids, _ := str.QueryAckedIDs("argo_uuid", "sub1")
+	suite.Equal([]string{"0", "1"}, ids)
+
+	// after a rewind only the message that was never acknowledged gets delivered again
+	str.SubList[0].Offset = 0
+	str.SubList[0].NextOffset = 0
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"3"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), `sub1:0"`)
+	suite.NotContains(w.Body.String(), `sub1:1"`)
+	suite.Contains(w.Body.String(), `"ackId": "v1/projects/ARGO/subscriptions/sub1:2"`)
+	suite.Equal(int64(2), str.SubList[0].Offset)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubLabels() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:updateLabels", WrapMockAuthConfig(SubUpdateLabels, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}", WrapMockAuthConfig(SubCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	postJSON := `{
+	"topic":"projects/ARGO/topics/topic1",
+	"labels": {"team": "sre", "env": "prod"}
+}`
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"labels": {
+      "env": "prod",
+      "team": "sre"
+   }`)
+
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/subscriptions/subNew2", strings.NewReader(`{"topic":"projects/ARGO/topics/topic1","labels":{"team":"sre team"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), labels.InvalidLabelValue)
+	suite.False(subscriptions.HasSub("argo_uuid", "subNew2", str))
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:updateLabels", strings.NewReader(`{"labels":{"team":"sre"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/unknown:updateLabels", strings.NewReader(`{"labels":{"team":"sre"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?labelSelector=team=sre", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res := subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(2), res.TotalSize)
+	suite.Equal("/projects/ARGO/subscriptions/subNew", res.Subscriptions[0].FullName)
+	suite.Equal("/projects/ARGO/subscriptions/sub1", res.Subscriptions[1].FullName)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?labelSelector=team=sre,env=prod", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	res = subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(1, len(res.Subscriptions))
+	suite.Equal("/projects/ARGO/subscriptions/subNew", res.Subscriptions[0].FullName)
+
+	// an empty set of labels removes them
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:updateLabels", strings.NewReader(`{"labels":{}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	sub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Nil(sub.Labels)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?labelSelector=team", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), labels.InvalidSelector)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllNamePrefix() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?namePrefix=sub2", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res := subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(1), res.TotalSize)
+	suite.Equal("/projects/ARGO/subscriptions/sub2", res.Subscriptions[0].FullName)
+
+	// the prefix is combined with the ordering by backlog as well
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?namePrefix=sub&orderBy=backlog", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res = subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(4, len(res.Subscriptions))
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?namePrefix=sub%2F", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid namePrefix")
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllEndpointHost() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.ModSubPush("argo_uuid", "sub1", "https://receiver.example.com:8443/push", "", "", 1, "linear", 300, "", true, nil, 0, 0, 0)
+	str.ModSubPush("argo_uuid", "sub2", "https://other.example.com/push", "", "", 1, "linear", 300, "", true, nil, 0, 0, 0)
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// the matches are returned in a single page, with their full endpoints
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?endpointHost=receiver.example.com&pageSize=1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res := subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(1), res.TotalSize)
+	suite.Equal("", res.NextPageToken)
+	suite.Equal("/projects/ARGO/subscriptions/sub1", res.Subscriptions[0].FullName)
+	suite.Equal("https://receiver.example.com:8443/push", res.Subscriptions[0].PushCfg.Pend)
+
+	// a host with a port matches only the endpoints on that port
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?endpointHost=receiver.example.com:443", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res = subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(0, len(res.Subscriptions))
+
+	// the host is combined with the ordering by backlog as well
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?endpointHost=other.example.com&orderBy=backlog", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res = subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(1, len(res.Subscriptions))
+	suite.Equal("/projects/ARGO/subscriptions/sub2", res.Subscriptions[0].FullName)
+	suite.NotNil(res.Subscriptions[0].Backlog)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubListAllOrderByBacklog() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/subscriptions", WrapMockAuthConfig(SubListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// the mock broker reports a max offset of 4 for every topic
+	str.SubList[0].Offset = 3
+	str.SubList[1].Offset = 0
+	str.SubList[2].Offset = 2
+	str.SubList[3].Offset = 4
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?orderBy=backlog", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	res := subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+
+	names := []string{}
+	backlogs := []int64{}
+	for _, sub := range res.Subscriptions {
+		names = append(names, sub.FullName)
+		backlogs = append(backlogs, *sub.Backlog)
+	}
+
+	suite.Equal([]string{
+		"/projects/ARGO/subscriptions/sub2",
+		"/projects/ARGO/subscriptions/sub3",
+		"/projects/ARGO/subscriptions/sub1",
+		"/projects/ARGO/subscriptions/sub4",
+	}, names)
+	suite.Equal([]int64{4, 2, 1, 0}, backlogs)
+	suite.Equal("", res.NextPageToken)
+	suite.Equal(int32(4), res.TotalSize)
+
+	// page size limits the returned subscriptions
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?orderBy=backlog&pageSize=1", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	res2 := subscriptions.PaginatedSubscriptions{}
+	json.Unmarshal(w2.Body.Bytes(), &res2)
+	suite.Equal(1, len(res2.Subscriptions))
+	suite.Equal("/projects/ARGO/subscriptions/sub2", res2.Subscriptions[0].FullName)
+
+	// unsupported ordering
+	req3, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/subscriptions?orderBy=name", nil)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(400, w3.Code)
+}
+
+// fakeClock is a clock whose time only moves when advanced explicitly
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestSubAckTimeoutWithClock() {
+
+	fc := &fakeClock{now: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)}
+
+	type td struct {
+		elapsed      time.Duration
+		expectedCode int
+		msg          string
+	}
+
+	testData := []td{
+		{
+			elapsed:      5 * time.Second,
+			expectedCode: 200,
+			msg:          "Ack within the deadline",
+		},
+		{
+			elapsed:      10 * time.Second,
+			expectedCode: 200,
+			msg:          "Ack right at the deadline",
+		},
+		{
+			elapsed:      11 * time.Second,
+			expectedCode: 408,
+			msg:          "Ack after the deadline",
+		},
+	}
+
+	for _, t := range testData {
+
+		cfgKafka := config.NewAPICfg()
+		cfgKafka.SetClock(fc.Now)
+		cfgKafka.LoadStrJSON(suite.cfgStr)
+		brk := brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		brk.PopulateThree()
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		mgr := oldPush.Manager{}
+		router := mux.NewRouter().StrictSlash(true)
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil))
+		router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:acknowledge", WrapMockAuthConfig(SubAck, cfgKafka, &brk, str, &mgr, nil))
+
+		pullReq, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{"maxMessages":"1"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, pullReq)
+		suite.Equal(200, w.Code, t.msg)
+		suite.Equal(fc.Now().Format("2006-01-02T15:04:05Z"), str.SubList[0].PendingAck, t.msg)
+
+		fc.Advance(t.elapsed)
+
+		ackReq, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:acknowledge", strings.NewReader(`{"ackIds":["projects/ARGO/subscriptions/sub1:0"]}`))
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w2, ackReq)
+		suite.Equal(t.expectedCode, w2.Code, t.msg)
+	}
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestOffsetReconciliation() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/offsets/reconciliation", WrapMockAuthConfig(OffsetReconciliation, cfgKafka, &brk, str, &mgr, nil))
+
+	// no reconciliation has run yet
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/offsets/reconciliation", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	str.UpdateSubOffset("argo_uuid", "sub1", 3)
+	_, err := subscriptions.ReconcileOffsets(time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC), str, &brk)
+	suite.Nil(err)
+
+	expResp := `{
+ "completed_at": "2020-11-25T10:00:00Z",
+ "checked": 4,
+ "adjustments": [
+  {
+   "project_uuid": "argo_uuid",
+   "subscription": "sub4",
+   "old_offset": 0,
+   "new_offset": 3
+  },
+  {
+   "project_uuid": "argo_uuid",
+   "subscription": "sub3",
+   "old_offset": 0,
+   "new_offset": 3
+  },
+  {
+   "project_uuid": "argo_uuid",
+   "subscription": "sub2",
+   "old_offset": 0,
+   "new_offset": 3
+  }
+ ]
+}`
+
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/offsets/reconciliation", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.Equal(expResp, w2.Body.String())
+}
+
+func (suite *SubscriptionsHandlersTestSuite) TestOffsetSnapshots() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	// the mock broker reports min offset 2 and max offset 3 for every topic
+	brk := brokers.MockBroker{MsgList: []string{"msg1", "msg2"}}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/offsets/snapshots", WrapMockAuthConfig(OffsetSnapshots, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/offsets/snapshots/{snapshot}:restore", WrapMockAuthConfig(OffsetSnapshotRestore, cfgKafka, &brk, str, &mgr, nil))
+
+	str.UpdateSubOffset("argo_uuid", "sub1", 2)
+	_, err := subscriptions.SnapshotOffsets(time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC), 0, str)
+	suite.Nil(err)
+
+	expList := `{
+ "snapshots": [
+  {
+   "name": "20201125T100000Z",
+   "created_on": "2020-11-25T10:00:00Z",
+   "subscriptions": 4
+  }
+ ]
+}`
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/offsets/snapshots", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expList, w.Body.String())
+
+	str.UpdateSubOffset("argo_uuid", "sub1", 3)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/offsets/snapshots/20201125T100000Z:restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"snapshot": "20201125T100000Z"`)
+	suite.Contains(w.Body.String(), `"skipped": []`)
+
+	// sub1 goes back to its offset, the rest are clamped to the min offset the broker holds
+	for sub, offset := range map[string]int64{"sub1": 2, "sub2": 2, "sub3": 2, "sub4": 2} {
+		qSub, _ := str.QueryOneSub("argo_uuid", sub)
+		suite.Equal(offset, qSub.Offset)
+	}
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/offsets/snapshots/unknown:restore", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "Offset snapshot doesn't exist")
 }
@@ -1,21 +1,28 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/events"
+	"github.com/ARGOeu/argo-messaging/labels"
 	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
 	"github.com/ARGOeu/argo-messaging/topics"
+	"github.com/ARGOeu/argo-messaging/validation"
 	gorillaContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -37,12 +44,44 @@ func TopicDelete(w http.ResponseWriter, r *http.Request) {
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	// when a grace period is configured, the topic is only marked as deleted
+	// and the reaper takes care of removing it from the store and the broker
+	if cfg.TopicDeleteGracePeriod > 0 {
+		err := topics.SoftDeleteTopic(projectUUID, urlVars["topic"], time.Now().UTC(), refStr)
+		if err != nil {
+			if err.Error() == "not found" {
+				if ignoreNotFound(r) {
+					respondOK(w, output)
+					return
+				}
+				err := APIErrorNotFound("Topic")
+				respondErr(w, err)
+				return
+			}
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+
+		emitEvent(r, events.TopicDeleted, "topics/"+urlVars["topic"])
+		respondOK(w, output)
+		return
+	}
+
+	// a renamed topic keeps its original broker topic, so resolve it before the topic leaves the store
+	fullTopic := topics.BrokerTopic(projectUUID, urlVars["topic"], refStr)
 
 	// Get Result Object
 
 	err := topics.RemoveTopic(projectUUID, urlVars["topic"], refStr)
 	if err != nil {
 		if err.Error() == "not found" {
+			if ignoreNotFound(r) {
+				respondOK(w, output)
+				return
+			}
 			err := APIErrorNotFound("Topic")
 			respondErr(w, err)
 			return
@@ -52,18 +91,19 @@ func TopicDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fullTopic := projectUUID + "." + urlVars["topic"]
 	err = refBrk.DeleteTopic(fullTopic)
 	if err != nil {
 		log.Errorf("Couldn't delete topic %v from broker, %v", fullTopic, err.Error())
 	}
 
+	emitEvent(r, events.TopicDeleted, "topics/"+urlVars["topic"])
+
 	// Write empty response if anything ok
 	respondOK(w, output)
 }
 
-// TopicModACL (PUT) modifies the ACL
-func TopicModACL(w http.ResponseWriter, r *http.Request) {
+// TopicUndelete (POST) restores a soft-deleted topic
+func TopicUndelete(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -75,45 +115,15 @@ func TopicModACL(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
-	// Get Result Object
-	urlTopic := urlVars["topic"]
-
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		err := APIErrorInvalidRequestBody()
-		respondErr(w, err)
-		return
-	}
-
-	// Parse pull options
-	postBody, err := auth.GetACLFromJSON(body)
-	if err != nil {
-		err := APIErrorInvalidArgument("Topic ACL")
-		respondErr(w, err)
-		log.Error(string(body[:]))
-		return
-	}
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// check if user list contain valid users for the given project
-	_, err = auth.AreValidUsers(projectUUID, postBody.AuthUsers, refStr)
-	if err != nil {
-		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
-		respondErr(w, err)
-		return
-	}
-
-	err = auth.ModACL(projectUUID, "topics", urlTopic, postBody.AuthUsers, refStr)
-
+	res, err := topics.UndeleteTopic(projectUUID, urlVars["topic"], refStr)
 	if err != nil {
-
 		if err.Error() == "not found" {
-			err := APIErrorNotFound("Topic")
+			err := APIErrorNotFound("Deleted topic")
 			respondErr(w, err)
 			return
 		}
@@ -122,12 +132,21 @@ func TopicModACL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	respondOK(w, output)
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
 
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
 }
 
-// TopicCreate (PUT) creates a new  topic
-func TopicCreate(w http.ResponseWriter, r *http.Request) {
+// TopicRename (PUT) renames a topic along with the subscriptions attached to it
+func TopicRename(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -144,73 +163,48 @@ func TopicCreate(w http.ResponseWriter, r *http.Request) {
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	postBody := map[string]string{}
-	schemaUUID := ""
-
-	// check if there's a request body provided before trying to decode
-	if r.Body != nil {
-
-		b, err := ioutil.ReadAll(r.Body)
-
-		if err != nil {
-			err := APIErrorInvalidRequestBody()
-			respondErr(w, err)
-			return
-		}
-		defer r.Body.Close()
-
-		if len(b) > 0 {
-			err = json.Unmarshal(b, &postBody)
-			if err != nil {
-				err := APIErrorInvalidRequestBody()
-				respondErr(w, err)
-				return
-			}
-
-			schemaRef := postBody["schema"]
-
-			// if there was a schema name provided, check its existence
-			if schemaRef != "" {
-				_, schemaName, err := schemas.ExtractSchema(schemaRef)
-				if err != nil {
-					err := APIErrorInvalidData(err.Error())
-					respondErr(w, err)
-					return
-				}
-				sl, err := schemas.Find(projectUUID, "", schemaName, refStr)
-				if err != nil {
-					err := APIErrGenericInternal(err.Error())
-					respondErr(w, err)
-					return
-				}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
 
-				if sl.Empty() {
-					err := APIErrorNotFound("Schema")
-					respondErr(w, err)
-					return
-				}
+	postBody := topics.RenameOptions{}
+	body = importJSON(r, body, postBody)
+	if err := json.Unmarshal(body, &postBody); err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
 
-				schemaUUID = sl.Schemas[0].UUID
-			}
-		}
+	if !validation.ValidName(postBody.Name) {
+		err := APIErrorInvalidName("Topic")
+		respondErr(w, err)
+		return
 	}
 
-	created := time.Now().UTC()
+	if postBody.Name == urlVars["topic"] {
+		err := APIErrorInvalidData("The new name of the topic should differ from its current one")
+		respondErr(w, err)
+		return
+	}
 
-	// Get Result Object
-	res, err := topics.CreateTopic(projectUUID, urlVars["topic"], schemaUUID, created, refStr)
+	res, err := topics.RenameTopic(projectUUID, urlVars["topic"], postBody.Name, refStr)
 	if err != nil {
-		if err.Error() == "exists" {
-			err := APIErrorConflict("Topic")
-			respondErr(w, err)
-			return
+		switch err.Error() {
+		case "not found":
+			respondErr(w, APIErrorNotFound("Topic"))
+		case "exists":
+			respondErr(w, APIErrorConflict("Topic"))
+		default:
+			respondErr(w, APIErrGenericInternal(err.Error()))
 		}
-		err := APIErrGenericInternal(err.Error())
-		respondErr(w, err)
+		return
 	}
 
 	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -222,8 +216,8 @@ func TopicCreate(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
-// TopicListOne (GET) one topic
-func TopicListOne(w http.ResponseWriter, r *http.Request) {
+// TopicUpdateLabels (POST) replaces the labels of a topic, an empty set of labels removes them
+func TopicUpdateLabels(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -235,43 +229,54 @@ func TopicListOne(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
 
-	// Grab context references
-	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
-
-	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, refStr)
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
 
+	postBody, err := labels.GetUpdateFromJSON(body)
 	if err != nil {
-		err := APIErrGenericBackend()
+		err := APIErrorInvalidArgument("Labels")
 		respondErr(w, err)
 		return
 	}
 
-	// If not found
-	if results.Empty() {
-		err := APIErrorNotFound("Topic")
+	if err := labels.Validate(postBody.Labels); err != nil {
+		err := APIErrorInvalidData(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	res := results.Topics[0]
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	err = topics.UpdateTopicLabels(projectUUID, urlTopic, postBody.Labels, refStr)
 	if err != nil {
-		err := APIErrExportJSON()
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	// Write response
-	output = []byte(resJSON)
 	respondOK(w, output)
 }
 
-// ListSubsByTopic (GET) lists all subscriptions associated with the given topic
-func ListSubsByTopic(w http.ResponseWriter, r *http.Request) {
+// TopicModPartitionKey (POST) modifies the message attribute that the partition key of a topic's messages is taken from
+func TopicModPartitionKey(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
 
 	// Add content type header to the response
 	contentType := "application/json"
@@ -280,46 +285,46 @@ func ListSubsByTopic(w http.ResponseWriter, r *http.Request) {
 
 	// Grab url path variables
 	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
 
-	// Grab context references
-	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
-
-	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, refStr)
-
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		err := APIErrGenericBackend()
+		err := APIErrorInvalidRequestBody()
 		respondErr(w, err)
 		return
 	}
 
-	// If not found
-	if results.Empty() {
-		err := APIErrorNotFound("Topic")
+	postBody := topics.PartitionKeyOptions{}
+	body = importJSON(r, body, postBody)
+	if err := json.Unmarshal(body, &postBody); err != nil {
+		err := APIErrorInvalidArgument("Partition key attribute")
 		respondErr(w, err)
 		return
 	}
 
-	subs, err := subscriptions.FindByTopic(projectUUID, results.Topics[0].Name, refStr)
-	if err != nil {
-		err := APIErrGenericBackend()
-		respondErr(w, err)
-		return
-
-	}
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	resJSON, err := json.Marshal(subs)
+	err = topics.UpdateTopicPartitionKey(projectUUID, urlTopic, postBody.Attribute, refStr)
 	if err != nil {
-		err := APIErrExportJSON()
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	respondOK(w, resJSON)
+	respondOK(w, output)
 }
 
-// TopicACL (GET) one topic's authorized users
-func TopicACL(w http.ResponseWriter, r *http.Request) {
+// TopicPurge (POST) advances the subscriptions of a topic past the messages older than the given age
+func TopicPurge(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -333,22 +338,68 @@ func TopicACL(w http.ResponseWriter, r *http.Request) {
 	urlVars := mux.Vars(r)
 	urlTopic := urlVars["topic"]
 
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	postBody := subscriptions.PurgeOptions{}
+	body = importJSON(r, body, postBody)
+	if err := json.Unmarshal(body, &postBody); err != nil {
+		err := APIErrorInvalidArgument("Purge")
+		respondErr(w, err)
+		return
+	}
+
+	if postBody.OlderThanSeconds <= 0 {
+		err := APIErrorInvalidData("Invalid age, older_than_seconds should be a positive number")
+		respondErr(w, err)
+		return
+	}
+
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
-
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
-	res, err := auth.GetACL(projectUUID, "topics", urlTopic, refStr)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 
-	// If not found
+	results, err := topics.Find(projectUUID, "", urlTopic, "", 0, false, refStr)
 	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	if len(results.Topics) == 0 {
 		err := APIErrorNotFound("Topic")
 		respondErr(w, err)
 		return
 	}
 
+	cutoff := cfg.Now().UTC().Add(-time.Duration(postBody.OlderThanSeconds) * time.Second)
+
+	// the broker's time index knows the publish times of the messages, the store keeps them only for brokers without one
+	off, err := topics.OffsetAt(projectUUID, urlTopic, cutoff, refStr, refBrk)
+	if err != nil {
+		log.Errorf(err.Error())
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	res, err := subscriptions.PurgeTopic(projectUUID, urlTopic, results.Topics[0].BrokerTopic, cutoff, off, postBody.DeleteRecords, refStr, refBrk)
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
 	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+	resJSON, err := exportJSON(r, &res)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
@@ -360,13 +411,8 @@ func TopicACL(w http.ResponseWriter, r *http.Request) {
 	respondOK(w, output)
 }
 
-// TopicListAll (GET) all topics
-func TopicListAll(w http.ResponseWriter, r *http.Request) {
-
-	var err error
-	var strPageSize string
-	var pageSize int
-	var res topics.PaginatedTopics
+// TopicModACL (PUT) modifies the ACL
+func TopicModACL(w http.ResponseWriter, r *http.Request) {
 
 	// Init output
 	output := []byte("")
@@ -376,51 +422,1083 @@ func TopicListAll(w http.ResponseWriter, r *http.Request) {
 	charset := "utf-8"
 	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
 
-	// Grab context references
-	refStr := gorillaContext.Get(r, "str").(stores.Store)
-	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
-	roles := gorillaContext.Get(r, "auth_roles").([]string)
-
-	urlValues := r.URL.Query()
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	// Get Result Object
+	urlTopic := urlVars["topic"]
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Parse pull options
+	postBody, err := auth.GetACLFromJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Topic ACL")
+		respondErr(w, err)
+		log.Error(string(body[:]))
+		return
+	}
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// check if user list contain valid users for the given project
+	_, err = auth.AreValidUsers(projectUUID, postBody.AuthUsers, refStr)
+	if err != nil {
+		err := APIErrorRoot{Body: APIErrorBody{Code: http.StatusNotFound, Message: err.Error(), Status: "NOT_FOUND"}}
+		respondErr(w, err)
+		return
+	}
+
+	// a validate only request reports what would change without persisting anything
+	if r.URL.Query().Get("validateOnly") == "true" {
+		diff, err := auth.DiffACL(projectUUID, "topics", urlTopic, postBody.AuthUsers, refStr)
+		if err != nil {
+			if err.Error() == "not found" {
+				err := APIErrorNotFound("Topic")
+				respondErr(w, err)
+				return
+			}
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+
+		resJSON, err := exportJSON(r, &diff)
+		if err != nil {
+			err := APIErrExportJSON()
+			respondErr(w, err)
+			return
+		}
+
+		respondOK(w, []byte(resJSON))
+		return
+	}
+
+	// a modification based on a stale read of the acl would silently undo the changes made since
+	version, err := aclIfMatch(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	err = auth.ModACLVersion(projectUUID, "topics", urlTopic, postBody.AuthUsers, version, refUserUUID, refStr)
+
+	if err != nil {
+
+		if err.Error() == "not found" {
+			err := APIErrorNotFound("Topic")
+			respondErr(w, err)
+			return
+		}
+		if err == auth.ErrACLVersionConflict {
+			err := APIErrorGenericConflict("The topic's acl has been modified since it was read, read it again and retry")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+
+}
+
+// TopicCreate (PUT) creates a new  topic
+func TopicCreate(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+
+	postBody := topics.CreateOptions{}
+	schemaUUID := ""
+	publishAcks := ""
+
+	// check if there's a request body provided before trying to decode
+	if r.Body != nil {
+
+		b, err := ioutil.ReadAll(r.Body)
+
+		if err != nil {
+			err := APIErrorInvalidRequestBody()
+			respondErr(w, err)
+			return
+		}
+		defer r.Body.Close()
+
+		if len(b) > 0 {
+			b = importJSON(r, b, postBody)
+			err = json.Unmarshal(b, &postBody)
+			if err != nil {
+				err := APIErrorInvalidRequestBody()
+				respondErr(w, err)
+				return
+			}
+
+			schemaRef := postBody.Schema
+
+			// if there was a schema name provided, check its existence
+			if schemaRef != "" {
+				_, schemaName, err := schemas.ExtractSchema(schemaRef)
+				if err != nil {
+					err := APIErrorInvalidData(err.Error())
+					respondErr(w, err)
+					return
+				}
+				sl, err := schemas.Find(projectUUID, "", schemaName, refStr)
+				if err != nil {
+					err := APIErrGenericInternal(err.Error())
+					respondErr(w, err)
+					return
+				}
+
+				if sl.Empty() {
+					err := APIErrorNotFound("Schema")
+					respondErr(w, err)
+					return
+				}
+
+				schemaUUID = sl.Schemas[0].UUID
+			}
+
+			publishAcks = strings.ToLower(postBody.PublishAcks)
+
+			if publishAcks != "" && !brokers.ValidAcksLevel(publishAcks) {
+				err := APIErrorInvalidData("Invalid publish acks level, it should be one of 0, 1 or all")
+				respondErr(w, err)
+				return
+			}
+
+			if postBody.Partitions < 0 || postBody.ReplicationFactor < 0 {
+				err := APIErrorInvalidData("Partitions and replication factor should be positive numbers")
+				respondErr(w, err)
+				return
+			}
+
+			if err := labels.Validate(postBody.Labels); err != nil {
+				err := APIErrorInvalidData(err.Error())
+				respondErr(w, err)
+				return
+			}
+		}
+	}
+
+	created := time.Now().UTC()
+
+	// Get Result Object
+	res, err := topics.CreateTopic(projectUUID, urlVars["topic"], schemaUUID, publishAcks, created, refStr)
+	if err != nil {
+		if err.Error() == "exists" {
+			err := APIErrorConflict("Topic")
+			respondErr(w, err)
+			return
+		}
+		if err.Error() == "broker topic in use" {
+			err := APIErrorGenericConflict("A renamed topic still uses the broker topic of this name")
+			respondErr(w, err)
+			return
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	if len(postBody.Labels) > 0 {
+		if err := topics.UpdateTopicLabels(projectUUID, urlVars["topic"], postBody.Labels, refStr); err != nil {
+			if rbErr := topics.RemoveTopic(projectUUID, urlVars["topic"], refStr); rbErr != nil {
+				log.Errorf("Could not roll back topic %v, %v", urlVars["topic"], rbErr.Error())
+			}
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+		res.Labels = postBody.Labels
+	}
+
+	if postBody.PartitionKeyAttribute != "" {
+		if err := topics.UpdateTopicPartitionKey(projectUUID, urlVars["topic"], postBody.PartitionKeyAttribute, refStr); err != nil {
+			if rbErr := topics.RemoveTopic(projectUUID, urlVars["topic"], refStr); rbErr != nil {
+				log.Errorf("Could not roll back topic %v, %v", urlVars["topic"], rbErr.Error())
+			}
+			err := APIErrGenericInternal(err.Error())
+			respondErr(w, err)
+			return
+		}
+		res.PartitionKeyAttribute = postBody.PartitionKeyAttribute
+	}
+
+	// the users of the project's default acl are granted access to the new topic
+	if err := auth.ApplyDefaultACL(projectUUID, "topics", urlVars["topic"], refUserUUID, refStr); err != nil {
+		if rbErr := topics.RemoveTopic(projectUUID, urlVars["topic"], refStr); rbErr != nil {
+			log.Errorf("Could not roll back topic %v, %v", urlVars["topic"], rbErr.Error())
+		}
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// Create the topic on the broker as well, rolling back the store entry if that fails
+	brkCfg, err := refBrk.CreateTopic(brokers.TopicName(projectUUID, urlVars["topic"]), postBody.Partitions, postBody.ReplicationFactor)
+	if err != nil {
+		if rbErr := topics.RemoveTopic(projectUUID, urlVars["topic"], refStr); rbErr != nil {
+			log.Errorf("Could not roll back topic %v, %v", urlVars["topic"], rbErr.Error())
+		}
+		if errors.Is(err, brokers.ErrInvalidTopicConfig) {
+			err := APIErrorInvalidData(err.Error())
+			respondErr(w, err)
+			return
+		}
+		log.Errorf("Could not create topic %v on the broker, %v", urlVars["topic"], err.Error())
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	res.BrokerConfig = &brkCfg
+	res.PublishAcks = res.EffectivePublishAcks(cfg.PublishAcks)
+
+	emitEvent(r, events.TopicCreated, "topics/"+urlVars["topic"])
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// TopicListOne (GET) one topic
+func TopicListOne(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+
+	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, false, refStr)
+
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// If not found
+	if results.Empty() {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	res := results.Topics[0]
+	res.PublishAcks = res.EffectivePublishAcks(cfg.PublishAcks)
+	if limits := topicPublishLimits(cfg); !limits.IsEmpty() {
+		res.PublishLimits = &limits
+	}
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondCacheable(w, r, output)
+}
+
+// TopicBrokerConfig (GET) shows the broker side configuration of a topic
+func TopicBrokerConfig(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	if !topics.HasTopic(projectUUID, urlVars["topic"], refStr) {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	topicCfg, err := refBrk.DescribeTopic(topics.BrokerTopic(projectUUID, urlVars["topic"], refStr))
+	if err != nil {
+		switch err {
+		case brokers.ErrIntrospectionUnsupported:
+			respondErr(w, APIErrorNotImplemented(err.Error()))
+		case brokers.ErrTopicNotFound:
+			respondErr(w, APIErrorNotFound("Broker topic"))
+		default:
+			log.Errorf("Could not describe topic %v, %v", urlVars["topic"], err.Error())
+			respondErr(w, APIErrGenericBackend())
+		}
+		return
+	}
+
+	output, err = json.MarshalIndent(topicCfg, "", "   ")
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, output)
+}
+
+// maxTailMessages is the max number of messages that can be requested when tailing a topic
+const maxTailMessages = 100
+
+// TopicTail (GET) returns the latest messages of a topic, read directly from the broker
+func TopicTail(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	n := 10
+	if strN := r.URL.Query().Get("n"); strN != "" {
+		var err error
+		n, err = strconv.Atoi(strN)
+		if err != nil || n < 1 || n > maxTailMessages {
+			err := APIErrorInvalidData(fmt.Sprintf("Invalid n, it should be between 1 and %v", maxTailMessages))
+			respondErr(w, err)
+			return
+		}
+	}
+
+	// only publishers and admins are allowed to inspect a topic's messages
+	if !auth.IsPublisher(refRoles) && !auth.IsProjectAdmin(refRoles) && !auth.IsServiceAdmin(refRoles) {
+		err := APIErrorForbidden()
+		respondErr(w, err)
+		return
+	}
+
+	if !topics.HasTopic(projectUUID, urlTopic, refStr) {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	// Check Authorization per topic
+	// - if enabled in config
+	// - if user has only publisher role
+	if refAuthResource && auth.IsPublisher(refRoles) {
+		if auth.PerResource(projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
+			respondErr(w, err)
+			return
+		}
+	}
+
+	fullTopic := topics.BrokerTopic(projectUUID, urlTopic, refStr)
+	to := refBrk.GetMaxOffset(fullTopic)
+	from := to - int64(n)
+	if minOff := refBrk.GetMinOffset(fullTopic); from < minOff {
+		from = minOff
+	}
+
+	msgs, err := refBrk.ConsumeRange(r.Context(), fullTopic, from, to)
+	if err != nil {
+		log.Errorf("Couldn't read the latest messages of topic %v, %v", urlTopic, err.Error())
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	msgList := messages.MsgList{Msgs: []messages.Message{}}
+	for i, msg := range msgs {
+		curMsg, err := messages.LoadMsgJSON([]byte(msg))
+		if err != nil {
+			err := APIErrGenericInternal("Message retrieved from broker network has invalid JSON Structure")
+			respondErr(w, err)
+			return
+		}
+		curMsg.ID = strconv.FormatInt(from+int64(i), 10)
+		msgList.Msgs = append(msgList.Msgs, curMsg)
+	}
+
+	resJSON, err := exportJSON(r, &msgList)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+const (
+	// maxSearchMessages is the max number of matching messages that can be requested when searching a topic
+	maxSearchMessages = 100
+	// maxSearchWindow is the max number of the latest messages of a topic that a search scans
+	maxSearchWindow = 1000
+)
+
+// TopicSearch (GET) returns the latest messages of a topic that carry the requested attributes,
+// read directly from the broker without affecting any subscription
+func TopicSearch(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	// every requested attribute has to be carried by a message for it to match
+	filter := messages.Attributes{}
+	for _, attr := range r.URL.Query()["attr"] {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			err := APIErrorInvalidData("Invalid attr, it should be in the form key=value")
+			respondErr(w, err)
+			return
+		}
+		filter[kv[0]] = kv[1]
+	}
+
+	if len(filter) == 0 {
+		err := APIErrorInvalidData("At least one attr should be specified")
+		respondErr(w, err)
+		return
+	}
+
+	limit := 10
+	if strLimit := r.URL.Query().Get("limit"); strLimit != "" {
+		var err error
+		limit, err = strconv.Atoi(strLimit)
+		if err != nil || limit < 1 || limit > maxSearchMessages {
+			err := APIErrorInvalidData(fmt.Sprintf("Invalid limit, it should be between 1 and %v", maxSearchMessages))
+			respondErr(w, err)
+			return
+		}
+	}
+
+	window := maxSearchWindow
+	if strWindow := r.URL.Query().Get("window"); strWindow != "" {
+		var err error
+		window, err = strconv.Atoi(strWindow)
+		if err != nil || window < 1 || window > maxSearchWindow {
+			err := APIErrorInvalidData(fmt.Sprintf("Invalid window, it should be between 1 and %v", maxSearchWindow))
+			respondErr(w, err)
+			return
+		}
+	}
+
+	// only publishers and admins are allowed to inspect a topic's messages
+	if !auth.IsPublisher(refRoles) && !auth.IsProjectAdmin(refRoles) && !auth.IsServiceAdmin(refRoles) {
+		err := APIErrorForbidden()
+		respondErr(w, err)
+		return
+	}
+
+	if !topics.HasTopic(projectUUID, urlTopic, refStr) {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	// Check Authorization per topic
+	// - if enabled in config
+	// - if user has only publisher role
+	if refAuthResource && auth.IsPublisher(refRoles) {
+		if auth.PerResource(projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
+			respondErr(w, err)
+			return
+		}
+	}
+
+	fullTopic := topics.BrokerTopic(projectUUID, urlTopic, refStr)
+	to := refBrk.GetMaxOffset(fullTopic)
+	from := to - int64(window)
+	if minOff := refBrk.GetMinOffset(fullTopic); from < minOff {
+		from = minOff
+	}
+
+	msgs, err := refBrk.ConsumeRange(r.Context(), fullTopic, from, to)
+	if err != nil {
+		log.Errorf("Couldn't search the latest messages of topic %v, %v", urlTopic, err.Error())
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	matches := []messages.Message{}
+	for i, msg := range msgs {
+		curMsg, err := messages.LoadMsgJSON([]byte(msg))
+		if err != nil {
+			err := APIErrGenericInternal("Message retrieved from broker network has invalid JSON Structure")
+			respondErr(w, err)
+			return
+		}
+
+		if !hasAttributes(curMsg, filter) {
+			continue
+		}
+
+		// the messages keep the id they were published with, unless they were stored without one
+		if curMsg.ID == "" {
+			curMsg.ID = strconv.FormatInt(from+int64(i), 10)
+		}
+		matches = append(matches, curMsg)
+	}
+
+	// keep the latest matches
+	if len(matches) > limit {
+		matches = matches[len(matches)-limit:]
+	}
+
+	msgList := messages.MsgList{Msgs: matches}
+	resJSON, err := exportJSON(r, &msgList)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// hasAttributes returns true if the message carries all the given attributes with the same values
+func hasAttributes(msg messages.Message, attrs messages.Attributes) bool {
+	for k, v := range attrs {
+		if value, ok := msg.Attr[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// ListSubsByTopic (GET) lists all subscriptions associated with the given topic
+func ListSubsByTopic(w http.ResponseWriter, r *http.Request) {
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, false, refStr)
+
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// If not found
+	if results.Empty() {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	subs, err := subscriptions.FindByTopic(projectUUID, results.Topics[0].Name, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+
+	}
+
+	resJSON, err := json.Marshal(subs)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	respondOK(w, resJSON)
+}
+
+// TopicACL (GET) one topic's authorized users
+func TopicACL(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	res, err := auth.GetACL(projectUUID, "topics", urlTopic, refStr)
+
+	// If not found
+	if err != nil {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// the etag lets a modification of the acl be based on this very read
+	w.Header().Set("ETag", aclETag(res.Version))
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// TopicACLHistory (GET) the history of one topic's acl changes
+func TopicACLHistory(w http.ResponseWriter, r *http.Request) {
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab url path variables
+	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	if !topics.HasTopic(projectUUID, urlTopic, refStr) {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	res, err := auth.GetACLHistory(projectUUID, "topics", urlTopic, refStr)
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// TopicListAll (GET) all topics
+func TopicListAll(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	var res topics.PaginatedTopics
+
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Grab context references
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+	roles := gorillaContext.Get(r, "auth_roles").([]string)
+
+	urlValues := r.URL.Query()
 	pageToken := urlValues.Get("pageToken")
-	strPageSize = urlValues.Get("pageSize")
+	showDeleted := urlValues.Get("showDeleted") == "true"
+
+	// if this route is used by a user who only  has a publisher role
+	// return all topics that he has access to
+	userUUID := ""
+	if !auth.IsProjectAdmin(roles) && !auth.IsServiceAdmin(roles) && auth.IsPublisher(roles) {
+		userUUID = gorillaContext.Get(r, "auth_user_uuid").(string)
+	}
+
+	pageSize, err := listPageSize(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	selector, err := labels.ParseSelector(urlValues.Get("labelSelector"))
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// the prefix is matched against stored names, so it can only consist of characters that are valid in a name
+	namePrefix := urlValues.Get("namePrefix")
+	if namePrefix != "" && !validation.ValidName(namePrefix) {
+		err := APIErrorInvalidData("Invalid namePrefix, it should only contain alphanumeric characters, underscores and dashes")
+		respondErr(w, err)
+		return
+	}
+
+	if res, err = topics.FindByLabels(projectUUID, userUUID, "", pageToken, int32(pageSize), showDeleted, selector, namePrefix, refStr); err != nil {
+		err := APIErrorInvalidData("Invalid page token")
+		respondErr(w, err)
+		return
+	}
+	// Output result to JSON
+	resJSON, err := exportJSON(r, &res)
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// Write Response
+	output = []byte(resJSON)
+	respondOK(w, output)
+}
+
+// TopicPublish (POST) publish messages to a topic
+func TopicPublish(w http.ResponseWriter, r *http.Request) {
+	// Init output
+	output := []byte("")
+
+	// Add content type header to the response
+	contentType := "application/json"
+	charset := "utf-8"
+	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
+
+	// Get url path variables
+	urlVars := mux.Vars(r)
+	urlTopic := urlVars["topic"]
+
+	// Grab context references
+
+	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
+	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
+	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
+	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
+	// Get project UUID First to use as reference
+	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
+
+	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, false, refStr)
+
+	if err != nil {
+		err := APIErrGenericBackend()
+		respondErr(w, err)
+		return
+	}
+
+	// If not found
+	if results.Empty() {
+		err := APIErrorNotFound("Topic")
+		respondErr(w, err)
+		return
+	}
+
+	res := results.Topics[0]
+
+	// Check Authorization per topic
+	// - if enabled in config
+	// - if user has only publisher role
+
+	if refAuthResource && auth.IsPublisher(refRoles) {
+
+		if auth.PerResource(projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
+			err := APIErrorForbidden()
+			respondErr(w, err)
+			return
+		}
+	}
+
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		err := APIErrorInvalidRequestBody()
+		respondErr(w, err)
+		return
+	}
+
+	// Create Message List from Post JSON
+	body = importJSON(r, body, messages.MsgList{})
+	msgList, err := messages.LoadMsgListJSON(body)
+	if err != nil {
+		err := APIErrorInvalidArgument("Message")
+		respondErr(w, err)
+		return
+	}
+
+	if err := msgList.ValidatePriorities(); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	payloadLimits := messages.PayloadLimits{MaxDepth: cfg.PayloadMaxDepth, MaxFields: cfg.PayloadMaxFields}
+	if err := msgList.ValidatePayloads(payloadLimits); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// timestamp of the publish event, which is also the publish time of the messages that don't declare their own
+	publishTime := cfg.Now().UTC()
+	maxSkew := time.Duration(cfg.PublishTimeMaxSkew) * time.Second
+	if err := msgList.AssignPublishTimes(publishTime, maxSkew); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// check the messages against the schema associated with the topic, if any
+	if apiErr := validateTopicSchema(projectUUID, res, msgList, refStr); apiErr != nil {
+		respondErr(w, *apiErr)
+		return
+	}
+
+	// producers learn how much of the topic's publish quota is left from every response, so that they can pace themselves
+	if limits := topicPublishLimits(cfg); !limits.IsEmpty() {
+		quota, retryAfter, ok := publishLimiter.Take(res.BrokerTopic, limits, len(msgList.Msgs), msgList.TotalSize(), cfg.Now())
+		setPublishQuotaHeaders(w, quota)
+		if !ok {
+			respondRateLimited(w, APIErrorPublishRateLimited(res.FullName), retryAfter)
+			return
+		}
+	}
 
-	// if this route is used by a user who only  has a publisher role
-	// return all topics that he has access to
-	userUUID := ""
-	if !auth.IsProjectAdmin(roles) && !auth.IsServiceAdmin(roles) && auth.IsPublisher(roles) {
-		userUUID = gorillaContext.Get(r, "auth_user_uuid").(string)
+	// Init message ids list
+	msgIDs := messages.MsgIDs{IDs: []string{}}
+
+	// with partial success every message is published independently and gets its own status
+	partialSuccess := r.URL.Query().Get("partialSuccess") == "true"
+	// a transactional publish either publishes all the messages or none of them
+	transactional := r.URL.Query().Get("transactional") == "true"
+	if partialSuccess && transactional {
+		err := APIErrorInvalidData("Transactional publishing can't be combined with partial success")
+		respondErr(w, err)
+		return
+	}
+	pubResults := PublishResults{Results: []PublishResult{}}
+	published := messages.MsgList{}
+
+	publishAcks := res.EffectivePublishAcks(cfg.PublishAcks)
+
+	// a pooled broker is held only while publishing the messages
+	pubBrk, releaseBrk, err := brokers.Acquire(r.Context(), refBrk)
+	if err != nil {
+		respondErr(w, APIErrorDeadlineExceeded())
+		return
 	}
 
-	if strPageSize != "" {
-		if pageSize, err = strconv.Atoi(strPageSize); err != nil {
-			log.Errorf("Pagesize %v produced an error  while being converted to int: %v", strPageSize, err.Error())
-			err := APIErrorInvalidData("Invalid page size")
+	if transactional {
+		ids, placements, apiErr := publishBatch(r.Context(), projectUUID, res, msgList, publishTime, pubBrk, refStr)
+		releaseBrk()
+		if apiErr != nil {
+			respondErr(w, *apiErr)
+			return
+		}
+
+		for i, msg := range msgList.Msgs {
+			msg.ID = ids[i]
+			published.Msgs = append(published.Msgs, msg)
+		}
+		msgIDs.IDs = ids
+		msgIDs.Placements = placements
+		recordPublishMetrics(projectUUID, res, published, publishTime, refStr)
+
+		resJSON, err := exportJSON(r, &msgIDs)
+		if err != nil {
+			err := APIErrExportJSON()
 			respondErr(w, err)
 			return
 		}
+		respondOK(w, []byte(resJSON))
+		return
 	}
 
-	if res, err = topics.Find(projectUUID, userUUID, "", pageToken, int32(pageSize), refStr); err != nil {
-		err := APIErrorInvalidData("Invalid page token")
-		respondErr(w, err)
+	// the placements are reported only if the broker reported them for every message
+	placements := []messages.MsgPlacement{}
+
+	// For each message in message list
+	for _, msg := range msgList.Msgs {
+
+		msgID, placement, apiErr := publishMessage(r.Context(), projectUUID, urlTopic, res.BrokerTopic, msg, publishAcks, res.RecordKey(msg), publishTime, pubBrk, refStr)
+		if apiErr != nil {
+			if !partialSuccess {
+				releaseBrk()
+				respondErr(w, *apiErr)
+				return
+			}
+			pubResults.Results = append(pubResults.Results, PublishResult{Error: &apiErr.Body})
+			continue
+		}
+
+		msg.ID = msgID
+		published.Msgs = append(published.Msgs, msg)
+
+		// Append the MsgID of the successful published message to the msgIds list
+		msgIDs.IDs = append(msgIDs.IDs, msg.ID)
+		pubResults.Results = append(pubResults.Results, PublishResult{ID: msg.ID, Placement: placement})
+		if placement != nil {
+			placements = append(placements, *placement)
+		}
+	}
+
+	releaseBrk()
+
+	if len(placements) == len(msgIDs.IDs) {
+		msgIDs.Placements = placements
+	}
+
+	// amount of messages published
+	msgCount := int64(len(published.Msgs))
+
+	if msgCount > 0 {
+		recordPublishMetrics(projectUUID, res, published, publishTime, refStr)
+	}
+
+	if partialSuccess {
+		output, err = json.MarshalIndent(pubResults, "", "   ")
+		if err != nil {
+			err := APIErrExportJSON()
+			respondErr(w, err)
+			return
+		}
+		// some of the messages failed, each one of them carries its own error
+		if msgCount < int64(len(msgList.Msgs)) {
+			w.WriteHeader(http.StatusMultiStatus)
+			w.Write(output)
+			return
+		}
+		respondOK(w, output)
 		return
 	}
-	// Output result to JSON
-	resJSON, err := res.ExportJSON()
+
+	// Export the msgIDs
+	resJSON, err := exportJSON(r, &msgIDs)
 	if err != nil {
 		err := APIErrExportJSON()
 		respondErr(w, err)
 		return
 	}
 
-	// Write Response
+	// Write response
 	output = []byte(resJSON)
 	respondOK(w, output)
 }
 
-// TopicPublish (POST) publish messages to a topic
-func TopicPublish(w http.ResponseWriter, r *http.Request) {
+// ProjectPublish (POST) publish the same messages to several topics of a project
+func ProjectPublish(w http.ResponseWriter, r *http.Request) {
 	// Init output
 	output := []byte("")
 
@@ -429,199 +1507,426 @@ func TopicPublish(w http.ResponseWriter, r *http.Request) {
 	charset := "utf-8"
 	w.Header().Add("Content-Type", fmt.Sprintf("%s; charset=%s", contentType, charset))
 
-	// Get url path variables
-	urlVars := mux.Vars(r)
-	urlTopic := urlVars["topic"]
-
 	// Grab context references
-
 	refBrk := gorillaContext.Get(r, "brk").(brokers.Broker)
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
 	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
 	refRoles := gorillaContext.Get(r, "auth_roles").([]string)
 	refAuthResource := gorillaContext.Get(r, "auth_resource").(bool)
+	cfg := gorillaContext.Get(r, "cfg").(*config.APICfg)
 	// Get project UUID First to use as reference
 	projectUUID := gorillaContext.Get(r, "auth_project_uuid").(string)
 
-	results, err := topics.Find(projectUUID, "", urlVars["topic"], "", 0, refStr)
-
+	// Read POST JSON body
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		err := APIErrGenericBackend()
+		err := APIErrorInvalidRequestBody()
 		respondErr(w, err)
 		return
 	}
 
-	// If not found
-	if results.Empty() {
-		err := APIErrorNotFound("Topic")
+	postBody := MultiPublishRequest{}
+	body = importJSON(r, body, postBody)
+	err = json.Unmarshal(body, &postBody)
+	if err != nil {
+		err := APIErrorInvalidArgument("Message")
 		respondErr(w, err)
 		return
 	}
 
-	res := results.Topics[0]
+	if len(postBody.Topics) == 0 {
+		err := APIErrorInvalidData("At least one target topic should be declared")
+		respondErr(w, err)
+		return
+	}
 
-	// Check Authorization per topic
-	// - if enabled in config
-	// - if user has only publisher role
+	if len(postBody.Msgs) == 0 {
+		err := APIErrorInvalidData("At least one message should be declared")
+		respondErr(w, err)
+		return
+	}
 
-	if refAuthResource && auth.IsPublisher(refRoles) {
+	msgList := messages.MsgList{Msgs: postBody.Msgs}
 
-		if auth.PerResource(projectUUID, "topics", urlTopic, refUserUUID, refStr) == false {
-			err := APIErrorForbidden()
-			respondErr(w, err)
-			return
-		}
+	if err := msgList.ValidatePriorities(); err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
 	}
 
-	// Read POST JSON body
-	body, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		err := APIErrorInvalidRequestBody()
+	payloadLimits := messages.PayloadLimits{MaxDepth: cfg.PayloadMaxDepth, MaxFields: cfg.PayloadMaxFields}
+	if err := msgList.ValidatePayloads(payloadLimits); err != nil {
+		err := APIErrorInvalidData(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	// Create Message List from Post JSON
-	msgList, err := messages.LoadMsgListJSON(body)
-	if err != nil {
-		err := APIErrorInvalidArgument("Message")
+	// timestamp of the publish event, shared by all the target topics
+	publishTime := cfg.Now().UTC()
+	maxSkew := time.Duration(cfg.PublishTimeMaxSkew) * time.Second
+	if err := msgList.AssignPublishTimes(publishTime, maxSkew); err != nil {
+		err := APIErrorInvalidData(err.Error())
 		respondErr(w, err)
 		return
 	}
 
-	// check if the topic has a schema associated with it
-	if res.Schema != "" {
+	// every target topic is checked before anything gets published,
+	// so that a request that can't be served as a whole doesn't publish to any of the topics
+	targets := []topics.Topic{}
+	declared := map[string]bool{}
+	for _, name := range postBody.Topics {
 
-		// retrieve the schema
-		_, schemaName, err := schemas.ExtractSchema(res.Schema)
-		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": res.Schema,
-					"topic_name":  res.Name,
-					"error":       err.Error(),
-				},
-			).Error("Could not extract schema name")
-			err := APIErrGenericInternal(schemas.GenericError)
+		if declared[name] {
+			err := APIErrorInvalidData("Target topics should be declared only once")
 			respondErr(w, err)
 			return
 		}
+		declared[name] = true
 
-		sl, err := schemas.Find(projectUUID, "", schemaName, refStr)
-
+		results, err := topics.Find(projectUUID, "", name, "", 0, false, refStr)
 		if err != nil {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": schemaName,
-					"topic_name":  res.Name,
-					"error":       err.Error(),
-				},
-			).Error("Could not retrieve schema from the store")
-			err := APIErrGenericInternal(schemas.GenericError)
+			err := APIErrGenericBackend()
 			respondErr(w, err)
 			return
 		}
 
-		if !sl.Empty() {
-			err := schemas.ValidateMessages(sl.Schemas[0], msgList)
-			if err != nil {
-				if err.Error() == "500" {
-					err := APIErrGenericInternal(schemas.GenericError)
-					respondErr(w, err)
-					return
-				} else {
-					err := APIErrorInvalidData(err.Error())
-					respondErr(w, err)
-					return
-				}
-			}
-		} else {
-			log.WithFields(
-				log.Fields{
-					"type":        "service_log",
-					"schema_name": res.Schema,
-					"topic_name":  res.Name,
-				},
-			).Error("List of schemas was empty")
-			err := APIErrGenericInternal(schemas.GenericError)
+		if results.Empty() {
+			err := APIErrorNotFound(fmt.Sprintf("Topic %v", name))
 			respondErr(w, err)
 			return
 		}
+
+		// publishers should be present in the acl of every target topic
+		if refAuthResource && auth.IsPublisher(refRoles) {
+			if auth.PerResource(projectUUID, "topics", name, refUserUUID, refStr) == false {
+				err := APIErrorForbiddenWithMsg(fmt.Sprintf("Not allowed to publish to topic %v", name))
+				respondErr(w, err)
+				return
+			}
+		}
+
+		if apiErr := validateTopicSchema(projectUUID, results.Topics[0], msgList, refStr); apiErr != nil {
+			respondErr(w, *apiErr)
+			return
+		}
+
+		// the quota taken from the topics checked before a rate limited one isn't given back
+		if limits := topicPublishLimits(cfg); !limits.IsEmpty() {
+			_, retryAfter, ok := publishLimiter.Take(results.Topics[0].BrokerTopic, limits, len(msgList.Msgs), msgList.TotalSize(), cfg.Now())
+			if !ok {
+				respondRateLimited(w, APIErrorPublishRateLimited(results.Topics[0].FullName), retryAfter)
+				return
+			}
+		}
+
+		targets = append(targets, results.Topics[0])
+	}
+
+	pubResults := MultiPublishResults{Results: []MultiPublishResult{}}
+	failed := false
+
+	// a pooled broker is held only while publishing the messages to all of the topics
+	pubBrk, releaseBrk, err := brokers.Acquire(r.Context(), refBrk)
+	if err != nil {
+		respondErr(w, APIErrorDeadlineExceeded())
+		return
+	}
+
+	// a failure stops the publishing to its own topic only, the messages already published can't be withdrawn
+	for _, t := range targets {
+
+		res := MultiPublishResult{Topic: t.FullName, IDs: []string{}}
+		published := messages.MsgList{}
+		publishAcks := t.EffectivePublishAcks(cfg.PublishAcks)
+		placements := []messages.MsgPlacement{}
+
+		for _, msg := range msgList.Msgs {
+			msgID, placement, apiErr := publishMessage(r.Context(), projectUUID, t.Name, t.BrokerTopic, msg, publishAcks, t.RecordKey(msg), publishTime, pubBrk, refStr)
+			if apiErr != nil {
+				res.Error = &apiErr.Body
+				failed = true
+				break
+			}
+			msg.ID = msgID
+			published.Msgs = append(published.Msgs, msg)
+			res.IDs = append(res.IDs, msgID)
+			if placement != nil {
+				placements = append(placements, *placement)
+			}
+		}
+
+		// the placements are reported only if the broker reported them for every published message
+		if len(placements) > 0 && len(placements) == len(res.IDs) {
+			res.Placements = placements
+		}
+
+		if len(published.Msgs) > 0 {
+			recordPublishMetrics(projectUUID, t, published, publishTime, refStr)
+		}
+
+		pubResults.Results = append(pubResults.Results, res)
 	}
 
-	// Init message ids list
-	msgIDs := messages.MsgIDs{IDs: []string{}}
+	releaseBrk()
 
-	// For each message in message list
-	for _, msg := range msgList.Msgs {
-		// Get offset and set it as msg
-		fullTopic := projectUUID + "." + urlTopic
+	output, err = json.MarshalIndent(pubResults, "", "   ")
+	if err != nil {
+		err := APIErrExportJSON()
+		respondErr(w, err)
+		return
+	}
+
+	// some of the topics failed, each one of them carries its own error
+	if failed {
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write(output)
+		return
+	}
+
+	respondOK(w, output)
+}
 
-		msgID, rTop, _, _, err := refBrk.Publish(fullTopic, msg)
+// MultiPublishRequest holds the messages that should be published to each one of the target topics
+type MultiPublishRequest struct {
+	Topics []string           `json:"topics"`
+	Msgs   []messages.Message `json:"messages"`
+}
+
+// MultiPublishResult holds the outcome of publishing to a single topic, the ids of the messages that got published,
+// where the broker placed them and the error that stopped the publishing, if any
+type MultiPublishResult struct {
+	Topic      string                  `json:"topic"`
+	IDs        []string                `json:"messageIds"`
+	Placements []messages.MsgPlacement `json:"placements,omitempty"`
+	Error      *APIErrorBody           `json:"error,omitempty"`
+}
+
+// MultiPublishResults holds the outcome of a publish request for every target topic, in the order they were declared
+type MultiPublishResults struct {
+	Results []MultiPublishResult `json:"results"`
+}
+
+// PublishResult holds the outcome of publishing a single message, either the message's id, along with where the broker
+// placed it, or the error that occurred
+type PublishResult struct {
+	ID        string                 `json:"messageId,omitempty"`
+	Placement *messages.MsgPlacement `json:"placement,omitempty"`
+	Error     *APIErrorBody          `json:"error,omitempty"`
+}
+
+// PublishResults holds the outcome of every message of a publish request, in the order they were sent
+type PublishResults struct {
+	Results []PublishResult `json:"results"`
+}
+
+// publishMessage publishes a single message, along with its partition key, to the topic's broker topic
+// and returns its id and the placement the broker reported for it, or the api error that should be reported for it.
+// The placement is nil when the broker reported no offset, since the publish didn't wait for an acknowledgement.
+// Nothing is published once the context is done, since the client no longer waits for the outcome
+func publishMessage(ctx context.Context, projectUUID string, topic string, fullTopic string, msg messages.Message, acks string, key string, publishTime time.Time, brk brokers.Broker, str stores.Store) (string, *messages.MsgPlacement, *APIErrorRoot) {
+
+	if ctx.Err() != nil {
+		err := APIErrorDeadlineExceeded()
+		return "", nil, &err
+	}
+
+	msgID, rTop, rPart, rOff, err := brk.Publish(fullTopic, msg, acks, key)
+
+	if err != nil {
+		if err.Error() == "kafka server: Message was too large, server rejected it to avoid allocation error." {
+			err := APIErrTooLargeMessage("Message size too large")
+			return "", nil, &err
+		}
 
+		err := APIErrGenericBackend()
+		return "", nil, &err
+	}
+
+	// Assertions for Succesfull Publish
+	if rTop != fullTopic {
+		err := APIErrGenericInternal("Broker reports wrong topic")
+		return "", nil, &err
+	}
+
+	var placement *messages.MsgPlacement
+	if rOff >= 0 {
+		placement = &messages.MsgPlacement{Partition: rPart, Offset: rOff}
+	}
+
+	// keep the publish time of the offset for brokers that can't look offsets up by timestamp
+	if off, err := strconv.ParseInt(msgID, 10, 64); err == nil {
+		err = topics.RecordPublishTime(projectUUID, topic, off, publishTime, str, brk)
 		if err != nil {
-			if err.Error() == "kafka server: Message was too large, server rejected it to avoid allocation error." {
-				err := APIErrTooLargeMessage("Message size too large")
-				respondErr(w, err)
-				return
-			}
+			log.Errorf("Could not record the publish time of offset %v of topic %v, %v", off, topic, err.Error())
+		}
+	}
 
-			err := APIErrGenericBackend()
-			respondErr(w, err)
-			return
+	return msgID, placement, nil
+}
+
+// publishBatch publishes the messages to the topic's broker topic all at once, so that either all of them
+// get published or none does, and returns their ids and placements, or the api error that should be reported for the batch
+func publishBatch(ctx context.Context, projectUUID string, topic topics.Topic, msgList messages.MsgList, publishTime time.Time, brk brokers.Broker, str stores.Store) ([]string, []messages.MsgPlacement, *APIErrorRoot) {
+
+	if ctx.Err() != nil {
+		err := APIErrorDeadlineExceeded()
+		return nil, nil, &err
+	}
+
+	keys := make([]string, 0, len(msgList.Msgs))
+	for _, msg := range msgList.Msgs {
+		keys = append(keys, topic.RecordKey(msg))
+	}
+
+	msgIDs, rTop, rPart, rOff, err := brk.PublishBatch(topic.BrokerTopic, msgList.Msgs, keys)
+	if err != nil {
+		if err == brokers.ErrTransactionsUnsupported {
+			err := APIErrorGenericConflict(err.Error())
+			return nil, nil, &err
 		}
 
-		msg.ID = msgID
-		// Assertions for Succesfull Publish
-		if rTop != fullTopic {
-			err := APIErrGenericInternal("Broker reports wrong topic")
-			respondErr(w, err)
-			return
+		if err.Error() == "kafka server: Message was too large, server rejected it to avoid allocation error." {
+			err := APIErrTooLargeMessage("Message size too large")
+			return nil, nil, &err
 		}
 
-		// Append the MsgID of the successful published message to the msgIds list
-		msgIDs.IDs = append(msgIDs.IDs, msg.ID)
+		err := APIErrGenericBackend()
+		return nil, nil, &err
 	}
 
-	// timestamp of the publish event
-	publishTime := time.Now().UTC()
+	if rTop != topic.BrokerTopic {
+		err := APIErrGenericInternal("Broker reports wrong topic")
+		return nil, nil, &err
+	}
 
-	// amount of messages published
-	msgCount := int64(len(msgList.Msgs))
+	for _, msgID := range msgIDs {
+		if off, err := strconv.ParseInt(msgID, 10, 64); err == nil {
+			err = topics.RecordPublishTime(projectUUID, topic.Name, off, publishTime, str, brk)
+			if err != nil {
+				log.Errorf("Could not record the publish time of offset %v of topic %v, %v", off, topic.Name, err.Error())
+			}
+		}
+	}
+
+	// the batch is appended to the partition as a whole, so its messages follow the offset of the first one
+	placements := make([]messages.MsgPlacement, 0, len(msgIDs))
+	for i := range msgIDs {
+		placements = append(placements, messages.MsgPlacement{Partition: rPart, Offset: rOff + int64(i)})
+	}
+
+	return msgIDs, placements, nil
+}
+
+// validateTopicSchema checks the messages against the schema associated with the topic, if any,
+// and returns the api error that should be reported for them
+func validateTopicSchema(projectUUID string, topic topics.Topic, msgList messages.MsgList, str stores.Store) *APIErrorRoot {
+
+	if topic.Schema == "" {
+		return nil
+	}
+
+	// retrieve the schema
+	_, schemaName, err := schemas.ExtractSchema(topic.Schema)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":        "service_log",
+				"schema_name": topic.Schema,
+				"topic_name":  topic.Name,
+				"error":       err.Error(),
+			},
+		).Error("Could not extract schema name")
+		err := APIErrGenericInternal(schemas.GenericError)
+		return &err
+	}
+
+	sl, err := schemas.Find(projectUUID, "", schemaName, str)
+
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":        "service_log",
+				"schema_name": schemaName,
+				"topic_name":  topic.Name,
+				"error":       err.Error(),
+			},
+		).Error("Could not retrieve schema from the store")
+		err := APIErrGenericInternal(schemas.GenericError)
+		return &err
+	}
+
+	if sl.Empty() {
+		log.WithFields(
+			log.Fields{
+				"type":        "service_log",
+				"schema_name": topic.Schema,
+				"topic_name":  topic.Name,
+			},
+		).Error("List of schemas was empty")
+		err := APIErrGenericInternal(schemas.GenericError)
+		return &err
+	}
+
+	err = schemas.ValidateMessages(sl.Schemas[0], msgList)
+	if err != nil {
+		if err.Error() == "500" {
+			err := APIErrGenericInternal(schemas.GenericError)
+			return &err
+		}
+		err := APIErrorInvalidData(err.Error())
+		return &err
+	}
+
+	return nil
+}
+
+// publishLimiter enforces the publish rate limits of the topics, each instance of the service keeping its own quotas
+var publishLimiter = topics.NewRateLimiter()
+
+// topicPublishLimits returns the publish rate limits that apply to every topic
+func topicPublishLimits(cfg *config.APICfg) topics.PublishLimits {
+	return topics.PublishLimits{
+		MessagesPerSecond: cfg.TopicPublishMaxMessagesRate,
+		BytesPerSecond:    cfg.TopicPublishMaxBytesRate,
+	}
+}
+
+// setPublishQuotaHeaders reports the publish rate limits of a topic and what is left of them through the response headers
+func setPublishQuotaHeaders(w http.ResponseWriter, quota topics.PublishQuota) {
+	if quota.Limits.MessagesPerSecond > 0 {
+		w.Header().Set("X-RateLimit-Messages-Limit", strconv.FormatFloat(quota.Limits.MessagesPerSecond, 'f', -1, 64))
+		w.Header().Set("X-RateLimit-Messages-Remaining", strconv.FormatInt(quota.RemainingMessages, 10))
+	}
+	if quota.Limits.BytesPerSecond > 0 {
+		w.Header().Set("X-RateLimit-Bytes-Limit", strconv.FormatFloat(quota.Limits.BytesPerSecond, 'f', -1, 64))
+		w.Header().Set("X-RateLimit-Bytes-Remaining", strconv.FormatInt(quota.RemainingBytes, 10))
+	}
+}
+
+// recordPublishMetrics updates the metrics of the topic after the given messages have been published
+func recordPublishMetrics(projectUUID string, topic topics.Topic, published messages.MsgList, publishTime time.Time, str stores.Store) {
+
+	msgCount := int64(len(published.Msgs))
 
 	// increment topic number of message metric
-	refStr.IncrementTopicMsgNum(projectUUID, urlTopic, msgCount)
+	str.IncrementTopicMsgNum(projectUUID, topic.Name, msgCount)
 
 	// increment daily count of topic messages
 	year, month, day := publishTime.Date()
-	refStr.IncrementDailyTopicMsgCount(projectUUID, urlTopic, msgCount, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+	str.IncrementDailyTopicMsgCount(projectUUID, topic.Name, msgCount, time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
 
 	// increment topic total bytes published
-	refStr.IncrementTopicBytes(projectUUID, urlTopic, msgList.TotalSize())
+	str.IncrementTopicBytes(projectUUID, topic.Name, published.TotalSize())
 
 	// update latest publish date for the given topic
-	refStr.UpdateTopicLatestPublish(projectUUID, urlTopic, publishTime)
+	str.UpdateTopicLatestPublish(projectUUID, topic.Name, publishTime)
 
 	// count the rate of published messages per sec between the last two publish events
 	var dt float64 = 1
 	// if its the first publish to the topic
 	// skip the subtraction that computes the DT between the last two publish events
-	if !res.LatestPublish.IsZero() {
-		dt = publishTime.Sub(res.LatestPublish).Seconds()
-	}
-	refStr.UpdateTopicPublishRate(projectUUID, urlTopic, float64(msgCount)/dt)
-
-	// Export the msgIDs
-	resJSON, err := msgIDs.ExportJSON()
-	if err != nil {
-		err := APIErrExportJSON()
-		respondErr(w, err)
-		return
+	if !topic.LatestPublish.IsZero() {
+		dt = publishTime.Sub(topic.LatestPublish).Seconds()
 	}
-
-	// Write response
-	output = []byte(resJSON)
-	respondOK(w, output)
+	str.UpdateTopicPublishRate(projectUUID, topic.Name, float64(msgCount)/dt)
 }
@@ -2,12 +2,19 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/events"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/topics"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/suite"
 	"io/ioutil"
@@ -69,95 +76,214 @@ func (suite *TopicsHandlersTestSuite) TestTopicDeleteNotfound() {
 
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicCreate() {
-
-	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	expResp := `{
-   "name": "/projects/ARGO/topics/topicNew",
-   "created_on": "{{CON}}"
-}`
+func (suite *TopicsHandlersTestSuite) TestTopicSoftDelete() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.TopicDeleteGracePeriod = 3600
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:undelete", WrapMockAuthConfig(TopicUndelete, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicDelete, cfgKafka, &brk, str, &mgr, nil))
+
+	// delete the topic
+	req, _ := http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
 	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
-	tp, _, _, _ := str.QueryTopics("argo_uuid", "", "topicNew", "", 1)
-	expResp = strings.Replace(expResp, "{{CON}}", tp[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Equal("", w.Body.String())
+
+	// the topic should still exist in the store, only marked as deleted
+	tpc, _, _, _ := str.QueryTopics("argo_uuid", "", "topic1", "", 0, true, nil, "")
+	suite.Equal(1, len(tpc))
+	suite.False(tpc[0].DeletedOn.IsZero())
 
+	// deleted topics are hidden from the listing by default
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.NotContains(w2.Body.String(), "/projects/ARGO/topics/topic1\"")
+	suite.Contains(w2.Body.String(), `"totalSize": 3`)
+
+	req3, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?showDeleted=true", nil)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(200, w3.Code)
+	suite.Contains(w3.Body.String(), "/projects/ARGO/topics/topic1\"")
+	suite.Contains(w3.Body.String(), `"deleted_on": "`)
+	suite.Contains(w3.Body.String(), `"totalSize": 4`)
+
+	// deleting it again should respond with not found
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, req)
+	suite.Equal(404, w4.Code)
+
+	// restore the topic
+	expResp := `{
+   "name": "/projects/ARGO/topics/topic1",
+   "created_on": "2020-11-22T00:00:00Z"
+}`
+	req5, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:undelete", nil)
+	w5 := httptest.NewRecorder()
+	router.ServeHTTP(w5, req5)
+	suite.Equal(200, w5.Code)
+	suite.Equal(expResp, w5.Body.String())
+
+	// a topic that isn't deleted can't be restored
+	expResp2 := `{
+   "error": {
+      "code": 404,
+      "message": "Deleted topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+	w6 := httptest.NewRecorder()
+	router.ServeHTTP(w6, req5)
+	suite.Equal(404, w6.Code)
+	suite.Equal(expResp2, w6.Body.String())
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicCreateExists() {
+func (suite *TopicsHandlersTestSuite) TestTopicDeleteIgnoreNotFound() {
 
-	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
-	if err != nil {
-		log.Fatal(err)
+	type td struct {
+		url                string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
 	}
 
-	expResp := `{
+	testData := []td{
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/topics/topicFoo",
+			expectedResponse: `{
    "error": {
-      "code": 409,
-      "message": "Topic already exists",
-      "status": "ALREADY_EXISTS"
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
    }
-}`
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing topic without ignoreNotFound",
+		},
+		{
+			url:                "http://localhost:8080/v1/projects/ARGO/topics/topicFoo?ignoreNotFound=true",
+			expectedResponse:   "",
+			expectedStatusCode: 200,
+			msg:                "Missing topic with ignoreNotFound",
+		},
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/topics/topicFoo?ignoreNotFound=false",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing topic with ignoreNotFound disabled",
+		},
+	}
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
-	router.ServeHTTP(w, req)
-	suite.Equal(409, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicDelete, cfgKafka, &brk, str, &mgr, nil))
+
+	for _, t := range testData {
+		req, _ := http.NewRequest("DELETE", t.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListOne() {
+func (suite *TopicsHandlersTestSuite) TestTopicSoftDeleteIgnoreNotFound() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
-	if err != nil {
-		log.Fatal(err)
+	type td struct {
+		url                string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
 	}
 
-	expResp := `{
-   "name": "/projects/ARGO/topics/topic1",
-   "created_on": "2020-11-22T00:00:00Z"
-}`
+	testData := []td{
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/topics/topicFoo",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing topic without ignoreNotFound",
+		},
+		{
+			url:                "http://localhost:8080/v1/projects/ARGO/topics/topicFoo?ignoreNotFound=true",
+			expectedResponse:   "",
+			expectedStatusCode: 200,
+			msg:                "Missing topic with ignoreNotFound",
+		},
+		{
+			url: "http://localhost:8080/v1/projects/ARGO/topics/topicFoo?ignoreNotFound=false",
+			expectedResponse: `{
+   "error": {
+      "code": 404,
+      "message": "Topic doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`,
+			expectedStatusCode: 404,
+			msg:                "Missing topic with ignoreNotFound disabled",
+		},
+	}
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.TopicDeleteGracePeriod = 3600
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicListOne, cfgKafka, &brk, str, &mgr, nil))
-	router.ServeHTTP(w, req)
-	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicDelete, cfgKafka, &brk, str, &mgr, nil))
+
+	for _, t := range testData {
+		req, _ := http.NewRequest("DELETE", t.url, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListSubscriptions() {
+func (suite *TopicsHandlersTestSuite) TestTopicCreate() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1/subscriptions", nil)
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{"subscriptions":["/projects/ARGO/subscriptions/sub1"]}`
+	expResp := `{
+   "name": "/projects/ARGO/topics/topicNew",
+   "created_on": "{{CON}}",
+   "publish_acks": "all",
+   "broker_config": {
+      "partitions": 1,
+      "replication_factor": 1,
+      "retention_ms": 604800000,
+      "retention_bytes": -1
+   }
+}`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
@@ -166,40 +292,79 @@ func (suite *TopicsHandlersTestSuite) TestTopicListSubscriptions() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}/subscriptions", WrapMockAuthConfig(ListSubsByTopic, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
+	tp, _, _, _ := str.QueryTopics("argo_uuid", "", "topicNew", "", 1, false, nil, "")
+	expResp = strings.Replace(expResp, "{{CON}}", tp[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
+
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListSubscriptionsEmpty() {
+// recordingEmitter keeps the lifecycle events emitted by the handlers
+type recordingEmitter struct {
+	emitted []events.Event
+}
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1/subscriptions", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+func (re *recordingEmitter) Emit(evt events.Event) error {
+	re.emitted = append(re.emitted, evt)
+	return nil
+}
 
-	expResp := `{"subscriptions":[]}`
+func (suite *TopicsHandlersTestSuite) TestTopicLifecycleEvents() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	str.SubList = nil
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}/subscriptions", WrapMockAuthConfig(ListSubsByTopic, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil)).Methods("PUT")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicDelete, cfgKafka, &brk, str, &mgr, nil)).Methods("DELETE")
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+
+	emitter := &recordingEmitter{}
+	p := events.NewPublisher(emitter)
+	events.SetPublisher(p)
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
-}
 
-func (suite *TopicsHandlersTestSuite) TestModTopicACL01() {
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topicNew:modifyAcl", strings.NewReader(`{"authorized_users":["UserZ"]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
 
-	postExp := `{"authorized_users":["UserX","UserZ"]}`
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
 
-	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	// a failed request emits nothing
+	req, _ = http.NewRequest("DELETE", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	events.SetPublisher(nil)
+	p.Close()
+
+	suite.Equal(3, len(emitter.emitted))
+	suite.Equal(events.TopicCreated, emitter.emitted[0].Type)
+	suite.Equal("/projects/ARGO/topics/topicNew", emitter.emitted[0].Resource)
+	suite.Equal("UserA", emitter.emitted[0].Actor)
+	suite.Equal(events.ACLModified, emitter.emitted[1].Type)
+	suite.Equal("/projects/ARGO/topics/topicNew", emitter.emitted[1].Resource)
+	suite.Equal("UserA", emitter.emitted[1].Actor)
+	suite.Equal(events.TopicDeleted, emitter.emitted[2].Type)
+	suite.Equal("/projects/ARGO/topics/topicNew", emitter.emitted[2].Resource)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicCreateDefaultACL() {
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -208,106 +373,240 @@ func (suite *TopicsHandlersTestSuite) TestModTopicACL01() {
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateProjectDefaultACL("argo_uuid", &stores.QDefaultACL{Topics: []string{"uuid2"}, Subscriptions: []string{"uuid1"}}, time.Now())
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal("", w.Body.String())
-
-	req2, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
-	w2 := httptest.NewRecorder()
-	router.ServeHTTP(w2, req2)
-	suite.Equal(200, w2.Code)
-
-	expResp := `{
-   "authorized_users": [
-      "UserX",
-      "UserZ"
-   ]
-}`
-
-	suite.Equal(expResp, w2.Body.String())
-
+	suite.Equal([]string{"uuid2"}, str.TopicsACL["topicNew"].ACL)
+	history, _ := str.QueryACLHistory("argo_uuid", "topics", "topicNew")
+	suite.Equal(1, len(history))
+	suite.Equal(auth.ACLOpDefault, history[0].Operation)
+	suite.Equal("uuid1", history[0].Actor)
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicACL01() {
-
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	expResp := `{
-   "authorized_users": [
-      "UserA",
-      "UserB"
-   ]
-}`
+func (suite *TopicsHandlersTestSuite) TestTopicLabels() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:updateLabels", WrapMockAuthConfig(TopicUpdateLabels, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", strings.NewReader(`{"labels":{"team":"sre","domain":"metrics"}}`))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Contains(w.Body.String(), `"labels": {
+      "domain": "metrics",
+      "team": "sre"
+   }`)
 
-}
+	req, _ = http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew2", strings.NewReader(`{"labels":{"-team":"sre"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), labels.InvalidLabelKey)
+	suite.False(topics.HasTopic("argo_uuid", "topicNew2", str))
 
-func (suite *TopicsHandlersTestSuite) TestTopicACL02() {
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:updateLabels", strings.NewReader(`{"labels":{"team":"ops"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic3:acl", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/unknown:updateLabels", strings.NewReader(`{"labels":{"team":"ops"}}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
 
-	expResp := `{
-   "authorized_users": [
-      "UserX"
-   ]
-}`
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?labelSelector=team+in+(sre,ops)", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res := topics.PaginatedTopics{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(2), res.TotalSize)
+	suite.Equal("/projects/ARGO/topics/topicNew", res.Topics[0].FullName)
+	suite.Equal("/projects/ARGO/topics/topic1", res.Topics[1].FullName)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?labelSelector=team=sre,domain=metrics", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	res = topics.PaginatedTopics{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(1, len(res.Topics))
+	suite.Equal("/projects/ARGO/topics/topicNew", res.Topics[0].FullName)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?labelSelector=team+in+sre", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), labels.InvalidSelector)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllNamePrefix() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?namePrefix=topic&pageSize=2", nil)
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	res := topics.PaginatedTopics{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(4), res.TotalSize)
+	suite.Equal(2, len(res.Topics))
+	suite.NotEqual("", res.NextPageToken)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?namePrefix=topic3", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res = topics.PaginatedTopics{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(int32(1), res.TotalSize)
+	suite.Equal("/projects/ARGO/topics/topic3", res.Topics[0].FullName)
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?namePrefix=TOPIC", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	res = topics.PaginatedTopics{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(0, len(res.Topics))
 
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?namePrefix=topic.*", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid namePrefix")
 }
 
-func (suite *TopicsHandlersTestSuite) TestModTopicACLWrong() {
+func (suite *TopicsHandlersTestSuite) TestTopicCreateBrokerConfig() {
 
-	postExp := `{"authorized_users":["UserX","UserFoo"]}`
+	type td struct {
+		createBody         string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
 
-	expRes := `{
+	testData := []td{
+		{
+			createBody: `{"partitions": 4, "replication_factor": 2}`,
+			expectedResponse: `{
+   "name": "/projects/ARGO/topics/topicBrk",
+   "created_on": "{{CON}}",
+   "publish_acks": "all",
+   "broker_config": {
+      "partitions": 4,
+      "replication_factor": 2,
+      "retention_ms": 604800000,
+      "retention_bytes": -1
+   }
+}`,
+			expectedStatusCode: 200,
+			msg:                "Topic with custom partitions and replication factor",
+		},
+		{
+			createBody: `{"partitions": 2}`,
+			expectedResponse: `{
+   "name": "/projects/ARGO/topics/topicBrk",
+   "created_on": "{{CON}}",
+   "publish_acks": "all",
+   "broker_config": {
+      "partitions": 2,
+      "replication_factor": 3,
+      "retention_ms": 604800000,
+      "retention_bytes": -1
+   }
+}`,
+			expectedStatusCode: 200,
+			msg:                "Topic with the default replication factor",
+		},
+		{
+			createBody: `{"replication_factor": 4}`,
+			expectedResponse: `{
    "error": {
-      "code": 404,
-      "message": "User(s): UserFoo do not exist",
-      "status": "NOT_FOUND"
+      "code": 400,
+      "message": "Invalid topic configuration: replication factor 4 is larger than the 3 available brokers",
+      "status": "INVALID_ARGUMENT"
    }
-}`
+}`,
+			expectedStatusCode: 400,
+			msg:                "Replication factor larger than the available brokers",
+		},
+		{
+			createBody: `{"partitions": -1}`,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Partitions and replication factor should be positive numbers",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Negative partitions",
+		},
+	}
 
-	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	for _, t := range testData {
+
+		cfgKafka := config.NewAPICfg()
+		cfgKafka.LoadStrJSON(suite.cfgStr)
+		brk := brokers.MockBroker{AvailableBrokers: 3}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		mgr := oldPush.Manager{}
+		router := mux.NewRouter().StrictSlash(true)
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
+
+		req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicBrk", strings.NewReader(t.createBody))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+
+		tp, _, _, _ := str.QueryTopics("argo_uuid", "", "topicBrk", "", 0, false, nil, "")
+
+		if t.expectedStatusCode != 200 {
+			suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+			// the store entry should not be left behind
+			suite.Equal(0, len(tp), t.msg)
+			continue
+		}
+
+		expResp := strings.Replace(t.expectedResponse, "{{CON}}", tp[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
+		suite.Equal(expResp, w.Body.String(), t.msg)
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicCreateExists() {
+
+	req, err := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	expResp := `{
+   "error": {
+      "code": 409,
+      "message": "Topic already exists",
+      "status": "ALREADY_EXISTS"
+   }
+}`
+
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
@@ -315,43 +614,125 @@ func (suite *TopicsHandlersTestSuite) TestModTopicACLWrong() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
-	suite.Equal(404, w.Code)
-	suite.Equal(expRes, w.Body.String())
+	suite.Equal(409, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPublishAcks() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+
+	type td struct {
+		createBody         string
+		expectedResponse   string
+		expectedStatusCode int
+		expectedAcks       string
+		msg                string
+	}
+
+	testData := []td{
+		{
+			createBody: `{"publish_acks": "1"}`,
+			expectedResponse: `{
+   "name": "/projects/ARGO/topics/topicAcks",
+   "created_on": "{{CON}}",
+   "publish_acks": "1",
+   "broker_config": {
+      "partitions": 1,
+      "replication_factor": 1,
+      "retention_ms": 604800000,
+      "retention_bytes": -1
+   }
+}`,
+			expectedStatusCode: 200,
+			expectedAcks:       "1",
+			msg:                "Topic with its own acks level",
+		},
+		{
+			createBody: "",
+			expectedResponse: `{
+   "name": "/projects/ARGO/topics/topicAcks",
+   "created_on": "{{CON}}",
+   "publish_acks": "0",
+   "broker_config": {
+      "partitions": 1,
+      "replication_factor": 1,
+      "retention_ms": 604800000,
+      "retention_bytes": -1
+   }
+}`,
+			expectedStatusCode: 200,
+			expectedAcks:       "0",
+			msg:                "Topic using the service wide acks level",
+		},
+		{
+			createBody: `{"publish_acks": "2"}`,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Invalid publish acks level, it should be one of 0, 1 or all",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			expectedStatusCode: 400,
+			msg:                "Unsupported acks level",
+		},
+	}
+
+	for _, t := range testData {
+
+		cfgKafka := config.NewAPICfg()
+		cfgKafka.LoadStrJSON(suite.cfgStr)
+		cfgKafka.PublishAcks = "0"
+		brk := brokers.MockBroker{}
+		str := stores.NewMockStore("whatever", "argo_mgs")
+		mgr := oldPush.Manager{}
+		router := mux.NewRouter().StrictSlash(true)
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
+
+		req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicAcks", strings.NewReader(t.createBody))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+
+		if t.expectedStatusCode != 200 {
+			suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+			continue
+		}
+
+		tp, _, _, _ := str.QueryTopics("argo_uuid", "", "topicAcks", "", 0, false, nil, "")
+		expResp := strings.Replace(t.expectedResponse, "{{CON}}", tp[0].CreatedOn.Format("2006-01-02T15:04:05Z"), 1)
+		suite.Equal(expResp, w.Body.String(), t.msg)
 
+		// the effective acks level should be passed on to the broker
+		req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topicAcks:publish", strings.NewReader(postJSON))
+		w2 := httptest.NewRecorder()
+		router.ServeHTTP(w2, req2)
+		suite.Equal(200, w2.Code, t.msg)
+		suite.Equal([]string{t.expectedAcks}, brk.PublishAcks, t.msg)
+	}
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAll() {
+func (suite *TopicsHandlersTestSuite) TestTopicListOne() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	expResp := `{
-   "topics": [
-      {
-         "name": "/projects/ARGO/topics/topic4",
-         "created_on": "2020-11-19T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/topics/topic3",
-         "schema": "projects/ARGO/schemas/schema-3",
-         "created_on": "2020-11-20T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/topics/topic2",
-         "schema": "projects/ARGO/schemas/schema-1",
-         "created_on": "2020-11-21T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/topics/topic1",
-         "created_on": "2020-11-22T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "",
-   "totalSize": 4
+   "name": "/projects/ARGO/topics/topic1",
+   "created_on": "2020-11-22T00:00:00Z",
+   "publish_acks": "all"
 }`
 
 	cfgKafka := config.NewAPICfg()
@@ -361,297 +742,273 @@ func (suite *TopicsHandlersTestSuite) TestTopicListAll() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicListOne, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllPublisher() {
-
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	expResp := `{
-   "topics": [
-      {
-         "name": "/projects/ARGO/topics/topic2",
-         "schema": "projects/ARGO/schemas/schema-1",
-         "created_on": "2020-11-21T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/topics/topic1",
-         "created_on": "2020-11-22T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "",
-   "totalSize": 2
-}`
+func (suite *TopicsHandlersTestSuite) TestTopicListOneETag() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicListOne, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
 	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "publisher"))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	etag := w.Header().Get("ETag")
+	suite.Equal(etagOf(w.Body.Bytes()), etag)
+
+	// an unchanged topic isn't sent again
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(304, w.Code)
+	suite.Equal("", w.Body.String())
+	suite.Equal(etag, w.Header().Get("ETag"))
+
+	// any change of the returned fields changes the etag
+	str.UpdateTopicLabels("argo_uuid", "topic1", map[string]string{"team": "ops"})
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotEqual(etag, w.Header().Get("ETag"))
+	suite.Contains(w.Body.String(), `"team": "ops"`)
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllPublisherWithPagination() {
+func (suite *TopicsHandlersTestSuite) TestTopicBrokerConfig() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=1", nil)
-	if err != nil {
-		log.Fatal(err)
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	brk.TopicConfigs = map[string]brokers.TopicConfig{
+		"argo_uuid.topic1": {Partitions: 3, ReplicationFactor: 2, RetentionMs: 604800000, RetentionBytes: -1},
 	}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:config", WrapMockAuthConfig(TopicBrokerConfig, cfgKafka, &brk, str, &mgr, nil))
 
 	expResp := `{
-   "topics": [
-      {
-         "name": "/projects/ARGO/topics/topic2",
-         "schema": "projects/ARGO/schemas/schema-1",
-         "created_on": "2020-11-21T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "MA==",
-   "totalSize": 2
+   "partitions": 3,
+   "replication_factor": 2,
+   "retention_ms": 604800000,
+   "retention_bytes": -1
+}`
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:config", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// topic registered in the store but not yet created on the broker
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic2:config", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(404, w2.Code)
+
+	// unknown topic
+	req3, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/unknown:config", nil)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(404, w3.Code)
+
+	// broker without introspection support
+	brk.NoIntrospection = true
+	expErr := `{
+   "error": {
+      "code": 501,
+      "message": "Topic introspection is not supported by the broker",
+      "status": "NOT_IMPLEMENTED"
+   }
 }`
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, req)
+	suite.Equal(501, w4.Code)
+	suite.Equal(expErr, w4.Body.String())
+}
+
+// tailBroker is a mock broker whose offsets match the positions of its queued messages
+type tailBroker struct {
+	brokers.MockBroker
+}
+
+func (b *tailBroker) GetMinOffset(topic string) int64 {
+	return 0
+}
+
+func (b *tailBroker) GetMaxOffset(topic string) int64 {
+	return int64(len(b.MsgList))
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicTail() {
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
+	brk := tailBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:tail", WrapMockAuthConfig(TopicTail, cfgKafka, &brk, str, &mgr, nil, "publisher"))
+
+	subOffsets := []int64{}
+	for _, sub := range str.SubList {
+		subOffsets = append(subOffsets, sub.Offset)
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:tail?n=2", nil)
 	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "publisher"))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
-}
 
-func (suite *TopicsHandlersTestSuite) TestPublishWithSchema() {
+	res := messages.MsgList{}
+	json.Unmarshal(w.Body.Bytes(), &res)
+	suite.Equal(2, len(res.Msgs))
+	suite.Equal("1", res.Msgs[0].ID)
+	suite.Equal("2", res.Msgs[1].ID)
 
-	type td struct {
-		topic              string
-		postBody           string
-		expectedResponse   string
-		expectedStatusCode int
-		msg                string
+	// asking for more messages than available returns all of them
+	req2, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:tail", nil)
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	res2 := messages.MsgList{}
+	json.Unmarshal(w2.Body.Bytes(), &res2)
+	suite.Equal(3, len(res2.Msgs))
+	suite.Equal("0", res2.Msgs[0].ID)
+
+	// no subscription offset should have been affected
+	for i, sub := range str.SubList {
+		suite.Equal(subOffsets[i], sub.Offset)
 	}
 
-	testData := []td{
-		{
-			topic: "topic2",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="
-		},
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
-		}
-	]
-}`,
-			expectedStatusCode: 200,
-			expectedResponse: `{
-   "messageIds": [
-      "1",
-      "2"
-   ]
-}`,
-			msg: "Case where the messages are validated successfully(JSON)",
-		},
-		{
-			topic: "topic3",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "DGFnZWxvc8T8Cg=="
-		},	
-		{
-			"attributes": {},
-			"data": "DGFnZWxvc8T8Cg=="
-		}
-	]
-}`,
-			expectedStatusCode: 200,
-			expectedResponse: `{
-   "messageIds": [
-      "3",
-      "4"
-   ]
-}`,
-			msg: "Case where the messages are validated successfully(AVRO)",
-		},
-		{
-			topic: "topic2",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6Njk0ODU2Nzg4OX0="
-		},
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
-		}
-	]
-}`,
-			expectedStatusCode: 400,
-			expectedResponse: `{
-   "error": {
-      "code": 400,
-      "message": "Message 0 data is not valid.1)(root): email is required.2)telephone: Invalid type. Expected: string, given: integer.",
-      "status": "INVALID_ARGUMENT"
-   }
-}`,
-			msg: "Case where one of the messages is not successfully validated(2 errors)",
-		},
-		{
-			topic: "topic3",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "T2JqAQQWYXZyby5zY2hlbWGYAnsidHlwZSI6InJlY29yZCIsIm5hbWUiOiJQbGFjZSIsIm5hbWVzcGFjZSI6InBsYWNlLmF2cm8iLCJmaWVsZHMiOlt7Im5hbWUiOiJwbGFjZW5hbWUiLCJ0eXBlIjoic3RyaW5nIn0seyJuYW1lIjoiYWRkcmVzcyIsInR5cGUiOiJzdHJpbmcifV19FGF2cm8uY29kZWMIbnVsbABM1P4b0GpYaCg9tqxa+YDZAiQSc3RyZWV0IDIyDnBsYWNlIGFM1P4b0GpYaCg9tqxa+YDZ"
-		},
-		
-		{
-			"attributes": {},
-			"data": "DGFnZWxvc8T8Cg=="
-		}
-	]
-}`,
-			expectedStatusCode: 400,
-			expectedResponse: `{
-   "error": {
-      "code": 400,
-      "message": "Message 0 is not valid.cannot decode binary record \"user.avro.User\" field \"username\": cannot decode binary string: cannot decode binary bytes: negative size: -40",
-      "status": "INVALID_ARGUMENT"
-   }
-}`,
-			msg: "Case where one of the messages is not successfully validated(1 error)(AVRO)",
-		},
-
-		{
-			topic: "topic2",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQo="
-		},
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
-		}
-	]
-}`,
-			expectedStatusCode: 400,
-			expectedResponse: `{
-   "error": {
-      "code": 400,
-      "message": "Message 0 data is not valid,(root): email is required",
-      "status": "INVALID_ARGUMENT"
-   }
-}`,
-			msg: "Case where the one of the messages is not successfully validated(1 error)",
-		},
-		{
-			topic: "topic2",
-			postBody: `{
-	"messages" : [
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
-		},
-		
-		{
-			"attributes": {},
-			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkiCg=="
-		}
-	]
-}`,
-			expectedStatusCode: 400,
-			expectedResponse: `{
-   "error": {
-      "code": 400,
-      "message": "Message 1 data is not valid JSON format,unexpected EOF",
-      "status": "INVALID_ARGUMENT"
-   }
-}`,
-			msg: "Case where the one of the messages is not in valid json format",
-		},
+	// invalid number of messages
+	for _, n := range []string{"0", "101", "foo"} {
+		reqN, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:tail?n="+n, nil)
+		wN := httptest.NewRecorder()
+		router.ServeHTTP(wN, reqN)
+		suite.Equal(400, wN.Code, n)
 	}
 
+	// unknown topic
+	req3, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/unknown:tail", nil)
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(404, w3.Code)
+
+	// consumers are not allowed to tail a topic
+	consumerRouter := mux.NewRouter().StrictSlash(true)
+	consumerRouter.HandleFunc("/v1/projects/{project}/topics/{topic}:tail", WrapMockAuthConfig(TopicTail, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	w4 := httptest.NewRecorder()
+	consumerRouter.ServeHTTP(w4, req)
+	suite.Equal(403, w4.Code)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicSearch() {
+
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	cfgKafka.PushEnabled = true
-	cfgKafka.PushWorkerToken = "push_token"
-	cfgKafka.ResAuth = false
-	brk := brokers.MockBroker{}
+	brk := tailBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	router := mux.NewRouter().StrictSlash(true)
 	mgr := oldPush.Manager{}
-	pc := new(push.MockClient)
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:search", WrapMockAuthConfig(TopicSearch, cfgKafka, &brk, str, &mgr, nil, "publisher"))
 
-	for _, t := range testData {
+	subOffsets := []int64{}
+	for _, sub := range str.SubList {
+		subOffsets = append(subOffsets, sub.Offset)
+	}
 
+	search := func(rt *mux.Router, query string) (*httptest.ResponseRecorder, messages.MsgList) {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:search?"+query, nil)
 		w := httptest.NewRecorder()
-		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%v", t.topic)
-		req, err := http.NewRequest("POST", url, strings.NewReader(t.postBody))
-		if err != nil {
-			log.Fatal(err)
-		}
-		router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, pc))
-		router.ServeHTTP(w, req)
+		rt.ServeHTTP(w, req)
+		res := messages.MsgList{}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		return w, res
+	}
 
-		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
-		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	w, res := search(router, "attr=foo2=bar2")
+	suite.Equal(200, w.Code)
+	suite.Equal(2, len(res.Msgs))
+	suite.Equal("1", res.Msgs[0].ID)
+	suite.Equal("2", res.Msgs[1].ID)
+
+	// the latest matches are kept
+	w, res = search(router, "attr=foo2=bar2&limit=1")
+	suite.Equal(200, w.Code)
+	suite.Equal(1, len(res.Msgs))
+	suite.Equal("2", res.Msgs[0].ID)
+
+	// every attribute has to match
+	w, res = search(router, "attr=foo=bar&attr=foo2=bar2")
+	suite.Equal(200, w.Code)
+	suite.Equal(0, len(res.Msgs))
+	suite.Equal("{\n   \"messages\": []\n}", w.Body.String())
+
+	// messages outside of the window are not scanned
+	w, res = search(router, "attr=foo=bar&window=2")
+	suite.Equal(200, w.Code)
+	suite.Equal(0, len(res.Msgs))
+
+	w, res = search(router, "attr=foo=bar&window=3")
+	suite.Equal(1, len(res.Msgs))
+	suite.Equal("0", res.Msgs[0].ID)
+
+	// the messages keep the id they were published with, e.g. when compaction removed the ones preceding them
+	brk.MsgList = append(brk.MsgList, `{"messageId": "5", "attributes": {"foo3": "bar3"}, "data": "YmFzZTY0ZW5jb2RlZA=="}`)
+	w, res = search(router, "attr=foo3=bar3")
+	suite.Equal(1, len(res.Msgs))
+	suite.Equal("5", res.Msgs[0].ID)
+	brk.MsgList = brk.MsgList[:3]
+
+	// no subscription offset should have been affected
+	for i, sub := range str.SubList {
+		suite.Equal(subOffsets[i], sub.Offset)
 	}
+
+	// invalid parameters
+	for _, query := range []string{"", "attr=foo", "attr==bar", "attr=foo=bar&limit=0", "attr=foo=bar&limit=101",
+		"attr=foo=bar&window=1001", "attr=foo=bar&window=foo"} {
+		w, _ = search(router, query)
+		suite.Equal(400, w.Code, query)
+	}
+
+	// unknown topic
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/unknown:search?attr=foo=bar", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	// consumers are not allowed to search a topic
+	consumerRouter := mux.NewRouter().StrictSlash(true)
+	consumerRouter.HandleFunc("/v1/projects/{project}/topics/{topic}:search", WrapMockAuthConfig(TopicSearch, cfgKafka, &brk, str, &mgr, nil, "consumer"))
+	w, _ = search(consumerRouter, "attr=foo=bar")
+	suite.Equal(403, w.Code)
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllFirstPage() {
+func (suite *TopicsHandlersTestSuite) TestTopicListSubscriptions() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1/subscriptions", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{
-   "topics": [
-      {
-         "name": "/projects/ARGO/topics/topic4",
-         "created_on": "2020-11-19T00:00:00Z"
-      },
-      {
-         "name": "/projects/ARGO/topics/topic3",
-         "schema": "projects/ARGO/schemas/schema-3",
-         "created_on": "2020-11-20T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "MQ==",
-   "totalSize": 4
-}`
+	expResp := `{"subscriptions":["/projects/ARGO/subscriptions/sub1"]}`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
@@ -660,235 +1017,1901 @@ func (suite *TopicsHandlersTestSuite) TestTopicListAllFirstPage() {
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}/subscriptions", WrapMockAuthConfig(ListSubsByTopic, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllNextPage() {
+func (suite *TopicsHandlersTestSuite) TestTopicListSubscriptionsEmpty() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2&pageToken=MA==", nil)
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1/subscriptions", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	expResp := `{
-   "topics": [
-      {
-         "name": "/projects/ARGO/topics/topic1",
-         "created_on": "2020-11-22T00:00:00Z"
-      }
-   ],
-   "nextPageToken": "",
-   "totalSize": 4
-}`
+	expResp := `{"subscriptions":[]}`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList = nil
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}/subscriptions", WrapMockAuthConfig(ListSubsByTopic, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expResp, w.Body.String())
-
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllEmpty() {
+func (suite *TopicsHandlersTestSuite) TestModTopicACL01() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2", nil)
+	postExp := `{"authorized_users":["UserX","UserZ"]}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+
+	req2, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
 
 	expResp := `{
-   "topics": [],
+   "authorized_users": [
+      "UserX",
+      "UserZ"
+   ]
+}`
+
+	suite.Equal(expResp, w2.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestModTopicACLIfMatch() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+
+	modify := func(ifMatch string, users string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modifyAcl", strings.NewReader(`{"authorized_users":[`+users+`]}`))
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	etag := w.Header().Get("ETag")
+	suite.Equal(`"0"`, etag)
+
+	// two pipelines read the same acl, the first modification bumps its version
+	suite.Equal(200, modify(etag, `"UserX"`).Code)
+
+	// and the second one, based on the stale read, is rejected without touching the acl
+	w = modify(etag, `"UserZ"`)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), "The topic's acl has been modified since it was read, read it again and retry")
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(`"1"`, w.Header().Get("ETag"))
+	suite.Contains(w.Body.String(), "UserX")
+	suite.NotContains(w.Body.String(), "UserZ")
+
+	suite.Equal(200, modify(`"1"`, `"UserZ"`).Code)
+
+	// modifications without If-Match, or with *, are unconditional
+	suite.Equal(200, modify("", `"UserX"`).Code)
+	suite.Equal(200, modify("*", `"UserZ"`).Code)
+
+	w = modify(`W/"4"`, `"UserX"`)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid If-Match header, it should hold the etag of the acl")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicACLHistory() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:aclHistory", WrapMockAuthConfig(TopicACLHistory, cfgKafka, &brk, str, &mgr, nil))
+
+	for _, postJSON := range []string{`{"authorized_users":["UserX","UserZ"]}`, `{"authorized_users":["UserZ"]}`} {
+		req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modifyAcl", bytes.NewBuffer([]byte(postJSON)))
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+	}
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:aclHistory", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	history := auth.ACLHistory{}
+	json.Unmarshal(w.Body.Bytes(), &history)
+	suite.Equal(2, len(history.Changes))
+	suite.Equal([]string{"UserX", "UserZ"}, history.Changes[0].AuthUsers)
+	suite.Equal([]string{"UserZ"}, history.Changes[1].AuthUsers)
+	suite.Equal("modify", history.Changes[1].Operation)
+	suite.Equal("UserA", history.Changes[1].ChangedBy)
+	suite.NotEqual("", history.Changes[1].ChangedOn)
+
+	// a topic whose acl never changed has an empty history
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic2:aclHistory", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("{\n   \"history\": []\n}", w.Body.String())
+
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/unknown:aclHistory", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicACL01() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1:acl", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "authorized_users": [
+      "UserA",
+      "UserB"
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicACL02() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic3:acl", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "authorized_users": [
+      "UserX"
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:acl", WrapMockAuthConfig(TopicACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestModTopicACLWrong() {
+
+	postExp := `{"authorized_users":["UserX","UserFoo"]}`
+
+	expRes := `{
+   "error": {
+      "code": 404,
+      "message": "User(s): UserFoo do not exist",
+      "status": "NOT_FOUND"
+   }
+}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl", bytes.NewBuffer([]byte(postExp)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expRes, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestModTopicACLValidateOnly() {
+
+	postExp := `{"authorized_users":["UserB","UserX"]}`
+
+	req, err := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl?validateOnly=true", bytes.NewBuffer([]byte(postExp)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "added": [
+      "UserX"
+   ],
+   "removed": [
+      "UserA"
+   ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modAcl", WrapMockAuthConfig(TopicModACL, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	// the acl should remain untouched
+	suite.Equal([]string{"uuid1", "uuid2"}, str.TopicsACL["topic1"].ACL)
+
+	// unknown users are reported the same way as when applying the change
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modAcl?validateOnly=true", bytes.NewBuffer([]byte(`{"authorized_users":["UserFoo"]}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Contains(w.Body.String(), "User(s): UserFoo do not exist")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAll() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [
+      {
+         "name": "/projects/ARGO/topics/topic4",
+         "created_on": "2020-11-19T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/topics/topic3",
+         "schema": "projects/ARGO/schemas/schema-3",
+         "created_on": "2020-11-20T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/topics/topic2",
+         "schema": "projects/ARGO/schemas/schema-1",
+         "created_on": "2020-11-21T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/topics/topic1",
+         "created_on": "2020-11-22T00:00:00Z"
+      }
+   ],
    "nextPageToken": "",
-   "totalSize": 0
+   "totalSize": 4
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllPublisher() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [
+      {
+         "name": "/projects/ARGO/topics/topic2",
+         "schema": "projects/ARGO/schemas/schema-1",
+         "created_on": "2020-11-21T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/topics/topic1",
+         "created_on": "2020-11-22T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 2
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "publisher"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllPublisherWithPagination() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=1", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [
+      {
+         "name": "/projects/ARGO/topics/topic2",
+         "schema": "projects/ARGO/schemas/schema-1",
+         "created_on": "2020-11-21T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "MA==",
+   "totalSize": 2
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "publisher"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishWithSchema() {
+
+	type td struct {
+		topic              string
+		postBody           string
+		expectedResponse   string
+		expectedStatusCode int
+		msg                string
+	}
+
+	testData := []td{
+		{
+			topic: "topic2",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20ifQ=="
+		},
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
+		}
+	]
+}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "1",
+      "2"
+   ],
+   "placements": [
+      {
+         "partition": 0,
+         "offset": 1
+      },
+      {
+         "partition": 0,
+         "offset": 2
+      }
+   ]
+}`,
+			msg: "Case where the messages are validated successfully(JSON)",
+		},
+		{
+			topic: "topic3",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "DGFnZWxvc8T8Cg=="
+		},	
+		{
+			"attributes": {},
+			"data": "DGFnZWxvc8T8Cg=="
+		}
+	]
+}`,
+			expectedStatusCode: 200,
+			expectedResponse: `{
+   "messageIds": [
+      "3",
+      "4"
+   ],
+   "placements": [
+      {
+         "partition": 0,
+         "offset": 3
+      },
+      {
+         "partition": 0,
+         "offset": 4
+      }
+   ]
+}`,
+			msg: "Case where the messages are validated successfully(AVRO)",
+		},
+		{
+			topic: "topic2",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6Njk0ODU2Nzg4OX0="
+		},
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
+		}
+	]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 0 data is not valid.1)(root): email is required.2)telephone: Invalid type. Expected: string, given: integer.",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where one of the messages is not successfully validated(2 errors)",
+		},
+		{
+			topic: "topic3",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "T2JqAQQWYXZyby5zY2hlbWGYAnsidHlwZSI6InJlY29yZCIsIm5hbWUiOiJQbGFjZSIsIm5hbWVzcGFjZSI6InBsYWNlLmF2cm8iLCJmaWVsZHMiOlt7Im5hbWUiOiJwbGFjZW5hbWUiLCJ0eXBlIjoic3RyaW5nIn0seyJuYW1lIjoiYWRkcmVzcyIsInR5cGUiOiJzdHJpbmcifV19FGF2cm8uY29kZWMIbnVsbABM1P4b0GpYaCg9tqxa+YDZAiQSc3RyZWV0IDIyDnBsYWNlIGFM1P4b0GpYaCg9tqxa+YDZ"
+		},
+		
+		{
+			"attributes": {},
+			"data": "DGFnZWxvc8T8Cg=="
+		}
+	]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 0 is not valid.cannot decode binary record \"user.avro.User\" field \"username\": cannot decode binary string: cannot decode binary bytes: negative size: -40",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where one of the messages is not successfully validated(1 error)(AVRO)",
+		},
+
+		{
+			topic: "topic2",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQo="
+		},
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
+		}
+	]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 0 data is not valid,(root): email is required",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the one of the messages is not successfully validated(1 error)",
+		},
+		{
+			topic: "topic2",
+			postBody: `{
+	"messages" : [
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwgImVtYWlsIjogInRlc3RAZXhhbXBsZS5jb20iLCAiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkifQ=="
+		},
+		
+		{
+			"attributes": {},
+			"data": "eyJuYW1lIjoibmFtZS0xIiwiYWRkcmVzcyI6IlN0cmVldCAxMyIsInRlbGVwaG9uZSI6IjY5NDg1Njc4ODkiCg=="
+		}
+	]
+}`,
+			expectedStatusCode: 400,
+			expectedResponse: `{
+   "error": {
+      "code": 400,
+      "message": "Message 1 data is not valid JSON format,unexpected EOF",
+      "status": "INVALID_ARGUMENT"
+   }
+}`,
+			msg: "Case where the one of the messages is not in valid json format",
+		},
+	}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PushEnabled = true
+	cfgKafka.PushWorkerToken = "push_token"
+	cfgKafka.ResAuth = false
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	pc := new(push.MockClient)
+
+	for _, t := range testData {
+
+		w := httptest.NewRecorder()
+		url := fmt.Sprintf("http://localhost:8080/v1/projects/ARGO/topics/%v", t.topic)
+		req, err := http.NewRequest("POST", url, strings.NewReader(t.postBody))
+		if err != nil {
+			log.Fatal(err)
+		}
+		router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, pc))
+		router.ServeHTTP(w, req)
+
+		suite.Equal(t.expectedStatusCode, w.Code, t.msg)
+		suite.Equal(t.expectedResponse, w.Body.String(), t.msg)
+	}
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllFirstPage() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [
+      {
+         "name": "/projects/ARGO/topics/topic4",
+         "created_on": "2020-11-19T00:00:00Z"
+      },
+      {
+         "name": "/projects/ARGO/topics/topic3",
+         "schema": "projects/ARGO/schemas/schema-3",
+         "created_on": "2020-11-20T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "MQ==",
+   "totalSize": 4
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllMaxPageSize() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.MaxPageSize = 2
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	// requests without a page size, or with a larger one, get clamped to the max page size
+	for _, query := range []string{"", "?pageSize=100"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+		res := topics.PaginatedTopics{}
+		json.Unmarshal(w.Body.Bytes(), &res)
+		suite.Equal(2, len(res.Topics))
+		suite.Equal("MQ==", res.NextPageToken)
+	}
+
+	cfgKafka.RejectOversizedPages = true
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=100", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid page size, it should not exceed 2")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllNextPage() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2&pageToken=MA==", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [
+      {
+         "name": "/projects/ARGO/topics/topic1",
+         "created_on": "2020-11-22T00:00:00Z"
+      }
+   ],
+   "nextPageToken": "",
+   "totalSize": 4
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllEmpty() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=2", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "topics": [],
+   "nextPageToken": "",
+   "totalSize": 0
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	// empty the store
+	str.TopicList = []stores.QTopic{}
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllInvalidPageSize() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=invalid", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid page size",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicListAllInvalidPageToken() {
+
+	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageToken=invalid", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid page token",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublish() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "attributes":
+        {
+         "foo":"bar"
+        }
+      ,
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "messageIds": [
+      "1"
+   ],
+   "placements": [
+      {
+         "partition": 0,
+         "offset": 1
+      }
+   ]
+}`
+	tn := time.Now().UTC()
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	w := httptest.NewRecorder()
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	tpc, _, _, _ := str.QueryTopics("argo_uuid", "", "topic1", "", 0, false, nil, "")
+	suite.True(tn.Before(tpc[0].LatestPublish))
+	suite.NotEqual(tpc[0].PublishRate, 10)
+
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishDeadlineExceeded() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+
+	expJSON := `{
+   "error": {
+      "code": 504,
+      "message": "Request deadline exceeded",
+      "status": "DEADLINE_EXCEEDED"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}:publish", WrapMockAuthConfig(ProjectPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	// the request's deadline has already passed, nothing reaches the broker
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, transactional := range []string{"false", "true"} {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?transactional="+transactional, bytes.NewBuffer([]byte(postJSON)))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req.WithContext(ctx))
+		suite.Equal(504, w.Code)
+		suite.Equal(expJSON, w.Body.String())
+	}
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(`{"topics": ["topic1"], "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req.WithContext(ctx))
+	suite.Equal(207, w.Code)
+	suite.Contains(w.Body.String(), "DEADLINE_EXCEEDED")
+
+	suite.Equal(0, len(brk.MsgList))
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishInvalidPriority() {
+
+	postJSON := `{"messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}, {"attributes": {"priority": "urgent"}, "data": "YmFzZTY0ZW5jb2RlZA=="}]}`
+
+	expResp := `{
+   "error": {
+      "code": 400,
+      "message": "Invalid priority urgent, it should be one of high, normal or low",
+      "status": "INVALID_ARGUMENT"
+   }
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(postJSON)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	// none of the messages got published
+	suite.Equal(0, len(brk.MsgList))
+
+	postJSON = `{"messages": [{"attributes": {"priority": "high"}, "data": "YmFzZTY0ZW5jb2RlZA=="}]}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", bytes.NewBuffer([]byte(postJSON)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishPooledBroker() {
+
+	postJSON := `{"messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}, {"data": "YmFzZTY0ZW5jb2RlZA=="}]}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	connected := []*brokers.MockBroker{}
+	pool := brokers.NewPool(3, func() brokers.Broker {
+		brk := &brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		connected = append(connected, brk)
+		return brk
+	})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, pool, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, pool, str, &mgr, nil))
+
+	// every request reuses the pooled broker instead of connecting a new one
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+
+		req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{}`))
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+	}
+
+	suite.Equal(1, len(connected))
+	suite.Equal(10, len(connected[0].PublishAcks))
+
+	// and releases it once done
+	stats := pool.Stats()
+	suite.Equal(0, stats.Active)
+	suite.Equal(1, stats.Idle)
+	suite.Equal(int64(0), stats.Waits)
+}
+
+func (suite *TopicsHandlersTestSuite) TestProjectPublishPooledBroker() {
+
+	postJSON := `{"topics": ["topic1", "topic4"], "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	connected := []*brokers.MockBroker{}
+	pool := brokers.NewPool(1, func() brokers.Broker {
+		brk := &brokers.MockBroker{}
+		brk.Initialize([]string{"localhost"})
+		connected = append(connected, brk)
+		return brk
+	})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}:publish", WrapMockAuthConfig(ProjectPublish, cfgKafka, pool, str, &mgr, nil, "project_admin"))
+
+	// all the topics are published to through a single pooled broker, which is released once done
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(1, len(connected))
+	suite.Equal(2, len(connected[0].PublishAcks))
+	suite.Equal(0, pool.Stats().Active)
+
+	// a request that can't get hold of a broker before its deadline publishes nothing
+	held, _ := pool.Acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(504, w.Code)
+	suite.Equal(2, len(connected[0].PublishAcks))
+	pool.Release(held)
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishPartitionKey() {
+
+	postJSON := `{
+  "messages": [
+    {"attributes": {"host": "node1"}, "data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"attributes": {"foo": "bar"}, "data": "YmFzZTY0ZW5jb2RlZA=="}
+  ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:modifyPartitionKey", WrapMockAuthConfig(TopicModPartitionKey, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicCreate, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicNew", strings.NewReader(`{"partition_key_attribute":"host"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"partition_key_attribute": "host"`)
+
+	// messages without the attribute are published without a key
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topicNew:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal([]string{"node1", ""}, brk.PublishKeys)
+
+	// topics without a partition key attribute never derive keys
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal([]string{"node1", "", "", ""}, brk.PublishKeys)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modifyPartitionKey", strings.NewReader(`{"partition_key_attribute":"foo"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("", w.Body.String())
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal([]string{"", "bar"}, brk.PublishKeys[4:])
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/unknown:modifyPartitionKey", strings.NewReader(`{"partition_key_attribute":"foo"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:modifyPartitionKey", strings.NewReader(`{"partition_key_attribute":1}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishMessageKey() {
+
+	postJSON := `{
+  "messages": [
+    {"key": "sensor-1", "attributes": {"host": "node1"}, "data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"attributes": {"host": "node2"}, "data": "YmFzZTY0ZW5jb2RlZA=="}
+  ]
+}`
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.UpdateTopicPartitionKey("argo_uuid", "topic1", "host")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// the message key becomes the record key, messages without one fall back to their partition key
+	suite.Equal([]string{"sensor-1", "node2"}, brk.PublishKeys)
+
+	// the key is delivered along with the message
+	msg, _ := messages.LoadMsgJSON([]byte(brk.MsgList[0]))
+	suite.Equal("sensor-1", msg.Key)
+	msg, _ = messages.LoadMsgJSON([]byte(brk.MsgList[1]))
+	suite.Equal("", msg.Key)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPublishTime() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PublishTimeMaxSkew = 10
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	postJSON := `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    },
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "publishTime": "2019-03-01T08:00:00Z"
+    },
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA==",
+      "publishTime": "2020-11-25T14:30:05Z"
+    }
+  ]
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	published := brk.MsgList[len(brk.MsgList)-3:]
+	suite.Contains(published[0], `"publishTime": "2020-11-25T14:30:00Z"`)
+	suite.NotContains(published[0], `"ingestTime"`)
+	suite.Contains(published[1], `"publishTime": "2019-03-01T08:00:00Z"`)
+	suite.Contains(published[1], `"ingestTime": "2020-11-25T14:30:00Z"`)
+	suite.Contains(published[2], `"publishTime": "2020-11-25T14:30:05Z"`)
+
+	// publish times further in the future than the allowed skew are rejected, along with the rest of the messages
+	total := len(brk.MsgList)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA==","publishTime":"2020-11-25T14:30:11Z"}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid publishTime 2020-11-25T14:30:11Z, it is further in the future than the allowed skew of 10s")
+	suite.Equal(total, len(brk.MsgList))
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA==","publishTime":"yesterday"}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "it should be an RFC3339 timestamp")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPublishPayloadLimits() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.PayloadMaxDepth = 3
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	// {"a":{"b":1}} and raw bytes are within the limits
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"eyJhIjp7ImIiOjF9fQ=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+
+	// {"a":{"b":[[1]]}} is nested too deep and nothing gets published
+	total := len(brk.MsgList)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"eyJhIjp7ImIiOltbMV1dfX0="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Message payload is nested deeper than 3 levels")
+	suite.Contains(w.Body.String(), "INVALID_ARGUMENT")
+	suite.Equal(total, len(brk.MsgList))
+
+	cfgKafka.PayloadMaxDepth = 0
+	cfgKafka.PayloadMaxFields = 2
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"eyJhIjp7ImIiOltbMV1dfX0="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Message payload has more than 2 fields")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPublishRateLimits() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+	publishLimiter = topics.NewRateLimiter()
+	defer func() {
+		publishLimiter = topics.NewRateLimiter()
+	}()
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	cfgKafka.TopicPublishMaxMessagesRate = 3
+	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}:publish", WrapMockAuthConfig(ProjectPublish, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}", WrapMockAuthConfig(TopicListOne, cfgKafka, &brk, str, &mgr, nil))
+
+	// the topic reports its effective limits
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"publish_limits": {
+      "messages_per_second": 3
+   }`)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("3", w.Header().Get("X-RateLimit-Messages-Limit"))
+	suite.Equal("1", w.Header().Get("X-RateLimit-Messages-Remaining"))
+	suite.Equal("", w.Header().Get("X-RateLimit-Bytes-Limit"))
+
+	// publishing faster than the limits allow is rejected and nothing gets published
+	total := len(brk.MsgList)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Contains(w.Body.String(), "Publish rate limit of topic /projects/ARGO/topics/topic1 has been reached, please retry")
+	suite.Equal("1", w.Header().Get("Retry-After"))
+	suite.Equal("1", w.Header().Get("X-RateLimit-Messages-Remaining"))
+	suite.Equal(total, len(brk.MsgList))
+
+	// so is publishing to the topic through the project
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(`{"topics":["topic1"],"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(429, w.Code)
+	suite.Equal(total, len(brk.MsgList))
+
+	fc.Advance(time.Second)
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="},{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal("1", w.Header().Get("X-RateLimit-Messages-Remaining"))
+
+	// topics without limits report none
+	cfgKafka.TopicPublishMaxMessagesRate = 0
+	req, _ = http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics/topic1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.NotContains(w.Body.String(), "publish_limits")
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPurge() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:purge", WrapMockAuthConfig(TopicPurge, cfgKafka, &brk, str, &mgr, nil))
+
+	// the broker has a time index, so the store keeps no publish times for the topic
+	base := time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC)
+	brk.TopicTimeIndices = map[string][]brokers.TimeToOffset{}
+	for i := int64(0); i < 5; i++ {
+		brk.TopicTimeIndices["argo_uuid.topic1"] = append(brk.TopicTimeIndices["argo_uuid.topic1"], brokers.TimeToOffset{Timestamp: base.Add(time.Duration(i) * time.Hour), Offset: i})
+	}
+	str.UpdateSubOffset("argo_uuid", "sub1", 1)
+
+	expResp := `{
+   "topic": "topic1",
+   "cutoff": "2020-11-25T12:30:00Z",
+   "offset": 3,
+   "broker_retention": true,
+   "subscriptions": [
+      {
+         "subscription": "sub1",
+         "old_offset": 1,
+         "new_offset": 3,
+         "expired": 2
+      }
+   ]
+}`
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:purge", strings.NewReader(`{"older_than_seconds": 7200, "delete_records": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+	suite.Equal(int64(3), brk.DeletedBefore["argo_uuid.topic1"])
+
+	// purging again expires nothing more, and leaves the broker alone unless asked to
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:purge", strings.NewReader(`{"older_than_seconds": 7200}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"broker_retention": false`)
+	suite.Contains(w.Body.String(), `"subscriptions": []`)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:purge", strings.NewReader(`{"older_than_seconds": 0}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid age, older_than_seconds should be a positive number")
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:purge", strings.NewReader(`{"older_than_seconds": "1h"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/unknown:purge", strings.NewReader(`{"older_than_seconds": 7200}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicPurgeWithoutTimeIndex() {
+
+	fc := &fakeClock{now: time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)}
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.SetClock(fc.Now)
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	// brokers without a time index fall back to the publish times kept in the store
+	brk := brokers.MockBroker{NoTimeIndex: true}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:purge", WrapMockAuthConfig(TopicPurge, cfgKafka, &brk, str, &mgr, nil))
+
+	base := time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 5; i++ {
+		str.InsertOffsetTime("argo_uuid", "topic1", i, base.Add(time.Duration(i)*time.Hour))
+	}
+	str.UpdateSubOffset("argo_uuid", "sub1", 1)
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:purge", strings.NewReader(`{"older_than_seconds": 7200, "delete_records": true}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"offset": 3`)
+	suite.Equal(int64(3), brk.DeletedBefore["argo_uuid.topic1"])
+}
+
+func (suite *TopicsHandlersTestSuite) TestPublishMultiple() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "attributes":
+        {
+          "foo":"bar"
+        }
+      ,
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    },
+    {
+      "attributes":
+        {
+      		"foo2":"bar2"
+        }
+      ,
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    },
+    {
+      "attributes":
+        {
+          "foo2":"bar2"
+        }
+      ,
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	expJSON := `{
+   "messageIds": [
+      "1",
+      "2",
+      "3"
+   ],
+   "placements": [
+      {
+         "partition": 0,
+         "offset": 1
+      },
+      {
+         "partition": 0,
+         "offset": 2
+      },
+      {
+         "partition": 0,
+         "offset": 3
+      }
+   ]
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
 	str := stores.NewMockStore("whatever", "argo_mgs")
-	// empty the store
-	str.TopicList = []stores.QTopic{}
 	router := mux.NewRouter().StrictSlash(true)
 	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Equal(expJSON, w.Body.String())
 
+	// the broker has a time index, so no publish times should have been recorded
+	suite.Equal(0, len(str.OffsetTimes))
+
+	// the publish time of every offset should be recorded for brokers without a time index
+	brk.NoTimeIndex = true
+	req, _ = http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	suite.Equal(3, len(str.OffsetTimes))
+	for i, item := range str.OffsetTimes {
+		suite.Equal("topic1", item.TopicName)
+		suite.Equal(int64(i+4), item.Offset)
+		suite.False(item.PublishTime.IsZero())
+	}
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllInvalidPageSize() {
+// failingPublishBroker rejects the n-th published message as too large
+type failingPublishBroker struct {
+	brokers.MockBroker
+	failAt    int
+	published int
+}
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageSize=invalid", nil)
-	if err != nil {
-		log.Fatal(err)
+func (b *failingPublishBroker) Publish(topic string, msg messages.Message, acks string, key string) (string, string, int, int64, error) {
+	b.published++
+	if b.published == b.failAt {
+		return "", "", 0, 0, fmt.Errorf("kafka server: Message was too large, server rejected it to avoid allocation error.")
+	}
+	return b.MockBroker.Publish(topic, msg, acks, key)
+}
+
+// placingBroker reports the placements of the published messages on a partition of its own, like kafka it reports
+// no offsets for the messages whose publishing doesn't wait for an acknowledgement
+type placingBroker struct {
+	brokers.MockBroker
+}
+
+func (b *placingBroker) Publish(topic string, msg messages.Message, acks string, key string) (string, string, int, int64, error) {
+	msgID, rTopic, _, rOff, err := b.MockBroker.Publish(topic, msg, acks, key)
+	if acks == brokers.AcksNone {
+		return msgID, rTopic, 2, -1, err
 	}
+	return msgID, rTopic, 2, rOff + 100, err
+}
 
-	expResp := `{
-   "error": {
-      "code": 400,
-      "message": "Invalid page size",
-      "status": "INVALID_ARGUMENT"
-   }
+func (suite *TopicsHandlersTestSuite) TestPublishPlacements() {
+
+	postJSON := `{
+  "messages": [
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    },
+    {
+      "data": "YmFzZTY0ZW5jb2RlZA=="
+    }
+  ]
+}`
+
+	expJSON := `{
+   "messageIds": [
+      "1",
+      "2"
+   ],
+   "placements": [
+      {
+         "partition": 2,
+         "offset": 101
+      },
+      {
+         "partition": 2,
+         "offset": 102
+      }
+   ]
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-
-	brk := brokers.MockBroker{}
+	brk := placingBroker{}
+	brk.Initialize([]string{"localhost"})
 	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
 	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
+	req, _ := http.NewRequest("POST", url, strings.NewReader(postJSON))
 	w := httptest.NewRecorder()
-	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
 	router.ServeHTTP(w, req)
-	suite.Equal(400, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Equal(200, w.Code)
+	suite.Equal(expJSON, w.Body.String())
 
+	// each message of a partially successful publish carries its own placement
+	req, _ = http.NewRequest("POST", url+"?partialSuccess=true", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Contains(w.Body.String(), `"messageId": "3",
+         "placement": {
+            "partition": 2,
+            "offset": 103
+         }`)
+
+	// publishing without waiting for acknowledgements leaves the placements unknown
+	cfgKafka.PublishAcks = brokers.AcksNone
+	req, _ = http.NewRequest("POST", url, strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(`{
+   "messageIds": [
+      "5",
+      "6"
+   ]
+}`, w.Body.String())
 }
 
-func (suite *TopicsHandlersTestSuite) TestTopicListAllInvalidPageToken() {
+func (suite *TopicsHandlersTestSuite) TestPublishPartialSuccess() {
 
-	req, err := http.NewRequest("GET", "http://localhost:8080/v1/projects/ARGO/topics?pageToken=invalid", nil)
-	if err != nil {
-		log.Fatal(err)
-	}
+	postJSON := `{
+  "messages": [
+    {"data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"data": "YmFzZTY0ZW5jb2RlZA=="}
+  ]
+}`
 
-	expResp := `{
-   "error": {
-      "code": 400,
-      "message": "Invalid page token",
-      "status": "INVALID_ARGUMENT"
-   }
+	expJSON := `{
+   "results": [
+      {
+         "messageId": "1",
+         "placement": {
+            "partition": 0,
+            "offset": 1
+         }
+      },
+      {
+         "error": {
+            "code": 413,
+            "message": "Message size is too large",
+            "status": "INVALID_ARGUMENT"
+         }
+      },
+      {
+         "messageId": "2",
+         "placement": {
+            "partition": 0,
+            "offset": 2
+         }
+      }
+   ]
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-
-	brk := brokers.MockBroker{}
+	brk := failingPublishBroker{failAt: 2}
+	brk.Initialize([]string{"localhost"})
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics", WrapMockAuthConfig(TopicListAll, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?partialSuccess=true", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	suite.Equal(400, w.Code)
-	suite.Equal(expResp, w.Body.String())
+	suite.Equal(207, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	// only the published messages count
+	suite.Equal(int64(2), str.TopicList[0].MsgNum)
+
+	// without failures the request succeeds as a whole
+	brk.failAt = 0
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?partialSuccess=true", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.NotContains(w.Body.String(), `"error"`)
 
+	// the default mode remains all or nothing
+	brk.published = 0
+	brk.failAt = 2
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(413, w.Code)
 }
 
-func (suite *TopicsHandlersTestSuite) TestPublish() {
+func (suite *TopicsHandlersTestSuite) TestPublishTransactional() {
 
 	postJSON := `{
   "messages": [
-    {
-      "attributes":
-        {
-         "foo":"bar"
-        }
-      ,
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    }
+    {"data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"data": "YmFzZTY0ZW5jb2RlZA=="}
   ]
 }`
-	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	expJSON := `{
-   "messageIds": [
-      "1"
-   ]
-}`
-	tn := time.Now().UTC()
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
 	brk := brokers.MockBroker{}
+	brk.Initialize([]string{"localhost"})
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
 	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?transactional=true", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
-	suite.Equal(expJSON, w.Body.String())
-	tpc, _, _, _ := str.QueryTopics("argo_uuid", "", "topic1", "", 0)
-	suite.True(tn.Before(tpc[0].LatestPublish))
-	suite.NotEqual(tpc[0].PublishRate, 10)
+	suite.Equal(`{
+   "messageIds": [
+      "1",
+      "2",
+      "3"
+   ],
+   "placements": [
+      {
+         "partition": 0,
+         "offset": 1
+      },
+      {
+         "partition": 0,
+         "offset": 2
+      },
+      {
+         "partition": 0,
+         "offset": 3
+      }
+   ]
+}`, w.Body.String())
+	suite.Equal(3, len(brk.MsgList))
+	suite.Equal(int64(3), str.TopicList[0].MsgNum)
+
+	// a batch rejected midway leaves none of its messages published
+	brk.FailBatchAt = 2
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?transactional=true", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(413, w.Code)
+	suite.NotContains(w.Body.String(), "messageIds")
+	suite.Equal(3, len(brk.MsgList))
+	suite.Equal(int64(3), str.TopicList[0].MsgNum)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?transactional=true&partialSuccess=true", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
 
+	brk.FailBatchAt = 0
+	brk.NoTransactions = true
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish?transactional=true", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(409, w.Code)
+	suite.Contains(w.Body.String(), brokers.ErrTransactionsUnsupported.Error())
+	suite.Equal(3, len(brk.MsgList))
 }
 
-func (suite *TopicsHandlersTestSuite) TestPublishMultiple() {
+func (suite *TopicsHandlersTestSuite) TestProjectPublish() {
 
 	postJSON := `{
+  "topics": ["topic1", "topic4"],
   "messages": [
-    {
-      "attributes":
-        {
-          "foo":"bar"
-        }
-      ,
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    },
-    {
-      "attributes":
-        {
-      		"foo2":"bar2"
-        }
-      ,
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    },
-    {
-      "attributes":
-        {
-          "foo2":"bar2"
-        }
-      ,
-      "data": "YmFzZTY0ZW5jb2RlZA=="
-    }
+    {"data": "YmFzZTY0ZW5jb2RlZA=="},
+    {"data": "YmFzZTY0ZW5jb2RlZA=="}
   ]
 }`
-	url := "http://localhost:8080/v1/projects/ARGO/topics/topic1:publish"
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer([]byte(postJSON)))
-	if err != nil {
-		log.Fatal(err)
-	}
 
 	expJSON := `{
-   "messageIds": [
-      "1",
-      "2",
-      "3"
+   "results": [
+      {
+         "topic": "/projects/ARGO/topics/topic1",
+         "messageIds": [
+            "1",
+            "2"
+         ],
+         "placements": [
+            {
+               "partition": 0,
+               "offset": 1
+            },
+            {
+               "partition": 0,
+               "offset": 2
+            }
+         ]
+      },
+      {
+         "topic": "/projects/ARGO/topics/topic4",
+         "messageIds": [
+            "3",
+            "4"
+         ],
+         "placements": [
+            {
+               "partition": 0,
+               "offset": 3
+            },
+            {
+               "partition": 0,
+               "offset": 4
+            }
+         ]
+      }
    ]
 }`
 
 	cfgKafka := config.NewAPICfg()
 	cfgKafka.LoadStrJSON(suite.cfgStr)
-	brk := brokers.MockBroker{}
+	brk := failingPublishBroker{}
 	brk.Initialize([]string{"localhost"})
 	str := stores.NewMockStore("whatever", "argo_mgs")
 	router := mux.NewRouter().StrictSlash(true)
-	w := httptest.NewRecorder()
 	mgr := oldPush.Manager{}
-	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}:publish", WrapMockAuthConfig(ProjectPublish, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	req, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	suite.Equal(200, w.Code)
 	suite.Equal(expJSON, w.Body.String())
+	suite.Equal(int64(2), str.TopicList[0].MsgNum)
+	suite.Equal(int64(2), str.TopicList[3].MsgNum)
+
+	// a failure stops the publishing to its own topic, while the rest of the topics are still served
+	brk.published = 0
+	brk.failAt = 2
+	expJSON = `{
+   "results": [
+      {
+         "topic": "/projects/ARGO/topics/topic1",
+         "messageIds": [
+            "5"
+         ],
+         "placements": [
+            {
+               "partition": 0,
+               "offset": 5
+            }
+         ],
+         "error": {
+            "code": 413,
+            "message": "Message size is too large",
+            "status": "INVALID_ARGUMENT"
+         }
+      },
+      {
+         "topic": "/projects/ARGO/topics/topic4",
+         "messageIds": [
+            "6",
+            "7"
+         ],
+         "placements": [
+            {
+               "partition": 0,
+               "offset": 6
+            },
+            {
+               "partition": 0,
+               "offset": 7
+            }
+         ]
+      }
+   ]
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(207, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	suite.Equal(int64(3), str.TopicList[0].MsgNum)
+	suite.Equal(int64(4), str.TopicList[3].MsgNum)
+
+	// nothing gets published if one of the topics doesn't exist
+	brk.failAt = 0
+	published := len(brk.MsgList)
+	postJSON = `{
+  "topics": ["topic1", "unknown"],
+  "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]
+}`
+	expJSON = `{
+   "error": {
+      "code": 404,
+      "message": "Topic unknown doesn't exist",
+      "status": "NOT_FOUND"
+   }
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	suite.Equal(published, len(brk.MsgList))
+
+	// topics should be declared only once
+	postJSON = `{
+  "topics": ["topic1", "topic1"],
+  "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Target topics should be declared only once")
 
+	// messages are validated against the schema of every target topic
+	postJSON = `{
+  "topics": ["topic1", "topic2"],
+  "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Equal(published, len(brk.MsgList))
+
+	// the publisher should be present in the acl of every target topic
+	router = mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}:publish", WrapMockAuthConfig(ProjectPublish, cfgKafka, &brk, str, &mgr, nil))
+	postJSON = `{
+  "topics": ["topic1", "topic3"],
+  "messages": [{"data": "YmFzZTY0ZW5jb2RlZA=="}]
+}`
+	expJSON = `{
+   "error": {
+      "code": 403,
+      "message": "Access to this resource is forbidden. Not allowed to publish to topic topic3",
+      "status": "FORBIDDEN"
+   }
+}`
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO:publish", strings.NewReader(postJSON))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(403, w.Code)
+	suite.Equal(expJSON, w.Body.String())
+	suite.Equal(published, len(brk.MsgList))
 }
 
 func (suite *TopicsHandlersTestSuite) TestPublishError() {
@@ -1002,7 +3025,8 @@ func (suite *TopicsHandlersTestSuite) TestValidationInTopics() {
 
 	okResp := `{
    "name": "/projects/ARGO/topics/topic1",
-   "created_on": "2020-11-22T00:00:00Z"
+   "created_on": "2020-11-22T00:00:00Z",
+   "publish_acks": "all"
 }`
 	invProject := `{
    "error": {
@@ -1069,3 +3093,79 @@ func TestTopicsHandlersTestSuite(t *testing.T) {
 	log.SetOutput(ioutil.Discard)
 	suite.Run(t, new(TopicsHandlersTestSuite))
 }
+
+// topicRecordingBroker records the broker topics that messages get published to and consumed from
+type topicRecordingBroker struct {
+	brokers.MockBroker
+	published []string
+	consumed  []string
+}
+
+func (b *topicRecordingBroker) Publish(topic string, msg messages.Message, acks string, key string) (string, string, int, int64, error) {
+	b.published = append(b.published, topic)
+	return b.MockBroker.Publish(topic, msg, acks, key)
+}
+
+func (b *topicRecordingBroker) Consume(ctx context.Context, topic string, offset int64, imm bool, max int64, isolation string) ([]string, error) {
+	b.consumed = append(b.consumed, topic)
+	return b.MockBroker.Consume(ctx, topic, offset, imm, max, isolation)
+}
+
+func (suite *TopicsHandlersTestSuite) TestTopicRename() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+	brk := topicRecordingBroker{}
+	brk.Initialize([]string{"localhost"})
+	brk.PopulateThree()
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	mgr := oldPush.Manager{}
+	router := mux.NewRouter().StrictSlash(true)
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:rename", WrapMockAuthConfig(TopicRename, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/topics/{topic}:publish", WrapMockAuthConfig(TopicPublish, cfgKafka, &brk, str, &mgr, nil))
+	router.HandleFunc("/v1/projects/{project}/subscriptions/{subscription}:pull", WrapMockAuthConfig(SubPull, cfgKafka, &brk, str, &mgr, nil, "project_admin"))
+
+	expResp := `{
+   "name": "/projects/ARGO/topics/topicRenamed",
+   "created_on": "2020-11-22T00:00:00Z"
+}`
+
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1:rename", strings.NewReader(`{"name":"topicRenamed"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(200, w.Code)
+	suite.Equal(expResp, w.Body.String())
+
+	// the subscription of the topic follows its new name
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal("topicRenamed", qSub.Topic)
+
+	// messages are still published to and consumed from the original broker topic
+	req2, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/topics/topicRenamed:publish", strings.NewReader(`{"messages":[{"data":"YmFzZTY0ZW5jb2RlZA=="}]}`))
+	w2 := httptest.NewRecorder()
+	router.ServeHTTP(w2, req2)
+	suite.Equal(200, w2.Code)
+	suite.Equal([]string{"argo_uuid.topic1"}, brk.published)
+
+	req3, _ := http.NewRequest("POST", "http://localhost:8080/v1/projects/ARGO/subscriptions/sub1:pull", strings.NewReader(`{}`))
+	w3 := httptest.NewRecorder()
+	router.ServeHTTP(w3, req3)
+	suite.Equal(200, w3.Code)
+	suite.Equal([]string{"argo_uuid.topic1"}, brk.consumed)
+
+	// the new name can't collide with another topic
+	req4, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicRenamed:rename", strings.NewReader(`{"name":"topic2"}`))
+	w4 := httptest.NewRecorder()
+	router.ServeHTTP(w4, req4)
+	suite.Equal(409, w4.Code)
+
+	req5, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topic1:rename", strings.NewReader(`{"name":"topicFoo"}`))
+	w5 := httptest.NewRecorder()
+	router.ServeHTTP(w5, req5)
+	suite.Equal(404, w5.Code)
+
+	req6, _ := http.NewRequest("PUT", "http://localhost:8080/v1/projects/ARGO/topics/topicRenamed:rename", strings.NewReader(`{"name":"topic/foo"}`))
+	w6 := httptest.NewRecorder()
+	router.ServeHTTP(w6, req6)
+	suite.Equal(400, w6.Code)
+}
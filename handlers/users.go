@@ -11,7 +11,6 @@ import (
 	"github.com/twinj/uuid"
 	"io/ioutil"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -80,11 +79,18 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 
 	// Grab context references
 	refStr := gorillaContext.Get(r, "str").(stores.Store)
+	refUserUUID := gorillaContext.Get(r, "auth_user_uuid").(string)
 
 	// Get Result Object
 	userUUID := auth.GetUUIDByName(urlUser, refStr)
 	token, err := auth.GenToken() // generate a new user token
+	if err != nil {
+		err := APIErrGenericInternal(err.Error())
+		respondErr(w, err)
+		return
+	}
 
+	// the previous token stops authenticating as soon as the new one gets stored
 	res, err := auth.UpdateUserToken(userUUID, token, refStr)
 
 	if err != nil {
@@ -98,6 +104,15 @@ func RefreshToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	log.WithFields(
+		log.Fields{
+			"type":      "audit_log",
+			"action":    "users:refreshToken",
+			"user_uuid": userUUID,
+			"requester": refUserUUID,
+		},
+	).Info("User token rotated")
+
 	// Output result to JSON
 	resJSON, err := res.ExportJSON()
 	if err != nil {
@@ -422,7 +437,6 @@ func UserListByUUID(w http.ResponseWriter, r *http.Request) {
 func UserListAll(w http.ResponseWriter, r *http.Request) {
 
 	var err error
-	var pageSize int
 	var paginatedUsers auth.PaginatedUsers
 
 	// Init output
@@ -441,7 +455,6 @@ func UserListAll(w http.ResponseWriter, r *http.Request) {
 	// Grab url path variables
 	urlValues := r.URL.Query()
 	pageToken := urlValues.Get("pageToken")
-	strPageSize := urlValues.Get("pageSize")
 	projectName := urlValues.Get("project")
 	details := urlValues.Get("details")
 	projectUUID := ""
@@ -459,20 +472,30 @@ func UserListAll(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	if strPageSize != "" {
-		if pageSize, err = strconv.Atoi(strPageSize); err != nil {
-			log.Errorf("Pagesize %v produced an error  while being converted to int: %v", strPageSize, err.Error())
-			err := APIErrorInvalidData("Invalid page size")
-			respondErr(w, err)
-			return
-		}
+	pageSize, err := listPageSize(r)
+	if err != nil {
+		err := APIErrorInvalidData(err.Error())
+		respondErr(w, err)
+		return
+	}
+
+	// users are listed by creation, most recent first, unless ordered by name
+	findUsers := auth.PaginatedFindUsers
+	switch urlValues.Get("orderBy") {
+	case "":
+	case "name":
+		findUsers = auth.PaginatedFindUsersByName
+	default:
+		err := APIErrorInvalidData("Invalid orderBy value, it should be name")
+		respondErr(w, err)
+		return
 	}
 
 	// check that user is indeed a service admin in order to be priviledged to see full user info
 	priviledged := auth.IsServiceAdmin(refRoles)
 
 	// Get Results Object - call is always priviledged because this handler is only accessible by service admins
-	if paginatedUsers, err = auth.PaginatedFindUsers(pageToken, int32(pageSize), projectUUID, priviledged, usersDetailedView, refStr); err != nil {
+	if paginatedUsers, err = findUsers(pageToken, int32(pageSize), projectUUID, priviledged, usersDetailedView, refStr); err != nil {
 		err := APIErrorInvalidData("Invalid page token")
 		respondErr(w, err)
 		return
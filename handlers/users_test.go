@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
@@ -323,6 +324,18 @@ func (suite *UsersHandlersTestSuite) TestRefreshToken() {
 	suite.Equal(200, w.Code)
 	userOut, _ := auth.GetUserFromJSON([]byte(w.Body.String()))
 	suite.NotEqual("S3CR3T", userOut.Token)
+	suite.Equal(64, len(userOut.Token))
+
+	// the previous token is rejected right away, while the new one authenticates
+	roles, _ := auth.Authenticate("argo_uuid", "S3CR3T4", str)
+	suite.Equal(0, len(roles))
+	_, user := auth.Authenticate("argo_uuid", userOut.Token, str)
+	suite.Equal("UserZ", user)
+
+	req, _ = http.NewRequest("POST", "http://localhost:8080/v1/users/unknown:refreshToken", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(404, w.Code)
 }
 
 func (suite *UsersHandlersTestSuite) TestUserUpdate() {
@@ -1557,6 +1570,53 @@ func (suite *UsersHandlersTestSuite) TestUserListAllInvalidPageToken() {
 
 }
 
+func (suite *UsersHandlersTestSuite) TestUserListAllOrderByName() {
+
+	cfgKafka := config.NewAPICfg()
+	cfgKafka.LoadStrJSON(suite.cfgStr)
+
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	router := mux.NewRouter().StrictSlash(true)
+	mgr := oldPush.Manager{}
+	router.HandleFunc("/v1/users", WrapMockAuthConfig(UserListAll, cfgKafka, &brk, str, &mgr, nil))
+
+	// follow the page tokens until the listing is exhausted
+	names := []string{}
+	tokens := []string{}
+	pageToken := ""
+	for {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/v1/users?orderBy=name&pageSize=3&pageToken="+pageToken, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Equal(200, w.Code)
+
+		page := auth.PaginatedUsers{}
+		suite.Nil(json.Unmarshal(w.Body.Bytes(), &page))
+		suite.Equal(int32(9), page.TotalSize)
+		suite.True(len(page.Users) <= 3)
+		for _, u := range page.Users {
+			names = append(names, u.Name)
+		}
+
+		if page.NextPageToken == "" {
+			break
+		}
+		tokens = append(tokens, page.NextPageToken)
+		pageToken = page.NextPageToken
+	}
+
+	suite.Equal([]string{"Test", "UserA", "UserB", "UserSame1", "UserSame2", "UserX", "UserZ", "UserZ", "push_worker_0"}, names)
+	// the page tokens are the base64 encoded names of the users starting the next pages
+	suite.Equal([]string{"VXNlclNhbWUx", "VXNlclo="}, tokens)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/v1/users?orderBy=created", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Equal(400, w.Code)
+	suite.Contains(w.Body.String(), "Invalid orderBy value, it should be name")
+}
+
 func (suite *UsersHandlersTestSuite) TestUserDelete() {
 
 	req, err := http.NewRequest("DELETE", "http://localhost:8080/v1/users/UserA", nil)
@@ -0,0 +1,198 @@
+package labels
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const (
+	// MaxLabels is the max number of labels a resource can carry
+	MaxLabels = 64
+	// TooManyLabels is returned for resources that declare more labels than allowed
+	TooManyLabels = "A resource can't carry more than 64 labels"
+	// InvalidLabelKey is returned for label keys that aren't 1 to 63 alphanumerics, '-', '_' or '.', starting and ending with an alphanumeric
+	InvalidLabelKey = "Label keys should be 1 to 63 characters long, consisting of alphanumerics, '-', '_' or '.' and starting and ending with an alphanumeric"
+	// InvalidLabelValue is returned for label values that aren't empty or up to 63 alphanumerics, '-', '_' or '.', starting and ending with an alphanumeric
+	InvalidLabelValue = "Label values should be up to 63 characters long, consisting of alphanumerics, '-', '_' or '.' and starting and ending with an alphanumeric"
+	// InvalidSelector is returned for selectors that aren't comma separated key=value or key in (value1,value2) requirements
+	InvalidSelector = "Invalid labelSelector, it should be a comma separated list of key=value or key in (value1,value2) requirements"
+)
+
+var labelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9_.-]{0,61}[a-zA-Z0-9])?$`)
+
+// Selector holds, for each required label key, the values the label can have.
+// A single value is an equality requirement while more values mean set membership
+type Selector map[string][]string
+
+// Update holds the body of a request that replaces the labels of a resource
+type Update struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// GetUpdateFromJSON retrieves the labels of an update request from json
+func GetUpdateFromJSON(input []byte) (Update, error) {
+	u := Update{}
+	err := json.Unmarshal(input, &u)
+	return u, err
+}
+
+// Validate checks that the keys and the values of the labels are well formed
+func Validate(labels map[string]string) error {
+
+	if len(labels) > MaxLabels {
+		return errors.New(TooManyLabels)
+	}
+
+	for k, v := range labels {
+		if !labelRegex.MatchString(k) {
+			return errors.New(InvalidLabelKey)
+		}
+		if !validValue(v) {
+			return errors.New(InvalidLabelValue)
+		}
+	}
+
+	return nil
+}
+
+func validValue(v string) bool {
+	return v == "" || labelRegex.MatchString(v)
+}
+
+// ParseSelector parses a selector of comma separated requirements, e.g. team=sre,env in (prod,staging).
+// An empty selector selects every resource
+func ParseSelector(selector string) (Selector, error) {
+
+	if strings.TrimSpace(selector) == "" {
+		return nil, nil
+	}
+
+	reqs, ok := splitRequirements(selector)
+	if !ok {
+		return nil, errors.New(InvalidSelector)
+	}
+
+	s := Selector{}
+
+	for _, req := range reqs {
+
+		key, values, ok := parseRequirement(strings.TrimSpace(req))
+		if !ok {
+			return nil, errors.New(InvalidSelector)
+		}
+
+		// requirements on the same key can't be combined
+		if _, exists := s[key]; exists {
+			return nil, errors.New(InvalidSelector)
+		}
+
+		s[key] = values
+	}
+
+	return s, nil
+}
+
+// splitRequirements splits the selector on the commas that aren't part of a set of values
+func splitRequirements(selector string) ([]string, bool) {
+
+	reqs := []string{}
+	depth := 0
+	start := 0
+
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+			if depth > 1 {
+				return nil, false
+			}
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, false
+			}
+		case ',':
+			if depth == 0 {
+				reqs = append(reqs, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, false
+	}
+
+	return append(reqs, selector[start:]), true
+}
+
+// parseRequirement parses either a key=value or a key in (value1,value2) requirement
+func parseRequirement(req string) (string, []string, bool) {
+
+	if kv := strings.SplitN(req, "=", 2); len(kv) == 2 {
+		k, v := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !labelRegex.MatchString(k) || !validValue(v) {
+			return "", nil, false
+		}
+		return k, []string{v}, true
+	}
+
+	fields := strings.SplitN(req, " ", 2)
+	if len(fields) != 2 {
+		return "", nil, false
+	}
+
+	k := fields[0]
+	set := strings.TrimSpace(fields[1])
+	if !labelRegex.MatchString(k) || !strings.HasPrefix(set, "in") {
+		return "", nil, false
+	}
+
+	set = strings.TrimSpace(strings.TrimPrefix(set, "in"))
+	if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+		return "", nil, false
+	}
+
+	if strings.TrimSpace(set[1:len(set)-1]) == "" {
+		return "", nil, false
+	}
+
+	values := []string{}
+	for _, v := range strings.Split(set[1:len(set)-1], ",") {
+		v = strings.TrimSpace(v)
+		if !validValue(v) {
+			return "", nil, false
+		}
+		values = append(values, v)
+	}
+
+	return k, values, true
+}
+
+// Matches returns true if the labels fulfill every requirement of the selector
+func (s Selector) Matches(labels map[string]string) bool {
+
+	for k, values := range s {
+
+		value, ok := labels[k]
+		if !ok {
+			return false
+		}
+
+		found := false
+		for _, v := range values {
+			if v == value {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
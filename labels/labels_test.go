@@ -0,0 +1,65 @@
+package labels
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type LabelsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LabelsTestSuite) TestValidate() {
+
+	suite.Nil(Validate(nil))
+	suite.Nil(Validate(map[string]string{"team": "sre", "env": "", "app.kubernetes.io": "ams-1"}))
+	suite.Equal(InvalidLabelKey, Validate(map[string]string{"-team": "sre"}).Error())
+	suite.Equal(InvalidLabelKey, Validate(map[string]string{"": "sre"}).Error())
+	suite.Equal(InvalidLabelValue, Validate(map[string]string{"team": "sre team"}).Error())
+
+	tooMany := map[string]string{}
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany["key"+strconv.Itoa(i)] = "v"
+	}
+	suite.Equal(TooManyLabels, Validate(tooMany).Error())
+}
+
+func (suite *LabelsTestSuite) TestParseSelector() {
+
+	s, err := ParseSelector("")
+	suite.Nil(err)
+	suite.Nil(s)
+
+	s, err = ParseSelector("team=sre, env=prod")
+	suite.Nil(err)
+	suite.Equal(Selector{"team": {"sre"}, "env": {"prod"}}, s)
+
+	s, err = ParseSelector("env in (prod, staging),team=sre")
+	suite.Nil(err)
+	suite.Equal(Selector{"team": {"sre"}, "env": {"prod", "staging"}}, s)
+
+	for _, selector := range []string{"team", "team=sre,", "team=sre,team=ops", "te am=sre", "team!=sre",
+		"env in ()", "env in (prod", "env in prod", "env notin (prod)", "env in ((prod))", "env in (pr od)"} {
+		_, err = ParseSelector(selector)
+		suite.Equal(InvalidSelector, err.Error(), selector)
+	}
+}
+
+func (suite *LabelsTestSuite) TestMatches() {
+
+	s := Selector{"team": {"sre"}, "env": {"prod", "staging"}}
+
+	suite.True(s.Matches(map[string]string{"team": "sre", "env": "staging", "app": "ams"}))
+	suite.False(s.Matches(map[string]string{"team": "sre", "env": "devel"}))
+	suite.False(s.Matches(map[string]string{"env": "prod"}))
+	suite.False(s.Matches(nil))
+
+	// an empty selector matches everything
+	suite.True(Selector(nil).Matches(nil))
+}
+
+func TestLabelsTestSuite(t *testing.T) {
+	suite.Run(t, new(LabelsTestSuite))
+}
@@ -4,12 +4,17 @@ import (
 	"crypto/tls"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/ARGOeu/argo-messaging/auth"
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/events"
 	oldPush "github.com/ARGOeu/argo-messaging/push"
 	push "github.com/ARGOeu/argo-messaging/push/grpc/client"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/topics"
 	"github.com/ARGOeu/argo-messaging/version"
 	"github.com/gorilla/handlers"
 	log "github.com/sirupsen/logrus"
@@ -33,15 +38,91 @@ func main() {
 	// create and load configuration object
 	cfg := config.NewAPICfg("LOAD")
 
-	// create the store
-	store := stores.NewMongoStore(cfg.StoreHost, cfg.StoreDB)
+	// deployments that share a broker cluster keep their broker topics apart through their prefix
+	if err := brokers.SetTopicPrefix(cfg.BrokerTopicPrefix); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	// create the store, routing the pinned projects to stores of their own
+	var store stores.Store = stores.NewMongoStore(cfg.StoreHost, cfg.StoreDB)
+	if routes := stores.ParseProjectRoutes(cfg.StoreProjectRoutes, cfg.StoreHost); len(routes) > 0 {
+		pinned := make(map[string]stores.Store, len(routes))
+		for _, route := range routes {
+			pinned[route.ProjectUUID] = stores.NewMongoStore(route.Server, route.Database)
+		}
+		store = stores.NewRoutingStore(store, pinned)
+	}
 	store.Initialize()
 
 	// create and initialize broker based on configuration
-	broker := brokers.NewKafkaBroker(cfg.GetBrokerInfo())
+	var broker brokers.Broker
+	if cfg.BrokerPoolSize > 0 {
+		broker = brokers.NewPool(cfg.BrokerPoolSize, func() brokers.Broker {
+			return brokers.NewKafkaBroker(cfg.GetBrokerInfo())
+		})
+	} else {
+		broker = brokers.NewKafkaBroker(cfg.GetBrokerInfo())
+	}
 	defer broker.CloseConnections()
 
-	mgr := &oldPush.Manager{}
+	// lifecycle events of the resources are emitted in the background to the configured sink
+	if cfg.EventSink != "" {
+		emitter, err := events.OpenEmitter(cfg.EventSink, broker)
+		if err != nil {
+			log.Fatal(err.Error())
+		}
+		events.SetPublisher(events.NewPublisher(emitter))
+	}
+
+	// the ams push server delivers to the https endpoints, the push manager of the service serves the subscriptions
+	// that push to endpoints it has transports for, e.g. sqs queues
+	mgr := oldPush.NewManager(broker, store, oldPush.NewHTTPSender(oldPush.DeliveryTimeout))
+	mgr.DelegateSchemes("http", "https")
+	if cfg.PushSQSAccessKeyID != "" {
+		mgr.RegisterTransport(oldPush.SQSScheme,
+			oldPush.NewSQSTransport(cfg.PushSQSAccessKeyID, cfg.PushSQSSecretAccessKey, cfg.PushSQSRegion, oldPush.DeliveryTimeout))
+		subscriptions.AllowPushScheme(oldPush.SQSScheme, oldPush.SQSHosts(cfg.PushSQSRegion))
+	}
+	if cfg.PushEnabled {
+		mgr.LoadPushSubs()
+		mgr.StartAll()
+	}
+	defer mgr.StopAll()
+
+	// per resource authorization decisions are cached to spare the store on every publish and pull
+	auth.SetACLCacheTTL(time.Duration(cfg.ACLCacheTTL) * time.Second)
+
+	// acl changes older than the retention are dropped from the acl history as new ones get recorded
+	auth.SetACLHistoryRetention(time.Duration(cfg.ACLHistoryRetentionDays) * 24 * time.Hour)
+
+	auth.SetCaseInsensitiveUsernames(cfg.CaseInsensitiveUsernames)
+
+	// pulls that omit maxMessages get a bounded number of messages, unless their subscription declares a default of its own
+	subscriptions.SetDefaultMaxMessages(cfg.DefaultMaxMessages)
+
+	// purge the soft-deleted topics once their grace period expires
+	if cfg.TopicDeleteGracePeriod > 0 {
+		topics.StartReaper(time.Minute, time.Duration(cfg.TopicDeleteGracePeriod)*time.Second, store, broker)
+	}
+
+	// sample the topic counters that their throughput is computed from
+	if cfg.TopicSampleInterval > 0 {
+		topics.StartSampler(time.Duration(cfg.TopicSampleInterval)*time.Second, topics.ParseThroughputWindows(cfg.TopicThroughputWindows), store)
+	}
+
+	// brokers without a time index get the publish times of their offsets recorded in the store,
+	// drop the ones of the messages the broker no longer holds
+	if !broker.SupportsTimeIndex() {
+		topics.StartOffsetTimePruner(time.Hour, store, broker)
+	}
+
+	// keep the subscription offsets within the range of messages the broker still holds
+	subscriptions.StartReconciler(time.Duration(cfg.OffsetReconcileInterval)*time.Second, store, broker)
+
+	// snapshot the subscription offsets, so that the consumption can be rolled back to them
+	if cfg.OffsetSnapshotInterval > 0 {
+		subscriptions.StartSnapshotter(time.Duration(cfg.OffsetSnapshotInterval)*time.Second, time.Duration(cfg.OffsetSnapshotRetentionDays)*24*time.Hour, store)
+	}
 
 	// ams push server pushClient
 	pushClient := push.NewGrpcClient(cfg)
@@ -68,10 +149,13 @@ func main() {
 	}
 
 	// Initialize CORS specifics
-	xReqWithConType := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type"})
+	xReqWithConType := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "If-None-Match", "If-Match"})
 	allowVerbs := handlers.AllowedMethods([]string{"OPTIONS", "POST", "GET", "PUT", "DELETE", "HEAD"})
+	// browser clients need to read the etags to make conditional requests and modifications,
+	// and the publish quota of the topics to pace themselves
+	exposeETag := handlers.ExposedHeaders([]string{"ETag", "Retry-After", "X-RateLimit-Messages-Limit", "X-RateLimit-Messages-Remaining", "X-RateLimit-Bytes-Limit", "X-RateLimit-Bytes-Remaining"})
 	// Initialize server wth proper parameters
-	server := &http.Server{Addr: ":" + strconv.Itoa(cfg.Port), Handler: handlers.CORS(xReqWithConType, allowVerbs)(API.Router), TLSConfig: config}
+	server := &http.Server{Addr: ":" + strconv.Itoa(cfg.Port), Handler: handlers.CORS(xReqWithConType, allowVerbs, exposeETag)(API.Router), TLSConfig: config}
 
 	// Web service binds to server. Requests served over HTTPS.
 	err = server.ListenAndServeTLS(cfg.Cert, cfg.CertKey)
@@ -0,0 +1,603 @@
+package manifests
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/topics"
+	"github.com/ARGOeu/argo-messaging/validation"
+	log "github.com/sirupsen/logrus"
+	"github.com/twinj/uuid"
+)
+
+// The statuses that an import reports for each resource of the manifest
+const (
+	// StatusCreated marks a resource that didn't exist and got created
+	StatusCreated = "created"
+	// StatusAdded marks an existing user that became a member of the project
+	StatusAdded = "added"
+	// StatusUnchanged marks a user that was already a member of the project
+	StatusUnchanged = "unchanged"
+	// StatusInvalid marks a resource that failed the validation, which stops the whole import
+	StatusInvalid = "invalid"
+	// StatusSkipped marks a resource that wasn't applied because of another resource's failure
+	StatusSkipped = "skipped"
+	// StatusFailed marks the resource whose application failed
+	StatusFailed = "failed"
+	// StatusRolledBack marks a resource that got applied and was removed again after a later failure
+	StatusRolledBack = "rolled_back"
+)
+
+// Result holds the outcome of importing a single resource of the manifest
+type Result struct {
+	Resource string `json:"resource"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+}
+
+// Report holds whether an import got applied and the outcome of every resource, in the order they were applied
+type Report struct {
+	Applied bool     `json:"applied"`
+	Results []Result `json:"results"`
+}
+
+// ExportJSON exports the report to json format
+func (r *Report) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(r, "", "   ")
+	return string(output), err
+}
+
+// ImportOptions holds the context an import runs in
+type ImportOptions struct {
+	// PushEnabled should be set when the push functionality is available, otherwise push subscriptions are invalid
+	PushEnabled bool
+	// Actor is the uuid of the user that performs the import
+	Actor string
+	// CreatedOn is the creation time of the imported resources
+	CreatedOn time.Time
+}
+
+// step is a validated resource of the manifest, apply recreates it and returns a function that removes
+// whatever got applied, even when it fails halfway
+type step struct {
+	result Result
+	apply  func() (func(), error)
+}
+
+// importer validates the resources of a manifest against the project they are imported into
+type importer struct {
+	projectUUID string
+	projectName string
+	manifest    Manifest
+	opts        ImportOptions
+	broker      brokers.Broker
+	store       stores.Store
+	// known holds the users that acls can refer to, the members of the project and the users of the manifest
+	known map[string]bool
+	// declaredSchemas holds the uuids of the schemas of the manifest that topics can refer to
+	declaredSchemas map[string]string
+	// declaredTopics holds the topics of the manifest that subscriptions can refer to
+	declaredTopics map[string]bool
+}
+
+// Import recreates the users, schemas, topics and subscriptions of the manifest under the project.
+// The whole manifest gets validated first and nothing is applied unless every resource is valid.
+// The store has no transactions, so if applying a resource fails, the resources that have already been
+// applied are removed again. Broker topics are left in place, since they might hold messages from before the import
+func Import(projectUUID string, m Manifest, opts ImportOptions, broker brokers.Broker, store stores.Store) Report {
+
+	imp := importer{
+		projectUUID:     projectUUID,
+		projectName:     projects.GetNameByUUID(projectUUID, store),
+		manifest:        m,
+		opts:            opts,
+		broker:          broker,
+		store:           store,
+		known:           map[string]bool{},
+		declaredSchemas: map[string]string{},
+		declaredTopics:  map[string]bool{},
+	}
+
+	members, _ := auth.FindUsers(projectUUID, "", "", false, store)
+	for _, u := range members.List {
+		imp.known[u.Name] = true
+	}
+
+	steps := []step{}
+	seen := map[string]bool{}
+	for _, u := range m.Users {
+		steps = append(steps, imp.userStep(u, seen))
+	}
+
+	seen = map[string]bool{}
+	for _, sc := range m.Schemas {
+		steps = append(steps, imp.schemaStep(sc, seen))
+	}
+
+	seen = map[string]bool{}
+	for _, t := range m.Topics {
+		steps = append(steps, imp.topicStep(t, seen))
+	}
+
+	seen = map[string]bool{}
+	for _, s := range m.Subscriptions {
+		steps = append(steps, imp.subStep(s, seen))
+	}
+
+	report := Report{Results: []Result{}}
+	valid := true
+	for _, st := range steps {
+		if st.result.Status == StatusInvalid {
+			valid = false
+		}
+	}
+
+	if !valid {
+		for _, st := range steps {
+			if st.result.Status != StatusInvalid {
+				st.result.Status = StatusSkipped
+				st.result.Message = ""
+			}
+			report.Results = append(report.Results, st.result)
+		}
+		return report
+	}
+
+	undos := []func(){}
+	for i, st := range steps {
+		undo, err := st.apply()
+		if err == nil {
+			undos = append(undos, undo)
+			report.Results = append(report.Results, st.result)
+			continue
+		}
+
+		log.WithFields(
+			log.Fields{
+				"type":         "service_log",
+				"project_uuid": projectUUID,
+				"resource":     st.result.Resource,
+				"name":         st.result.Name,
+				"error":        err.Error(),
+			},
+		).Error("Could not import resource, rolling back the import")
+
+		if undo != nil {
+			undo()
+		}
+		for j := len(undos) - 1; j >= 0; j-- {
+			if undos[j] != nil {
+				undos[j]()
+				report.Results[j].Status = StatusRolledBack
+				report.Results[j].Message = ""
+			}
+		}
+
+		report.Results = append(report.Results, Result{Resource: st.result.Resource, Name: st.result.Name, Status: StatusFailed, Message: err.Error()})
+		for _, rest := range steps[i+1:] {
+			rest.result.Status = StatusSkipped
+			rest.result.Message = ""
+			report.Results = append(report.Results, rest.result)
+		}
+		return report
+	}
+
+	report.Applied = true
+	return report
+}
+
+// invalid returns a step that reports the resource as invalid and can't be applied
+func invalid(resource string, name string, msg string) step {
+	return step{result: Result{Resource: resource, Name: name, Status: StatusInvalid, Message: msg}}
+}
+
+// unknownUsers returns a validation message for the users of the acl that the import doesn't know of
+func (imp *importer) unknownUsers(acl []string) string {
+	missing := []string{}
+	for _, username := range acl {
+		if !imp.known[username] {
+			missing = append(missing, username)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return "User(s): " + strings.Join(missing, ", ") + " do not exist"
+}
+
+// userStep validates a user of the manifest, which gets created if it doesn't exist
+// or becomes a member of the project if it exists only in other projects
+func (imp *importer) userStep(u User, seen map[string]bool) step {
+
+	if u.Name == "" {
+		return invalid("users", u.Name, "user name should not be empty")
+	}
+
+	if seen[u.Name] {
+		return invalid("users", u.Name, "user is declared more than once")
+	}
+	seen[u.Name] = true
+
+	validRoles := imp.store.GetAllRoles()
+	for _, role := range u.Roles {
+		if !auth.IsRoleValid(role, validRoles) {
+			return invalid("users", u.Name, "invalid role: "+role)
+		}
+	}
+
+	imp.known[u.Name] = true
+
+	existing, err := auth.FindUsers("", "", u.Name, true, imp.store)
+	if err != nil {
+		return step{
+			result: Result{Resource: "users", Name: u.Name, Status: StatusCreated},
+			apply: func() (func(), error) {
+				token, err := auth.GenToken()
+				if err != nil {
+					return nil, err
+				}
+				userUUID := uuid.NewV4().String()
+				pRoles := []auth.ProjectRoles{{Project: imp.projectName, Roles: u.Roles}}
+				_, err = auth.CreateUser(userUUID, u.Name, u.FirstName, u.LastName, u.Organization, u.Description,
+					pRoles, token, u.Email, []string{}, imp.opts.CreatedOn, imp.opts.Actor, imp.store)
+				if err != nil {
+					return nil, err
+				}
+				return func() { auth.RemoveUser(userUUID, imp.store) }, nil
+			},
+		}
+	}
+
+	previous := existing.One()
+	for _, p := range previous.Projects {
+		if p.Project == imp.projectName {
+			return step{
+				result: Result{Resource: "users", Name: u.Name, Status: StatusUnchanged},
+				apply:  func() (func(), error) { return nil, nil },
+			}
+		}
+	}
+
+	return step{
+		result: Result{Resource: "users", Name: u.Name, Status: StatusAdded},
+		apply: func() (func(), error) {
+			err := auth.AppendToUserProjects(previous.UUID, imp.projectUUID, imp.store, u.Roles...)
+			if err != nil {
+				return nil, err
+			}
+			return func() {
+				auth.UpdateUser(previous.UUID, previous.FirstName, previous.LastName, previous.Organization, previous.Description,
+					previous.Name, previous.Projects, previous.Email, previous.ServiceRoles, time.Now().UTC(), false, imp.store)
+			}, nil
+		},
+	}
+}
+
+// schemaStep validates a schema of the manifest, its definition gets checked once it is created
+func (imp *importer) schemaStep(sc Schema, seen map[string]bool) step {
+
+	if !validation.ValidName(sc.Name) {
+		return invalid("schemas", sc.Name, "Invalid schema name")
+	}
+
+	if seen[sc.Name] {
+		return invalid("schemas", sc.Name, "schema is declared more than once")
+	}
+	seen[sc.Name] = true
+
+	exists, err := schemas.ExistsWithName(imp.projectUUID, sc.Name, imp.store)
+	if err != nil {
+		return invalid("schemas", sc.Name, err.Error())
+	}
+
+	if exists {
+		return invalid("schemas", sc.Name, "schema already exists")
+	}
+
+	schemaUUID := uuid.NewV4().String()
+	imp.declaredSchemas[sc.Name] = schemaUUID
+
+	return step{
+		result: Result{Resource: "schemas", Name: sc.Name, Status: StatusCreated},
+		apply: func() (func(), error) {
+			_, err := schemas.Create(imp.projectUUID, schemaUUID, sc.Name, sc.Type, sc.RawSchema, imp.store)
+			if err != nil {
+				return nil, err
+			}
+			return func() { schemas.Delete(schemaUUID, imp.store) }, nil
+		},
+	}
+}
+
+// topicStep validates a topic of the manifest, which gets created both in the store and in the broker
+func (imp *importer) topicStep(t Topic, seen map[string]bool) step {
+
+	if !validation.ValidName(t.Name) {
+		return invalid("topics", t.Name, "Invalid topic name")
+	}
+
+	if seen[t.Name] {
+		return invalid("topics", t.Name, "topic is declared more than once")
+	}
+	seen[t.Name] = true
+
+	if topics.HasTopic(imp.projectUUID, t.Name, imp.store) || topics.IsDeleted(imp.projectUUID, t.Name, imp.store) {
+		return invalid("topics", t.Name, "topic already exists")
+	}
+
+	schemaUUID := imp.declaredSchemas[t.Schema]
+	if t.Schema != "" && schemaUUID == "" {
+		sl, err := schemas.Find(imp.projectUUID, "", t.Schema, imp.store)
+		if err != nil || sl.Empty() {
+			return invalid("topics", t.Name, fmt.Sprintf("schema %v doesn't exist", t.Schema))
+		}
+		schemaUUID = sl.Schemas[0].UUID
+	}
+
+	publishAcks := strings.ToLower(t.PublishAcks)
+	if publishAcks != "" && !brokers.ValidAcksLevel(publishAcks) {
+		return invalid("topics", t.Name, "Invalid publish acks level, it should be one of 0, 1 or all")
+	}
+
+	if t.Partitions < 0 || t.ReplicationFactor < 0 {
+		return invalid("topics", t.Name, "Partitions and replication factor should be positive numbers")
+	}
+
+	if err := labels.Validate(t.Labels); err != nil {
+		return invalid("topics", t.Name, err.Error())
+	}
+
+	if msg := imp.unknownUsers(t.ACL); msg != "" {
+		return invalid("topics", t.Name, msg)
+	}
+
+	imp.declaredTopics[t.Name] = true
+
+	return step{
+		result: Result{Resource: "topics", Name: t.Name, Status: StatusCreated},
+		apply: func() (func(), error) {
+			_, err := topics.CreateTopic(imp.projectUUID, t.Name, schemaUUID, publishAcks, imp.opts.CreatedOn, imp.store)
+			if err != nil {
+				return nil, err
+			}
+			undo := func() { topics.RemoveTopic(imp.projectUUID, t.Name, imp.store) }
+
+			if len(t.Labels) > 0 {
+				if err := topics.UpdateTopicLabels(imp.projectUUID, t.Name, t.Labels, imp.store); err != nil {
+					return undo, err
+				}
+			}
+
+			if t.PartitionKeyAttribute != "" {
+				if err := topics.UpdateTopicPartitionKey(imp.projectUUID, t.Name, t.PartitionKeyAttribute, imp.store); err != nil {
+					return undo, err
+				}
+			}
+
+			if len(t.ACL) > 0 {
+				if err := auth.ModACL(imp.projectUUID, "topics", t.Name, t.ACL, imp.opts.Actor, imp.store); err != nil {
+					return undo, err
+				}
+			}
+
+			if _, err := imp.broker.CreateTopic(brokers.TopicName(imp.projectUUID, t.Name), t.Partitions, t.ReplicationFactor); err != nil {
+				return undo, err
+			}
+
+			return undo, nil
+		},
+	}
+}
+
+// subStep validates a subscription of the manifest. Its topic should either be declared in the manifest
+// or already exist in the project, and a push subscription has to get its endpoint verified again
+func (imp *importer) subStep(s Subscription, seen map[string]bool) step {
+
+	if !validation.ValidName(s.Name) {
+		return invalid("subscriptions", s.Name, "Invalid subscription name")
+	}
+
+	if seen[s.Name] {
+		return invalid("subscriptions", s.Name, "subscription is declared more than once")
+	}
+	seen[s.Name] = true
+
+	if subscriptions.HasSub(imp.projectUUID, s.Name, imp.store) {
+		return invalid("subscriptions", s.Name, "subscription already exists")
+	}
+
+	tProject, tName, err := subscriptions.ExtractFullTopicRef(s.Topic)
+	if err != nil {
+		return invalid("subscriptions", s.Name, "Invalid topic name")
+	}
+
+	// the manifest's own project refers to the project that it gets imported into
+	if tProject != imp.manifest.Project && tProject != imp.projectName {
+		return invalid("subscriptions", s.Name, "topic should belong to the project")
+	}
+
+	if !imp.declaredTopics[tName] && !topics.HasTopic(imp.projectUUID, tName, imp.store) {
+		return invalid("subscriptions", s.Name, fmt.Sprintf("topic %v doesn't exist", tName))
+	}
+
+	if s.Ack < 0 || s.Ack > 600 {
+		return invalid("subscriptions", s.Name, "Invalid ackDeadlineSeconds(needs value between 0 and 600) Arguments")
+	}
+
+	if s.Transform != nil {
+		if err := s.Transform.Validate(); err != nil {
+			return invalid("subscriptions", s.Name, err.Error())
+		}
+	}
+
+	if err := labels.Validate(s.Labels); err != nil {
+		return invalid("subscriptions", s.Name, err.Error())
+	}
+
+	limits := subscriptions.Subscription{MaxConcurrentPulls: s.MaxConcurrentPulls, IsolationLevel: s.IsolationLevel, DefaultMaxMessages: s.DefaultMaxMessages, SamplingRate: s.SamplingRate}
+	if !limits.ValidMaxConcurrentPulls() {
+		return invalid("subscriptions", s.Name, subscriptions.InvalidMaxConcurrentPulls)
+	}
+
+	if !limits.ValidIsolationLevel() {
+		return invalid("subscriptions", s.Name, subscriptions.InvalidIsolationLevel)
+	}
+
+	if !imp.broker.SupportsIsolationLevel(limits.ReadIsolation()) {
+		return invalid("subscriptions", s.Name, subscriptions.UnsupportedIsolationLevel)
+	}
+
+	if !limits.ValidDefaultMaxMessages() {
+		return invalid("subscriptions", s.Name, subscriptions.InvalidDefaultMaxMessages)
+	}
+
+	if !limits.ValidSamplingRate() {
+		return invalid("subscriptions", s.Name, subscriptions.InvalidSamplingRate)
+	}
+
+	if s.Offset != nil && *s.Offset < 0 {
+		return invalid("subscriptions", s.Name, "offset should not be negative")
+	}
+
+	pushCfg := s.PushCfg
+	if !pushCfg.IsEmpty() {
+
+		if !imp.opts.PushEnabled {
+			return invalid("subscriptions", s.Name, "Push functionality is currently disabled")
+		}
+
+		if !subscriptions.IsValidPushEndpoint(pushCfg.Pend) {
+			return invalid("subscriptions", s.Name, "Push endpoint should be addressed by a valid https url")
+		}
+
+		if !subscriptions.ValidFanout(pushCfg.Pend, pushCfg.Fanout) {
+			return invalid("subscriptions", s.Name, subscriptions.InvalidFanoutEndpointsError)
+		}
+
+		if !pushCfg.ValidMaxConcurrentDeliveries() {
+			return invalid("subscriptions", s.Name, subscriptions.InvalidMaxConcurrentDeliveries)
+		}
+
+		if !pushCfg.ValidMaxRetryDuration() {
+			return invalid("subscriptions", s.Name, subscriptions.InvalidMaxRetryDuration)
+		}
+
+		if !pushCfg.ValidMaxRetries() {
+			return invalid("subscriptions", s.Name, subscriptions.InvalidMaxRetries)
+		}
+
+		if pushCfg.AuthorizationHeader.Type == "" {
+			pushCfg.AuthorizationHeader.Type = subscriptions.AutoGenerationAuthorizationHeader
+		}
+
+		if !subscriptions.IsAuthorizationHeaderTypeSupported(pushCfg.AuthorizationHeader.Type) {
+			return invalid("subscriptions", s.Name, subscriptions.UnSupportedAuthorizationHeader)
+		}
+
+		if pushCfg.RetPol.PolicyType == "" {
+			pushCfg.RetPol.PolicyType = subscriptions.LinearRetryPolicyType
+		}
+
+		if !subscriptions.IsRetryPolicySupported(pushCfg.RetPol.PolicyType) {
+			return invalid("subscriptions", s.Name, subscriptions.UnSupportedRetryPolicyError)
+		}
+
+		if pushCfg.MaxMessages == 0 {
+			pushCfg.MaxMessages = 1
+		}
+
+		if pushCfg.RetPol.Period <= 0 {
+			pushCfg.RetPol.Period = subscriptions.DefaultRetryPeriod
+		}
+	}
+
+	if msg := imp.unknownUsers(s.ACL); msg != "" {
+		return invalid("subscriptions", s.Name, msg)
+	}
+
+	st := step{result: Result{Resource: "subscriptions", Name: s.Name, Status: StatusCreated}}
+	if !pushCfg.IsEmpty() {
+		st.result.Message = "the push endpoint should be verified before messages get pushed to it"
+	}
+
+	st.apply = func() (func(), error) {
+
+		brkTopic := topics.BrokerTopic(imp.projectUUID, tName, imp.store)
+		offset := imp.broker.GetMaxOffset(brkTopic)
+		if s.Offset != nil {
+			minOffset := imp.broker.GetMinOffset(brkTopic)
+			switch {
+			case *s.Offset < minOffset:
+				offset = minOffset
+			case *s.Offset <= offset:
+				offset = *s.Offset
+			}
+		}
+
+		authzValue := ""
+		verifyHash := ""
+		if !pushCfg.IsEmpty() {
+			var err error
+			if pushCfg.AuthorizationHeader.Type == subscriptions.AutoGenerationAuthorizationHeader {
+				// keeping a value given in the manifest lets the push endpoint accept the messages without any change,
+				// while the masked values of the exports are replaced by new ones
+				authzValue = pushCfg.AuthorizationHeader.Value
+				if authzValue == "" || authzValue == subscriptions.SecretMask {
+					if authzValue, err = auth.GenToken(); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if verifyHash, err = auth.GenToken(); err != nil {
+				return nil, err
+			}
+		}
+
+		settings := subscriptions.Settings{
+			Fanout:                  pushCfg.Fanout,
+			MaxConcurrentDeliveries: pushCfg.MaxConcurrentDeliveries,
+			MaxRetryDuration:        pushCfg.MaxRetryDuration,
+			MaxRetries:              pushCfg.MaxRetries,
+			Transform:               s.Transform,
+			NewMessagesOnly:         s.NewMessagesOnly,
+			Deduplicate:             s.Deduplicate,
+			Prioritize:              s.Prioritize,
+			AtMostOnce:              s.AtMostOnce,
+			IsolationLevel:          s.IsolationLevel,
+			DefaultMaxMessages:      s.DefaultMaxMessages,
+			MaxConcurrentPulls:      s.MaxConcurrentPulls,
+			Labels:                  s.Labels,
+		}
+		if limits.Sampled() {
+			settings.SamplingRate = *s.SamplingRate
+		}
+
+		_, err := subscriptions.CreateSub(imp.projectUUID, s.Name, tName, pushCfg.Pend, offset, pushCfg.MaxMessages,
+			pushCfg.AuthorizationHeader.Type, authzValue, s.Ack, pushCfg.RetPol.PolicyType, pushCfg.RetPol.Period,
+			verifyHash, false, imp.opts.CreatedOn, settings, imp.store)
+		if err != nil {
+			return nil, err
+		}
+		undo := func() { subscriptions.RemoveSub(imp.projectUUID, s.Name, imp.store) }
+
+		if len(s.ACL) > 0 {
+			if err := auth.ModACL(imp.projectUUID, "subscriptions", s.Name, s.ACL, imp.opts.Actor, imp.store); err != nil {
+				return undo, err
+			}
+		}
+
+		return undo, nil
+	}
+
+	return st
+}
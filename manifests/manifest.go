@@ -0,0 +1,227 @@
+package manifests
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/projects"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	"github.com/ARGOeu/argo-messaging/topics"
+)
+
+// Manifest describes all the resources of a project in a single document,
+// so that they can be recreated in the same or in another deployment
+type Manifest struct {
+	Project       string         `json:"project"`
+	ExportedOn    string         `json:"exported_on"`
+	Users         []User         `json:"users"`
+	Schemas       []Schema       `json:"schemas"`
+	Topics        []Topic        `json:"topics"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+// User is a member of the project along with the roles it holds in it. Tokens are never exported
+type User struct {
+	Name         string   `json:"name"`
+	FirstName    string   `json:"first_name,omitempty"`
+	LastName     string   `json:"last_name,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Roles        []string `json:"roles"`
+}
+
+// Schema holds the definition of a schema that the project's topics can validate their messages against
+type Schema struct {
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"`
+	RawSchema map[string]interface{} `json:"schema"`
+}
+
+// Topic holds the configuration and the acl of a topic
+type Topic struct {
+	Name string `json:"name"`
+	// Schema is the name of a schema of the project that the topic's messages are validated against
+	Schema                string            `json:"schema,omitempty"`
+	PublishAcks           string            `json:"publish_acks,omitempty"`
+	Labels                map[string]string `json:"labels,omitempty"`
+	PartitionKeyAttribute string            `json:"partition_key_attribute,omitempty"`
+	Partitions            int               `json:"partitions,omitempty"`
+	ReplicationFactor     int               `json:"replication_factor,omitempty"`
+	ACL                   []string          `json:"acl"`
+}
+
+// Subscription holds the configuration and the acl of a subscription
+type Subscription struct {
+	Name string `json:"name"`
+	// Topic is the full reference of the subscription's topic, e.g. projects/ARGO/topics/topic1
+	Topic              string                   `json:"topic"`
+	PushCfg            subscriptions.PushConfig `json:"pushConfig"`
+	Ack                int                      `json:"ackDeadlineSeconds"`
+	Transform          *subscriptions.Transform `json:"transform,omitempty"`
+	NewMessagesOnly    bool                     `json:"newMessagesOnly,omitempty"`
+	Deduplicate        bool                     `json:"deduplicate,omitempty"`
+	MaxConcurrentPulls int                      `json:"maxConcurrentPulls,omitempty"`
+	Prioritize         bool                     `json:"prioritize,omitempty"`
+	AtMostOnce         bool                     `json:"atMostOnce,omitempty"`
+	IsolationLevel     string                   `json:"isolationLevel,omitempty"`
+	DefaultMaxMessages int                      `json:"defaultMaxMessages,omitempty"`
+	SamplingRate       *float64                 `json:"samplingRate,omitempty"`
+	Labels             map[string]string        `json:"labels,omitempty"`
+	ACL                []string                 `json:"acl"`
+	// Offset is included only when requested during the export
+	Offset *int64 `json:"offset,omitempty"`
+}
+
+// GetFromJSON retrieves a manifest from the given json input
+func GetFromJSON(input []byte) (Manifest, error) {
+	m := Manifest{}
+	err := json.Unmarshal(input, &m)
+	return m, err
+}
+
+// ExportJSON exports the manifest to json format
+func (m *Manifest) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(m, "", "   ")
+	return string(output), err
+}
+
+// Export collects the users, schemas, topics and subscriptions of a project in a manifest.
+// The acls keep only the members of the project, leaving out service users such as the push worker
+func Export(projectUUID string, withOffsets bool, exportedOn time.Time, broker brokers.Broker, store stores.Store) (Manifest, error) {
+
+	projectName := projects.GetNameByUUID(projectUUID, store)
+	if projectName == "" {
+		return Manifest{}, errors.New("not found")
+	}
+
+	m := Manifest{
+		Project:       projectName,
+		ExportedOn:    timestamp.Format(exportedOn),
+		Users:         []User{},
+		Schemas:       []Schema{},
+		Topics:        []Topic{},
+		Subscriptions: []Subscription{},
+	}
+
+	members := map[string]bool{}
+	users, err := auth.FindUsers(projectUUID, "", "", false, store)
+	if err != nil && err.Error() != "not found" {
+		return Manifest{}, err
+	}
+
+	for _, u := range users.List {
+		roles := []string{}
+		for _, p := range u.Projects {
+			if p.Project == projectName {
+				roles = p.Roles
+			}
+		}
+		m.Users = append(m.Users, User{
+			Name:         u.Name,
+			FirstName:    u.FirstName,
+			LastName:     u.LastName,
+			Organization: u.Organization,
+			Description:  u.Description,
+			Email:        u.Email,
+			Roles:        roles,
+		})
+		members[u.Name] = true
+	}
+
+	memberACL := func(resourceType string, name string) ([]string, error) {
+		result := []string{}
+		acl, err := auth.GetACL(projectUUID, resourceType, name, store)
+		if err != nil {
+			// the resource was removed while exporting
+			if err.Error() == "not found" {
+				return result, nil
+			}
+			return nil, err
+		}
+		for _, username := range acl.AuthUsers {
+			if members[username] {
+				result = append(result, username)
+			}
+		}
+		return result, nil
+	}
+
+	schemaList, err := schemas.Find(projectUUID, "", "", store)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	for _, sc := range schemaList.Schemas {
+		m.Schemas = append(m.Schemas, Schema{Name: sc.Name, Type: sc.Type, RawSchema: sc.RawSchema})
+	}
+
+	tl, err := topics.Find(projectUUID, "", "", "", 0, false, store)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	for _, t := range tl.Topics {
+		mt := Topic{
+			Name:                  t.Name,
+			PublishAcks:           t.PublishAcks,
+			Labels:                t.Labels,
+			PartitionKeyAttribute: t.PartitionKeyAttribute,
+		}
+		if t.Schema != "" {
+			_, mt.Schema, _ = schemas.ExtractSchema(t.Schema)
+		}
+		// brokers without introspection support leave the partitioning to the broker defaults
+		if cfg, err := broker.DescribeTopic(t.BrokerTopic); err == nil {
+			mt.Partitions = cfg.Partitions
+			mt.ReplicationFactor = cfg.ReplicationFactor
+		}
+		if mt.ACL, err = memberACL("topics", t.Name); err != nil {
+			return Manifest{}, err
+		}
+		m.Topics = append(m.Topics, mt)
+	}
+
+	sl, err := subscriptions.Find(projectUUID, "", "", "", 0, store)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	for _, s := range sl.Subscriptions {
+		// the secrets don't leave the service, the import generates new ones in their place
+		s.MaskSecrets()
+		ms := Subscription{
+			Name:               s.Name,
+			Topic:              strings.TrimPrefix(s.FullTopic, "/"),
+			PushCfg:            s.PushCfg,
+			Ack:                s.Ack,
+			Transform:          s.Transform,
+			NewMessagesOnly:    s.NewMessagesOnly,
+			Deduplicate:        s.Deduplicate,
+			MaxConcurrentPulls: s.MaxConcurrentPulls,
+			Prioritize:         s.Prioritize,
+			AtMostOnce:         s.AtMostOnce,
+			IsolationLevel:     s.IsolationLevel,
+			DefaultMaxMessages: s.DefaultMaxMessages,
+			SamplingRate:       s.SamplingRate,
+			Labels:             s.Labels,
+		}
+		if withOffsets {
+			offset := s.Offset
+			ms.Offset = &offset
+		}
+		if ms.ACL, err = memberACL("subscriptions", s.Name); err != nil {
+			return Manifest{}, err
+		}
+		m.Subscriptions = append(m.Subscriptions, ms)
+	}
+
+	return m, nil
+}
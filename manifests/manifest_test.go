@@ -0,0 +1,176 @@
+package manifests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/schemas"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/topics"
+	"github.com/stretchr/testify/suite"
+)
+
+type ManifestsTestSuite struct {
+	suite.Suite
+}
+
+func (suite *ManifestsTestSuite) TestExport() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	brk := &brokers.MockBroker{}
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	m, err := Export("argo_uuid", false, tm, brk, store)
+	suite.Nil(err)
+	suite.Equal("ARGO", m.Project)
+	suite.Equal("2009-11-10T23:00:00Z", m.ExportedOn)
+	suite.Equal(7, len(m.Users))
+	suite.Equal(User{Name: "UserA", FirstName: "FirstA", LastName: "LastA", Organization: "OrgA", Description: "DescA",
+		Email: "foo-email", Roles: []string{"consumer", "publisher"}}, m.Users[1])
+	suite.Equal(3, len(m.Schemas))
+	suite.Equal("schema-1", m.Schemas[0].Name)
+	suite.Equal([]string{"topic4", "topic3", "topic2", "topic1"}, []string{m.Topics[0].Name, m.Topics[1].Name, m.Topics[2].Name, m.Topics[3].Name})
+	suite.Equal(Topic{Name: "topic2", Schema: "schema-1", ACL: []string{"UserA", "UserB", "UserZ"}}, m.Topics[2])
+	suite.Equal(4, len(m.Subscriptions))
+	suite.Equal("projects/ARGO/topics/topic1", m.Subscriptions[3].Topic)
+	suite.Equal([]string{"UserA", "UserB"}, m.Subscriptions[3].ACL)
+	suite.Nil(m.Subscriptions[3].Offset)
+	suite.Equal("sub4", m.Subscriptions[0].Name)
+	suite.Equal(subscriptions.SecretMask, m.Subscriptions[0].PushCfg.AuthorizationHeader.Value)
+
+	// offsets are included only when requested
+	m, err = Export("argo_uuid", true, tm, brk, store)
+	suite.Nil(err)
+	suite.Equal(int64(0), *m.Subscriptions[3].Offset)
+
+	// unknown project
+	_, err = Export("unknown", false, tm, brk, store)
+	suite.Equal("not found", err.Error())
+}
+
+func (suite *ManifestsTestSuite) TestImport() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	brk := &brokers.MockBroker{}
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+	opts := ImportOptions{PushEnabled: true, Actor: "uuid1", CreatedOn: tm}
+
+	m, _ := Export("argo_uuid", true, tm, brk, store)
+	m.Subscriptions[0].PushCfg.Pend = "https://endpoint.foo"
+
+	report := Import("argo_uuid2", m, opts, brk, store)
+	suite.True(report.Applied)
+	suite.Equal(18, len(report.Results))
+	suite.Equal(Result{Resource: "users", Name: "UserA", Status: StatusAdded}, report.Results[1])
+	suite.Equal(Result{Resource: "schemas", Name: "schema-1", Status: StatusCreated}, report.Results[7])
+	suite.Equal(Result{Resource: "topics", Name: "topic1", Status: StatusCreated}, report.Results[13])
+	suite.Equal(Result{Resource: "subscriptions", Name: "sub4", Status: StatusCreated,
+		Message: "the push endpoint should be verified before messages get pushed to it"}, report.Results[14])
+
+	// the members of the source project became members of the target one
+	u, _ := auth.FindUsers("", "uuid1", "", true, store)
+	suite.Equal("ARGO2", u.One().Projects[1].Project)
+
+	// the topics refer to the recreated schemas
+	tl, _ := topics.Find("argo_uuid2", "", "topic2", "", 0, false, store)
+	suite.Equal("projects/ARGO2/schemas/schema-1", tl.Topics[0].Schema)
+	acl, _ := auth.GetACL("argo_uuid2", "topics", "topic2", store)
+	suite.Equal([]string{"UserA", "UserB", "UserZ"}, acl.AuthUsers)
+
+	// the push subscription gets a new authorization header, since the exported one was masked, and has to be verified again
+	sl, _ := subscriptions.Find("argo_uuid2", "", "sub4", "", 0, store)
+	suite.Equal("topic4", sl.Subscriptions[0].Topic)
+	suite.Equal("https://endpoint.foo", sl.Subscriptions[0].PushCfg.Pend)
+	suite.NotEqual("auth-header-1", sl.Subscriptions[0].PushCfg.AuthorizationHeader.Value)
+	suite.NotEqual(subscriptions.SecretMask, sl.Subscriptions[0].PushCfg.AuthorizationHeader.Value)
+	suite.NotEmpty(sl.Subscriptions[0].PushCfg.AuthorizationHeader.Value)
+	suite.False(sl.Subscriptions[0].PushCfg.Verified)
+	suite.NotEqual("push-id-1", sl.Subscriptions[0].PushCfg.VerificationHash)
+	acl, _ = auth.GetACL("argo_uuid2", "subscriptions", "sub1", store)
+	suite.Equal([]string{"UserA", "UserB"}, acl.AuthUsers)
+
+	// importing the same manifest again conflicts with the existing resources
+	report = Import("argo_uuid2", m, opts, brk, store)
+	suite.False(report.Applied)
+	suite.Equal(Result{Resource: "users", Name: "UserA", Status: StatusSkipped}, report.Results[1])
+	suite.Equal(Result{Resource: "topics", Name: "topic1", Status: StatusInvalid, Message: "topic already exists"}, report.Results[13])
+}
+
+func (suite *ManifestsTestSuite) TestImportInvalid() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	brk := &brokers.MockBroker{}
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	m := Manifest{
+		Project: "ARGO",
+		Users:   []User{{Name: "UserNew", Roles: []string{"consumer"}}, {Name: "UserBad", Roles: []string{"unknown"}}},
+		Topics:  []Topic{{Name: "topicNew", ACL: []string{"UserNew"}}, {Name: "topicOther", Schema: "schema-x", ACL: []string{}}},
+		Subscriptions: []Subscription{
+			{Name: "subNew", Topic: "projects/ARGO/topics/topicNew", ACL: []string{"UserNew", "UserUnknown"}},
+			{Name: "subPush", Topic: "projects/ARGO/topics/topicNew", PushCfg: subscriptions.PushConfig{Pend: "https://endpoint.foo"}, ACL: []string{}},
+			{Name: "subOther", Topic: "projects/ARGO/topics/topicMissing", ACL: []string{}},
+		},
+	}
+
+	report := Import("argo_uuid2", m, ImportOptions{Actor: "uuid1", CreatedOn: tm}, brk, store)
+	suite.False(report.Applied)
+	suite.Equal([]Result{
+		{Resource: "users", Name: "UserNew", Status: StatusSkipped},
+		{Resource: "users", Name: "UserBad", Status: StatusInvalid, Message: "invalid role: unknown"},
+		{Resource: "topics", Name: "topicNew", Status: StatusSkipped},
+		{Resource: "topics", Name: "topicOther", Status: StatusInvalid, Message: "schema schema-x doesn't exist"},
+		{Resource: "subscriptions", Name: "subNew", Status: StatusInvalid, Message: "User(s): UserUnknown do not exist"},
+		{Resource: "subscriptions", Name: "subPush", Status: StatusInvalid, Message: "Push functionality is currently disabled"},
+		{Resource: "subscriptions", Name: "subOther", Status: StatusInvalid, Message: "topic topicMissing doesn't exist"},
+	}, report.Results)
+
+	// nothing got applied
+	suite.False(auth.ExistsWithName("UserNew", store))
+	suite.False(topics.HasTopic("argo_uuid2", "topicNew", store))
+}
+
+func (suite *ManifestsTestSuite) TestImportRollback() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	brk := &brokers.MockBroker{}
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	m := Manifest{
+		Project: "ARGO",
+		Users:   []User{{Name: "UserNew", Roles: []string{"consumer"}}, {Name: "UserA", Roles: []string{"consumer"}}},
+		Schemas: []Schema{{Name: "schemaNew", Type: "json", RawSchema: map[string]interface{}{"type": "object"}}},
+		Topics: []Topic{
+			{Name: "topicNew", Schema: "schemaNew", ACL: []string{"UserNew"}},
+			{Name: "topicReplicated", ReplicationFactor: 3, ACL: []string{}},
+		},
+		Subscriptions: []Subscription{{Name: "subNew", Topic: "projects/ARGO/topics/topicNew", ACL: []string{"UserNew"}}},
+	}
+
+	// the broker can't create a topic with more replicas than its brokers
+	report := Import("argo_uuid2", m, ImportOptions{Actor: "uuid1", CreatedOn: tm}, brk, store)
+	suite.False(report.Applied)
+	suite.Equal(StatusRolledBack, report.Results[0].Status)
+	suite.Equal(StatusRolledBack, report.Results[1].Status)
+	suite.Equal(StatusRolledBack, report.Results[2].Status)
+	suite.Equal(StatusRolledBack, report.Results[3].Status)
+	suite.Equal("topicReplicated", report.Results[4].Name)
+	suite.Equal(StatusFailed, report.Results[4].Status)
+	suite.Equal(Result{Resource: "subscriptions", Name: "subNew", Status: StatusSkipped}, report.Results[5])
+
+	// the applied resources have been removed again
+	suite.False(auth.ExistsWithName("UserNew", store))
+	u, _ := auth.FindUsers("", "uuid1", "", true, store)
+	suite.Equal(1, len(u.One().Projects))
+	exists, _ := schemas.ExistsWithName("argo_uuid2", "schemaNew", store)
+	suite.False(exists)
+	suite.False(topics.HasTopic("argo_uuid2", "topicNew", store))
+	suite.False(topics.HasTopic("argo_uuid2", "topicReplicated", store))
+}
+
+func TestManifestsTestSuite(t *testing.T) {
+	suite.Run(t, new(ManifestsTestSuite))
+}
@@ -2,24 +2,84 @@ package messages
 
 import (
 	"bytes"
+	"compress/gzip"
 	b64 "encoding/base64"
 	"encoding/json"
 	"errors"
+	"io"
+	"io/ioutil"
 	"sort"
 	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/timestamp"
+)
+
+// CompressionAttribute is the message attribute that declares the compression applied to the message's data
+const CompressionAttribute = "compression"
+
+// CompressionGzip marks data compressed with gzip
+const CompressionGzip = "gzip"
+
+// DefaultMaxMessageSize bounds the decompressed data of a message when no max message size is given
+const DefaultMaxMessageSize = 1048576
+
+// ErrDecompressedTooLarge is returned when the decompressed data of a message exceed the max message size
+var ErrDecompressedTooLarge = errors.New("Decompressed data exceed the max message size")
+
+// PriorityAttribute is the message attribute that declares the priority level of the message
+const PriorityAttribute = "priority"
+
+const (
+	// PriorityHigh marks messages that prioritizing subscriptions deliver ahead of the rest of a pulled batch
+	PriorityHigh = "high"
+	// PriorityNormal is the priority of messages that don't declare one
+	PriorityNormal = "normal"
+	// PriorityLow marks messages that prioritizing subscriptions deliver after the rest of a pulled batch
+	PriorityLow = "low"
 )
 
+// priorityRanks orders the supported priority levels, the higher the rank the sooner the delivery
+var priorityRanks = map[string]int{
+	PriorityHigh:   2,
+	PriorityNormal: 1,
+	PriorityLow:    0,
+}
+
+// DefaultPublishTimeMaxSkew is how far ahead of the service's clock a producer supplied publish time may be,
+// when the service doesn't configure it
+const DefaultPublishTimeMaxSkew = time.Minute
+
 // RecMsg holds info for a received message
 type RecMsg struct {
 	AckID string  `json:"ackId,omitempty"`
 	Msg   Message `json:"message"`
+	// Unprojected marks a message whose data couldn't be projected to the requested fields and are delivered as is
+	Unprojected bool `json:"unprojected,omitempty"`
+	// AckDeadline is the time by which the message should be acknowledged, messages that are consumed as soon as
+	// they are handed out have none
+	AckDeadline string `json:"ackDeadline,omitempty"`
 }
 
 // RecList holds the array of the receivedMessages - subscription related
 type RecList struct {
 	RecMsgs []RecMsg `json:"receivedMessages"`
+	// MessageCount is the number of the received messages
+	MessageCount int `json:"messageCount"`
+	// MoreAvailable hints that the topic holds messages beyond the received ones, so that clients can pull again right away
+	MoreAvailable bool `json:"moreAvailable"`
+	// DeliverySemantics tells whether the received messages are redelivered unless acknowledged, or never delivered again
+	DeliverySemantics string `json:"deliverySemantics,omitempty"`
 }
 
+// Delivery semantics of pulled messages
+const (
+	// DeliveryAtLeastOnce messages are delivered again if they don't get acknowledged within the ack deadline
+	DeliveryAtLeastOnce = "atLeastOnce"
+	// DeliveryAtMostOnce messages are considered consumed as soon as they are handed out
+	DeliveryAtMostOnce = "atMostOnce"
+)
+
 // MsgList is used to hold a list of messages
 type MsgList struct {
 	Msgs []Message `json:"messages"`
@@ -31,6 +91,10 @@ type Message struct {
 	Attr    Attributes `json:"attributes,omitempty"`  // used to hold attribute key/value store
 	Data    string     `json:"data"`                  // base64 encoded data payload
 	PubTime string     `json:"publishTime,omitempty"` // publish timedate of message
+	// IngestTime is when the service received a message whose publish time was supplied by its producer
+	IngestTime string `json:"ingestTime,omitempty"`
+	// Key identifies the messages that supersede each other on topics whose broker keeps only the latest message per key
+	Key string `json:"key,omitempty"`
 }
 
 // PushMsg contains structure for push messages
@@ -42,6 +106,14 @@ type PushMsg struct {
 // MsgIDs utility struct
 type MsgIDs struct {
 	IDs []string `json:"messageIds"`
+	// Placements are where the broker placed each message, in the order of the ids, when the broker reports it
+	Placements []MsgPlacement `json:"placements,omitempty"`
+}
+
+// MsgPlacement is the partition and offset the broker assigned to a published message
+type MsgPlacement struct {
+	Partition int   `json:"partition"`
+	Offset    int64 `json:"offset"`
 }
 
 // Attributes representation as key/value
@@ -144,6 +216,119 @@ func (msg *Message) GetDecoded() string {
 	return string(decoded[:])
 }
 
+// IsCompressed checks whether the message's data are declared as compressed
+func (msg *Message) IsCompressed() bool {
+	exists, _ := msg.AttrExists(CompressionAttribute)
+	return exists
+}
+
+// Decompress replaces the message's compressed data with their decompressed form
+// and removes the compression attribute. Messages that aren't compressed are left untouched.
+// Data that decompress to more than maxSize bytes are left compressed and ErrDecompressedTooLarge is returned,
+// a maxSize of zero falls back to DefaultMaxMessageSize
+func (msg *Message) Decompress(maxSize int) error {
+
+	if maxSize <= 0 {
+		maxSize = DefaultMaxMessageSize
+	}
+
+	exists, compression := msg.AttrExists(CompressionAttribute)
+	if !exists {
+		return nil
+	}
+
+	if compression != CompressionGzip {
+		return errors.New("Unsupported compression " + compression)
+	}
+
+	compressed, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	// reading a byte past the max size is enough to tell that the data don't fit
+	decompressed, err := ioutil.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+	if err != nil {
+		return err
+	}
+
+	if len(decompressed) > maxSize {
+		return ErrDecompressedTooLarge
+	}
+
+	msg.Data = b64.StdEncoding.EncodeToString(decompressed)
+	delete(msg.Attr, CompressionAttribute)
+
+	return nil
+}
+
+// Priority returns the priority level of the message, messages that don't declare one are of normal priority
+func (msg *Message) Priority() string {
+	exists, priority := msg.AttrExists(PriorityAttribute)
+	if !exists {
+		return PriorityNormal
+	}
+	return priority
+}
+
+// ValidatePriorities checks that every message of the list declares one of the supported priority levels, if any
+func (msgL MsgList) ValidatePriorities() error {
+	for _, msg := range msgL.Msgs {
+		if _, ok := priorityRanks[msg.Priority()]; !ok {
+			return errors.New("Invalid priority " + msg.Priority() + ", it should be one of high, normal or low")
+		}
+	}
+	return nil
+}
+
+// AssignPublishTimes sets the publish time of the messages that don't carry one to the given time.
+// Producer supplied publish times are kept, normalized to UTC, as long as they are not ahead of that time by more than maxSkew,
+// so that historical data can be backfilled with their real event times. Such messages get the given time as their ingest time.
+// A non positive maxSkew falls back to the default one
+func (msgL *MsgList) AssignPublishTimes(now time.Time, maxSkew time.Duration) error {
+
+	if maxSkew <= 0 {
+		maxSkew = DefaultPublishTimeMaxSkew
+	}
+
+	for i, msg := range msgL.Msgs {
+
+		if msg.PubTime == "" {
+			msgL.Msgs[i].PubTime = timestamp.FormatNano(now)
+			msgL.Msgs[i].IngestTime = ""
+			continue
+		}
+
+		pubTime, err := timestamp.Parse(msg.PubTime)
+		if err != nil {
+			return errors.New("Invalid publishTime " + msg.PubTime + ", it should be an RFC3339 timestamp")
+		}
+
+		if pubTime.After(now.Add(maxSkew)) {
+			return errors.New("Invalid publishTime " + msg.PubTime + ", it is further in the future than the allowed skew of " + maxSkew.String())
+		}
+
+		msgL.Msgs[i].PubTime = timestamp.FormatNano(pubTime)
+		msgL.Msgs[i].IngestTime = timestamp.FormatNano(now)
+	}
+
+	return nil
+}
+
+// OrderByPriority moves the higher priority messages ahead of the lower priority ones,
+// keeping the order of the messages of the same priority
+func (msgL *RecList) OrderByPriority() {
+	sort.SliceStable(msgL.RecMsgs, func(i, j int) bool {
+		return priorityRanks[msgL.RecMsgs[i].Msg.Priority()] > priorityRanks[msgL.RecMsgs[j].Msg.Priority()]
+	})
+}
+
 // AttrExists checks if an attribute exists based on key. Returns also a boolean
 // if the attribute exists
 func (msg *Message) AttrExists(key string) (bool, string) {
@@ -1,9 +1,12 @@
 package messages
 
 import (
+	"bytes"
+	"compress/gzip"
 	b64 "encoding/base64"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
@@ -113,6 +116,159 @@ func (suite *MsgTestSuite) TestGetDecodedData() {
 
 }
 
+func (suite *MsgTestSuite) TestDecompress() {
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte("hello world!"))
+	zw.Close()
+
+	// gzip compressed message
+	testMsg := New(b64.StdEncoding.EncodeToString(buf.Bytes()))
+	testMsg.InsertAttribute("compression", "gzip")
+	testMsg.InsertAttribute("foo", "bar")
+	suite.True(testMsg.IsCompressed())
+	suite.Nil(testMsg.Decompress(0))
+	suite.Equal("hello world!", testMsg.GetDecoded())
+	suite.Equal(Attributes{"foo": "bar"}, testMsg.Attr)
+	suite.False(testMsg.IsCompressed())
+
+	// uncompressed message is left untouched
+	plainMsg := New("aGVsbG8gd29ybGQh")
+	suite.Nil(plainMsg.Decompress(0))
+	suite.Equal("aGVsbG8gd29ybGQh", plainMsg.Data)
+
+	// unsupported compression
+	zstdMsg := New("aGVsbG8gd29ybGQh")
+	zstdMsg.InsertAttribute("compression", "zstd")
+	suite.Equal(errors.New("Unsupported compression zstd"), zstdMsg.Decompress(0))
+	suite.Equal("aGVsbG8gd29ybGQh", zstdMsg.Data)
+
+	// data declared as gzip but not compressed
+	badMsg := New("aGVsbG8gd29ybGQh")
+	badMsg.InsertAttribute("compression", "gzip")
+	suite.NotNil(badMsg.Decompress(0))
+	suite.Equal("aGVsbG8gd29ybGQh", badMsg.Data)
+	suite.True(badMsg.IsCompressed())
+
+	// data that decompress beyond the max size, e.g. a decompression bomb, are left compressed
+	buf.Reset()
+	zw = gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte("0"), DefaultMaxMessageSize+1))
+	zw.Close()
+	bombMsg := New(b64.StdEncoding.EncodeToString(buf.Bytes()))
+	bombMsg.InsertAttribute("compression", "gzip")
+	suite.Equal(ErrDecompressedTooLarge, bombMsg.Decompress(0))
+	suite.Equal(b64.StdEncoding.EncodeToString(buf.Bytes()), bombMsg.Data)
+	suite.True(bombMsg.IsCompressed())
+
+	// data that fit the max size exactly are decompressed
+	suite.Nil(bombMsg.Decompress(DefaultMaxMessageSize + 1))
+	suite.False(bombMsg.IsCompressed())
+
+	// as well as data that exceed a lower max size
+	smallMsg := New(b64.StdEncoding.EncodeToString(buf.Bytes()))
+	smallMsg.InsertAttribute("compression", "gzip")
+	suite.Equal(ErrDecompressedTooLarge, smallMsg.Decompress(10))
+}
+
+func (suite *MsgTestSuite) TestPriority() {
+
+	normal := New("YmFzZTY0ZW5jb2RlZA==")
+	suite.Equal(PriorityNormal, normal.Priority())
+
+	high := New("YmFzZTY0ZW5jb2RlZA==")
+	high.InsertAttribute(PriorityAttribute, PriorityHigh)
+	low := New("YmFzZTY0ZW5jb2RlZA==")
+	low.InsertAttribute(PriorityAttribute, PriorityLow)
+	suite.Equal(PriorityHigh, high.Priority())
+
+	suite.Nil(MsgList{Msgs: []Message{normal, high, low}}.ValidatePriorities())
+
+	urgent := New("YmFzZTY0ZW5jb2RlZA==")
+	urgent.InsertAttribute(PriorityAttribute, "urgent")
+	suite.Equal(errors.New("Invalid priority urgent, it should be one of high, normal or low"),
+		MsgList{Msgs: []Message{normal, urgent}}.ValidatePriorities())
+
+	// messages of the same priority keep their order
+	rec := func(id string, msg Message) RecMsg {
+		msg.ID = id
+		return RecMsg{AckID: id, Msg: msg}
+	}
+	recList := RecList{RecMsgs: []RecMsg{rec("0", low), rec("1", normal), rec("2", high), rec("3", normal), rec("4", high)}}
+	recList.OrderByPriority()
+
+	ids := []string{}
+	for _, r := range recList.RecMsgs {
+		ids = append(ids, r.Msg.ID)
+	}
+	suite.Equal([]string{"2", "4", "1", "3", "0"}, ids)
+}
+
+func (suite *MsgTestSuite) TestAssignPublishTimes() {
+
+	now := time.Date(2019, 5, 6, 10, 0, 0, 0, time.UTC)
+
+	msgList := MsgList{Msgs: []Message{
+		{Data: "YmFzZTY0ZW5jb2RlZA=="},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2018-01-01T12:00:00.5+02:00"},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2019-05-06T10:01:00Z"},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", IngestTime: "2000-01-01T00:00:00Z"},
+	}}
+	suite.Nil(msgList.AssignPublishTimes(now, time.Minute))
+	suite.Equal([]Message{
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2019-05-06T10:00:00Z"},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2018-01-01T10:00:00.5Z", IngestTime: "2019-05-06T10:00:00Z"},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2019-05-06T10:01:00Z", IngestTime: "2019-05-06T10:00:00Z"},
+		{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2019-05-06T10:00:00Z"},
+	}, msgList.Msgs)
+
+	future := MsgList{Msgs: []Message{{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "2019-05-06T10:01:00.1Z"}}}
+	suite.Equal(errors.New("Invalid publishTime 2019-05-06T10:01:00.1Z, it is further in the future than the allowed skew of 1m0s"),
+		future.AssignPublishTimes(now, 0))
+
+	invalid := MsgList{Msgs: []Message{{Data: "YmFzZTY0ZW5jb2RlZA==", PubTime: "06/05/2019"}}}
+	suite.Equal(errors.New("Invalid publishTime 06/05/2019, it should be an RFC3339 timestamp"),
+		invalid.AssignPublishTimes(now, time.Minute))
+}
+
+func (suite *MsgTestSuite) TestValidatePayload() {
+
+	encode := func(data string) Message {
+		return New(b64.StdEncoding.EncodeToString([]byte(data)))
+	}
+	limits := PayloadLimits{MaxDepth: 3, MaxFields: 4}
+
+	suite.Nil(encode(`{"a": {"b": [1, 2]}}`).ValidatePayload(limits))
+	suite.Nil(encode(`{"a": 1, "b": {"c": 2}, "d": []}`).ValidatePayload(limits))
+
+	msg := encode(`{"a": {"b": [[1]]}}`)
+	suite.Equal("Message payload is nested deeper than 3 levels", msg.ValidatePayload(limits).Error())
+
+	msg = encode(`[1, 2, 3, {"a": 1}]`)
+	suite.Equal("Message payload has more than 4 fields", msg.ValidatePayload(limits).Error())
+	msg = encode(`{"a": 1, "b": 2, "c": {"d": 3, "e": 4}}`)
+	suite.Equal("Message payload has more than 4 fields", msg.ValidatePayload(limits).Error())
+
+	// the payload is rejected as soon as it exceeds a limit, even if it isn't valid json further on
+	msg = encode(`[[[[[ not json`)
+	suite.NotNil(msg.ValidatePayload(limits))
+
+	// raw bytes, json scalars and compressed payloads are left unchecked
+	suite.Nil(encode(`raw bytes [[[[[`).ValidatePayload(limits))
+	suite.Nil(encode(`"[[[[["`).ValidatePayload(limits))
+	suite.Nil(encode(`{"a": not json`).ValidatePayload(limits))
+	compressed := encode(`[[[[[1]]]]]`)
+	compressed.InsertAttribute(CompressionAttribute, CompressionGzip)
+	suite.Nil(compressed.ValidatePayload(limits))
+
+	// without limits nothing is checked
+	suite.Nil(encode(`[[[[[1]]]]]`).ValidatePayload(PayloadLimits{}))
+
+	msgList := MsgList{Msgs: []Message{encode(`{"a": 1}`), encode(`[[[[1]]]]`)}}
+	suite.Equal("Message payload is nested deeper than 3 levels", msgList.ValidatePayloads(limits).Error())
+}
+
 func TestMsgTestSuite(t *testing.T) {
 	suite.Run(t, new(MsgTestSuite))
 }
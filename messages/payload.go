@@ -0,0 +1,102 @@
+package messages
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PayloadLimits bounds the structure of the json payloads that get published, so that pathological
+// payloads don't reach the consumers. A non positive limit leaves that aspect of the payloads unchecked
+type PayloadLimits struct {
+	// MaxDepth is the deepest nesting of objects and arrays a payload may have
+	MaxDepth int
+	// MaxFields is the total number of object fields and array elements a payload may have
+	MaxFields int
+}
+
+// IsEmpty returns true if the limits don't check anything
+func (pl PayloadLimits) IsEmpty() bool {
+	return pl.MaxDepth <= 0 && pl.MaxFields <= 0
+}
+
+// ValidatePayload checks the message's payload against the limits. Only payloads that are json objects or arrays
+// are checked, raw bytes payloads, json scalars and compressed payloads are left unchecked.
+// The payload is scanned as a stream, so that a payload is rejected as soon as it exceeds a limit
+func (msg Message) ValidatePayload(limits PayloadLimits) error {
+
+	if limits.IsEmpty() || msg.IsCompressed() {
+		return nil
+	}
+
+	data, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return nil
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	depth := 0
+	fields := 0
+	// objects yield their keys and values as separate tokens, so only every other token inside them is a field
+	inObject := []bool{}
+	expectKey := false
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		// payloads that turn out not to be json are raw bytes as far as the limits are concerned
+		if err != nil {
+			return nil
+		}
+
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			depth--
+			inObject = inObject[:len(inObject)-1]
+			expectKey = len(inObject) > 0 && inObject[len(inObject)-1]
+			continue
+		}
+
+		if len(inObject) > 0 {
+			if inObject[len(inObject)-1] {
+				// the key of an object field counts as the field, its value doesn't
+				if expectKey {
+					fields++
+				}
+				expectKey = !expectKey
+			} else {
+				fields++
+			}
+			if limits.MaxFields > 0 && fields > limits.MaxFields {
+				return fmt.Errorf("Message payload has more than %v fields", limits.MaxFields)
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			depth++
+			if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+				return fmt.Errorf("Message payload is nested deeper than %v levels", limits.MaxDepth)
+			}
+			inObject = append(inObject, delim == '{')
+			expectKey = delim == '{'
+		}
+	}
+}
+
+// ValidatePayloads checks the payload of every message of the list against the limits
+func (msgL MsgList) ValidatePayloads(limits PayloadLimits) error {
+	for _, msg := range msgL.Msgs {
+		if err := msg.ValidatePayload(limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,6 +3,8 @@ package metrics
 import (
 	"encoding/json"
 	"time"
+
+	"github.com/ARGOeu/argo-messaging/timestamp"
 )
 
 // Metric names and descriptions
@@ -25,6 +27,10 @@ const (
 	NameDailyTopicMsgs    = "topic.number_of_daily_messages"
 	DescTopicBytes        = "Counter that displays the total size of data (in bytes) published to the specific topic"
 	NameTopicBytes        = "topic.number_of_bytes"
+	DescTopicMsgsPerSec   = "A rate that displays how many messages were published per second to the specific topic during the window"
+	NameTopicMsgsPerSec   = "topic.messages_per_second"
+	DescTopicBytesPerSec  = "A rate that displays how many bytes were published per second to the specific topic during the window"
+	NameTopicBytesPerSec  = "topic.bytes_per_second"
 	DescProjectUserSubs   = "Counter that displays the number of subscriptions that a user has access to the specific project"
 	NameProjectUserSubs   = "project.user.number_of_subscriptions"
 	DescProjectUserTopics = "Counter that displays the number of topics that a user has access to the specific project"
@@ -33,10 +39,18 @@ const (
 	NameSubMsgs           = "subscription.number_of_messages"
 	DescSubBytes          = "Counter that displays the total size of data (in bytes) consumed from the specific subscription"
 	NameSubBytes          = "subscription.number_of_bytes"
+	DescSubAckedMsgs      = "Counter that displays the number of messages acknowledged through the specific subscription since its last reset"
+	NameSubAckedMsgs      = "subscription.number_of_acked_messages"
 	DescOpNodeCPU         = "Percentage value that displays the CPU usage of ams service in the specific node"
 	NameOpNodeCPU         = "ams_node.cpu_usage"
 	DescOpNodeMEM         = "Percentage value that displays the Memory usage of ams service in the specific node"
 	NameOpNodeMEM         = "ams_node.memory_usage"
+	DescOpPoolActive      = "Counter that displays the number of pooled broker connections in use by the ams service in the specific node"
+	NameOpPoolActive      = "ams_node.broker_pool.active"
+	DescOpPoolIdle        = "Counter that displays the number of pooled broker connections waiting to be reused by the ams service in the specific node"
+	NameOpPoolIdle        = "ams_node.broker_pool.idle"
+	DescOpPoolWaitTime    = "Counter that displays the total time (in milliseconds) requests of the ams service in the specific node waited for a pooled broker connection"
+	NameOpPoolWaitTime    = "ams_node.broker_pool.wait_time"
 )
 
 type MetricList struct {
@@ -151,6 +165,14 @@ func NewSubBytes(topic string, value int64, tstamp string) Metric {
 	return m
 }
 
+func NewSubAckedMsgs(topic string, value int64, tstamp string) Metric {
+	// Initialize single point timeseries with the latest timestamp and value
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameSubAckedMsgs, MetricType: "counter", ValueType: "int64", ResourceType: "subscription", Resource: topic, Timeseries: ts, Description: DescSubAckedMsgs}
+
+	return m
+}
+
 func NewTopicMsgs(topic string, value int64, tstamp string) Metric {
 	// Initialize single point timeseries with the latest timestamp and value
 	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
@@ -171,6 +193,22 @@ func NewTopicBytes(topic string, value int64, tstamp string) Metric {
 	return m
 }
 
+// NewTopicMsgsPerSec creates the messages per second rate of a topic over a window, e.g. topic.messages_per_second.5m.
+// A nil value means that there weren't enough data to compute the rate
+func NewTopicMsgsPerSec(topic string, window string, value *float64, tstamp string) Metric {
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameTopicMsgsPerSec + "." + window, MetricType: "rate", ValueType: "float64", ResourceType: "topic", Resource: topic, Timeseries: ts, Description: DescTopicMsgsPerSec}
+	return m
+}
+
+// NewTopicBytesPerSec creates the bytes per second rate of a topic over a window, e.g. topic.bytes_per_second.5m.
+// A nil value means that there weren't enough data to compute the rate
+func NewTopicBytesPerSec(topic string, window string, value *float64, tstamp string) Metric {
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameTopicBytesPerSec + "." + window, MetricType: "rate", ValueType: "float64", ResourceType: "topic", Resource: topic, Timeseries: ts, Description: DescTopicBytesPerSec}
+	return m
+}
+
 func NewProjectUserSubs(project string, user string, value int64, tstamp string) Metric {
 	// Initialize single point timeseries with the latest timestamp and value
 	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
@@ -202,6 +240,30 @@ func NewOpNodeMEM(hostname string, value float64, tstamp string) Metric {
 	return m
 }
 
+// NewOpPoolActive creates a metric of the broker connections a node uses
+func NewOpPoolActive(hostname string, value int64, tstamp string) Metric {
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameOpPoolActive, MetricType: "counter", ValueType: "int64", ResourceType: "ams_node", Resource: hostname, Timeseries: ts, Description: DescOpPoolActive}
+
+	return m
+}
+
+// NewOpPoolIdle creates a metric of the broker connections a node keeps idle
+func NewOpPoolIdle(hostname string, value int64, tstamp string) Metric {
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameOpPoolIdle, MetricType: "counter", ValueType: "int64", ResourceType: "ams_node", Resource: hostname, Timeseries: ts, Description: DescOpPoolIdle}
+
+	return m
+}
+
+// NewOpPoolWaitTime creates a metric of the time a node's requests waited for broker connections
+func NewOpPoolWaitTime(hostname string, value int64, tstamp string) Metric {
+	ts := []Timepoint{Timepoint{Timestamp: tstamp, Value: value}}
+	m := Metric{Metric: NameOpPoolWaitTime, MetricType: "counter", ValueType: "int64", ResourceType: "ams_node", Resource: hostname, Timeseries: ts, Description: DescOpPoolWaitTime}
+
+	return m
+}
+
 // GetUserFromJSON retrieves User info From JSON string
 func GetMetricsFromJSON(input []byte) (MetricList, error) {
 	ml := MetricList{}
@@ -210,8 +272,5 @@ func GetMetricsFromJSON(input []byte) (MetricList, error) {
 }
 
 func GetTimeNowZulu() string {
-	zSec := "2006-01-02T15:04:05Z"
-	t := time.Now().UTC()
-	ts := t.Format(zSec)
-	return ts
+	return timestamp.Format(time.Now())
 }
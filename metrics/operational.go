@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/stores"
 	log "github.com/sirupsen/logrus"
 )
@@ -57,3 +58,20 @@ func GetUsageCpuMem(store stores.Store) (MetricList, error) {
 
 	return ml, err
 }
+
+// GetBrokerPoolUsage reports the utilization of the broker pool of the node
+func GetBrokerPoolUsage(stats brokers.PoolStats) MetricList {
+
+	host, err := os.Hostname()
+	if err != nil {
+		log.Error(err)
+	}
+
+	tstamp := GetTimeNowZulu()
+
+	return MetricList{Metrics: []Metric{
+		NewOpPoolActive(host, int64(stats.Active), tstamp),
+		NewOpPoolIdle(host, int64(stats.Idle), tstamp),
+		NewOpPoolWaitTime(host, stats.WaitTime.Milliseconds(), tstamp),
+	}}
+}
@@ -9,8 +9,7 @@ import (
 )
 
 func GetProjectTopics(projectUUID string, store stores.Store) (int64, error) {
-	topics, _, _, err := store.QueryTopics(projectUUID, "", "", "", 0)
-	return int64(len(topics)), err
+	return store.CountTopicsByProject(projectUUID)
 }
 
 func GetProjectSubsByTopic(projectUUID string, topic string, store stores.Store) (int64, error) {
@@ -24,8 +23,7 @@ func GetProjectTopicsACL(projectUUID string, username string, store stores.Store
 }
 
 func GetProjectSubs(projectUUID string, store stores.Store) (int64, error) {
-	subs, _, _, err := store.QuerySubs(projectUUID, "", "", "", 0)
-	return int64(len(subs)), err
+	return store.CountSubsByProject(projectUUID)
 }
 
 func GetProjectSubsACL(projectUUID string, username string, store stores.Store) (int64, error) {
@@ -0,0 +1,284 @@
+package naming
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const (
+	// Default keeps the field names as they are declared by the api resources
+	Default = "default"
+	// SnakeCase names the fields like created_on
+	SnakeCase = "snake_case"
+	// CamelCase names the fields like createdOn
+	CamelCase = "camel_case"
+)
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// Valid returns whether or not the convention is supported, an empty convention being the default one
+func Valid(convention string) bool {
+	switch convention {
+	case "", Default, SnakeCase, CamelCase:
+		return true
+	}
+	return false
+}
+
+// Convert names the field after the convention
+func Convert(name string, convention string) string {
+	switch convention {
+	case SnakeCase:
+		return toSnakeCase(name)
+	case CamelCase:
+		return toCamelCase(name)
+	}
+	return name
+}
+
+// toSnakeCase lowers the words of the name and joins them with underscores,
+// treating a run of capitals as a single word, e.g. ackIDs becomes ack_ids and HTTPServer http_server
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && runes[i-1] != '_' {
+			prev := runes[i-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && startsWord(runes, i)) {
+				b.WriteRune('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// startsWord returns whether the capital at i, following another capital, starts a new word
+// instead of ending a run of capitals, as in the plural of IDs
+func startsWord(runes []rune, i int) bool {
+	if i+1 >= len(runes) || !unicode.IsLower(runes[i+1]) {
+		return false
+	}
+	plural := runes[i+1] == 's' && (i+2 == len(runes) || !unicode.IsLower(runes[i+2]))
+	return !plural
+}
+
+// toCamelCase drops the underscores of the name, capitalizing the words that follow them
+// and lowering the first letter
+func toCamelCase(name string) string {
+	var b strings.Builder
+	upper := false
+	for i, r := range name {
+		switch {
+		case r == '_':
+			upper = b.Len() > 0
+		case i == 0:
+			b.WriteRune(unicode.ToLower(r))
+		case upper:
+			b.WriteRune(unicode.ToUpper(r))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// renameFunc maps the key of a struct field found in the json input to the key written to the output,
+// returning false if the key isn't one of the fields
+type renameFunc func(key string, fields map[string]reflect.Type) (string, reflect.Type, bool)
+
+// Export renames the fields of data, the json encoding of v, after the convention.
+// Only the keys that stem from struct fields are renamed, the keys of maps such as
+// the attributes of a message or the labels of a subscription are kept as they are
+func Export(data []byte, v interface{}, convention string) ([]byte, error) {
+
+	if convention == "" || convention == Default {
+		return data, nil
+	}
+
+	return rewrite(data, reflect.TypeOf(v), func(key string, fields map[string]reflect.Type) (string, reflect.Type, bool) {
+		t, ok := fields[key]
+		return Convert(key, convention), t, ok
+	})
+}
+
+// Import renames the fields of data, named after the convention, back to the ones declared by v,
+// so that the input can be decoded into v. Fields already named as declared are kept as they are
+func Import(data []byte, v interface{}, convention string) ([]byte, error) {
+
+	if convention == "" || convention == Default {
+		return data, nil
+	}
+
+	return rewrite(data, reflect.TypeOf(v), func(key string, fields map[string]reflect.Type) (string, reflect.Type, bool) {
+		if t, ok := fields[key]; ok {
+			return key, t, true
+		}
+		for name, t := range fields {
+			if Convert(name, convention) == key {
+				return name, t, true
+			}
+		}
+		return key, nil, false
+	})
+}
+
+// rewrite re-encodes data walking it along with type t, renaming the struct fields it meets,
+// while preserving the order of the fields and the indentation of the api responses
+func rewrite(data []byte, t reflect.Type, rename renameFunc) ([]byte, error) {
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	buf := bytes.Buffer{}
+	if err := rewriteValue(dec, &buf, t, rename); err != nil {
+		return nil, err
+	}
+
+	out := bytes.Buffer{}
+	if err := json.Indent(&out, buf.Bytes(), "", "   "); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func rewriteValue(dec *json.Decoder, buf *bytes.Buffer, t reflect.Type, rename renameFunc) error {
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	t = resolve(t)
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		out, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = structFields(t)
+		}
+
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, ok := keyTok.(string)
+			if !ok {
+				return errors.New("invalid object key")
+			}
+
+			var valueType reflect.Type
+			if fields != nil {
+				if renamed, ft, found := rename(key, fields); found {
+					key, valueType = renamed, ft
+				}
+			} else if t != nil && t.Kind() == reflect.Map {
+				valueType = t.Elem()
+			}
+
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			out, _ := json.Marshal(key)
+			buf.Write(out)
+			buf.WriteByte(':')
+
+			if err := rewriteValue(dec, buf, valueType, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+
+	case '[':
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := rewriteValue(dec, buf, elemType, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	// consume the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// resolve dereferences t, returning nil for the types whose json encoding is
+// not derived from their fields, whose keys are left untouched
+func resolve(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		return nil
+	}
+	return t
+}
+
+// structFields maps the json keys of the struct's fields to their types, following the
+// rules of encoding/json for tags and for the fields of embedded structs
+func structFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for k, v := range structFields(ft) {
+					if _, exists := fields[k]; !exists {
+						fields[k] = v
+					}
+				}
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
@@ -0,0 +1,151 @@
+package naming
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type NamingTestSuite struct {
+	suite.Suite
+}
+
+type testPushConfig struct {
+	PushEndpoint string `json:"pushEndpoint"`
+	MaxMessages  int64  `json:"maxMessages"`
+}
+
+type testBase struct {
+	Name string `json:"name"`
+}
+
+type testResource struct {
+	testBase
+	PushCfg   testPushConfig    `json:"pushConfig"`
+	Ack       int               `json:"ackDeadlineSeconds,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Items     []testPushConfig  `json:"items,omitempty"`
+	CreatedOn string            `json:"created_on"`
+	PullTime  time.Time         `json:"pull_time"`
+	Extra     interface{}       `json:"extra,omitempty"`
+	AckIDs    []string
+	internal  string
+}
+
+func (suite *NamingTestSuite) TestValid() {
+
+	suite.True(Valid(""))
+	suite.True(Valid(Default))
+	suite.True(Valid(SnakeCase))
+	suite.True(Valid(CamelCase))
+	suite.False(Valid("kebab-case"))
+}
+
+func (suite *NamingTestSuite) TestConvert() {
+
+	suite.Equal("ack_deadline_seconds", Convert("ackDeadlineSeconds", SnakeCase))
+	suite.Equal("created_on", Convert("created_on", SnakeCase))
+	suite.Equal("ack_ids", Convert("AckIds", SnakeCase))
+	suite.Equal("ack_ids", Convert("AckIDs", SnakeCase))
+	suite.Equal("max_offset2", Convert("maxOffset2", SnakeCase))
+	suite.Equal("http_server", Convert("HTTPServer", SnakeCase))
+
+	suite.Equal("ackDeadlineSeconds", Convert("ackDeadlineSeconds", CamelCase))
+	suite.Equal("createdOn", Convert("created_on", CamelCase))
+	suite.Equal("ackIds", Convert("AckIds", CamelCase))
+	suite.Equal("authorizationHeader", Convert("authorization_header", CamelCase))
+
+	suite.Equal("created_on", Convert("created_on", Default))
+}
+
+func (suite *NamingTestSuite) TestExportImport() {
+
+	res := testResource{
+		testBase:  testBase{Name: "sub1"},
+		PushCfg:   testPushConfig{PushEndpoint: "https://example.com", MaxMessages: 1},
+		Ack:       10,
+		Labels:    map[string]string{"team_name": "alerts"},
+		Items:     []testPushConfig{{MaxMessages: 2}},
+		CreatedOn: "2020-11-19T00:00:00Z",
+		PullTime:  time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC),
+		Extra:     map[string]string{"someKey": "v"},
+		AckIDs:    []string{"a<b"},
+	}
+
+	data, _ := json.MarshalIndent(res, "", "   ")
+
+	// the default convention keeps the output as is
+	out, err := Export(data, res, Default)
+	suite.Nil(err)
+	suite.Equal(data, out)
+
+	expSnake := `{
+   "name": "sub1",
+   "push_config": {
+      "push_endpoint": "https://example.com",
+      "max_messages": 1
+   },
+   "ack_deadline_seconds": 10,
+   "labels": {
+      "team_name": "alerts"
+   },
+   "items": [
+      {
+         "push_endpoint": "",
+         "max_messages": 2
+      }
+   ],
+   "created_on": "2020-11-19T00:00:00Z",
+   "pull_time": "2020-11-19T00:00:00Z",
+   "extra": {
+      "someKey": "v"
+   },
+   "ack_ids": [
+      "a\u003cb"
+   ]
+}`
+	out, err = Export(data, &res, SnakeCase)
+	suite.Nil(err)
+	suite.Equal(expSnake, string(out))
+
+	// parsing the exported output and exporting it again gives the same output
+	in, err := Import(out, &res, SnakeCase)
+	suite.Nil(err)
+	suite.Equal(string(data), string(in))
+
+	parsed := testResource{}
+	suite.Nil(json.Unmarshal(in, &parsed))
+	suite.Equal(res.PushCfg, parsed.PushCfg)
+	suite.Equal(res.Labels, parsed.Labels)
+	suite.Equal(res.AckIDs, parsed.AckIDs)
+
+	reExported, _ := json.MarshalIndent(parsed, "", "   ")
+	out2, _ := Export(reExported, parsed, SnakeCase)
+	suite.Equal(expSnake, string(out2))
+
+	out, err = Export(data, res, CamelCase)
+	suite.Nil(err)
+	suite.Contains(string(out), `"createdOn": "2020-11-19T00:00:00Z"`)
+	suite.Contains(string(out), `"team_name": "alerts"`)
+	in, err = Import(out, res, CamelCase)
+	suite.Nil(err)
+	suite.Equal(string(data), string(in))
+
+	// input already named as declared is accepted as well
+	in, err = Import([]byte(`{"pushConfig":{"max_messages":3}}`), res, SnakeCase)
+	suite.Nil(err)
+	suite.Equal(`{
+   "pushConfig": {
+      "maxMessages": 3
+   }
+}`, string(in))
+
+	_, err = Import([]byte(`{"name":`), res, SnakeCase)
+	suite.NotNil(err)
+}
+
+func TestNamingTestSuite(t *testing.T) {
+	suite.Run(t, new(NamingTestSuite))
+}
@@ -0,0 +1,115 @@
+package projects
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// PatternACL lists the users that are granted access to the project's topics and subscriptions whose names match a regular expression.
+// Pattern grants add to the acls of the resources, they never take access away
+type PatternACL struct {
+	Topics        []PatternGrant `json:"topics"`
+	Subscriptions []PatternGrant `json:"subscriptions"`
+}
+
+// PatternGrant grants its users access to the resources whose names match the pattern
+type PatternGrant struct {
+	Pattern   string   `json:"pattern"`
+	AuthUsers []string `json:"authorized_users"`
+}
+
+// resolveGrants compiles the patterns of the grants and maps their users to uuids,
+// collecting the users that don't exist. Grants without any users are dropped
+func resolveGrants(grants []PatternGrant, missing []string, store stores.Store) ([]stores.QPatternGrant, []string, error) {
+
+	qGrants := []stores.QPatternGrant{}
+	for _, grant := range grants {
+
+		if grant.Pattern == "" {
+			return nil, missing, errors.New("invalid pattern, it should not be empty")
+		}
+
+		if _, err := regexp.Compile(grant.Pattern); err != nil {
+			return nil, missing, errors.New("invalid pattern " + grant.Pattern + ", " + err.Error())
+		}
+
+		users, missingUsers := userUUIDs(grant.AuthUsers, store)
+		for _, name := range missingUsers {
+			if !contains(missing, name) {
+				missing = append(missing, name)
+			}
+		}
+
+		if len(users) > 0 {
+			qGrants = append(qGrants, stores.QPatternGrant{Pattern: grant.Pattern, Users: users})
+		}
+	}
+
+	return qGrants, missing, nil
+}
+
+// resolve compiles the patterns of the acl and maps its users to their uuids, failing if any pattern
+// is not a valid regular expression or any user doesn't exist. An acl without any users resolves to none
+func (acl *PatternACL) resolve(store stores.Store) (*stores.QPatternACL, error) {
+
+	topicGrants, missing, err := resolveGrants(acl.Topics, []string{}, store)
+	if err != nil {
+		return nil, err
+	}
+
+	subGrants, missing, err := resolveGrants(acl.Subscriptions, missing, store)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missing) > 0 {
+		return nil, errors.New("User(s): " + strings.Join(missing, ", ") + " do not exist")
+	}
+
+	if len(topicGrants) == 0 && len(subGrants) == 0 {
+		return nil, nil
+	}
+
+	return &stores.QPatternACL{Topics: topicGrants, Subscriptions: subGrants}, nil
+}
+
+// Validate checks that the patterns of the acl are valid regular expressions and that its users exist
+func (acl *PatternACL) Validate(store stores.Store) error {
+	_, err := acl.resolve(store)
+	return err
+}
+
+// patternGrants resolves the names of the users of the stored grants, skipping the users that no longer exist
+func patternGrants(qGrants []stores.QPatternGrant, store stores.Store) []PatternGrant {
+	grants := []PatternGrant{}
+	for _, grant := range qGrants {
+		grants = append(grants, PatternGrant{Pattern: grant.Pattern, AuthUsers: usernames(grant.Users, store)})
+	}
+	return grants
+}
+
+// UpdatePatternACL replaces the pattern grants of the project's topics and subscriptions.
+// A pattern acl without any users is removed
+func UpdatePatternACL(uuid string, acl PatternACL, modifiedOn time.Time, store stores.Store) (Project, error) {
+
+	if ExistsWithUUID(uuid, store) == false {
+		return Project{}, errors.New("not found")
+	}
+
+	qACL, err := acl.resolve(store)
+	if err != nil {
+		return Project{}, err
+	}
+
+	if err := store.UpdateProjectPatternACL(uuid, qACL, modifiedOn); err != nil {
+		return Project{}, err
+	}
+
+	// reflect stored object
+	stored, err := Find(uuid, "", store)
+	return stored.One(), err
+}
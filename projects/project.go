@@ -3,10 +3,12 @@ package projects
 import (
 	"encoding/json"
 	"errors"
+	"strings"
 
 	"time"
 
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 )
 
 // ProjectUUID is the struct that holds ProjectUUID information
@@ -17,11 +19,22 @@ type Project struct {
 	ModifiedOn  string `json:"modified_on,omitempty"`
 	CreatedBy   string `json:"created_by,omitempty"`
 	Description string `json:"description,omitempty"`
+	// DefaultACL is granted to every new topic and subscription of the project
+	DefaultACL *DefaultACL `json:"default_acl,omitempty"`
+	// PatternACL grants access to the topics and subscriptions whose names match a regular expression
+	PatternACL *PatternACL `json:"pattern_acl,omitempty"`
+}
+
+// DefaultACL lists the users that are granted access to every new topic and subscription of a project.
+// The acls of the resources can still be modified once they are created
+type DefaultACL struct {
+	Topics        []string `json:"topics"`
+	Subscriptions []string `json:"subscriptions"`
 }
 
 // Projects holds a list of available projects
 type Projects struct {
-	List []Project `json:"projects,omitempty"`
+	List []Project `json:"projects"`
 }
 
 // ExportJSON exports ProjectUUID to json format
@@ -61,14 +74,13 @@ func GetFromJSON(input []byte) (Project, error) {
 
 // NewProject accepts parameters and creates a new project
 func NewProject(uuid string, name string, createdOn time.Time, modifiedOn time.Time, createdBy string, description string) Project {
-	zuluForm := "2006-01-02T15:04:05Z"
-	return Project{UUID: uuid, Name: name, CreatedOn: createdOn.Format(zuluForm), ModifiedOn: modifiedOn.Format(zuluForm), CreatedBy: createdBy, Description: description}
+	return Project{UUID: uuid, Name: name, CreatedOn: timestamp.Format(createdOn), ModifiedOn: timestamp.Format(modifiedOn), CreatedBy: createdBy, Description: description}
 }
 
 // Find returns a specific project or a list of all available projects in the datastore.
 // To return all projects use an empty project string parameter
 func Find(uuid string, name string, store stores.Store) (Projects, error) {
-	result := Projects{}
+	result := Projects{List: []Project{}}
 	// if project string empty, returns all projects
 	projects, err := store.QueryProjects(uuid, name)
 
@@ -82,12 +94,118 @@ func Find(uuid string, name string, store stores.Store) (Projects, error) {
 			}
 		}
 		curProject := NewProject(item.UUID, item.Name, item.CreatedOn.UTC(), item.ModifiedOn.UTC(), username, item.Description)
+		if item.DefaultACL != nil {
+			curProject.DefaultACL = &DefaultACL{
+				Topics:        usernames(item.DefaultACL.Topics, store),
+				Subscriptions: usernames(item.DefaultACL.Subscriptions, store),
+			}
+		}
+		if item.PatternACL != nil {
+			curProject.PatternACL = &PatternACL{
+				Topics:        patternGrants(item.PatternACL.Topics, store),
+				Subscriptions: patternGrants(item.PatternACL.Subscriptions, store),
+			}
+		}
 		result.List = append(result.List, curProject)
 	}
 
 	return result, err
 }
 
+// usernames resolves the names of the users with the given uuids, skipping the users that no longer exist
+func usernames(userUUIDs []string, store stores.Store) []string {
+	names := []string{}
+	for _, userUUID := range userUUIDs {
+		usr, err := store.QueryUsers("", userUUID, "")
+		if err == nil && len(usr) > 0 {
+			names = append(names, usr[0].Name)
+		}
+	}
+	return names
+}
+
+// userUUIDs resolves the uuids of the users with the given names, returning the names that don't match any user
+func userUUIDs(names []string, store stores.Store) ([]string, []string) {
+	uuids := []string{}
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		usr, err := store.QueryUsers("", "", name)
+		if err != nil || len(usr) == 0 {
+			missing = append(missing, name)
+			continue
+		}
+		uuids = append(uuids, usr[0].UUID)
+	}
+	return uuids, missing
+}
+
+// resolve maps the users of the default acl to their uuids, failing if any of them doesn't exist.
+// A default acl without any users resolves to none
+func (acl *DefaultACL) resolve(store stores.Store) (*stores.QDefaultACL, error) {
+
+	topicUsers, missing := userUUIDs(acl.Topics, store)
+	subUsers, missingSubs := userUUIDs(acl.Subscriptions, store)
+
+	for _, name := range missingSubs {
+		if !contains(missing, name) {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, errors.New("User(s): " + strings.Join(missing, ", ") + " do not exist")
+	}
+
+	if len(topicUsers) == 0 && len(subUsers) == 0 {
+		return nil, nil
+	}
+
+	return &stores.QDefaultACL{Topics: topicUsers, Subscriptions: subUsers}, nil
+}
+
+// Validate checks that the users of the default acl exist
+func (acl *DefaultACL) Validate(store stores.Store) error {
+	_, err := acl.resolve(store)
+	return err
+}
+
+// contains returns true if the name is in the list
+func contains(list []string, name string) bool {
+	for _, item := range list {
+		if item == name {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateDefaultACL replaces the acl that is granted to every new topic and subscription of the project.
+// A default acl without any users is removed
+func UpdateDefaultACL(uuid string, acl DefaultACL, modifiedOn time.Time, store stores.Store) (Project, error) {
+
+	if ExistsWithUUID(uuid, store) == false {
+		return Project{}, errors.New("not found")
+	}
+
+	qACL, err := acl.resolve(store)
+	if err != nil {
+		return Project{}, err
+	}
+
+	if err := store.UpdateProjectDefaultACL(uuid, qACL, modifiedOn); err != nil {
+		return Project{}, err
+	}
+
+	// reflect stored object
+	stored, err := Find(uuid, "", store)
+	return stored.One(), err
+}
+
 // GetNameByUUID queries projects by UUID and returns the project name. If not found, returns an empty string
 func GetNameByUUID(uuid string, store stores.Store) string {
 	result := ""
@@ -22,7 +22,7 @@ func (suite *ProjectsTestSuite) TestProjects() {
 	ep1 := Projects{List: []Project{item1}}
 	ep2 := Projects{List: []Project{item2}}
 	ep3 := Projects{List: []Project{item1, item2}}
-	ep4 := Projects{}
+	ep4 := Projects{List: []Project{}}
 
 	p1, err := Find("", "ARGO", store)
 	suite.Equal(ep1, p1)
@@ -171,16 +171,93 @@ func (suite *ProjectsTestSuite) TestProjects() {
 	// Test removing project
 	RemoveProject("argo_uuid", store)
 	pRemoved, err := Find("argo_uuid", "", store)
-	suite.Equal(Projects{}, pRemoved)
+	suite.Equal(Projects{List: []Project{}}, pRemoved)
 	suite.Equal(errors.New("not found"), err)
 	// Check to see that also projects topics and subscriptions have been removed from the store
 
-	resTop, _, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0)
+	resTop, _, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
 	suite.Equal(0, len(resTop))
-	resSub, _, _, _ := store.QuerySubs("argo_uuid", "", "", "", 0)
+	resSub, _, _, _ := store.QuerySubs("argo_uuid", "", "", "", 0, nil, "")
 	suite.Equal(0, len(resSub))
 }
 
+func (suite *ProjectsTestSuite) TestDefaultACL() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	// set a default acl using usernames
+	p, err := UpdateDefaultACL("argo_uuid", DefaultACL{Topics: []string{"UserA", "UserA"}, Subscriptions: []string{"UserB"}}, tm, store)
+	suite.Nil(err)
+	suite.Equal(&DefaultACL{Topics: []string{"UserA"}, Subscriptions: []string{"UserB"}}, p.DefaultACL)
+
+	// the store holds the user uuids
+	qp, _ := store.QueryProjects("argo_uuid", "")
+	suite.Equal(&stores.QDefaultACL{Topics: []string{"uuid1"}, Subscriptions: []string{"uuid2"}}, qp[0].DefaultACL)
+
+	// unknown users
+	_, err = UpdateDefaultACL("argo_uuid", DefaultACL{Topics: []string{"foo", "UserA", "bar"}}, tm, store)
+	suite.Equal(errors.New("User(s): foo, bar do not exist"), err)
+
+	// unknown project
+	_, err = UpdateDefaultACL("unknown", DefaultACL{Topics: []string{"UserA"}}, tm, store)
+	suite.Equal(errors.New("not found"), err)
+
+	// empty lists remove the default acl
+	p, err = UpdateDefaultACL("argo_uuid", DefaultACL{}, tm, store)
+	suite.Nil(err)
+	suite.Nil(p.DefaultACL)
+}
+
+func (suite *ProjectsTestSuite) TestPatternACL() {
+
+	store := stores.NewMockStore("mockhost", "mockbase")
+	tm := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
+
+	p, err := UpdatePatternACL("argo_uuid", PatternACL{
+		Topics: []PatternGrant{
+			{Pattern: "^team-a-.*$", AuthUsers: []string{"UserA", "UserA"}},
+			{Pattern: "^team-b-", AuthUsers: []string{}},
+		},
+		Subscriptions: []PatternGrant{{Pattern: "^team-a-", AuthUsers: []string{"UserB"}}},
+	}, tm, store)
+	suite.Nil(err)
+	// grants without users are dropped
+	suite.Equal(&PatternACL{
+		Topics:        []PatternGrant{{Pattern: "^team-a-.*$", AuthUsers: []string{"UserA"}}},
+		Subscriptions: []PatternGrant{{Pattern: "^team-a-", AuthUsers: []string{"UserB"}}},
+	}, p.PatternACL)
+
+	// the store holds the user uuids
+	qp, _ := store.QueryProjects("argo_uuid", "")
+	suite.Equal(&stores.QPatternACL{
+		Topics:        []stores.QPatternGrant{{Pattern: "^team-a-.*$", Users: []string{"uuid1"}}},
+		Subscriptions: []stores.QPatternGrant{{Pattern: "^team-a-", Users: []string{"uuid2"}}},
+	}, qp[0].PatternACL)
+
+	// invalid patterns
+	_, err = UpdatePatternACL("argo_uuid", PatternACL{Topics: []PatternGrant{{Pattern: "^team-(a", AuthUsers: []string{"UserA"}}}}, tm, store)
+	suite.Equal(errors.New("invalid pattern ^team-(a, error parsing regexp: missing closing ): `^team-(a`"), err)
+	_, err = UpdatePatternACL("argo_uuid", PatternACL{Subscriptions: []PatternGrant{{AuthUsers: []string{"UserA"}}}}, tm, store)
+	suite.Equal(errors.New("invalid pattern, it should not be empty"), err)
+
+	// unknown users
+	pacl := PatternACL{
+		Topics:        []PatternGrant{{Pattern: "a", AuthUsers: []string{"foo", "UserA"}}},
+		Subscriptions: []PatternGrant{{Pattern: "b", AuthUsers: []string{"bar", "foo"}}},
+	}
+	suite.Equal(errors.New("User(s): foo, bar do not exist"), pacl.Validate(store))
+
+	// unknown project
+	_, err = UpdatePatternACL("unknown", PatternACL{}, tm, store)
+	suite.Equal(errors.New("not found"), err)
+
+	// empty lists remove the pattern acl
+	p, err = UpdatePatternACL("argo_uuid", PatternACL{}, tm, store)
+	suite.Nil(err)
+	suite.Nil(p.PatternACL)
+}
+
 func TestProjectsTestSuite(t *testing.T) {
 	suite.Run(t, new(ProjectsTestSuite))
 }
@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/status"
+	"time"
 )
 
 // GrpcClient is used to interface with ams push server
@@ -118,17 +119,68 @@ func (c *GrpcClient) SubscriptionStatus(ctx context.Context, fullSub string) Cli
 	}
 }
 
+// RetryStatus reports the back off state of a subscription through the grpc SubscriptionStatus call
+func (c *GrpcClient) RetryStatus(ctx context.Context, fullSub string) (RetryStatus, error) {
+
+	statusSubR := &amsPb.SubscriptionStatusRequest{
+		FullName: fullSub,
+	}
+
+	r, err := c.psc.SubscriptionStatus(ctx, statusSubR)
+	if err != nil {
+		return RetryStatus{}, err
+	}
+
+	rs := RetryStatus{
+		Failures: int(r.GetConsecutiveFailures()),
+	}
+
+	if r.GetNextRetry() > 0 {
+		rs.NextRetry = time.Unix(0, r.GetNextRetry()).UTC()
+	}
+
+	return rs, nil
+}
+
+// Workers reports the liveness of the workers of the push server through the grpc Status call
+func (c *GrpcClient) Workers(ctx context.Context) ([]WorkerStatus, error) {
+
+	r, err := c.psc.Status(ctx, &amsPb.StatusRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	workers := []WorkerStatus{}
+
+	for _, w := range r.GetWorkers() {
+		ws := WorkerStatus{
+			FullName: w.GetFullName(),
+			Status:   w.GetStatus(),
+		}
+		if w.GetLastActive() > 0 {
+			ws.LastActive = time.Unix(0, w.GetLastActive()).UTC()
+		}
+		workers = append(workers, ws)
+	}
+
+	return workers, nil
+}
+
 // ActivateSubscription is a wrapper over the grpc ActivateSubscription call
-func (c *GrpcClient) ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string) ClientStatus {
+func (c *GrpcClient) ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string, fanoutEndpoints []string, maxRetries, maxConcurrentDeliveries, maxRetryDuration uint32) ClientStatus {
 
 	actSubR := &amsPb.ActivateSubscriptionRequest{
 		Subscription: &amsPb.Subscription{
 			FullName:  fullSub,
 			FullTopic: fullTopic,
 			PushConfig: &amsPb.PushConfig{
-				PushEndpoint:        pushEndpoint,
-				MaxMessages:         maxMessages,
-				AuthorizationHeader: authzHeader,
+				PushEndpoint:            pushEndpoint,
+				MaxMessages:             maxMessages,
+				AuthorizationHeader:     authzHeader,
+				FanoutEndpoints:         fanoutEndpoints,
+				MaxRetries:              maxRetries,
+				MaxConcurrentDeliveries: maxConcurrentDeliveries,
+				MaxRetryDuration:        maxRetryDuration,
 				RetryPolicy: &amsPb.RetryPolicy{
 					Type:   retryType,
 					Period: retryPeriod,
@@ -3,6 +3,7 @@ package push
 import (
 	"context"
 	"fmt"
+	"time"
 )
 
 type MockClient struct{}
@@ -14,6 +15,31 @@ func (*MockClient) SubscriptionStatus(ctx context.Context, fullSub string) Clien
 	}
 }
 
+func (*MockClient) RetryStatus(ctx context.Context, fullSub string) (RetryStatus, error) {
+
+	switch fullSub {
+	case "/projects/ARGO/subscriptions/sub4":
+
+		return RetryStatus{
+			Failures:  3,
+			NextRetry: time.Date(2020, 11, 19, 10, 15, 5, 123456789, time.UTC),
+		}, nil
+	}
+
+	return RetryStatus{}, nil
+}
+
+func (*MockClient) Workers(ctx context.Context) ([]WorkerStatus, error) {
+
+	return []WorkerStatus{
+		{
+			FullName:   "/projects/ARGO/subscriptions/sub4",
+			Status:     "running",
+			LastActive: time.Date(2020, 11, 19, 10, 15, 5, 0, time.UTC),
+		},
+	}, nil
+}
+
 func (*MockClient) HealthCheck(ctx context.Context) ClientStatus {
 	return &MockClientStatus{
 		Status: "SERVING",
@@ -26,7 +52,7 @@ func (*MockClient) Target() string {
 
 func (*MockClient) Dial() error { return nil }
 
-func (*MockClient) ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string) ClientStatus {
+func (*MockClient) ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string, fanoutEndpoints []string, maxRetries, maxConcurrentDeliveries, maxRetryDuration uint32) ClientStatus {
 
 	switch fullSub {
 	case "/projects/ARGO/subscriptions/subNew":
@@ -2,6 +2,7 @@ package push
 
 import (
 	"context"
+	"time"
 )
 
 // Client help us interface with any push backend mechanism
@@ -9,12 +10,17 @@ type Client interface {
 	// Dial establishes a connection with the push backend
 	Dial() error
 	// ActivateSubscription provides the push backend
-	// with all the necessary information to start the push functionality for the respective subscription
-	ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string) ClientStatus
+	// with all the necessary information to start the push functionality for the respective subscription,
+	// the fanout endpoints that receive every message along with the push endpoint included
+	ActivateSubscription(ctx context.Context, fullSub, fullTopic, pushEndpoint, retryType string, retryPeriod uint32, maxMessages int64, authzHeader string, fanoutEndpoints []string, maxRetries, maxConcurrentDeliveries, maxRetryDuration uint32) ClientStatus
 	// DeactivateSubscription asks the push backend to stop the push functionality for the respective subscription
 	DeactivateSubscription(ctx context.Context, fullSub string) ClientStatus
 	// SubscriptionStatus returns the current push status oif the given subscription
 	SubscriptionStatus(ctx context.Context, fullSub string) ClientStatus
+	// RetryStatus returns the back off state of the given subscription on the push backend
+	RetryStatus(ctx context.Context, fullSub string) (RetryStatus, error)
+	// Workers returns the liveness of the workers of the push backend, one for each activated subscription
+	Workers(ctx context.Context) ([]WorkerStatus, error)
 	// HealthCheck performs the grpc health check call
 	HealthCheck(ctx context.Context) ClientStatus
 	// Target returns the endpoint the client has been connected to
@@ -23,6 +29,24 @@ type Client interface {
 	Close()
 }
 
+// RetryStatus holds the back off state of a subscription on a push backend
+type RetryStatus struct {
+	// Failures is the number of consecutive failed delivery attempts of the subscription
+	Failures int
+	// NextRetry is when the failed deliveries are attempted again, zero when the subscription isn't backing off
+	NextRetry time.Time
+}
+
+// WorkerStatus holds the liveness of the worker that handles a subscription on a push backend
+type WorkerStatus struct {
+	// FullName is the full resource name of the subscription
+	FullName string
+	// Status is the status of the worker, e.g. running or stalled
+	Status string
+	// LastActive is when the worker was last active, zero if it never was
+	LastActive time.Time
+}
+
 // ClientStatus represents responses from a push backend
 type ClientStatus interface {
 	// Result returns the string representation for the response from a push backend
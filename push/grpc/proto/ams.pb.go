@@ -66,7 +66,11 @@ func (m *SubscriptionStatusRequest) GetFullName() string {
 // Empty wrapper for status response call
 type SubscriptionStatusResponse struct {
 	// Required. The full resource name of the subscrption.
-	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Status string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	// Optional. The number of consecutive failed delivery attempts of the subscription.
+	ConsecutiveFailures uint32 `protobuf:"varint,2,opt,name=consecutive_failures,json=consecutiveFailures,proto3" json:"consecutive_failures,omitempty"`
+	// Optional. When the failed deliveries are attempted again in unix nanoseconds, 0 when the subscription isn't backing off.
+	NextRetry            int64    `protobuf:"varint,3,opt,name=next_retry,json=nextRetry,proto3" json:"next_retry,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -104,6 +108,20 @@ func (m *SubscriptionStatusResponse) GetStatus() string {
 	return ""
 }
 
+func (m *SubscriptionStatusResponse) GetConsecutiveFailures() uint32 {
+	if m != nil {
+		return m.ConsecutiveFailures
+	}
+	return 0
+}
+
+func (m *SubscriptionStatusResponse) GetNextRetry() int64 {
+	if m != nil {
+		return m.NextRetry
+	}
+	return 0
+}
+
 // Empty wrapper for status request call
 type StatusRequest struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -136,11 +154,13 @@ func (m *StatusRequest) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StatusRequest proto.InternalMessageInfo
 
-// Empty wrapper for status response call
+// Wrapper for status response call
 type StatusResponse struct {
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	// Optional. The liveness of the workers that handle the activated subscriptions.
+	Workers              []*WorkerStatus `protobuf:"bytes,1,rep,name=workers,proto3" json:"workers,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
 }
 
 func (m *StatusResponse) Reset()         { *m = StatusResponse{} }
@@ -168,6 +188,13 @@ func (m *StatusResponse) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_StatusResponse proto.InternalMessageInfo
 
+func (m *StatusResponse) GetWorkers() []*WorkerStatus {
+	if m != nil {
+		return m.Workers
+	}
+	return nil
+}
+
 // Wrapper for subscription
 type DeactivateSubscriptionResponse struct {
 	// Message response
@@ -400,7 +427,15 @@ type PushConfig struct {
 	// Required. Retry policy.
 	RetryPolicy *RetryPolicy `protobuf:"bytes,2,opt,name=retry_policy,json=retryPolicy,proto3" json:"retry_policy,omitempty"`
 	// Required. Authorization header that the sent messages should include into the request
-	AuthorizationHeader  string   `protobuf:"bytes,4,opt,name=authorization_header,json=authorizationHeader,proto3" json:"authorization_header,omitempty"`
+	AuthorizationHeader string `protobuf:"bytes,4,opt,name=authorization_header,json=authorizationHeader,proto3" json:"authorization_header,omitempty"`
+	// Optional. Endpoints that receive every message along with the push endpoint, each one retrying on its own.
+	FanoutEndpoints []string `protobuf:"bytes,5,rep,name=fanout_endpoints,json=fanoutEndpoints,proto3" json:"fanout_endpoints,omitempty"`
+	// Optional. How many times a failed delivery of a message is retried on each endpoint before it is skipped, 0 meaning no limit.
+	MaxRetries uint32 `protobuf:"varint,6,opt,name=max_retries,json=maxRetries,proto3" json:"max_retries,omitempty"`
+	// Defaults to 1. How many deliveries the push server should keep in flight for the subscription.
+	MaxConcurrentDeliveries uint32 `protobuf:"varint,7,opt,name=max_concurrent_deliveries,json=maxConcurrentDeliveries,proto3" json:"max_concurrent_deliveries,omitempty"`
+	// Optional. For how many seconds a message is retried before it is skipped, 0 meaning no limit.
+	MaxRetryDuration     uint32   `protobuf:"varint,8,opt,name=max_retry_duration,json=maxRetryDuration,proto3" json:"max_retry_duration,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -459,6 +494,34 @@ func (m *PushConfig) GetAuthorizationHeader() string {
 	return ""
 }
 
+func (m *PushConfig) GetFanoutEndpoints() []string {
+	if m != nil {
+		return m.FanoutEndpoints
+	}
+	return nil
+}
+
+func (m *PushConfig) GetMaxRetries() uint32 {
+	if m != nil {
+		return m.MaxRetries
+	}
+	return 0
+}
+
+func (m *PushConfig) GetMaxConcurrentDeliveries() uint32 {
+	if m != nil {
+		return m.MaxConcurrentDeliveries
+	}
+	return 0
+}
+
+func (m *PushConfig) GetMaxRetryDuration() uint32 {
+	if m != nil {
+		return m.MaxRetryDuration
+	}
+	return 0
+}
+
 // RetryPolicy holds information regarding the retry policy.
 type RetryPolicy struct {
 	// Required. Type of the retry policy used (Only linear policy supported).
@@ -509,6 +572,65 @@ func (m *RetryPolicy) GetPeriod() uint32 {
 	return 0
 }
 
+// WorkerStatus holds the liveness of the worker that handles a subscription.
+type WorkerStatus struct {
+	// Required. The full resource name of the subscription.
+	FullName string `protobuf:"bytes,1,opt,name=full_name,json=fullName,proto3" json:"full_name,omitempty"`
+	// Required. The status of the worker, running, stalled or stopped.
+	Status string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	// Optional. When the worker was last active in unix nanoseconds.
+	LastActive           int64    `protobuf:"varint,3,opt,name=last_active,json=lastActive,proto3" json:"last_active,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WorkerStatus) Reset()         { *m = WorkerStatus{} }
+func (m *WorkerStatus) String() string { return proto.CompactTextString(m) }
+func (*WorkerStatus) ProtoMessage()    {}
+func (*WorkerStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_85e4db6795b5b1aa, []int{11}
+}
+
+func (m *WorkerStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WorkerStatus.Unmarshal(m, b)
+}
+func (m *WorkerStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WorkerStatus.Marshal(b, m, deterministic)
+}
+func (m *WorkerStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WorkerStatus.Merge(m, src)
+}
+func (m *WorkerStatus) XXX_Size() int {
+	return xxx_messageInfo_WorkerStatus.Size(m)
+}
+func (m *WorkerStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_WorkerStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WorkerStatus proto.InternalMessageInfo
+
+func (m *WorkerStatus) GetFullName() string {
+	if m != nil {
+		return m.FullName
+	}
+	return ""
+}
+
+func (m *WorkerStatus) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *WorkerStatus) GetLastActive() int64 {
+	if m != nil {
+		return m.LastActive
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*SubscriptionStatusRequest)(nil), "SubscriptionStatusRequest")
 	proto.RegisterType((*SubscriptionStatusResponse)(nil), "SubscriptionStatusResponse")
@@ -521,42 +643,54 @@ func init() {
 	proto.RegisterType((*Subscription)(nil), "Subscription")
 	proto.RegisterType((*PushConfig)(nil), "PushConfig")
 	proto.RegisterType((*RetryPolicy)(nil), "RetryPolicy")
+	proto.RegisterType((*WorkerStatus)(nil), "WorkerStatus")
 }
 
 func init() { proto.RegisterFile("ams.proto", fileDescriptor_85e4db6795b5b1aa) }
 
 var fileDescriptor_85e4db6795b5b1aa = []byte{
-	// 472 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0x41, 0x6f, 0xd3, 0x30,
-	0x14, 0x6e, 0xb7, 0xa9, 0xd0, 0x97, 0x74, 0x43, 0x8f, 0x69, 0x0a, 0xe9, 0x3a, 0x86, 0xb9, 0x4c,
-	0x02, 0x19, 0xad, 0x70, 0x18, 0x88, 0xcb, 0x04, 0x48, 0x5c, 0x80, 0xca, 0x85, 0x13, 0x87, 0xc8,
-	0x4b, 0xbd, 0xd5, 0x52, 0x13, 0x1b, 0xdb, 0x99, 0x5a, 0x7e, 0x19, 0x37, 0xfe, 0x1a, 0x8a, 0x49,
-	0xb7, 0x06, 0xda, 0x08, 0x71, 0xf3, 0xfb, 0x9e, 0x3f, 0x7f, 0xcf, 0xcf, 0xef, 0x33, 0x74, 0x79,
-	0x66, 0xa9, 0x36, 0xca, 0x29, 0x72, 0x06, 0x0f, 0xc6, 0xc5, 0x85, 0x4d, 0x8d, 0xd4, 0x4e, 0xaa,
-	0x7c, 0xec, 0xb8, 0x2b, 0x2c, 0x13, 0xdf, 0x0a, 0x61, 0x1d, 0xf6, 0xa1, 0x7b, 0x59, 0xcc, 0x66,
-	0x49, 0xce, 0x33, 0x11, 0xb5, 0x8f, 0xdb, 0x27, 0x5d, 0x76, 0xb7, 0x04, 0x3e, 0xf2, 0x4c, 0x90,
-	0x17, 0x10, 0xaf, 0x63, 0x5a, 0xad, 0x72, 0x2b, 0xf0, 0x00, 0x3a, 0xd6, 0x23, 0x15, 0xaf, 0x8a,
-	0xc8, 0x1e, 0xf4, 0x6a, 0x1a, 0xe4, 0x1e, 0xec, 0xd6, 0xa9, 0xe4, 0x15, 0x1c, 0xbd, 0x15, 0x3c,
-	0x75, 0xf2, 0x9a, 0x3b, 0xb1, 0x2a, 0x71, 0x73, 0x78, 0x04, 0x77, 0x32, 0x61, 0x2d, 0xbf, 0x5a,
-	0x56, 0xb5, 0x0c, 0xc9, 0x6b, 0x18, 0x6c, 0xe2, 0xfe, 0xc3, 0x95, 0xce, 0xe0, 0xf0, 0xfc, 0xff,
-	0x74, 0x47, 0xd0, 0x3f, 0x6f, 0x50, 0x3d, 0x85, 0xd0, 0xae, 0xc0, 0x9e, 0x1d, 0x0c, 0x7b, 0xb4,
-	0xb6, 0xb7, 0xb6, 0x85, 0xcc, 0x21, 0x5c, 0xcd, 0x36, 0x16, 0x8e, 0x03, 0x00, 0x9f, 0x74, 0x4a,
-	0xcb, 0x34, 0xda, 0xf2, 0x59, 0xbf, 0xfd, 0x73, 0x09, 0xe0, 0x53, 0x08, 0x74, 0x61, 0xa7, 0x49,
-	0xaa, 0xf2, 0x4b, 0x79, 0x15, 0xed, 0x78, 0xf5, 0x80, 0x8e, 0x0a, 0x3b, 0x7d, 0xe3, 0x21, 0x06,
-	0xfa, 0x66, 0x4d, 0x7e, 0xb4, 0x01, 0x6e, 0x53, 0xf8, 0x18, 0x7a, 0x9e, 0x2c, 0xf2, 0x89, 0x56,
-	0x32, 0x77, 0x95, 0x78, 0x58, 0x82, 0xef, 0x2a, 0x0c, 0x1f, 0x41, 0x98, 0xf1, 0x79, 0x52, 0xb5,
-	0xc3, 0x46, 0xdb, 0xc7, 0xed, 0x93, 0x6d, 0x16, 0x64, 0x7c, 0xfe, 0xa1, 0x82, 0xf0, 0x19, 0x84,
-	0x46, 0x38, 0xb3, 0x48, 0xb4, 0x9a, 0xc9, 0x74, 0xe1, 0xab, 0x0c, 0x86, 0x21, 0x65, 0x25, 0x38,
-	0xf2, 0x18, 0x0b, 0xcc, 0x6d, 0x80, 0xa7, 0xb0, 0xcf, 0x0b, 0x37, 0x55, 0x46, 0x7e, 0xe7, 0x65,
-	0x0b, 0x92, 0xa9, 0xe0, 0x13, 0x61, 0x7c, 0xf9, 0x5d, 0x76, 0xbf, 0x96, 0x7b, 0xef, 0x53, 0xe4,
-	0x25, 0x04, 0x2b, 0xc7, 0x21, 0xc2, 0x8e, 0x5b, 0xe8, 0x65, 0xbb, 0xfc, 0xba, 0x1c, 0x4c, 0x2d,
-	0x8c, 0x54, 0x13, 0x5f, 0x40, 0x8f, 0x55, 0xd1, 0xf0, 0xe7, 0x16, 0x04, 0xe5, 0xad, 0xc7, 0xc2,
-	0x5c, 0xcb, 0x54, 0xe0, 0x17, 0xd8, 0x5f, 0xf7, 0xa2, 0x78, 0x48, 0x1b, 0x1e, 0x3a, 0x1e, 0xd0,
-	0xa6, 0x01, 0x22, 0x2d, 0xfc, 0x0a, 0x07, 0xeb, 0x07, 0x14, 0x8f, 0x68, 0xe3, 0xe4, 0xc6, 0x0f,
-	0x69, 0xb3, 0x2b, 0x48, 0x0b, 0x9f, 0x40, 0xe7, 0xb7, 0x97, 0x70, 0x97, 0xd6, 0x5c, 0x16, 0xef,
-	0xd1, 0x3f, 0x4c, 0xd6, 0xc2, 0x4f, 0x80, 0x7f, 0xfb, 0x17, 0x63, 0xba, 0xf1, 0x3b, 0x88, 0xfb,
-	0x74, 0xb3, 0xe1, 0x49, 0xeb, 0xa2, 0xe3, 0x7f, 0x94, 0xe7, 0xbf, 0x02, 0x00, 0x00, 0xff, 0xff,
-	0x9c, 0x92, 0xc0, 0xda, 0x5e, 0x04, 0x00, 0x00,
+	// 653 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x54, 0xcd, 0x6e, 0xd3, 0x40,
+	0x10, 0xce, 0x4f, 0x49, 0xeb, 0xb1, 0xd3, 0x56, 0xdb, 0xaa, 0xb8, 0xe9, 0x5f, 0x58, 0x0e, 0x04,
+	0x51, 0x19, 0xb5, 0x5c, 0xda, 0x8a, 0x4b, 0xd5, 0x82, 0xb8, 0x00, 0x95, 0x0b, 0xe2, 0xc0, 0xc1,
+	0xda, 0x3a, 0x9b, 0x66, 0x45, 0xec, 0x35, 0xbb, 0xeb, 0x90, 0xf0, 0x00, 0xbc, 0x16, 0x6f, 0xc4,
+	0x33, 0xa0, 0xdd, 0x6e, 0x52, 0x1b, 0x5a, 0x0b, 0x71, 0xcb, 0x7c, 0xdf, 0x8c, 0xe7, 0xcb, 0xec,
+	0x37, 0x03, 0x0e, 0x49, 0x64, 0x90, 0x09, 0xae, 0x38, 0x3e, 0x82, 0xcd, 0xcb, 0xfc, 0x4a, 0xc6,
+	0x82, 0x65, 0x8a, 0xf1, 0xf4, 0x52, 0x11, 0x95, 0xcb, 0x90, 0x7e, 0xcd, 0xa9, 0x54, 0x68, 0x0b,
+	0x9c, 0x41, 0x3e, 0x1a, 0x45, 0x29, 0x49, 0xa8, 0x5f, 0xef, 0xd6, 0x7b, 0x4e, 0xb8, 0xa4, 0x81,
+	0x77, 0x24, 0xa1, 0xf8, 0x47, 0x1d, 0x3a, 0x77, 0x95, 0xca, 0x8c, 0xa7, 0x92, 0xa2, 0x0d, 0x68,
+	0x49, 0x83, 0xd8, 0x42, 0x1b, 0xa1, 0x03, 0x58, 0x8f, 0x75, 0x42, 0x9c, 0x2b, 0x36, 0xa6, 0xd1,
+	0x80, 0xb0, 0x51, 0x2e, 0xa8, 0xf4, 0x1b, 0xdd, 0x7a, 0xaf, 0x1d, 0xae, 0x15, 0xb8, 0xd7, 0x96,
+	0x42, 0x3b, 0x00, 0x29, 0x9d, 0xa8, 0x48, 0x50, 0x25, 0xa6, 0x7e, 0xb3, 0x5b, 0xef, 0x35, 0x43,
+	0x47, 0x23, 0xa1, 0x06, 0xf0, 0x0a, 0xb4, 0x4b, 0xb2, 0xf1, 0x31, 0x2c, 0xff, 0x21, 0xe6, 0x09,
+	0x2c, 0x7e, 0xe3, 0xe2, 0x0b, 0x15, 0x5a, 0x4d, 0xb3, 0xe7, 0x1e, 0xb6, 0x83, 0x4f, 0x26, 0xb6,
+	0x79, 0x33, 0x16, 0x9f, 0xc0, 0xee, 0x39, 0x25, 0xb1, 0x62, 0x63, 0xa2, 0x68, 0xf1, 0xdf, 0xcd,
+	0x3f, 0xe5, 0xc3, 0x62, 0x42, 0xa5, 0x24, 0xd7, 0xb3, 0x89, 0xcc, 0x42, 0xfc, 0x12, 0x76, 0xee,
+	0xab, 0xfd, 0x87, 0x71, 0x1e, 0xc1, 0xf6, 0xe9, 0xff, 0xf5, 0xbd, 0x80, 0xad, 0xd3, 0x8a, 0xae,
+	0x07, 0xe0, 0xc9, 0x02, 0x6c, 0xaa, 0xf5, 0x00, 0x4a, 0xb9, 0xa5, 0x14, 0x3c, 0x01, 0xaf, 0xc8,
+	0x56, 0x0a, 0xd7, 0xaf, 0x63, 0x48, 0xc5, 0x33, 0x16, 0x9b, 0x67, 0x74, 0x42, 0x93, 0xfe, 0x41,
+	0x03, 0x68, 0x1f, 0xdc, 0x2c, 0x97, 0xc3, 0x28, 0xe6, 0xe9, 0x80, 0x5d, 0xfb, 0x0b, 0xa6, 0xbb,
+	0x1b, 0x5c, 0xe4, 0x72, 0x78, 0x66, 0xa0, 0x10, 0xb2, 0xf9, 0x6f, 0xfc, 0xab, 0x01, 0x70, 0x4b,
+	0xa1, 0xc7, 0xd0, 0x36, 0xc5, 0x34, 0xed, 0x67, 0x9c, 0xa5, 0xca, 0x36, 0xf7, 0x34, 0xf8, 0xca,
+	0x62, 0xe8, 0x11, 0x78, 0x09, 0x99, 0x44, 0x76, 0x1c, 0xd2, 0x1a, 0xc4, 0x4d, 0xc8, 0xe4, 0xad,
+	0x85, 0xd0, 0x73, 0xf0, 0x8c, 0x79, 0xa2, 0x8c, 0x8f, 0x58, 0x3c, 0x35, 0x2a, 0xdd, 0x43, 0x2f,
+	0x30, 0x06, 0xba, 0x30, 0x58, 0xe8, 0x8a, 0xdb, 0x40, 0xbb, 0x94, 0xe4, 0x6a, 0xc8, 0x05, 0xfb,
+	0x4e, 0xf4, 0x08, 0xa2, 0x21, 0x25, 0x7d, 0x2a, 0x8c, 0x7c, 0x27, 0x5c, 0x2b, 0x71, 0x6f, 0x0c,
+	0x85, 0x9e, 0xc2, 0xea, 0x80, 0xa4, 0x3c, 0x57, 0x73, 0xb5, 0xd2, 0x7f, 0xd0, 0x6d, 0xf6, 0x9c,
+	0x70, 0xe5, 0x06, 0x9f, 0x09, 0x96, 0x68, 0x0f, 0xb4, 0x3a, 0xe3, 0x67, 0x46, 0xa5, 0xdf, 0x32,
+	0xd6, 0x87, 0x84, 0x4c, 0xc2, 0x1b, 0x04, 0x9d, 0xc0, 0xa6, 0x4e, 0x88, 0x79, 0x1a, 0xe7, 0x42,
+	0xd0, 0x54, 0x45, 0x7d, 0x3a, 0x62, 0x63, 0x6a, 0xd2, 0x17, 0x4d, 0xfa, 0xc3, 0x84, 0x4c, 0xce,
+	0xe6, 0xfc, 0xf9, 0x9c, 0x46, 0xfb, 0x80, 0x66, 0x1f, 0x9f, 0x46, 0xfd, 0x5c, 0x18, 0x8d, 0xfe,
+	0x92, 0x29, 0x5a, 0xb5, 0x3d, 0xa6, 0xe7, 0x16, 0xc7, 0xc7, 0xe0, 0x16, 0x86, 0x80, 0x10, 0x2c,
+	0xa8, 0x69, 0x36, 0x7b, 0x64, 0xf3, 0x5b, 0x6f, 0x72, 0x46, 0x05, 0xe3, 0x7d, 0xbb, 0xa3, 0x36,
+	0xc2, 0x7d, 0xf0, 0x8a, 0x4b, 0x54, 0xed, 0x92, 0xdb, 0x73, 0xd0, 0x28, 0x9d, 0x83, 0x3d, 0x70,
+	0x47, 0x44, 0xaa, 0xc8, 0xec, 0x0d, 0xb5, 0x6f, 0x07, 0x1a, 0x32, 0x9e, 0xa6, 0x87, 0x3f, 0x1b,
+	0xe0, 0x6a, 0x47, 0x5c, 0x52, 0x31, 0x66, 0x31, 0x45, 0x1f, 0x61, 0xfd, 0x2e, 0xb7, 0xa3, 0xed,
+	0xa0, 0x62, 0x09, 0x3a, 0x3b, 0x41, 0xd5, 0x72, 0xe1, 0x1a, 0xfa, 0x0c, 0x1b, 0x77, 0x2f, 0x2f,
+	0xda, 0x0d, 0x2a, 0xb7, 0xba, 0xb3, 0x17, 0x54, 0x5f, 0x0c, 0x5c, 0x43, 0xcf, 0xa0, 0x65, 0x67,
+	0xb4, 0x1c, 0x94, 0x4e, 0x55, 0x67, 0x25, 0x28, 0x5f, 0x2a, 0x5c, 0x43, 0xef, 0x01, 0xfd, 0x7d,
+	0x56, 0x51, 0x27, 0xb8, 0xf7, 0x4c, 0x77, 0xb6, 0x82, 0xfb, 0xef, 0x30, 0xae, 0x5d, 0xb5, 0xcc,
+	0xa5, 0x7f, 0xf1, 0x7b, 0x00, 0x3b, 0xe9, 0xf9, 0x31, 0xf6, 0x05, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -2,8 +2,13 @@ package push
 
 import (
 	"context"
+	b64 "encoding/base64"
 	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -12,20 +17,76 @@ import (
 	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/ARGOeu/argo-messaging/subscriptions"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 )
 
 // Pusher holds information for the pusher routine and subscription
 type Pusher struct {
 	id          int
 	sub         subscriptions.Subscription
-	endpoint    string
+	endpoints   []string
 	retryPolicy string
 	retryPeriod int
 	stop        chan int      // 1: Stop 2: restart
 	rate        time.Duration // in milliseconds
 	running     bool
-	sndr        Sender
 	mgr         *Manager
+	// deliveries keeps the delivery state of the consumed messages for each endpoint, keyed by their offset
+	deliveries map[int64]map[string]*delivery
+	// lastActive is the time the pusher was launched or last completed a push round
+	lastActive time.Time
+	// failedRounds counts the consecutive push rounds that left deliveries to be retried
+	failedRounds int
+	// nextRetry is when the failed deliveries are attempted again, zero while the pusher isn't backing off
+	nextRetry time.Time
+	activeMu  sync.Mutex
+}
+
+// StallGrace is how long a running pusher may go without completing a push round, on top of its rate,
+// before it is reported as stalled
+const StallGrace = time.Minute
+
+// Worker statuses reported for the push subscriptions
+const (
+	WorkerRunning = "running"
+	WorkerStalled = "stalled"
+	WorkerStopped = "stopped"
+	WorkerMissing = "missing"
+)
+
+// WorkerStatus reports the liveness of the pusher of a push subscription
+type WorkerStatus struct {
+	ProjectUUID  string
+	Subscription string
+	Status       string
+	LastActive   time.Time
+}
+
+// RetryStatus reports whether the pusher of a push subscription is backing off endpoints that failed their deliveries
+type RetryStatus struct {
+	// Failures is the number of consecutive push rounds that failed to deliver some of their messages
+	Failures int
+	// NextRetry is when the failed deliveries are attempted again, zero when the pusher isn't backing off
+	NextRetry time.Time
+}
+
+// delivery tracks the delivery of a message to one of the subscription's endpoints
+type delivery struct {
+	done     bool
+	failures int
+	// firstAttempt is when the message was first sent to the endpoint
+	firstAttempt time.Time
+	// abandoned marks a message that kept failing for longer than the subscription's max retry duration and is not retried anymore
+	abandoned bool
+}
+
+// outgoing is a consumed message that is about to be pushed
+type outgoing struct {
+	offset  int64
+	payload string
+	size    int64
+	// skip marks the messages that the subscription doesn't admit and are not delivered at all
+	skip bool
 }
 
 // Manager manages all pusher routines
@@ -33,7 +94,15 @@ type Manager struct {
 	list   map[string]*Pusher // map using as key the string = "{project}/{sub}"
 	broker brokers.Broker     // Reference to backend broker
 	store  stores.Store       // Reference to backend store
-	sender Sender             // Reference to send mechanism (HTTP client)
+	// transports deliver the messages to the endpoints, keyed by the scheme of the endpoints they serve
+	transports map[string]Transport
+	// defaultTransport delivers to the endpoints whose scheme has no transport of its own
+	defaultTransport Transport
+	// delegated are the schemes of the endpoints that another push backend delivers to,
+	// subscriptions with all of their endpoints over them are left to that backend
+	delegated map[string]bool
+	// lock guards the list, since pushers get added and removed while requests are served
+	lock sync.RWMutex
 }
 
 // LoadPushSubs is called during API initialization to retrieve available
@@ -44,10 +113,39 @@ func (mgr *Manager) LoadPushSubs() {
 
 	// Add all of them
 	for _, item := range results.Subscriptions {
+		if !mgr.Serves(item.PushCfg) {
+			continue
+		}
 		mgr.Add(item.ProjectUUID, item.Name)
 	}
 }
 
+// DelegateSchemes leaves the subscriptions whose endpoints are all addressed over the given schemes to another
+// push backend, e.g. the http ones to the ams push server, so the manager runs pushers only for the rest of them
+func (mgr *Manager) DelegateSchemes(schemes ...string) {
+	if mgr.delegated == nil {
+		mgr.delegated = map[string]bool{}
+	}
+	for _, scheme := range schemes {
+		mgr.delegated[strings.ToLower(scheme)] = true
+	}
+}
+
+// Serves returns true if the manager delivers the messages of a subscription with the given push configuration,
+// which is the case when it has been set up and some of the endpoints are not delegated to another push backend
+func (mgr *Manager) Serves(pc subscriptions.PushConfig) bool {
+	if !mgr.isSet() {
+		return false
+	}
+	for _, endpoint := range pc.Endpoints() {
+		u, err := url.Parse(endpoint)
+		if err != nil || !mgr.delegated[strings.ToLower(u.Scheme)] {
+			return true
+		}
+	}
+	return false
+}
+
 // StartAll enables all pushsers
 func (mgr *Manager) StartAll() {
 	for k := range mgr.list {
@@ -92,6 +190,16 @@ func (mgr *Manager) RemoveProjectAll(projectUUID string) error {
 	return nil
 }
 
+// deliver sends the message to the endpoint, presenting the subscription's authorization header to the endpoints
+// whose transport supports it, as the ams push server does
+func (p *Pusher) deliver(msg string, endpoint string) error {
+	t := p.mgr.transport(endpoint)
+	if at, ok := t.(AuthorizingTransport); ok {
+		return at.DeliverAuthorized(msg, endpoint, p.sub.PushCfg.AuthorizationHeader.Value).Err
+	}
+	return t.Deliver(msg, endpoint)
+}
+
 // Push method of pusher object to consume and push messages
 func (p *Pusher) push(brk brokers.Broker, store stores.Store) {
 	log.Debug("pid ", p.id, "pushing")
@@ -107,40 +215,311 @@ func (p *Pusher) push(brk brokers.Broker, store stores.Store) {
 	p.sub = subs.Subscriptions[0]
 	// Init Received Message List
 
-	fullTopic := p.sub.ProjectUUID + "." + p.sub.Topic
-	msgs, err := brk.Consume(context.Background(), fullTopic, p.sub.Offset, true, 1)
+	// as many messages as the deliveries that can be in flight are consumed on each round
+	concurrency := p.sub.PushCfg.EffectiveMaxConcurrentDeliveries()
+
+	fullTopic := p.sub.BrokerTopic
+	msgs, err := brk.Consume(context.Background(), fullTopic, p.sub.Offset, true, int64(concurrency), p.sub.ReadIsolation())
 	if err != nil {
 		// If tracked offset is off, update it to the latest min offset
 		if err == brokers.ErrOffsetOff {
 			// Get Current Min Offset and advanced tracked one
 			p.sub.Offset = brk.GetMinOffset(fullTopic)
-			msgs, err = brk.Consume(context.Background(), fullTopic, p.sub.Offset, true, 1)
+			msgs, err = brk.Consume(context.Background(), fullTopic, p.sub.Offset, true, int64(concurrency), p.sub.ReadIsolation())
 			if err != nil {
 				log.Error("Unable to consume after updating offset")
 				return
 			}
 		}
 	}
-	if len(msgs) > 0 {
+	// the mock broker and compacted topics might return more messages than requested
+	if len(msgs) > concurrency {
+		msgs = msgs[:concurrency]
+	}
+
+	if len(msgs) == 0 {
+		log.Debug("pid: ", p.id, " empty")
+		p.recordRound(time.Now(), false)
+		return
+	}
+
+	batch := make([]outgoing, 0, len(msgs))
+	for i, msg := range msgs {
 		// Generate push message template
 		pMsg := messages.PushMsg{}
 
-		pMsg.Msg, _ = messages.LoadMsgJSON([]byte(msgs[0]))
+		pMsg.Msg, _ = messages.LoadMsgJSON([]byte(msg))
+		item := outgoing{offset: p.sub.Offset + int64(i), size: pMsg.Msg.Size()}
+		// messages published in a batch are stored without an id, their offset is their id
+		if pMsg.Msg.ID == "" {
+			pMsg.Msg.ID = strconv.FormatInt(item.offset, 10)
+		}
+		// messages published before the subscription's creation are skipped without being delivered
+		if !p.sub.Admits(pMsg.Msg) {
+			item.skip = true
+			batch = append(batch, item)
+			continue
+		}
+		if p.sub.Transform != nil {
+			if err := p.sub.Transform.Apply(&pMsg.Msg); err != nil {
+				log.Error("pid: ", p.id, " pushing untransformed message, ", err.Error())
+			}
+		}
 		pMsg.Sub = p.sub.FullName
-		pMsgJSON, _ := pMsg.ExportJSON()
-		err := p.sndr.Send(pMsgJSON, p.endpoint)
-
-		if err == nil {
-			// Advance the offset
-			store.UpdateSubOffset(p.sub.ProjectUUID, p.sub.Name, 1+p.sub.Offset)
-			// Update subscription's metrics
-			store.IncrementSubMsgNum(p.sub.ProjectUUID, p.sub.Name, int64(1))
-			store.IncrementSubBytes(p.sub.ProjectUUID, p.sub.Name, pMsg.Msg.Size())
-			log.Debug("offset updated")
+		item.payload, _ = pMsg.ExportJSON()
+		batch = append(batch, item)
+	}
+
+	delivered := p.deliverAll(batch, concurrency)
+	if delivered == 0 {
+		return
+	}
+
+	// Advance the offset past the messages that every endpoint has received
+	store.UpdateSubOffset(p.sub.ProjectUUID, p.sub.Name, int64(delivered)+p.sub.Offset)
+	store.UpdateSubLastProgress(p.sub.ProjectUUID, p.sub.Name, time.Now().UTC())
+
+	// Update subscription's metrics
+	num, bytes := int64(0), int64(0)
+	for _, item := range batch[:delivered] {
+		if !item.skip && !p.abandonedByAll(item.offset) {
+			num++
+			bytes += item.size
 		}
-	} else {
-		log.Debug("pid: ", p.id, " empty")
 	}
+	if num > 0 {
+		store.IncrementSubMsgNum(p.sub.ProjectUUID, p.sub.Name, num)
+		store.IncrementSubBytes(p.sub.ProjectUUID, p.sub.Name, bytes)
+	}
+	log.Debug("offset updated")
+}
+
+// deliverAll sends every message of the batch to the endpoints that haven't received it yet,
+// running at most concurrency sends at a time. Endpoints that failed are retried on the next round,
+// while the ones that succeeded are skipped. With a max retry duration, an endpoint that has been failing
+// a message for longer than it is given up on, so that a single message can't block the subscription indefinitely.
+// It returns the number of leading messages of the batch that all endpoints have either received or given up on,
+// which is how far the offset can advance
+func (p *Pusher) deliverAll(batch []outgoing, concurrency int) int {
+
+	now := time.Now()
+	maxRetry := time.Duration(p.sub.PushCfg.MaxRetryDuration) * time.Second
+	maxRetries := p.sub.PushCfg.EffectiveMaxRetries()
+
+	// the state of the messages that the offset has moved past is not relevant anymore
+	if p.deliveries == nil {
+		p.deliveries = make(map[int64]map[string]*delivery)
+	}
+	for offset := range p.deliveries {
+		if offset < p.sub.Offset {
+			delete(p.deliveries, offset)
+		}
+	}
+
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for _, item := range batch {
+		states, ok := p.deliveries[item.offset]
+		if !ok {
+			states = make(map[string]*delivery)
+			p.deliveries[item.offset] = states
+		}
+
+		if item.skip {
+			continue
+		}
+
+		for _, endpoint := range p.endpoints {
+			d, ok := states[endpoint]
+			if !ok {
+				d = &delivery{firstAttempt: now}
+				states[endpoint] = d
+			}
+
+			if d.done || d.abandoned {
+				continue
+			}
+
+			if maxRetry > 0 && d.failures > 0 && now.Sub(d.firstAttempt) >= maxRetry {
+				d.abandoned = true
+				log.WithFields(
+					log.Fields{
+						"type":         "service_log",
+						"project_uuid": p.sub.ProjectUUID,
+						"subscription": p.sub.Name,
+						"endpoint":     endpoint,
+						"offset":       item.offset,
+						"failures":     d.failures,
+					},
+				).Warning("Skipping a message that exceeded the max retry duration of the subscription")
+				continue
+			}
+
+			// the first attempt isn't a retry
+			if maxRetries > 0 && d.failures > maxRetries {
+				d.abandoned = true
+				log.WithFields(
+					log.Fields{
+						"type":         "service_log",
+						"project_uuid": p.sub.ProjectUUID,
+						"subscription": p.sub.Name,
+						"endpoint":     endpoint,
+						"offset":       item.offset,
+						"failures":     d.failures,
+					},
+				).Warning("Skipping a message that exceeded the max retries of the endpoint")
+				continue
+			}
+
+			// each delivery state is only touched by the goroutine that sends to its endpoint
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(msg string, endpoint string, d *delivery) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				if err := p.deliver(msg, endpoint); err != nil {
+					d.failures++
+					log.Debug("pid: ", p.id, " endpoint: ", endpoint, " failures: ", d.failures)
+					return
+				}
+				d.done = true
+			}(item.payload, endpoint, d)
+		}
+	}
+
+	wg.Wait()
+
+	failed := false
+	for _, item := range batch {
+		for _, d := range p.deliveries[item.offset] {
+			if !d.done && !d.abandoned && d.failures > 0 {
+				failed = true
+			}
+		}
+	}
+	p.recordRound(time.Now(), failed)
+
+	delivered := 0
+	for _, item := range batch {
+		if !item.skip && !p.deliveredToAll(item.offset) {
+			break
+		}
+		delivered++
+	}
+
+	return delivered
+}
+
+// deliveredToAll returns true if every endpoint has either received the message at the given offset or given up on it
+func (p *Pusher) deliveredToAll(offset int64) bool {
+	for _, endpoint := range p.endpoints {
+		if d, ok := p.deliveries[offset][endpoint]; !ok || !(d.done || d.abandoned) {
+			return false
+		}
+	}
+	return true
+}
+
+// abandonedByAll returns true if every endpoint has given up on the message at the given offset
+func (p *Pusher) abandonedByAll(offset int64) bool {
+	for _, endpoint := range p.endpoints {
+		if d, ok := p.deliveries[offset][endpoint]; !ok || !d.abandoned {
+			return false
+		}
+	}
+	return true
+}
+
+// touch records that the pusher is alive at the given time
+func (p *Pusher) touch(now time.Time) {
+	p.activeMu.Lock()
+	p.lastActive = now
+	p.activeMu.Unlock()
+}
+
+// recordRound tracks whether the push round that ended at the given time left failed deliveries behind.
+// The next round starts a rate after the end of the current one, which is when the deliveries are retried
+func (p *Pusher) recordRound(end time.Time, failed bool) {
+	p.activeMu.Lock()
+	defer p.activeMu.Unlock()
+
+	if !failed {
+		p.failedRounds = 0
+		p.nextRetry = time.Time{}
+		return
+	}
+	p.failedRounds++
+	p.nextRetry = end.Add(p.rate)
+}
+
+// retryStatus reports the backoff of the pusher, a stopped pusher retries nothing
+func (p *Pusher) retryStatus() RetryStatus {
+	p.activeMu.Lock()
+	defer p.activeMu.Unlock()
+
+	if !p.running {
+		return RetryStatus{}
+	}
+	return RetryStatus{Failures: p.failedRounds, NextRetry: p.nextRetry}
+}
+
+// status reports whether the pusher is running and has completed a push round recently enough
+func (p *Pusher) status(now time.Time) (string, time.Time) {
+	p.activeMu.Lock()
+	last := p.lastActive
+	p.activeMu.Unlock()
+
+	if !p.running {
+		return WorkerStopped, last
+	}
+	// a round is due every rate, so a pusher that missed a few of them is stuck on a delivery or no longer looping
+	if now.Sub(last) > 3*p.rate+StallGrace {
+		return WorkerStalled, last
+	}
+	return WorkerRunning, last
+}
+
+// IsActive returns true if the manager has been set up to run pushers
+func (mgr *Manager) IsActive() bool {
+	return mgr.isSet()
+}
+
+// Workers reports the liveness of the pusher of every push subscription in the store, ordered as the store returns them.
+// Push subscriptions without a pusher are reported as missing, e.g. when their pusher stopped after an error
+func (mgr *Manager) Workers(now time.Time) []WorkerStatus {
+
+	results := []WorkerStatus{}
+
+	for _, qSub := range mgr.store.QueryPushSubs() {
+		// subscriptions left to another push backend, or not yet verified, have no pusher to report
+		if !qSub.Verified || !mgr.Serves(subscriptions.PushConfig{Pend: qSub.PushEndpoint, Fanout: qSub.FanoutEndpoints}) {
+			continue
+		}
+		results = append(results, mgr.Status(qSub.ProjectUUID, qSub.Name, now))
+	}
+
+	return results
+}
+
+// Status reports the liveness of the pusher of the subscription, missing if it has none
+func (mgr *Manager) Status(projectUUID string, sub string, now time.Time) WorkerStatus {
+	ws := WorkerStatus{ProjectUUID: projectUUID, Subscription: sub, Status: WorkerMissing}
+	if p, err := mgr.Get(projectUUID + "/" + sub); err == nil {
+		ws.Status, ws.LastActive = p.status(now)
+	}
+	return ws
+}
+
+// RetryStatus reports whether the pusher of the subscription is backing off and when it retries its failed deliveries
+func (mgr *Manager) RetryStatus(projectUUID string, sub string) (RetryStatus, error) {
+	p, err := mgr.Get(projectUUID + "/" + sub)
+	if err != nil {
+		return RetryStatus{}, err
+	}
+	return p.retryStatus(), nil
 }
 
 // PrintAll prints manager stats
@@ -151,12 +530,73 @@ func (mgr *Manager) PrintAll() {
 	}
 }
 
-// NewManager creates a new manager object for managing push routines
-func NewManager(brk brokers.Broker, str stores.Store, sndr Sender) *Manager {
+// TestPushTimeout bounds the deliveries of test pushes to http endpoints that the manager has no transport for
+const TestPushTimeout = 10 * time.Second
+
+// TestPushAttribute marks the synthetic messages of the test pushes, so that endpoints can tell them apart
+const TestPushAttribute = "ams_test_push"
+
+// TestPush delivers a synthetic message to every verified endpoint of the subscription right away, along with the
+// subscription's authorization header, and reports how each endpoint responded. Endpoints that haven't been verified
+// are reported without anything being delivered to them, so that the service can't be used to reach arbitrary hosts.
+// The subscription's offset and the delivery state of its pusher are left untouched.
+// Http endpoints are tested directly when the manager has no transport for them, e.g. when the push server pushes
+func (mgr *Manager) TestPush(sub subscriptions.Subscription, now time.Time) []DeliveryReport {
+
+	msg := messages.Message{
+		ID:      "0",
+		Attr:    messages.Attributes{TestPushAttribute: "true"},
+		Data:    b64.StdEncoding.EncodeToString([]byte("test push from " + sub.FullName)),
+		PubTime: timestamp.FormatNano(now),
+	}
+	pMsg := messages.PushMsg{Msg: msg, Sub: sub.FullName}
+	payload, _ := pMsg.ExportJSON()
+
+	reports := []DeliveryReport{}
+	for _, endpoint := range sub.PushCfg.Endpoints() {
+
+		if !sub.PushCfg.IsVerified(endpoint) {
+			reports = append(reports, DeliveryReport{Endpoint: endpoint, Err: ErrEndpointNotVerified})
+			continue
+		}
+
+		t := mgr.transport(endpoint)
+		if _, none := t.(noTransport); none {
+			if u, err := url.Parse(endpoint); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+				t = &HTTPSender{Client: http.Client{Timeout: TestPushTimeout}}
+			}
+		}
+
+		if at, ok := t.(AuthorizingTransport); ok {
+			reports = append(reports, at.DeliverAuthorized(payload, endpoint, sub.PushCfg.AuthorizationHeader.Value))
+			continue
+		}
+
+		if rt, ok := t.(ReportingTransport); ok {
+			reports = append(reports, rt.DeliverWithReport(payload, endpoint))
+			continue
+		}
+
+		start := time.Now()
+		err := t.Deliver(payload, endpoint)
+		reports = append(reports, DeliveryReport{Endpoint: endpoint, Latency: time.Since(start), Err: err})
+	}
+
+	return reports
+}
+
+// NewManager creates a new manager object for managing push routines.
+// The given transport delivers to http endpoints and to any endpoint without a registered transport
+func NewManager(brk brokers.Broker, str stores.Store, httpTransport Transport) *Manager {
 	mgr := Manager{}
 	mgr.broker = brk
 	mgr.store = str
-	mgr.sender = sndr
+	mgr.transports = map[string]Transport{}
+	mgr.defaultTransport = httpTransport
+	if httpTransport != nil {
+		mgr.transports["http"] = httpTransport
+		mgr.transports["https"] = httpTransport
+	}
 	mgr.list = make(map[string]*Pusher)
 	log.Info("PUSH", "\t", "Manager Initialized")
 	return &mgr
@@ -182,6 +622,8 @@ func (mgr *Manager) isSet() bool {
 
 // Get returns a pusher
 func (mgr *Manager) Get(psub string) (*Pusher, error) {
+	mgr.lock.RLock()
+	defer mgr.lock.RUnlock()
 	if p, ok := mgr.list[psub]; ok {
 		return p, nil
 	}
@@ -196,7 +638,9 @@ func (mgr *Manager) Remove(project string, sub string) error {
 	}
 
 	if _, err := mgr.Get(project + "/" + sub); err == nil {
+		mgr.lock.Lock()
 		delete(mgr.list, project+"/"+sub)
+		mgr.lock.Unlock()
 		return nil
 	}
 
@@ -262,7 +706,7 @@ func (mgr *Manager) Refresh(projectUUID string, sub string) error {
 			return errors.New("Not Found")
 		}
 
-		p.endpoint = subs.Subscriptions[0].PushCfg.Pend
+		p.endpoints = subs.Subscriptions[0].PushCfg.Endpoints()
 		p.retryPolicy = subs.Subscriptions[0].PushCfg.RetPol.PolicyType
 		p.retryPeriod = subs.Subscriptions[0].PushCfg.RetPol.Period
 		p.rate = time.Duration(p.retryPeriod) * time.Millisecond
@@ -289,17 +733,24 @@ func (mgr *Manager) Add(projectUUID string, subName string) error {
 		return errors.New("not found")
 	}
 
+	// nothing gets delivered before the ownership of every endpoint has been verified
+	if !subs.Subscriptions[0].PushCfg.Verified {
+		return ErrEndpointNotVerified
+	}
+
+	mgr.lock.Lock()
+	defer mgr.lock.Unlock()
+
 	// Create new pusher
 	pushr := Pusher{}
 	pushr.id = len(mgr.list)
 	pushr.sub = subs.Subscriptions[0]
-	pushr.endpoint = subs.Subscriptions[0].PushCfg.Pend
+	pushr.endpoints = subs.Subscriptions[0].PushCfg.Endpoints()
 	pushr.running = false
 	pushr.stop = make(chan int, 2)
 	pushr.retryPolicy = subs.Subscriptions[0].PushCfg.RetPol.PolicyType
 	pushr.retryPeriod = subs.Subscriptions[0].PushCfg.RetPol.Period
 	pushr.rate = time.Duration(pushr.retryPeriod) * time.Millisecond
-	pushr.sndr = mgr.sender
 	pushr.mgr = mgr
 	mgr.list[projectUUID+"/"+subName] = &pushr
 	log.Info("PUSH", "\t", "Push Subscription Added")
@@ -335,6 +786,7 @@ func (mgr *Manager) Launch(project string, sub string) error {
 func (p *Pusher) launch(brk brokers.Broker, store stores.Store) {
 	log.Info("PUSH", "\t", "pusher: ", p.id, " launching...")
 	p.running = true
+	p.touch(time.Now())
 	if p.retryPolicy == "linear" {
 		go LinearActivity(p, brk, store)
 	}
@@ -364,6 +816,7 @@ func LinearActivity(p *Pusher, brk brokers.Broker, store stores.Store) error {
 		case <-rate:
 			{
 				p.push(brk, store)
+				p.touch(time.Now())
 			}
 		}
 	}
@@ -1,15 +1,24 @@
 package push
 
 import (
+	"context"
+	"encoding/hex"
 	"errors"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
 	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/subscriptions"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -60,6 +69,434 @@ func (suite *PushTestSuite) TestPusher() {
 	suite.Equal("endpoint.foo", p.sub.PushCfg.Pend)
 }
 
+func (suite *PushTestSuite) TestWorkers() {
+	sndr := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	pushMgr := NewManager(&brk, str, sndr)
+	now := time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC)
+
+	// push subscriptions without a pusher are missing
+	suite.Equal([]WorkerStatus{{ProjectUUID: "argo_uuid", Subscription: "sub4", Status: WorkerMissing}}, pushMgr.Workers(now))
+
+	pushMgr.Add("argo_uuid", "sub4")
+	suite.Equal(WorkerStopped, pushMgr.Workers(now)[0].Status)
+
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	p.running = true
+	p.touch(now)
+	ws := pushMgr.Workers(now.Add(p.rate))
+	suite.Equal(WorkerRunning, ws[0].Status)
+	suite.Equal(now, ws[0].LastActive)
+
+	// a pusher that stopped completing push rounds is stalled
+	suite.Equal(WorkerStalled, pushMgr.Workers(now.Add(3*p.rate + StallGrace + time.Second))[0].Status)
+}
+
+func (suite *PushTestSuite) TestPusherFanout() {
+	sndr := NewMockSender(false)
+	sndr.FailEndpoints = map[string]bool{"https://fan2.example.com": true}
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("aGVsbG8="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].FanoutEndpoints = []string{"https://fan1.example.com", "https://fan2.example.com"}
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	suite.Equal([]string{"endpoint.foo", "https://fan1.example.com", "https://fan2.example.com"}, p.endpoints)
+
+	// one of the endpoints fails, so the offset should stay put
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(0), qSub.Offset)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+	suite.Equal(1, sndr.Sent["https://fan1.example.com"])
+	suite.Equal(1, p.deliveries[0]["https://fan2.example.com"].failures)
+
+	// only the failed endpoint should be retried
+	p.push(&brk, str)
+	suite.Equal(2, p.deliveries[0]["https://fan2.example.com"].failures)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+
+	// once every endpoint has received the message the offset advances
+	sndr.FailEndpoints = nil
+	p.push(&brk, str)
+	qSub, _ = str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(1), qSub.Offset)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+	suite.Equal(1, sndr.Sent["https://fan1.example.com"])
+	suite.Equal(1, sndr.Sent["https://fan2.example.com"])
+}
+
+func (suite *PushTestSuite) TestPusherMaxConcurrentDeliveries() {
+	sndr := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMg=="), brokers.AcksAll, "")
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMw=="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].MaxConcurrentDeliveries = 3
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	suite.Equal(3, p.sub.PushCfg.MaxConcurrentDeliveries)
+
+	// all three messages are delivered in a single round
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(3), qSub.Offset)
+	suite.Equal(int64(3), qSub.MsgNum)
+	suite.Equal(3, sndr.Sent["endpoint.foo"])
+}
+
+func (suite *PushTestSuite) TestPusherDefaultMaxConcurrentDeliveries() {
+	sndr := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMg=="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	suite.Equal(1, p.sub.PushCfg.MaxConcurrentDeliveries)
+
+	// messages are delivered one at a time
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(1), qSub.Offset)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+}
+
+func (suite *PushTestSuite) TestPusherMaxRetryDuration() {
+	sndr := NewMockSender(false)
+	sndr.FailEndpoints = map[string]bool{"endpoint.foo": true}
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].MaxRetryDuration = 60
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	suite.Equal(60, p.sub.PushCfg.MaxRetryDuration)
+
+	// the message is retried while it is within the max retry duration
+	p.push(&brk, str)
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(0), qSub.Offset)
+	suite.Equal(2, p.deliveries[0]["endpoint.foo"].failures)
+
+	// once it has been failing for longer, it is skipped without another attempt and the offset advances
+	p.deliveries[0]["endpoint.foo"].firstAttempt = time.Now().Add(-time.Minute)
+	p.push(&brk, str)
+	qSub, _ = str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(1), qSub.Offset)
+	suite.Equal(int64(0), qSub.MsgNum)
+	suite.Equal(2, p.deliveries[0]["endpoint.foo"].failures)
+	suite.True(p.deliveries[0]["endpoint.foo"].abandoned)
+}
+
+func (suite *PushTestSuite) TestServes() {
+	sqs := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	queue := "sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"
+
+	// a manager that hasn't been set up serves nothing
+	suite.False((&Manager{}).Serves(subscriptions.PushConfig{Pend: queue}))
+
+	pushMgr := NewManager(&brk, str, NewMockSender(false))
+	suite.True(pushMgr.Serves(subscriptions.PushConfig{Pend: "https://www.example.com"}))
+
+	// https subscriptions are left to the push server, along with their liveness
+	pushMgr.DelegateSchemes("http", "https")
+	pushMgr.RegisterTransport(SQSScheme, sqs)
+	suite.False(pushMgr.Serves(subscriptions.PushConfig{Pend: "https://www.example.com"}))
+	suite.True(pushMgr.Serves(subscriptions.PushConfig{Pend: queue}))
+	suite.True(pushMgr.Serves(subscriptions.PushConfig{Pend: "https://www.example.com", Fanout: []string{queue}}))
+
+	str.SubList[3].PushEndpoint = "https://www.example.com"
+	pushMgr.LoadPushSubs()
+	_, err := pushMgr.Get("argo_uuid/sub4")
+	suite.NotNil(err)
+	suite.Equal([]WorkerStatus{}, pushMgr.Workers(time.Now()))
+
+	str.SubList[3].PushEndpoint = queue
+	pushMgr.LoadPushSubs()
+	_, err = pushMgr.Get("argo_uuid/sub4")
+	suite.Nil(err)
+	suite.Equal(WorkerStopped, pushMgr.Status("argo_uuid", "sub4", time.Now()).Status)
+
+	// deliveries present the subscription's authorization header
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	p.push(&brk, str)
+	suite.Equal(1, sqs.Sent[queue])
+	suite.Equal("auth-header-1", sqs.LastAuthorization)
+}
+
+func (suite *PushTestSuite) TestVerifyEndpoint() {
+	sqs := NewMockSender(false)
+	sqs.FailEndpoints = map[string]bool{"sqs://sqs.eu-west-1.amazonaws.com/123456789012/denied": true}
+	pushMgr := NewManager(&brokers.MockBroker{}, stores.NewMockStore("whatever", "argo_mgs"), nil)
+	pushMgr.RegisterTransport(SQSScheme, sqs)
+
+	suite.Nil(pushMgr.VerifyEndpoint(context.Background(), "sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams", "vhash-1"))
+	suite.True(strings.Contains(sqs.LastMsg, `"ams_verification_hash": "vhash-1"`))
+
+	suite.NotNil(pushMgr.VerifyEndpoint(context.Background(), "sqs://sqs.eu-west-1.amazonaws.com/123456789012/denied", "vhash-1"))
+	suite.Equal(errNoTransport, pushMgr.VerifyEndpoint(context.Background(), "queue://queues.example.com/ams", "vhash-1"))
+}
+
+func (suite *PushTestSuite) TestSQSAllowList() {
+	suite.True(SQSHosts("eu-west-1").MatchString("sqs.eu-west-1.amazonaws.com"))
+	suite.False(SQSHosts("eu-west-1").MatchString("sqs.us-east-1.amazonaws.com"))
+	suite.False(SQSHosts("eu-west-1").MatchString("sqs.eu-west-1.amazonaws.com.example.com"))
+	suite.True(SQSHosts("").MatchString("sqs.us-east-1.amazonaws.com"))
+	suite.False(SQSHosts("").MatchString("queues.example.com"))
+
+	allowed := ParseSQSAllowedQueues([]string{"ARGO=123456789012", "ARGO=arn:aws:sqs:eu-west-1:210987654321:ams", "invalid", "OTHER=111111111111"})
+	suite.Equal([]string{"123456789012", "arn:aws:sqs:eu-west-1:210987654321:ams"}, allowed["ARGO"])
+	suite.True(SQSQueueAllowed("sqs://sqs.eu-west-1.amazonaws.com/123456789012/any", allowed["ARGO"]))
+	suite.True(SQSQueueAllowed("sqs://sqs.eu-west-1.amazonaws.com/210987654321/ams", allowed["ARGO"]))
+	suite.False(SQSQueueAllowed("sqs://sqs.eu-west-1.amazonaws.com/210987654321/other", allowed["ARGO"]))
+	suite.False(SQSQueueAllowed("sqs://sqs.eu-west-1.amazonaws.com/111111111111/ams", allowed["ARGO"]))
+
+	// queues outside the allow-list never reach the transport, the rest of the endpoints go through the verifier as they are
+	sqs := NewMockSender(false)
+	pushMgr := NewManager(&brokers.MockBroker{}, stores.NewMockStore("whatever", "argo_mgs"), nil)
+	pushMgr.RegisterTransport(SQSScheme, sqs)
+	verifier := SQSAllowList{Allowed: allowed["ARGO"], Verifier: pushMgr}
+	suite.Equal(ErrQueueNotAllowed, verifier.VerifyEndpoint(context.Background(), "sqs://sqs.eu-west-1.amazonaws.com/111111111111/ams", "vhash-1"))
+	suite.Equal("", sqs.LastMsg)
+	suite.Nil(verifier.VerifyEndpoint(context.Background(), "sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams", "vhash-1"))
+	suite.Equal(errNoTransport, verifier.VerifyEndpoint(context.Background(), "queue://queues.example.com/ams", "vhash-1"))
+}
+
+func (suite *PushTestSuite) TestPusherMaxRetries() {
+	sndr := NewMockSender(false)
+	sndr.FailEndpoints = map[string]bool{"https://fan.example.com": true}
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].FanoutEndpoints = []string{"https://fan.example.com"}
+	str.SubList[3].MaxRetries = 1
+	pushMgr := NewManager(&brk, str, sndr)
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+
+	// the failing endpoint gets its first attempt and a single retry
+	p.push(&brk, str)
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(0), qSub.Offset)
+	suite.Equal(2, p.deliveries[0]["https://fan.example.com"].failures)
+
+	// after that it skips the message without holding back the rest of the endpoints
+	p.push(&brk, str)
+	qSub, _ = str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(1), qSub.Offset)
+	suite.True(p.deliveries[0]["https://fan.example.com"].abandoned)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+
+	// subscriptions that fan out fall back to the default max retries
+	str.SubList[3].MaxRetries = 0
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ = pushMgr.Get("argo_uuid/sub4")
+	suite.Equal(subscriptions.DefaultFanoutMaxRetries, p.sub.PushCfg.EffectiveMaxRetries())
+
+	// unverified subscriptions get no pusher
+	str.SubList[3].Verified = false
+	suite.Equal(ErrEndpointNotVerified, pushMgr.Add("argo_uuid", "sub4"))
+}
+
+func (suite *PushTestSuite) TestRetryStatus() {
+	sndr := NewMockSender(false)
+	sndr.FailEndpoints = map[string]bool{"endpoint.foo": true}
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("bXNnMQ=="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	pushMgr := NewManager(&brk, str, sndr)
+
+	_, err := pushMgr.RetryStatus("argo_uuid", "sub4")
+	suite.Equal("not found", err.Error())
+
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	p.running = true
+
+	// a pusher that hasn't failed any delivery isn't backing off
+	rs, err := pushMgr.RetryStatus("argo_uuid", "sub4")
+	suite.Nil(err)
+	suite.Equal(RetryStatus{}, rs)
+
+	// each failed round schedules the retry a rate after its end
+	before := time.Now()
+	p.push(&brk, str)
+	p.push(&brk, str)
+	rs, _ = pushMgr.RetryStatus("argo_uuid", "sub4")
+	suite.Equal(2, rs.Failures)
+	suite.False(rs.NextRetry.Before(before.Add(p.rate)))
+	suite.False(rs.NextRetry.After(time.Now().Add(p.rate)))
+
+	// stopped pushers retry nothing
+	p.running = false
+	rs, _ = pushMgr.RetryStatus("argo_uuid", "sub4")
+	suite.Equal(RetryStatus{}, rs)
+
+	// the backoff ends once the endpoint accepts the message
+	p.running = true
+	sndr.FailEndpoints = nil
+	p.push(&brk, str)
+	rs, _ = pushMgr.RetryStatus("argo_uuid", "sub4")
+	suite.Equal(RetryStatus{}, rs)
+}
+
+func (suite *PushTestSuite) TestPusherTransports() {
+	sndr := NewMockSender(false)
+	sqs := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	brk.Publish("argo_uuid.topic4", messages.New("aGVsbG8="), brokers.AcksAll, "")
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	str.SubList[3].FanoutEndpoints = []string{"sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"}
+	pushMgr := NewManager(&brk, str, sndr)
+	pushMgr.RegisterTransport("SQS", sqs)
+
+	suite.Equal(sndr, pushMgr.transport("https://www.example.com"))
+	suite.Equal(sndr, pushMgr.transport("endpoint.foo"))
+	suite.Equal(sqs, pushMgr.transport("sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"))
+	suite.Equal(errNoTransport, pushMgr.transport("amqp://broker.example.com/ams").Deliver("msg", "amqp://broker.example.com/ams"))
+
+	// each endpoint gets the message through the transport of its scheme
+	suite.Nil(pushMgr.Add("argo_uuid", "sub4"))
+	p, _ := pushMgr.Get("argo_uuid/sub4")
+	p.push(&brk, str)
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(1), qSub.Offset)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+	suite.Equal(0, sndr.Sent["sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"])
+	suite.Equal(1, sqs.Sent["sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"])
+}
+
+func (suite *PushTestSuite) TestSQSTransport() {
+
+	// the derived key of the aws signature version 4 documentation example
+	suite.Equal("f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d",
+		hex.EncodeToString(sigV4Key("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")))
+
+	suite.Equal("eu-west-1", sqsRegion("sqs.eu-west-1.amazonaws.com"))
+	suite.Equal("us-east-2", sqsRegion("us-east-2.queue.amazonaws.com"))
+	suite.Equal("", sqsRegion("localhost"))
+
+	status := http.StatusOK
+	var received *http.Request
+	var form url.Values
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		r.ParseForm()
+		form = r.PostForm
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	st := NewSQSTransport("AKIDEXAMPLE", "SECRET", "eu-west-1", 5)
+	st.Scheme = "http"
+	st.now = func() time.Time { return time.Date(2019, 5, 6, 10, 0, 0, 0, time.UTC) }
+
+	endpoint := "sqs://" + strings.TrimPrefix(srv.URL, "http://") + "/123456789012/ams"
+	suite.Nil(st.Deliver(`{"messages":[]}`, endpoint))
+	suite.Equal(http.MethodPost, received.Method)
+	suite.Equal("/123456789012/ams", received.URL.Path)
+	suite.Equal("SendMessage", form.Get("Action"))
+	suite.Equal(`{"messages":[]}`, form.Get("MessageBody"))
+	suite.Equal("20190506T100000Z", received.Header.Get("X-Amz-Date"))
+	suite.True(strings.HasPrefix(received.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20190506/eu-west-1/sqs/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature="))
+
+	status = http.StatusForbidden
+	suite.EqualError(st.Deliver("msg", endpoint), "Queue Responded: not delivered")
+
+	suite.EqualError(st.Deliver("msg", "https://www.example.com/queue"), "invalid sqs endpoint")
+
+	// without a configured region it has to be part of the host
+	st.Region = ""
+	suite.EqualError(st.Deliver("msg", endpoint), "could not determine the region of the sqs endpoint")
+}
+
+func (suite *PushTestSuite) TestTestPush() {
+	sndr := NewMockSender(false)
+	sndr.FailEndpoints = map[string]bool{"https://fan.example.com": true}
+	sqs := NewMockSender(false)
+	brk := brokers.MockBroker{}
+	str := stores.NewMockStore("whatever", "argo_mgs")
+	pushMgr := NewManager(&brk, str, sndr)
+	pushMgr.RegisterTransport("SQS", sqs)
+
+	sub := subscriptions.Subscription{
+		FullName: "/projects/ARGO/subscriptions/sub4",
+		PushCfg: subscriptions.PushConfig{
+			Pend:                "endpoint.foo",
+			Verified:            true,
+			AuthorizationHeader: subscriptions.AuthorizationHeader{Type: "autogen", Value: "auth-header-1"},
+			Fanout:              []string{"https://fan.example.com", "sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"},
+		},
+	}
+
+	reports := pushMgr.TestPush(sub, time.Date(2019, 5, 6, 10, 0, 0, 0, time.UTC))
+	suite.Equal(3, len(reports))
+	suite.Equal("endpoint.foo", reports[0].Endpoint)
+	suite.Equal(http.StatusOK, reports[0].StatusCode)
+	suite.Nil(reports[0].Err)
+	suite.Equal("auth-header-1", sndr.LastAuthorization)
+
+	// the verification covers the fanout endpoints as well, each one reporting on its own
+	suite.Equal("https://fan.example.com", reports[1].Endpoint)
+	suite.EqualError(reports[1].Err, "endpoint not reachable")
+	suite.Nil(reports[2].Err)
+	suite.Equal(1, sqs.Sent["sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"])
+
+	// the synthetic message is marked as a test and the subscription's offset is left untouched
+	suite.True(strings.Contains(sndr.LastMsg, `"ams_test_push": "true"`))
+	suite.True(strings.Contains(sndr.LastMsg, `"subscription": "/projects/ARGO/subscriptions/sub4"`))
+	qSub, _ := str.QueryOneSub("argo_uuid", "sub4")
+	suite.Equal(int64(0), qSub.Offset)
+
+	// the push endpoint is tested only once verified
+	sub.PushCfg.Verified = false
+	reports = pushMgr.TestPush(sub, time.Now())
+	suite.Equal(ErrEndpointNotVerified, reports[0].Err)
+	suite.Equal(ErrEndpointNotVerified, reports[1].Err)
+	suite.Equal(ErrEndpointNotVerified, reports[2].Err)
+	suite.Equal(1, sndr.Sent["endpoint.foo"])
+	suite.Equal(1, sqs.Sent["sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams"])
+
+	// without a transport an http endpoint is tested with a direct request
+	authorization := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(strings.Repeat("a", MaxReportBodySize+10)))
+	}))
+	defer srv.Close()
+
+	sub.PushCfg = subscriptions.PushConfig{
+		Pend:                srv.URL,
+		Verified:            true,
+		AuthorizationHeader: subscriptions.AuthorizationHeader{Type: "autogen", Value: "auth-header-1"},
+	}
+	reports = (&Manager{}).TestPush(sub, time.Now())
+	suite.Equal(1, len(reports))
+	suite.Equal(http.StatusCreated, reports[0].StatusCode)
+	suite.Equal(strings.Repeat("a", MaxReportBodySize), reports[0].Body)
+	suite.Nil(reports[0].Err)
+	suite.Equal("auth-header-1", authorization)
+
+	// a verified endpoint without a transport can't be tested
+	sub.PushCfg = subscriptions.PushConfig{Pend: "amqp://broker.example.com/ams", Verified: true}
+	reports = (&Manager{}).TestPush(sub, time.Now())
+	suite.Equal(errNoTransport, reports[0].Err)
+}
+
 func TestPushTestSuite(t *testing.T) {
 	suite.Run(t, new(PushTestSuite))
 }
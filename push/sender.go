@@ -4,28 +4,32 @@ import (
 	"bytes"
 	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 )
 
-// Sender is inteface for sending messages to remote endpoints
-type Sender interface {
-	Send(msg string, endpoint string) error
-}
-
-// HTTPSender sends msgs through http
+// HTTPSender is the transport that delivers msgs to http endpoints
 type HTTPSender struct {
 	Client http.Client
 }
 
-// MockSender mocks sending messages to remote endpoints
+// MockSender mocks the delivery of messages to remote endpoints
 type MockSender struct {
-	ClientFail   bool
-	LastMsg      string
-	LastEndpoint string
+	mu            sync.Mutex
+	ClientFail    bool
+	FailEndpoints map[string]bool
+	LastMsg       string
+	LastEndpoint  string
+	// LastAuthorization is the authorization header of the last authorized delivery
+	LastAuthorization string
+	Sent              map[string]int
 }
 
+// DeliveryTimeout is the number of seconds a delivery of the push manager to an endpoint can take
+const DeliveryTimeout = 10
+
 // NewHTTPSender creates a new HTTPSender. Specify timeout in seconds
 func NewHTTPSender(timeoutSec int) *HTTPSender {
 	timeout := time.Duration(timeoutSec) * time.Second
@@ -34,17 +38,42 @@ func NewHTTPSender(timeoutSec int) *HTTPSender {
 	return &snd
 }
 
-// Send sends a message through HTTP
-func (hs *HTTPSender) Send(msg string, endpoint string) error {
+// Deliver sends a message through HTTP
+func (hs *HTTPSender) Deliver(msg string, endpoint string) error {
+	return hs.DeliverWithReport(msg, endpoint).Err
+}
+
+// DeliverWithReport sends a message through HTTP and reports how the endpoint responded
+func (hs *HTTPSender) DeliverWithReport(msg string, endpoint string) DeliveryReport {
+	return hs.DeliverAuthorized(msg, endpoint, "")
+}
+
+// DeliverAuthorized sends a message through HTTP along with the given authorization header, if any,
+// and reports how the endpoint responded
+func (hs *HTTPSender) DeliverAuthorized(msg string, endpoint string, authorization string) DeliveryReport {
+	report := DeliveryReport{Endpoint: endpoint}
+
 	var jsonStr = []byte(msg)
 	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonStr))
+	if err != nil {
+		report.Err = err
+		return report
+	}
 	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
 	log.Debug("Sending to endpoint:", endpoint)
 	log.Debug("message contents:", msg)
 
+	start := time.Now()
 	resp, err := hs.Client.Do(req)
+	report.Latency = time.Since(start)
 
 	if err == nil {
+		defer resp.Body.Close()
+		report.StatusCode = resp.StatusCode
+		report.Body = readReportBody(resp.Body)
 
 		if resp.StatusCode != 200 && resp.StatusCode != 201 && resp.StatusCode != 204 && resp.StatusCode != 102 {
 			err = errors.New("Endpoint Responded: not delivered")
@@ -54,7 +83,8 @@ func (hs *HTTPSender) Send(msg string, endpoint string) error {
 		log.Debug(err.Error())
 	}
 
-	return err
+	report.Err = err
+	return report
 }
 
 // NewMockSender creates a new Mock sender
@@ -64,14 +94,40 @@ func NewMockSender(fail bool) *MockSender {
 	return &snd
 }
 
-// Send sends a message through HTTP
-func (ms *MockSender) Send(msg string, endpoint string) error {
-	if ms.ClientFail == true {
+// Deliver records the delivery of a message, unless the client or the endpoint is set to fail
+func (ms *MockSender) Deliver(msg string, endpoint string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.ClientFail == true || ms.FailEndpoints[endpoint] {
 		return errors.New("endpoint not reachable")
 	}
 
 	ms.LastMsg = msg
 	ms.LastEndpoint = endpoint
+	if ms.Sent == nil {
+		ms.Sent = make(map[string]int)
+	}
+	ms.Sent[endpoint]++
 
 	return nil
 }
+
+// DeliverWithReport records the delivery of a message, reporting a successful response unless it fails
+func (ms *MockSender) DeliverWithReport(msg string, endpoint string) DeliveryReport {
+	if err := ms.Deliver(msg, endpoint); err != nil {
+		return DeliveryReport{Endpoint: endpoint, Err: err}
+	}
+	return DeliveryReport{Endpoint: endpoint, StatusCode: http.StatusOK}
+}
+
+// DeliverAuthorized records the delivery of a message along with its authorization header
+func (ms *MockSender) DeliverAuthorized(msg string, endpoint string, authorization string) DeliveryReport {
+	report := ms.DeliverWithReport(msg, endpoint)
+	if report.Err == nil {
+		ms.mu.Lock()
+		ms.LastAuthorization = authorization
+		ms.mu.Unlock()
+	}
+	return report
+}
@@ -0,0 +1,268 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/subscriptions"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// SQSScheme is the scheme of the push endpoints that are aws sqs queues,
+	// e.g. sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams-queue
+	SQSScheme = "sqs"
+	// sqsAPIVersion is the version of the sqs query api the transport speaks
+	sqsAPIVersion = "2012-11-05"
+)
+
+// SQSTransport delivers msgs to aws sqs queues, by sending each msg as the body of a SendMessage request
+// to the queue url that the endpoint declares over the sqs scheme
+type SQSTransport struct {
+	Client          http.Client
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is only needed by temporary credentials
+	SessionToken string
+	// Region of the queues, if empty it is extracted from the host of each queue
+	Region string
+	// Scheme the queue urls are reached over, https unless set otherwise
+	Scheme string
+	now    func() time.Time
+}
+
+// NewSQSTransport creates a new SQSTransport with the given credentials. Specify timeout in seconds
+func NewSQSTransport(accessKeyID string, secretAccessKey string, region string, timeoutSec int) *SQSTransport {
+	return &SQSTransport{
+		Client:          http.Client{Timeout: time.Duration(timeoutSec) * time.Second},
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		Region:          region,
+		Scheme:          "https",
+		now:             time.Now,
+	}
+}
+
+// Deliver sends the message to the sqs queue of the endpoint
+func (st *SQSTransport) Deliver(msg string, endpoint string) error {
+	return st.DeliverWithReport(msg, endpoint).Err
+}
+
+// DeliverWithReport sends the message to the sqs queue of the endpoint and reports how the queue responded
+func (st *SQSTransport) DeliverWithReport(msg string, endpoint string) DeliveryReport {
+	report := DeliveryReport{Endpoint: endpoint}
+
+	queue, err := url.Parse(endpoint)
+	if err != nil || queue.Scheme != SQSScheme || queue.Host == "" || queue.Path == "" {
+		report.Err = errors.New("invalid sqs endpoint")
+		return report
+	}
+
+	queue.Scheme = st.Scheme
+	if queue.Scheme == "" {
+		queue.Scheme = "https"
+	}
+
+	region := st.Region
+	if region == "" {
+		region = sqsRegion(queue.Hostname())
+	}
+	if region == "" {
+		report.Err = errors.New("could not determine the region of the sqs endpoint")
+		return report
+	}
+
+	form := url.Values{}
+	form.Set("Action", "SendMessage")
+	form.Set("MessageBody", msg)
+	form.Set("Version", sqsAPIVersion)
+	body := form.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, queue.String(), bytes.NewBufferString(body))
+	if err != nil {
+		report.Err = err
+		return report
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	now := time.Now
+	if st.now != nil {
+		now = st.now
+	}
+	st.sign(req, body, region, now().UTC())
+
+	log.Debug("Sending to sqs queue:", queue.String())
+
+	start := time.Now()
+	resp, err := st.Client.Do(req)
+	report.Latency = time.Since(start)
+	if err != nil {
+		log.Debug(err.Error())
+		report.Err = err
+		return report
+	}
+	defer resp.Body.Close()
+
+	report.StatusCode = resp.StatusCode
+	report.Body = readReportBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		report.Err = errors.New("Queue Responded: not delivered")
+		return report
+	}
+
+	log.Debug("message Delivered")
+	return report
+}
+
+// sign signs the request with aws signature version 4
+func (st *SQSTransport) sign(req *http.Request, body string, region string, t time.Time) {
+
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if st.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", st.SessionToken)
+	}
+
+	signedHeaders := []string{"content-type", "host", "x-amz-date"}
+	if st.SessionToken != "" {
+		signedHeaders = append(signedHeaders, "x-amz-security-token")
+	}
+
+	canonicalHeaders := ""
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders += h + ":" + strings.TrimSpace(value) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		strings.Join(signedHeaders, ";"),
+		hexSHA256(body),
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/sqs/aws4_request", date, region)
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hexSHA256(canonicalRequest)}, "\n")
+	signature := hex.EncodeToString(hmacSHA256(sigV4Key(st.SecretAccessKey, date, region, "sqs"), stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		st.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// SQSHosts matches the hosts of the sqs queues of the region, e.g. sqs.eu-west-1.amazonaws.com, or of every region if empty
+func SQSHosts(region string) *regexp.Regexp {
+	pattern := `[a-z0-9-]+`
+	if region != "" {
+		pattern = regexp.QuoteMeta(strings.ToLower(region))
+	}
+	return regexp.MustCompile(`^sqs\.` + pattern + `\.amazonaws\.com$`)
+}
+
+// ErrQueueNotAllowed is returned when verifying an sqs endpoint whose queue is not in the allowed queues of the project
+var ErrQueueNotAllowed = errors.New("The queue is not in the allowed queues of the project")
+
+// ParseSQSAllowedQueues parses the queues each project may push to, declared as project_name=account_id
+// or project_name=queue_arn, into the allowed entries of each project
+func ParseSQSAllowedQueues(declared []string) map[string][]string {
+	allowed := map[string][]string{}
+	for _, d := range declared {
+		kv := strings.SplitN(d, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			log.Errorf("Invalid allowed sqs queue %v, it should be declared as project_name=account_id or project_name=queue_arn", d)
+			continue
+		}
+		allowed[kv[0]] = append(allowed[kv[0]], kv[1])
+	}
+	return allowed
+}
+
+// SQSQueueAllowed checks that the queue of the sqs endpoint, e.g. sqs://sqs.eu-west-1.amazonaws.com/123456789012/ams-queue,
+// is allowed by one of the entries, either the id of the aws account that owns it or its arn,
+// e.g. arn:aws:sqs:eu-west-1:123456789012:ams-queue
+func SQSQueueAllowed(endpoint string, allowed []string) bool {
+	queue, err := url.Parse(endpoint)
+	if err != nil || queue.Scheme != SQSScheme {
+		return false
+	}
+
+	path := strings.Split(strings.Trim(queue.Path, "/"), "/")
+	region := sqsRegion(queue.Hostname())
+	if len(path) != 2 || region == "" {
+		return false
+	}
+
+	arn := fmt.Sprintf("arn:aws:sqs:%s:%s:%s", region, path[0], path[1])
+	for _, a := range allowed {
+		if a == path[0] || a == arn {
+			return true
+		}
+	}
+	return false
+}
+
+// SQSAllowList verifies the sqs endpoints of a project only when their queues are in the project's allowed queues.
+// Every delivery to a queue is signed with the credentials of the service, so a delivery that gets through doesn't
+// show that the project owns the queue, only that the service can write to it. The service admin grants the queues
+// each project may push to instead, the rest of the endpoints are left to the verifier as they are
+type SQSAllowList struct {
+	Allowed  []string
+	Verifier subscriptions.EndpointVerifier
+}
+
+// VerifyEndpoint rejects the sqs endpoints whose queue isn't allowed and verifies the rest through the verifier
+func (l SQSAllowList) VerifyEndpoint(ctx context.Context, endpoint string, vhash string) error {
+	if u, err := url.Parse(endpoint); err == nil && strings.ToLower(u.Scheme) == SQSScheme && !SQSQueueAllowed(endpoint, l.Allowed) {
+		return ErrQueueNotAllowed
+	}
+	return l.Verifier.VerifyEndpoint(ctx, endpoint, vhash)
+}
+
+// sqsRegion extracts the region from the host of an sqs queue, e.g. sqs.eu-west-1.amazonaws.com
+// or eu-west-1.queue.amazonaws.com
+func sqsRegion(host string) string {
+	parts := strings.Split(host, ".")
+	if len(parts) >= 4 && parts[0] == "sqs" {
+		return parts[1]
+	}
+	if len(parts) >= 4 && parts[1] == "queue" {
+		return parts[0]
+	}
+	return ""
+}
+
+// sigV4Key derives the signing key of aws signature version 4 for the given date, region and service
+func sigV4Key(secret string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
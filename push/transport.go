@@ -0,0 +1,117 @@
+package push
+
+import (
+	"context"
+	b64 "encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/messages"
+)
+
+// Transport delivers messages to the push endpoints of a messaging system, e.g. http endpoints or queues
+type Transport interface {
+	Deliver(msg string, endpoint string) error
+}
+
+// MaxReportBodySize is the max number of bytes of an endpoint's response kept in a delivery report
+const MaxReportBodySize = 512
+
+// DeliveryReport describes how an endpoint responded to the delivery of a message
+type DeliveryReport struct {
+	Endpoint string
+	// StatusCode is the status the endpoint responded with, zero if the transport has none to report
+	StatusCode int
+	Latency    time.Duration
+	// Body holds the first MaxReportBodySize bytes of the endpoint's response
+	Body string
+	Err  error
+}
+
+// ReportingTransport is a transport that can also report how the endpoints respond to the deliveries
+type ReportingTransport interface {
+	Transport
+	DeliverWithReport(msg string, endpoint string) DeliveryReport
+}
+
+// AuthorizingTransport is a reporting transport that can also present the authorization header of the push subscription
+// to the endpoints
+type AuthorizingTransport interface {
+	ReportingTransport
+	DeliverAuthorized(msg string, endpoint string, authorization string) DeliveryReport
+}
+
+// readReportBody reads the snippet of a response body kept in a delivery report
+func readReportBody(body io.Reader) string {
+	snippet, _ := ioutil.ReadAll(io.LimitReader(body, MaxReportBodySize))
+	return string(snippet)
+}
+
+// errNoTransport is returned for endpoints that no transport can deliver to
+var errNoTransport = errors.New("no transport for endpoint")
+
+// ErrEndpointNotVerified is reported for the endpoints whose ownership hasn't been verified, so they are not tested
+var ErrEndpointNotVerified = errors.New("endpoint has not been verified")
+
+// RegisterTransport makes the manager deliver to the endpoints of the given scheme, e.g. sqs, through the transport.
+// Transports should be registered before any pusher is launched
+func (mgr *Manager) RegisterTransport(scheme string, t Transport) {
+	if mgr.transports == nil {
+		mgr.transports = map[string]Transport{}
+	}
+	mgr.transports[strings.ToLower(scheme)] = t
+}
+
+// transport selects the transport that delivers to the endpoint by the endpoint's scheme.
+// Endpoints without a scheme are delivered to through the default transport
+func (mgr *Manager) transport(endpoint string) Transport {
+	if u, err := url.Parse(endpoint); err == nil && u.Scheme != "" {
+		if t, ok := mgr.transports[strings.ToLower(u.Scheme)]; ok {
+			return t
+		}
+		return noTransport{}
+	}
+	if mgr.defaultTransport == nil {
+		return noTransport{}
+	}
+	return mgr.defaultTransport
+}
+
+// VerificationHashAttribute carries the verification hash of a subscription in the verification messages
+// delivered to the endpoints that can't serve the hash themselves, e.g. queues
+const VerificationHashAttribute = "ams_verification_hash"
+
+// VerifyEndpoint checks that an endpoint that isn't reached over http can be delivered to, by delivering a message
+// carrying the verification hash to it, so that the owner of the endpoint can tell which subscription it was registered to.
+// Deliveries to such endpoints, e.g. sqs queues, are made with the credentials of the service, so a successful one
+// doesn't show who owns the endpoint, see SQSAllowList for restricting the queues each project may verify
+func (mgr *Manager) VerifyEndpoint(ctx context.Context, endpoint string, vhash string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg := messages.Message{
+		ID:   "0",
+		Attr: messages.Attributes{VerificationHashAttribute: vhash},
+		Data: b64.StdEncoding.EncodeToString([]byte("push endpoint verification")),
+	}
+	pMsg := messages.PushMsg{Msg: msg}
+	payload, err := pMsg.ExportJSON()
+	if err != nil {
+		return err
+	}
+
+	return mgr.transport(endpoint).Deliver(payload, endpoint)
+}
+
+// noTransport fails every delivery, so that the messages are retried once a transport is available
+type noTransport struct{}
+
+// Deliver fails the delivery
+func (noTransport) Deliver(msg string, endpoint string) error {
+	return errNoTransport
+}
@@ -40,6 +40,14 @@ func NewRouting(cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *o
 
 	tokenExtractStrategy := handlers.GetRequestTokenExtractStrategy(cfg.AuthOption())
 
+	// keep track of the operations that roles grant access to
+	operations := make([]string, 0, len(ar.Routes))
+	for _, route := range ar.Routes {
+		if !unauthorizedRoutes[route.Name] {
+			operations = append(operations, route.Name)
+		}
+	}
+
 	// For each route
 	for _, route := range ar.Routes {
 
@@ -48,15 +56,18 @@ func NewRouting(cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *o
 		handler = route.Handler
 
 		handler = handlers.WrapLog(handler, route.Name)
+		handler = handlers.WrapMaintenance(handler, cfg, route.Method, route.Name)
+		handler = handlers.WrapContentType(handler, route.Method)
 
 		// skip authentication/authorization for the health status and profile api calls
-		if route.Name != "ams:healthStatus" && "users:profile" != route.Name && route.Name != "version:list" {
+		if !unauthorizedRoutes[route.Name] {
 			handler = handlers.WrapAuthorize(handler, route.Name, tokenExtractStrategy)
 			handler = handlers.WrapAuthenticate(handler, tokenExtractStrategy)
 		}
 
 		handler = handlers.WrapValidate(handler)
-		handler = handlers.WrapConfig(handler, cfg, brk, str, mgr, c)
+		handler = handlers.WrapConfig(handler, cfg, brk, str, mgr, c, operations)
+		handler = handlers.WrapTimeout(handler, cfg, route.Name)
 
 		ar.Router.
 			PathPrefix("/v1").
@@ -71,12 +82,23 @@ func NewRouting(cfg *config.APICfg, brk brokers.Broker, str stores.Store, mgr *o
 	return &ar
 }
 
+// unauthorizedRoutes are served without authenticating and authorizing the requests
+var unauthorizedRoutes = map[string]bool{
+	"ams:healthStatus": true,
+	"users:profile":    true,
+	"version:list":     true,
+}
+
 // Global list populated with default routes
 var defaultRoutes = []APIRoute{
 
 	{"ams:metrics", "GET", "/metrics", handlers.OpMetrics},
 	{"ams:healthStatus", "GET", "/status", handlers.HealthCheck},
+	{"ams:maintenance", "POST", "/maintenance", handlers.MaintenanceToggle},
 	{"ams:vaMetrics", "GET", "/metrics/va_metrics", handlers.VaMetrics},
+	{"ams:offsetReconciliation", "GET", "/offsets/reconciliation", handlers.OffsetReconciliation},
+	{"ams:offsetSnapshots", "GET", "/offsets/snapshots", handlers.OffsetSnapshots},
+	{"ams:restoreOffsetSnapshot", "POST", "/offsets/snapshots/{snapshot}:restore", handlers.OffsetSnapshotRestore},
 	{"users:byToken", "GET", "/users:byToken/{token}", handlers.UserListByToken},
 	{"users:byUUID", "GET", "/users:byUUID/{uuid}", handlers.UserListByUUID},
 	{"users:list", "GET", "/users", handlers.UserListAll},
@@ -86,6 +108,12 @@ var defaultRoutes = []APIRoute{
 	{"users:create", "POST", "/users/{user}", handlers.UserCreate},
 	{"users:update", "PUT", "/users/{user}", handlers.UserUpdate},
 	{"users:delete", "DELETE", "/users/{user}", handlers.UserDelete},
+	{"roles:list", "GET", "/roles", handlers.RoleListAll},
+	{"operations:list", "GET", "/operations", handlers.OperationListAll},
+	{"roles:show", "GET", "/roles/{operation}", handlers.RoleListByOperation},
+	{"roles:create", "POST", "/roles/{operation}", handlers.RoleCreate},
+	{"roles:update", "PUT", "/roles/{operation}", handlers.RoleUpdate},
+	{"roles:delete", "DELETE", "/roles/{operation}", handlers.RoleDelete},
 	{"registrations:newUser", "POST", "/registrations", handlers.RegisterUser},
 	{"registrations:acceptNewUser", "POST", "/registrations/{uuid}:accept", handlers.AcceptRegisterUser},
 	{"registrations:declineNewUser", "POST", "/registrations/{uuid}:decline", handlers.DeclineRegisterUser},
@@ -93,40 +121,71 @@ var defaultRoutes = []APIRoute{
 	{"registrations:list", "GET", "/registrations", handlers.ListAllRegistrations},
 	{"projects:list", "GET", "/projects", handlers.ProjectListAll},
 	{"projects:metrics", "GET", "/projects/{project}:metrics", handlers.ProjectMetrics},
+	{"projects:exportAcls", "GET", "/projects/{project}:exportAcls", handlers.ProjectExportACLs},
+	{"projects:publish", "POST", "/projects/{project}:publish", handlers.ProjectPublish},
+	{"projects:importAcls", "POST", "/projects/{project}:importAcls", handlers.ProjectImportACLs},
+	{"projects:export", "GET", "/projects/{project}:export", handlers.ProjectExport},
+	{"projects:import", "POST", "/projects/{project}:import", handlers.ProjectImport},
 	{"projects:addUser", "POST", "/projects/{project}/members/{user}:add", handlers.ProjectUserAdd},
 	{"projects:removeUser", "POST", "/projects/{project}/members/{user}:remove", handlers.ProjectUserRemove},
 	{"projects:showUser", "GET", "/projects/{project}/members/{user}", handlers.ProjectUserListOne},
 	{"projects:createUser", "POST", "/projects/{project}/members/{user}", handlers.ProjectUserCreate},
 	{"projects:updateUser", "PUT", "/projects/{project}/members/{user}", handlers.ProjectUserUpdate},
 	{"projects:listUsers", "GET", "/projects/{project}/members", handlers.ProjectListUsers},
+	{"projects:userAcls", "GET", "/projects/{project}/users/{user}:acls", handlers.ProjectUserACLs},
 	{"projects:show", "GET", "/projects/{project}", handlers.ProjectListOne},
 	{"projects:create", "POST", "/projects/{project}", handlers.ProjectCreate},
 	{"projects:update", "PUT", "/projects/{project}", handlers.ProjectUpdate},
 	{"projects:delete", "DELETE", "/projects/{project}", handlers.ProjectDelete},
 	{"subscriptions:list", "GET", "/projects/{project}/subscriptions", handlers.SubListAll},
+	{"subscriptions:stalled", "GET", "/projects/{project}/subscriptions:stalled", handlers.SubListStalled},
 	{"subscriptions:listByTopic", "GET", "/projects/{project}/topics/{topic}/subscriptions", handlers.ListSubsByTopic},
 	{"subscriptions:offsets", "GET", "/projects/{project}/subscriptions/{subscription}:offsets", handlers.SubGetOffsets},
 	{"subscriptions:timeToOffset", "GET", "/projects/{project}/subscriptions/{subscription}:timeToOffset", handlers.SubTimeToOffset},
 	{"subscriptions:acl", "GET", "/projects/{project}/subscriptions/{subscription}:acl", handlers.SubACL},
+	{"subscriptions:aclHistory", "GET", "/projects/{project}/subscriptions/{subscription}:aclHistory", handlers.SubACLHistory},
+	{"subscriptions:config", "GET", "/projects/{project}/subscriptions/{subscription}:config", handlers.SubConfig},
+	{"subscriptions:compare", "GET", "/projects/{project}/subscriptions/{subscription}:compare", handlers.SubCompare},
 	{"subscriptions:metrics", "GET", "/projects/{project}/subscriptions/{subscription}:metrics", handlers.SubMetrics},
+	{"subscriptions:outstanding", "GET", "/projects/{project}/subscriptions/{subscription}:outstanding", handlers.SubOutstanding},
+	{"subscriptions:stream", "GET", "/projects/{project}/subscriptions/{subscription}:stream", handlers.SubStream},
 	{"subscriptions:show", "GET", "/projects/{project}/subscriptions/{subscription}", handlers.SubListOne},
 	{"subscriptions:create", "PUT", "/projects/{project}/subscriptions/{subscription}", handlers.SubCreate},
 	{"subscriptions:delete", "DELETE", "/projects/{project}/subscriptions/{subscription}", handlers.SubDelete},
 	{"subscriptions:pull", "POST", "/projects/{project}/subscriptions/{subscription}:pull", handlers.SubPull},
 	{"subscriptions:acknowledge", "POST", "/projects/{project}/subscriptions/{subscription}:acknowledge", handlers.SubAck},
 	{"subscriptions:verifyPushEndpoint", "POST", "/projects/{project}/subscriptions/{subscription}:verifyPushEndpoint", handlers.SubVerifyPushEndpoint},
+	{"subscriptions:testPush", "POST", "/projects/{project}/subscriptions/{subscription}:testPush", handlers.SubTestPush},
+	{"subscriptions:nextRetry", "GET", "/projects/{project}/subscriptions/{subscription}:nextRetry", handlers.SubNextRetry},
 	{"subscriptions:modifyAckDeadline", "POST", "/projects/{project}/subscriptions/{subscription}:modifyAckDeadline", handlers.SubModAck},
+	{"subscriptions:modifyMaxConcurrentPulls", "POST", "/projects/{project}/subscriptions/{subscription}:modifyMaxConcurrentPulls", handlers.SubModMaxConcurrentPulls},
+	{"subscriptions:modifyDefaultMaxMessages", "POST", "/projects/{project}/subscriptions/{subscription}:modifyDefaultMaxMessages", handlers.SubModDefaultMaxMessages},
+	{"subscriptions:modifyIsolationLevel", "POST", "/projects/{project}/subscriptions/{subscription}:modifyIsolationLevel", handlers.SubModIsolationLevel},
 	{"subscriptions:modifyPushConfig", "POST", "/projects/{project}/subscriptions/{subscription}:modifyPushConfig", handlers.SubModPush},
 	{"subscriptions:modifyOffset", "POST", "/projects/{project}/subscriptions/{subscription}:modifyOffset", handlers.SubSetOffset},
+	{"subscriptions:replay", "POST", "/projects/{project}/subscriptions/{subscription}:replay", handlers.SubReplay},
+	{"subscriptions:clone", "POST", "/projects/{project}/subscriptions/{subscription}:clone", handlers.SubClone},
 	{"subscriptions:modifyAcl", "POST", "/projects/{project}/subscriptions/{subscription}:modifyAcl", handlers.SubModACL},
+	{"subscriptions:drain", "POST", "/projects/{project}/subscriptions/{subscription}:drain", handlers.SubDrain},
+	{"subscriptions:resetAckedMessages", "POST", "/projects/{project}/subscriptions/{subscription}:resetAckedMessages", handlers.SubResetAckedMessages},
+	{"subscriptions:updateLabels", "POST", "/projects/{project}/subscriptions/{subscription}:updateLabels", handlers.SubUpdateLabels},
 	{"topics:list", "GET", "/projects/{project}/topics", handlers.TopicListAll},
 	{"topics:acl", "GET", "/projects/{project}/topics/{topic}:acl", handlers.TopicACL},
+	{"topics:aclHistory", "GET", "/projects/{project}/topics/{topic}:aclHistory", handlers.TopicACLHistory},
 	{"topics:metrics", "GET", "/projects/{project}/topics/{topic}:metrics", handlers.TopicMetrics},
+	{"topics:config", "GET", "/projects/{project}/topics/{topic}:config", handlers.TopicBrokerConfig},
+	{"topics:tail", "GET", "/projects/{project}/topics/{topic}:tail", handlers.TopicTail},
+	{"topics:search", "GET", "/projects/{project}/topics/{topic}:search", handlers.TopicSearch},
 	{"topics:show", "GET", "/projects/{project}/topics/{topic}", handlers.TopicListOne},
+	{"topics:rename", "PUT", "/projects/{project}/topics/{topic}:rename", handlers.TopicRename},
 	{"topics:create", "PUT", "/projects/{project}/topics/{topic}", handlers.TopicCreate},
 	{"topics:delete", "DELETE", "/projects/{project}/topics/{topic}", handlers.TopicDelete},
 	{"topics:publish", "POST", "/projects/{project}/topics/{topic}:publish", handlers.TopicPublish},
 	{"topics:modifyAcl", "POST", "/projects/{project}/topics/{topic}:modifyAcl", handlers.TopicModACL},
+	{"topics:updateLabels", "POST", "/projects/{project}/topics/{topic}:updateLabels", handlers.TopicUpdateLabels},
+	{"topics:modifyPartitionKey", "POST", "/projects/{project}/topics/{topic}:modifyPartitionKey", handlers.TopicModPartitionKey},
+	{"topics:undelete", "POST", "/projects/{project}/topics/{topic}:undelete", handlers.TopicUndelete},
+	{"topics:purge", "POST", "/projects/{project}/topics/{topic}:purge", handlers.TopicPurge},
 	{"schemas:validateMessage", "POST", "/projects/{project}/schemas/{schema}:validate", handlers.SchemaValidateMessage},
 	{"schemas:create", "POST", "/projects/{project}/schemas/{schema}", handlers.SchemaCreate},
 	{"schemas:show", "GET", "/projects/{project}/schemas/{schema}", handlers.SchemaListOne},
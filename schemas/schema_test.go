@@ -476,7 +476,7 @@ func (suite *SchemasTestSuite) TestDelete() {
 
 	e1 := Delete("schema_uuid_1", store)
 	sl, _ := Find("argo_uuid", "schema_uuid_1", "", store)
-	qtd, _, _, _ := store.QueryTopics("argo_uuid", "", "topic2", "", 1)
+	qtd, _, _, _ := store.QueryTopics("argo_uuid", "", "topic2", "", 1, false, nil, "")
 	suite.Equal([]Schema{}, sl.Schemas)
 	suite.Equal("", qtd[0].SchemaUUID)
 	suite.Nil(e1)
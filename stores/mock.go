@@ -4,7 +4,11 @@ import (
 	"errors"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 )
 
 // MockStore holds configuration
@@ -15,6 +19,12 @@ type MockStore struct {
 	SubList            []QSub
 	TopicList          []QTopic
 	DailyTopicMsgCount []QDailyTopicMsgCount
+	OffsetTimes        []QOffsetTime
+	TopicSamples       []QTopicSample
+	OffsetSnapshots    []QOffsetSnapshot
+	ACLHistory         []QACLChange
+	AckedIDs           []QAckedIDs
+	PullLeases         []QPullLeases
 	ProjectList        []QProject
 	UserList           []QUser
 	RoleList           []QRole
@@ -50,6 +60,32 @@ func (mk *MockStore) SubscriptionsCount(startDate, endDate time.Time) (int, erro
 	return counter, nil
 }
 
+// CountTopicsByProject returns the amount of topics that belong to the given project
+func (mk *MockStore) CountTopicsByProject(projectUUID string) (int64, error) {
+
+	counter := int64(0)
+	for _, t := range mk.visibleTopics(false) {
+		if t.ProjectUUID == projectUUID {
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
+// CountSubsByProject returns the amount of subscriptions that belong to the given project
+func (mk *MockStore) CountSubsByProject(projectUUID string) (int64, error) {
+
+	counter := int64(0)
+	for _, sub := range mk.SubList {
+		if sub.ProjectUUID == projectUUID {
+			counter++
+		}
+	}
+
+	return counter, nil
+}
+
 func (mk *MockStore) UsersCount(startDate, endDate time.Time) (int, error) {
 
 	counter := 0
@@ -231,6 +267,50 @@ func (mk *MockStore) GetAllRoles() []string {
 	return []string{"service_admin", "admin", "project_admin", "viewer", "consumer", "producer", "publisher", "push_worker"}
 }
 
+// QueryRoles returns the role mappings of a specific operation or of all operations if the operation is empty
+func (mk *MockStore) QueryRoles(operation string) ([]QRole, error) {
+
+	results := []QRole{}
+
+	for _, item := range mk.RoleList {
+		if operation == "" || item.Name == operation {
+			results = append(results, item)
+		}
+	}
+
+	return results, nil
+}
+
+// InsertRole inserts a new mapping between an operation and the roles that grant access to it
+func (mk *MockStore) InsertRole(operation string, roles []string) error {
+	mk.RoleList = append(mk.RoleList, QRole{Name: operation, Roles: roles})
+	return nil
+}
+
+// UpdateRole updates the roles that grant access to an operation
+func (mk *MockStore) UpdateRole(operation string, roles []string) error {
+	for i, item := range mk.RoleList {
+		if item.Name == operation {
+			mk.RoleList[i].Roles = roles
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+// RemoveRole removes the mapping of an operation to its roles
+func (mk *MockStore) RemoveRole(operation string) error {
+	for i, item := range mk.RoleList {
+		if item.Name == operation {
+			mk.RoleList = append(mk.RoleList[:i], mk.RoleList[i+1:]...)
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // UpdateUserToken updates user's token
 func (mk *MockStore) UpdateUserToken(uuid string, token string) error {
 	for i, item := range mk.UserList {
@@ -329,40 +409,28 @@ func (mk *MockStore) UpdateUser(uuid, fname, lname, org, desc string, projects [
 }
 
 // HasUsers accepts a user array of usernames and returns the not found
-func (mk *MockStore) HasUsers(projectUUID string, users []string) (bool, []string) {
-
-	var notFound []string
-
-	// for each given username
-	for _, username := range users {
-		found := false
-		// loop through all found users
-		for _, user := range mk.UserList {
-			if username == user.Name {
-				found = true
-			}
-		}
-		// if not found add it to the notFound
-		if !found {
-			notFound = append(notFound, username)
-		}
+func (mk *MockStore) HasUsers(projectUUID string, users []string, caseSensitive bool) (bool, []string) {
 
+	names := []string{}
+	for _, user := range mk.UserList {
+		names = append(names, user.Name)
 	}
 
+	notFound := missingUsernames(users, names, caseSensitive)
+
 	return len(notFound) == 0, notFound
 }
 
 // ModACL changes the acl in a function
 func (mk *MockStore) ModACL(projectUUID string, resource string, name string, acl []string) error {
-	newACL := QAcl{ACL: acl}
 	if resource == "topics" {
-		if _, exists := mk.TopicsACL[name]; exists {
-			mk.TopicsACL[name] = newACL
+		if qACL, exists := mk.TopicsACL[name]; exists {
+			mk.TopicsACL[name] = QAcl{ACL: acl, Version: qACL.Version + 1}
 			return nil
 		}
 	} else if resource == "subscriptions" {
-		if _, exists := mk.SubsACL[name]; exists {
-			mk.SubsACL[name] = newACL
+		if qACL, exists := mk.SubsACL[name]; exists {
+			mk.SubsACL[name] = QAcl{ACL: acl, Version: qACL.Version + 1}
 			return nil
 		}
 	}
@@ -370,17 +438,31 @@ func (mk *MockStore) ModACL(projectUUID string, resource string, name string, ac
 	return errors.New("wrong resource type")
 }
 
+// ModACLVersion replaces the acl of a resource as long as it hasn't been modified since the given version
+func (mk *MockStore) ModACLVersion(projectUUID string, resource string, name string, acl []string, version int64) error {
+	qACL, err := mk.QueryACL(projectUUID, resource, name)
+	if err != nil {
+		return err
+	}
+	if qACL.Version != version {
+		return errors.New("conflict")
+	}
+	return mk.ModACL(projectUUID, resource, name, acl)
+}
+
 // AppendToACL adds given users to an existing ACL
 func (mk *MockStore) AppendToACL(projectUUID string, resource string, name string, acl []string) error {
 	if resource == "topics" {
 		if qACL, exists := mk.TopicsACL[name]; exists {
 			qACL.ACL = appendUniqueValues(qACL.ACL, acl...)
+			qACL.Version++
 			mk.TopicsACL[name] = qACL
 			return nil
 		}
 	} else if resource == "subscriptions" {
 		if qACL, exists := mk.SubsACL[name]; exists {
 			qACL.ACL = appendUniqueValues(qACL.ACL, acl...)
+			qACL.Version++
 			mk.SubsACL[name] = qACL
 			return nil
 		}
@@ -412,12 +494,14 @@ func (mk *MockStore) RemoveFromACL(projectUUID string, resource string, name str
 	if resource == "topics" {
 		if qACL, exists := mk.TopicsACL[name]; exists {
 			qACL.ACL = removeValues(qACL.ACL, acl...)
+			qACL.Version++
 			mk.TopicsACL[name] = qACL
 			return nil
 		}
 	} else if resource == "subscriptions" {
 		if qACL, exists := mk.SubsACL[name]; exists {
 			qACL.ACL = removeValues(qACL.ACL, acl...)
+			qACL.Version++
 			mk.SubsACL[name] = qACL
 			return nil
 		}
@@ -428,6 +512,47 @@ func (mk *MockStore) RemoveFromACL(projectUUID string, resource string, name str
 	return errors.New("no acl found")
 }
 
+// InsertACLChange appends the acl that a modification left the resource with to its history
+func (mk *MockStore) InsertACLChange(projectUUID string, resource string, name string, operation string, acl []string, actor string, changedOn time.Time) error {
+	change := QACLChange{
+		ProjectUUID:  projectUUID,
+		Resource:     resource,
+		ResourceName: name,
+		Operation:    operation,
+		ACL:          append([]string{}, acl...),
+		Actor:        actor,
+		ChangedOn:    changedOn,
+	}
+	mk.ACLHistory = append(mk.ACLHistory, change)
+	return nil
+}
+
+// QueryACLHistory returns the acl changes of a resource, oldest first
+func (mk *MockStore) QueryACLHistory(projectUUID string, resource string, name string) ([]QACLChange, error) {
+	result := []QACLChange{}
+	for _, item := range mk.ACLHistory {
+		if item.ProjectUUID == projectUUID && item.Resource == resource && item.ResourceName == name {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ChangedOn.Before(result[j].ChangedOn)
+	})
+	return result, nil
+}
+
+// RemoveACLHistory removes the acl changes of all resources made before the given time
+func (mk *MockStore) RemoveACLHistory(before time.Time) error {
+	kept := []QACLChange{}
+	for _, item := range mk.ACLHistory {
+		if !item.ChangedOn.Before(before) {
+			kept = append(kept, item)
+		}
+	}
+	mk.ACLHistory = kept
+	return nil
+}
+
 func removeValues(existingValues []string, valuesToRemove ...string) []string {
 
 	for _, value := range valuesToRemove {
@@ -469,6 +594,34 @@ func (mk *MockStore) UpdateProject(projectUUID string, name string, description
 
 }
 
+// UpdateProjectDefaultACL replaces the acl that is granted to every new topic and subscription of the project
+func (mk *MockStore) UpdateProjectDefaultACL(projectUUID string, defaultACL *QDefaultACL, modifiedOn time.Time) error {
+
+	for i, item := range mk.ProjectList {
+		if item.UUID == projectUUID {
+			mk.ProjectList[i].DefaultACL = defaultACL
+			mk.ProjectList[i].ModifiedOn = modifiedOn
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+// UpdateProjectPatternACL replaces the grants of the project's resources whose names match a pattern
+func (mk *MockStore) UpdateProjectPatternACL(projectUUID string, patternACL *QPatternACL, modifiedOn time.Time) error {
+
+	for i, item := range mk.ProjectList {
+		if item.UUID == projectUUID {
+			mk.ProjectList[i].PatternACL = patternACL
+			mk.ProjectList[i].ModifiedOn = modifiedOn
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
 // QueryDailyProjectMsgCount retrieves the number of total messages that have been published to all project's topics daily
 func (mk *MockStore) QueryDailyProjectMsgCount(projectUUID string) ([]QDailyProjectMsgCount, error) {
 
@@ -570,7 +723,12 @@ func (mk *MockStore) IncrementSubMsgNum(projectUUID string, name string, num int
 
 // UpdateSubOffset updates the offset of the current subscription
 func (mk *MockStore) UpdateSubOffset(projectUUID string, name string, offset int64) {
-
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].Offset = offset
+			mk.SubList[i].Version++
+		}
+	}
 }
 
 // ModAck modifies the subscription ack
@@ -587,7 +745,7 @@ func (mk *MockStore) ModAck(projectUUID string, name string, ack int) error {
 }
 
 // ModSubPush modifies the subscription push configuration
-func (mk *MockStore) ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool) error {
+func (mk *MockStore) ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool, fanout []string, maxConcurrentDeliveries int, maxRetryDuration int, maxRetries int) error {
 	for i, item := range mk.SubList {
 		if item.ProjectUUID == projectUUID && item.Name == name {
 			mk.SubList[i].PushEndpoint = push
@@ -598,6 +756,254 @@ func (mk *MockStore) ModSubPush(projectUUID string, name string, push string, au
 			mk.SubList[i].RetPeriod = rPeriod
 			mk.SubList[i].VerificationHash = vhash
 			mk.SubList[i].Verified = verified
+			mk.SubList[i].FanoutEndpoints = fanout
+			mk.SubList[i].MaxConcurrentDeliveries = maxConcurrentDeliveries
+			mk.SubList[i].MaxRetryDuration = maxRetryDuration
+			mk.SubList[i].MaxRetries = maxRetries
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// InsertOffsetTime records the publish time of the message stored at the given offset of a topic
+func (mk *MockStore) InsertOffsetTime(projectUUID string, topicName string, offset int64, publishTime time.Time) error {
+	for i, item := range mk.OffsetTimes {
+		if item.ProjectUUID == projectUUID && item.TopicName == topicName && item.Offset == offset {
+			mk.OffsetTimes[i].PublishTime = publishTime
+			return nil
+		}
+	}
+	mk.OffsetTimes = append(mk.OffsetTimes, QOffsetTime{ProjectUUID: projectUUID, TopicName: topicName, Offset: offset, PublishTime: publishTime})
+	return nil
+}
+
+// QueryOffsetByTime returns the first offset of a topic that was published at or after the given time
+func (mk *MockStore) QueryOffsetByTime(projectUUID string, topicName string, t time.Time) (int64, error) {
+	off := int64(-1)
+	for _, item := range mk.OffsetTimes {
+		if item.ProjectUUID != projectUUID || item.TopicName != topicName || item.PublishTime.Before(t) {
+			continue
+		}
+		if off == -1 || item.Offset < off {
+			off = item.Offset
+		}
+	}
+	return off, nil
+}
+
+// RemoveOffsetTimes removes the publish times kept for the offsets of a topic before the given one
+func (mk *MockStore) RemoveOffsetTimes(projectUUID string, topicName string, before int64) error {
+	kept := []QOffsetTime{}
+	for _, item := range mk.OffsetTimes {
+		if item.ProjectUUID != projectUUID || item.TopicName != topicName || item.Offset >= before {
+			kept = append(kept, item)
+		}
+	}
+	mk.OffsetTimes = kept
+	return nil
+}
+
+// InsertTopicSample records the counters of a topic at the given time
+func (mk *MockStore) InsertTopicSample(projectUUID string, topicName string, timestamp time.Time, msgNum int64, totalBytes int64) error {
+	mk.TopicSamples = append(mk.TopicSamples, QTopicSample{ProjectUUID: projectUUID, TopicName: topicName, Timestamp: timestamp, MsgNum: msgNum, TotalBytes: totalBytes})
+	return nil
+}
+
+// QueryTopicSamples returns the samples of a topic taken at or after the given time, oldest first
+func (mk *MockStore) QueryTopicSamples(projectUUID string, topicName string, since time.Time) ([]QTopicSample, error) {
+	result := []QTopicSample{}
+	for _, item := range mk.TopicSamples {
+		if item.ProjectUUID == projectUUID && item.TopicName == topicName && !item.Timestamp.Before(since) {
+			result = append(result, item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+// RemoveTopicSamples removes the samples of all topics taken before the given time
+func (mk *MockStore) RemoveTopicSamples(before time.Time) error {
+	kept := []QTopicSample{}
+	for _, item := range mk.TopicSamples {
+		if !item.Timestamp.Before(before) {
+			kept = append(kept, item)
+		}
+	}
+	mk.TopicSamples = kept
+	return nil
+}
+
+// InsertOffsetSnapshot records the offsets of a project's subscriptions under the snapshot's name
+func (mk *MockStore) InsertOffsetSnapshot(projectUUID string, name string, createdOn time.Time, offsets []QSubOffset) error {
+	mk.OffsetSnapshots = append(mk.OffsetSnapshots, QOffsetSnapshot{ProjectUUID: projectUUID, Name: name, CreatedOn: createdOn, Offsets: offsets})
+	return nil
+}
+
+// QueryOffsetSnapshots returns the offset snapshots of a project, or only the one with the given name, oldest first
+func (mk *MockStore) QueryOffsetSnapshots(projectUUID string, name string) ([]QOffsetSnapshot, error) {
+	result := []QOffsetSnapshot{}
+	for _, item := range mk.OffsetSnapshots {
+		if item.ProjectUUID == projectUUID && (name == "" || item.Name == name) {
+			result = append(result, item)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedOn.Before(result[j].CreatedOn)
+	})
+	return result, nil
+}
+
+// RemoveOffsetSnapshots removes the offset snapshots of all projects taken before the given time
+func (mk *MockStore) RemoveOffsetSnapshots(before time.Time) error {
+	kept := []QOffsetSnapshot{}
+	for _, item := range mk.OffsetSnapshots {
+		if !item.CreatedOn.Before(before) {
+			kept = append(kept, item)
+		}
+	}
+	mk.OffsetSnapshots = kept
+	return nil
+}
+
+// ModSubLabels replaces the labels of a subscription
+func (mk *MockStore) ModSubLabels(projectUUID string, name string, labels map[string]string) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].Labels = nil
+			if len(labels) > 0 {
+				mk.SubList[i].Labels = labels
+			}
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// ModSubIsolationLevel updates the broker isolation level a subscription consumes with
+func (mk *MockStore) ModSubIsolationLevel(projectUUID string, name string, level string) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].IsolationLevel = level
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// AppendAckedIDs records message ids acknowledged through a subscription, keeping only the latest window of them
+func (mk *MockStore) AppendAckedIDs(projectUUID string, name string, ids []string, window int) error {
+
+	idx := -1
+	for i, item := range mk.AckedIDs {
+		if item.ProjectUUID == projectUUID && item.Subscription == name {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		mk.AckedIDs = append(mk.AckedIDs, QAckedIDs{ProjectUUID: projectUUID, Subscription: name})
+		idx = len(mk.AckedIDs) - 1
+	}
+
+	acked := append(mk.AckedIDs[idx].IDs, ids...)
+	if len(acked) > window {
+		acked = acked[len(acked)-window:]
+	}
+	mk.AckedIDs[idx].IDs = acked
+
+	return nil
+}
+
+// QueryAckedIDs returns the latest message ids acknowledged through a subscription
+func (mk *MockStore) QueryAckedIDs(projectUUID string, name string) ([]string, error) {
+	for _, item := range mk.AckedIDs {
+		if item.ProjectUUID == projectUUID && item.Subscription == name {
+			return item.IDs, nil
+		}
+	}
+	return []string{}, nil
+}
+
+// ModSubMaxConcurrentPulls updates the number of pulls allowed to proceed at once on a subscription
+func (mk *MockStore) ModSubMaxConcurrentPulls(projectUUID string, name string, max int) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].MaxConcurrentPulls = max
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// ModSubDefaultMaxMessages updates the number of messages returned by the pulls of a subscription that don't declare maxMessages
+func (mk *MockStore) ModSubDefaultMaxMessages(projectUUID string, name string, max int) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].DefaultMaxMessages = max
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// AcquirePullLease adds a lease to the pulls in progress on a subscription, unless max unexpired leases are already held
+func (mk *MockStore) AcquirePullLease(projectUUID string, name string, leaseID string, max int, now time.Time, expiresAt time.Time) (bool, error) {
+
+	idx := -1
+	for i, item := range mk.PullLeases {
+		if item.ProjectUUID == projectUUID && item.Subscription == name {
+			idx = i
+			break
+		}
+	}
+
+	if idx < 0 {
+		mk.PullLeases = append(mk.PullLeases, QPullLeases{ProjectUUID: projectUUID, Subscription: name})
+		idx = len(mk.PullLeases) - 1
+	}
+
+	held := []QPullLease{}
+	for _, lease := range mk.PullLeases[idx].Leases {
+		if lease.ExpiresAt.After(now) {
+			held = append(held, lease)
+		}
+	}
+
+	if len(held) >= max {
+		mk.PullLeases[idx].Leases = held
+		return false, nil
+	}
+
+	mk.PullLeases[idx].Leases = append(held, QPullLease{ID: leaseID, ExpiresAt: expiresAt})
+
+	return true, nil
+}
+
+// ReleasePullLease removes the lease of a completed pull from a subscription
+func (mk *MockStore) ReleasePullLease(projectUUID string, name string, leaseID string) error {
+	for i, item := range mk.PullLeases {
+		if item.ProjectUUID == projectUUID && item.Subscription == name {
+			held := []QPullLease{}
+			for _, lease := range item.Leases {
+				if lease.ID != leaseID {
+					held = append(held, lease)
+				}
+			}
+			mk.PullLeases[i].Leases = held
+		}
+	}
+	return nil
+}
+
+// ResetSubAckedMsgs sets the number of messages acknowledged through a subscription back to zero
+func (mk *MockStore) ResetSubAckedMsgs(projectUUID string, name string) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].AckedMsgs = 0
 			return nil
 		}
 	}
@@ -605,7 +1011,7 @@ func (mk *MockStore) ModSubPush(projectUUID string, name string, push string, au
 }
 
 // UpdateSubOffsetAck updates the offset of the current subscription
-func (mk *MockStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string) error {
+func (mk *MockStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string, version int64) error {
 	// find sub
 	sub := QSub{}
 
@@ -615,6 +1021,11 @@ func (mk *MockStore) UpdateSubOffsetAck(projectUUID string, name string, offset
 		}
 	}
 
+	// the offsets have moved since the caller read the subscription
+	if sub.Name != "" && sub.Version != version {
+		return errors.New("conflict")
+	}
+
 	// check if no ack pending
 	if sub.NextOffset == 0 {
 		return errors.New("no ack pending")
@@ -625,16 +1036,25 @@ func (mk *MockStore) UpdateSubOffsetAck(projectUUID string, name string, offset
 		return errors.New("wrong ack")
 	}
 
-	// check if ack has timeout
-	zSec := "2006-01-02T15:04:05Z"
-	timeGiven, _ := time.Parse(zSec, ts)
-	timeRef, _ := time.Parse(zSec, sub.PendingAck)
-	durSec := timeGiven.Sub(timeRef).Seconds()
+	// check if ack has timeout, both the given and the pending ack timestamps
+	// are read from the clock of the service's config, which tests set to control the elapsed time
+	timeGiven, _ := timestamp.Parse(ts)
+	timeRef, _ := timestamp.Parse(sub.PendingAck)
 
-	if int(durSec) > sub.Ack {
+	if timeGiven.Sub(timeRef) > time.Duration(sub.Ack)*time.Second {
 		return errors.New("ack timeout")
 	}
 
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			mk.SubList[i].AckedMsgs += offset - sub.Offset
+			mk.SubList[i].Offset = offset
+			mk.SubList[i].NextOffset = 0
+			mk.SubList[i].PendingAck = ""
+			mk.SubList[i].Version++
+		}
+	}
+
 	return nil
 }
 
@@ -794,12 +1214,76 @@ func (mk *MockStore) PaginatedQueryUsers(pageToken string, pageSize int32, proje
 
 }
 
+// PaginatedQueryUsersByName returns a page of users ordered by name, starting from the user named by the page token
+func (mk *MockStore) PaginatedQueryUsersByName(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error) {
+
+	selected := []QUser{}
+	for _, user := range mk.UserList {
+		if projectUUID != "" {
+			found := false
+			for _, project := range user.Projects {
+				if projectUUID == project.ProjectUUID {
+					found = true
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+		selected = append(selected, user)
+	}
+
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Name != selected[j].Name {
+			return selected[i].Name < selected[j].Name
+		}
+		return selected[i].ID.(int) < selected[j].ID.(int)
+	})
+
+	qUsers := []QUser{}
+	nextPageToken := ""
+	for _, user := range selected {
+		if pageToken != "" && user.Name < pageToken {
+			continue
+		}
+		if pageSize > 0 && len(qUsers) == int(pageSize) {
+			nextPageToken = user.Name
+			break
+		}
+		qUsers = append(qUsers, user)
+	}
+
+	return qUsers, int32(len(selected)), nextPageToken, nil
+}
+
 // UpdateSubPull updates next offset info after a pull
-func (mk *MockStore) UpdateSubPull(projectUUID string, name string, offset int64, ts string) error {
+func (mk *MockStore) UpdateSubPull(projectUUID string, name string, offset int64, ts string, version int64) error {
 	for i, item := range mk.SubList {
 		if item.ProjectUUID == projectUUID && item.Name == name {
+			if item.Version != version {
+				return errors.New("conflict")
+			}
 			mk.SubList[i].NextOffset = offset
 			mk.SubList[i].PendingAck = ts
+			mk.SubList[i].Version++
+			return nil
+		}
+	}
+	return errors.New("not found")
+
+}
+
+// UpdateSubPullCommit moves the offset of a subscription past the pulled messages, leaving no ack pending
+func (mk *MockStore) UpdateSubPullCommit(projectUUID string, name string, offset int64, version int64) error {
+	for i, item := range mk.SubList {
+		if item.ProjectUUID == projectUUID && item.Name == name {
+			if item.Version != version {
+				return errors.New("conflict")
+			}
+			mk.SubList[i].Offset = offset
+			mk.SubList[i].NextOffset = 0
+			mk.SubList[i].PendingAck = ""
+			mk.SubList[i].Version++
 			return nil
 		}
 	}
@@ -812,20 +1296,20 @@ func (mk *MockStore) Initialize() {
 	mk.OpMetrics = make(map[string]QopMetric)
 
 	// populate topics
-	qtop4 := QTopic{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}}
-	qtop3 := QTopic{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}}
-	qtop2 := QTopic{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}}
-	qtop1 := QTopic{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}}
+	qtop4 := QTopic{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""}
+	qtop3 := QTopic{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""}
+	qtop2 := QTopic{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""}
+	qtop1 := QTopic{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""}
 	mk.TopicList = append(mk.TopicList, qtop1)
 	mk.TopicList = append(mk.TopicList, qtop2)
 	mk.TopicList = append(mk.TopicList, qtop3)
 	mk.TopicList = append(mk.TopicList, qtop4)
 
 	// populate Subscriptions
-	qsub1 := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}}
-	qsub2 := QSub{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}}
-	qsub3 := QSub{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}}
-	qsub4 := QSub{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}}
+	qsub1 := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}
+	qsub2 := QSub{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}
+	qsub3 := QSub{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}
+	qsub4 := QSub{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}
 	mk.SubList = append(mk.SubList, qsub1)
 	mk.SubList = append(mk.SubList, qsub2)
 	mk.SubList = append(mk.SubList, qsub3)
@@ -898,14 +1382,14 @@ func (mk *MockStore) Initialize() {
 	mk.RoleList = append(mk.RoleList, qRole1)
 	mk.RoleList = append(mk.RoleList, qRole2)
 
-	qTopicACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qTopicACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
-	qTopicACL03 := QAcl{[]string{"uuid3"}}
+	qTopicACL01 := QAcl{[]string{"uuid1", "uuid2"}, 0}
+	qTopicACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}, 0}
+	qTopicACL03 := QAcl{[]string{"uuid3"}, 0}
 
-	qSubACL01 := QAcl{[]string{"uuid1", "uuid2"}}
-	qSubACL02 := QAcl{[]string{"uuid1", "uuid3"}}
-	qSubACL03 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
-	qSubACL04 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	qSubACL01 := QAcl{[]string{"uuid1", "uuid2"}, 0}
+	qSubACL02 := QAcl{[]string{"uuid1", "uuid3"}, 0}
+	qSubACL03 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}, 0}
+	qSubACL04 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}, 0}
 
 	mk.TopicsACL = make(map[string]QAcl)
 	mk.SubsACL = make(map[string]QAcl)
@@ -1009,6 +1493,11 @@ func (mk *MockStore) Clone() Store {
 	return mk
 }
 
+// Type returns the type of the mock store
+func (mk *MockStore) Type() string {
+	return "mock"
+}
+
 // GetUserFromToken retrieves specific user info from a given token
 func (mk *MockStore) GetUserFromToken(token string) (QUser, error) {
 	for _, item := range mk.UserList {
@@ -1068,7 +1557,7 @@ func (mk *MockStore) HasProject(name string) bool {
 }
 
 // InsertTopic inserts a new topic object to the store
-func (mk *MockStore) InsertTopic(projectUUID string, name string, schemaUUID string, createdOn time.Time) error {
+func (mk *MockStore) InsertTopic(projectUUID string, name string, schemaUUID string, publishAcks string, createdOn time.Time) error {
 	topic := QTopic{
 		ID:            len(mk.TopicList),
 		ProjectUUID:   projectUUID,
@@ -1080,38 +1569,22 @@ func (mk *MockStore) InsertTopic(projectUUID string, name string, schemaUUID str
 		SchemaUUID:    schemaUUID,
 		CreatedOn:     createdOn,
 		ACL:           []string{},
+		PublishAcks:   publishAcks,
 	}
 	mk.TopicList = append(mk.TopicList, topic)
+	mk.TopicsACL[name] = QAcl{}
 	return nil
 }
 
 // InsertSub inserts a new sub object to the store
-func (mk *MockStore) InsertSub(projectUUID string, name string, topic string, offset int64, maxMessages int64, authT string, authH string, ack int, push string, rPolicy string, rPeriod int, vhash string, verified bool, createdOn time.Time) error {
-	sub := QSub{
-		ID:                  len(mk.SubList),
-		ProjectUUID:         projectUUID,
-		Name:                name,
-		Topic:               topic,
-		Offset:              offset,
-		Ack:                 ack,
-		MaxMessages:         maxMessages,
-		AuthorizationType:   authT,
-		AuthorizationHeader: authH,
-		PushEndpoint:        push,
-		RetPolicy:           rPolicy,
-		RetPeriod:           rPeriod,
-		VerificationHash:    vhash,
-		Verified:            verified,
-		MsgNum:              0,
-		TotalBytes:          0,
-		LatestConsume:       time.Time{},
-		ConsumeRate:         0,
-		CreatedOn:           createdOn,
-		ACL:                 []string{},
+func (mk *MockStore) InsertSub(sub QSub) error {
+	sub.ID = len(mk.SubList)
+	if sub.ACL == nil {
+		sub.ACL = []string{}
 	}
 
 	mk.SubList = append(mk.SubList, sub)
-	mk.SubsACL[name] = QAcl{}
+	mk.SubsACL[sub.Name] = QAcl{}
 	return nil
 }
 
@@ -1148,6 +1621,90 @@ func (mk *MockStore) RemoveTopic(projectUUID string, name string) error {
 	return errors.New("not found")
 }
 
+// SoftDeleteTopic marks an existing topic as deleted
+func (mk *MockStore) SoftDeleteTopic(projectUUID string, name string, deletedOn time.Time) error {
+	for i, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[i].DeletedOn = deletedOn
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+// RestoreTopic clears the deleted mark of an existing topic
+func (mk *MockStore) RestoreTopic(projectUUID string, name string) error {
+	for i, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[i].DeletedOn = time.Time{}
+			return nil
+		}
+	}
+
+	return errors.New("not found")
+}
+
+// RenameTopic renames an existing topic along with the subscriptions, message counts and publish times that refer to it
+func (mk *MockStore) RenameTopic(projectUUID string, name string, newName string, brokerTopic string) error {
+
+	found := false
+	for i, topic := range mk.TopicList {
+		if topic.Name == name && topic.ProjectUUID == projectUUID {
+			mk.TopicList[i].Name = newName
+			mk.TopicList[i].BrokerTopic = brokerTopic
+			found = true
+		}
+	}
+
+	if !found {
+		return errors.New("not found")
+	}
+
+	if acl, exists := mk.TopicsACL[name]; exists {
+		mk.TopicsACL[newName] = acl
+		delete(mk.TopicsACL, name)
+	}
+
+	for i, sub := range mk.SubList {
+		if sub.Topic == name && sub.ProjectUUID == projectUUID {
+			mk.SubList[i].Topic = newName
+		}
+	}
+
+	for i, item := range mk.DailyTopicMsgCount {
+		if item.TopicName == name && item.ProjectUUID == projectUUID {
+			mk.DailyTopicMsgCount[i].TopicName = newName
+		}
+	}
+
+	for i, item := range mk.OffsetTimes {
+		if item.TopicName == name && item.ProjectUUID == projectUUID {
+			mk.OffsetTimes[i].TopicName = newName
+		}
+	}
+
+	for i, item := range mk.TopicSamples {
+		if item.TopicName == name && item.ProjectUUID == projectUUID {
+			mk.TopicSamples[i].TopicName = newName
+		}
+	}
+
+	return nil
+}
+
+// QueryDeletedTopics returns the soft-deleted topics that were deleted before the given time
+func (mk *MockStore) QueryDeletedTopics(deletedBefore time.Time) ([]QTopic, error) {
+	result := []QTopic{}
+	for _, topic := range mk.TopicList {
+		if !topic.DeletedOn.IsZero() && !topic.DeletedOn.After(deletedBefore) {
+			result = append(result, topic)
+		}
+	}
+
+	return result, nil
+}
+
 // RemoveUser removes an existing user
 func (mk *MockStore) RemoveUser(uuid string) error {
 	for i, user := range mk.UserList {
@@ -1193,6 +1750,20 @@ func (mk *MockStore) RemoveProjectSubs(projectUUID string) error {
 		}
 	}
 	mk.SubList = newList
+	newAcked := []QAckedIDs{}
+	for _, acked := range mk.AckedIDs {
+		if acked.ProjectUUID != projectUUID {
+			newAcked = append(newAcked, acked)
+		}
+	}
+	mk.AckedIDs = newAcked
+	newLeases := []QPullLeases{}
+	for _, leases := range mk.PullLeases {
+		if leases.ProjectUUID != projectUUID {
+			newLeases = append(newLeases, leases)
+		}
+	}
+	mk.PullLeases = newLeases
 	if found {
 		return nil
 	}
@@ -1205,6 +1776,18 @@ func (mk *MockStore) RemoveSub(projectUUID string, name string) error {
 		if sub.Name == name && sub.ProjectUUID == projectUUID {
 			// found item at i, remove it using index
 			mk.SubList = append(mk.SubList[:i], mk.SubList[i+1:]...)
+			for j, acked := range mk.AckedIDs {
+				if acked.ProjectUUID == projectUUID && acked.Subscription == name {
+					mk.AckedIDs = append(mk.AckedIDs[:j], mk.AckedIDs[j+1:]...)
+					break
+				}
+			}
+			for j, leases := range mk.PullLeases {
+				if leases.ProjectUUID == projectUUID && leases.Subscription == name {
+					mk.PullLeases = append(mk.PullLeases[:j], mk.PullLeases[j+1:]...)
+					break
+				}
+			}
 			return nil
 		}
 	}
@@ -1214,11 +1797,17 @@ func (mk *MockStore) RemoveSub(projectUUID string, name string) error {
 
 // QueryPushSubs Query push Subscription info from store
 func (mk *MockStore) QueryPushSubs() []QSub {
-	return mk.SubList
+	results := []QSub{}
+	for _, item := range mk.SubList {
+		if item.PushEndpoint != "" {
+			results = append(results, item)
+		}
+	}
+	return results
 }
 
 // QuerySubs Query Subscription info from store
-func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pageSize int32) ([]QSub, int32, string, error) {
+func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pageSize int32, selector labels.Selector, namePrefix string) ([]QSub, int32, string, error) {
 
 	var qSubs []QSub
 	var totalSize int32
@@ -1229,7 +1818,7 @@ func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pa
 	var counter int
 
 	for _, sub := range mk.SubList {
-		if sub.ProjectUUID == projectUUID {
+		if sub.ProjectUUID == projectUUID && selector.Matches(sub.Labels) && strings.HasPrefix(sub.Name, namePrefix) {
 
 			if userUUID != "" {
 				if !mk.existsInACL("subscriptions", sub.Name, userUUID) {
@@ -1271,7 +1860,7 @@ func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pa
 
 			if pageToken != "" {
 
-				if sub.ID.(int) <= pg && sub.ProjectUUID == projectUUID {
+				if sub.ID.(int) <= pg && sub.ProjectUUID == projectUUID && selector.Matches(sub.Labels) && strings.HasPrefix(sub.Name, namePrefix) {
 
 					if userUUID != "" {
 						if !mk.existsInACL("subscriptions", sub.Name, userUUID) {
@@ -1286,7 +1875,7 @@ func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pa
 
 			} else {
 
-				if sub.ProjectUUID == projectUUID {
+				if sub.ProjectUUID == projectUUID && selector.Matches(sub.Labels) && strings.HasPrefix(sub.Name, namePrefix) {
 
 					qSubs = append(qSubs, sub)
 					limit--
@@ -1298,14 +1887,14 @@ func (mk *MockStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pa
 
 		totalSize = int32(counter)
 
-		if len(qSubs) > 0 && len(qSubs) == int(pageSize)+1 {
+		if pageSize > 0 && len(qSubs) > 0 && len(qSubs) == int(pageSize)+1 {
 			nextPageToken = strconv.Itoa(qSubs[int(pageSize)].ID.(int))
 			qSubs = qSubs[:len(qSubs)-1]
 		}
 
 	case false:
 		for _, sub := range mk.SubList {
-			if sub.ProjectUUID == projectUUID && sub.Name == name {
+			if sub.ProjectUUID == projectUUID && sub.Name == name && selector.Matches(sub.Labels) && strings.HasPrefix(sub.Name, namePrefix) {
 
 				if userUUID != "" {
 					if !mk.existsInACL("subscriptions", sub.Name, userUUID) {
@@ -1355,7 +1944,7 @@ func (mk *MockStore) QuerySubsByACL(projectUUID, user string) ([]QSub, error) {
 func (mk *MockStore) QueryTopicsByACL(projectUUID, user string) ([]QTopic, error) {
 
 	result := []QTopic{}
-	for _, item := range mk.TopicList {
+	for _, item := range mk.visibleTopics(false) {
 		if projectUUID == item.ProjectUUID {
 			for _, usr := range mk.TopicsACL[item.Name].ACL {
 				if usr == user {
@@ -1368,8 +1957,19 @@ func (mk *MockStore) QueryTopicsByACL(projectUUID, user string) ([]QTopic, error
 	return result, nil
 }
 
+// visibleTopics returns the topics in their current order, leaving out the soft-deleted ones unless they should be shown
+func (mk *MockStore) visibleTopics(showDeleted bool) []QTopic {
+	topics := []QTopic{}
+	for _, topic := range mk.TopicList {
+		if showDeleted || topic.DeletedOn.IsZero() {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
 // QueryTopics Query Subscription info from store
-func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string, pageSize int32) ([]QTopic, int32, string, error) {
+func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string, pageSize int32, showDeleted bool, selector labels.Selector, namePrefix string) ([]QTopic, int32, string, error) {
 
 	var qTopics []QTopic
 	var totalSize int32
@@ -1379,8 +1979,8 @@ func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string,
 	var limit int
 	var counter int
 
-	for _, topic := range mk.TopicList {
-		if topic.ProjectUUID == projectUUID {
+	for _, topic := range mk.visibleTopics(showDeleted) {
+		if topic.ProjectUUID == projectUUID && selector.Matches(topic.Labels) && strings.HasPrefix(topic.Name, namePrefix) {
 
 			if userUUID != "" {
 				if !mk.existsInACL("topics", topic.Name, userUUID) {
@@ -1413,7 +2013,7 @@ func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string,
 			return id1 > id2
 		})
 
-		for _, topic := range mk.TopicList {
+		for _, topic := range mk.visibleTopics(showDeleted) {
 
 			if limit == 0 {
 				break
@@ -1421,7 +2021,7 @@ func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string,
 
 			if pageToken != "" {
 
-				if topic.ID.(int) <= pg && topic.ProjectUUID == projectUUID {
+				if topic.ID.(int) <= pg && topic.ProjectUUID == projectUUID && selector.Matches(topic.Labels) && strings.HasPrefix(topic.Name, namePrefix) {
 
 					if userUUID != "" {
 						if !mk.existsInACL("topics", topic.Name, userUUID) {
@@ -1436,7 +2036,7 @@ func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string,
 
 			} else {
 
-				if topic.ProjectUUID == projectUUID {
+				if topic.ProjectUUID == projectUUID && selector.Matches(topic.Labels) && strings.HasPrefix(topic.Name, namePrefix) {
 
 					if userUUID != "" {
 						if !mk.existsInACL("topics", topic.Name, userUUID) {
@@ -1454,15 +2054,15 @@ func (mk *MockStore) QueryTopics(projectUUID, userUUID, name, pageToken string,
 
 		totalSize = int32(counter)
 
-		if len(qTopics) > 0 && len(qTopics) == int(pageSize)+1 {
+		if pageSize > 0 && len(qTopics) > 0 && len(qTopics) == int(pageSize)+1 {
 			nextPageToken = strconv.Itoa(qTopics[int(pageSize)].ID.(int))
 			qTopics = qTopics[:len(qTopics)-1]
 		}
 
 	case false:
 
-		for _, topic := range mk.TopicList {
-			if topic.ProjectUUID == projectUUID && topic.Name == name {
+		for _, topic := range mk.visibleTopics(showDeleted) {
+			if topic.ProjectUUID == projectUUID && selector.Matches(topic.Labels) && strings.HasPrefix(topic.Name, namePrefix) && topic.Name == name {
 
 				if userUUID != "" {
 					if !mk.existsInACL("topics", topic.Name, userUUID) {
@@ -1544,6 +2144,31 @@ func (mk *MockStore) UpdateTopicPublishRate(projectUUID string, name string, rat
 	return errors.New("topic not found")
 }
 
+// UpdateTopicLabels replaces the labels of a topic
+func (mk *MockStore) UpdateTopicLabels(projectUUID string, name string, labels map[string]string) error {
+	for idx, topic := range mk.TopicList {
+		if topic.ProjectUUID == projectUUID && topic.Name == name {
+			mk.TopicList[idx].Labels = nil
+			if len(labels) > 0 {
+				mk.TopicList[idx].Labels = labels
+			}
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+// UpdateTopicPartitionKey updates the message attribute that holds the partition key of the topic's messages
+func (mk *MockStore) UpdateTopicPartitionKey(projectUUID string, name string, attribute string) error {
+	for idx, topic := range mk.TopicList {
+		if topic.ProjectUUID == projectUUID && topic.Name == name {
+			mk.TopicList[idx].PartitionKeyAttribute = attribute
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
 // UpdateSubLatestConsume updates the subscription's latest consume time
 func (mk *MockStore) UpdateSubLatestConsume(projectUUID string, name string, date time.Time) error {
 	for idx, topic := range mk.SubList {
@@ -1555,6 +2180,17 @@ func (mk *MockStore) UpdateSubLatestConsume(projectUUID string, name string, dat
 	return errors.New("subscription not found")
 }
 
+// UpdateSubLastProgress updates the latest time the subscription's offset was advanced by its consumers
+func (mk *MockStore) UpdateSubLastProgress(projectUUID string, name string, date time.Time) error {
+	for idx, sub := range mk.SubList {
+		if sub.ProjectUUID == projectUUID && sub.Name == name {
+			mk.SubList[idx].LastProgress = date
+			return nil
+		}
+	}
+	return errors.New("subscription not found")
+}
+
 // UpdateSubConsumeRate updates the subscription's consume rate
 func (mk *MockStore) UpdateSubConsumeRate(projectUUID string, name string, rate float64) error {
 	for idx, topic := range mk.SubList {
@@ -2,8 +2,12 @@ package stores
 
 import (
 	"errors"
+	"regexp"
 	"time"
 
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+
 	log "github.com/sirupsen/logrus"
 
 	"fmt"
@@ -40,6 +44,11 @@ func (mong *MongoStore) Clone() Store {
 	return nStore
 }
 
+// Type returns the type of the store backend
+func (mong *MongoStore) Type() string {
+	return "mongo"
+}
+
 // Initialize initializes the mongo store struct
 func (mong *MongoStore) Initialize() {
 
@@ -93,6 +102,38 @@ func (mong *MongoStore) UsersCount(startDate, endDate time.Time) (int, error) {
 	return mong.getDocCountForCollection(startDate, endDate, "users")
 }
 
+// CountTopicsByProject returns the amount of topics that belong to the given project
+func (mong *MongoStore) CountTopicsByProject(projectUUID string) (int64, error) {
+	return mong.getProjectDocCountForCollection(projectUUID, "topics")
+}
+
+// CountSubsByProject returns the amount of subscriptions that belong to the given project
+func (mong *MongoStore) CountSubsByProject(projectUUID string) (int64, error) {
+	return mong.getProjectDocCountForCollection(projectUUID, "subscriptions")
+}
+
+// getProjectDocCountForCollection returns the document count for a collection for the given project
+// without retrieving the documents themselves, leaving out the soft-deleted documents
+// collection should support field project_uuid
+func (mong *MongoStore) getProjectDocCountForCollection(projectUUID string, col string) (int64, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C(col)
+
+	count, err := c.Find(bson.M{"project_uuid": projectUUID, "deleted_on": bson.M{"$exists": false}}).Count()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	return int64(count), nil
+}
+
 // getDocCountForCollection returns the document count for a collection in a given time period
 // collection should support field created_on
 func (mong *MongoStore) getDocCountForCollection(startDate, endDate time.Time, col string) (int, error) {
@@ -191,6 +232,32 @@ func (mong *MongoStore) UpdateProject(projectUUID string, name string, descripti
 
 }
 
+// UpdateProjectDefaultACL replaces the acl that is granted to every new topic and subscription of the project
+func (mong *MongoStore) UpdateProjectDefaultACL(projectUUID string, defaultACL *QDefaultACL, modifiedOn time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("projects")
+
+	change := bson.M{"$set": bson.M{"default_acl": defaultACL, "modified_on": modifiedOn}}
+	if defaultACL == nil {
+		change = bson.M{"$set": bson.M{"modified_on": modifiedOn}, "$unset": bson.M{"default_acl": ""}}
+	}
+
+	return c.Update(bson.M{"uuid": projectUUID}, change)
+}
+
+// UpdateProjectPatternACL replaces the grants of the project's resources whose names match a pattern
+func (mong *MongoStore) UpdateProjectPatternACL(projectUUID string, patternACL *QPatternACL, modifiedOn time.Time) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("projects")
+
+	change := bson.M{"$set": bson.M{"pattern_acl": patternACL, "modified_on": modifiedOn}}
+	if patternACL == nil {
+		change = bson.M{"$set": bson.M{"modified_on": modifiedOn}, "$unset": bson.M{"pattern_acl": ""}}
+	}
+
+	return c.Update(bson.M{"uuid": projectUUID}, change)
+}
+
 // RegisterUser inserts a new user registration to the database
 func (mong *MongoStore) RegisterUser(uuid, name, firstName, lastName, email, org, desc, registeredAt, atkn, status string) error {
 
@@ -374,16 +441,47 @@ func (mong *MongoStore) UpdateUser(uuid, fname, lname, org, desc string, project
 
 }
 
-// UpdateSubPull updates next offset and sets timestamp for Ack
-func (mong *MongoStore) UpdateSubPull(projectUUID string, name string, nextOff int64, ts string) error {
+// UpdateSubPull updates next offset and sets timestamp for Ack.
+// The update takes place only if the subscription is still at the given version
+func (mong *MongoStore) UpdateSubPull(projectUUID string, name string, nextOff int64, ts string, version int64) error {
 
 	db := mong.Session.DB(mong.Database)
 	c := db.C("subscriptions")
 
-	doc := bson.M{"project_uuid": projectUUID, "name": name}
-	change := bson.M{"$set": bson.M{"next_offset": nextOff, "pending_ack": ts}}
+	doc := bson.M{"project_uuid": projectUUID, "name": name, "version": versionQuery(version)}
+	change := bson.M{"$set": bson.M{"next_offset": nextOff, "pending_ack": ts}, "$inc": bson.M{"version": 1}}
 	err := c.Update(doc, change)
-	if err != nil && err != mgo.ErrNotFound {
+	if err == mgo.ErrNotFound {
+		return mong.subVersionErr(projectUUID, name)
+	}
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	return err
+
+}
+
+// UpdateSubPullCommit moves the offset of a subscription past the pulled messages, leaving no ack pending,
+// as long as the offsets haven't moved since the given version
+func (mong *MongoStore) UpdateSubPullCommit(projectUUID string, name string, offset int64, version int64) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name, "version": versionQuery(version)}
+	change := bson.M{"$set": bson.M{"offset": offset, "next_offset": 0, "pending_ack": ""}, "$inc": bson.M{"version": 1}}
+	err := c.Update(doc, change)
+	if err == mgo.ErrNotFound {
+		return mong.subVersionErr(projectUUID, name)
+	}
+	if err != nil {
 		log.WithFields(
 			log.Fields{
 				"type":            "backend_log",
@@ -397,8 +495,42 @@ func (mong *MongoStore) UpdateSubPull(projectUUID string, name string, nextOff i
 
 }
 
-// UpdateSubOffsetAck updates a subscription offset after Ack
-func (mong *MongoStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string) error {
+// versionQuery matches the given subscription version.
+// Subscriptions created before versioning was introduced lack the field and count as version 0
+func versionQuery(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$in": []interface{}{0, nil}}
+	}
+	return version
+}
+
+// subVersionErr tells apart a subscription that doesn't exist from one whose version has changed
+func (mong *MongoStore) subVersionErr(projectUUID string, name string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	count, err := c.Find(bson.M{"project_uuid": projectUUID, "name": name}).Count()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	if count == 0 {
+		return mgo.ErrNotFound
+	}
+
+	return errors.New("conflict")
+}
+
+// UpdateSubOffsetAck updates a subscription offset after Ack.
+// The update takes place only if the subscription is still at the given version
+func (mong *MongoStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string, version int64) error {
 
 	db := mong.Session.DB(mong.Database)
 	c := db.C("subscriptions")
@@ -407,6 +539,11 @@ func (mong *MongoStore) UpdateSubOffsetAck(projectUUID string, name string, offs
 	res := QSub{}
 	err := c.Find(bson.M{"project_uuid": projectUUID, "name": name}).One(&res)
 
+	// the offsets have moved since the caller read the subscription
+	if err == nil && res.Version != version {
+		return errors.New("conflict")
+	}
+
 	// check if no ack pending
 	if res.NextOffset == 0 {
 		return errors.New("no ack pending")
@@ -418,19 +555,20 @@ func (mong *MongoStore) UpdateSubOffsetAck(projectUUID string, name string, offs
 	}
 
 	// check if ack has timeout
-	zSec := "2006-01-02T15:04:05Z"
-	timeGiven, _ := time.Parse(zSec, ts)
-	timeRef, _ := time.Parse(zSec, res.PendingAck)
-	durSec := timeGiven.Sub(timeRef).Seconds()
+	timeGiven, _ := timestamp.Parse(ts)
+	timeRef, _ := timestamp.Parse(res.PendingAck)
 
-	if int(durSec) > res.Ack {
+	if timeGiven.Sub(timeRef) > time.Duration(res.Ack)*time.Second {
 		return errors.New("ack timeout")
 	}
 
-	doc := bson.M{"project_uuid": projectUUID, "name": name}
-	change := bson.M{"$set": bson.M{"offset": offset, "next_offset": 0, "pending_ack": ""}}
+	doc := bson.M{"project_uuid": projectUUID, "name": name, "version": versionQuery(version)}
+	change := bson.M{"$set": bson.M{"offset": offset, "next_offset": 0, "pending_ack": ""}, "$inc": bson.M{"version": 1, "acked_msgs": offset - res.Offset}}
 	err = c.Update(doc, change)
-	if err != nil && err != mgo.ErrNotFound {
+	if err == mgo.ErrNotFound {
+		return mong.subVersionErr(projectUUID, name)
+	}
+	if err != nil {
 		log.WithFields(
 			log.Fields{
 				"type":            "backend_log",
@@ -451,7 +589,7 @@ func (mong *MongoStore) UpdateSubOffset(projectUUID string, name string, offset
 	c := db.C("subscriptions")
 
 	doc := bson.M{"project_uuid": projectUUID, "name": name}
-	change := bson.M{"$set": bson.M{"offset": offset, "next_offset": 0, "pending_ack": ""}}
+	change := bson.M{"$set": bson.M{"offset": offset, "next_offset": 0, "pending_ack": ""}, "$inc": bson.M{"version": 1}}
 	err := c.Update(doc, change)
 	if err != nil && err != mgo.ErrNotFound {
 		log.WithFields(
@@ -466,13 +604,22 @@ func (mong *MongoStore) UpdateSubOffset(projectUUID string, name string, offset
 }
 
 // HasUsers accepts a user array of usernames and returns the not found
-func (mong *MongoStore) HasUsers(projectUUID string, users []string) (bool, []string) {
+func (mong *MongoStore) HasUsers(projectUUID string, users []string, caseSensitive bool) (bool, []string) {
 	db := mong.Session.DB(mong.Database)
 	var results []QUser
-	var notFound []string
 	c := db.C("users")
 
-	err := c.Find(bson.M{"projects": bson.M{"$elemMatch": bson.M{"project_uuid": projectUUID}}, "name": bson.M{"$in": users}}).All(&results)
+	// without case sensitivity each username is matched as an anchored, case insensitive, literal pattern
+	names := []interface{}{}
+	for _, username := range users {
+		if caseSensitive {
+			names = append(names, username)
+		} else {
+			names = append(names, bson.RegEx{Pattern: "^" + regexp.QuoteMeta(username) + "$", Options: "i"})
+		}
+	}
+
+	err := c.Find(bson.M{"projects": bson.M{"$elemMatch": bson.M{"project_uuid": projectUUID}}, "name": bson.M{"$in": names}}).All(&results)
 	if err != nil {
 		log.WithFields(
 			log.Fields{
@@ -483,22 +630,13 @@ func (mong *MongoStore) HasUsers(projectUUID string, users []string) (bool, []st
 		).Fatal(err.Error())
 	}
 
-	// for each given username
-	for _, username := range users {
-		found := false
-		// loop through all found users
-		for _, user := range results {
-			if username == user.Name {
-				found = true
-			}
-		}
-		// if not found add it to the notFound
-		if !found {
-			notFound = append(notFound, username)
-		}
-
+	found := []string{}
+	for _, user := range results {
+		found = append(found, user.Name)
 	}
 
+	notFound := missingUsernames(users, found, caseSensitive)
+
 	return len(notFound) == 0, notFound
 }
 
@@ -683,6 +821,65 @@ func (mong *MongoStore) PaginatedQueryUsers(pageToken string, pageSize int32, pr
 	return qUsers, totalSize, nextPageToken, err
 }
 
+// PaginatedQueryUsersByName returns a page of users ordered by name. The page token is the name of the first user
+// of the page, so that a listing resumes where it stopped even if users get created or removed in between
+func (mong *MongoStore) PaginatedQueryUsersByName(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error) {
+
+	var qUsers []QUser
+	var nextPageToken string
+
+	// grab one more user to check if there is a next page, a zero page size means no limit
+	limit := 0
+	if pageSize > 0 {
+		limit = int(pageSize) + 1
+	}
+
+	query := bson.M{}
+	if projectUUID != "" {
+		query["projects"] = bson.M{
+			"$elemMatch": bson.M{
+				"project_uuid": projectUUID,
+			},
+		}
+	}
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("users")
+
+	// the total of the users selected by the query not taking into account pagination
+	size, err := c.Find(query).Count()
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	if pageToken != "" {
+		query["name"] = bson.M{"$gte": pageToken}
+	}
+
+	if err = c.Find(query).Sort("name", "_id").Limit(limit).All(&qUsers); err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	if pageSize > 0 && len(qUsers) == limit {
+		nextPageToken = qUsers[limit-1].Name
+		qUsers = qUsers[:len(qUsers)-1]
+	}
+
+	return qUsers, int32(size), nextPageToken, err
+}
+
 //QuerySubsByTopic returns subscriptions of a specific topic
 func (mong *MongoStore) QuerySubsByTopic(projectUUID, topic string) ([]QSub, error) {
 	// By default return all subs of a given project
@@ -739,12 +936,12 @@ func (mong *MongoStore) QuerySubsByACL(projectUUID, user string) ([]QSub, error)
 
 //QueryTopicsByACL returns topics that a specific username has access to
 func (mong *MongoStore) QueryTopicsByACL(projectUUID, user string) ([]QTopic, error) {
-	// By default return all topics of a given project
-	query := bson.M{"project_uuid": projectUUID}
+	// By default return all topics of a given project, soft-deleted topics are hidden like in QueryTopics
+	query := bson.M{"project_uuid": projectUUID, "deleted_on": bson.M{"$exists": false}}
 
 	// If name is given return only the specific topic
 	if user != "" {
-		query = bson.M{"project_uuid": projectUUID, "acl": user}
+		query["acl"] = user
 	}
 	db := mong.Session.DB(mong.Database)
 	c := db.C("topics")
@@ -765,7 +962,7 @@ func (mong *MongoStore) QueryTopicsByACL(projectUUID, user string) ([]QTopic, er
 }
 
 // QueryTopics Query Subscription info from store
-func (mong *MongoStore) QueryTopics(projectUUID, userUUID, name, pageToken string, pageSize int32) ([]QTopic, int32, string, error) {
+func (mong *MongoStore) QueryTopics(projectUUID, userUUID, name, pageToken string, pageSize int32, showDeleted bool, selector labels.Selector, namePrefix string) ([]QTopic, int32, string, error) {
 
 	var err error
 	var totalSize int32
@@ -783,6 +980,15 @@ func (mong *MongoStore) QueryTopics(projectUUID, userUUID, name, pageToken strin
 		query["acl"] = bson.M{"$in": []string{userUUID}}
 	}
 
+	// soft-deleted topics are hidden unless explicitly requested
+	if !showDeleted {
+		query["deleted_on"] = bson.M{"$exists": false}
+	}
+
+	// find the topics that fulfill the label selector and whose name starts with the prefix
+	selectLabels(query, selector)
+	selectNamePrefix(query, namePrefix)
+
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
 	// will be a next page after the current one
 	if pageSize > 0 {
@@ -833,6 +1039,11 @@ func (mong *MongoStore) QueryTopics(projectUUID, userUUID, name, pageToken strin
 		if userUUID != "" {
 			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
 		}
+		if !showDeleted {
+			countQuery["deleted_on"] = bson.M{"$exists": false}
+		}
+		selectLabels(countQuery, selector)
+		selectNamePrefix(countQuery, namePrefix)
 
 		if size, err = c.Find(countQuery).Count(); err != nil {
 			log.WithFields(
@@ -860,8 +1071,29 @@ func (mong *MongoStore) QueryTopics(projectUUID, userUUID, name, pageToken strin
 
 }
 
-// UpdateTopicLatestPublish updates the topic's latest publish time
-func (mong *MongoStore) UpdateTopicLatestPublish(projectUUID string, name string, date time.Time) error {
+// QueryDeletedTopics returns all the soft-deleted topics that were deleted before the given time
+func (mong *MongoStore) QueryDeletedTopics(deletedBefore time.Time) ([]QTopic, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+
+	var results []QTopic
+	err := c.Find(bson.M{"deleted_on": bson.M{"$lte": deletedBefore}}).All(&results)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	return results, err
+}
+
+// SoftDeleteTopic marks a topic as deleted without removing it from the store
+func (mong *MongoStore) SoftDeleteTopic(projectUUID string, name string, deletedOn time.Time) error {
 
 	db := mong.Session.DB(mong.Database)
 	c := db.C("topics")
@@ -873,15 +1105,15 @@ func (mong *MongoStore) UpdateTopicLatestPublish(projectUUID string, name string
 
 	change := bson.M{
 		"$set": bson.M{
-			"latest_publish": date,
+			"deleted_on": deletedOn,
 		},
 	}
 
 	return c.Update(doc, change)
 }
 
-// UpdateTopicPublishRate updates the topic's publishing rate
-func (mong *MongoStore) UpdateTopicPublishRate(projectUUID string, name string, rate float64) error {
+// RestoreTopic clears the deleted mark of a soft-deleted topic
+func (mong *MongoStore) RestoreTopic(projectUUID string, name string) error {
 
 	db := mong.Session.DB(mong.Database)
 	c := db.C("topics")
@@ -892,19 +1124,104 @@ func (mong *MongoStore) UpdateTopicPublishRate(projectUUID string, name string,
 	}
 
 	change := bson.M{
-		"$set": bson.M{
-			"publish_rate": rate,
+		"$unset": bson.M{
+			"deleted_on": "",
 		},
 	}
 
 	return c.Update(doc, change)
 }
 
-// UpdateSubLatestConsume updates the subscription's latest consume time
-func (mong *MongoStore) UpdateSubLatestConsume(projectUUID string, name string, date time.Time) error {
+// RenameTopic renames a topic, pointing it to the given broker topic, along with the subscriptions,
+// the message counts and the publish times that refer to it.
+// The subscriptions are moved first and the topic document last, so that a failure never leaves the topic renamed
+// without its subscriptions. The subscriptions that got moved are moved back if the rest of the rename fails
+func (mong *MongoStore) RenameTopic(projectUUID string, name string, newName string, brokerTopic string) error {
 
 	db := mong.Session.DB(mong.Database)
-	c := db.C("subscriptions")
+	c := db.C("topics")
+	subs := db.C("subscriptions")
+
+	n, err := c.Find(bson.M{"project_uuid": projectUUID, "name": name}).Count()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("not found")
+	}
+
+	// only the subscriptions that are moved get moved back, since subscriptions of a former topic with the new name may exist
+	var subIDs []struct {
+		ID bson.ObjectId `bson:"_id"`
+	}
+	if err := subs.Find(bson.M{"project_uuid": projectUUID, "topic": name}).Select(bson.M{"_id": 1}).All(&subIDs); err != nil {
+		return err
+	}
+	ids := make([]bson.ObjectId, 0, len(subIDs))
+	for _, sub := range subIDs {
+		ids = append(ids, sub.ID)
+	}
+
+	restoreSubs := func() {
+		_, rbErr := subs.UpdateAll(bson.M{"_id": bson.M{"$in": ids}, "topic": newName}, bson.M{"$set": bson.M{"topic": name}})
+		if rbErr != nil {
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"backend_service": "mongo",
+					"backend_hosts":   mong.Server,
+					"error":           rbErr.Error(),
+				},
+			).Errorf("Could not restore the topic of the subscriptions of topic %v", name)
+		}
+	}
+
+	if len(ids) > 0 {
+		if _, err := subs.UpdateAll(bson.M{"_id": bson.M{"$in": ids}}, bson.M{"$set": bson.M{"topic": newName}}); err != nil {
+			restoreSubs()
+			return err
+		}
+	}
+
+	change := bson.M{"$set": bson.M{"name": newName, "broker_topic": brokerTopic}}
+	if brokerTopic == "" {
+		change = bson.M{"$set": bson.M{"name": newName}, "$unset": bson.M{"broker_topic": ""}}
+	}
+
+	// the topic document is updated in a single write, so its name and broker topic change together or not at all
+	if err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, change); err != nil {
+		restoreSubs()
+		if err == mgo.ErrNotFound {
+			return errors.New("not found")
+		}
+		return err
+	}
+
+	// the message counts, publish times and samples only serve metrics and lookups by time,
+	// so failing to rename them doesn't fail the rename of the topic
+	for _, col := range []string{"daily_topic_msg_count", "topic_offsets", "topic_samples"} {
+		_, err := db.C(col).UpdateAll(bson.M{"project_uuid": projectUUID, "topic_name": name}, bson.M{"$set": bson.M{"topic_name": newName}})
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":            "backend_log",
+					"backend_service": "mongo",
+					"backend_hosts":   mong.Server,
+					"collection":      col,
+					"error":           err.Error(),
+				},
+			).Errorf("Could not rename topic %v to %v", name, newName)
+		}
+	}
+
+	return nil
+}
+
+// UpdateTopicLatestPublish updates the topic's latest publish time
+func (mong *MongoStore) UpdateTopicLatestPublish(projectUUID string, name string, date time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
 
 	doc := bson.M{
 		"project_uuid": projectUUID,
@@ -913,18 +1230,18 @@ func (mong *MongoStore) UpdateSubLatestConsume(projectUUID string, name string,
 
 	change := bson.M{
 		"$set": bson.M{
-			"latest_consume": date,
+			"latest_publish": date,
 		},
 	}
 
 	return c.Update(doc, change)
 }
 
-// UpdateSubConsumeRate updates the subscription's consume rate
-func (mong *MongoStore) UpdateSubConsumeRate(projectUUID string, name string, rate float64) error {
+// UpdateTopicPublishRate updates the topic's publishing rate
+func (mong *MongoStore) UpdateTopicPublishRate(projectUUID string, name string, rate float64) error {
 
 	db := mong.Session.DB(mong.Database)
-	c := db.C("subscriptions")
+	c := db.C("topics")
 
 	doc := bson.M{
 		"project_uuid": projectUUID,
@@ -933,32 +1250,130 @@ func (mong *MongoStore) UpdateSubConsumeRate(projectUUID string, name string, ra
 
 	change := bson.M{
 		"$set": bson.M{
-			"consume_rate": rate,
+			"publish_rate": rate,
 		},
 	}
 
 	return c.Update(doc, change)
 }
 
-// QueryDailyTopicMsgCount returns results regarding the number of messages published to a topic
-func (mong *MongoStore) QueryDailyTopicMsgCount(projectUUID string, topicName string, date time.Time) ([]QDailyTopicMsgCount, error) {
-
-	var err error
-	var qDailyTopicMsgCount []QDailyTopicMsgCount
-	var query bson.M
-
-	// represents an empty time object
-	var zeroValueTime time.Time
+// UpdateTopicLabels replaces the labels of a topic
+func (mong *MongoStore) UpdateTopicLabels(projectUUID string, name string, labels map[string]string) error {
 
-	query = bson.M{"date": date, "project_uuid": projectUUID, "topic_name": topicName}
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
 
-	// if nothing's specified return the whole collection
-	if projectUUID == "" && topicName == "" && date == zeroValueTime {
-		query = bson.M{}
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
 	}
 
-	if projectUUID != "" && topicName != "" && date == zeroValueTime {
-		query = bson.M{"project_uuid": projectUUID, "topic_name": topicName}
+	change := bson.M{"$set": bson.M{"labels": labels}}
+	if len(labels) == 0 {
+		change = bson.M{"$unset": bson.M{"labels": ""}}
+	}
+
+	return c.Update(doc, change)
+}
+
+// UpdateTopicPartitionKey updates the message attribute that holds the partition key of the topic's messages
+func (mong *MongoStore) UpdateTopicPartitionKey(projectUUID string, name string, attribute string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topics")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}
+
+	change := bson.M{"$set": bson.M{"partition_key_attribute": attribute}}
+	if attribute == "" {
+		change = bson.M{"$unset": bson.M{"partition_key_attribute": ""}}
+	}
+
+	return c.Update(doc, change)
+}
+
+// UpdateSubLatestConsume updates the subscription's latest consume time
+func (mong *MongoStore) UpdateSubLatestConsume(projectUUID string, name string, date time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"latest_consume": date,
+		},
+	}
+
+	return c.Update(doc, change)
+}
+
+// UpdateSubLastProgress updates the latest time the subscription's offset was advanced by its consumers
+func (mong *MongoStore) UpdateSubLastProgress(projectUUID string, name string, date time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"last_progress": date,
+		},
+	}
+
+	return c.Update(doc, change)
+}
+
+// UpdateSubConsumeRate updates the subscription's consume rate
+func (mong *MongoStore) UpdateSubConsumeRate(projectUUID string, name string, rate float64) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	doc := bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}
+
+	change := bson.M{
+		"$set": bson.M{
+			"consume_rate": rate,
+		},
+	}
+
+	return c.Update(doc, change)
+}
+
+// QueryDailyTopicMsgCount returns results regarding the number of messages published to a topic
+func (mong *MongoStore) QueryDailyTopicMsgCount(projectUUID string, topicName string, date time.Time) ([]QDailyTopicMsgCount, error) {
+
+	var err error
+	var qDailyTopicMsgCount []QDailyTopicMsgCount
+	var query bson.M
+
+	// represents an empty time object
+	var zeroValueTime time.Time
+
+	query = bson.M{"date": date, "project_uuid": projectUUID, "topic_name": topicName}
+
+	// if nothing's specified return the whole collection
+	if projectUUID == "" && topicName == "" && date == zeroValueTime {
+		query = bson.M{}
+	}
+
+	if projectUUID != "" && topicName != "" && date == zeroValueTime {
+		query = bson.M{"project_uuid": projectUUID, "topic_name": topicName}
 	}
 
 	db := mong.Session.DB(mong.Database)
@@ -1009,6 +1424,167 @@ func (mong *MongoStore) IncrementDailyTopicMsgCount(projectUUID string, topicNam
 
 }
 
+// InsertOffsetTime records the publish time of the message stored at the given offset of a topic
+func (mong *MongoStore) InsertOffsetTime(projectUUID string, topicName string, offset int64, publishTime time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_offsets")
+
+	doc := bson.M{"project_uuid": projectUUID, "topic_name": topicName, "offset": offset}
+	change := bson.M{"$set": bson.M{"publish_time": publishTime}}
+
+	_, err := c.Upsert(doc, change)
+
+	return err
+}
+
+// QueryOffsetByTime returns the first offset of a topic that was published at or after the given time,
+// or -1 if no such message has been recorded
+func (mong *MongoStore) QueryOffsetByTime(projectUUID string, topicName string, t time.Time) (int64, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_offsets")
+
+	var results []QOffsetTime
+	query := bson.M{"project_uuid": projectUUID, "topic_name": topicName, "publish_time": bson.M{"$gte": t}}
+
+	err := c.Find(query).Sort("offset").Limit(1).All(&results)
+
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+		return -1, err
+	}
+
+	if len(results) == 0 {
+		return -1, nil
+	}
+
+	return results[0].Offset, nil
+}
+
+// RemoveOffsetTimes removes the publish times kept for the offsets of a topic before the given one
+func (mong *MongoStore) RemoveOffsetTimes(projectUUID string, topicName string, before int64) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_offsets")
+
+	_, err := c.RemoveAll(bson.M{"project_uuid": projectUUID, "topic_name": topicName, "offset": bson.M{"$lt": before}})
+
+	return err
+}
+
+// InsertTopicSample records the counters of a topic at the given time
+func (mong *MongoStore) InsertTopicSample(projectUUID string, topicName string, timestamp time.Time, msgNum int64, totalBytes int64) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_samples")
+
+	sample := QTopicSample{
+		ProjectUUID: projectUUID,
+		TopicName:   topicName,
+		Timestamp:   timestamp,
+		MsgNum:      msgNum,
+		TotalBytes:  totalBytes,
+	}
+
+	return c.Insert(sample)
+}
+
+// QueryTopicSamples returns the samples of a topic taken at or after the given time, oldest first
+func (mong *MongoStore) QueryTopicSamples(projectUUID string, topicName string, since time.Time) ([]QTopicSample, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_samples")
+
+	results := []QTopicSample{}
+	query := bson.M{"project_uuid": projectUUID, "topic_name": topicName, "timestamp": bson.M{"$gte": since}}
+
+	err := c.Find(query).Sort("timestamp").All(&results)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Error(err.Error())
+		return results, err
+	}
+
+	return results, nil
+}
+
+// RemoveTopicSamples removes the samples of all topics taken before the given time
+func (mong *MongoStore) RemoveTopicSamples(before time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("topic_samples")
+
+	_, err := c.RemoveAll(bson.M{"timestamp": bson.M{"$lt": before}})
+
+	return err
+}
+
+// InsertOffsetSnapshot records the offsets of a project's subscriptions under the snapshot's name
+func (mong *MongoStore) InsertOffsetSnapshot(projectUUID string, name string, createdOn time.Time, offsets []QSubOffset) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("offset_snapshots")
+
+	snapshot := QOffsetSnapshot{
+		ProjectUUID: projectUUID,
+		Name:        name,
+		CreatedOn:   createdOn,
+		Offsets:     offsets,
+	}
+
+	return c.Insert(snapshot)
+}
+
+// QueryOffsetSnapshots returns the offset snapshots of a project, or only the one with the given name, oldest first
+func (mong *MongoStore) QueryOffsetSnapshots(projectUUID string, name string) ([]QOffsetSnapshot, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("offset_snapshots")
+
+	results := []QOffsetSnapshot{}
+	query := bson.M{"project_uuid": projectUUID}
+	if name != "" {
+		query["name"] = name
+	}
+
+	err := c.Find(query).Sort("created_on").All(&results)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Error(err.Error())
+		return results, err
+	}
+
+	return results, nil
+}
+
+// RemoveOffsetSnapshots removes the offset snapshots of all projects taken before the given time
+func (mong *MongoStore) RemoveOffsetSnapshots(before time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("offset_snapshots")
+
+	_, err := c.RemoveAll(bson.M{"created_on": bson.M{"$lt": before}})
+
+	return err
+}
+
 //IncrementTopicBytes increases the total number of bytes published in a topic
 func (mong *MongoStore) IncrementTopicBytes(projectUUID string, name string, totalBytes int64) error {
 	db := mong.Session.DB(mong.Database)
@@ -1094,6 +1670,69 @@ func (mong *MongoStore) GetAllRoles() []string {
 	return results
 }
 
+// QueryRoles returns the role mappings of a specific operation or of all operations if the operation is empty
+func (mong *MongoStore) QueryRoles(operation string) ([]QRole, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("roles")
+
+	query := bson.M{}
+
+	if operation != "" {
+		query["resource"] = operation
+	}
+
+	var results []QRole
+	err := c.Find(query).Sort("resource").All(&results)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	return results, nil
+}
+
+// InsertRole inserts a new mapping between an operation and the roles that grant access to it
+func (mong *MongoStore) InsertRole(operation string, roles []string) error {
+	role := QRole{
+		Name:  operation,
+		Roles: roles,
+	}
+	return mong.InsertResource("roles", role)
+}
+
+// UpdateRole updates the roles that grant access to an operation
+func (mong *MongoStore) UpdateRole(operation string, roles []string) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("roles")
+
+	doc := bson.M{"resource": operation}
+	change := bson.M{"$set": bson.M{"roles": roles}}
+	err := c.Update(doc, change)
+	if err != nil && err != mgo.ErrNotFound {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Fatal(err.Error())
+	}
+
+	return err
+}
+
+// RemoveRole removes the mapping of an operation to its roles
+func (mong *MongoStore) RemoveRole(operation string) error {
+	return mong.RemoveResource("roles", bson.M{"resource": operation})
+}
+
 //GetOpMetrics returns the operational metrics from datastore
 func (mong *MongoStore) GetOpMetrics() []QopMetric {
 
@@ -1243,7 +1882,7 @@ func (mong *MongoStore) HasProject(name string) bool {
 }
 
 // InsertTopic inserts a topic to the store
-func (mong *MongoStore) InsertTopic(projectUUID string, name string, schemaUUID string, createdOn time.Time) error {
+func (mong *MongoStore) InsertTopic(projectUUID string, name string, schemaUUID string, publishAcks string, createdOn time.Time) error {
 
 	topic := QTopic{
 		ProjectUUID:   projectUUID,
@@ -1255,6 +1894,7 @@ func (mong *MongoStore) InsertTopic(projectUUID string, name string, schemaUUID
 		SchemaUUID:    schemaUUID,
 		CreatedOn:     createdOn,
 		ACL:           []string{},
+		PublishAcks:   publishAcks,
 	}
 
 	return mong.InsertResource("topics", topic)
@@ -1308,28 +1948,11 @@ func (mong *MongoStore) InsertProject(uuid string, name string, createdOn time.T
 	return mong.InsertResource("projects", project)
 }
 
-// InsertSub inserts a subscription to the store
-func (mong *MongoStore) InsertSub(projectUUID string, name string, topic string, offset int64, maxMessages int64, authzType string, authzHeader string, ack int, push string, rPolicy string, rPeriod int, vhash string, verified bool, createdOn time.Time) error {
-	sub := QSub{
-		ProjectUUID:         projectUUID,
-		Name:                name,
-		Topic:               topic,
-		Offset:              offset,
-		NextOffset:          0,
-		PendingAck:          "",
-		Ack:                 ack,
-		MaxMessages:         maxMessages,
-		AuthorizationType:   authzType,
-		AuthorizationHeader: authzHeader,
-		PushEndpoint:        push,
-		RetPolicy:           rPolicy,
-		RetPeriod:           rPeriod,
-		VerificationHash:    vhash,
-		Verified:            verified,
-		MsgNum:              0,
-		TotalBytes:          0,
-		CreatedOn:           createdOn,
-		ACL:                 []string{},
+// InsertSub inserts a subscription to the store along with all of its settings
+func (mong *MongoStore) InsertSub(sub QSub) error {
+	sub.ID = nil
+	if sub.ACL == nil {
+		sub.ACL = []string{}
 	}
 	return mong.InsertResource("subscriptions", sub)
 }
@@ -1343,7 +1966,13 @@ func (mong *MongoStore) RemoveProjectTopics(projectUUID string) error {
 // RemoveProjectSubs removes all subscriptions related to a project UUID
 func (mong *MongoStore) RemoveProjectSubs(projectUUID string) error {
 	subMatch := bson.M{"project_uuid": projectUUID}
-	return mong.RemoveAll("subscriptions", subMatch)
+	if err := mong.RemoveAll("subscriptions", subMatch); err != nil {
+		return err
+	}
+	if err := mong.RemoveAll("pull_leases", subMatch); err != nil {
+		return err
+	}
+	return mong.RemoveAll("acked_ids", subMatch)
 }
 
 // QueryTotalMessagesPerProject returns the total amount of messages per project for the given time window
@@ -1505,7 +2134,14 @@ func (mong *MongoStore) RemoveUser(uuid string) error {
 // RemoveSub removes a subscription from the store
 func (mong *MongoStore) RemoveSub(projectUUID string, name string) error {
 	sub := bson.M{"project_uuid": projectUUID, "name": name}
-	return mong.RemoveResource("subscriptions", sub)
+	if err := mong.RemoveResource("subscriptions", sub); err != nil {
+		return err
+	}
+	// a subscription created later under the same name starts without acknowledged ids or pulls in progress
+	if err := mong.RemoveAll("pull_leases", bson.M{"project_uuid": projectUUID, "subscription": name}); err != nil {
+		return err
+	}
+	return mong.RemoveAll("acked_ids", bson.M{"project_uuid": projectUUID, "subscription": name})
 }
 
 // ExistsInACL checks if a user is part of a topic's or sub's acl
@@ -1541,10 +2177,38 @@ func (mong *MongoStore) ModACL(projectUUID string, resource string, name string,
 
 	c := db.C(resource)
 
-	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"acl": acl}})
+	err := c.Update(bson.M{"project_uuid": projectUUID, "name": name}, bson.M{"$set": bson.M{"acl": acl}, "$inc": bson.M{"acl_version": 1}})
 	return err
 }
 
+// ModACLVersion replaces the acl of a resource as long as it hasn't been modified since the given version
+func (mong *MongoStore) ModACLVersion(projectUUID string, resource string, name string, acl []string, version int64) error {
+	db := mong.Session.DB(mong.Database)
+
+	if resource != "topics" && resource != "subscriptions" {
+		return errors.New("wrong resource type")
+	}
+
+	c := db.C(resource)
+
+	doc := bson.M{"project_uuid": projectUUID, "name": name, "acl_version": versionQuery(version)}
+	err := c.Update(doc, bson.M{"$set": bson.M{"acl": acl}, "$inc": bson.M{"acl_version": 1}})
+	if err != mgo.ErrNotFound {
+		return err
+	}
+
+	// tell apart a resource that doesn't exist from one whose acl has been modified in the meantime
+	count, err := c.Find(bson.M{"project_uuid": projectUUID, "name": name}).Count()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return mgo.ErrNotFound
+	}
+
+	return errors.New("conflict")
+}
+
 // AppendToACL adds additional users to an existing ACL
 func (mong *MongoStore) AppendToACL(projectUUID string, resource string, name string, acl []string) error {
 
@@ -1566,7 +2230,9 @@ func (mong *MongoStore) AppendToACL(projectUUID string, resource string, name st
 				"acl": bson.M{
 					"$each": acl,
 				},
-			}})
+			},
+			"$inc": bson.M{"acl_version": 1},
+		})
 	return err
 }
 
@@ -1590,11 +2256,66 @@ func (mong *MongoStore) RemoveFromACL(projectUUID string, resource string, name
 			"$pullAll": bson.M{
 				"acl": acl,
 			},
+			"$inc": bson.M{"acl_version": 1},
 		})
 
 	return err
 }
 
+// InsertACLChange appends the acl that a modification left the resource with to its history
+func (mong *MongoStore) InsertACLChange(projectUUID string, resource string, name string, operation string, acl []string, actor string, changedOn time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("acl_history")
+
+	change := QACLChange{
+		ProjectUUID:  projectUUID,
+		Resource:     resource,
+		ResourceName: name,
+		Operation:    operation,
+		ACL:          acl,
+		Actor:        actor,
+		ChangedOn:    changedOn,
+	}
+
+	return c.Insert(change)
+}
+
+// QueryACLHistory returns the acl changes of a resource, oldest first
+func (mong *MongoStore) QueryACLHistory(projectUUID string, resource string, name string) ([]QACLChange, error) {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("acl_history")
+
+	results := []QACLChange{}
+	query := bson.M{"project_uuid": projectUUID, "resource": resource, "resource_name": name}
+
+	err := c.Find(query).Sort("changed_on", "_id").All(&results)
+	if err != nil {
+		log.WithFields(
+			log.Fields{
+				"type":            "backend_log",
+				"backend_service": "mongo",
+				"backend_hosts":   mong.Server,
+			},
+		).Error(err.Error())
+		return results, err
+	}
+
+	return results, nil
+}
+
+// RemoveACLHistory removes the acl changes of all resources made before the given time
+func (mong *MongoStore) RemoveACLHistory(before time.Time) error {
+
+	db := mong.Session.DB(mong.Database)
+	c := db.C("acl_history")
+
+	_, err := c.RemoveAll(bson.M{"changed_on": bson.M{"$lt": before}})
+
+	return err
+}
+
 // ModAck modifies the subscription's ack timeout field in mongodb
 func (mong *MongoStore) ModAck(projectUUID string, name string, ack int) error {
 	log.Info("Modifying Ack Deadline", ack)
@@ -1605,7 +2326,7 @@ func (mong *MongoStore) ModAck(projectUUID string, name string, ack int) error {
 }
 
 // ModSubPush modifies the push configuration
-func (mong *MongoStore) ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool) error {
+func (mong *MongoStore) ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool, fanout []string, maxConcurrentDeliveries int, maxRetryDuration int, maxRetries int) error {
 	db := mong.Session.DB(mong.Database)
 	c := db.C("subscriptions")
 
@@ -1614,19 +2335,157 @@ func (mong *MongoStore) ModSubPush(projectUUID string, name string, push string,
 		"name":         name,
 	},
 		bson.M{"$set": bson.M{
-			"push_endpoint":        push,
-			"authorization_type":   authzType,
-			"authorization_header": authzValue,
-			"max_messages":         maxMessages,
-			"retry_policy":         rPolicy,
-			"retry_period":         rPeriod,
-			"verification_hash":    vhash,
-			"verified":             verified,
+			"push_endpoint":             push,
+			"authorization_type":        authzType,
+			"authorization_header":      authzValue,
+			"max_messages":              maxMessages,
+			"retry_policy":              rPolicy,
+			"retry_period":              rPeriod,
+			"verification_hash":         vhash,
+			"verified":                  verified,
+			"fanout_endpoints":          fanout,
+			"max_concurrent_deliveries": maxConcurrentDeliveries,
+			"max_retry_duration":        maxRetryDuration,
+			"max_retries":               maxRetries,
 		},
 		})
 	return err
 }
 
+// ModSubLabels replaces the labels of a subscription
+func (mong *MongoStore) ModSubLabels(projectUUID string, name string, labels map[string]string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	change := bson.M{"$set": bson.M{"labels": labels}}
+	if len(labels) == 0 {
+		change = bson.M{"$unset": bson.M{"labels": ""}}
+	}
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}, change)
+	return err
+}
+
+// ModSubIsolationLevel updates the broker isolation level a subscription consumes with
+func (mong *MongoStore) ModSubIsolationLevel(projectUUID string, name string, level string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	}, bson.M{"$set": bson.M{"isolation_level": level}})
+	return err
+}
+
+// AppendAckedIDs records message ids acknowledged through a subscription, keeping only the latest window of them
+func (mong *MongoStore) AppendAckedIDs(projectUUID string, name string, ids []string, window int) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("acked_ids")
+
+	_, err := c.Upsert(bson.M{
+		"project_uuid": projectUUID,
+		"subscription": name,
+	}, bson.M{"$push": bson.M{"ids": bson.M{"$each": ids, "$slice": -window}}})
+	return err
+}
+
+// QueryAckedIDs returns the latest message ids acknowledged through a subscription
+func (mong *MongoStore) QueryAckedIDs(projectUUID string, name string) ([]string, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("acked_ids")
+
+	res := QAckedIDs{}
+	err := c.Find(bson.M{"project_uuid": projectUUID, "subscription": name}).One(&res)
+	if err == mgo.ErrNotFound {
+		return []string{}, nil
+	}
+	return res.IDs, err
+}
+
+// ModSubMaxConcurrentPulls updates the number of pulls allowed to proceed at once on a subscription
+func (mong *MongoStore) ModSubMaxConcurrentPulls(projectUUID string, name string, max int) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	},
+		bson.M{"$set": bson.M{"max_concurrent_pulls": max}},
+	)
+	return err
+}
+
+// ModSubDefaultMaxMessages updates the number of messages returned by the pulls of a subscription that don't declare maxMessages
+func (mong *MongoStore) ModSubDefaultMaxMessages(projectUUID string, name string, max int) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	},
+		bson.M{"$set": bson.M{"default_max_messages": max}},
+	)
+	return err
+}
+
+// AcquirePullLease adds a lease to the pulls in progress on a subscription, unless max unexpired leases are already held.
+// Expired leases are dropped first, so that pulls of a node that went away don't hold on to them
+func (mong *MongoStore) AcquirePullLease(projectUUID string, name string, leaseID string, max int, now time.Time, expiresAt time.Time) (bool, error) {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("pull_leases")
+
+	query := bson.M{"project_uuid": projectUUID, "subscription": name}
+
+	_, err := c.Upsert(query, bson.M{"$pull": bson.M{"leases": bson.M{"expires_at": bson.M{"$lte": now}}}})
+	if err != nil {
+		return false, err
+	}
+
+	// the lease gets added only while the list holds less than max leases, checked and updated atomically
+	query[fmt.Sprintf("leases.%d", max-1)] = bson.M{"$exists": false}
+	err = c.Update(query, bson.M{"$push": bson.M{"leases": QPullLease{ID: leaseID, ExpiresAt: expiresAt}}})
+	if err == mgo.ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ReleasePullLease removes the lease of a completed pull from a subscription
+func (mong *MongoStore) ReleasePullLease(projectUUID string, name string, leaseID string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("pull_leases")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"subscription": name,
+	}, bson.M{"$pull": bson.M{"leases": bson.M{"id": leaseID}}})
+	if err == mgo.ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// ResetSubAckedMsgs sets the number of messages acknowledged through a subscription back to zero
+func (mong *MongoStore) ResetSubAckedMsgs(projectUUID string, name string) error {
+	db := mong.Session.DB(mong.Database)
+	c := db.C("subscriptions")
+
+	err := c.Update(bson.M{
+		"project_uuid": projectUUID,
+		"name":         name,
+	},
+		bson.M{"$set": bson.M{"acked_msgs": 0}},
+	)
+	return err
+}
+
 // InsertResource inserts a new topic object to the datastore
 func (mong *MongoStore) InsertResource(col string, res interface{}) error {
 
@@ -1662,8 +2521,27 @@ func (mong *MongoStore) RemoveResource(col string, res interface{}) error {
 	return err
 }
 
+// selectNamePrefix restricts a query to the documents whose name starts with the given prefix, the match is case-sensitive
+func selectNamePrefix(query bson.M, prefix string) {
+	if prefix == "" {
+		return
+	}
+	query["name"] = bson.RegEx{Pattern: "^" + regexp.QuoteMeta(prefix)}
+}
+
+// selectLabels adds the requirements of a label selector to a query
+func selectLabels(query bson.M, selector labels.Selector) {
+	for k, values := range selector {
+		if len(values) == 1 {
+			query["labels."+k] = values[0]
+			continue
+		}
+		query["labels."+k] = bson.M{"$in": values}
+	}
+}
+
 // QuerySubs Query Subscription info from store
-func (mong *MongoStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pageSize int32) ([]QSub, int32, string, error) {
+func (mong *MongoStore) QuerySubs(projectUUID, userUUID, name, pageToken string, pageSize int32, selector labels.Selector, namePrefix string) ([]QSub, int32, string, error) {
 
 	var err error
 	var totalSize int32
@@ -1681,6 +2559,10 @@ func (mong *MongoStore) QuerySubs(projectUUID, userUUID, name, pageToken string,
 		query["acl"] = bson.M{"$in": []string{userUUID}}
 	}
 
+	// find the subscriptions that fulfill the label selector and whose name starts with the prefix
+	selectLabels(query, selector)
+	selectNamePrefix(query, namePrefix)
+
 	// if the page size is other than zero(where zero means, no limit), try to grab one more document to check if there
 	// will be a next page after the current one
 	if pageSize > 0 {
@@ -1731,6 +2613,8 @@ func (mong *MongoStore) QuerySubs(projectUUID, userUUID, name, pageToken string,
 		if userUUID != "" {
 			countQuery["acl"] = bson.M{"$in": []string{userUUID}}
 		}
+		selectLabels(countQuery, selector)
+		selectNamePrefix(countQuery, namePrefix)
 
 		if size, err = c.Find(countQuery).Count(); err != nil {
 			log.WithFields(
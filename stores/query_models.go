@@ -2,6 +2,8 @@ package stores
 
 import (
 	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
 )
 
 // QSub are the results of the Qsub query
@@ -28,11 +30,82 @@ type QSub struct {
 	ConsumeRate         float64     `bson:"consume_rate"`
 	CreatedOn           time.Time   `bson:"created_on"`
 	ACL                 []string    `bson:"acl"`
+	FanoutEndpoints     []string    `bson:"fanout_endpoints"`
+	Transform           *QTransform `bson:"transform,omitempty"`
+	// Version increases on every offset update and guards against concurrent updates overwriting each other
+	Version int64 `bson:"version"`
+	// NewMessagesOnly restricts the delivery to messages published at or after the subscription's creation
+	NewMessagesOnly bool `bson:"new_messages_only"`
+	// MaxConcurrentDeliveries is the number of push deliveries kept in flight, zero meaning the default
+	MaxConcurrentDeliveries int `bson:"max_concurrent_deliveries,omitempty"`
+	// Labels are key/value pairs that organize subscriptions, e.g. by team or environment
+	Labels map[string]string `bson:"labels,omitempty"`
+	// Deduplicate skips the delivery of messages whose ids have already been acknowledged
+	Deduplicate bool `bson:"deduplicate,omitempty"`
+	// MaxConcurrentPulls is the number of pulls allowed to proceed at once, zero meaning no limit
+	MaxConcurrentPulls int `bson:"max_concurrent_pulls,omitempty"`
+	// Prioritize delivers the higher priority messages of a pulled batch ahead of the rest
+	Prioritize bool `bson:"prioritize,omitempty"`
+	// LastProgress is the latest time the subscription's consumers advanced its offset
+	LastProgress time.Time `bson:"last_progress,omitempty"`
+	// AtMostOnce commits the offset of a pull before the messages are returned, instead of waiting for their acknowledgement
+	AtMostOnce bool `bson:"at_most_once,omitempty"`
+	// MaxRetryDuration is the number of seconds a push delivery of a message is retried for before it is given up on, zero meaning no limit
+	MaxRetryDuration int `bson:"max_retry_duration,omitempty"`
+	// AckedMsgs counts the messages acknowledged through the subscription, it only goes back to zero when explicitly reset
+	AckedMsgs int64 `bson:"acked_msgs,omitempty"`
+	// IsolationLevel is the broker isolation level the subscription consumes with, empty meaning read_committed
+	IsolationLevel string `bson:"isolation_level,omitempty"`
+	// DefaultMaxMessages is the number of messages returned by the pulls that don't declare maxMessages, zero meaning the service's default
+	DefaultMaxMessages int `bson:"default_max_messages,omitempty"`
+	// SamplingRate is the fraction of the messages that the subscription's pulls deliver, zero meaning all of them
+	SamplingRate float64 `bson:"sampling_rate,omitempty"`
+	// MaxRetries is the number of times a failed push delivery of a message is retried on each endpoint, zero meaning no limit
+	MaxRetries int `bson:"max_retries,omitempty"`
+}
+
+// QAckedIDs holds the latest message ids acknowledged through a deduplicating subscription
+type QAckedIDs struct {
+	ProjectUUID  string   `bson:"project_uuid"`
+	Subscription string   `bson:"subscription"`
+	IDs          []string `bson:"ids"`
+}
+
+// QPullLeases holds the leases of the pulls in progress on a subscription
+type QPullLeases struct {
+	ProjectUUID  string       `bson:"project_uuid"`
+	Subscription string       `bson:"subscription"`
+	Leases       []QPullLease `bson:"leases"`
+}
+
+// QPullLease is held by a pull in progress until it completes or its expiration time passes
+type QPullLease struct {
+	ID        string    `bson:"id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// QTransform holds the transformation applied to a subscription's messages before their delivery
+type QTransform struct {
+	Type  string `bson:"type"`
+	Field string `bson:"field"`
 }
 
 // QAcl holds a list of authorized users queried from topic or subscription collections
 type QAcl struct {
 	ACL []string `bson:"acl"`
+	// Version counts the modifications of the acl, so that stale modifications can be told apart
+	Version int64 `bson:"acl_version"`
+}
+
+// QACLChange records the acl that a modification left a resource with, along with who modified it and when
+type QACLChange struct {
+	ProjectUUID  string    `bson:"project_uuid"`
+	Resource     string    `bson:"resource"`
+	ResourceName string    `bson:"resource_name"`
+	Operation    string    `bson:"operation"`
+	ACL          []string  `bson:"acl"`
+	Actor        string    `bson:"actor"`
+	ChangedOn    time.Time `bson:"changed_on"`
 }
 
 // QopMetric are the results of the QopMetric query
@@ -50,6 +123,28 @@ type QProject struct {
 	ModifiedOn  time.Time `bson:"modified_on"`
 	CreatedBy   string    `bson:"created_by"`
 	Description string    `bson:"description"`
+	// DefaultACL is granted to every new topic and subscription of the project
+	DefaultACL *QDefaultACL `bson:"default_acl,omitempty"`
+	// PatternACL grants users access to the project's topics and subscriptions whose names match a regular expression
+	PatternACL *QPatternACL `bson:"pattern_acl,omitempty"`
+}
+
+// QDefaultACL holds the uuids of the users that are granted access to every new topic and subscription of a project
+type QDefaultACL struct {
+	Topics        []string `bson:"topics"`
+	Subscriptions []string `bson:"subscriptions"`
+}
+
+// QPatternACL holds the pattern grants of a project's topics and subscriptions
+type QPatternACL struct {
+	Topics        []QPatternGrant `bson:"topics"`
+	Subscriptions []QPatternGrant `bson:"subscriptions"`
+}
+
+// QPatternGrant holds the uuids of the users that are granted access to the resources whose names match the pattern
+type QPatternGrant struct {
+	Pattern string   `bson:"pattern"`
+	Users   []string `bson:"users"`
 }
 
 // QUserRegistration holds information about a UserRegister query
@@ -110,6 +205,21 @@ type QTopic struct {
 	SchemaUUID    string      `bson:"schema_uuid"`
 	CreatedOn     time.Time   `bson:"created_on"`
 	ACL           []string    `bson:"acl"`
+	DeletedOn     time.Time   `bson:"deleted_on,omitempty"`
+	PublishAcks   string      `bson:"publish_acks,omitempty"`
+	// BrokerTopic is set once a topic gets renamed and holds the broker topic it was created with
+	BrokerTopic string            `bson:"broker_topic,omitempty"`
+	Labels      map[string]string `bson:"labels,omitempty"`
+	// PartitionKeyAttribute names the message attribute that holds the partition key of the messages
+	PartitionKeyAttribute string `bson:"partition_key_attribute,omitempty"`
+}
+
+// BrokerTopicName returns the name of the topic in the broker
+func (qTop *QTopic) BrokerTopicName() string {
+	if qTop.BrokerTopic != "" {
+		return qTop.BrokerTopic
+	}
+	return brokers.TopicName(qTop.ProjectUUID, qTop.Name)
 }
 
 // QDailyTopicMsgCount holds information about the daily number of messages published to a topic
@@ -120,6 +230,37 @@ type QDailyTopicMsgCount struct {
 	NumberOfMessages int64     `bson:"msg_count"`
 }
 
+// QOffsetTime maps the offset of a message published to a topic to its publish time
+type QOffsetTime struct {
+	ProjectUUID string    `bson:"project_uuid"`
+	TopicName   string    `bson:"topic_name"`
+	Offset      int64     `bson:"offset"`
+	PublishTime time.Time `bson:"publish_time"`
+}
+
+// QTopicSample records the message and byte counters of a topic at a point in time
+type QTopicSample struct {
+	ProjectUUID string    `bson:"project_uuid"`
+	TopicName   string    `bson:"topic_name"`
+	Timestamp   time.Time `bson:"timestamp"`
+	MsgNum      int64     `bson:"msg_num"`
+	TotalBytes  int64     `bson:"total_bytes"`
+}
+
+// QSubOffset is the offset of a subscription at the time of an offset snapshot
+type QSubOffset struct {
+	Subscription string `bson:"subscription"`
+	Offset       int64  `bson:"offset"`
+}
+
+// QOffsetSnapshot records the offsets of a project's subscriptions at a point in time
+type QOffsetSnapshot struct {
+	ProjectUUID string       `bson:"project_uuid"`
+	Name        string       `bson:"name"`
+	CreatedOn   time.Time    `bson:"created_on"`
+	Offsets     []QSubOffset `bson:"offsets"`
+}
+
 // QDailyProjectMsgCount holds information about the total amount of messages published to all of a project's topics daily
 type QDailyProjectMsgCount struct {
 	Date             time.Time `bson:"date"`
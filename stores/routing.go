@@ -0,0 +1,730 @@
+package stores
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/labels"
+	log "github.com/sirupsen/logrus"
+)
+
+// ProjectRoute pins the resources of a project to a store server and database of their own
+type ProjectRoute struct {
+	ProjectUUID string
+	Server      string
+	Database    string
+}
+
+// ParseProjectRoutes converts route declarations to project routes.
+// A declaration has the form project_uuid=database or project_uuid=server/database,
+// routes that don't declare a server use the default one. Invalid declarations are skipped
+func ParseProjectRoutes(specs []string, defaultServer string) []ProjectRoute {
+
+	routes := []ProjectRoute{}
+	seen := map[string]bool{}
+
+	for _, spec := range specs {
+
+		route := ProjectRoute{Server: defaultServer}
+
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) == 2 {
+			route.ProjectUUID = strings.TrimSpace(parts[0])
+			target := strings.TrimSpace(parts[1])
+			if i := strings.LastIndex(target, "/"); i >= 0 {
+				route.Server = target[:i]
+				route.Database = target[i+1:]
+			} else {
+				route.Database = target
+			}
+		}
+
+		if route.ProjectUUID == "" || route.Server == "" || route.Database == "" || seen[route.ProjectUUID] {
+			log.WithFields(
+				log.Fields{
+					"type":  "service_log",
+					"route": spec,
+				},
+			).Warning("Skipping invalid store project route")
+			continue
+		}
+
+		seen[route.ProjectUUID] = true
+		routes = append(routes, route)
+	}
+
+	return routes
+}
+
+// RoutingStore dispatches the resources of each project, e.g. topics, subscriptions, schemas and metrics,
+// to the store the project is pinned to, or to the shared store if it isn't pinned.
+// Projects, users, registrations, roles and operational metrics always reside in the shared store
+// and operations that span all projects are carried out on every store
+type RoutingStore struct {
+	Shared Store
+	// Pinned holds the stores of the pinned projects, keyed by project uuid
+	Pinned map[string]Store
+}
+
+// NewRoutingStore creates a routing store over the shared store and the stores of the pinned projects
+func NewRoutingStore(shared Store, pinned map[string]Store) *RoutingStore {
+	if pinned == nil {
+		pinned = map[string]Store{}
+	}
+	return &RoutingStore{Shared: shared, Pinned: pinned}
+}
+
+// For returns the store that holds the resources of the given project
+func (rs *RoutingStore) For(projectUUID string) Store {
+	if s, ok := rs.Pinned[projectUUID]; ok {
+		return s
+	}
+	return rs.Shared
+}
+
+// all returns the shared store followed by the stores of the pinned projects
+func (rs *RoutingStore) all() []Store {
+	stores := []Store{rs.Shared}
+	for _, s := range rs.Pinned {
+		stores = append(stores, s)
+	}
+	return stores
+}
+
+// forSchema returns the store that holds the schema with the given uuid
+func (rs *RoutingStore) forSchema(schemaUUID string) Store {
+	for projectUUID, s := range rs.Pinned {
+		if qs, err := s.QuerySchemas(projectUUID, schemaUUID, ""); err == nil && len(qs) > 0 {
+			return s
+		}
+	}
+	return rs.Shared
+}
+
+// Initialize initializes the shared store and the stores of the pinned projects
+func (rs *RoutingStore) Initialize() {
+	for _, s := range rs.all() {
+		s.Initialize()
+	}
+}
+
+// Clone clones the shared store and the stores of the pinned projects
+func (rs *RoutingStore) Clone() Store {
+	pinned := make(map[string]Store, len(rs.Pinned))
+	for projectUUID, s := range rs.Pinned {
+		pinned[projectUUID] = s.Clone()
+	}
+	return NewRoutingStore(rs.Shared.Clone(), pinned)
+}
+
+// Close closes the shared store and the stores of the pinned projects
+func (rs *RoutingStore) Close() {
+	for _, s := range rs.all() {
+		s.Close()
+	}
+}
+
+// Type returns the type of the shared store
+func (rs *RoutingStore) Type() string {
+	return rs.Shared.Type()
+}
+
+// QuerySubsByTopic is served by the store of the project
+func (rs *RoutingStore) QuerySubsByTopic(projectUUID, topic string) ([]QSub, error) {
+	return rs.For(projectUUID).QuerySubsByTopic(projectUUID, topic)
+}
+
+// QueryTopicsByACL is served by the store of the project
+func (rs *RoutingStore) QueryTopicsByACL(projectUUID, user string) ([]QTopic, error) {
+	return rs.For(projectUUID).QueryTopicsByACL(projectUUID, user)
+}
+
+// QuerySubsByACL is served by the store of the project
+func (rs *RoutingStore) QuerySubsByACL(projectUUID, user string) ([]QSub, error) {
+	return rs.For(projectUUID).QuerySubsByACL(projectUUID, user)
+}
+
+// QuerySubs is served by the store of the project
+func (rs *RoutingStore) QuerySubs(projectUUID string, userUUID string, name string, pageToken string, pageSize int32, selector labels.Selector, namePrefix string) ([]QSub, int32, string, error) {
+	return rs.For(projectUUID).QuerySubs(projectUUID, userUUID, name, pageToken, pageSize, selector, namePrefix)
+}
+
+// QueryTopics is served by the store of the project
+func (rs *RoutingStore) QueryTopics(projectUUID string, userUUID string, name string, pageToken string, pageSize int32, showDeleted bool, selector labels.Selector, namePrefix string) ([]QTopic, int32, string, error) {
+	return rs.For(projectUUID).QueryTopics(projectUUID, userUUID, name, pageToken, pageSize, showDeleted, selector, namePrefix)
+}
+
+// QueryDeletedTopics queries the soft-deleted topics of every store
+func (rs *RoutingStore) QueryDeletedTopics(deletedBefore time.Time) ([]QTopic, error) {
+	results := []QTopic{}
+	for _, s := range rs.all() {
+		qTopics, err := s.QueryDeletedTopics(deletedBefore)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, qTopics...)
+	}
+	return results, nil
+}
+
+// QueryDailyTopicMsgCount is served by the store of the project
+func (rs *RoutingStore) QueryDailyTopicMsgCount(projectUUID string, name string, date time.Time) ([]QDailyTopicMsgCount, error) {
+	return rs.For(projectUUID).QueryDailyTopicMsgCount(projectUUID, name, date)
+}
+
+// UpdateTopicLatestPublish is served by the store of the project
+func (rs *RoutingStore) UpdateTopicLatestPublish(projectUUID string, name string, date time.Time) error {
+	return rs.For(projectUUID).UpdateTopicLatestPublish(projectUUID, name, date)
+}
+
+// UpdateTopicPublishRate is served by the store of the project
+func (rs *RoutingStore) UpdateTopicPublishRate(projectUUID string, name string, rate float64) error {
+	return rs.For(projectUUID).UpdateTopicPublishRate(projectUUID, name, rate)
+}
+
+// UpdateTopicLabels is served by the store of the project
+func (rs *RoutingStore) UpdateTopicLabels(projectUUID string, name string, labels map[string]string) error {
+	return rs.For(projectUUID).UpdateTopicLabels(projectUUID, name, labels)
+}
+
+// UpdateTopicPartitionKey is served by the store of the project
+func (rs *RoutingStore) UpdateTopicPartitionKey(projectUUID string, name string, attribute string) error {
+	return rs.For(projectUUID).UpdateTopicPartitionKey(projectUUID, name, attribute)
+}
+
+// UpdateSubLatestConsume is served by the store of the project
+func (rs *RoutingStore) UpdateSubLatestConsume(projectUUID string, name string, date time.Time) error {
+	return rs.For(projectUUID).UpdateSubLatestConsume(projectUUID, name, date)
+}
+
+// UpdateSubConsumeRate is served by the store of the project
+func (rs *RoutingStore) UpdateSubConsumeRate(projectUUID string, name string, rate float64) error {
+	return rs.For(projectUUID).UpdateSubConsumeRate(projectUUID, name, rate)
+}
+
+// UpdateSubLastProgress is served by the store of the project
+func (rs *RoutingStore) UpdateSubLastProgress(projectUUID string, name string, date time.Time) error {
+	return rs.For(projectUUID).UpdateSubLastProgress(projectUUID, name, date)
+}
+
+// RemoveTopic is served by the store of the project
+func (rs *RoutingStore) RemoveTopic(projectUUID string, name string) error {
+	return rs.For(projectUUID).RemoveTopic(projectUUID, name)
+}
+
+// SoftDeleteTopic is served by the store of the project
+func (rs *RoutingStore) SoftDeleteTopic(projectUUID string, name string, deletedOn time.Time) error {
+	return rs.For(projectUUID).SoftDeleteTopic(projectUUID, name, deletedOn)
+}
+
+// RestoreTopic is served by the store of the project
+func (rs *RoutingStore) RestoreTopic(projectUUID string, name string) error {
+	return rs.For(projectUUID).RestoreTopic(projectUUID, name)
+}
+
+// RenameTopic is served by the store of the project
+func (rs *RoutingStore) RenameTopic(projectUUID string, name string, newName string, brokerTopic string) error {
+	return rs.For(projectUUID).RenameTopic(projectUUID, name, newName, brokerTopic)
+}
+
+// RemoveSub is served by the store of the project
+func (rs *RoutingStore) RemoveSub(projectUUID string, name string) error {
+	return rs.For(projectUUID).RemoveSub(projectUUID, name)
+}
+
+// PaginatedQueryUsers is served by the shared store
+func (rs *RoutingStore) PaginatedQueryUsers(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error) {
+	return rs.Shared.PaginatedQueryUsers(pageToken, pageSize, projectUUID)
+}
+
+// PaginatedQueryUsersByName is served by the shared store
+func (rs *RoutingStore) PaginatedQueryUsersByName(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error) {
+	return rs.Shared.PaginatedQueryUsersByName(pageToken, pageSize, projectUUID)
+}
+
+// QueryUsers is served by the shared store
+func (rs *RoutingStore) QueryUsers(projectUUID string, uuid string, name string) ([]QUser, error) {
+	return rs.Shared.QueryUsers(projectUUID, uuid, name)
+}
+
+// UpdateUser is served by the shared store
+func (rs *RoutingStore) UpdateUser(uuid, fname, lname, org, desc string, projects []QProjectRoles, name string, email string, serviceRoles []string, modifiedOn time.Time) error {
+	return rs.Shared.UpdateUser(uuid, fname, lname, org, desc, projects, name, email, serviceRoles, modifiedOn)
+}
+
+// AppendToUserProjects is served by the shared store
+func (rs *RoutingStore) AppendToUserProjects(userUUID string, projectUUID string, pRoles ...string) error {
+	return rs.Shared.AppendToUserProjects(userUUID, projectUUID, pRoles...)
+}
+
+// UpdateUserToken is served by the shared store
+func (rs *RoutingStore) UpdateUserToken(uuid string, token string) error {
+	return rs.Shared.UpdateUserToken(uuid, token)
+}
+
+// RemoveUser is served by the shared store
+func (rs *RoutingStore) RemoveUser(uuid string) error {
+	return rs.Shared.RemoveUser(uuid)
+}
+
+// QueryProjects is served by the shared store
+func (rs *RoutingStore) QueryProjects(uuid string, name string) ([]QProject, error) {
+	return rs.Shared.QueryProjects(uuid, name)
+}
+
+// UpdateProject is served by the shared store
+func (rs *RoutingStore) UpdateProject(projectUUID string, name string, description string, modifiedOn time.Time) error {
+	return rs.Shared.UpdateProject(projectUUID, name, description, modifiedOn)
+}
+
+// RemoveProject is served by the shared store
+func (rs *RoutingStore) RemoveProject(uuid string) error {
+	return rs.Shared.RemoveProject(uuid)
+}
+
+// RemoveProjectTopics is served by the store of the project
+func (rs *RoutingStore) RemoveProjectTopics(projectUUID string) error {
+	return rs.For(projectUUID).RemoveProjectTopics(projectUUID)
+}
+
+// RemoveProjectSubs is served by the store of the project
+func (rs *RoutingStore) RemoveProjectSubs(projectUUID string) error {
+	return rs.For(projectUUID).RemoveProjectSubs(projectUUID)
+}
+
+// QueryDailyProjectMsgCount is served by the store of the project
+func (rs *RoutingStore) QueryDailyProjectMsgCount(projectUUID string) ([]QDailyProjectMsgCount, error) {
+	return rs.For(projectUUID).QueryDailyProjectMsgCount(projectUUID)
+}
+
+// QueryTotalMessagesPerProject queries every store for the projects it holds,
+// or for all of its projects if no project is given
+func (rs *RoutingStore) QueryTotalMessagesPerProject(projectUUIDs []string, startDate time.Time, endDate time.Time) ([]QProjectMessageCount, error) {
+
+	results := []QProjectMessageCount{}
+
+	if len(projectUUIDs) == 0 {
+		for _, s := range rs.all() {
+			counts, err := s.QueryTotalMessagesPerProject(projectUUIDs, startDate, endDate)
+			if err != nil {
+				return results, err
+			}
+			results = append(results, counts...)
+		}
+		return results, nil
+	}
+
+	// split the projects by store, an empty list would query all the projects of a store
+	shared := []string{}
+	pinned := []string{}
+	for _, projectUUID := range projectUUIDs {
+		if _, ok := rs.Pinned[projectUUID]; ok {
+			pinned = append(pinned, projectUUID)
+			continue
+		}
+		shared = append(shared, projectUUID)
+	}
+
+	if len(shared) > 0 {
+		counts, err := rs.Shared.QueryTotalMessagesPerProject(shared, startDate, endDate)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, counts...)
+	}
+
+	for _, projectUUID := range pinned {
+		counts, err := rs.Pinned[projectUUID].QueryTotalMessagesPerProject([]string{projectUUID}, startDate, endDate)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, counts...)
+	}
+
+	return results, nil
+}
+
+// RegisterUser is served by the shared store
+func (rs *RoutingStore) RegisterUser(uuid, name, firstName, lastName, email, org, desc, registeredAt, atkn, status string) error {
+	return rs.Shared.RegisterUser(uuid, name, firstName, lastName, email, org, desc, registeredAt, atkn, status)
+}
+
+// QueryRegistrations is served by the shared store
+func (rs *RoutingStore) QueryRegistrations(regUUID, status, activationToken, name, email, org string) ([]QUserRegistration, error) {
+	return rs.Shared.QueryRegistrations(regUUID, status, activationToken, name, email, org)
+}
+
+// UpdateRegistration is served by the shared store
+func (rs *RoutingStore) UpdateRegistration(regUUID, status, modifiedBy, modifiedAt string) error {
+	return rs.Shared.UpdateRegistration(regUUID, status, modifiedBy, modifiedAt)
+}
+
+// InsertUser is served by the shared store
+func (rs *RoutingStore) InsertUser(uuid string, projects []QProjectRoles, name string, firstName string, lastName string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string) error {
+	return rs.Shared.InsertUser(uuid, projects, name, firstName, lastName, org, desc, token, email, serviceRoles, createdOn, modifiedOn, createdBy)
+}
+
+// UpdateProjectDefaultACL is served by the shared store
+func (rs *RoutingStore) UpdateProjectDefaultACL(projectUUID string, defaultACL *QDefaultACL, modifiedOn time.Time) error {
+	return rs.Shared.UpdateProjectDefaultACL(projectUUID, defaultACL, modifiedOn)
+}
+
+// UpdateProjectPatternACL is served by the shared store
+func (rs *RoutingStore) UpdateProjectPatternACL(projectUUID string, patternACL *QPatternACL, modifiedOn time.Time) error {
+	return rs.Shared.UpdateProjectPatternACL(projectUUID, patternACL, modifiedOn)
+}
+
+// InsertProject is served by the shared store
+func (rs *RoutingStore) InsertProject(uuid string, name string, createdOn time.Time, modifiedOn time.Time, createdBy string, description string) error {
+	return rs.Shared.InsertProject(uuid, name, createdOn, modifiedOn, createdBy, description)
+}
+
+// InsertOpMetric is served by the shared store
+func (rs *RoutingStore) InsertOpMetric(hostname string, cpu float64, mem float64) error {
+	return rs.Shared.InsertOpMetric(hostname, cpu, mem)
+}
+
+// InsertTopic is served by the store of the project
+func (rs *RoutingStore) InsertTopic(projectUUID string, name string, schemaUUID string, publishAcks string, createdOn time.Time) error {
+	return rs.For(projectUUID).InsertTopic(projectUUID, name, schemaUUID, publishAcks, createdOn)
+}
+
+// IncrementTopicMsgNum is served by the store of the project
+func (rs *RoutingStore) IncrementTopicMsgNum(projectUUID string, name string, num int64) error {
+	return rs.For(projectUUID).IncrementTopicMsgNum(projectUUID, name, num)
+}
+
+// IncrementDailyTopicMsgCount is served by the store of the project
+func (rs *RoutingStore) IncrementDailyTopicMsgCount(projectUUID string, topicName string, num int64, date time.Time) error {
+	return rs.For(projectUUID).IncrementDailyTopicMsgCount(projectUUID, topicName, num, date)
+}
+
+// InsertOffsetTime is served by the store of the project
+func (rs *RoutingStore) InsertOffsetTime(projectUUID string, topicName string, offset int64, publishTime time.Time) error {
+	return rs.For(projectUUID).InsertOffsetTime(projectUUID, topicName, offset, publishTime)
+}
+
+// QueryOffsetByTime is served by the store of the project
+func (rs *RoutingStore) QueryOffsetByTime(projectUUID string, topicName string, t time.Time) (int64, error) {
+	return rs.For(projectUUID).QueryOffsetByTime(projectUUID, topicName, t)
+}
+
+// RemoveOffsetTimes is served by the store of the project
+func (rs *RoutingStore) RemoveOffsetTimes(projectUUID string, topicName string, before int64) error {
+	return rs.For(projectUUID).RemoveOffsetTimes(projectUUID, topicName, before)
+}
+
+// InsertTopicSample is served by the store of the project
+func (rs *RoutingStore) InsertTopicSample(projectUUID string, topicName string, timestamp time.Time, msgNum int64, totalBytes int64) error {
+	return rs.For(projectUUID).InsertTopicSample(projectUUID, topicName, timestamp, msgNum, totalBytes)
+}
+
+// QueryTopicSamples is served by the store of the project
+func (rs *RoutingStore) QueryTopicSamples(projectUUID string, topicName string, since time.Time) ([]QTopicSample, error) {
+	return rs.For(projectUUID).QueryTopicSamples(projectUUID, topicName, since)
+}
+
+// RemoveTopicSamples removes the old topic samples from every store
+func (rs *RoutingStore) RemoveTopicSamples(before time.Time) error {
+	for _, s := range rs.all() {
+		if err := s.RemoveTopicSamples(before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InsertOffsetSnapshot is served by the store of the project
+func (rs *RoutingStore) InsertOffsetSnapshot(projectUUID string, name string, createdOn time.Time, offsets []QSubOffset) error {
+	return rs.For(projectUUID).InsertOffsetSnapshot(projectUUID, name, createdOn, offsets)
+}
+
+// QueryOffsetSnapshots is served by the store of the project
+func (rs *RoutingStore) QueryOffsetSnapshots(projectUUID string, name string) ([]QOffsetSnapshot, error) {
+	return rs.For(projectUUID).QueryOffsetSnapshots(projectUUID, name)
+}
+
+// RemoveOffsetSnapshots removes the old offset snapshots from every store
+func (rs *RoutingStore) RemoveOffsetSnapshots(before time.Time) error {
+	for _, s := range rs.all() {
+		if err := s.RemoveOffsetSnapshots(before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IncrementTopicBytes is served by the store of the project
+func (rs *RoutingStore) IncrementTopicBytes(projectUUID string, name string, totalBytes int64) error {
+	return rs.For(projectUUID).IncrementTopicBytes(projectUUID, name, totalBytes)
+}
+
+// IncrementSubBytes is served by the store of the project
+func (rs *RoutingStore) IncrementSubBytes(projectUUID string, name string, totalBytes int64) error {
+	return rs.For(projectUUID).IncrementSubBytes(projectUUID, name, totalBytes)
+}
+
+// IncrementSubMsgNum is served by the store of the project
+func (rs *RoutingStore) IncrementSubMsgNum(projectUUID string, name string, num int64) error {
+	return rs.For(projectUUID).IncrementSubMsgNum(projectUUID, name, num)
+}
+
+// InsertSub is served by the store of the project
+func (rs *RoutingStore) InsertSub(sub QSub) error {
+	return rs.For(sub.ProjectUUID).InsertSub(sub)
+}
+
+// HasProject is served by the shared store
+func (rs *RoutingStore) HasProject(name string) bool {
+	return rs.Shared.HasProject(name)
+}
+
+// HasUsers is served by the shared store
+func (rs *RoutingStore) HasUsers(projectUUID string, users []string, caseSensitive bool) (bool, []string) {
+	return rs.Shared.HasUsers(projectUUID, users, caseSensitive)
+}
+
+// QueryOneSub is served by the store of the project
+func (rs *RoutingStore) QueryOneSub(projectUUID string, name string) (QSub, error) {
+	return rs.For(projectUUID).QueryOneSub(projectUUID, name)
+}
+
+// QueryPushSubs queries the push subscriptions of every store
+func (rs *RoutingStore) QueryPushSubs() []QSub {
+	results := []QSub{}
+	for _, s := range rs.all() {
+		results = append(results, s.QueryPushSubs()...)
+	}
+	return results
+}
+
+// HasResourceRoles is served by the shared store
+func (rs *RoutingStore) HasResourceRoles(resource string, roles []string) bool {
+	return rs.Shared.HasResourceRoles(resource, roles)
+}
+
+// GetOpMetrics is served by the shared store
+func (rs *RoutingStore) GetOpMetrics() []QopMetric {
+	return rs.Shared.GetOpMetrics()
+}
+
+// GetUserRoles is served by the shared store
+func (rs *RoutingStore) GetUserRoles(projectUUID string, token string) ([]string, string) {
+	return rs.Shared.GetUserRoles(projectUUID, token)
+}
+
+// GetUserFromToken is served by the shared store
+func (rs *RoutingStore) GetUserFromToken(token string) (QUser, error) {
+	return rs.Shared.GetUserFromToken(token)
+}
+
+// UpdateSubOffset is served by the store of the project
+func (rs *RoutingStore) UpdateSubOffset(projectUUID string, name string, offset int64) {
+	rs.For(projectUUID).UpdateSubOffset(projectUUID, name, offset)
+}
+
+// UpdateSubPull is served by the store of the project
+func (rs *RoutingStore) UpdateSubPull(projectUUID string, name string, offset int64, ts string, version int64) error {
+	return rs.For(projectUUID).UpdateSubPull(projectUUID, name, offset, ts, version)
+}
+
+// UpdateSubPullCommit is served by the store of the project
+func (rs *RoutingStore) UpdateSubPullCommit(projectUUID string, name string, offset int64, version int64) error {
+	return rs.For(projectUUID).UpdateSubPullCommit(projectUUID, name, offset, version)
+}
+
+// UpdateSubOffsetAck is served by the store of the project
+func (rs *RoutingStore) UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string, version int64) error {
+	return rs.For(projectUUID).UpdateSubOffsetAck(projectUUID, name, offset, ts, version)
+}
+
+// ModSubPush is served by the store of the project
+func (rs *RoutingStore) ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool, fanout []string, maxConcurrentDeliveries int, maxRetryDuration int, maxRetries int) error {
+	return rs.For(projectUUID).ModSubPush(projectUUID, name, push, authzType, authzValue, maxMessages, rPolicy, rPeriod, vhash, verified, fanout, maxConcurrentDeliveries, maxRetryDuration, maxRetries)
+}
+
+// ResetSubAckedMsgs is served by the store of the project
+func (rs *RoutingStore) ResetSubAckedMsgs(projectUUID string, name string) error {
+	return rs.For(projectUUID).ResetSubAckedMsgs(projectUUID, name)
+}
+
+// ModSubMaxConcurrentPulls is served by the store of the project
+func (rs *RoutingStore) ModSubMaxConcurrentPulls(projectUUID string, name string, max int) error {
+	return rs.For(projectUUID).ModSubMaxConcurrentPulls(projectUUID, name, max)
+}
+
+// ModSubDefaultMaxMessages is served by the store of the project
+func (rs *RoutingStore) ModSubDefaultMaxMessages(projectUUID string, name string, max int) error {
+	return rs.For(projectUUID).ModSubDefaultMaxMessages(projectUUID, name, max)
+}
+
+// AcquirePullLease is served by the store of the project
+func (rs *RoutingStore) AcquirePullLease(projectUUID string, name string, leaseID string, max int, now time.Time, expiresAt time.Time) (bool, error) {
+	return rs.For(projectUUID).AcquirePullLease(projectUUID, name, leaseID, max, now, expiresAt)
+}
+
+// ReleasePullLease is served by the store of the project
+func (rs *RoutingStore) ReleasePullLease(projectUUID string, name string, leaseID string) error {
+	return rs.For(projectUUID).ReleasePullLease(projectUUID, name, leaseID)
+}
+
+// ModSubIsolationLevel is served by the store of the project
+func (rs *RoutingStore) ModSubIsolationLevel(projectUUID string, name string, level string) error {
+	return rs.For(projectUUID).ModSubIsolationLevel(projectUUID, name, level)
+}
+
+// AppendAckedIDs is served by the store of the project
+func (rs *RoutingStore) AppendAckedIDs(projectUUID string, name string, ids []string, window int) error {
+	return rs.For(projectUUID).AppendAckedIDs(projectUUID, name, ids, window)
+}
+
+// QueryAckedIDs is served by the store of the project
+func (rs *RoutingStore) QueryAckedIDs(projectUUID string, name string) ([]string, error) {
+	return rs.For(projectUUID).QueryAckedIDs(projectUUID, name)
+}
+
+// ModSubLabels is served by the store of the project
+func (rs *RoutingStore) ModSubLabels(projectUUID string, name string, labels map[string]string) error {
+	return rs.For(projectUUID).ModSubLabels(projectUUID, name, labels)
+}
+
+// QueryACL is served by the store of the project
+func (rs *RoutingStore) QueryACL(projectUUID string, resource string, name string) (QAcl, error) {
+	return rs.For(projectUUID).QueryACL(projectUUID, resource, name)
+}
+
+// ExistsInACL is served by the store of the project
+func (rs *RoutingStore) ExistsInACL(projectUUID string, resource string, resourceName string, userUUID string) error {
+	return rs.For(projectUUID).ExistsInACL(projectUUID, resource, resourceName, userUUID)
+}
+
+// ModACL is served by the store of the project
+func (rs *RoutingStore) ModACL(projectUUID string, resource string, name string, acl []string) error {
+	return rs.For(projectUUID).ModACL(projectUUID, resource, name, acl)
+}
+
+// ModACLVersion is served by the store of the project
+func (rs *RoutingStore) ModACLVersion(projectUUID string, resource string, name string, acl []string, version int64) error {
+	return rs.For(projectUUID).ModACLVersion(projectUUID, resource, name, acl, version)
+}
+
+// AppendToACL is served by the store of the project
+func (rs *RoutingStore) AppendToACL(projectUUID string, resource string, name string, acl []string) error {
+	return rs.For(projectUUID).AppendToACL(projectUUID, resource, name, acl)
+}
+
+// RemoveFromACL is served by the store of the project
+func (rs *RoutingStore) RemoveFromACL(projectUUID string, resource string, name string, acl []string) error {
+	return rs.For(projectUUID).RemoveFromACL(projectUUID, resource, name, acl)
+}
+
+// InsertACLChange is served by the store of the project
+func (rs *RoutingStore) InsertACLChange(projectUUID string, resource string, name string, operation string, acl []string, actor string, changedOn time.Time) error {
+	return rs.For(projectUUID).InsertACLChange(projectUUID, resource, name, operation, acl, actor, changedOn)
+}
+
+// QueryACLHistory is served by the store of the project
+func (rs *RoutingStore) QueryACLHistory(projectUUID string, resource string, name string) ([]QACLChange, error) {
+	return rs.For(projectUUID).QueryACLHistory(projectUUID, resource, name)
+}
+
+// RemoveACLHistory removes the old acl changes from every store
+func (rs *RoutingStore) RemoveACLHistory(before time.Time) error {
+	for _, s := range rs.all() {
+		if err := s.RemoveACLHistory(before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ModAck is served by the store of the project
+func (rs *RoutingStore) ModAck(projectUUID string, name string, ack int) error {
+	return rs.For(projectUUID).ModAck(projectUUID, name, ack)
+}
+
+// GetAllRoles is served by the shared store
+func (rs *RoutingStore) GetAllRoles() []string {
+	return rs.Shared.GetAllRoles()
+}
+
+// QueryRoles is served by the shared store
+func (rs *RoutingStore) QueryRoles(operation string) ([]QRole, error) {
+	return rs.Shared.QueryRoles(operation)
+}
+
+// InsertRole is served by the shared store
+func (rs *RoutingStore) InsertRole(operation string, roles []string) error {
+	return rs.Shared.InsertRole(operation, roles)
+}
+
+// UpdateRole is served by the shared store
+func (rs *RoutingStore) UpdateRole(operation string, roles []string) error {
+	return rs.Shared.UpdateRole(operation, roles)
+}
+
+// RemoveRole is served by the shared store
+func (rs *RoutingStore) RemoveRole(operation string) error {
+	return rs.Shared.RemoveRole(operation)
+}
+
+// InsertSchema is served by the store of the project
+func (rs *RoutingStore) InsertSchema(projectUUID, schemaUUID, name, schemaType, rawSchemaString string) error {
+	return rs.For(projectUUID).InsertSchema(projectUUID, schemaUUID, name, schemaType, rawSchemaString)
+}
+
+// QuerySchemas is served by the store of the project
+func (rs *RoutingStore) QuerySchemas(projectUUID, schemaUUID, name string) ([]QSchema, error) {
+	return rs.For(projectUUID).QuerySchemas(projectUUID, schemaUUID, name)
+}
+
+// UpdateSchema updates the schema in the store that holds it
+func (rs *RoutingStore) UpdateSchema(schemaUUID, name, schemaType, rawSchemaString string) error {
+	return rs.forSchema(schemaUUID).UpdateSchema(schemaUUID, name, schemaType, rawSchemaString)
+}
+
+// DeleteSchema deletes the schema from the store that holds it
+func (rs *RoutingStore) DeleteSchema(schemaUUID string) error {
+	return rs.forSchema(schemaUUID).DeleteSchema(schemaUUID)
+}
+
+// UsersCount is served by the shared store
+func (rs *RoutingStore) UsersCount(startDate, endDate time.Time) (int, error) {
+	return rs.Shared.UsersCount(startDate, endDate)
+}
+
+// TopicsCount sums the topics created in every store during the given period
+func (rs *RoutingStore) TopicsCount(startDate, endDate time.Time) (int, error) {
+	total := 0
+	for _, s := range rs.all() {
+		count, err := s.TopicsCount(startDate, endDate)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// SubscriptionsCount sums the subscriptions created in every store during the given period
+func (rs *RoutingStore) SubscriptionsCount(startDate, endDate time.Time) (int, error) {
+	total := 0
+	for _, s := range rs.all() {
+		count, err := s.SubscriptionsCount(startDate, endDate)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// CountTopicsByProject is served by the store of the project
+func (rs *RoutingStore) CountTopicsByProject(projectUUID string) (int64, error) {
+	return rs.For(projectUUID).CountTopicsByProject(projectUUID)
+}
+
+// CountSubsByProject is served by the store of the project
+func (rs *RoutingStore) CountSubsByProject(projectUUID string) (int64, error) {
+	return rs.For(projectUUID).CountSubsByProject(projectUUID)
+}
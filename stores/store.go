@@ -2,6 +2,8 @@ package stores
 
 import (
 	"time"
+
+	"github.com/ARGOeu/argo-messaging/labels"
 )
 
 // Store encapsulates the generic store interface
@@ -10,16 +12,24 @@ type Store interface {
 	QuerySubsByTopic(projectUUID, topic string) ([]QSub, error)
 	QueryTopicsByACL(projectUUID, user string) ([]QTopic, error)
 	QuerySubsByACL(projectUUID, user string) ([]QSub, error)
-	QuerySubs(projectUUID string, userUUID string, name string, pageToken string, pageSize int32) ([]QSub, int32, string, error)
-	QueryTopics(projectUUID string, userUUID string, name string, pageToken string, pageSize int32) ([]QTopic, int32, string, error)
+	QuerySubs(projectUUID string, userUUID string, name string, pageToken string, pageSize int32, selector labels.Selector, namePrefix string) ([]QSub, int32, string, error)
+	QueryTopics(projectUUID string, userUUID string, name string, pageToken string, pageSize int32, showDeleted bool, selector labels.Selector, namePrefix string) ([]QTopic, int32, string, error)
+	QueryDeletedTopics(deletedBefore time.Time) ([]QTopic, error)
 	QueryDailyTopicMsgCount(projectUUID string, name string, date time.Time) ([]QDailyTopicMsgCount, error)
 	UpdateTopicLatestPublish(projectUUID string, name string, date time.Time) error
 	UpdateTopicPublishRate(projectUUID string, name string, rate float64) error
+	UpdateTopicLabels(projectUUID string, name string, labels map[string]string) error
+	UpdateTopicPartitionKey(projectUUID string, name string, attribute string) error
 	UpdateSubLatestConsume(projectUUID string, name string, date time.Time) error
 	UpdateSubConsumeRate(projectUUID string, name string, rate float64) error
+	UpdateSubLastProgress(projectUUID string, name string, date time.Time) error
 	RemoveTopic(projectUUID string, name string) error
+	SoftDeleteTopic(projectUUID string, name string, deletedOn time.Time) error
+	RestoreTopic(projectUUID string, name string) error
+	RenameTopic(projectUUID string, name string, newName string, brokerTopic string) error
 	RemoveSub(projectUUID string, name string) error
 	PaginatedQueryUsers(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error)
+	PaginatedQueryUsersByName(pageToken string, pageSize int32, projectUUID string) ([]QUser, int32, string, error)
 	QueryUsers(projectUUID string, uuid string, name string) ([]QUser, error)
 	UpdateUser(uuid, fname, lname, org, desc string, projects []QProjectRoles, name string, email string, serviceRoles []string, modifiedOn time.Time) error
 	AppendToUserProjects(userUUID string, projectUUID string, pRoles ...string) error
@@ -27,6 +37,8 @@ type Store interface {
 	RemoveUser(uuid string) error
 	QueryProjects(uuid string, name string) ([]QProject, error)
 	UpdateProject(projectUUID string, name string, description string, modifiedOn time.Time) error
+	UpdateProjectDefaultACL(projectUUID string, defaultACL *QDefaultACL, modifiedOn time.Time) error
+	UpdateProjectPatternACL(projectUUID string, patternACL *QPatternACL, modifiedOn time.Time) error
 	RemoveProject(uuid string) error
 	RemoveProjectTopics(projectUUID string) error
 	RemoveProjectSubs(projectUUID string) error
@@ -38,15 +50,24 @@ type Store interface {
 	InsertUser(uuid string, projects []QProjectRoles, name string, firstName string, lastName string, org string, desc string, token string, email string, serviceRoles []string, createdOn time.Time, modifiedOn time.Time, createdBy string) error
 	InsertProject(uuid string, name string, createdOn time.Time, modifiedOn time.Time, createdBy string, description string) error
 	InsertOpMetric(hostname string, cpu float64, mem float64) error
-	InsertTopic(projectUUID string, name string, schemaUUID string, createdOn time.Time) error
+	InsertTopic(projectUUID string, name string, schemaUUID string, publishAcks string, createdOn time.Time) error
 	IncrementTopicMsgNum(projectUUID string, name string, num int64) error
 	IncrementDailyTopicMsgCount(projectUUID string, topicName string, num int64, date time.Time) error
+	InsertOffsetTime(projectUUID string, topicName string, offset int64, publishTime time.Time) error
+	QueryOffsetByTime(projectUUID string, topicName string, t time.Time) (int64, error)
+	RemoveOffsetTimes(projectUUID string, topicName string, before int64) error
+	InsertTopicSample(projectUUID string, topicName string, timestamp time.Time, msgNum int64, totalBytes int64) error
+	QueryTopicSamples(projectUUID string, topicName string, since time.Time) ([]QTopicSample, error)
+	RemoveTopicSamples(before time.Time) error
+	InsertOffsetSnapshot(projectUUID string, name string, createdOn time.Time, offsets []QSubOffset) error
+	QueryOffsetSnapshots(projectUUID string, name string) ([]QOffsetSnapshot, error)
+	RemoveOffsetSnapshots(before time.Time) error
 	IncrementTopicBytes(projectUUID string, name string, totalBytes int64) error
 	IncrementSubBytes(projectUUID string, name string, totalBytes int64) error
 	IncrementSubMsgNum(projectUUID string, name string, num int64) error
-	InsertSub(projectUUID string, name string, topic string, offest int64, maxMessages int64, authzType string, authzHeader string, ack int, push string, rPolicy string, rPeriod int, vhash string, verified bool, createdOn time.Time) error
+	InsertSub(sub QSub) error
 	HasProject(name string) bool
-	HasUsers(projectUUID string, users []string) (bool, []string)
+	HasUsers(projectUUID string, users []string, caseSensitive bool) (bool, []string)
 	QueryOneSub(projectUUID string, name string) (QSub, error)
 	QueryPushSubs() []QSub
 	HasResourceRoles(resource string, roles []string) bool
@@ -54,16 +75,34 @@ type Store interface {
 	GetUserRoles(projectUUID string, token string) ([]string, string)
 	GetUserFromToken(token string) (QUser, error)
 	UpdateSubOffset(projectUUID string, name string, offset int64)
-	UpdateSubPull(projectUUID string, name string, offset int64, ts string) error
-	UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string) error
-	ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool) error
+	UpdateSubPull(projectUUID string, name string, offset int64, ts string, version int64) error
+	UpdateSubPullCommit(projectUUID string, name string, offset int64, version int64) error
+	UpdateSubOffsetAck(projectUUID string, name string, offset int64, ts string, version int64) error
+	ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, rPolicy string, rPeriod int, vhash string, verified bool, fanout []string, maxConcurrentDeliveries int, maxRetryDuration int, maxRetries int) error
+	ResetSubAckedMsgs(projectUUID string, name string) error
+	ModSubLabels(projectUUID string, name string, labels map[string]string) error
+	ModSubIsolationLevel(projectUUID string, name string, level string) error
+	AppendAckedIDs(projectUUID string, name string, ids []string, window int) error
+	QueryAckedIDs(projectUUID string, name string) ([]string, error)
+	ModSubMaxConcurrentPulls(projectUUID string, name string, max int) error
+	ModSubDefaultMaxMessages(projectUUID string, name string, max int) error
+	AcquirePullLease(projectUUID string, name string, leaseID string, max int, now time.Time, expiresAt time.Time) (bool, error)
+	ReleasePullLease(projectUUID string, name string, leaseID string) error
 	QueryACL(projectUUID string, resource string, name string) (QAcl, error)
 	ExistsInACL(projectUUID string, resource string, resourceName string, userUUID string) error
 	ModACL(projectUUID string, resource string, name string, acl []string) error
+	ModACLVersion(projectUUID string, resource string, name string, acl []string, version int64) error
 	AppendToACL(projectUUID string, resource string, name string, acl []string) error
 	RemoveFromACL(projectUUID string, resource string, name string, acl []string) error
+	InsertACLChange(projectUUID string, resource string, name string, operation string, acl []string, actor string, changedOn time.Time) error
+	QueryACLHistory(projectUUID string, resource string, name string) ([]QACLChange, error)
+	RemoveACLHistory(before time.Time) error
 	ModAck(projectUUID string, name string, ack int) error
 	GetAllRoles() []string
+	QueryRoles(operation string) ([]QRole, error)
+	InsertRole(operation string, roles []string) error
+	UpdateRole(operation string, roles []string) error
+	RemoveRole(operation string) error
 	InsertSchema(projectUUID, schemaUUID, name, schemaType, rawSchemaString string) error
 	QuerySchemas(projectUUID, schemaUUID, name string) ([]QSchema, error)
 	UpdateSchema(schemaUUID, name, schemaType, rawSchemaString string) error
@@ -71,6 +110,9 @@ type Store interface {
 	UsersCount(startDate, endDate time.Time) (int, error)
 	TopicsCount(startDate, endDate time.Time) (int, error)
 	SubscriptionsCount(startDate, endDate time.Time) (int, error)
+	CountTopicsByProject(projectUUID string) (int64, error)
+	CountSubsByProject(projectUUID string) (int64, error)
 	Clone() Store
 	Close()
+	Type() string
 }
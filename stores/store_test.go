@@ -19,95 +19,95 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("mockbase", store.Database)
 
 	eTopList := []QTopic{
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
 
 	eSubList := []QSub{
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}},
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
 	}
 	// retrieve all topics
-	tpList, ts1, pg1, _ := store.QueryTopics("argo_uuid", "", "", "", 0)
+	tpList, ts1, pg1, _ := store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
 	suite.Equal(eTopList, tpList)
 	suite.Equal(int32(4), ts1)
 	suite.Equal("", pg1)
 
 	// retrieve first 2
 	eTopList1st2 := []QTopic{
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
-	tpList2, ts2, pg2, _ := store.QueryTopics("argo_uuid", "", "", "", 2)
+	tpList2, ts2, pg2, _ := store.QueryTopics("argo_uuid", "", "", "", 2, false, nil, "")
 	suite.Equal(eTopList1st2, tpList2)
 	suite.Equal(int32(4), ts2)
 	suite.Equal("1", pg2)
 
 	// retrieve the last one
 	eTopList3 := []QTopic{
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
-	tpList3, ts3, pg3, _ := store.QueryTopics("argo_uuid", "", "", "0", 1)
+	tpList3, ts3, pg3, _ := store.QueryTopics("argo_uuid", "", "", "0", 1, false, nil, "")
 	suite.Equal(eTopList3, tpList3)
 	suite.Equal(int32(4), ts3)
 	suite.Equal("", pg3)
 
 	// retrieve a single topic
 	eTopList4 := []QTopic{
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
-	tpList4, ts4, pg4, _ := store.QueryTopics("argo_uuid", "", "topic1", "", 0)
+	tpList4, ts4, pg4, _ := store.QueryTopics("argo_uuid", "", "topic1", "", 0, false, nil, "")
 	suite.Equal(eTopList4, tpList4)
 	suite.Equal(int32(0), ts4)
 	suite.Equal("", pg4)
 
 	// retrieve user's topics
 	eTopList5 := []QTopic{
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
-	tpList5, ts5, pg5, _ := store.QueryTopics("argo_uuid", "uuid1", "", "", 0)
+	tpList5, ts5, pg5, _ := store.QueryTopics("argo_uuid", "uuid1", "", "", 0, false, nil, "")
 	suite.Equal(eTopList5, tpList5)
 	suite.Equal(int32(2), ts5)
 	suite.Equal("", pg5)
 
 	// retrieve use's topic with pagination
 	eTopList6 := []QTopic{
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
 
-	tpList6, ts6, pg6, _ := store.QueryTopics("argo_uuid", "uuid1", "", "", 1)
+	tpList6, ts6, pg6, _ := store.QueryTopics("argo_uuid", "uuid1", "", "", 1, false, nil, "")
 	suite.Equal(eTopList6, tpList6)
 	suite.Equal(int32(2), ts6)
 	suite.Equal("0", pg6)
 
 	// retrieve all subs
-	subList, ts1, pg1, err1 := store.QuerySubs("argo_uuid", "", "", "", 0)
+	subList, ts1, pg1, err1 := store.QuerySubs("argo_uuid", "", "", "", 0, nil, "")
 	suite.Equal(eSubList, subList)
 	suite.Equal(int32(4), ts1)
 	suite.Equal("", pg3)
 
 	// retrieve first 2 subs
 	eSubListFirstPage := []QSub{
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}}}
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}}
 
-	subList2, ts2, pg2, err2 := store.QuerySubs("argo_uuid", "", "", "", 2)
+	subList2, ts2, pg2, err2 := store.QuerySubs("argo_uuid", "", "", "", 2, nil, "")
 	suite.Equal(eSubListFirstPage, subList2)
 	suite.Equal(int32(4), ts2)
 	suite.Equal("1", pg2)
 
 	// retrieve next 2 subs
 	eSubListNextPage := []QSub{
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
 	}
 
-	subList3, ts3, pg3, err3 := store.QuerySubs("argo_uuid", "", "", "1", 2)
+	subList3, ts3, pg3, err3 := store.QuerySubs("argo_uuid", "", "", "1", 2, nil, "")
 	suite.Equal(eSubListNextPage, subList3)
 	suite.Equal(int32(4), ts3)
 	suite.Equal("", pg3)
@@ -119,7 +119,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 		{ID: 1, ProjectUUID: "argo_uuid", Name: "sub2", Topic: "topic2", Offset: 0, NextOffset: 0, PendingAck: "", PushEndpoint: "", MaxMessages: 0, Ack: 10, RetPolicy: "", RetPeriod: 0, MsgNum: 0, TotalBytes: 0, LatestConsume: time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), ConsumeRate: 8.99, CreatedOn: time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), ACL: []string{}},
 	}
 
-	subList4, ts4, pg4, err4 := store.QuerySubs("argo_uuid", "uuid1", "", "", 0)
+	subList4, ts4, pg4, err4 := store.QuerySubs("argo_uuid", "uuid1", "", "", 0, nil, "")
 
 	suite.Equal(int32(3), ts4)
 	suite.Equal("", pg4)
@@ -130,7 +130,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 		{ID: 3, ProjectUUID: "argo_uuid", Name: "sub4", Topic: "topic4", Offset: 0, NextOffset: 0, PendingAck: "", PushEndpoint: "endpoint.foo", MaxMessages: 1, AuthorizationType: "autogen", AuthorizationHeader: "auth-header-1", Ack: 10, RetPolicy: "linear", RetPeriod: 300, MsgNum: 0, TotalBytes: 0, VerificationHash: "push-id-1", Verified: true, LatestConsume: time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), ConsumeRate: 0, CreatedOn: time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), ACL: []string{}},
 		{ID: 2, ProjectUUID: "argo_uuid", Name: "sub3", Topic: "topic3", Offset: 0, NextOffset: 0, PendingAck: "", PushEndpoint: "", MaxMessages: 0, Ack: 10, RetPolicy: "", RetPeriod: 0, MsgNum: 0, TotalBytes: 0, LatestConsume: time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), ConsumeRate: 5.45, CreatedOn: time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), ACL: []string{}},
 	}
-	subList5, ts5, pg5, err5 := store.QuerySubs("argo_uuid", "uuid1", "", "", 2)
+	subList5, ts5, pg5, err5 := store.QuerySubs("argo_uuid", "uuid1", "", "", 2, nil, "")
 
 	suite.Equal(int32(3), ts5)
 	suite.Equal("1", pg5)
@@ -201,33 +201,33 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(true, store.HasResourceRoles("topics:list_all", []string{"publisher"}))
 	suite.Equal(true, store.HasResourceRoles("topics:publish", []string{"publisher"}))
 
-	store.InsertTopic("argo_uuid", "topicFresh", "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.Local))
-	store.InsertSub("argo_uuid", "subFresh", "topicFresh", 0, 0, "", "", 10, "", "", 0, "", false, time.Date(2020, 12, 19, 0, 0, 0, 0, time.Local))
+	store.InsertTopic("argo_uuid", "topicFresh", "", "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.Local))
+	store.InsertSub(QSub{ProjectUUID: "argo_uuid", Name: "subFresh", Topic: "topicFresh", Ack: 10, CreatedOn: time.Date(2020, 12, 19, 0, 0, 0, 0, time.Local)})
 
 	eTopList2 := []QTopic{
-		{4, "argo_uuid", "topicFresh", 0, 0, time.Time{}, 0, "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.Local), []string{}},
-		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
-		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
+		{4, "argo_uuid", "topicFresh", 0, 0, time.Time{}, 0, "", time.Date(2020, 9, 11, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{3, "argo_uuid", "topic4", 0, 0, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{2, "argo_uuid", "topic3", 0, 0, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "schema_uuid_3", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{1, "argo_uuid", "topic2", 0, 0, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "schema_uuid_1", time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
+		{0, "argo_uuid", "topic1", 0, 0, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, time.Time{}, "", "", nil, ""},
 	}
 
 	eSubList2 := []QSub{
-		{4, "argo_uuid", "subFresh", "topicFresh", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Time{}, 0, time.Date(2020, 12, 19, 0, 0, 0, 0, time.Local), []string{}},
-		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}},
-		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}},
-		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}},
-		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}}}
+		{4, "argo_uuid", "subFresh", "topicFresh", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Time{}, 0, time.Date(2020, 12, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{3, "argo_uuid", "sub4", "topic4", 0, 0, "", "endpoint.foo", 1, "autogen", "auth-header-1", 10, "linear", 300, 0, 0, "push-id-1", true, time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{2, "argo_uuid", "sub3", "topic3", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, time.Date(2020, 11, 21, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{1, "argo_uuid", "sub2", "topic2", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0},
+		{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}}
 
-	tpList, _, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0)
+	tpList, _, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
 	suite.Equal(eTopList2, tpList)
-	subList, _, _, _ = store.QuerySubs("argo_uuid", "", "", "", 0)
+	subList, _, _, _ = store.QuerySubs("argo_uuid", "", "", "", 0, nil, "")
 	suite.Equal(eSubList2, subList)
 
 	// Test delete on topic
 	err := store.RemoveTopic("argo_uuid", "topicFresh")
 	suite.Equal(nil, err)
-	tpList, _, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0)
+	tpList, _, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
 	suite.Equal(eTopList, tpList)
 	err = store.RemoveTopic("argo_uuid", "topicFresh")
 	suite.Equal("not found", err.Error())
@@ -235,13 +235,13 @@ func (suite *StoreTestSuite) TestMockStore() {
 	// Test delete on subscription
 	err = store.RemoveSub("argo_uuid", "subFresh")
 	suite.Equal(nil, err)
-	subList, _, _, _ = store.QuerySubs("argo_uuid", "", "", "", 0)
+	subList, _, _, _ = store.QuerySubs("argo_uuid", "", "", "", 0, nil, "")
 	suite.Equal(eSubList, subList)
 	err = store.RemoveSub("argo_uuid", "subFresh")
 	suite.Equal("not found", err.Error())
 
 	sb, err := store.QueryOneSub("argo_uuid", "sub1")
-	esb := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}}
+	esb := QSub{0, "argo_uuid", "sub1", "topic1", 0, 0, "", "", 0, "", "", 10, "", 0, 0, 0, "", false, time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, time.Date(2020, 11, 19, 0, 0, 0, 0, time.Local), []string{}, nil, nil, 0, false, 0, nil, false, 0, false, time.Time{}, false, 0, 0, "", 0, 0, 0}
 	suite.Equal(esb, sb)
 
 	// Test modify ack deadline in store
@@ -250,7 +250,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(66, subAck.Ack)
 
 	// Test mod push sub
-	e1 := store.ModSubPush("argo_uuid", "sub1", "example.com", "autogen", "auth-h-1", 3, "linear", 400, "hash-1", true)
+	e1 := store.ModSubPush("argo_uuid", "sub1", "example.com", "autogen", "auth-h-1", 3, "linear", 400, "hash-1", true, []string{"https://fan.example.com"}, 2, 30, 5)
 	sub1, _ := store.QueryOneSub("argo_uuid", "sub1")
 	suite.Nil(e1)
 	suite.Equal("example.com", sub1.PushEndpoint)
@@ -261,8 +261,12 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("autogen", sub1.AuthorizationType)
 	suite.Equal("auth-h-1", sub1.AuthorizationHeader)
 	suite.True(sub1.Verified)
+	suite.Equal([]string{"https://fan.example.com"}, sub1.FanoutEndpoints)
+	suite.Equal(2, sub1.MaxConcurrentDeliveries)
+	suite.Equal(30, sub1.MaxRetryDuration)
+	suite.Equal(5, sub1.MaxRetries)
 
-	e2 := store.ModSubPush("argo_uuid", "unknown", "", "", "", 0, "", 0, "", false)
+	e2 := store.ModSubPush("argo_uuid", "unknown", "", "", "", 0, "", 0, "", false, nil, 0, 0, 0)
 	suite.Equal("not found", e2.Error())
 
 	// exists in acl
@@ -273,31 +277,31 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("not found", existsE2.Error())
 
 	// Query ACLS
-	ExpectedACL01 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL01 := QAcl{[]string{"uuid1", "uuid2"}, 0}
 	QAcl01, _ := store.QueryACL("argo_uuid", "topics", "topic1")
 	suite.Equal(ExpectedACL01, QAcl01)
 
-	ExpectedACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}}
+	ExpectedACL02 := QAcl{[]string{"uuid1", "uuid2", "uuid4"}, 0}
 	QAcl02, _ := store.QueryACL("argo_uuid", "topics", "topic2")
 	suite.Equal(ExpectedACL02, QAcl02)
 
-	ExpectedACL03 := QAcl{[]string{"uuid3"}}
+	ExpectedACL03 := QAcl{[]string{"uuid3"}, 0}
 	QAcl03, _ := store.QueryACL("argo_uuid", "topics", "topic3")
 	suite.Equal(ExpectedACL03, QAcl03)
 
-	ExpectedACL04 := QAcl{[]string{"uuid1", "uuid2"}}
+	ExpectedACL04 := QAcl{[]string{"uuid1", "uuid2"}, 0}
 	QAcl04, _ := store.QueryACL("argo_uuid", "subscriptions", "sub1")
 	suite.Equal(ExpectedACL04, QAcl04)
 
-	ExpectedACL05 := QAcl{[]string{"uuid1", "uuid3"}}
+	ExpectedACL05 := QAcl{[]string{"uuid1", "uuid3"}, 0}
 	QAcl05, _ := store.QueryACL("argo_uuid", "subscriptions", "sub2")
 	suite.Equal(ExpectedACL05, QAcl05)
 
-	ExpectedACL06 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}}
+	ExpectedACL06 := QAcl{[]string{"uuid4", "uuid2", "uuid1"}, 0}
 	QAcl06, _ := store.QueryACL("argo_uuid", "subscriptions", "sub3")
 	suite.Equal(ExpectedACL06, QAcl06)
 
-	ExpectedACL07 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}}
+	ExpectedACL07 := QAcl{[]string{"uuid2", "uuid4", "uuid7"}, 0}
 	QAcl07, _ := store.QueryACL("argo_uuid", "subscriptions", "sub4")
 	suite.Equal(ExpectedACL07, QAcl07)
 
@@ -348,14 +352,28 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("wrong resource type", eRemACL3.Error())
 
 	//Check has users
-	allFound, notFound := store.HasUsers("argo_uuid", []string{"UserA", "UserB", "FooUser"})
+	allFound, notFound := store.HasUsers("argo_uuid", []string{"UserA", "UserB", "FooUser"}, true)
 	suite.Equal(false, allFound)
 	suite.Equal([]string{"FooUser"}, notFound)
 
-	allFound, notFound = store.HasUsers("argo_uuid", []string{"UserA", "UserB"})
+	allFound, notFound = store.HasUsers("argo_uuid", []string{"UserA", "UserB"}, true)
 	suite.Equal(true, allFound)
 	suite.Equal([]string(nil), notFound)
 
+	// duplicated usernames are reported once, in the order they were given
+	allFound, notFound = store.HasUsers("argo_uuid", []string{"FooUser", "UserA", "BarUser", "FooUser", "UserA"}, true)
+	suite.Equal(false, allFound)
+	suite.Equal([]string{"FooUser", "BarUser"}, notFound)
+
+	// differently cased usernames match only when the matching isn't case sensitive
+	allFound, notFound = store.HasUsers("argo_uuid", []string{"usera", "USERB", "fooUser", "FOOUSER"}, true)
+	suite.Equal(false, allFound)
+	suite.Equal([]string{"usera", "USERB", "fooUser", "FOOUSER"}, notFound)
+
+	allFound, notFound = store.HasUsers("argo_uuid", []string{"usera", "USERB", "fooUser", "FOOUSER"}, false)
+	suite.Equal(false, allFound)
+	suite.Equal([]string{"fooUser"}, notFound)
+
 	created := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
 	modified := time.Date(2009, time.November, 10, 23, 0, 0, 0, time.UTC)
 
@@ -417,17 +435,17 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(expPr3, prUp3[0])
 
 	// Test Sub Update Pull
-	err = store.UpdateSubPull("argo_uuid", "sub4", 4, "2016-10-11T12:00:35:15Z")
-	qSubUpd, _, _, err := store.QuerySubs("argo_uuid", "", "sub4", "", 0)
+	err = store.UpdateSubPull("argo_uuid", "sub4", 4, "2016-10-11T12:00:35:15Z", 0)
+	qSubUpd, _, _, err := store.QuerySubs("argo_uuid", "", "sub4", "", 0, nil, "")
 	var nxtOff int64 = 4
 	suite.Equal(qSubUpd[0].NextOffset, nxtOff)
 	suite.Equal("2016-10-11T12:00:35:15Z", qSubUpd[0].PendingAck)
 	// Test RemoveProjectTopics
 	store.RemoveProjectTopics("argo_uuid")
-	resTop, _, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0)
+	resTop, _, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
 	suite.Equal(0, len(resTop))
 	store.RemoveProjectSubs("argo_uuid")
-	resSub, _, _, _ := store.QuerySubs("argo_uuid", "", "", "", 0)
+	resSub, _, _, _ := store.QuerySubs("argo_uuid", "", "", "", 0, nil, "")
 	suite.Equal(0, len(resSub))
 
 	// Test RemoveProject
@@ -521,28 +539,46 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal("2", pg4)
 	suite.Equal(int32(2), ts4)
 
+	// test paginated query users ordered by name
+	qUsers5, ts5, pg5, _ := store2.PaginatedQueryUsersByName("", 2, "")
+	suite.Equal("Test", qUsers5[0].Name)
+	suite.Equal("UserA", qUsers5[1].Name)
+	suite.Equal("UserB", pg5)
+	suite.Equal(int32(9), ts5)
+
+	qUsers6, _, pg6, _ := store2.PaginatedQueryUsersByName(pg5, 2, "")
+	suite.Equal("UserB", qUsers6[0].Name)
+	suite.Equal("UserSame1", qUsers6[1].Name)
+	suite.Equal("UserSame2", pg6)
+
+	qUsers7, ts7, pg7, _ := store2.PaginatedQueryUsersByName("", 0, "argo_uuid2")
+	suite.Equal(1, len(qUsers7))
+	suite.Equal("UserZ", qUsers7[0].Name)
+	suite.Equal("", pg7)
+	suite.Equal(int32(1), ts7)
+
 	// test update topic latest publish time
 	e1ulp := store2.UpdateTopicLatestPublish("argo_uuid", "topic1", time.Date(2019, 8, 8, 0, 0, 0, 0, time.Local))
 	suite.Nil(e1ulp)
-	tpc, _, _, _ := store2.QueryTopics("argo_uuid", "", "topic1", "", 0)
+	tpc, _, _, _ := store2.QueryTopics("argo_uuid", "", "topic1", "", 0, false, nil, "")
 	suite.Equal(time.Date(2019, 8, 8, 0, 0, 0, 0, time.Local), tpc[0].LatestPublish)
 
 	// test update topic publishing rate
 	e1upr := store2.UpdateTopicPublishRate("argo_uuid", "topic1", 8.44)
 	suite.Nil(e1upr)
-	tpc2, _, _, _ := store2.QueryTopics("argo_uuid", "", "topic1", "", 0)
+	tpc2, _, _, _ := store2.QueryTopics("argo_uuid", "", "topic1", "", 0, false, nil, "")
 	suite.Equal(8.44, tpc2[0].PublishRate)
 
 	// test update topic latest publish time
 	scre1 := store2.UpdateSubLatestConsume("argo_uuid", "sub1", time.Date(2019, 8, 8, 0, 0, 0, 0, time.Local))
 	suite.Nil(scre1)
-	spc, _, _, _ := store2.QuerySubs("argo_uuid", "", "sub1", "", 0)
+	spc, _, _, _ := store2.QuerySubs("argo_uuid", "", "sub1", "", 0, nil, "")
 	suite.Equal(time.Date(2019, 8, 8, 0, 0, 0, 0, time.Local), spc[0].LatestConsume)
 
 	// test update topic publishing rate
 	scre2 := store2.UpdateSubConsumeRate("argo_uuid", "sub1", 8.44)
 	suite.Nil(scre2)
-	spc2, _, _, _ := store2.QuerySubs("argo_uuid", "", "sub1", "", 0)
+	spc2, _, _, _ := store2.QuerySubs("argo_uuid", "", "sub1", "", 0, nil, "")
 	suite.Equal(8.44, spc2[0].ConsumeRate)
 
 	// test QueryTotalMessagesPerProject
@@ -592,7 +628,7 @@ func (suite *StoreTestSuite) TestMockStore() {
 	ed := store4.DeleteSchema("schema_uuid_1")
 	expd, _ := store4.QuerySchemas("argo_uuid", "schema_uuid_1", "")
 	// check that topic-1 no longer has any schema_uuid associated with it
-	qtd, _, _, _ := store4.QueryTopics("argo_uuid", "", "topic2", "", 1)
+	qtd, _, _, _ := store4.QueryTopics("argo_uuid", "", "topic2", "", 1, false, nil, "")
 	suite.Equal("", qtd[0].SchemaUUID)
 	suite.Equal([]QSchema{}, expd)
 	suite.Nil(ed)
@@ -647,6 +683,296 @@ func (suite *StoreTestSuite) TestMockStore() {
 	suite.Equal(9, uc)
 }
 
+func (suite *StoreTestSuite) TestACLHistory() {
+
+	store := NewMockStore("", "")
+	t1 := time.Date(2019, 5, 6, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	suite.Nil(store.InsertACLChange("argo_uuid", "topics", "topic1", "modify", []string{"uuid2"}, "uuid1", t2))
+	suite.Nil(store.InsertACLChange("argo_uuid", "topics", "topic1", "append", []string{"uuid1", "uuid2"}, "uuid1", t1))
+	suite.Nil(store.InsertACLChange("argo_uuid", "subscriptions", "topic1", "modify", []string{}, "uuid1", t1))
+
+	// changes are returned oldest first and only for the given resource
+	history, err := store.QueryACLHistory("argo_uuid", "topics", "topic1")
+	suite.Nil(err)
+	suite.Equal(2, len(history))
+	suite.Equal("append", history[0].Operation)
+	suite.Equal([]string{"uuid1", "uuid2"}, history[0].ACL)
+	suite.Equal(t2, history[1].ChangedOn)
+
+	suite.Nil(store.RemoveACLHistory(t2))
+	history, _ = store.QueryACLHistory("argo_uuid", "topics", "topic1")
+	suite.Equal(1, len(history))
+	suite.Equal("modify", history[0].Operation)
+	history, _ = store.QueryACLHistory("argo_uuid", "subscriptions", "topic1")
+	suite.Equal(0, len(history))
+}
+
+func (suite *StoreTestSuite) TestModACLVersion() {
+
+	store := NewMockStore("", "")
+
+	// every modification of the acl bumps its version
+	suite.Nil(store.ModACLVersion("argo_uuid", "topics", "topic1", []string{"uuid1"}, 0))
+	suite.Nil(store.AppendToACL("argo_uuid", "topics", "topic1", []string{"uuid2"}))
+	suite.Nil(store.RemoveFromACL("argo_uuid", "topics", "topic1", []string{"uuid1"}))
+	qACL, _ := store.QueryACL("argo_uuid", "topics", "topic1")
+	suite.Equal(QAcl{ACL: []string{"uuid2"}, Version: 3}, qACL)
+
+	// a modification based on an older version is rejected
+	suite.Equal("conflict", store.ModACLVersion("argo_uuid", "topics", "topic1", []string{"uuid4"}, 1).Error())
+	qACL, _ = store.QueryACL("argo_uuid", "topics", "topic1")
+	suite.Equal([]string{"uuid2"}, qACL.ACL)
+
+	suite.Nil(store.ModACL("argo_uuid", "subscriptions", "sub1", []string{"uuid3"}))
+	suite.Nil(store.ModACLVersion("argo_uuid", "subscriptions", "sub1", []string{"uuid4"}, 1))
+	suite.Equal("not found", store.ModACLVersion("argo_uuid", "subscriptions", "unknown", []string{"uuid4"}, 0).Error())
+}
+
+func (suite *StoreTestSuite) TestOffsetSnapshots() {
+
+	store := NewMockStore("", "")
+	t1 := time.Date(2019, 5, 6, 10, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+
+	suite.Nil(store.InsertOffsetSnapshot("argo_uuid", "20190506T110000Z", t2, []QSubOffset{{Subscription: "sub1", Offset: 4}}))
+	suite.Nil(store.InsertOffsetSnapshot("argo_uuid", "20190506T100000Z", t1, []QSubOffset{{Subscription: "sub1", Offset: 2}}))
+	suite.Nil(store.InsertOffsetSnapshot("argo_uuid2", "20190506T100000Z", t1, []QSubOffset{}))
+
+	// snapshots are returned oldest first and only for the given project
+	snapshots, err := store.QueryOffsetSnapshots("argo_uuid", "")
+	suite.Nil(err)
+	suite.Equal(2, len(snapshots))
+	suite.Equal("20190506T100000Z", snapshots[0].Name)
+	suite.Equal(int64(2), snapshots[0].Offsets[0].Offset)
+
+	snapshots, _ = store.QueryOffsetSnapshots("argo_uuid", "20190506T110000Z")
+	suite.Equal(1, len(snapshots))
+	suite.Equal(t2, snapshots[0].CreatedOn)
+
+	suite.Nil(store.RemoveOffsetSnapshots(t2))
+	snapshots, _ = store.QueryOffsetSnapshots("argo_uuid", "")
+	suite.Equal(1, len(snapshots))
+	suite.Equal("20190506T110000Z", snapshots[0].Name)
+	snapshots, _ = store.QueryOffsetSnapshots("argo_uuid2", "")
+	suite.Equal(0, len(snapshots))
+}
+
+func (suite *StoreTestSuite) TestCountByProject() {
+
+	store := NewMockStore("mockhost", "mockbase")
+
+	tc, err := store.CountTopicsByProject("argo_uuid")
+	suite.Nil(err)
+	suite.Equal(int64(4), tc)
+
+	sc, err := store.CountSubsByProject("argo_uuid")
+	suite.Nil(err)
+	suite.Equal(int64(4), sc)
+
+	tc2, _ := store.CountTopicsByProject("unknown")
+	suite.Equal(int64(0), tc2)
+
+	sc2, _ := store.CountSubsByProject("unknown")
+	suite.Equal(int64(0), sc2)
+
+	// soft-deleted topics are neither counted nor listed by their acl
+	aclTopics, _ := store.QueryTopicsByACL("argo_uuid", "uuid1")
+	store.SoftDeleteTopic("argo_uuid", "topic1", time.Date(2020, 11, 25, 0, 0, 0, 0, time.UTC))
+	tc, _ = store.CountTopicsByProject("argo_uuid")
+	suite.Equal(int64(3), tc)
+	remaining, _ := store.QueryTopicsByACL("argo_uuid", "uuid1")
+	suite.Equal(len(aclTopics)-1, len(remaining))
+	for _, topic := range remaining {
+		suite.NotEqual("topic1", topic.Name)
+	}
+}
+
+func (suite *StoreTestSuite) TestOffsetTimes() {
+	store := NewMockStore("", "")
+
+	store.InsertOffsetTime("argo_uuid", "topic1", 0, time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC))
+	store.InsertOffsetTime("argo_uuid", "topic1", 1, time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC))
+	store.InsertOffsetTime("argo_uuid", "topic1", 2, time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC))
+	store.InsertOffsetTime("argo_uuid", "topic2", 0, time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC))
+
+	// an offset at the exact timestamp
+	off, err := store.QueryOffsetByTime("argo_uuid", "topic1", time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC))
+	suite.Nil(err)
+	suite.Equal(int64(1), off)
+
+	// the first offset after the timestamp
+	off, _ = store.QueryOffsetByTime("argo_uuid", "topic1", time.Date(2019, 6, 10, 12, 0, 0, 0, time.UTC))
+	suite.Equal(int64(1), off)
+
+	// nothing has been published after the timestamp
+	off, _ = store.QueryOffsetByTime("argo_uuid", "topic1", time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC))
+	suite.Equal(int64(-1), off)
+
+	// recording an offset again updates its publish time
+	store.InsertOffsetTime("argo_uuid", "topic1", 0, time.Date(2019, 6, 13, 0, 0, 0, 0, time.UTC))
+	suite.Equal(4, len(store.OffsetTimes))
+	off, _ = store.QueryOffsetByTime("argo_uuid", "topic1", time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC))
+	suite.Equal(int64(0), off)
+
+	// removing the offsets of a topic before one leaves the rest and the other topics untouched
+	suite.Nil(store.RemoveOffsetTimes("argo_uuid", "topic1", 2))
+	suite.Equal(2, len(store.OffsetTimes))
+	off, _ = store.QueryOffsetByTime("argo_uuid", "topic1", time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC))
+	suite.Equal(int64(2), off)
+	off, _ = store.QueryOffsetByTime("argo_uuid", "topic2", time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC))
+	suite.Equal(int64(0), off)
+}
+
+func (suite *StoreTestSuite) TestSubOffsetVersion() {
+	store := NewMockStore("", "")
+
+	// a pull against the version that was read succeeds and bumps the version
+	suite.Nil(store.UpdateSubPull("argo_uuid", "sub1", 2, "2019-06-10T09:00:00Z", 0))
+	suite.Equal(int64(1), store.SubList[0].Version)
+
+	// a second pull that read the subscription before the first one got persisted conflicts
+	suite.EqualError(store.UpdateSubPull("argo_uuid", "sub1", 4, "2019-06-10T09:00:00Z", 0), "conflict")
+	suite.Equal(int64(2), store.SubList[0].NextOffset)
+
+	// an ack that read a stale version conflicts and leaves the offsets intact
+	suite.EqualError(store.UpdateSubOffsetAck("argo_uuid", "sub1", 2, "2019-06-10T09:00:05Z", 0), "conflict")
+	suite.Equal(int64(0), store.SubList[0].Offset)
+
+	suite.Nil(store.UpdateSubOffsetAck("argo_uuid", "sub1", 2, "2019-06-10T09:00:05Z", 1))
+	suite.Equal(int64(2), store.SubList[0].Offset)
+	suite.Equal(int64(2), store.SubList[0].Version)
+
+	// explicit offset modifications count as updates as well
+	store.UpdateSubOffset("argo_uuid", "sub1", 0)
+	suite.Equal(int64(3), store.SubList[0].Version)
+
+	suite.EqualError(store.UpdateSubPull("argo_uuid", "unknown", 4, "2019-06-10T09:00:00Z", 0), "not found")
+}
+
+func (suite *StoreTestSuite) TestAckTimeoutPrecision() {
+	store := NewMockStore("", "")
+
+	suite.Nil(store.UpdateSubPull("argo_uuid", "sub1", 2, "2019-06-10T09:00:00.900Z", 0))
+
+	// the deadline of 10 seconds passed by a fraction of a second
+	suite.EqualError(store.UpdateSubOffsetAck("argo_uuid", "sub1", 2, "2019-06-10T09:00:10.950Z", 1), "ack timeout")
+
+	suite.Nil(store.UpdateSubOffsetAck("argo_uuid", "sub1", 2, "2019-06-10T09:00:10.850Z", 1))
+}
+
+func (suite *StoreTestSuite) TestSubAckedMsgs() {
+	store := NewMockStore("", "")
+
+	suite.Nil(store.UpdateSubPull("argo_uuid", "sub1", 2, "2019-06-10T09:00:00Z", 0))
+	suite.Nil(store.UpdateSubOffsetAck("argo_uuid", "sub1", 2, "2019-06-10T09:00:05Z", 1))
+	suite.Equal(int64(2), store.SubList[0].AckedMsgs)
+
+	// failed acks leave the counter untouched
+	suite.EqualError(store.UpdateSubOffsetAck("argo_uuid", "sub1", 3, "2019-06-10T09:00:05Z", 2), "no ack pending")
+	suite.Equal(int64(2), store.SubList[0].AckedMsgs)
+
+	// only the messages between the previous and the acknowledged offset are counted
+	suite.Nil(store.UpdateSubPull("argo_uuid", "sub1", 5, "2019-06-10T09:01:00Z", 2))
+	suite.Nil(store.UpdateSubOffsetAck("argo_uuid", "sub1", 5, "2019-06-10T09:01:05Z", 3))
+	suite.Equal(int64(5), store.SubList[0].AckedMsgs)
+
+	// moving the offset explicitly is not an acknowledgement
+	store.UpdateSubOffset("argo_uuid", "sub1", 0)
+	suite.Equal(int64(5), store.SubList[0].AckedMsgs)
+
+	suite.Nil(store.ResetSubAckedMsgs("argo_uuid", "sub1"))
+	suite.Equal(int64(0), store.SubList[0].AckedMsgs)
+	suite.EqualError(store.ResetSubAckedMsgs("argo_uuid", "unknown"), "not found")
+}
+
+func (suite *StoreTestSuite) TestNamePrefix() {
+	store := NewMockStore("", "")
+
+	tpList, ts, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "topic")
+	suite.Equal(4, len(tpList))
+	suite.Equal(int32(4), ts)
+
+	tpList, ts, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "topic2")
+	suite.Equal(1, len(tpList))
+	suite.Equal("topic2", tpList[0].Name)
+	suite.Equal(int32(1), ts)
+
+	// names are matched case-sensitively
+	tpList, ts, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "Topic")
+	suite.Equal(0, len(tpList))
+	suite.Equal(int32(0), ts)
+
+	// the prefix applies to every page and to the total size
+	subList, ts, pg, _ := store.QuerySubs("argo_uuid", "", "", "", 2, nil, "sub")
+	suite.Equal(2, len(subList))
+	suite.Equal(int32(4), ts)
+	suite.Equal("1", pg)
+
+	subList, ts, pg, _ = store.QuerySubs("argo_uuid", "", "", "", 2, nil, "sub3")
+	suite.Equal(1, len(subList))
+	suite.Equal("sub3", subList[0].Name)
+	suite.Equal(int32(1), ts)
+	suite.Equal("", pg)
+}
+
+func (suite *StoreTestSuite) TestParseProjectRoutes() {
+
+	routes := ParseProjectRoutes([]string{
+		"argo_uuid=argo_tenant",
+		"argo_uuid2 = mongo4:27017,mongo5:27017/argo_tenant2",
+		"argo_uuid=argo_other",
+		"argo_uuid3",
+		"=argo_tenant",
+		"argo_uuid4=mongo4:27017/",
+	}, "localhost")
+
+	suite.Equal([]ProjectRoute{
+		{ProjectUUID: "argo_uuid", Server: "localhost", Database: "argo_tenant"},
+		{ProjectUUID: "argo_uuid2", Server: "mongo4:27017,mongo5:27017", Database: "argo_tenant2"},
+	}, routes)
+}
+
+func (suite *StoreTestSuite) TestRoutingStore() {
+
+	shared := NewMockStore("", "")
+	pinned := &MockStore{Session: true, TopicsACL: map[string]QAcl{}, SubsACL: map[string]QAcl{}}
+	store := NewRoutingStore(shared, map[string]Store{"argo_uuid": pinned})
+
+	suite.Equal(pinned, store.For("argo_uuid"))
+	suite.Equal(shared, store.For("argo_uuid2"))
+
+	// the resources of the pinned project reside in its own store
+	qTopics, _, _, _ := store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
+	suite.Equal(0, len(qTopics))
+
+	created := time.Date(2020, 11, 19, 0, 0, 0, 0, time.UTC)
+	suite.Nil(store.InsertTopic("argo_uuid", "tenant_topic", "", "", created))
+	qTopics, _, _, _ = store.QueryTopics("argo_uuid", "", "", "", 0, false, nil, "")
+	suite.Equal(1, len(qTopics))
+	suite.Equal("tenant_topic", qTopics[0].Name)
+	suite.Equal(1, len(pinned.TopicList))
+
+	sharedTopics, _, _, _ := shared.QueryTopics("argo_uuid", "", "tenant_topic", "", 0, false, nil, "")
+	suite.Equal(0, len(sharedTopics))
+
+	// projects and users stay in the shared store
+	qProjects, _ := store.QueryProjects("argo_uuid", "")
+	suite.Equal("ARGO", qProjects[0].Name)
+	suite.Equal(0, len(pinned.ProjectList))
+
+	// service wide operations span every store
+	topicsCount, _ := store.TopicsCount(created.Add(-time.Hour), created.Add(time.Hour))
+	suite.Equal(1, topicsCount)
+
+	pinned.SubList = append(pinned.SubList, QSub{ProjectUUID: "argo_uuid", Name: "tenant_push", PushEndpoint: "https://tenant.example.com/receive"})
+	suite.Equal(len(shared.QueryPushSubs())+1, len(store.QueryPushSubs()))
+
+	// clones keep routing to the clones of the same stores
+	suite.Equal(pinned, store.Clone().(*RoutingStore).For("argo_uuid"))
+}
+
 func TestStoresTestSuite(t *testing.T) {
 	suite.Run(t, new(StoreTestSuite))
 }
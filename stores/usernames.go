@@ -0,0 +1,33 @@
+package stores
+
+import "strings"
+
+// missingUsernames returns the usernames that don't match any of the existing names, uniquely and in input order.
+// Usernames that differ only in their case are the same username, unless the matching is case sensitive
+func missingUsernames(usernames []string, existing []string, caseSensitive bool) []string {
+
+	fold := func(name string) string {
+		if caseSensitive {
+			return name
+		}
+		return strings.ToLower(name)
+	}
+
+	found := make(map[string]bool)
+	for _, name := range existing {
+		found[fold(name)] = true
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	for _, username := range usernames {
+		key := fold(username)
+		if found[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		missing = append(missing, username)
+	}
+
+	return missing
+}
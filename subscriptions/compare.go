@@ -0,0 +1,108 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// FieldDiff is a configuration field whose value differs between two subscriptions
+type FieldDiff struct {
+	Field string      `json:"field"`
+	Value interface{} `json:"value"`
+	With  interface{} `json:"with"`
+}
+
+// Comparison holds the configuration fields that differ between a subscription and the one it is compared with
+type Comparison struct {
+	Subscription string      `json:"subscription"`
+	With         string      `json:"with"`
+	Identical    bool        `json:"identical"`
+	Differences  []FieldDiff `json:"differences"`
+}
+
+// comparedField is a configuration field along with its value for each one of the compared subscriptions
+type comparedField struct {
+	name  string
+	value interface{}
+	with  interface{}
+}
+
+// Compare reports the fields of the effective configurations, the offsets and the acls that differ between the
+// two subscriptions. Defaults are resolved before comparing, so a declared default matches an undeclared one.
+// The acls are compared regardless of the order of their users and the authorization header values are never reported
+func Compare(sub Subscription, subACL []string, with Subscription, withACL []string) Comparison {
+
+	cfg := sub.EffectiveConfig()
+	withCfg := with.EffectiveConfig()
+
+	fields := []comparedField{
+		{"topic", cfg.Topic, withCfg.Topic},
+		{"offset", sub.Offset, with.Offset},
+		{"ackDeadlineSeconds", cfg.AckDeadline.Value, withCfg.AckDeadline.Value},
+		{"newMessagesOnly", cfg.NewMessagesOnly.Value, withCfg.NewMessagesOnly.Value},
+		{"deduplicate", cfg.Deduplicate.Value, withCfg.Deduplicate.Value},
+		{"transform", cfg.Transform.Value, withCfg.Transform.Value},
+		{"maxConcurrentPulls", cfg.MaxConcurrentPulls.Value, withCfg.MaxConcurrentPulls.Value},
+		{"prioritize", cfg.Prioritize.Value, withCfg.Prioritize.Value},
+		{"atMostOnce", cfg.AtMostOnce.Value, withCfg.AtMostOnce.Value},
+		{"isolationLevel", cfg.IsolationLevel.Value, withCfg.IsolationLevel.Value},
+		{"defaultMaxMessages", cfg.DefaultMaxMessages.Value, withCfg.DefaultMaxMessages.Value},
+		{"samplingRate", cfg.SamplingRate.Value, withCfg.SamplingRate.Value},
+	}
+
+	pushCfg, withPushCfg := cfg.PushCfg, withCfg.PushCfg
+	if pushCfg != nil || withPushCfg != nil {
+		if pushCfg == nil {
+			pushCfg = &EffectivePushConfig{}
+		}
+		if withPushCfg == nil {
+			withPushCfg = &EffectivePushConfig{}
+		}
+		fields = append(fields,
+			comparedField{"pushConfig.pushEndpoint", pushCfg.Pend.Value, withPushCfg.Pend.Value},
+			comparedField{"pushConfig.maxMessages", pushCfg.MaxMessages.Value, withPushCfg.MaxMessages.Value},
+			comparedField{"pushConfig.authorizationHeaderType", pushCfg.AuthorizationHeaderType.Value, withPushCfg.AuthorizationHeaderType.Value},
+			comparedField{"pushConfig.retryPolicyType", pushCfg.RetryPolicyType.Value, withPushCfg.RetryPolicyType.Value},
+			comparedField{"pushConfig.retryPolicyPeriod", pushCfg.RetryPolicyPeriod.Value, withPushCfg.RetryPolicyPeriod.Value},
+			comparedField{"pushConfig.fanoutEndpoints", pushCfg.Fanout.Value, withPushCfg.Fanout.Value},
+			comparedField{"pushConfig.maxConcurrentDeliveries", pushCfg.MaxConcurrentDeliveries.Value, withPushCfg.MaxConcurrentDeliveries.Value},
+			comparedField{"pushConfig.maxRetryDuration", pushCfg.MaxRetryDuration.Value, withPushCfg.MaxRetryDuration.Value},
+			comparedField{"pushConfig.maxRetries", pushCfg.MaxRetries.Value, withPushCfg.MaxRetries.Value},
+			comparedField{"pushConfig.verified", pushCfg.Verified, withPushCfg.Verified},
+		)
+	}
+
+	result := Comparison{
+		Subscription: sub.FullName,
+		With:         with.FullName,
+		Differences:  []FieldDiff{},
+	}
+
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.value, f.with) {
+			result.Differences = append(result.Differences, FieldDiff{Field: f.name, Value: f.value, With: f.with})
+		}
+	}
+
+	if !reflect.DeepEqual(sortedCopy(subACL), sortedCopy(withACL)) {
+		result.Differences = append(result.Differences, FieldDiff{Field: "acl", Value: subACL, With: withACL})
+	}
+
+	result.Identical = len(result.Differences) == 0
+
+	return result
+}
+
+// sortedCopy returns the sorted values of the list, leaving the list itself untouched
+func sortedCopy(list []string) []string {
+	sorted := append([]string{}, list...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// ExportJSON exports the comparison to json format
+func (c *Comparison) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(c, "", "   ")
+	return string(output), err
+}
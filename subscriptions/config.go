@@ -0,0 +1,132 @@
+package subscriptions
+
+import (
+	"encoding/json"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+)
+
+const (
+	// ExplicitConfigSource labels a configuration value that was declared for the subscription
+	ExplicitConfigSource = "explicit"
+	// DefaultConfigSource labels a configuration value that the service falls back to
+	DefaultConfigSource = "default"
+	// DefaultAckDeadline is the ack deadline in seconds of subscriptions that don't declare one
+	DefaultAckDeadline = 10
+	// DefaultPushMaxMessages is the number of messages included in each push request
+	DefaultPushMaxMessages = int64(1)
+	// DefaultRetryPeriod is the period in milliseconds of a linear retry policy that doesn't declare one
+	DefaultRetryPeriod = 3000
+)
+
+// ConfigValue is a configuration value along with whether it was declared or resolved to a default
+type ConfigValue struct {
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// EffectiveConfig is the configuration a subscription is served with, with all defaults resolved
+type EffectiveConfig struct {
+	Name               string               `json:"name"`
+	Topic              string               `json:"topic"`
+	AckDeadline        ConfigValue          `json:"ackDeadlineSeconds"`
+	NewMessagesOnly    ConfigValue          `json:"newMessagesOnly"`
+	Deduplicate        ConfigValue          `json:"deduplicate"`
+	Transform          ConfigValue          `json:"transform"`
+	MaxConcurrentPulls ConfigValue          `json:"maxConcurrentPulls"`
+	Prioritize         ConfigValue          `json:"prioritize"`
+	AtMostOnce         ConfigValue          `json:"atMostOnce"`
+	IsolationLevel     ConfigValue          `json:"isolationLevel"`
+	DefaultMaxMessages ConfigValue          `json:"defaultMaxMessages"`
+	SamplingRate       ConfigValue          `json:"samplingRate"`
+	PushCfg            *EffectivePushConfig `json:"pushConfig,omitempty"`
+}
+
+// EffectivePushConfig is the push configuration of a subscription with all defaults resolved
+type EffectivePushConfig struct {
+	Pend                    ConfigValue `json:"pushEndpoint"`
+	MaxMessages             ConfigValue `json:"maxMessages"`
+	AuthorizationHeaderType ConfigValue `json:"authorizationHeaderType"`
+	RetryPolicyType         ConfigValue `json:"retryPolicyType"`
+	RetryPolicyPeriod       ConfigValue `json:"retryPolicyPeriod"`
+	Fanout                  ConfigValue `json:"fanoutEndpoints"`
+	MaxConcurrentDeliveries ConfigValue `json:"maxConcurrentDeliveries"`
+	MaxRetryDuration        ConfigValue `json:"maxRetryDuration"`
+	MaxRetries              ConfigValue `json:"maxRetries"`
+	Verified                bool        `json:"verified"`
+}
+
+// resolve returns the value labeled as explicit, unless it is unset or equal to the default.
+// Since some defaults get stored when a subscription is created, a stored default can't be told apart from a declared one
+func resolve(value interface{}, unset bool, def interface{}) ConfigValue {
+	if unset || value == def {
+		return ConfigValue{Value: def, Source: DefaultConfigSource}
+	}
+	return ConfigValue{Value: value, Source: ExplicitConfigSource}
+}
+
+// EffectiveConfig resolves the configuration the subscription is served with
+func (sub *Subscription) EffectiveConfig() EffectiveConfig {
+
+	cfg := EffectiveConfig{
+		Name:               sub.FullName,
+		Topic:              sub.FullTopic,
+		AckDeadline:        resolve(sub.Ack, sub.Ack <= 0, DefaultAckDeadline),
+		NewMessagesOnly:    resolve(sub.NewMessagesOnly, !sub.NewMessagesOnly, false),
+		Deduplicate:        resolve(sub.Deduplicate, !sub.Deduplicate, false),
+		Transform:          ConfigValue{Value: nil, Source: DefaultConfigSource},
+		MaxConcurrentPulls: resolve(sub.MaxConcurrentPulls, sub.MaxConcurrentPulls <= 0, 0),
+		Prioritize:         resolve(sub.Prioritize, !sub.Prioritize, false),
+		AtMostOnce:         resolve(sub.AtMostOnce, !sub.AtMostOnce, false),
+		IsolationLevel:     resolve(sub.IsolationLevel, sub.IsolationLevel == "", brokers.IsolationReadCommitted),
+		DefaultMaxMessages: resolve(sub.DefaultMaxMessages, sub.DefaultMaxMessages <= 0, defaultMaxMessages),
+		SamplingRate:       ConfigValue{Value: 1.0, Source: DefaultConfigSource},
+	}
+
+	if sub.Sampled() {
+		cfg.SamplingRate = ConfigValue{Value: *sub.SamplingRate, Source: ExplicitConfigSource}
+	}
+
+	if sub.Transform != nil {
+		cfg.Transform = ConfigValue{Value: sub.Transform, Source: ExplicitConfigSource}
+	}
+
+	pc := sub.PushCfg
+	if pc.IsEmpty() {
+		return cfg
+	}
+
+	retPolType := resolve(pc.RetPol.PolicyType, pc.RetPol.PolicyType == "", LinearRetryPolicyType)
+
+	// slow start retries don't make use of a period
+	retPolPeriod := ConfigValue{Value: 0, Source: DefaultConfigSource}
+	if retPolType.Value == LinearRetryPolicyType {
+		retPolPeriod = resolve(pc.RetPol.Period, pc.RetPol.Period <= 0, DefaultRetryPeriod)
+	}
+
+	fanout := ConfigValue{Value: []string{}, Source: DefaultConfigSource}
+	if len(pc.Fanout) > 0 {
+		fanout = ConfigValue{Value: pc.Fanout, Source: ExplicitConfigSource}
+	}
+
+	cfg.PushCfg = &EffectivePushConfig{
+		Pend:                    ConfigValue{Value: pc.Pend, Source: ExplicitConfigSource},
+		MaxMessages:             resolve(pc.MaxMessages, pc.MaxMessages <= 0, DefaultPushMaxMessages),
+		AuthorizationHeaderType: resolve(pc.AuthorizationHeader.Type, pc.AuthorizationHeader.Type == "", AutoGenerationAuthorizationHeader),
+		RetryPolicyType:         retPolType,
+		RetryPolicyPeriod:       retPolPeriod,
+		Fanout:                  fanout,
+		MaxConcurrentDeliveries: resolve(pc.MaxConcurrentDeliveries, pc.MaxConcurrentDeliveries <= 0, DefaultMaxConcurrentDeliveries),
+		MaxRetryDuration:        resolve(pc.MaxRetryDuration, pc.MaxRetryDuration <= 0, 0),
+		MaxRetries:              resolve(pc.MaxRetries, pc.MaxRetries <= 0, pc.EffectiveMaxRetries()),
+		Verified:                pc.Verified,
+	}
+
+	return cfg
+}
+
+// ExportJSON exports the effective configuration to json format
+func (cfg *EffectiveConfig) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(cfg, "", "   ")
+	return string(output), err
+}
@@ -0,0 +1,67 @@
+package subscriptions
+
+import (
+	"strconv"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// DefaultDedupWindow is the number of acknowledged message ids remembered for each deduplicating subscription
+// when the service doesn't configure one
+const DefaultDedupWindow = 10000
+
+// AckedSet holds the message ids that a deduplicating subscription won't deliver again
+type AckedSet map[int64]bool
+
+// Has returns true if the message id at the given offset has already been acknowledged
+func (s AckedSet) Has(offset int64) bool {
+	return s[offset]
+}
+
+// AckedIDs returns the message ids acknowledged through the subscription that are still remembered.
+// Subscriptions that don't deduplicate always get an empty set
+func (sub *Subscription) AckedIDs(store stores.Store) (AckedSet, error) {
+
+	set := AckedSet{}
+
+	if !sub.Deduplicate {
+		return set, nil
+	}
+
+	ids, err := store.QueryAckedIDs(sub.ProjectUUID, sub.Name)
+	if err != nil {
+		return set, err
+	}
+
+	for _, id := range ids {
+		if off, err := strconv.ParseInt(id, 10, 64); err == nil {
+			set[off] = true
+		}
+	}
+
+	return set, nil
+}
+
+// RecordAcked remembers the ids of the messages between the two offsets, both included, as acknowledged.
+// Only the latest window of ids is kept, so a range wider than the window only records its tail
+func (sub *Subscription) RecordAcked(from int64, to int64, window int, store stores.Store) error {
+
+	if !sub.Deduplicate || to < from {
+		return nil
+	}
+
+	if window <= 0 {
+		window = DefaultDedupWindow
+	}
+
+	if to-from+1 > int64(window) {
+		from = to - int64(window) + 1
+	}
+
+	ids := make([]string, 0, to-from+1)
+	for off := from; off <= to; off++ {
+		ids = append(ids, strconv.FormatInt(off, 10))
+	}
+
+	return store.AppendAckedIDs(sub.ProjectUUID, sub.Name, ids, window)
+}
@@ -0,0 +1,202 @@
+package subscriptions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/messages"
+	"github.com/ARGOeu/argo-messaging/stores"
+)
+
+// drainBatchSize is the number of messages read from the broker and written to the sink
+// before the offset of a draining subscription is advanced
+const drainBatchSize = 500
+
+// ErrUnsupportedSink is returned for sink urls whose scheme no sink has been registered for
+var ErrUnsupportedSink = errors.New("unsupported sink")
+
+// Sink stores the messages drained from subscriptions, e.g. as local files or as the objects of an object store
+type Sink interface {
+	// Create opens a new export with the given name for writing, returning the location it can be found at
+	Create(name string) (io.WriteCloser, string, error)
+}
+
+// SinkOpener creates the sink that a sink url points to
+type SinkOpener func(u *url.URL) (Sink, error)
+
+var (
+	sinksMu sync.RWMutex
+	sinks   = map[string]SinkOpener{}
+)
+
+// RegisterSink makes the sink urls of the given scheme, e.g. s3, open their sinks through the opener.
+// Sinks should be registered before the service starts serving requests
+func RegisterSink(scheme string, open SinkOpener) {
+	sinksMu.Lock()
+	defer sinksMu.Unlock()
+	sinks[strings.ToLower(scheme)] = open
+}
+
+// OpenSink opens the sink the url points to. Urls with the file scheme, or without a scheme at all,
+// point to the local directory of their path, any other scheme needs a registered sink
+func OpenSink(rawURL string) (Sink, error) {
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "" || scheme == "file" {
+		if u.Path == "" {
+			return nil, errors.New("the sink's directory is missing")
+		}
+		return FileSink{Dir: u.Path}, nil
+	}
+
+	sinksMu.RLock()
+	open, ok := sinks[scheme]
+	sinksMu.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedSink
+	}
+
+	return open(u)
+}
+
+// FileSink writes the exports as the files of a local directory, which is created if missing
+type FileSink struct {
+	Dir string
+}
+
+// Create creates the export file, failing if a file with the same name already exists
+func (fs FileSink) Create(name string) (io.WriteCloser, string, error) {
+
+	if err := os.MkdirAll(fs.Dir, 0750); err != nil {
+		return nil, "", err
+	}
+
+	path := filepath.Join(fs.Dir, name)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return f, path, nil
+}
+
+// DrainResult summarizes the export of the messages a drain consumed from a subscription
+type DrainResult struct {
+	Subscription string `json:"subscription"`
+	Location     string `json:"location,omitempty"`
+	Messages     int64  `json:"messages"`
+	Bytes        int64  `json:"bytes"`
+	OldOffset    int64  `json:"old_offset"`
+	NewOffset    int64  `json:"new_offset"`
+}
+
+// ExportJSON exports the drain result to json format
+func (res *DrainResult) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(res, "", "   ")
+	return string(output), err
+}
+
+// exportName names the export of a subscription drained at the given time
+func exportName(projectUUID string, sub string, now time.Time) string {
+	return fmt.Sprintf("%s.%s.%s.ndjson", projectUUID, sub, now.UTC().Format("20060102T150405Z"))
+}
+
+// Drain consumes all the messages available to the subscription and writes them to the sink as newline delimited json,
+// one message per line. The offset of the subscription is advanced after each batch of messages is written,
+// so a drain that fails midway leaves the subscription at the first message that wasn't exported.
+// Messages that the broker's retention already removed are skipped. A subscription without available messages
+// is left untouched and no export is created for it
+func Drain(ctx context.Context, projectUUID string, sub Subscription, sink Sink, now time.Time, store stores.Store, broker brokers.Broker) (DrainResult, error) {
+
+	res := DrainResult{
+		Subscription: sub.FullName,
+		OldOffset:    sub.Offset,
+		NewOffset:    sub.Offset,
+	}
+
+	from := sub.Offset
+	if minOff := broker.GetMinOffset(sub.BrokerTopic); from < minOff {
+		from = minOff
+	}
+
+	maxOff := broker.GetMaxOffset(sub.BrokerTopic)
+	if from >= maxOff {
+		return res, nil
+	}
+
+	w, location, err := sink.Create(exportName(projectUUID, sub.Name, now))
+	if err != nil {
+		return res, err
+	}
+	res.Location = location
+
+	err = drainRange(ctx, projectUUID, sub, from, maxOff, w, &res, store, broker)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	return res, err
+}
+
+// drainRange writes the messages between the offsets in batches, advancing the subscription past each written batch
+func drainRange(ctx context.Context, projectUUID string, sub Subscription, from int64, to int64, w io.Writer, res *DrainResult, store stores.Store, broker brokers.Broker) error {
+
+	for from < to {
+
+		batchEnd := from + drainBatchSize
+		if batchEnd > to {
+			batchEnd = to
+		}
+
+		msgs, err := broker.ConsumeRange(ctx, sub.BrokerTopic, from, batchEnd)
+		if err != nil {
+			return err
+		}
+
+		for i, msg := range msgs {
+			curMsg, err := messages.LoadMsgJSON([]byte(msg))
+			if err != nil {
+				return err
+			}
+			// the messages keep the id they were published with, unless they were stored without one
+			if curMsg.ID == "" {
+				curMsg.ID = strconv.FormatInt(from+int64(i), 10)
+			}
+
+			line, err := json.Marshal(curMsg)
+			if err != nil {
+				return err
+			}
+			line = append(line, '\n')
+
+			n, err := w.Write(line)
+			res.Bytes += int64(n)
+			if err != nil {
+				return err
+			}
+			res.Messages++
+		}
+
+		store.UpdateSubOffset(projectUUID, sub.Name, batchEnd)
+		res.NewOffset = batchEnd
+		from = batchEnd
+	}
+
+	return nil
+}
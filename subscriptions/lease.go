@@ -0,0 +1,50 @@
+package subscriptions
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/twinj/uuid"
+)
+
+// DefaultPullLeaseTTL is how long a pull holds on to its lease when the service doesn't configure it
+const DefaultPullLeaseTTL = 30 * time.Second
+
+// PullLease is held by a pull while it proceeds, counting against the subscription's max concurrent pulls
+type PullLease struct {
+	ID           string
+	ProjectUUID  string
+	Subscription string
+}
+
+// AcquirePullLease takes one of the leases of the subscription's concurrent pulls, returning false when all of them are held.
+// Subscriptions without a limit always give out an empty lease. A lease that doesn't get released expires after the ttl
+func (sub *Subscription) AcquirePullLease(now time.Time, ttl time.Duration, store stores.Store) (PullLease, bool, error) {
+
+	if sub.MaxConcurrentPulls <= 0 {
+		return PullLease{}, true, nil
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultPullLeaseTTL
+	}
+
+	lease := PullLease{ID: uuid.NewV4().String(), ProjectUUID: sub.ProjectUUID, Subscription: sub.Name}
+
+	acquired, err := store.AcquirePullLease(sub.ProjectUUID, sub.Name, lease.ID, sub.MaxConcurrentPulls, now, now.Add(ttl))
+	if err != nil || !acquired {
+		return PullLease{}, false, err
+	}
+
+	return lease, true, nil
+}
+
+// Release gives the lease back, so that another pull can proceed
+func (l PullLease) Release(store stores.Store) error {
+
+	if l.ID == "" {
+		return nil
+	}
+
+	return store.ReleasePullLease(l.ProjectUUID, l.Subscription, l.ID)
+}
@@ -0,0 +1,117 @@
+package subscriptions
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ARGOeu/argo-messaging/messages"
+)
+
+const (
+	// MaxProjectionFields is the max number of field paths a pull request can project the messages' data to
+	MaxProjectionFields = 32
+	// MaxProjectionDepth is the max number of keys in a projected field path
+	MaxProjectionDepth = 10
+)
+
+// Projection lists the dot separated paths of the json payload fields, e.g. envelope.body, that a consumer
+// wants to receive. Every other field of the messages' data is left out of the delivered messages
+type Projection []string
+
+// Validate checks that the projection declares well formed paths and stays within the complexity bounds
+func (p Projection) Validate() error {
+
+	if len(p) > MaxProjectionFields {
+		return fmt.Errorf("fields can't declare more than %d paths", MaxProjectionFields)
+	}
+
+	for _, path := range p {
+		keys := strings.Split(path, ".")
+		if len(keys) > MaxProjectionDepth {
+			return fmt.Errorf("fields can't declare paths deeper than %d keys", MaxProjectionDepth)
+		}
+		for _, key := range keys {
+			if strings.TrimSpace(key) == "" {
+				return errors.New(InvalidFieldsError)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply replaces the message's data with a json object that holds only the projected fields.
+// Fields missing from the payload are omitted. Messages that can't be projected, because their data
+// are compressed or not a json object, are left untouched and an error is returned
+func (p Projection) Apply(msg *messages.Message) error {
+
+	if msg.IsCompressed() {
+		return errors.New("compressed messages can't be projected")
+	}
+
+	data, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+
+	projected := map[string]interface{}{}
+	for _, path := range p {
+		keys := strings.Split(path, ".")
+		if value, found := lookupPath(payload, keys); found {
+			setPath(projected, keys, value)
+		}
+	}
+
+	out, err := json.Marshal(projected)
+	if err != nil {
+		return err
+	}
+
+	msg.Data = b64.StdEncoding.EncodeToString(out)
+	return nil
+}
+
+// lookupPath returns the value found under the keys of the payload
+func lookupPath(payload map[string]interface{}, keys []string) (interface{}, bool) {
+
+	var value interface{} = payload
+	for _, key := range keys {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// setPath stores the value under the keys of the projected object, creating the intermediate objects.
+// A path nested in an already projected field is covered by it and leaves the object as is
+func setPath(projected map[string]interface{}, keys []string, value interface{}) {
+
+	obj := projected
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := obj[key].(map[string]interface{})
+		if !ok {
+			if _, exists := obj[key]; exists {
+				return
+			}
+			next = map[string]interface{}{}
+			obj[key] = next
+		}
+		obj = next
+	}
+
+	obj[keys[len(keys)-1]] = value
+}
@@ -0,0 +1,101 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	log "github.com/sirupsen/logrus"
+)
+
+// PurgeOptions holds the age of the messages that a purge expires and whether the broker should delete them too
+type PurgeOptions struct {
+	OlderThanSeconds int64 `json:"older_than_seconds"`
+	DeleteRecords    bool  `json:"delete_records"`
+}
+
+// ExpiredMessages records how far a purge advanced a subscription and how many of its messages were expired
+type ExpiredMessages struct {
+	Subscription string `json:"subscription"`
+	OldOffset    int64  `json:"old_offset"`
+	NewOffset    int64  `json:"new_offset"`
+	Expired      int64  `json:"expired"`
+}
+
+// PurgeResult summarizes the purge of a topic's messages that were published before a cutoff
+type PurgeResult struct {
+	Topic           string            `json:"topic"`
+	Cutoff          string            `json:"cutoff"`
+	Offset          int64             `json:"offset"`
+	BrokerRetention bool              `json:"broker_retention"`
+	Subscriptions   []ExpiredMessages `json:"subscriptions"`
+}
+
+// ExportJSON exports the purge result to json format
+func (res *PurgeResult) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(res, "", "   ")
+	return string(output), err
+}
+
+// PurgeTopic advances every subscription of the topic past the messages published before the cutoff.
+// The given offset is the one of the first message published at or after the cutoff, as resolved by topics.OffsetAt,
+// if it is negative there is none and all the messages of the topic are considered old. Subscriptions that are already
+// past that offset are left untouched. With deleteRecords the broker is also asked to delete the purged messages, which is reported
+// in the result's broker retention, brokers without support for it keep the messages until their own retention removes them
+func PurgeTopic(projectUUID string, topic string, brokerTopic string, cutoff time.Time, off int64, deleteRecords bool, store stores.Store, broker brokers.Broker) (PurgeResult, error) {
+
+	res := PurgeResult{
+		Topic:         topic,
+		Cutoff:        timestamp.Format(cutoff),
+		Subscriptions: []ExpiredMessages{},
+	}
+
+	if off < 0 {
+		off = broker.GetMaxOffset(brokerTopic)
+	}
+
+	res.Offset = off
+
+	subs, err := store.QuerySubsByTopic(projectUUID, topic)
+	if err != nil {
+		return res, err
+	}
+
+	for _, s := range subs {
+
+		if s.Offset >= off {
+			continue
+		}
+
+		store.UpdateSubOffset(projectUUID, s.Name, off)
+
+		res.Subscriptions = append(res.Subscriptions, ExpiredMessages{
+			Subscription: s.Name,
+			OldOffset:    s.Offset,
+			NewOffset:    off,
+			Expired:      off - s.Offset,
+		})
+	}
+
+	if deleteRecords && off > 0 {
+		err := broker.DeleteRecordsBefore(brokerTopic, off)
+		if err == nil {
+			res.BrokerRetention = true
+		} else if !errors.Is(err, brokers.ErrDeleteRecordsUnsupported) {
+			log.WithFields(
+				log.Fields{
+					"type":         "service_log",
+					"project_uuid": projectUUID,
+					"topic_name":   topic,
+					"offset":       off,
+					"error":        err.Error(),
+				},
+			).Error("Could not delete the purged records of the topic")
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,164 @@
+package subscriptions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	log "github.com/sirupsen/logrus"
+)
+
+// OffsetAdjustment records a subscription whose stored offset fell outside the range of its topic in the broker
+type OffsetAdjustment struct {
+	ProjectUUID  string `json:"project_uuid"`
+	Subscription string `json:"subscription"`
+	OldOffset    int64  `json:"old_offset"`
+	NewOffset    int64  `json:"new_offset"`
+}
+
+// ReconciliationResult summarizes a run of the offset reconciliation
+type ReconciliationResult struct {
+	CompletedAt string             `json:"completed_at"`
+	Checked     int                `json:"checked"`
+	Adjustments []OffsetAdjustment `json:"adjustments"`
+}
+
+var (
+	lastReconciliationMu sync.RWMutex
+	lastReconciliation   *ReconciliationResult
+)
+
+// LastReconciliation returns the result of the most recent offset reconciliation
+// or false if no reconciliation has completed yet
+func LastReconciliation() (ReconciliationResult, bool) {
+
+	lastReconciliationMu.RLock()
+	defer lastReconciliationMu.RUnlock()
+
+	if lastReconciliation == nil {
+		return ReconciliationResult{}, false
+	}
+
+	return *lastReconciliation, true
+}
+
+func setLastReconciliation(res ReconciliationResult) {
+	lastReconciliationMu.Lock()
+	lastReconciliation = &res
+	lastReconciliationMu.Unlock()
+}
+
+// ReconcileOffsets clamps the stored offset of every subscription into the [min,max] range
+// that the broker currently holds for its topic, e.g. after the broker's retention removed old messages.
+// Subscriptions whose topic no longer exists, or whose offsets the broker can't report, are left untouched
+func ReconcileOffsets(now time.Time, store stores.Store, broker brokers.Broker) (ReconciliationResult, error) {
+
+	res := ReconciliationResult{Adjustments: []OffsetAdjustment{}}
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return res, err
+	}
+
+	for _, p := range projects {
+
+		subs, _, _, err := store.QuerySubs(p.UUID, "", "", "", 0, nil, "")
+		if err != nil {
+			return res, err
+		}
+
+		for _, s := range subs {
+
+			qTopics, _, _, err := store.QueryTopics(p.UUID, "", s.Topic, "", 0, false, nil, "")
+			if err != nil || len(qTopics) == 0 {
+				continue
+			}
+
+			fullTopic := qTopics[0].BrokerTopicName()
+			min := broker.GetMinOffset(fullTopic)
+			max := broker.GetMaxOffset(fullTopic)
+			if min < 0 || max < min {
+				continue
+			}
+
+			res.Checked++
+
+			newOffset := s.Offset
+			if newOffset < min {
+				newOffset = min
+			} else if newOffset > max {
+				newOffset = max
+			}
+
+			if newOffset == s.Offset {
+				continue
+			}
+
+			store.UpdateSubOffset(p.UUID, s.Name, newOffset)
+
+			log.WithFields(
+				log.Fields{
+					"type":              "service_log",
+					"project_uuid":      p.UUID,
+					"subscription_name": s.Name,
+					"old_offset":        s.Offset,
+					"new_offset":        newOffset,
+				},
+			).Warning("Subscription offset was out of the topic's range and has been adjusted")
+
+			res.Adjustments = append(res.Adjustments, OffsetAdjustment{
+				ProjectUUID:  p.UUID,
+				Subscription: s.Name,
+				OldOffset:    s.Offset,
+				NewOffset:    newOffset,
+			})
+		}
+	}
+
+	res.CompletedAt = timestamp.Format(now)
+	setLastReconciliation(res)
+
+	return res, nil
+}
+
+// StartReconciler reconciles the subscription offsets once on start up and,
+// if the interval is positive, periodically afterwards
+func StartReconciler(interval time.Duration, store stores.Store, broker brokers.Broker) {
+
+	reconcile := func() {
+		refStr := store.Clone()
+		res, err := ReconcileOffsets(time.Now(), refStr, broker)
+		refStr.Close()
+		if err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":  "service_log",
+					"error": err.Error(),
+				},
+			).Error("Offset reconciliation failed")
+			return
+		}
+		log.WithFields(
+			log.Fields{
+				"type":        "service_log",
+				"checked":     res.Checked,
+				"adjustments": len(res.Adjustments),
+			},
+		).Info("Offset reconciliation completed")
+	}
+
+	go func() {
+		reconcile()
+
+		if interval <= 0 {
+			return
+		}
+
+		ticker := time.NewTicker(interval)
+		for range ticker.C {
+			reconcile()
+		}
+	}()
+}
@@ -0,0 +1,239 @@
+package subscriptions
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrSnapshotNotFound is returned when restoring a snapshot that none of the projects has
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// OffsetSnapshot summarizes a snapshot of the subscription offsets of all projects
+type OffsetSnapshot struct {
+	Name          string `json:"name"`
+	CreatedOn     string `json:"created_on"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+// OffsetSnapshotList holds the offset snapshots that are kept, oldest first
+type OffsetSnapshotList struct {
+	Snapshots []OffsetSnapshot `json:"snapshots"`
+}
+
+// OffsetRestore records a subscription whose offset was restored from a snapshot. NewOffset differs from
+// SnapshotOffset when the snapshot's offset fell outside the range the broker currently holds for the topic
+type OffsetRestore struct {
+	ProjectUUID    string `json:"project_uuid"`
+	Subscription   string `json:"subscription"`
+	OldOffset      int64  `json:"old_offset"`
+	SnapshotOffset int64  `json:"snapshot_offset"`
+	NewOffset      int64  `json:"new_offset"`
+}
+
+// RestoreResult summarizes the restore of the subscription offsets from a snapshot
+type RestoreResult struct {
+	Snapshot string          `json:"snapshot"`
+	Restored []OffsetRestore `json:"restored"`
+	// Skipped are the subscriptions of the snapshot that no longer exist, or whose topic the broker can't report on
+	Skipped []string `json:"skipped"`
+}
+
+// snapshotName names the snapshot taken at the given time
+func snapshotName(now time.Time) string {
+	return now.UTC().Format("20060102T150405Z")
+}
+
+// SnapshotOffsets records the current offset of every subscription, one snapshot per project named after the given time,
+// and removes the snapshots that are older than the retention. A non positive retention keeps the snapshots forever
+func SnapshotOffsets(now time.Time, retention time.Duration, store stores.Store) (OffsetSnapshot, error) {
+
+	res := OffsetSnapshot{Name: snapshotName(now), CreatedOn: timestamp.Format(now)}
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return res, err
+	}
+
+	for _, p := range projects {
+
+		subs, _, _, err := store.QuerySubs(p.UUID, "", "", "", 0, nil, "")
+		if err != nil {
+			return res, err
+		}
+
+		offsets := make([]stores.QSubOffset, 0, len(subs))
+		for _, s := range subs {
+			offsets = append(offsets, stores.QSubOffset{Subscription: s.Name, Offset: s.Offset})
+		}
+
+		if err := store.InsertOffsetSnapshot(p.UUID, res.Name, now, offsets); err != nil {
+			return res, err
+		}
+		res.Subscriptions += len(offsets)
+	}
+
+	if retention <= 0 {
+		return res, nil
+	}
+
+	return res, store.RemoveOffsetSnapshots(now.Add(-retention))
+}
+
+// ListSnapshots returns the offset snapshots that are kept, merging the snapshots of the projects that share a name
+func ListSnapshots(store stores.Store) (OffsetSnapshotList, error) {
+
+	res := OffsetSnapshotList{Snapshots: []OffsetSnapshot{}}
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return res, err
+	}
+
+	created := map[string]time.Time{}
+	counts := map[string]int{}
+	for _, p := range projects {
+
+		snapshots, err := store.QueryOffsetSnapshots(p.UUID, "")
+		if err != nil {
+			return res, err
+		}
+
+		for _, s := range snapshots {
+			if _, ok := created[s.Name]; !ok {
+				created[s.Name] = s.CreatedOn
+			}
+			counts[s.Name] += len(s.Offsets)
+		}
+	}
+
+	for name, createdOn := range created {
+		res.Snapshots = append(res.Snapshots, OffsetSnapshot{Name: name, CreatedOn: timestamp.Format(createdOn), Subscriptions: counts[name]})
+	}
+	// the names follow the time the snapshots were taken at
+	sort.Slice(res.Snapshots, func(i, j int) bool {
+		return res.Snapshots[i].Name < res.Snapshots[j].Name
+	})
+
+	return res, nil
+}
+
+// RestoreSnapshot moves every subscription of the named snapshot back to the offset the snapshot recorded for it,
+// clamped into the [min,max] range that the broker currently holds for its topic. Subscriptions created after the snapshot
+// are left untouched, while the ones that got deleted since are skipped
+func RestoreSnapshot(name string, store stores.Store, broker brokers.Broker) (RestoreResult, error) {
+
+	res := RestoreResult{Snapshot: name, Restored: []OffsetRestore{}, Skipped: []string{}}
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return res, err
+	}
+
+	found := false
+	for _, p := range projects {
+
+		snapshots, err := store.QueryOffsetSnapshots(p.UUID, name)
+		if err != nil {
+			return res, err
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+		found = true
+
+		for _, so := range snapshots[0].Offsets {
+
+			fullName := "/projects/" + p.Name + "/subscriptions/" + so.Subscription
+
+			s, err := store.QueryOneSub(p.UUID, so.Subscription)
+			if err != nil {
+				res.Skipped = append(res.Skipped, fullName)
+				continue
+			}
+
+			qTopics, _, _, err := store.QueryTopics(p.UUID, "", s.Topic, "", 0, false, nil, "")
+			if err != nil || len(qTopics) == 0 {
+				res.Skipped = append(res.Skipped, fullName)
+				continue
+			}
+
+			fullTopic := qTopics[0].BrokerTopicName()
+			min := broker.GetMinOffset(fullTopic)
+			max := broker.GetMaxOffset(fullTopic)
+			if min < 0 || max < min {
+				res.Skipped = append(res.Skipped, fullName)
+				continue
+			}
+
+			newOffset := so.Offset
+			if newOffset < min {
+				newOffset = min
+			} else if newOffset > max {
+				newOffset = max
+			}
+
+			store.UpdateSubOffset(p.UUID, s.Name, newOffset)
+
+			log.WithFields(
+				log.Fields{
+					"type":              "service_log",
+					"project_uuid":      p.UUID,
+					"subscription_name": s.Name,
+					"snapshot":          name,
+					"old_offset":        s.Offset,
+					"new_offset":        newOffset,
+				},
+			).Info("Subscription offset restored from snapshot")
+
+			res.Restored = append(res.Restored, OffsetRestore{
+				ProjectUUID:    p.UUID,
+				Subscription:   s.Name,
+				OldOffset:      s.Offset,
+				SnapshotOffset: so.Offset,
+				NewOffset:      newOffset,
+			})
+		}
+	}
+
+	if !found {
+		return res, ErrSnapshotNotFound
+	}
+
+	return res, nil
+}
+
+// StartSnapshotter periodically snapshots the offsets of all subscriptions, keeping the snapshots for the retention
+func StartSnapshotter(interval time.Duration, retention time.Duration, store stores.Store) {
+
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			refStr := store.Clone()
+			res, err := SnapshotOffsets(time.Now().UTC(), retention, refStr)
+			refStr.Close()
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Offset snapshot failed")
+				continue
+			}
+			log.WithFields(
+				log.Fields{
+					"type":          "service_log",
+					"snapshot":      res.Name,
+					"subscriptions": res.Subscriptions,
+				},
+			).Info("Offset snapshot completed")
+		}
+	}()
+}
@@ -0,0 +1,82 @@
+package subscriptions
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+)
+
+// StalledSubscription is a subscription with messages waiting to be consumed whose offset has not advanced for a while
+type StalledSubscription struct {
+	Name          string `json:"name"`
+	Topic         string `json:"topic"`
+	Backlog       int64  `json:"backlog"`
+	LastProgress  string `json:"last_progress"`
+	LatestConsume string `json:"latest_consume,omitempty"`
+}
+
+// StalledList holds the stalled subscriptions of a project
+type StalledList struct {
+	Subscriptions []StalledSubscription `json:"subscriptions"`
+}
+
+// ExportJSON exports the stalled subscriptions to json format
+func (sl *StalledList) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(sl, "", "   ")
+	return string(output), err
+}
+
+// FindStalled returns the subscriptions of a project that have a backlog but whose offset has not advanced since the given time,
+// either through acknowledgements, push deliveries or streaming. Subscriptions that never made progress are judged by their creation time.
+// If a user is given, only the subscriptions the user has access to are examined. The max offset is retrieved from the broker once per topic
+func FindStalled(projectUUID string, userUUID string, since time.Time, store stores.Store, broker brokers.Broker) (StalledList, error) {
+
+	result := StalledList{Subscriptions: []StalledSubscription{}}
+
+	subs, err := Find(projectUUID, userUUID, "", "", 0, store)
+	if err != nil {
+		return result, err
+	}
+
+	maxOffsets := make(map[string]int64)
+
+	for _, sub := range subs.Subscriptions {
+
+		lastProgress := sub.LastProgress
+		if lastProgress.IsZero() {
+			lastProgress, _ = timestamp.Parse(sub.CreatedOn)
+		}
+
+		if !lastProgress.Before(since) {
+			continue
+		}
+
+		maxOff, ok := maxOffsets[sub.BrokerTopic]
+		if !ok {
+			maxOff = broker.GetMaxOffset(sub.BrokerTopic)
+			maxOffsets[sub.BrokerTopic] = maxOff
+		}
+
+		backlog := maxOff - sub.Offset
+		if backlog <= 0 {
+			continue
+		}
+
+		stalled := StalledSubscription{
+			Name:         sub.FullName,
+			Topic:        sub.FullTopic,
+			Backlog:      backlog,
+			LastProgress: timestamp.Format(lastProgress),
+		}
+		if !sub.LatestConsume.IsZero() {
+			stalled.LatestConsume = timestamp.Format(sub.LatestConsume)
+		}
+
+		result.Subscriptions = append(result.Subscriptions, stalled)
+	}
+
+	return result, nil
+}
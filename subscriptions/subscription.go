@@ -1,6 +1,11 @@
 package subscriptions
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"strconv"
@@ -9,11 +14,21 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
+	"github.com/ARGOeu/argo-messaging/topics"
+	"github.com/ARGOeu/argo-messaging/validation"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"net/http"
 	"net/url"
+	"regexp"
+	"sort"
 	"time"
 )
 
@@ -24,6 +39,30 @@ const (
 	DisabledAuthorizationHeader       = "disabled"
 	UnSupportedRetryPolicyError       = `Retry policy can only be of 'linear' or 'slowstart' type`
 	UnSupportedAuthorizationHeader    = `Authorization header type can only be of 'autogen' or 'disabled' type`
+	InvalidFanoutEndpointsError       = `Fanout endpoints should be valid https urls, distinct from each other and from the push endpoint`
+	InvalidMaxConcurrentDeliveries    = `Max concurrent deliveries should be between 1 and 100`
+	InvalidMaxConcurrentPulls         = `Max concurrent pulls should be between 0 and 100`
+	// DefaultMaxConcurrentDeliveries keeps a single delivery in flight, so that messages get pushed in order
+	DefaultMaxConcurrentDeliveries = 1
+	// MaxConcurrentDeliveriesLimit bounds the deliveries a single subscription can keep in flight
+	MaxConcurrentDeliveriesLimit = 100
+	InvalidMaxRetryDuration      = `Max retry duration should be between 0 and 604800 seconds`
+	// MaxRetryDurationLimit is the longest a push delivery can be retried for, a week being the default retention of a topic
+	MaxRetryDurationLimit = 7 * 24 * 60 * 60
+	InvalidMaxRetries     = `Max retries should be between 0 and 100`
+	// DefaultFanoutMaxRetries caps the retries of each endpoint of a subscription that fans out and doesn't declare max retries,
+	// so that an endpoint that keeps failing can't hold back the rest of them
+	DefaultFanoutMaxRetries = 10
+	// MaxRetriesLimit bounds the retries of a message on each endpoint
+	MaxRetriesLimit = 100
+	// MaxConcurrentPullsLimit bounds the pulls that can be allowed to proceed at once on a single subscription
+	MaxConcurrentPullsLimit   = 100
+	InvalidIsolationLevel     = `Isolation level can only be 'read_committed' or 'read_uncommitted'`
+	UnsupportedIsolationLevel = `The broker doesn't support the requested isolation level`
+	InvalidDefaultMaxMessages = `Default max messages should be a non-negative integer`
+	InvalidSamplingRate       = `Sampling rate should be greater than 0.0 and at most 1.0`
+	// SecretMask replaces the values of secret fields when subscriptions are being listed
+	SecretMask = "***"
 )
 
 var supportedRetryPolicyTypes = []string{
@@ -38,20 +77,93 @@ var supportedAuthorizationHeaderTypes = []string{
 
 // Subscription struct to hold information for a given topic
 type Subscription struct {
-	ProjectUUID   string     `json:"-"`
-	Name          string     `json:"-"`
-	Topic         string     `json:"-"`
-	FullName      string     `json:"name"`
-	FullTopic     string     `json:"topic"`
-	PushCfg       PushConfig `json:"pushConfig"`
-	Ack           int        `json:"ackDeadlineSeconds"`
-	Offset        int64      `json:"-"`
-	NextOffset    int64      `json:"-"`
-	PendingAck    string     `json:"-"`
-	PushStatus    string     `json:"push_status,omitempty"`
-	CreatedOn     string     `json:"created_on"`
-	LatestConsume time.Time  `json:"-"`
-	ConsumeRate   float64    `json:"-"`
+	ProjectUUID string `json:"-"`
+	Name        string `json:"-"`
+	FullName    string `json:"name"`
+	FullTopic   string `json:"topic"`
+	// Topic is the short name of the topic that FullTopic references
+	Topic      string     `json:"topicName"`
+	PushCfg    PushConfig `json:"pushConfig"`
+	Ack        int        `json:"ackDeadlineSeconds"`
+	Offset     int64      `json:"-"`
+	NextOffset int64      `json:"-"`
+	PendingAck string     `json:"-"`
+	// Version of the subscription's offsets, used to detect concurrent offset updates
+	Version       int64     `json:"-"`
+	PushStatus    string    `json:"push_status,omitempty"`
+	CreatedOn     string    `json:"created_on"`
+	LatestConsume time.Time `json:"-"`
+	ConsumeRate   float64   `json:"-"`
+	// Backlog is reported only when subscriptions get ordered by it
+	Backlog *int64 `json:"backlog,omitempty"`
+	// Transform is applied to the subscription's messages before they get delivered
+	Transform *Transform `json:"transform,omitempty"`
+	// NewMessagesOnly restricts the delivery to messages published at or after the subscription's creation
+	NewMessagesOnly bool `json:"newMessagesOnly,omitempty"`
+	// NotBefore holds the exact creation time of a subscription that delivers only new messages
+	NotBefore time.Time `json:"-"`
+	// BrokerTopic is the name of the subscription's topic in the broker
+	BrokerTopic string `json:"-"`
+	// Labels are key/value pairs that organize subscriptions, e.g. by team or environment
+	Labels map[string]string `json:"labels,omitempty"`
+	// Deduplicate skips the delivery of messages whose ids have already been acknowledged
+	Deduplicate bool `json:"deduplicate,omitempty"`
+	// MaxConcurrentPulls is the number of pulls allowed to proceed at once, zero meaning no limit
+	MaxConcurrentPulls int `json:"maxConcurrentPulls,omitempty"`
+	// Prioritize delivers the higher priority messages of each pulled batch ahead of the rest
+	Prioritize bool `json:"prioritize,omitempty"`
+	// LastProgress is the latest time the consumers advanced the offset
+	LastProgress time.Time `json:"-"`
+	// AtMostOnce commits the offset of a pull before the messages are handed out, so that they are never delivered twice
+	AtMostOnce bool `json:"atMostOnce,omitempty"`
+	// IsolationLevel is the broker isolation level the messages are consumed with, empty meaning read_committed
+	IsolationLevel string `json:"isolationLevel,omitempty"`
+	// DefaultMaxMessages is the number of messages returned by the pulls that don't declare maxMessages, zero meaning the service's default
+	DefaultMaxMessages int `json:"defaultMaxMessages,omitempty"`
+	// SamplingRate is the fraction of the messages that the pulls deliver, all of them when unset
+	SamplingRate *float64 `json:"samplingRate,omitempty"`
+}
+
+// ValidMaxConcurrentPulls checks that the declared max concurrent pulls is within bounds, zero lifting the limit
+func (sub *Subscription) ValidMaxConcurrentPulls() bool {
+	return sub.MaxConcurrentPulls >= 0 && sub.MaxConcurrentPulls <= MaxConcurrentPullsLimit
+}
+
+// ValidIsolationLevel checks that the declared isolation level is one of the broker isolation levels, if declared at all
+func (sub *Subscription) ValidIsolationLevel() bool {
+	return sub.IsolationLevel == "" || brokers.ValidIsolationLevel(sub.IsolationLevel)
+}
+
+// ReadIsolation returns the isolation level that the subscription's messages are consumed with
+func (sub *Subscription) ReadIsolation() string {
+	if sub.IsolationLevel == "" {
+		return brokers.IsolationReadCommitted
+	}
+	return sub.IsolationLevel
+}
+
+// ValidDefaultMaxMessages checks that the declared default max messages isn't negative, zero falling back to the service's default
+func (sub *Subscription) ValidDefaultMaxMessages() bool {
+	return sub.DefaultMaxMessages >= 0
+}
+
+// ValidSamplingRate checks that the declared sampling rate is a fraction above zero, a rate of zero would deliver nothing
+func (sub *Subscription) ValidSamplingRate() bool {
+	return sub.SamplingRate == nil || (*sub.SamplingRate > 0 && *sub.SamplingRate <= 1)
+}
+
+// Sampled returns true if the subscription delivers only a sample of its messages
+func (sub *Subscription) Sampled() bool {
+	return sub.SamplingRate != nil && *sub.SamplingRate < 1
+}
+
+// PullMaxMessages returns the number of messages returned by the subscription's pulls that don't declare maxMessages,
+// its own default taking precedence over the service's one
+func (sub *Subscription) PullMaxMessages() int {
+	if sub.DefaultMaxMessages > 0 {
+		return sub.DefaultMaxMessages
+	}
+	return defaultMaxMessages
 }
 
 // PushConfig holds optional configuration for push operations
@@ -62,12 +174,141 @@ type PushConfig struct {
 	RetPol              RetryPolicy         `json:"retryPolicy"`
 	VerificationHash    string              `json:"verification_hash"`
 	Verified            bool                `json:"verified"`
+	// Fanout holds additional endpoints that receive every message along with the push endpoint
+	Fanout []string `json:"fanoutEndpoints,omitempty"`
+	// MaxConcurrentDeliveries is the number of deliveries the push backend keeps in flight for the subscription
+	MaxConcurrentDeliveries int `json:"maxConcurrentDeliveries,omitempty"`
+	// MaxRetryDuration is the number of seconds the push backend keeps retrying a message before skipping it, zero meaning no limit
+	MaxRetryDuration int `json:"maxRetryDuration,omitempty"`
+	// MaxRetries is the number of times a failed delivery of a message is retried on each endpoint before the endpoint skips it
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// IsEmpty returns true if no push configuration has been declared
+func (pc *PushConfig) IsEmpty() bool {
+	return pc.Pend == "" && pc.MaxMessages == 0 && pc.AuthorizationHeader == (AuthorizationHeader{}) &&
+		pc.RetPol == (RetryPolicy{}) && pc.VerificationHash == "" && !pc.Verified && len(pc.Fanout) == 0 &&
+		pc.MaxConcurrentDeliveries == 0 && pc.MaxRetryDuration == 0 && pc.MaxRetries == 0
+}
+
+// ValidMaxRetries checks that the declared max retries is within bounds, zero meaning that it was not declared
+func (pc *PushConfig) ValidMaxRetries() bool {
+	return pc.MaxRetries >= 0 && pc.MaxRetries <= MaxRetriesLimit
+}
+
+// EffectiveMaxRetries returns the number of times a failed delivery is retried on each endpoint, zero meaning no limit.
+// Subscriptions that fan out fall back to the DefaultFanoutMaxRetries
+func (pc *PushConfig) EffectiveMaxRetries() int {
+	if pc.MaxRetries > 0 {
+		return pc.MaxRetries
+	}
+	if len(pc.Fanout) > 0 {
+		return DefaultFanoutMaxRetries
+	}
+	return 0
+}
+
+// ValidMaxRetryDuration checks that the declared max retry duration is within bounds, zero meaning that messages are retried indefinitely
+func (pc *PushConfig) ValidMaxRetryDuration() bool {
+	return pc.MaxRetryDuration >= 0 && pc.MaxRetryDuration <= MaxRetryDurationLimit
+}
+
+// ValidMaxConcurrentDeliveries checks that the declared max concurrent deliveries is within bounds,
+// zero meaning that it was not declared and the default applies
+func (pc *PushConfig) ValidMaxConcurrentDeliveries() bool {
+	return pc.MaxConcurrentDeliveries >= 0 && pc.MaxConcurrentDeliveries <= MaxConcurrentDeliveriesLimit
+}
+
+// EffectiveMaxConcurrentDeliveries returns the number of deliveries that can be in flight,
+// falling back to the DefaultMaxConcurrentDeliveries
+func (pc *PushConfig) EffectiveMaxConcurrentDeliveries() int {
+	if pc.MaxConcurrentDeliveries < 1 {
+		return DefaultMaxConcurrentDeliveries
+	}
+	return pc.MaxConcurrentDeliveries
+}
+
+// Endpoints returns all the endpoints a message should be delivered to, starting with the push endpoint
+func (pc *PushConfig) Endpoints() []string {
+	if pc.Pend == "" {
+		return []string{}
+	}
+	return append([]string{pc.Pend}, pc.Fanout...)
+}
+
+// IsVerified reports whether the ownership of the endpoint has been verified, which is required before
+// anything gets delivered to it. The verification covers the push endpoint along with every fanout endpoint
+func (pc *PushConfig) IsVerified(endpoint string) bool {
+	if !pc.Verified {
+		return false
+	}
+	for _, e := range pc.Endpoints() {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// SameEndpoints returns true if the push configuration delivers to the same endpoints as the given one,
+// in which case a verification of their ownership still holds
+func (pc *PushConfig) SameEndpoints(other PushConfig) bool {
+	endpoints, others := pc.Endpoints(), other.Endpoints()
+	if len(endpoints) != len(others) {
+		return false
+	}
+	declared := map[string]bool{}
+	for _, e := range others {
+		declared[e] = true
+	}
+	for _, e := range endpoints {
+		if !declared[e] {
+			return false
+		}
+	}
+	return true
+}
+
+// pushSchemes are the schemes, besides https, that push endpoints can be addressed over, along with the hosts they can reach
+var pushSchemes = map[string]*regexp.Regexp{}
+
+// AllowPushScheme makes the push endpoints addressed over the scheme to one of the matching hosts valid,
+// e.g. sqs once the push manager of the service has a transport for it
+func AllowPushScheme(scheme string, hosts *regexp.Regexp) {
+	pushSchemes[strings.ToLower(scheme)] = hosts
+}
+
+// IsValidPushEndpoint checks that the push endpoint is a valid https url, or a url over one of the allowed push schemes
+// to one of their hosts
+func IsValidPushEndpoint(endpoint string) bool {
+	if validation.IsValidHTTPS(endpoint) {
+		return true
+	}
+	u, err := url.ParseRequestURI(endpoint)
+	if err != nil {
+		return false
+	}
+	hosts, ok := pushSchemes[strings.ToLower(u.Scheme)]
+	return ok && u.Host != "" && hosts.MatchString(strings.ToLower(u.Host))
+}
+
+// ValidFanout checks that all fanout endpoints are valid push endpoints and that no endpoint is declared twice
+func ValidFanout(pushEnd string, fanout []string) bool {
+	seen := map[string]bool{pushEnd: true}
+	for _, endpoint := range fanout {
+		if seen[endpoint] || !IsValidPushEndpoint(endpoint) {
+			return false
+		}
+		seen[endpoint] = true
+	}
+	return true
 }
 
 // SubMetrics holds the subscription's metric details
 type SubMetrics struct {
 	MsgNum        int64     `json:"number_of_messages"`
 	TotalBytes    int64     `json:"total_bytes"`
+	AckedMsgs     int64     `json:"number_of_acked_messages"`
 	LatestConsume time.Time `json:"-"`
 	ConsumeRate   float64   `json:"-"`
 }
@@ -92,8 +333,10 @@ type PaginatedSubscriptions struct {
 
 // SubPullOptions holds info about a pull operation on a subscription
 type SubPullOptions struct {
-	RetImm string `json:"returnImmediately,omitempty"`
-	MaxMsg string `json:"maxMessages,omitempty"`
+	RetImm        string     `json:"returnImmediately,omitempty"`
+	MaxMsg        string     `json:"maxMessages,omitempty"`
+	RetCompressed string     `json:"returnCompressed,omitempty"`
+	Fields        Projection `json:"fields,omitempty"`
 }
 
 // SetOffset structure is used for input in set Offset Request
@@ -101,6 +344,22 @@ type SetOffset struct {
 	Offset int64 `json:"offset"`
 }
 
+// ReplayOptions structure is used for input in replay subscription requests
+type ReplayOptions struct {
+	Subscription   string `json:"subscription"`
+	Offset         *int64 `json:"offset,omitempty"`
+	Timestamp      string `json:"timestamp,omitempty"`
+	CopyACL        bool   `json:"copyAcl"`
+	CopyPushConfig bool   `json:"copyPushConfig"`
+}
+
+// CloneOptions structure is used for input in clone subscription requests
+type CloneOptions struct {
+	Subscription string `json:"subscription"`
+	Offset       *int64 `json:"offset,omitempty"`
+	Timestamp    string `json:"timestamp,omitempty"`
+}
+
 // Offsets is used as a json structure for show offsets Response
 type Offsets struct {
 	Max     int64 `json:"max"`
@@ -111,6 +370,22 @@ type Offsets struct {
 // AckIDs utility struct
 type AckIDs struct {
 	IDs []string `json:"AckIds"`
+	// AckOffset acknowledges all the messages up to and including the offset, instead of listing their ack ids
+	AckOffset *int64 `json:"ackOffset,omitempty"`
+}
+
+// OutstandingMessage holds a pulled message whose lease hasn't expired and that hasn't been acknowledged yet.
+// The messages of the same pull share its lease, so they have the same pull time and ack deadline
+type OutstandingMessage struct {
+	MessageID   string `json:"messageId"`
+	AckID       string `json:"ackId"`
+	PullTime    string `json:"pullTime"`
+	AckDeadline string `json:"ackDeadline"`
+}
+
+// OutstandingMessages is used as a json structure for the outstanding messages response
+type OutstandingMessages struct {
+	Messages []OutstandingMessage `json:"outstandingMessages"`
 }
 
 // Ack utility struct
@@ -118,6 +393,21 @@ type AckDeadline struct {
 	AckDeadline int `json:"ackDeadlineSeconds"`
 }
 
+// MaxConcurrentPulls utility struct
+type MaxConcurrentPulls struct {
+	MaxConcurrentPulls int `json:"maxConcurrentPulls"`
+}
+
+// IsolationLevel utility struct
+type IsolationLevel struct {
+	IsolationLevel string `json:"isolationLevel"`
+}
+
+// SubDefaultMaxMessages utility struct
+type SubDefaultMaxMessages struct {
+	DefaultMaxMessages int `json:"defaultMaxMessages"`
+}
+
 type NamesList struct {
 	Subscriptions []string `json:"subscriptions"`
 }
@@ -153,7 +443,7 @@ func IsAuthorizationHeaderTypeSupported(authzType string) bool {
 // FindMetric returns the metric of a specific subscription
 func FindMetric(projectUUID string, name string, store stores.Store) (SubMetrics, error) {
 	result := SubMetrics{MsgNum: 0}
-	subs, _, _, err := store.QuerySubs(projectUUID, "", name, "", 0)
+	subs, _, _, err := store.QuerySubs(projectUUID, "", name, "", 0, nil, "")
 
 	// check if sub exists
 	if len(subs) == 0 {
@@ -168,6 +458,7 @@ func FindMetric(projectUUID string, name string, store stores.Store) (SubMetrics
 
 		result.MsgNum = item.MsgNum
 		result.TotalBytes = item.TotalBytes
+		result.AckedMsgs = item.AckedMsgs
 		result.LatestConsume = item.LatestConsume
 		result.ConsumeRate = item.ConsumeRate
 
@@ -180,10 +471,16 @@ func (sl *PaginatedSubscriptions) Empty() bool {
 	return len(sl.Subscriptions) <= 0
 }
 
-// GetAckFromJSON retrieves ack ids from json
+// GetAckFromJSON retrieves ack ids from json, after validating the input against the ack request schema
 func GetAckFromJSON(input []byte) (AckIDs, error) {
 	s := AckIDs{}
+	if err := validateRequestBody(ackIDsSchemaLoader, input); err != nil {
+		return s, err
+	}
 	err := json.Unmarshal([]byte(input), &s)
+	if err == nil && s.AckOffset != nil && len(s.IDs) > 0 {
+		return s, errors.New(AckIDsWithAckOffsetError)
+	}
 	return s, err
 }
 
@@ -194,11 +491,44 @@ func GetSetOffsetJSON(input []byte) (SetOffset, error) {
 	return s, err
 }
 
-// GetPullOptionsJSON retrieves pull information
+// GetReplayOptionsJSON retrieves replay information,
+// exactly one of offset or timestamp should be provided as the starting position
+func GetReplayOptionsJSON(input []byte) (ReplayOptions, error) {
+	s := ReplayOptions{}
+	err := json.Unmarshal([]byte(input), &s)
+	if err != nil {
+		return s, err
+	}
+	if s.Subscription == "" || (s.Offset == nil) == (s.Timestamp == "") {
+		return s, errors.New("wrong argument")
+	}
+	return s, nil
+}
+
+// GetCloneOptionsJSON retrieves clone information,
+// at most one of offset or timestamp may be provided as the starting position
+func GetCloneOptionsJSON(input []byte) (CloneOptions, error) {
+	s := CloneOptions{}
+	err := json.Unmarshal([]byte(input), &s)
+	if err != nil {
+		return s, err
+	}
+	if s.Subscription == "" || (s.Offset != nil && s.Timestamp != "") {
+		return s, errors.New("wrong argument")
+	}
+	return s, nil
+}
+
+// GetPullOptionsJSON retrieves pull information, after validating the input against the pull request schema
 func GetPullOptionsJSON(input []byte) (SubPullOptions, error) {
 	s := SubPullOptions{}
-	err := json.Unmarshal([]byte(input), &s)
-	return s, err
+	if err := validateRequestBody(pullOptionsSchemaLoader, input); err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal([]byte(input), &s); err != nil {
+		return s, err
+	}
+	return s, s.Validate()
 }
 
 // GetAckDeadlineFromJson retrieves ack deadline from json input
@@ -208,6 +538,27 @@ func GetAckDeadlineFromJSON(input []byte) (AckDeadline, error) {
 	return s, err
 }
 
+// GetMaxConcurrentPullsFromJSON retrieves the max concurrent pulls from json input
+func GetMaxConcurrentPullsFromJSON(input []byte) (MaxConcurrentPulls, error) {
+	s := MaxConcurrentPulls{}
+	err := json.Unmarshal([]byte(input), &s)
+	return s, err
+}
+
+// GetIsolationLevelFromJSON retrieves the isolation level from json input
+func GetIsolationLevelFromJSON(input []byte) (IsolationLevel, error) {
+	s := IsolationLevel{}
+	err := json.Unmarshal([]byte(input), &s)
+	return s, err
+}
+
+// GetDefaultMaxMessagesFromJSON retrieves the default max messages of pulls from json input
+func GetDefaultMaxMessagesFromJSON(input []byte) (SubDefaultMaxMessages, error) {
+	s := SubDefaultMaxMessages{}
+	err := json.Unmarshal([]byte(input), &s)
+	return s, err
+}
+
 // GetFromJSON retrieves Sub Info From Json
 func GetFromJSON(input []byte) (Subscription, error) {
 	s := Subscription{}
@@ -220,7 +571,7 @@ func New(projectUUID string, projectName string, name string, topic string) Subs
 	fsn := "/projects/" + projectName + "/subscriptions/" + name
 	ftn := "/projects/" + projectName + "/topics/" + topic
 	ps := PushConfig{}
-	s := Subscription{ProjectUUID: projectUUID, Name: name, Topic: topic, FullName: fsn, FullTopic: ftn, PushCfg: ps, Ack: 10}
+	s := Subscription{ProjectUUID: projectUUID, Name: name, Topic: topic, FullName: fsn, FullTopic: ftn, PushCfg: ps, Ack: 10, BrokerTopic: brokers.TopicName(projectUUID, topic)}
 	return s
 }
 
@@ -242,6 +593,60 @@ func (sub *Subscription) ExportJSON() (string, error) {
 	return string(output[:]), err
 }
 
+// Outstanding returns the messages of the subscription's current lease, the ones that have been pulled
+// but not yet acknowledged, with their ack ids signed with the given secret, if any.
+// Leases whose ack deadline has passed at the given time are not outstanding anymore,
+// since their messages are going to be redelivered
+func (sub *Subscription) Outstanding(projectName string, secret string, now time.Time) OutstandingMessages {
+
+	result := OutstandingMessages{Messages: []OutstandingMessage{}}
+
+	// no pull is pending acknowledgement
+	if sub.NextOffset == 0 || sub.PendingAck == "" || sub.NextOffset <= sub.Offset {
+		return result
+	}
+
+	leasedOn, err := timestamp.Parse(sub.PendingAck)
+	if err != nil {
+		return result
+	}
+
+	deadline := sub.PullAckDeadline(leasedOn)
+	if now.After(deadline) {
+		return result
+	}
+
+	for off := sub.Offset; off < sub.NextOffset; off++ {
+		result.Messages = append(result.Messages, OutstandingMessage{
+			MessageID:   strconv.FormatInt(off, 10),
+			AckID:       NewAckID(projectName, sub.Name, off).Signed(secret).String(),
+			PullTime:    timestamp.Format(leasedOn),
+			AckDeadline: timestamp.Format(deadline),
+		})
+	}
+
+	return result
+}
+
+// PullAckDeadline returns the time by which the messages pulled at the given time should be acknowledged,
+// after which the acks are rejected and the messages get redelivered
+func (sub *Subscription) PullAckDeadline(pullTime time.Time) time.Time {
+	return pullTime.Add(time.Duration(sub.Ack) * time.Second)
+}
+
+// ExportJSON exports the outstanding messages as a json string
+func (om *OutstandingMessages) ExportJSON() (string, error) {
+	output, err := json.MarshalIndent(om, "", "   ")
+	return string(output[:]), err
+}
+
+// MaskSecrets masks all the secret fields of the subscription's push configuration
+func (sub *Subscription) MaskSecrets() {
+	if sub.PushCfg.AuthorizationHeader.Value != "" {
+		sub.PushCfg.AuthorizationHeader.Value = SecretMask
+	}
+}
+
 // PushEndpointHost extracts the host:port of a push endpoint
 func (sub *Subscription) PushEndpointHost() string {
 
@@ -257,34 +662,160 @@ func (sub *Subscription) PushEndpointHost() string {
 	return u.Host
 }
 
+// onHost returns true if the host:port of an endpoint is on the given host. A host without a port matches
+// the endpoint on any port, while a host with a port matches only endpoints that declare the same one
+func onHost(endpointHost string, host string) bool {
+
+	if endpointHost == "" {
+		return false
+	}
+
+	u := url.URL{Host: endpointHost}
+	if h, port, err := net.SplitHostPort(host); err == nil {
+		return strings.EqualFold(u.Hostname(), h) && u.Port() == port
+	}
+
+	return strings.EqualFold(u.Hostname(), strings.Trim(host, "[]"))
+}
+
+// PushesToHost returns true if the push endpoint, or any of the fanout endpoints, of the subscription is on the given host
+func (sub *Subscription) PushesToHost(host string) bool {
+
+	if onHost(sub.PushEndpointHost(), host) {
+		return true
+	}
+
+	for _, endpoint := range sub.PushCfg.Fanout {
+		if u, err := url.Parse(endpoint); err == nil && onHost(u.Host, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FilterByEndpointHost keeps only the subscriptions of the page that push to the given host
+func (sl *PaginatedSubscriptions) FilterByEndpointHost(host string) {
+
+	matched := []Subscription{}
+	for _, sub := range sl.Subscriptions {
+		if sub.PushesToHost(host) {
+			matched = append(matched, sub)
+		}
+	}
+
+	sl.Subscriptions = matched
+	sl.TotalSize = int32(len(matched))
+}
+
+// MaskSecrets masks the secret fields of all the subscriptions in the page
+func (sl *PaginatedSubscriptions) MaskSecrets() {
+	for i := range sl.Subscriptions {
+		sl.Subscriptions[i].MaskSecrets()
+	}
+}
+
+// OrderByBacklog computes the backlog of each subscription, the number of messages between its offset
+// and the max offset of its topic, and sorts the subscriptions by it in descending order.
+// The max offset is retrieved from the broker once per topic.
+func (sl *PaginatedSubscriptions) OrderByBacklog(broker brokers.Broker) {
+
+	maxOffsets := make(map[string]int64)
+
+	for i := range sl.Subscriptions {
+		fullTopic := sl.Subscriptions[i].BrokerTopic
+		maxOff, ok := maxOffsets[fullTopic]
+		if !ok {
+			maxOff = broker.GetMaxOffset(fullTopic)
+			maxOffsets[fullTopic] = maxOff
+		}
+
+		backlog := maxOff - sl.Subscriptions[i].Offset
+		if backlog < 0 {
+			backlog = 0
+		}
+		sl.Subscriptions[i].Backlog = &backlog
+	}
+
+	sort.SliceStable(sl.Subscriptions, func(i, j int) bool {
+		return *sl.Subscriptions[i].Backlog > *sl.Subscriptions[j].Backlog
+	})
+}
+
 // ExportJSON exports whole sub List Structure as a json string
 func (sl *PaginatedSubscriptions) ExportJSON() (string, error) {
 	output, err := json.MarshalIndent(sl, "", "   ")
 	return string(output[:]), err
 }
 
-// VerifyPushEndpoint verifies the ownership of a push endpoint
-func VerifyPushEndpoint(sub Subscription, c *http.Client, store stores.Store) error {
+// EndpointVerifier verifies the ownership of the push endpoints that aren't reached over http, e.g. queues
+type EndpointVerifier interface {
+	VerifyEndpoint(ctx context.Context, endpoint string, vhash string) error
+}
+
+// VerifyPushEndpoint verifies the ownership of the push endpoint and of every fanout endpoint of the subscription.
+// Http endpoints have to respond with the verification hash, the rest are verified through the verifier.
+// The subscription is marked as verified only once all of them are
+func VerifyPushEndpoint(ctx context.Context, sub Subscription, c *http.Client, verifier EndpointVerifier, store stores.Store) error {
 
 	// extract the push endpoint host
 	if sub.PushCfg.Pend == "" {
 		return errors.New("Could not retrieve push endpoint host")
 	}
 
-	u1 := &url.URL{}
-	u1, err := url.Parse(sub.PushCfg.Pend)
+	// endpoints on the same host share the verification hash they serve
+	verified := map[string]bool{}
+	for _, endpoint := range sub.PushCfg.Endpoints() {
+
+		u1, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+
+		if u1.Scheme != "http" && u1.Scheme != "https" {
+			if verifier == nil {
+				return errors.New("Unsupported push endpoint scheme")
+			}
+			if err := verifier.VerifyEndpoint(ctx, endpoint, sub.PushCfg.VerificationHash); err != nil {
+				log.Errorf("failed to verify push endpoint %v for subscription %v, %v", endpoint, sub.FullName, err.Error())
+				return err
+			}
+			continue
+		}
+
+		if verified[u1.Scheme+"://"+u1.Host] {
+			continue
+		}
+
+		if err := verifyEndpointHost(ctx, sub, u1, c); err != nil {
+			return err
+		}
+		verified[u1.Scheme+"://"+u1.Host] = true
+	}
+
+	// update the push config with verified true
+	err := ModSubPush(sub.ProjectUUID, sub.Name, sub.PushCfg.Pend, sub.PushCfg.AuthorizationHeader.Type,
+		sub.PushCfg.AuthorizationHeader.Value, sub.PushCfg.MaxMessages, sub.PushCfg.RetPol.PolicyType,
+		sub.PushCfg.RetPol.Period, sub.PushCfg.VerificationHash, true, sub.PushCfg.Fanout, sub.PushCfg.MaxConcurrentDeliveries,
+		sub.PushCfg.MaxRetryDuration, sub.PushCfg.MaxRetries, store)
 	if err != nil {
 		return err
 	}
 
+	return nil
+}
+
+// verifyEndpointHost retrieves the verification hash that the host of an endpoint serves and compares it with the subscription's one
+func verifyEndpointHost(ctx context.Context, sub Subscription, endpoint *url.URL, c *http.Client) error {
+
 	// create a new url that will be used to retrieve the verification hash
 	u := url.URL{
-		Scheme: u1.Scheme,
-		Host:   u1.Host,
+		Scheme: endpoint.Scheme,
+		Host:   endpoint.Host,
 		Path:   "ams_verification_hash",
 	}
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return err
 	}
@@ -295,29 +826,21 @@ func VerifyPushEndpoint(sub Subscription, c *http.Client, store stores.Store) er
 	}
 
 	if resp.StatusCode != 200 {
-		log.Errorf("failed to verify push endpoint for subscription %v. Expected status response %v but got %v",
-			sub.FullName, http.StatusOK, resp.StatusCode)
+		log.Errorf("failed to verify push endpoint %v for subscription %v. Expected status response %v but got %v",
+			endpoint.String(), sub.FullName, http.StatusOK, resp.StatusCode)
 		return errors.New("Wrong response status code")
-	} else {
-		// read the response
-		buf := bytes.Buffer{}
-		buf.ReadFrom(resp.Body)
+	}
 
-		defer resp.Body.Close()
+	// read the response
+	buf := bytes.Buffer{}
+	buf.ReadFrom(resp.Body)
 
-		if sub.PushCfg.VerificationHash != buf.String() {
-			log.Errorf("failed to verify push endpoint for subscription %v. Expected verification hash %v but got %v",
-				sub.FullName, sub.PushCfg.VerificationHash, buf.String())
-			return errors.New("Wrong verification hash")
-		}
-	}
+	defer resp.Body.Close()
 
-	// update the push config with verified true
-	err = ModSubPush(sub.ProjectUUID, sub.Name, sub.PushCfg.Pend, sub.PushCfg.AuthorizationHeader.Type,
-		sub.PushCfg.AuthorizationHeader.Value, sub.PushCfg.MaxMessages, sub.PushCfg.RetPol.PolicyType,
-		sub.PushCfg.RetPol.Period, sub.PushCfg.VerificationHash, true, store)
-	if err != nil {
-		return err
+	if sub.PushCfg.VerificationHash != buf.String() {
+		log.Errorf("failed to verify push endpoint %v for subscription %v. Expected verification hash %v but got %v",
+			endpoint.String(), sub.FullName, sub.PushCfg.VerificationHash, buf.String())
+		return errors.New("Wrong verification hash")
 	}
 
 	return nil
@@ -325,6 +848,12 @@ func VerifyPushEndpoint(sub Subscription, c *http.Client, store stores.Store) er
 
 // Find searches the store for all subscriptions of a given project or a specific one
 func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store stores.Store) (PaginatedSubscriptions, error) {
+	return FindByLabels(projectUUID, userUUID, name, pageToken, pageSize, nil, "", store)
+}
+
+// FindByLabels searches the store for the subscriptions of a given project, or a specific one, that fulfill the label selector
+// and, when namePrefix is not empty, whose name starts with it
+func FindByLabels(projectUUID, userUUID, name, pageToken string, pageSize int32, selector labels.Selector, namePrefix string, store stores.Store) (PaginatedSubscriptions, error) {
 
 	var err error
 	var qSubs []stores.QSub
@@ -340,7 +869,7 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 		return result, err
 	}
 
-	if qSubs, totalSize, nextPageToken, err = store.QuerySubs(projectUUID, userUUID, name, string(pageTokenBytes), pageSize); err != nil {
+	if qSubs, totalSize, nextPageToken, err = store.QuerySubs(projectUUID, userUUID, name, string(pageTokenBytes), pageSize, selector, namePrefix); err != nil {
 		return result, err
 	}
 
@@ -350,12 +879,17 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 		return result, errors.New("invalid project")
 	}
 
+	brokerTopics := brokerTopicResolver(store)
+
 	for _, item := range qSubs {
 		curSub := New(item.ProjectUUID, projectName, item.Name, item.Topic)
+		curSub.BrokerTopic = brokerTopics(item.ProjectUUID, item.Topic)
 		curSub.Offset = item.Offset
 		curSub.NextOffset = item.NextOffset
+		curSub.PendingAck = item.PendingAck
+		curSub.Version = item.Version
 		curSub.Ack = item.Ack
-		curSub.CreatedOn = item.CreatedOn.Format("2006-01-02T15:04:05Z")
+		curSub.CreatedOn = timestamp.Format(item.CreatedOn)
 		if item.PushEndpoint != "" {
 			rp := RetryPolicy{
 				PolicyType: item.RetPolicy,
@@ -382,10 +916,38 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 				RetPol:              rp,
 				VerificationHash:    item.VerificationHash,
 				Verified:            item.Verified,
+				Fanout:              item.FanoutEndpoints,
+			}
+			curSub.PushCfg.MaxConcurrentDeliveries = curSub.PushCfg.EffectiveMaxConcurrentDeliveries()
+			if item.MaxConcurrentDeliveries > 0 {
+				curSub.PushCfg.MaxConcurrentDeliveries = item.MaxConcurrentDeliveries
 			}
+			curSub.PushCfg.MaxRetryDuration = item.MaxRetryDuration
+			curSub.PushCfg.MaxRetries = item.MaxRetries
+		}
+		if item.Transform != nil {
+			curSub.Transform = &Transform{Type: item.Transform.Type, Field: item.Transform.Field}
+		}
+		if item.NewMessagesOnly {
+			curSub.NewMessagesOnly = true
+			curSub.NotBefore = item.CreatedOn
 		}
+		if len(item.Labels) > 0 {
+			curSub.Labels = item.Labels
+		}
+		curSub.Deduplicate = item.Deduplicate
+		curSub.MaxConcurrentPulls = item.MaxConcurrentPulls
+		curSub.Prioritize = item.Prioritize
 		curSub.LatestConsume = item.LatestConsume
 		curSub.ConsumeRate = item.ConsumeRate
+		curSub.LastProgress = item.LastProgress
+		curSub.AtMostOnce = item.AtMostOnce
+		curSub.IsolationLevel = item.IsolationLevel
+		curSub.DefaultMaxMessages = item.DefaultMaxMessages
+		if item.SamplingRate > 0 {
+			rate := item.SamplingRate
+			curSub.SamplingRate = &rate
+		}
 		result.Subscriptions = append(result.Subscriptions, curSub)
 	}
 
@@ -395,6 +957,23 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 	return result, err
 }
 
+// brokerTopicResolver returns a function that looks up the broker topic of a project's topic,
+// querying the store once per topic
+func brokerTopicResolver(store stores.Store) func(projectUUID string, topic string) string {
+
+	resolved := make(map[string]string)
+
+	return func(projectUUID string, topic string) string {
+		key := projectUUID + "/" + topic
+		if brokerTopic, ok := resolved[key]; ok {
+			return brokerTopic
+		}
+		brokerTopic := topics.BrokerTopic(projectUUID, topic, store)
+		resolved[key] = brokerTopic
+		return brokerTopic
+	}
+}
+
 // FindByTopic retrieves all subscriptions associated with the given topic
 func FindByTopic(projectUUID string, topicName string, store stores.Store) (NamesList, error) {
 
@@ -418,11 +997,15 @@ func FindByTopic(projectUUID string, topicName string, store stores.Store) (Name
 func LoadPushSubs(store stores.Store) PaginatedSubscriptions {
 	result := PaginatedSubscriptions{Subscriptions: []Subscription{}}
 	subs := store.QueryPushSubs()
+	brokerTopics := brokerTopicResolver(store)
 	for _, item := range subs {
 		projectName := projects.GetNameByUUID(item.ProjectUUID, store)
 		curSub := New(item.ProjectUUID, projectName, item.Name, item.Topic)
+		curSub.BrokerTopic = brokerTopics(item.ProjectUUID, item.Topic)
 		curSub.Offset = item.Offset
 		curSub.NextOffset = item.NextOffset
+		curSub.PendingAck = item.PendingAck
+		curSub.Version = item.Version
 		curSub.Ack = item.Ack
 		rp := RetryPolicy{item.RetPolicy, item.RetPeriod}
 		curSub.PushCfg = PushConfig{Pend: item.PushEndpoint, RetPol: rp}
@@ -432,22 +1015,75 @@ func LoadPushSubs(store stores.Store) PaginatedSubscriptions {
 	return result
 }
 
-// CreateSub creates a new subscription
-func CreateSub(projectUUID string, name string, topic string, push string, offset int64, maxMessages int64, authzType string, authzHeader string, ack int, retPolicy string, retPeriod int, vhash string, verified bool, createdOn time.Time, store stores.Store) (Subscription, error) {
+// Settings are the optional settings that a subscription is created with
+type Settings struct {
+	Fanout                  []string
+	MaxConcurrentDeliveries int
+	MaxRetryDuration        int
+	MaxRetries              int
+	Transform               *Transform
+	NewMessagesOnly         bool
+	Deduplicate             bool
+	Prioritize              bool
+	AtMostOnce              bool
+	IsolationLevel          string
+	DefaultMaxMessages      int
+	// SamplingRate is the fraction of the messages that the pulls deliver, zero meaning all of them
+	SamplingRate       float64
+	MaxConcurrentPulls int
+	Labels             map[string]string
+}
+
+// CreateSub creates a new subscription, storing it along with its settings at once
+func CreateSub(projectUUID string, name string, topic string, push string, offset int64, maxMessages int64, authzType string, authzHeader string, ack int, retPolicy string, retPeriod int, vhash string, verified bool, createdOn time.Time, settings Settings, store stores.Store) (Subscription, error) {
 
 	if HasSub(projectUUID, name, store) {
 		return Subscription{}, errors.New("exists")
 	}
 
 	if ack == 0 {
-		ack = 10
+		ack = DefaultAckDeadline
 	}
 
 	if retPolicy == SlowStartRetryPolicyType {
 		retPeriod = 0
 	}
 
-	err := store.InsertSub(projectUUID, name, topic, offset, maxMessages, authzType, authzHeader, ack, push, retPolicy, retPeriod, vhash, verified, createdOn)
+	var qTransform *stores.QTransform
+	if settings.Transform != nil {
+		qTransform = &stores.QTransform{Type: settings.Transform.Type, Field: settings.Transform.Field}
+	}
+
+	err := store.InsertSub(stores.QSub{
+		ProjectUUID:             projectUUID,
+		Name:                    name,
+		Topic:                   topic,
+		Offset:                  offset,
+		Ack:                     ack,
+		MaxMessages:             maxMessages,
+		AuthorizationType:       authzType,
+		AuthorizationHeader:     authzHeader,
+		PushEndpoint:            push,
+		RetPolicy:               retPolicy,
+		RetPeriod:               retPeriod,
+		VerificationHash:        vhash,
+		Verified:                verified,
+		CreatedOn:               createdOn,
+		FanoutEndpoints:         settings.Fanout,
+		MaxConcurrentDeliveries: settings.MaxConcurrentDeliveries,
+		MaxRetryDuration:        settings.MaxRetryDuration,
+		MaxRetries:              settings.MaxRetries,
+		Transform:               qTransform,
+		NewMessagesOnly:         settings.NewMessagesOnly,
+		Deduplicate:             settings.Deduplicate,
+		Prioritize:              settings.Prioritize,
+		AtMostOnce:              settings.AtMostOnce,
+		IsolationLevel:          settings.IsolationLevel,
+		DefaultMaxMessages:      settings.DefaultMaxMessages,
+		SamplingRate:            settings.SamplingRate,
+		MaxConcurrentPulls:      settings.MaxConcurrentPulls,
+		Labels:                  settings.Labels,
+	})
 	if err != nil {
 		return Subscription{}, errors.New("backend error")
 	}
@@ -474,8 +1110,8 @@ func ModAck(projectUUID string, name string, ack int, store stores.Store) error
 	return store.ModAck(projectUUID, name, ack)
 }
 
-// ModSubPush updates the subscription push config
-func ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, retPolicy string, retPeriod int, vhash string, verified bool, store stores.Store) error {
+// ModSubPush updates the subscription push config, the fanout endpoints and the delivery limits included
+func ModSubPush(projectUUID string, name string, push string, authzType string, authzValue string, maxMessages int64, retPolicy string, retPeriod int, vhash string, verified bool, fanout []string, maxConcurrentDeliveries int, maxRetryDuration int, maxRetries int, store stores.Store) error {
 
 	if HasSub(projectUUID, name, store) == false {
 		return errors.New("not found")
@@ -485,16 +1121,104 @@ func ModSubPush(projectUUID string, name string, push string, authzType string,
 		retPeriod = 0
 	}
 
-	return store.ModSubPush(projectUUID, name, push, authzType, authzValue, maxMessages, retPolicy, retPeriod, vhash, verified)
+	return store.ModSubPush(projectUUID, name, push, authzType, authzValue, maxMessages, retPolicy, retPeriod, vhash, verified, fanout, maxConcurrentDeliveries, maxRetryDuration, maxRetries)
+}
+
+// ResetAckedMsgs sets the acknowledged messages counter of a subscription back to zero
+func ResetAckedMsgs(projectUUID string, name string, store stores.Store) error {
+
+	if HasSub(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ResetSubAckedMsgs(projectUUID, name)
+}
+
+// ModSubIsolationLevel updates the broker isolation level a subscription consumes with
+func ModSubIsolationLevel(projectUUID string, name string, level string, store stores.Store) error {
+
+	if HasSub(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModSubIsolationLevel(projectUUID, name, level)
+}
+
+// ModSubMaxConcurrentPulls updates the number of pulls allowed to proceed at once on a subscription
+func ModSubMaxConcurrentPulls(projectUUID string, name string, max int, store stores.Store) error {
+
+	if HasSub(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModSubMaxConcurrentPulls(projectUUID, name, max)
+}
+
+// ModSubDefaultMaxMessages updates the number of messages returned by the pulls of a subscription that don't declare maxMessages
+func ModSubDefaultMaxMessages(projectUUID string, name string, max int, store stores.Store) error {
+
+	if HasSub(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModSubDefaultMaxMessages(projectUUID, name, max)
+}
+
+// ModSubLabels replaces the labels of a subscription
+func ModSubLabels(projectUUID string, name string, labels map[string]string, store stores.Store) error {
+
+	if HasSub(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.ModSubLabels(projectUUID, name, labels)
+}
+
+// Admits returns false for messages that the subscription shouldn't deliver because they were published before its creation.
+// Messages backfilled with a publish time of their own are judged by the time the service received them.
+// Messages without a valid publish time are always admitted, since there is no way to tell when they were published
+func (sub *Subscription) Admits(msg messages.Message) bool {
+
+	if sub.NotBefore.IsZero() {
+		return true
+	}
+
+	received := msg.PubTime
+	if msg.IngestTime != "" {
+		received = msg.IngestTime
+	}
+
+	pubTime, err := time.Parse(time.RFC3339Nano, received)
+	if err != nil {
+		return true
+	}
+
+	return !pubTime.Before(sub.NotBefore)
 }
 
-// RemoveSub removes an existing subscription
+// Samples returns false for the messages that are left out of the subscription's sample. The decision hashes the message's id,
+// so that a message is either always or never part of the sample, however many times it gets read
+func (sub *Subscription) Samples(offset int64) bool {
+
+	if !sub.Sampled() {
+		return true
+	}
+
+	sum := sha256.Sum256([]byte(strconv.FormatInt(offset, 10)))
+
+	// the top 53 bits of the hash map evenly onto [0,1)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < *sub.SamplingRate
+}
+
+// RemoveSub removes an existing subscription, along with the cached authorization decisions about it,
+// so that a subscription created later under the same name doesn't inherit them
 func RemoveSub(projectUUID string, name string, store stores.Store) error {
 
 	if HasSub(projectUUID, name, store) == false {
 		return errors.New("not found")
 	}
 
+	defer auth.InvalidateACL(projectUUID, "subscriptions", name)
 	return store.RemoveSub(projectUUID, name)
 }
 
@@ -523,6 +1247,136 @@ func ExtractFullTopicRef(fTopicRef string) (string, string, error) {
 
 }
 
+const (
+	// AckIDVersionLegacy is the unversioned ack id format projects/{project}/subscriptions/{subscription}:{offset}
+	// It is still accepted when acknowledging messages but it is deprecated and no longer generated
+	AckIDVersionLegacy = 0
+	// AckIDVersion1 is the ack id format v1/projects/{project}/subscriptions/{subscription}:{offset}
+	AckIDVersion1 = 1
+	// CurrentAckIDVersion is the version used when generating new ack ids
+	CurrentAckIDVersion = AckIDVersion1
+)
+
+// AckID holds the structured information that is encoded inside an ack id
+type AckID struct {
+	Version      int
+	Project      string
+	Subscription string
+	Offset       int64
+	// Signature is the hmac of the ack id, appended to it as .{signature} when the service signs its ack ids
+	Signature string
+}
+
+// NewAckID returns an ack id of the current version for the given project, subscription and offset
+func NewAckID(project string, sub string, offset int64) AckID {
+	return AckID{
+		Version:      CurrentAckIDVersion,
+		Project:      project,
+		Subscription: sub,
+		Offset:       offset,
+	}
+}
+
+// String formats the ack id according to its version
+func (a AckID) String() string {
+
+	ackID := a.unsigned()
+
+	if a.Signature != "" {
+		return ackID + "." + a.Signature
+	}
+
+	return ackID
+}
+
+// unsigned formats the ack id without its signature
+func (a AckID) unsigned() string {
+
+	ackID := fmt.Sprintf("projects/%v/subscriptions/%v:%v", a.Project, a.Subscription, a.Offset)
+
+	if a.Version == AckIDVersionLegacy {
+		return ackID
+	}
+
+	return fmt.Sprintf("v%v/%v", a.Version, ackID)
+}
+
+// sign computes the hmac of the unsigned ack id with the given secret
+func (a AckID) sign(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(a.unsigned()))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Signed returns the ack id signed with the given secret, an empty secret leaves it unsigned
+func (a AckID) Signed(secret string) AckID {
+	a.Signature = ""
+	if secret != "" {
+		a.Signature = a.sign(secret)
+	}
+	return a
+}
+
+// Verify checks that the ack id carries the signature the given secret produces for it
+func (a AckID) Verify(secret string) bool {
+	return a.Signature != "" && hmac.Equal([]byte(a.Signature), []byte(a.sign(secret)))
+}
+
+// BelongsTo checks whether or not the ack id refers to the given project and subscription
+func (a AckID) BelongsTo(project string, sub string) bool {
+	return a.Project == project && a.Subscription == sub
+}
+
+// ParseAckID parses and validates an ack id string of any supported version
+func ParseAckID(ackID string) (AckID, error) {
+
+	a := AckID{}
+
+	tokens := strings.Split(ackID, "/")
+
+	switch len(tokens) {
+	case 4:
+		a.Version = AckIDVersionLegacy
+	case 5:
+		if tokens[0] != fmt.Sprintf("v%v", AckIDVersion1) {
+			return AckID{}, errors.New("invalid argument")
+		}
+		a.Version = AckIDVersion1
+		tokens = tokens[1:]
+	default:
+		return AckID{}, errors.New("invalid argument")
+	}
+
+	if tokens[0] != "projects" || tokens[1] == "" || tokens[2] != "subscriptions" {
+		return AckID{}, errors.New("invalid argument")
+	}
+
+	subTokens := strings.Split(tokens[3], ":")
+	if len(subTokens) != 2 || subTokens[0] == "" {
+		return AckID{}, errors.New("invalid argument")
+	}
+
+	// the offset may be followed by the signature of the ack id
+	offTokens := strings.SplitN(subTokens[1], ".", 2)
+	if len(offTokens) == 2 {
+		if offTokens[1] == "" {
+			return AckID{}, errors.New("invalid argument")
+		}
+		a.Signature = offTokens[1]
+	}
+
+	offset, err := strconv.ParseInt(offTokens[0], 10, 64)
+	if err != nil || offset < 0 {
+		return AckID{}, errors.New("invalid argument")
+	}
+
+	a.Project = tokens[1]
+	a.Subscription = subTokens[0]
+	a.Offset = offset
+
+	return a, nil
+}
+
 // GetMaxAckID gets a list of ack ids and selects the maximum one
 func GetMaxAckID(ackIDs []string) (string, error) {
 	var max int64
@@ -550,13 +1404,10 @@ func GetMaxAckID(ackIDs []string) (string, error) {
 // GetOffsetFromAckID extracts an offset from an ackID
 func GetOffsetFromAckID(ackID string) (int64, error) {
 
-	var num int64
-	tokens := strings.Split(ackID, "/")
-	if len(tokens) != 4 {
-		return num, errors.New("invalid argument")
+	a, err := ParseAckID(ackID)
+	if err != nil {
+		return 0, err
 	}
-	subTokens := strings.Split(tokens[3], ":")
-	num, err := strconv.ParseInt(subTokens[1], 10, 64)
 
-	return num, err
+	return a.Offset, nil
 }
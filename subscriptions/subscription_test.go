@@ -1,14 +1,26 @@
 package subscriptions
 
 import (
+	"context"
+	b64 "encoding/base64"
 	"errors"
+	"io"
 	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"testing"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	"github.com/stretchr/testify/suite"
 	"net/http"
 	"strings"
@@ -200,12 +212,13 @@ func (suite *SubTestSuite) TestGetSubsByProject() {
 	rp := RetryPolicy{"linear", 300}
 	authCFG := AuthorizationHeader{"autogen", "auth-header-1"}
 	expSub4.PushCfg = PushConfig{
-		Pend:                "endpoint.foo",
-		AuthorizationHeader: authCFG,
-		RetPol:              rp,
-		VerificationHash:    "push-id-1",
-		Verified:            true,
-		MaxMessages:         1,
+		Pend:                    "endpoint.foo",
+		AuthorizationHeader:     authCFG,
+		RetPol:                  rp,
+		VerificationHash:        "push-id-1",
+		Verified:                true,
+		MaxMessages:             1,
+		MaxConcurrentDeliveries: 1,
 	}
 	expSub4.LatestConsume = time.Date(0, 0, 0, 0, 0, 0, 0, time.Local)
 	expSub4.ConsumeRate = 0
@@ -303,12 +316,13 @@ func (suite *SubTestSuite) TestLoadFromCfg() {
 	expSub4.CreatedOn = "2020-11-22T00:00:00Z"
 	rp := RetryPolicy{"linear", 300}
 	expSub4.PushCfg = PushConfig{
-		Pend:                "endpoint.foo",
-		AuthorizationHeader: authCFG,
-		RetPol:              rp,
-		VerificationHash:    "push-id-1",
-		Verified:            true,
-		MaxMessages:         1,
+		Pend:                    "endpoint.foo",
+		AuthorizationHeader:     authCFG,
+		RetPol:                  rp,
+		VerificationHash:        "push-id-1",
+		Verified:                true,
+		MaxMessages:             1,
+		MaxConcurrentDeliveries: 1,
 	}
 	expSub4.LatestConsume = time.Date(0, 0, 0, 0, 0, 0, 0, time.Local)
 	expSub4.ConsumeRate = 0
@@ -347,6 +361,19 @@ func (suite *SubTestSuite) TestRemoveSubStore() {
 	suite.Equal(false, HasSub("ARGO", "sub1", store))
 }
 
+func (suite *SubTestSuite) TestRemoveSubInvalidatesACL() {
+
+	store := stores.NewMockStore("", "")
+	auth.SetACLCacheTTL(time.Minute)
+	defer auth.SetACLCacheTTL(0)
+
+	// the decisions cached for the removed subscription don't survive it
+	suite.True(auth.PerResource("argo_uuid", "subscriptions", "sub1", "uuid1", store))
+	store.ModACL("argo_uuid", "subscriptions", "sub1", []string{})
+	suite.Nil(RemoveSub("argo_uuid", "sub1", store))
+	suite.False(auth.PerResource("argo_uuid", "subscriptions", "sub1", "uuid1", store))
+}
+
 func (suite *SubTestSuite) TestCreateSubStore() {
 
 	APIcfg := config.NewAPICfg()
@@ -354,16 +381,42 @@ func (suite *SubTestSuite) TestCreateSubStore() {
 
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
-	sub, err := CreateSub("argo_uuid", "sub1", "topic1", "", 0, 0, "", "", 0, "linear", 300, "", true, time.Date(2019, 7, 7, 0, 0, 0, 0, time.Local), store)
+	sub, err := CreateSub("argo_uuid", "sub1", "topic1", "", 0, 0, "", "", 0, "linear", 300, "", true, time.Date(2019, 7, 7, 0, 0, 0, 0, time.Local), Settings{}, store)
 	suite.Equal(Subscription{}, sub)
 	suite.Equal("exists", err.Error())
 
-	sub2, err2 := CreateSub("argo_uuid", "subNew", "topicNew", "", 0, 0, "", "", 0, "linear", 300, "", true, time.Date(2019, 7, 7, 0, 0, 0, 0, time.Local), store)
+	sub2, err2 := CreateSub("argo_uuid", "subNew", "topicNew", "", 0, 0, "", "", 0, "linear", 300, "", true, time.Date(2019, 7, 7, 0, 0, 0, 0, time.Local), Settings{}, store)
 	expSub := New("argo_uuid", "ARGO", "subNew", "topicNew")
 	expSub.CreatedOn = "2019-07-07T00:00:00Z"
 	suite.Equal(expSub, sub2)
 	suite.Equal(nil, err2)
 
+	// the settings are stored along with the subscription
+	settings := Settings{
+		Transform:          &Transform{Type: ExtractFieldTransformType, Field: "body"},
+		NewMessagesOnly:    true,
+		Deduplicate:        true,
+		Prioritize:         true,
+		AtMostOnce:         true,
+		IsolationLevel:     "read_uncommitted",
+		DefaultMaxMessages: 20,
+		SamplingRate:       0.5,
+		MaxConcurrentPulls: 2,
+		Labels:             map[string]string{"team": "sre"},
+	}
+	sub3, err3 := CreateSub("argo_uuid", "subSettings", "topicNew", "", 0, 0, "", "", 0, "linear", 300, "", true, time.Date(2019, 7, 7, 0, 0, 0, 0, time.Local), settings, store)
+	suite.Nil(err3)
+	suite.Equal(&Transform{Type: ExtractFieldTransformType, Field: "body"}, sub3.Transform)
+	suite.True(sub3.NewMessagesOnly)
+	suite.True(sub3.Deduplicate)
+	suite.True(sub3.Prioritize)
+	suite.True(sub3.AtMostOnce)
+	suite.Equal("read_uncommitted", sub3.IsolationLevel)
+	suite.Equal(20, sub3.DefaultMaxMessages)
+	suite.Equal(0.5, *sub3.SamplingRate)
+	suite.Equal(2, sub3.MaxConcurrentPulls)
+	suite.Equal(map[string]string{"team": "sre"}, sub3.Labels)
+
 }
 
 func (suite *SubTestSuite) TestModAck() {
@@ -394,7 +447,8 @@ func (suite *SubTestSuite) TestModSubPush() {
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
 	// modify push config
-	err1 := ModSubPush("argo_uuid", "sub1", "example.com", "autogen", "auth-h", 2, "linear", 400, "hash-1", true, store)
+	err1 := ModSubPush("argo_uuid", "sub1", "example.com", "autogen", "auth-h", 2, "linear", 400, "hash-1", true,
+		[]string{"https://fan.example.com"}, 4, 60, 3, store)
 
 	suite.Nil(err1)
 
@@ -405,9 +459,13 @@ func (suite *SubTestSuite) TestModSubPush() {
 	suite.Equal(400, sub1.RetPeriod)
 	suite.Equal("hash-1", sub1.VerificationHash)
 	suite.True(sub1.Verified)
+	suite.Equal([]string{"https://fan.example.com"}, sub1.FanoutEndpoints)
+	suite.Equal(4, sub1.MaxConcurrentDeliveries)
+	suite.Equal(60, sub1.MaxRetryDuration)
+	suite.Equal(3, sub1.MaxRetries)
 
 	// test error case
-	err2 := ModSubPush("argo_uuid", "unknown", "", "", "", 0, "", 0, "", false, store)
+	err2 := ModSubPush("argo_uuid", "unknown", "", "", "", 0, "", 0, "", false, nil, 0, 0, 0, store)
 	suite.Equal("not found", err2.Error())
 }
 
@@ -444,6 +502,34 @@ func (suite *SubTestSuite) TestPushEndpointHost() {
 	suite.Equal("example.com:8084", u1)
 }
 
+func (suite *SubTestSuite) TestPushesToHost() {
+
+	sub := Subscription{
+		PushCfg: PushConfig{
+			Pend:   "https://Example.com:8084/receive_here",
+			Fanout: []string{"https://archive.example.org/receive_here"},
+		},
+	}
+
+	// hosts match regardless of their case and of the endpoint's port, unless they declare one
+	suite.True(sub.PushesToHost("example.com"))
+	suite.True(sub.PushesToHost("EXAMPLE.com:8084"))
+	suite.False(sub.PushesToHost("example.com:443"))
+	suite.False(sub.PushesToHost("sub.example.com"))
+	suite.False(sub.PushesToHost("example"))
+
+	// the fanout endpoints are matched too
+	suite.True(sub.PushesToHost("archive.example.org"))
+
+	pull := Subscription{}
+	suite.False(pull.PushesToHost("example.com"))
+
+	sl := PaginatedSubscriptions{Subscriptions: []Subscription{sub, {}}, TotalSize: 2, NextPageToken: "token"}
+	sl.FilterByEndpointHost("archive.example.org")
+	suite.Equal(1, len(sl.Subscriptions))
+	suite.Equal(int32(1), sl.TotalSize)
+}
+
 func (suite *SubTestSuite) TestVerifyPushEndpoint() {
 
 	str := stores.NewMockStore("", "")
@@ -473,7 +559,7 @@ func (suite *SubTestSuite) TestVerifyPushEndpoint() {
 		Transport: new(MockPushRoundTripper),
 	}
 
-	e1 := VerifyPushEndpoint(s1, c1, str)
+	e1 := VerifyPushEndpoint(context.Background(), s1, c1, nil, str)
 
 	qs1, _ := str.QueryOneSub("argo_uuid", "push-sub-v1")
 
@@ -492,7 +578,7 @@ func (suite *SubTestSuite) TestVerifyPushEndpoint() {
 		Transport: new(MockPushRoundTripper),
 	}
 
-	e2 := VerifyPushEndpoint(s2, c2, nil)
+	e2 := VerifyPushEndpoint(context.Background(), s2, c2, nil, nil)
 
 	suite.Equal("Wrong response status code", e2.Error())
 
@@ -508,9 +594,81 @@ func (suite *SubTestSuite) TestVerifyPushEndpoint() {
 		Transport: new(MockPushRoundTripper),
 	}
 
-	e3 := VerifyPushEndpoint(s3, c3, nil)
+	e3 := VerifyPushEndpoint(context.Background(), s3, c3, nil, nil)
 
 	suite.Equal("Wrong verification hash", e3.Error())
+
+	// every fanout endpoint has to be verified along with the push endpoint
+	s4 := Subscription{
+		Name:        "push-sub-v1",
+		ProjectUUID: "argo_uuid",
+		PushCfg: PushConfig{
+			Pend:             "https://example.com/receive_here",
+			VerificationHash: "vhash-1",
+			Fanout:           []string{"https://example.com/fanout", "https://example_mismatch.com/fanout"},
+		},
+	}
+	str.SubList[len(str.SubList)-1].Verified = false
+
+	e4 := VerifyPushEndpoint(context.Background(), s4, c1, nil, str)
+	qs4, _ := str.QueryOneSub("argo_uuid", "push-sub-v1")
+
+	suite.Equal("Wrong verification hash", e4.Error())
+	suite.False(qs4.Verified)
+
+	s4.PushCfg.Fanout = []string{"https://example.com/fanout"}
+	e5 := VerifyPushEndpoint(context.Background(), s4, c1, nil, str)
+	qs5, _ := str.QueryOneSub("argo_uuid", "push-sub-v1")
+
+	suite.Nil(e5)
+	suite.True(qs5.Verified)
+	suite.Equal([]string{"https://example.com/fanout"}, qs5.FanoutEndpoints)
+}
+
+func (suite *SubTestSuite) TestPushConfigEndpointsVerification() {
+	pc := PushConfig{Pend: "https://example.com/push", Fanout: []string{"https://fan.example.com/push"}, Verified: true}
+
+	suite.True(pc.IsVerified("https://example.com/push"))
+	suite.True(pc.IsVerified("https://fan.example.com/push"))
+	suite.False(pc.IsVerified("https://other.example.com/push"))
+
+	suite.True(pc.SameEndpoints(PushConfig{Pend: "https://example.com/push", Fanout: []string{"https://fan.example.com/push"}}))
+	suite.False(pc.SameEndpoints(PushConfig{Pend: "https://example.com/push"}))
+	suite.False(pc.SameEndpoints(PushConfig{Pend: "https://example.com/push", Fanout: []string{"https://other.example.com/push"}}))
+
+	pc.Verified = false
+	suite.False(pc.IsVerified("https://example.com/push"))
+}
+
+func (suite *SubTestSuite) TestIsValidPushEndpoint() {
+	suite.True(IsValidPushEndpoint("https://www.example.com/push"))
+	suite.False(IsValidPushEndpoint("http://www.example.com/push"))
+	suite.False(IsValidPushEndpoint("ftp://www.example.com/push"))
+
+	// other schemes are valid once allowed, only towards their hosts
+	suite.False(IsValidPushEndpoint("queue://queues.example.com/ams"))
+	AllowPushScheme("QUEUE", regexp.MustCompile(`^queues\.example\.com$`))
+	suite.True(IsValidPushEndpoint("queue://queues.example.com/ams"))
+	suite.False(IsValidPushEndpoint("queue://queues.example.org/ams"))
+	suite.False(IsValidPushEndpoint("queue:///ams"))
+	suite.True(ValidFanout("https://www.example.com/push", []string{"queue://queues.example.com/ams"}))
+}
+
+func (suite *SubTestSuite) TestMaxRetries() {
+	pc := PushConfig{Pend: "https://example.com/push"}
+	suite.True(pc.ValidMaxRetries())
+	suite.Equal(0, pc.EffectiveMaxRetries())
+
+	pc.Fanout = []string{"https://fan.example.com/push"}
+	suite.Equal(DefaultFanoutMaxRetries, pc.EffectiveMaxRetries())
+
+	pc.MaxRetries = 3
+	suite.Equal(3, pc.EffectiveMaxRetries())
+
+	pc.MaxRetries = MaxRetriesLimit + 1
+	suite.False(pc.ValidMaxRetries())
+	pc.MaxRetries = -1
+	suite.False(pc.ValidMaxRetries())
 }
 
 func (suite *SubTestSuite) TestExportJson() {
@@ -525,6 +683,7 @@ func (suite *SubTestSuite) TestExportJson() {
 	expJSON := `{
    "name": "/projects/ARGO/subscriptions/sub1",
    "topic": "/projects/ARGO/topics/topic1",
+   "topicName": "topic1",
    "pushConfig": {
       "pushEndpoint": "",
       "maxMessages": 0,
@@ -543,6 +702,7 @@ func (suite *SubTestSuite) TestExportJson() {
       {
          "name": "/projects/ARGO/subscriptions/sub4",
          "topic": "/projects/ARGO/topics/topic4",
+         "topicName": "topic4",
          "pushConfig": {
             "pushEndpoint": "endpoint.foo",
             "maxMessages": 1,
@@ -555,7 +715,8 @@ func (suite *SubTestSuite) TestExportJson() {
                "period": 300
             },
             "verification_hash": "push-id-1",
-            "verified": true
+            "verified": true,
+            "maxConcurrentDeliveries": 1
          },
          "ackDeadlineSeconds": 10,
          "created_on": "2020-11-22T00:00:00Z"
@@ -563,6 +724,7 @@ func (suite *SubTestSuite) TestExportJson() {
       {
          "name": "/projects/ARGO/subscriptions/sub3",
          "topic": "/projects/ARGO/topics/topic3",
+         "topicName": "topic3",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -577,6 +739,7 @@ func (suite *SubTestSuite) TestExportJson() {
       {
          "name": "/projects/ARGO/subscriptions/sub2",
          "topic": "/projects/ARGO/topics/topic2",
+         "topicName": "topic2",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -591,6 +754,7 @@ func (suite *SubTestSuite) TestExportJson() {
       {
          "name": "/projects/ARGO/subscriptions/sub1",
          "topic": "/projects/ARGO/topics/topic1",
+         "topicName": "topic1",
          "pushConfig": {
             "pushEndpoint": "",
             "maxMessages": 0,
@@ -642,6 +806,824 @@ func (suite *SubTestSuite) TestGetOffsetFromAckID() {
 
 }
 
+func (suite *SubTestSuite) TestMaskSecrets() {
+
+	store := stores.NewMockStore("", "")
+	results, _ := Find("argo_uuid", "", "", "", 0, store)
+	results.MaskSecrets()
+
+	for _, sub := range results.Subscriptions {
+		if sub.Name == "sub4" {
+			suite.Equal(SecretMask, sub.PushCfg.AuthorizationHeader.Value)
+			continue
+		}
+		// empty values should stay empty
+		suite.Equal("", sub.PushCfg.AuthorizationHeader.Value)
+	}
+
+	// the stored value remains intact
+	results2, _ := Find("argo_uuid", "", "sub4", "", 0, store)
+	suite.Equal("auth-header-1", results2.Subscriptions[0].PushCfg.AuthorizationHeader.Value)
+}
+
+func (suite *SubTestSuite) TestParseAckID() {
+
+	// legacy unversioned format
+	a1, err := ParseAckID("projects/ARGO/subscriptions/sub1:5")
+	suite.Nil(err)
+	suite.Equal(AckID{Version: AckIDVersionLegacy, Project: "ARGO", Subscription: "sub1", Offset: 5}, a1)
+	suite.Equal("projects/ARGO/subscriptions/sub1:5", a1.String())
+
+	// version 1 format
+	a2, err := ParseAckID("v1/projects/ARGO/subscriptions/sub1:1555")
+	suite.Nil(err)
+	suite.Equal(AckID{Version: AckIDVersion1, Project: "ARGO", Subscription: "sub1", Offset: 1555}, a2)
+	suite.Equal("v1/projects/ARGO/subscriptions/sub1:1555", a2.String())
+	suite.True(a2.BelongsTo("ARGO", "sub1"))
+	suite.False(a2.BelongsTo("ARGO", "sub2"))
+	suite.False(a2.BelongsTo("ARGO2", "sub1"))
+
+	invalid := []string{
+		"",
+		"v2/projects/ARGO/subscriptions/sub1:5",
+		"v1/projects/ARGO/subscriptions/sub1",
+		"projects/ARGO/subscriptions/sub1:aaa",
+		"projects/ARGO/subscriptions/sub1:-1",
+		"projects//subscriptions/sub1:5",
+		"projects/ARGO/subscriptions/:5",
+		"falsepath/ARGO/subscriptions/sub1:5",
+		"projects/ARGO/topics/sub1:5",
+		"projects/ARGO//subscriptions/sub1:5",
+		"v1/projects/ARGO/subscriptions/sub1:5.",
+		"v1/projects/ARGO/subscriptions/sub1:.abcd",
+	}
+
+	for _, ackID := range invalid {
+		_, err := ParseAckID(ackID)
+		suite.Equal("invalid argument", err.Error(), ackID)
+	}
+}
+
+func (suite *SubTestSuite) TestNewAckID() {
+	a := NewAckID("ARGO", "sub1", 12)
+	suite.Equal(CurrentAckIDVersion, a.Version)
+	suite.Equal("v1/projects/ARGO/subscriptions/sub1:12", a.String())
+
+	parsed, err := ParseAckID(a.String())
+	suite.Nil(err)
+	suite.Equal(a, parsed)
+}
+
+func (suite *SubTestSuite) TestSignedAckID() {
+
+	// an empty secret leaves the ack id unsigned
+	suite.Equal("v1/projects/ARGO/subscriptions/sub1:12", NewAckID("ARGO", "sub1", 12).Signed("").String())
+
+	a := NewAckID("ARGO", "sub1", 12).Signed("s3cr3t")
+	suite.Equal(32, len(a.Signature))
+	suite.Equal("v1/projects/ARGO/subscriptions/sub1:12."+a.Signature, a.String())
+
+	parsed, err := ParseAckID(a.String())
+	suite.Nil(err)
+	suite.Equal(a, parsed)
+	suite.True(parsed.Verify("s3cr3t"))
+	suite.False(parsed.Verify("other"))
+
+	// moving the signature to another offset or subscription doesn't verify
+	forged, _ := ParseAckID("v1/projects/ARGO/subscriptions/sub1:13." + a.Signature)
+	suite.False(forged.Verify("s3cr3t"))
+	forged, _ = ParseAckID("v1/projects/ARGO/subscriptions/sub2:12." + a.Signature)
+	suite.False(forged.Verify("s3cr3t"))
+
+	unsigned, _ := ParseAckID("v1/projects/ARGO/subscriptions/sub1:12")
+	suite.False(unsigned.Verify("s3cr3t"))
+}
+
+func (suite *SubTestSuite) TestOutstanding() {
+
+	sub := New("argo_uuid", "ARGO", "sub1", "topic1")
+	sub.Ack = 10
+	now := time.Date(2020, 12, 1, 10, 0, 5, 0, time.UTC)
+
+	// nothing has been pulled
+	suite.Equal(OutstandingMessages{Messages: []OutstandingMessage{}}, sub.Outstanding("ARGO", "", now))
+
+	// two messages pulled and not yet acknowledged
+	sub.Offset = 3
+	sub.NextOffset = 5
+	sub.PendingAck = "2020-12-01T10:00:00Z"
+
+	expOM := OutstandingMessages{Messages: []OutstandingMessage{
+		{MessageID: "3", AckID: "v1/projects/ARGO/subscriptions/sub1:3", PullTime: "2020-12-01T10:00:00Z", AckDeadline: "2020-12-01T10:00:10Z"},
+		{MessageID: "4", AckID: "v1/projects/ARGO/subscriptions/sub1:4", PullTime: "2020-12-01T10:00:00Z", AckDeadline: "2020-12-01T10:00:10Z"},
+	}}
+	suite.Equal(expOM, sub.Outstanding("ARGO", "", now))
+
+	// the lease has expired
+	suite.Equal(0, len(sub.Outstanding("ARGO", "", now.Add(10*time.Second)).Messages))
+
+	expJSON := `{
+   "outstandingMessages": [
+      {
+         "messageId": "3",
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:3",
+         "pullTime": "2020-12-01T10:00:00Z",
+         "ackDeadline": "2020-12-01T10:00:10Z"
+      },
+      {
+         "messageId": "4",
+         "ackId": "v1/projects/ARGO/subscriptions/sub1:4",
+         "pullTime": "2020-12-01T10:00:00Z",
+         "ackDeadline": "2020-12-01T10:00:10Z"
+      }
+   ]
+}`
+	outJSON, _ := expOM.ExportJSON()
+	suite.Equal(expJSON, outJSON)
+}
+
+func (suite *SubTestSuite) TestTransform() {
+
+	suite.Equal(UnSupportedTransformError, (&Transform{Type: "script"}).Validate().Error())
+	suite.Equal(MissingTransformFieldError, (&Transform{Type: ExtractFieldTransformType, Field: " . "}).Validate().Error())
+
+	t := Transform{Type: ExtractFieldTransformType, Field: "envelope.body"}
+	suite.Nil(t.Validate())
+
+	// string values become the payload as they are
+	msg := messages.New(b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello"}}`)))
+	suite.Nil(t.Apply(&msg))
+	suite.Equal("hello", msg.GetDecoded())
+
+	// other values keep their json form
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":{"count":2}}}`)))
+	suite.Nil(t.Apply(&msg))
+	suite.Equal(`{"count":2}`, msg.GetDecoded())
+
+	// payloads that can't be transformed are left untouched
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`{"envelope":"body"}`)))
+	suite.Equal("field envelope.body not found", t.Apply(&msg).Error())
+	suite.Equal(`{"envelope":"body"}`, msg.GetDecoded())
+
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte("plain text")))
+	suite.NotNil(t.Apply(&msg))
+	suite.Equal("plain text", msg.GetDecoded())
+
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`{"envelope":{"body":"hello"}}`)))
+	msg.InsertAttribute(messages.CompressionAttribute, messages.CompressionGzip)
+	suite.NotNil(t.Apply(&msg))
+}
+
+func (suite *SubTestSuite) TestProjection() {
+
+	suite.Nil(Projection{"id", "envelope.body"}.Validate())
+	suite.Equal(InvalidFieldsError, Projection{"envelope..body"}.Validate().Error())
+	suite.Equal(InvalidFieldsError, Projection{""}.Validate().Error())
+	suite.Equal("fields can't declare paths deeper than 10 keys", Projection{"a.b.c.d.e.f.g.h.i.j.k"}.Validate().Error())
+
+	tooMany := Projection{}
+	for i := 0; i <= MaxProjectionFields; i++ {
+		tooMany = append(tooMany, "field")
+	}
+	suite.Equal("fields can't declare more than 32 paths", tooMany.Validate().Error())
+
+	// only the projected fields are kept and missing ones are omitted
+	p := Projection{"id", "envelope.body.count", "missing", "envelope.missing"}
+	msg := messages.New(b64.StdEncoding.EncodeToString([]byte(`{"id":1,"name":"n","envelope":{"body":{"count":2,"other":3},"head":"h"}}`)))
+	suite.Nil(p.Apply(&msg))
+	suite.Equal(`{"envelope":{"body":{"count":2}},"id":1}`, msg.GetDecoded())
+
+	// a projected field covers the paths nested in it
+	p = Projection{"envelope.body.count", "envelope"}
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`{"id":1,"envelope":{"body":{"count":2,"other":3}}}`)))
+	suite.Nil(p.Apply(&msg))
+	suite.Equal(`{"envelope":{"body":{"count":2,"other":3}}}`, msg.GetDecoded())
+
+	// payloads that aren't json objects are left untouched
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte("plain text")))
+	suite.NotNil(p.Apply(&msg))
+	suite.Equal("plain text", msg.GetDecoded())
+
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`[1,2]`)))
+	suite.NotNil(p.Apply(&msg))
+	suite.Equal("[1,2]", msg.GetDecoded())
+
+	msg = messages.New(b64.StdEncoding.EncodeToString([]byte(`{"envelope":{}}`)))
+	msg.InsertAttribute(messages.CompressionAttribute, messages.CompressionGzip)
+	suite.NotNil(p.Apply(&msg))
+}
+
+func (suite *SubTestSuite) TestGetPullOptionsJSON() {
+
+	po, err := GetPullOptionsJSON([]byte(`{"maxMessages":"10","returnImmediately":"false","returnCompressed":"true"}`))
+	suite.Nil(err)
+	suite.Equal(SubPullOptions{MaxMsg: "10", RetImm: "false", RetCompressed: "true"}, po)
+
+	// field names are matched case insensitively, as in the json decoding
+	po, err = GetPullOptionsJSON([]byte(`{"MaxMessages":"2"}`))
+	suite.Nil(err)
+	suite.Equal("2", po.MaxMsg)
+
+	po, err = GetPullOptionsJSON([]byte(`{}`))
+	suite.Nil(err)
+	suite.Equal(DefaultMaxMessages, po.MaxMessages())
+
+	// empty and zero values fall back to the default
+	po, err = GetPullOptionsJSON([]byte(`{"maxMessages":""}`))
+	suite.Nil(err)
+	suite.Equal(DefaultMaxMessages, po.MaxMessages())
+
+	po, err = GetPullOptionsJSON([]byte(`{"maxMessages":"0"}`))
+	suite.Nil(err)
+	suite.Equal(DefaultMaxMessages, po.MaxMessages())
+
+	po, err = GetPullOptionsJSON([]byte(`{"maxMessages":"7"}`))
+	suite.Nil(err)
+	suite.Equal(7, po.MaxMessages())
+	suite.Equal(7, po.MaxMessagesOr(20))
+
+	// an omitted maxMessages falls back to the given default
+	po, _ = GetPullOptionsJSON([]byte(`{}`))
+	suite.Equal(20, po.MaxMessagesOr(20))
+
+	for _, body := range []string{
+		`{"maxMessages":"-3"}`,
+		`{"maxMessages":"ten"}`,
+		`{"maxMessages":10}`,
+		`{"maxMessages":"99999999999999999999999"}`,
+	} {
+		_, err = GetPullOptionsJSON([]byte(body))
+		suite.EqualError(err, InvalidMaxMessagesError, body)
+	}
+
+	_, err = GetPullOptionsJSON([]byte(`{"maxMessages":"1.5","returnImmediately":"yes"}`))
+	suite.EqualError(err, "maxMessages must be a non-negative integer, returnImmediately must be either true or false")
+
+	_, err = GetPullOptionsJSON([]byte(`{"returnCompressed":true}`))
+	suite.EqualError(err, InvalidReturnCompressedError)
+
+	_, err = GetPullOptionsJSON([]byte(`["maxMessages"]`))
+	suite.EqualError(err, InvalidRequestBodyJSONError)
+
+	_, err = GetPullOptionsJSON([]byte(`{"maxMessages":`))
+	suite.EqualError(err, InvalidRequestBodyJSONError)
+}
+
+func (suite *SubTestSuite) TestGetAckFromJSON() {
+
+	ack, err := GetAckFromJSON([]byte(`{"AckIds":["v1/projects/ARGO/subscriptions/sub1:1"]}`))
+	suite.Nil(err)
+	suite.Equal([]string{"v1/projects/ARGO/subscriptions/sub1:1"}, ack.IDs)
+
+	ack, err = GetAckFromJSON([]byte(`{"ackIds":["v1/projects/ARGO/subscriptions/sub1:1"]}`))
+	suite.Nil(err)
+	suite.Equal([]string{"v1/projects/ARGO/subscriptions/sub1:1"}, ack.IDs)
+
+	_, err = GetAckFromJSON([]byte(`{"AckIds":"v1/projects/ARGO/subscriptions/sub1:1"}`))
+	suite.EqualError(err, InvalidAckIDsError)
+
+	_, err = GetAckFromJSON([]byte(`{"AckIds":["v1/projects/ARGO/subscriptions/sub1:1", 2]}`))
+	suite.EqualError(err, InvalidAckIDsError)
+
+	ack, err = GetAckFromJSON([]byte(`{"ackOffset":3}`))
+	suite.Nil(err)
+	suite.Equal(int64(3), *ack.AckOffset)
+	suite.Equal(0, len(ack.IDs))
+
+	_, err = GetAckFromJSON([]byte(`{"ackOffset":-1}`))
+	suite.EqualError(err, InvalidAckOffsetError)
+
+	_, err = GetAckFromJSON([]byte(`{"ackOffset":"3"}`))
+	suite.EqualError(err, InvalidAckOffsetError)
+
+	_, err = GetAckFromJSON([]byte(`{"ackIds":["v1/projects/ARGO/subscriptions/sub1:1"],"ackOffset":3}`))
+	suite.EqualError(err, AckIDsWithAckOffsetError)
+
+	_, err = GetAckFromJSON([]byte(`not json`))
+	suite.EqualError(err, InvalidRequestBodyJSONError)
+}
+
+func (suite *SubTestSuite) TestAdmits() {
+
+	sub := Subscription{}
+	suite.True(sub.Admits(messages.Message{PubTime: "2019-05-06T10:00:00Z"}))
+
+	sub.NotBefore = time.Date(2019, 5, 6, 10, 0, 0, 500, time.UTC)
+	suite.False(sub.Admits(messages.Message{PubTime: "2019-05-06T10:00:00.000000499Z"}))
+	suite.True(sub.Admits(messages.Message{PubTime: "2019-05-06T10:00:00.0000005Z"}))
+	suite.True(sub.Admits(messages.Message{PubTime: "2019-05-06T10:00:01Z"}))
+	// messages without a publish time can't be told apart
+	suite.True(sub.Admits(messages.Message{}))
+	// backfilled messages are judged by the time they were received
+	suite.True(sub.Admits(messages.Message{PubTime: "2018-01-01T00:00:00Z", IngestTime: "2019-05-06T10:00:01Z"}))
+	suite.False(sub.Admits(messages.Message{PubTime: "2019-05-06T10:00:01Z", IngestTime: "2019-05-06T09:00:00Z"}))
+}
+
+func (suite *SubTestSuite) TestSamples() {
+
+	sub := Subscription{}
+	suite.True(sub.ValidSamplingRate())
+	suite.True(sub.Samples(2))
+
+	one := 1.0
+	sub.SamplingRate = &one
+	suite.True(sub.ValidSamplingRate())
+	suite.True(sub.Samples(2))
+
+	rate := 0.4
+	sub.SamplingRate = &rate
+	suite.True(sub.ValidSamplingRate())
+	suite.True(sub.Samples(0))
+	suite.False(sub.Samples(1))
+	suite.False(sub.Samples(2))
+	suite.True(sub.Samples(3))
+
+	// the sample is close to the rate and includes the messages of any lower rate
+	lowerRate := 0.1
+	lower := Subscription{SamplingRate: &lowerRate}
+	sampled := 0
+	for off := int64(0); off < 10000; off++ {
+		if sub.Samples(off) {
+			sampled++
+		} else {
+			suite.False(lower.Samples(off))
+		}
+	}
+	suite.InDelta(4000, sampled, 200)
+
+	// a rate of zero would sample nothing
+	for _, invalid := range []float64{-0.1, 0, 1.01} {
+		sub.SamplingRate = &invalid
+		suite.False(sub.ValidSamplingRate())
+	}
+}
+
+func (suite *SubTestSuite) TestPullMaxMessages() {
+
+	defer SetDefaultMaxMessages(0)
+
+	sub := Subscription{}
+	suite.Equal(DefaultMaxMessages, sub.PullMaxMessages())
+
+	SetDefaultMaxMessages(50)
+	suite.Equal(50, sub.PullMaxMessages())
+
+	// the subscription's default takes precedence over the service's one
+	sub.DefaultMaxMessages = 5
+	suite.Equal(5, sub.PullMaxMessages())
+	suite.True(sub.ValidDefaultMaxMessages())
+
+	sub.DefaultMaxMessages = -1
+	suite.False(sub.ValidDefaultMaxMessages())
+
+	sub.DefaultMaxMessages = 0
+	SetDefaultMaxMessages(0)
+	suite.Equal(DefaultMaxMessages, sub.PullMaxMessages())
+}
+
 func TestSubTestSuite(t *testing.T) {
 	suite.Run(t, new(SubTestSuite))
 }
+
+func (suite *SubTestSuite) TestReconcileOffsets() {
+
+	store := stores.NewMockStore("", "")
+	// the mock broker reports min offset 2 and max offset 3 for every topic
+	broker := brokers.MockBroker{MsgList: []string{"msg1", "msg2"}}
+
+	store.UpdateSubOffset("argo_uuid", "sub2", 5)
+	store.UpdateSubOffset("argo_uuid", "sub3", 2)
+
+	res, err := ReconcileOffsets(time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	suite.Equal("2020-11-25T10:00:00Z", res.CompletedAt)
+	suite.Equal(4, res.Checked)
+	suite.Equal([]OffsetAdjustment{
+		{ProjectUUID: "argo_uuid", Subscription: "sub4", OldOffset: 0, NewOffset: 2},
+		{ProjectUUID: "argo_uuid", Subscription: "sub2", OldOffset: 5, NewOffset: 3},
+		{ProjectUUID: "argo_uuid", Subscription: "sub1", OldOffset: 0, NewOffset: 2},
+	}, res.Adjustments)
+
+	for sub, offset := range map[string]int64{"sub1": 2, "sub2": 3, "sub3": 2, "sub4": 2} {
+		qSub, _ := store.QueryOneSub("argo_uuid", sub)
+		suite.Equal(offset, qSub.Offset)
+	}
+
+	last, found := LastReconciliation()
+	suite.True(found)
+	suite.Equal(res, last)
+
+	// offsets already within range are left untouched
+	res, err = ReconcileOffsets(time.Date(2020, 11, 25, 11, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	suite.Equal(4, res.Checked)
+	suite.Equal(0, len(res.Adjustments))
+}
+
+func (suite *SubTestSuite) TestOffsetSnapshots() {
+
+	store := stores.NewMockStore("", "")
+	// the mock broker reports min offset 2 and max offset 3 for every topic
+	broker := brokers.MockBroker{MsgList: []string{"msg1", "msg2"}}
+
+	t1 := time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC)
+	store.UpdateSubOffset("argo_uuid", "sub1", 2)
+	store.UpdateSubOffset("argo_uuid", "sub2", 5)
+	store.UpdateSubOffset("argo_uuid", "sub3", 3)
+
+	res, err := SnapshotOffsets(t1, time.Hour, store)
+	suite.Nil(err)
+	suite.Equal(OffsetSnapshot{Name: "20201125T100000Z", CreatedOn: "2020-11-25T10:00:00Z", Subscriptions: 4}, res)
+
+	// the consumption moves on after the snapshot
+	store.UpdateSubOffset("argo_uuid", "sub1", 3)
+	store.UpdateSubOffset("argo_uuid", "sub2", 3)
+	store.RemoveSub("argo_uuid", "sub4")
+
+	restored, err := RestoreSnapshot("20201125T100000Z", store, &broker)
+	suite.Nil(err)
+	suite.Equal("20201125T100000Z", restored.Snapshot)
+	suite.Equal([]string{"/projects/ARGO/subscriptions/sub4"}, restored.Skipped)
+	suite.Equal(3, len(restored.Restored))
+
+	// offsets outside the range the broker still holds are clamped into it
+	for sub, offset := range map[string]int64{"sub1": 2, "sub2": 3, "sub3": 3} {
+		qSub, _ := store.QueryOneSub("argo_uuid", sub)
+		suite.Equal(offset, qSub.Offset)
+	}
+	for _, r := range restored.Restored {
+		if r.Subscription == "sub2" {
+			suite.Equal(OffsetRestore{ProjectUUID: "argo_uuid", Subscription: "sub2", OldOffset: 3, SnapshotOffset: 5, NewOffset: 3}, r)
+		}
+	}
+
+	_, err = RestoreSnapshot("20201125T090000Z", store, &broker)
+	suite.Equal(ErrSnapshotNotFound, err)
+
+	// snapshots older than the retention are removed as new ones get taken
+	_, err = SnapshotOffsets(t1.Add(30*time.Minute), time.Hour, store)
+	suite.Nil(err)
+	list, err := ListSnapshots(store)
+	suite.Nil(err)
+	suite.Equal(2, len(list.Snapshots))
+	suite.Equal("20201125T100000Z", list.Snapshots[0].Name)
+	suite.Equal("20201125T103000Z", list.Snapshots[1].Name)
+	suite.Equal(3, list.Snapshots[1].Subscriptions)
+
+	_, err = SnapshotOffsets(t1.Add(90*time.Minute), time.Hour, store)
+	suite.Nil(err)
+	list, _ = ListSnapshots(store)
+	suite.Equal(2, len(list.Snapshots))
+	suite.Equal("20201125T103000Z", list.Snapshots[0].Name)
+}
+
+func (suite *SubTestSuite) TestEffectiveConfig() {
+
+	sub := New("argo_uuid", "ARGO", "sub1", "topic1")
+	sub.Ack = 30
+	sub.PushCfg = PushConfig{
+		Pend:   "https://www.example.com",
+		RetPol: RetryPolicy{PolicyType: SlowStartRetryPolicyType},
+		Fanout: []string{"https://one.example.com"},
+	}
+
+	cfg := sub.EffectiveConfig()
+	suite.Equal(ConfigValue{Value: 30, Source: ExplicitConfigSource}, cfg.AckDeadline)
+	suite.Equal(ConfigValue{Value: false, Source: DefaultConfigSource}, cfg.NewMessagesOnly)
+	suite.Equal(ConfigValue{Value: DefaultPushMaxMessages, Source: DefaultConfigSource}, cfg.PushCfg.MaxMessages)
+	suite.Equal(ConfigValue{Value: AutoGenerationAuthorizationHeader, Source: DefaultConfigSource}, cfg.PushCfg.AuthorizationHeaderType)
+	suite.Equal(ConfigValue{Value: SlowStartRetryPolicyType, Source: ExplicitConfigSource}, cfg.PushCfg.RetryPolicyType)
+	// slow start retries don't use a period
+	suite.Equal(ConfigValue{Value: 0, Source: DefaultConfigSource}, cfg.PushCfg.RetryPolicyPeriod)
+	suite.Equal(ConfigValue{Value: []string{"https://one.example.com"}, Source: ExplicitConfigSource}, cfg.PushCfg.Fanout)
+	suite.Equal(ConfigValue{Value: DefaultMaxConcurrentDeliveries, Source: DefaultConfigSource}, cfg.PushCfg.MaxConcurrentDeliveries)
+
+	// pull subscriptions have no push configuration to resolve
+	sub = New("argo_uuid", "ARGO", "sub2", "topic2")
+	cfg = sub.EffectiveConfig()
+	suite.Equal(ConfigValue{Value: DefaultAckDeadline, Source: DefaultConfigSource}, cfg.AckDeadline)
+	suite.Nil(cfg.PushCfg)
+}
+
+func (suite *SubTestSuite) TestLabels() {
+
+	store := stores.NewMockStore("", "")
+	suite.Nil(ModSubLabels("argo_uuid", "sub1", map[string]string{"team": "sre"}, store))
+	suite.Equal("not found", ModSubLabels("argo_uuid", "unknown", map[string]string{"team": "sre"}, store).Error())
+
+	res, err := FindByLabels("argo_uuid", "", "", "", 0, labels.Selector{"team": {"sre"}}, "", store)
+	suite.Nil(err)
+	suite.Equal(1, len(res.Subscriptions))
+	suite.Equal("sub1", res.Subscriptions[0].Name)
+	suite.Equal(map[string]string{"team": "sre"}, res.Subscriptions[0].Labels)
+
+	res, _ = FindByLabels("argo_uuid", "", "", "", 0, labels.Selector{"team": {"ops"}}, "", store)
+	suite.Equal(0, len(res.Subscriptions))
+
+	// an empty set of labels removes them
+	suite.Nil(ModSubLabels("argo_uuid", "sub1", map[string]string{}, store))
+	res, _ = Find("argo_uuid", "", "sub1", "", 0, store)
+	suite.Nil(res.Subscriptions[0].Labels)
+}
+
+func (suite *SubTestSuite) TestDeduplicate() {
+
+	store := stores.NewMockStore("", "")
+	sub := New("argo_uuid", "ARGO", "sub1", "topic1")
+
+	// subscriptions that don't deduplicate don't record anything
+	suite.Nil(sub.RecordAcked(0, 4, 3, store))
+	ids, _ := store.QueryAckedIDs("argo_uuid", "sub1")
+	suite.Equal(0, len(ids))
+
+	store.SubList[0].Deduplicate = true
+	res, _ := Find("argo_uuid", "", "sub1", "", 0, store)
+	sub = res.Subscriptions[0]
+	suite.True(sub.Deduplicate)
+
+	// a range wider than the window only records its tail
+	suite.Nil(sub.RecordAcked(0, 4, 3, store))
+	acked, err := sub.AckedIDs(store)
+	suite.Nil(err)
+	suite.Equal(AckedSet{2: true, 3: true, 4: true}, acked)
+
+	// the oldest ids are forgotten as newer ones get recorded
+	suite.Nil(sub.RecordAcked(5, 6, 3, store))
+	acked, _ = sub.AckedIDs(store)
+	suite.Equal(AckedSet{4: true, 5: true, 6: true}, acked)
+	suite.False(acked.Has(2))
+	suite.True(acked.Has(6))
+}
+
+func (suite *SubTestSuite) TestCompare() {
+
+	sub := New("argo_uuid", "ARGO", "sub1", "topic1")
+	with := New("argo_uuid", "ARGO", "sub2", "topic1")
+
+	// a declared default matches an undeclared value and the order of the acl users doesn't matter
+	with.Ack = DefaultAckDeadline
+	c := Compare(sub, []string{"UserA", "UserB"}, with, []string{"UserB", "UserA"})
+	suite.True(c.Identical)
+	suite.Equal("/projects/ARGO/subscriptions/sub1", c.Subscription)
+	suite.Equal("/projects/ARGO/subscriptions/sub2", c.With)
+	suite.Equal([]FieldDiff{}, c.Differences)
+
+	with.Offset = 4
+	with.Ack = 30
+	with.PushCfg = PushConfig{Pend: "https://www.example.com", AuthorizationHeader: AuthorizationHeader{Value: "secret"}}
+	c = Compare(sub, []string{"UserA"}, with, []string{"UserB"})
+	suite.False(c.Identical)
+	suite.Equal([]FieldDiff{
+		{Field: "offset", Value: int64(0), With: int64(4)},
+		{Field: "ackDeadlineSeconds", Value: DefaultAckDeadline, With: 30},
+		{Field: "pushConfig.pushEndpoint", Value: nil, With: "https://www.example.com"},
+		{Field: "pushConfig.maxMessages", Value: nil, With: DefaultPushMaxMessages},
+		{Field: "pushConfig.authorizationHeaderType", Value: nil, With: AutoGenerationAuthorizationHeader},
+		{Field: "pushConfig.retryPolicyType", Value: nil, With: LinearRetryPolicyType},
+		{Field: "pushConfig.retryPolicyPeriod", Value: nil, With: DefaultRetryPeriod},
+		{Field: "pushConfig.fanoutEndpoints", Value: nil, With: []string{}},
+		{Field: "pushConfig.maxConcurrentDeliveries", Value: nil, With: DefaultMaxConcurrentDeliveries},
+		{Field: "pushConfig.maxRetryDuration", Value: nil, With: 0},
+		{Field: "pushConfig.maxRetries", Value: nil, With: 0},
+		{Field: "acl", Value: []string{"UserA"}, With: []string{"UserB"}},
+	}, c.Differences)
+}
+
+func (suite *SubTestSuite) TestPullLease() {
+
+	store := stores.NewMockStore("whatever", "argo_mgs")
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	// subscriptions without a limit don't take leases
+	sub := New("argo_uuid", "ARGO", "sub1", "topic1")
+	lease, acquired, err := sub.AcquirePullLease(now, 0, store)
+	suite.True(acquired)
+	suite.Nil(err)
+	suite.Equal(PullLease{}, lease)
+	suite.Nil(lease.Release(store))
+	suite.Equal(0, len(store.PullLeases))
+
+	suite.Nil(ModSubMaxConcurrentPulls("argo_uuid", "sub1", 2, store))
+	suite.Equal("not found", ModSubMaxConcurrentPulls("argo_uuid", "unknown", 2, store).Error())
+	res, _ := Find("argo_uuid", "", "sub1", "", 0, store)
+	sub = res.Subscriptions[0]
+	suite.Equal(2, sub.MaxConcurrentPulls)
+
+	first, acquired, _ := sub.AcquirePullLease(now, 10*time.Second, store)
+	suite.True(acquired)
+	_, acquired, _ = sub.AcquirePullLease(now, 20*time.Second, store)
+	suite.True(acquired)
+	_, acquired, _ = sub.AcquirePullLease(now, 10*time.Second, store)
+	suite.False(acquired)
+
+	// a released lease can be taken by another pull
+	suite.Nil(first.Release(store))
+	_, acquired, _ = sub.AcquirePullLease(now, 10*time.Second, store)
+	suite.True(acquired)
+
+	// leases that were never released expire after their ttl
+	_, acquired, _ = sub.AcquirePullLease(now.Add(5*time.Second), 10*time.Second, store)
+	suite.False(acquired)
+	_, acquired, _ = sub.AcquirePullLease(now.Add(10*time.Second), 10*time.Second, store)
+	suite.True(acquired)
+	_, acquired, _ = sub.AcquirePullLease(now.Add(10*time.Second), 10*time.Second, store)
+	suite.False(acquired)
+
+	sub.MaxConcurrentPulls = MaxConcurrentPullsLimit + 1
+	suite.False(sub.ValidMaxConcurrentPulls())
+	sub.MaxConcurrentPulls = -1
+	suite.False(sub.ValidMaxConcurrentPulls())
+	sub.MaxConcurrentPulls = 0
+	suite.True(sub.ValidMaxConcurrentPulls())
+}
+
+func (suite *SubTestSuite) TestPurgeTopic() {
+
+	store := stores.NewMockStore("", "")
+	broker := brokers.MockBroker{MsgList: []string{"msg1", "msg2", "msg3", "msg4", "msg5"}}
+
+	base := time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC)
+
+	store.UpdateSubOffset("argo_uuid", "sub1", 1)
+	store.SubList = append(store.SubList, stores.QSub{ProjectUUID: "argo_uuid", Name: "sub_ahead", Topic: "topic1", Offset: 4})
+
+	// the message at offset 3 is the first one published after the cutoff
+	res, err := PurgeTopic("argo_uuid", "topic1", "argo_uuid.topic1", base.Add(150*time.Minute), 3, true, store, &broker)
+	suite.Nil(err)
+	suite.Equal("topic1", res.Topic)
+	suite.Equal("2020-11-25T12:30:00Z", res.Cutoff)
+	suite.Equal(int64(3), res.Offset)
+	suite.True(res.BrokerRetention)
+	suite.Equal([]ExpiredMessages{{Subscription: "sub1", OldOffset: 1, NewOffset: 3, Expired: 2}}, res.Subscriptions)
+	suite.Equal(int64(3), broker.DeletedBefore["argo_uuid.topic1"])
+
+	qSub, _ := store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(3), qSub.Offset)
+	qSub, _ = store.QueryOneSub("argo_uuid", "sub_ahead")
+	suite.Equal(int64(4), qSub.Offset)
+
+	// without messages published after the cutoff all of them expire,
+	// while a broker that can't delete records leaves them to its retention
+	broker.NoDeleteRecords = true
+	res, err = PurgeTopic("argo_uuid", "topic1", "argo_uuid.topic1", base.Add(24*time.Hour), -1, true, store, &broker)
+	suite.Nil(err)
+	suite.Equal(int64(6), res.Offset)
+	suite.False(res.BrokerRetention)
+	suite.Equal([]ExpiredMessages{
+		{Subscription: "sub1", OldOffset: 3, NewOffset: 6, Expired: 3},
+		{Subscription: "sub_ahead", OldOffset: 4, NewOffset: 6, Expired: 2},
+	}, res.Subscriptions)
+}
+
+// drainBroker retains all the messages of its list, unlike the mock broker whose min offset is past them
+type drainBroker struct {
+	brokers.MockBroker
+}
+
+func (b *drainBroker) GetMinOffset(topic string) int64 {
+	return 0
+}
+
+func (b *drainBroker) GetMaxOffset(topic string) int64 {
+	return int64(len(b.MsgList))
+}
+
+// failingWriter fails every write after the first n bytes
+type failingWriter struct {
+	n int
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.n {
+		return 0, errors.New("disk full")
+	}
+	fw.n -= len(p)
+	return len(p), nil
+}
+
+func (fw *failingWriter) Close() error {
+	return nil
+}
+
+type failingSink struct {
+	w *failingWriter
+}
+
+func (fs failingSink) Create(name string) (io.WriteCloser, string, error) {
+	return fs.w, "failing://" + name, nil
+}
+
+func (suite *SubTestSuite) TestOpenSink() {
+
+	sink, err := OpenSink("file:///var/lib/argo-messaging/drains")
+	suite.Nil(err)
+	suite.Equal(FileSink{Dir: "/var/lib/argo-messaging/drains"}, sink)
+
+	sink, err = OpenSink("/var/lib/argo-messaging/drains")
+	suite.Nil(err)
+	suite.Equal(FileSink{Dir: "/var/lib/argo-messaging/drains"}, sink)
+
+	_, err = OpenSink("file://")
+	suite.NotNil(err)
+
+	_, err = OpenSink("s3test://bucket/drains")
+	suite.Equal(ErrUnsupportedSink, err)
+
+	RegisterSink("S3TEST", func(u *url.URL) (Sink, error) {
+		return failingSink{w: &failingWriter{}}, nil
+	})
+	sink, err = OpenSink("s3test://bucket/drains")
+	suite.Nil(err)
+	suite.IsType(failingSink{}, sink)
+}
+
+func (suite *SubTestSuite) TestDrain() {
+
+	store := stores.NewMockStore("", "")
+	broker := drainBroker{}
+	broker.PopulateThree()
+	dir, _ := ioutil.TempDir("", "drains")
+	defer os.RemoveAll(dir)
+	sink := FileSink{Dir: filepath.Join(dir, "exports")}
+	now := time.Date(2020, 11, 25, 10, 0, 0, 0, time.UTC)
+
+	store.UpdateSubOffset("argo_uuid", "sub1", 1)
+	subs, _ := Find("argo_uuid", "", "sub1", "", 0, store)
+
+	res, err := Drain(context.Background(), "argo_uuid", subs.Subscriptions[0], sink, now, store, &broker)
+	suite.Nil(err)
+	suite.Equal(filepath.Join(dir, "exports", "argo_uuid.sub1.20201125T100000Z.ndjson"), res.Location)
+	suite.Equal(int64(2), res.Messages)
+	suite.Equal(int64(1), res.OldOffset)
+	suite.Equal(int64(3), res.NewOffset)
+
+	qSub, _ := store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(3), qSub.Offset)
+
+	// one message per line, in the order they were published
+	content, _ := ioutil.ReadFile(res.Location)
+	suite.Equal(res.Bytes, int64(len(content)))
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	suite.Equal(2, len(lines))
+	msg, err := messages.LoadMsgJSON([]byte(lines[0]))
+	suite.Nil(err)
+	suite.Equal("1", msg.ID)
+	suite.Equal("bar2", msg.Attr["foo2"])
+
+	// nothing is left to drain, so no export is created
+	subs, _ = Find("argo_uuid", "", "sub1", "", 0, store)
+	res, err = Drain(context.Background(), "argo_uuid", subs.Subscriptions[0], sink, now, store, &broker)
+	suite.Nil(err)
+	suite.Equal(DrainResult{Subscription: "/projects/ARGO/subscriptions/sub1", OldOffset: 3, NewOffset: 3}, res)
+
+	// an export that already exists isn't overwritten
+	store.UpdateSubOffset("argo_uuid", "sub1", 0)
+	subs, _ = Find("argo_uuid", "", "sub1", "", 0, store)
+	_, err = Drain(context.Background(), "argo_uuid", subs.Subscriptions[0], sink, now, store, &broker)
+	suite.NotNil(err)
+	qSub, _ = store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(0), qSub.Offset)
+
+	// a failed write leaves the subscription at the first message that wasn't exported
+	res, err = Drain(context.Background(), "argo_uuid", subs.Subscriptions[0], failingSink{w: &failingWriter{n: 10}}, now, store, &broker)
+	suite.Equal("disk full", err.Error())
+	suite.Equal(int64(0), res.Messages)
+	suite.Equal(int64(0), res.NewOffset)
+	qSub, _ = store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal(int64(0), qSub.Offset)
+
+	// the messages keep the id they were published with, e.g. when compaction removed the ones preceding them
+	broker.MsgList = append(broker.MsgList, `{"messageId": "5", "data": "YmFzZTY0ZW5jb2RlZA=="}`)
+	store.UpdateSubOffset("argo_uuid", "sub1", 3)
+	subs, _ = Find("argo_uuid", "", "sub1", "", 0, store)
+	res, err = Drain(context.Background(), "argo_uuid", subs.Subscriptions[0], sink, now.Add(time.Hour), store, &broker)
+	suite.Nil(err)
+	content, _ = ioutil.ReadFile(res.Location)
+	msg, _ = messages.LoadMsgJSON(content)
+	suite.Equal("5", msg.ID)
+}
+
+func (suite *SubTestSuite) TestFindStalled() {
+
+	store := stores.NewMockStore("", "")
+	// the mock broker reports max offset 4 for every topic
+	broker := brokers.MockBroker{MsgList: []string{"msg1", "msg2", "msg3"}}
+
+	store.UpdateSubLastProgress("argo_uuid", "sub1", time.Date(2020, 11, 23, 0, 0, 0, 0, time.UTC))
+	store.UpdateSubOffset("argo_uuid", "sub3", 4)
+	store.UpdateSubOffset("argo_uuid", "sub4", 1)
+	store.UpdateSubLatestConsume("argo_uuid", "sub4", time.Date(2020, 11, 22, 10, 0, 0, 0, time.UTC))
+
+	res, err := FindStalled("argo_uuid", "", time.Date(2020, 11, 23, 0, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	// sub1 made progress at the cutoff and sub3 has no backlog
+	suite.Equal([]StalledSubscription{
+		{Name: "/projects/ARGO/subscriptions/sub4", Topic: "/projects/ARGO/topics/topic4", Backlog: 3, LastProgress: timestamp.Format(time.Date(2020, 11, 22, 0, 0, 0, 0, time.Local)), LatestConsume: "2020-11-22T10:00:00Z"},
+		{Name: "/projects/ARGO/subscriptions/sub2", Topic: "/projects/ARGO/topics/topic2", Backlog: 4, LastProgress: timestamp.Format(time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local)), LatestConsume: timestamp.Format(time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local))},
+	}, res.Subscriptions)
+
+	// subscriptions that progressed within the duration are not stalled
+	res, err = FindStalled("argo_uuid", "", time.Date(2020, 11, 20, 0, 0, 0, 0, time.Local), store, &broker)
+	suite.Nil(err)
+	suite.Equal(0, len(res.Subscriptions))
+}
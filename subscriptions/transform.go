@@ -0,0 +1,101 @@
+package subscriptions
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/ARGOeu/argo-messaging/messages"
+)
+
+const (
+	// ExtractFieldTransformType replaces the message's data with the value of a field of its json payload
+	ExtractFieldTransformType  = "extract_field"
+	UnSupportedTransformError  = `Transform can only be of 'extract_field' type`
+	MissingTransformFieldError = `Transform of 'extract_field' type should declare the field to extract`
+)
+
+var supportedTransformTypes = []string{
+	ExtractFieldTransformType,
+}
+
+// Transform declares a transformation that is applied to the messages of a subscription before their delivery.
+// Only the allow-listed transform types are supported, so that a subscription can't run arbitrary logic
+type Transform struct {
+	Type string `json:"type"`
+	// Field is the dot separated path of the payload field to extract, e.g. envelope.body
+	Field string `json:"field,omitempty"`
+}
+
+// Validate checks that the transform is of a supported type and is properly declared
+func (t *Transform) Validate() error {
+
+	supported := false
+	for _, tt := range supportedTransformTypes {
+		if t.Type == tt {
+			supported = true
+		}
+	}
+
+	if !supported {
+		return errors.New(UnSupportedTransformError)
+	}
+
+	if t.Type == ExtractFieldTransformType && strings.Trim(t.Field, ". ") == "" {
+		return errors.New(MissingTransformFieldError)
+	}
+
+	return nil
+}
+
+// Apply transforms the message in place. Messages that can't be transformed, e.g. because their payload
+// is not json or lacks the field, are left untouched and an error is returned
+func (t *Transform) Apply(msg *messages.Message) error {
+
+	if msg.IsCompressed() {
+		return errors.New("compressed messages can't be transformed")
+	}
+
+	switch t.Type {
+	case ExtractFieldTransformType:
+		return extractField(msg, t.Field)
+	}
+
+	return errors.New(UnSupportedTransformError)
+}
+
+// extractField replaces the message's data with the value found under the given path of its json payload.
+// String values become the new payload as they are, any other value keeps its json form
+func extractField(msg *messages.Message, path string) error {
+
+	data, err := b64.StdEncoding.DecodeString(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return errors.New("field " + path + " not found")
+		}
+		if value, ok = obj[key]; !ok {
+			return errors.New("field " + path + " not found")
+		}
+	}
+
+	var extracted []byte
+	if s, ok := value.(string); ok {
+		extracted = []byte(s)
+	} else if extracted, err = json.Marshal(value); err != nil {
+		return err
+	}
+
+	msg.Data = b64.StdEncoding.EncodeToString(extracted)
+	return nil
+}
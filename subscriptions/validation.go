@@ -0,0 +1,145 @@
+package subscriptions
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	// DefaultMaxMessages is the number of messages returned by a pull request that doesn't declare maxMessages
+	DefaultMaxMessages            = 1
+	InvalidMaxMessagesError       = "maxMessages must be a non-negative integer"
+	InvalidReturnImmediatelyError = "returnImmediately must be either true or false"
+	InvalidReturnCompressedError  = "returnCompressed must be either true or false"
+	InvalidAckIDsError            = "ackIds must be a list of ack ids"
+	InvalidAckOffsetError         = "ackOffset must be a non-negative integer"
+	AckIDsWithAckOffsetError      = "ackIds and ackOffset can't be declared together"
+	InvalidFieldsError            = "fields must be a list of dot separated field paths"
+	InvalidRequestBodyJSONError   = "request body must be a valid json object"
+)
+
+// defaultMaxMessages is the number of messages returned by the pulls that don't declare maxMessages,
+// on subscriptions without a default of their own
+var defaultMaxMessages = DefaultMaxMessages
+
+// SetDefaultMaxMessages sets the service's default number of messages returned by the pulls that don't declare maxMessages.
+// Zero, or a negative value, restores the DefaultMaxMessages
+func SetDefaultMaxMessages(max int) {
+	if max <= 0 {
+		max = DefaultMaxMessages
+	}
+	defaultMaxMessages = max
+}
+
+// pullOptionsSchema describes the body of a pull request.
+// The fields are declared as strings to stay compatible with the existing clients and,
+// since the json decoding matches field names case insensitively, so does the schema
+const pullOptionsSchema = `{
+  "type": "object",
+  "patternProperties": {
+    "^(?i)maxMessages$": {"type": "string", "pattern": "^[0-9]*$"},
+    "^(?i)returnImmediately$": {"type": "string", "enum": ["true", "false"]},
+    "^(?i)returnCompressed$": {"type": "string", "enum": ["true", "false"]},
+    "^(?i)fields$": {"type": "array", "items": {"type": "string"}}
+  }
+}`
+
+// ackIDsSchema describes the body of an acknowledge request, which either lists ack ids or declares an offset.
+// A missing or empty list of ack ids is reported by the acknowledge handler itself
+const ackIDsSchema = `{
+  "type": "object",
+  "patternProperties": {
+    "^(?i)ackIds$": {"type": "array", "items": {"type": "string"}},
+    "^(?i)ackOffset$": {"type": "integer", "minimum": 0}
+  }
+}`
+
+// fieldErrors holds the message returned for each field that fails the validation of a request body,
+// keyed by the lower case name of the field
+var fieldErrors = map[string]string{
+	"maxmessages":       InvalidMaxMessagesError,
+	"returnimmediately": InvalidReturnImmediatelyError,
+	"returncompressed":  InvalidReturnCompressedError,
+	"ackids":            InvalidAckIDsError,
+	"ackoffset":         InvalidAckOffsetError,
+	"fields":            InvalidFieldsError,
+}
+
+var (
+	pullOptionsSchemaLoader = gojsonschema.NewStringLoader(pullOptionsSchema)
+	ackIDsSchemaLoader      = gojsonschema.NewStringLoader(ackIDsSchema)
+)
+
+// validateRequestBody checks the input against the given schema and
+// returns an error that names every invalid field of the request body
+func validateRequestBody(schema gojsonschema.JSONLoader, input []byte) error {
+
+	result, err := gojsonschema.Validate(schema, gojsonschema.NewBytesLoader(input))
+	if err != nil {
+		return errors.New(InvalidRequestBodyJSONError)
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	msgs := map[string]bool{}
+	for _, resErr := range result.Errors() {
+		// errors of array items are reported as field.index, e.g. ackIds.0
+		field := strings.Split(resErr.Field(), ".")[0]
+		msg, found := fieldErrors[strings.ToLower(field)]
+		if !found {
+			msg = InvalidRequestBodyJSONError
+		}
+		msgs[msg] = true
+	}
+
+	errMsgs := []string{}
+	for msg := range msgs {
+		errMsgs = append(errMsgs, msg)
+	}
+	sort.Strings(errMsgs)
+
+	return errors.New(strings.Join(errMsgs, ", "))
+}
+
+// Validate checks that the pull options hold values that can be served
+func (po SubPullOptions) Validate() error {
+
+	if err := po.Fields.Validate(); err != nil {
+		return err
+	}
+
+	if po.MaxMsg == "" {
+		return nil
+	}
+
+	// the schema accepts only digits, although the number might still be too large to handle
+	if max, err := strconv.Atoi(po.MaxMsg); err != nil || max < 0 {
+		return errors.New(InvalidMaxMessagesError)
+	}
+
+	return nil
+}
+
+// MaxMessages returns the max number of messages that should be pulled.
+// An empty or zero maxMessages falls back to the DefaultMaxMessages
+func (po SubPullOptions) MaxMessages() int {
+	return po.MaxMessagesOr(DefaultMaxMessages)
+}
+
+// MaxMessagesOr returns the max number of messages that should be pulled,
+// an empty or zero maxMessages falling back to the given default
+func (po SubPullOptions) MaxMessagesOr(def int) int {
+
+	max, err := strconv.Atoi(po.MaxMsg)
+	if err != nil || max <= 0 {
+		return def
+	}
+
+	return max
+}
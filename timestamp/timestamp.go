@@ -0,0 +1,26 @@
+package timestamp
+
+import (
+	"time"
+)
+
+// Format formats the time as an RFC3339 timestamp in UTC with second precision, e.g. 2019-05-06T10:00:00Z,
+// which is how the service reports timestamps
+func Format(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// FormatNano formats the time as an RFC3339 timestamp in UTC with sub-second precision, e.g. 2019-05-06T10:00:00.123456789Z.
+// Trailing zeros of the fraction are omitted
+func FormatNano(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// Parse parses an RFC3339 timestamp, with or without a fraction of a second, and returns the time in UTC
+func Parse(ts string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
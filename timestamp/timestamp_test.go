@@ -0,0 +1,67 @@
+package timestamp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TimestampTestSuite struct {
+	suite.Suite
+}
+
+func (suite *TimestampTestSuite) TestFormat() {
+
+	athens := time.FixedZone("EET", 2*60*60)
+	t := time.Date(2019, 5, 6, 13, 0, 0, 123456000, athens)
+
+	suite.Equal("2019-05-06T11:00:00Z", Format(t))
+	suite.Equal("2019-05-06T11:00:00.123456Z", FormatNano(t))
+	suite.Equal("2019-05-06T11:00:00Z", FormatNano(t.Truncate(time.Second)))
+}
+
+func (suite *TimestampTestSuite) TestParse() {
+
+	t, err := Parse("2019-05-06T11:00:00Z")
+	suite.Nil(err)
+	suite.Equal(time.Date(2019, 5, 6, 11, 0, 0, 0, time.UTC), t)
+
+	t, err = Parse("2019-05-06T11:00:00.5Z")
+	suite.Nil(err)
+	suite.Equal(time.Date(2019, 5, 6, 11, 0, 0, 500000000, time.UTC), t)
+
+	// offsets are converted to UTC
+	t, err = Parse("2019-05-06T13:00:00+02:00")
+	suite.Nil(err)
+	suite.Equal(time.Date(2019, 5, 6, 11, 0, 0, 0, time.UTC), t)
+
+	_, err = Parse("2019-05-06 11:00:00")
+	suite.NotNil(err)
+
+	_, err = Parse("")
+	suite.NotNil(err)
+}
+
+func (suite *TimestampTestSuite) TestRoundTrip() {
+
+	t := time.Date(2019, 5, 6, 11, 0, 0, 987654321, time.UTC)
+
+	parsed, err := Parse(FormatNano(t))
+	suite.Nil(err)
+	suite.Equal(t, parsed)
+
+	parsed, err = Parse(Format(t))
+	suite.Nil(err)
+	suite.Equal(t.Truncate(time.Second), parsed)
+
+	local := time.Date(2019, 5, 6, 11, 0, 0, 987654321, time.FixedZone("", -5*60*60))
+	parsed, err = Parse(FormatNano(local))
+	suite.Nil(err)
+	suite.True(local.Equal(parsed))
+	suite.Equal(time.UTC, parsed.Location())
+}
+
+func TestTimestampTestSuite(t *testing.T) {
+	suite.Run(t, new(TimestampTestSuite))
+}
@@ -0,0 +1,118 @@
+package topics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// PublishLimits are the rates each topic may be published to. A non positive rate leaves that aspect of the publishing unbounded
+type PublishLimits struct {
+	MessagesPerSecond float64 `json:"messages_per_second,omitempty"`
+	BytesPerSecond    float64 `json:"bytes_per_second,omitempty"`
+}
+
+// IsEmpty returns true if the limits don't bound anything
+func (pl PublishLimits) IsEmpty() bool {
+	return pl.MessagesPerSecond <= 0 && pl.BytesPerSecond <= 0
+}
+
+// PublishQuota is what is left of a topic's limits right after a publish, the remaining amounts are
+// meaningful only for the limits that are set
+type PublishQuota struct {
+	Limits            PublishLimits
+	RemainingMessages int64
+	RemainingBytes    int64
+}
+
+// bucket is a token bucket that refills at the rate of its limit and holds up to a second's worth of tokens
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens accumulated since the bucket was last used, a new bucket starts full
+func (b *bucket) refill(rate float64, now time.Time) {
+	if b.last.IsZero() {
+		b.tokens = rate
+	} else if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(rate, b.tokens+elapsed*rate)
+	}
+	b.last = now
+}
+
+// wait returns how long it takes for the bucket to hold n tokens. A full bucket admits requests larger than itself,
+// going into debt that the following requests wait for, so that no request is held back forever
+func (b *bucket) wait(rate float64, n float64) time.Duration {
+	need := math.Min(n, rate) - b.tokens
+	if need <= 0 {
+		return 0
+	}
+	return time.Duration(need / rate * float64(time.Second))
+}
+
+// remaining returns the whole tokens left in the bucket
+func (b *bucket) remaining() int64 {
+	if b.tokens <= 0 {
+		return 0
+	}
+	return int64(b.tokens)
+}
+
+type topicBuckets struct {
+	messages bucket
+	bytes    bucket
+}
+
+// RateLimiter enforces the publish limits of the topics, keeping the quota of each topic in memory
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*topicBuckets
+}
+
+// NewRateLimiter creates a rate limiter with the full quota available to every topic
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: map[string]*topicBuckets{}}
+}
+
+// Take takes the published messages and bytes out of the quota of the topic identified by the key. If either limit would
+// be exceeded nothing is taken, and Take returns false along with how long the publisher should wait before retrying
+func (rl *RateLimiter) Take(key string, limits PublishLimits, msgs int, bytes int64, now time.Time) (PublishQuota, time.Duration, bool) {
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	tb, found := rl.buckets[key]
+	if !found {
+		tb = &topicBuckets{}
+		rl.buckets[key] = tb
+	}
+
+	quota := PublishQuota{Limits: limits}
+	var retryAfter time.Duration
+
+	if limits.MessagesPerSecond > 0 {
+		tb.messages.refill(limits.MessagesPerSecond, now)
+		if d := tb.messages.wait(limits.MessagesPerSecond, float64(msgs)); d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	if limits.BytesPerSecond > 0 {
+		tb.bytes.refill(limits.BytesPerSecond, now)
+		if d := tb.bytes.wait(limits.BytesPerSecond, float64(bytes)); d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	allowed := retryAfter == 0
+	if allowed {
+		tb.messages.tokens -= float64(msgs)
+		tb.bytes.tokens -= float64(bytes)
+	}
+
+	quota.RemainingMessages = tb.messages.remaining()
+	quota.RemainingBytes = tb.bytes.remaining()
+
+	return quota, retryAfter, allowed
+}
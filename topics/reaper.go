@@ -0,0 +1,75 @@
+package topics
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+// PurgeDeleted permanently removes the topics that have been soft-deleted for longer than the grace period,
+// both from the store and the broker. It returns the number of purged topics
+func PurgeDeleted(gracePeriod time.Duration, now time.Time, store stores.Store, broker brokers.Broker) (int, error) {
+
+	deleted, err := store.QueryDeletedTopics(now.Add(-gracePeriod))
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, t := range deleted {
+
+		if err := store.RemoveTopic(t.ProjectUUID, t.Name); err != nil {
+			log.WithFields(
+				log.Fields{
+					"type":         "service_log",
+					"project_uuid": t.ProjectUUID,
+					"topic_name":   t.Name,
+					"error":        err.Error(),
+				},
+			).Error("Could not purge soft-deleted topic")
+			continue
+		}
+
+		fullTopic := t.BrokerTopicName()
+		if err := broker.DeleteTopic(fullTopic); err != nil {
+			log.Errorf("Couldn't delete topic %v from broker, %v", fullTopic, err.Error())
+		}
+
+		purged++
+	}
+
+	return purged, nil
+}
+
+// StartReaper periodically purges the soft-deleted topics whose grace period has expired
+func StartReaper(interval time.Duration, gracePeriod time.Duration, store stores.Store, broker brokers.Broker) {
+
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			refStr := store.Clone()
+			purged, err := PurgeDeleted(gracePeriod, time.Now().UTC(), refStr, broker)
+			refStr.Close()
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Topic reaper failed")
+				continue
+			}
+			if purged > 0 {
+				log.WithFields(
+					log.Fields{
+						"type":   "service_log",
+						"purged": purged,
+					},
+				).Info("Purged soft-deleted topics")
+			}
+		}
+	}()
+}
@@ -0,0 +1,169 @@
+package topics
+
+import (
+	"time"
+
+	"github.com/ARGOeu/argo-messaging/stores"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultThroughputWindows are the windows the throughput of topics is computed over when none are configured
+var DefaultThroughputWindows = []string{"1m", "5m", "1h"}
+
+// ThroughputWindow is a period of time, ending now, over which the throughput of a topic is computed
+type ThroughputWindow struct {
+	Label    string
+	Duration time.Duration
+}
+
+// Throughput holds the rates a topic was published to during a window.
+// The rates are nil if no sample of the topic was taken during the window
+type Throughput struct {
+	Window            ThroughputWindow
+	MessagesPerSecond *float64
+	BytesPerSecond    *float64
+}
+
+// ParseThroughputWindows converts window declarations, e.g. 5m, to throughput windows.
+// Invalid or non positive declarations are skipped and the default windows apply if none is valid
+func ParseThroughputWindows(specs []string) []ThroughputWindow {
+
+	windows := []ThroughputWindow{}
+	for _, spec := range specs {
+		d, err := time.ParseDuration(spec)
+		if err != nil || d <= 0 {
+			log.WithFields(
+				log.Fields{
+					"type":   "service_log",
+					"window": spec,
+				},
+			).Warning("Skipping invalid topic throughput window")
+			continue
+		}
+		windows = append(windows, ThroughputWindow{Label: spec, Duration: d})
+	}
+
+	if len(windows) == 0 {
+		for _, spec := range DefaultThroughputWindows {
+			d, _ := time.ParseDuration(spec)
+			windows = append(windows, ThroughputWindow{Label: spec, Duration: d})
+		}
+	}
+
+	return windows
+}
+
+// ComputeThroughput computes the rates a topic was published to during each window, from the samples of its counters
+// and their current values. Every window covers the time between its oldest sample and now, so that a gap in the
+// sampling shortens the window instead of skewing the rates. A counter that got smaller is considered restarted
+func ComputeThroughput(projectUUID string, name string, current TopicMetrics, now time.Time, windows []ThroughputWindow, store stores.Store) ([]Throughput, error) {
+
+	results := []Throughput{}
+
+	for _, w := range windows {
+
+		res := Throughput{Window: w}
+
+		samples, err := store.QueryTopicSamples(projectUUID, name, now.Add(-w.Duration))
+		if err != nil {
+			return results, err
+		}
+
+		if len(samples) > 0 {
+
+			span := now.Sub(samples[0].Timestamp).Seconds()
+			if span > 0 {
+
+				points := append(samples, stores.QTopicSample{MsgNum: current.MsgNum, TotalBytes: current.TotalBytes})
+				msgs, bytes := int64(0), int64(0)
+				for i := 1; i < len(points); i++ {
+					msgs += counterDelta(points[i-1].MsgNum, points[i].MsgNum)
+					bytes += counterDelta(points[i-1].TotalBytes, points[i].TotalBytes)
+				}
+
+				msgRate := float64(msgs) / span
+				byteRate := float64(bytes) / span
+				res.MessagesPerSecond = &msgRate
+				res.BytesPerSecond = &byteRate
+			}
+		}
+
+		results = append(results, res)
+	}
+
+	return results, nil
+}
+
+// counterDelta returns how much a counter increased, considering a counter that got smaller as restarted from zero
+func counterDelta(prev int64, next int64) int64 {
+	if next < prev {
+		return next
+	}
+	return next - prev
+}
+
+// SampleTopics records the current counters of every topic and removes the samples that are older than the retention.
+// It returns the number of sampled topics
+func SampleTopics(now time.Time, retention time.Duration, store stores.Store) (int, error) {
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return 0, err
+	}
+
+	sampled := 0
+	for _, p := range projects {
+
+		qTopics, _, _, err := store.QueryTopics(p.UUID, "", "", "", 0, false, nil, "")
+		if err != nil {
+			return sampled, err
+		}
+
+		for _, t := range qTopics {
+			if err := store.InsertTopicSample(p.UUID, t.Name, now, t.MsgNum, t.TotalBytes); err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":         "service_log",
+						"project_uuid": p.UUID,
+						"topic_name":   t.Name,
+						"error":        err.Error(),
+					},
+				).Error("Could not sample topic")
+				continue
+			}
+			sampled++
+		}
+	}
+
+	return sampled, store.RemoveTopicSamples(now.Add(-retention))
+}
+
+// StartSampler periodically samples the counters of all topics, keeping the samples needed by the widest window
+func StartSampler(interval time.Duration, windows []ThroughputWindow, store stores.Store) {
+
+	// one more interval is kept, so that the widest window always starts with a sample
+	retention := interval
+	for _, w := range windows {
+		if w.Duration+interval > retention {
+			retention = w.Duration + interval
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			refStr := store.Clone()
+			_, err := SampleTopics(time.Now().UTC(), retention, refStr)
+			refStr.Close()
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Topic sampling failed")
+			}
+		}
+	}()
+}
@@ -5,9 +5,14 @@ import (
 	"errors"
 
 	"encoding/base64"
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/projects"
 	"github.com/ARGOeu/argo-messaging/schemas"
 	"github.com/ARGOeu/argo-messaging/stores"
+	"github.com/ARGOeu/argo-messaging/timestamp"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -21,6 +26,38 @@ type Topic struct {
 	PublishRate   float64   `json:"-"`
 	Schema        string    `json:"schema,omitempty"`
 	CreatedOn     string    `json:"created_on"`
+	DeletedOn     string    `json:"deleted_on,omitempty"`
+	PublishAcks   string    `json:"publish_acks,omitempty"`
+	// BrokerTopic is the name of the topic in the broker, which a rename leaves unchanged
+	BrokerTopic string `json:"-"`
+	// BrokerConfig is reported only when the topic gets created
+	BrokerConfig *brokers.TopicConfig `json:"broker_config,omitempty"`
+	// Labels are key/value pairs that group topics, e.g. by team or data domain
+	Labels map[string]string `json:"labels,omitempty"`
+	// PartitionKeyAttribute names the message attribute whose value is used as the partition key of the messages
+	PartitionKeyAttribute string `json:"partition_key_attribute,omitempty"`
+	// PublishLimits are the effective publish rate limits of the topic, reported only by the topic's own view
+	PublishLimits *PublishLimits `json:"publish_limits,omitempty"`
+}
+
+// RenameOptions holds the body of a topic rename request
+type RenameOptions struct {
+	Name string `json:"name"`
+}
+
+// PartitionKeyOptions holds the body of a request that modifies the partition key attribute of a topic
+type PartitionKeyOptions struct {
+	Attribute string `json:"partition_key_attribute"`
+}
+
+// CreateOptions holds the optional settings of a topic create request
+type CreateOptions struct {
+	Schema                string            `json:"schema"`
+	PublishAcks           string            `json:"publish_acks"`
+	Partitions            int               `json:"partitions"`
+	ReplicationFactor     int               `json:"replication_factor"`
+	Labels                map[string]string `json:"labels"`
+	PartitionKeyAttribute string            `json:"partition_key_attribute"`
 }
 
 type TopicMetrics struct {
@@ -51,6 +88,7 @@ func New(projectUUID string, projectName string, name string) Topic {
 		FullName:      ftn,
 		LatestPublish: time.Time{},
 		PublishRate:   0,
+		BrokerTopic:   brokers.TopicName(projectUUID, name),
 	}
 	return t
 }
@@ -58,7 +96,7 @@ func New(projectUUID string, projectName string, name string) Topic {
 // Find searches and returns a specific topic or all topics of a given project
 func FindMetric(projectUUID string, name string, store stores.Store) (TopicMetrics, error) {
 	result := TopicMetrics{MsgNum: 0}
-	topics, _, _, err := store.QueryTopics(projectUUID, "", name, "", 0)
+	topics, _, _, err := store.QueryTopics(projectUUID, "", name, "", 0, false, nil, "")
 
 	// check if the topic exists
 	if len(topics) == 0 {
@@ -79,8 +117,15 @@ func FindMetric(projectUUID string, name string, store stores.Store) (TopicMetri
 	return result, err
 }
 
-// Find searches and returns a specific topic or all topics of a given project
-func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store stores.Store) (PaginatedTopics, error) {
+// Find searches and returns a specific topic or all topics of a given project.
+// Soft-deleted topics are only included when showDeleted is true
+func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, showDeleted bool, store stores.Store) (PaginatedTopics, error) {
+	return FindByLabels(projectUUID, userUUID, name, pageToken, pageSize, showDeleted, nil, "", store)
+}
+
+// FindByLabels searches and returns a specific topic or all topics of a given project that fulfill the label selector.
+// A non empty namePrefix further limits the topics to the ones whose name starts with it
+func FindByLabels(projectUUID, userUUID, name, pageToken string, pageSize int32, showDeleted bool, selector labels.Selector, namePrefix string, store stores.Store) (PaginatedTopics, error) {
 
 	var err error
 	var qTopics []stores.QTopic
@@ -96,7 +141,7 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 		return result, err
 	}
 
-	if qTopics, totalSize, nextPageToken, err = store.QueryTopics(projectUUID, userUUID, name, string(pageTokenBytes), pageSize); err != nil {
+	if qTopics, totalSize, nextPageToken, err = store.QueryTopics(projectUUID, userUUID, name, string(pageTokenBytes), pageSize, showDeleted, selector, namePrefix); err != nil {
 		return result, err
 	}
 
@@ -111,7 +156,16 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 		curTop := New(item.ProjectUUID, projectName, item.Name)
 		curTop.LatestPublish = item.LatestPublish
 		curTop.PublishRate = item.PublishRate
-		curTop.CreatedOn = item.CreatedOn.Format("2006-01-02T15:04:05Z")
+		curTop.CreatedOn = timestamp.Format(item.CreatedOn)
+		curTop.PublishAcks = item.PublishAcks
+		curTop.BrokerTopic = item.BrokerTopicName()
+		curTop.PartitionKeyAttribute = item.PartitionKeyAttribute
+		if len(item.Labels) > 0 {
+			curTop.Labels = item.Labels
+		}
+		if !item.DeletedOn.IsZero() {
+			curTop.DeletedOn = timestamp.Format(item.DeletedOn)
+		}
 
 		if item.SchemaUUID != "" {
 			sl, err := schemas.Find(projectUUID, item.SchemaUUID, "", store)
@@ -140,6 +194,33 @@ func Find(projectUUID, userUUID, name, pageToken string, pageSize int32, store s
 	return result, err
 }
 
+// EffectivePublishAcks returns the acknowledgement level used when publishing to the topic,
+// which is its own level if one has been defined, otherwise the given service wide one
+func (tp *Topic) EffectivePublishAcks(defaultAcks string) string {
+	if tp.PublishAcks != "" {
+		return tp.PublishAcks
+	}
+	return defaultAcks
+}
+
+// PartitionKey returns the partition key of a message published to the topic, which is the value of the topic's
+// partition key attribute. Messages without the attribute, or topics without one, get an empty key
+func (tp *Topic) PartitionKey(msg messages.Message) string {
+	if tp.PartitionKeyAttribute == "" {
+		return ""
+	}
+	return msg.Attr[tp.PartitionKeyAttribute]
+}
+
+// RecordKey returns the key of the broker record a message is published as. The key the producer gave the message
+// takes precedence over the partition key, since it decides which messages the broker keeps when compacting the topic
+func (tp *Topic) RecordKey(msg messages.Message) string {
+	if msg.Key != "" {
+		return msg.Key
+	}
+	return tp.PartitionKey(msg)
+}
+
 // ExportJSON exports whole TopicMetrics Structure as a json string
 func (tp *TopicMetrics) ExportJSON() (string, error) {
 
@@ -160,19 +241,24 @@ func (tl *PaginatedTopics) ExportJSON() (string, error) {
 	return string(output[:]), err
 }
 
-// CreateTopic creates a new topic
-func CreateTopic(projectUUID string, name string, schemaUUID string, createdOn time.Time, store stores.Store) (Topic, error) {
+// CreateTopic creates a new topic, an empty publishAcks means that the topic uses the service wide acks level
+func CreateTopic(projectUUID string, name string, schemaUUID string, publishAcks string, createdOn time.Time, store stores.Store) (Topic, error) {
 
-	if HasTopic(projectUUID, name, store) {
+	// a soft-deleted topic still reserves its name until it gets purged
+	if HasTopic(projectUUID, name, store) || IsDeleted(projectUUID, name, store) {
 		return Topic{}, errors.New("exists")
 	}
 
-	err := store.InsertTopic(projectUUID, name, schemaUUID, createdOn)
+	if brokerTopicInUse(projectUUID, name, store) {
+		return Topic{}, errors.New("broker topic in use")
+	}
+
+	err := store.InsertTopic(projectUUID, name, schemaUUID, publishAcks, createdOn)
 	if err != nil {
 		return Topic{}, errors.New("backend error")
 	}
 
-	results, err := Find(projectUUID, "", name, "", 0, store)
+	results, err := Find(projectUUID, "", name, "", 0, false, store)
 
 	if len(results.Topics) != 1 {
 		return Topic{}, errors.New("backend error")
@@ -181,20 +267,227 @@ func CreateTopic(projectUUID string, name string, schemaUUID string, createdOn t
 	return results.Topics[0], err
 }
 
-// RemoveTopic removes an existing topic
+// RemoveTopic removes an existing topic, along with the cached authorization decisions about it,
+// so that a topic created later under the same name doesn't inherit them
 func RemoveTopic(projectUUID string, name string, store stores.Store) error {
 	if HasTopic(projectUUID, name, store) == false {
 		return errors.New("not found")
 	}
 
+	defer auth.InvalidateACL(projectUUID, "topics", name)
 	return store.RemoveTopic(projectUUID, name)
 }
 
-// HasTopic returns true if project & topic combination exist
+// UpdateTopicLabels replaces the labels of an existing topic
+func UpdateTopicLabels(projectUUID string, name string, labels map[string]string, store stores.Store) error {
+	if HasTopic(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.UpdateTopicLabels(projectUUID, name, labels)
+}
+
+// UpdateTopicPartitionKey updates the message attribute an existing topic takes the partition key from,
+// an empty attribute stops deriving partition keys
+func UpdateTopicPartitionKey(projectUUID string, name string, attribute string, store stores.Store) error {
+	if HasTopic(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	return store.UpdateTopicPartitionKey(projectUUID, name, attribute)
+}
+
+// SoftDeleteTopic marks an existing topic as deleted, keeping it restorable until it gets purged
+func SoftDeleteTopic(projectUUID string, name string, deletedOn time.Time, store stores.Store) error {
+	if HasTopic(projectUUID, name, store) == false {
+		return errors.New("not found")
+	}
+
+	defer auth.InvalidateACL(projectUUID, "topics", name)
+	return store.SoftDeleteTopic(projectUUID, name, deletedOn)
+}
+
+// UndeleteTopic restores a soft-deleted topic
+func UndeleteTopic(projectUUID string, name string, store stores.Store) (Topic, error) {
+	if IsDeleted(projectUUID, name, store) == false {
+		return Topic{}, errors.New("not found")
+	}
+
+	if err := store.RestoreTopic(projectUUID, name); err != nil {
+		return Topic{}, errors.New("backend error")
+	}
+
+	results, err := Find(projectUUID, "", name, "", 0, false, store)
+
+	if len(results.Topics) != 1 {
+		return Topic{}, errors.New("backend error")
+	}
+
+	return results.Topics[0], err
+}
+
+// RenameTopic renames an existing topic and the subscriptions attached to it.
+// The rename is logical, the topic keeps using the broker topic it was created with,
+// so its messages and the offsets of its subscriptions remain intact
+func RenameTopic(projectUUID string, name string, newName string, store stores.Store) (Topic, error) {
+
+	res, err := Find(projectUUID, "", name, "", 0, false, store)
+	if err != nil || len(res.Topics) == 0 {
+		return Topic{}, errors.New("not found")
+	}
+
+	if HasTopic(projectUUID, newName, store) || IsDeleted(projectUUID, newName, store) {
+		return Topic{}, errors.New("exists")
+	}
+
+	// renaming a topic back to its original name no longer needs to track the broker topic
+	brokerTopic := res.Topics[0].BrokerTopic
+	if brokerTopic == brokers.TopicName(projectUUID, newName) {
+		brokerTopic = ""
+	}
+
+	// the acl moves along with the topic, so neither name may keep the decisions cached before the rename
+	auth.InvalidateACL(projectUUID, "topics", name)
+	auth.InvalidateACL(projectUUID, "topics", newName)
+
+	if err := store.RenameTopic(projectUUID, name, newName, brokerTopic); err != nil {
+		return Topic{}, errors.New("backend error")
+	}
+
+	results, err := Find(projectUUID, "", newName, "", 0, false, store)
+
+	if len(results.Topics) != 1 {
+		return Topic{}, errors.New("backend error")
+	}
+
+	return results.Topics[0], err
+}
+
+// BrokerTopic returns the name of the given topic in the broker
+func BrokerTopic(projectUUID string, name string, store stores.Store) string {
+	qTopics, _, _, err := store.QueryTopics(projectUUID, "", name, "", 0, true, nil, "")
+	if err == nil && len(qTopics) > 0 {
+		return qTopics[0].BrokerTopicName()
+	}
+	return brokers.TopicName(projectUUID, name)
+}
+
+// brokerTopicInUse returns true if a renamed topic still uses the broker topic
+// that a new topic with the given name would be created with
+func brokerTopicInUse(projectUUID string, name string, store stores.Store) bool {
+
+	qTopics, _, _, err := store.QueryTopics(projectUUID, "", "", "", 0, true, nil, "")
+	if err != nil {
+		return false
+	}
+
+	for _, t := range qTopics {
+		if t.BrokerTopicName() == brokers.TopicName(projectUUID, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecordPublishTime keeps the publish time of a message, so that its offset can be later found by timestamp.
+// Nothing is kept for brokers that look offsets up by timestamp themselves
+func RecordPublishTime(projectUUID string, name string, offset int64, publishTime time.Time, store stores.Store, broker brokers.Broker) error {
+	if broker.SupportsTimeIndex() {
+		return nil
+	}
+	return store.InsertOffsetTime(projectUUID, name, offset, publishTime)
+}
+
+// PruneOffsetTimes removes the publish times kept for the offsets that the broker no longer holds, i.e. below the
+// first offset of each topic. It returns the number of pruned topics
+func PruneOffsetTimes(store stores.Store, broker brokers.Broker) (int, error) {
+
+	projects, err := store.QueryProjects("", "")
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, p := range projects {
+
+		qTopics, _, _, err := store.QueryTopics(p.UUID, "", "", "", 0, true, nil, "")
+		if err != nil {
+			return pruned, err
+		}
+
+		for _, t := range qTopics {
+
+			min := broker.GetMinOffset(t.BrokerTopicName())
+			if min <= 0 {
+				continue
+			}
+
+			if err := store.RemoveOffsetTimes(p.UUID, t.Name, min); err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":         "service_log",
+						"project_uuid": p.UUID,
+						"topic_name":   t.Name,
+						"error":        err.Error(),
+					},
+				).Error("Could not prune the publish times of topic")
+				continue
+			}
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
+
+// StartOffsetTimePruner periodically prunes the publish times kept for the offsets the broker no longer holds
+func StartOffsetTimePruner(interval time.Duration, store stores.Store, broker brokers.Broker) {
+
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			refStr := store.Clone()
+			_, err := PruneOffsetTimes(refStr, broker)
+			refStr.Close()
+			if err != nil {
+				log.WithFields(
+					log.Fields{
+						"type":  "service_log",
+						"error": err.Error(),
+					},
+				).Error("Pruning the topic publish times failed")
+			}
+		}
+	}()
+}
+
+// OffsetAt returns the first offset of a topic published at or after the given time, or -1 if there is none.
+// The broker's time index is preferred and the publish times kept in the store are used
+// when the broker doesn't support timestamp lookups
+func OffsetAt(projectUUID string, name string, t time.Time, store stores.Store, broker brokers.Broker) (int64, error) {
+	off, err := broker.TimeToOffset(BrokerTopic(projectUUID, name, store), t.Local())
+	if errors.Is(err, brokers.ErrTimeIndexUnsupported) {
+		return store.QueryOffsetByTime(projectUUID, name, t)
+	}
+	return off, err
+}
+
+// HasTopic returns true if project & topic combination exist and the topic is not soft-deleted
 func HasTopic(projectUUID string, name string, store stores.Store) bool {
-	res, err := Find(projectUUID, "", name, "", 0, store)
+	res, err := Find(projectUUID, "", name, "", 0, false, store)
 	if len(res.Topics) > 0 && err == nil {
 		return true
 	}
 	return false
 }
+
+// IsDeleted returns true if project & topic combination exist and the topic is soft-deleted
+func IsDeleted(projectUUID string, name string, store stores.Store) bool {
+	res, err := Find(projectUUID, "", name, "", 0, true, store)
+	if len(res.Topics) > 0 && err == nil {
+		return res.Topics[0].DeletedOn != ""
+	}
+	return false
+}
@@ -6,7 +6,11 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/ARGOeu/argo-messaging/auth"
+	"github.com/ARGOeu/argo-messaging/brokers"
 	"github.com/ARGOeu/argo-messaging/config"
+	"github.com/ARGOeu/argo-messaging/labels"
+	"github.com/ARGOeu/argo-messaging/messages"
 	"github.com/ARGOeu/argo-messaging/stores"
 	"github.com/stretchr/testify/suite"
 	"time"
@@ -37,7 +41,7 @@ func (suite *TopicTestSuite) TestGetTopicByName() {
 	APIcfg := config.NewAPICfg()
 	APIcfg.LoadStrJSON(suite.cfgStr)
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
-	myTopics, _ := Find("argo_uuid", "", "topic1", "", 0, store)
+	myTopics, _ := Find("argo_uuid", "", "topic1", "", 0, false, store)
 	expTopic := New("argo_uuid", "ARGO", "topic1")
 	expTopic.PublishRate = 10
 	expTopic.LatestPublish = time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local)
@@ -51,41 +55,41 @@ func (suite *TopicTestSuite) TestGetPaginatedTopics() {
 
 	// retrieve all topics
 	expPt1 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", "2020-11-19T00:00:00Z"},
-		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z"},
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"},
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", "2020-11-19T00:00:00Z", "", "", "argo_uuid.topic4", nil, nil, "", nil},
+		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z", "", "", "argo_uuid.topic3", nil, nil, "", nil},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", "", "", "argo_uuid.topic2", nil, nil, "", nil},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z", "", "", "argo_uuid.topic1", nil, nil, "", nil}},
 		NextPageToken: "", TotalSize: 4}
-	pgTopics1, err1 := Find("argo_uuid", "", "", "", 0, store)
+	pgTopics1, err1 := Find("argo_uuid", "", "", "", 0, false, store)
 
 	// retrieve first 2 topics
 	expPt2 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", "2020-11-19T00:00:00Z"},
-		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z"}},
+		{"argo_uuid", "topic4", "/projects/ARGO/topics/topic4", time.Date(0, 0, 0, 0, 0, 0, 0, time.Local), 0, "", "2020-11-19T00:00:00Z", "", "", "argo_uuid.topic4", nil, nil, "", nil},
+		{"argo_uuid", "topic3", "/projects/ARGO/topics/topic3", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), 8.99, "projects/ARGO/schemas/schema-3", "2020-11-20T00:00:00Z", "", "", "argo_uuid.topic3", nil, nil, "", nil}},
 		NextPageToken: "MQ==", TotalSize: 4}
-	pgTopics2, err2 := Find("argo_uuid", "", "", "", 2, store)
+	pgTopics2, err2 := Find("argo_uuid", "", "", "", 2, false, store)
 
 	// retrieve the next topic
 	expPt3 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z", "", "", "argo_uuid.topic1", nil, nil, "", nil}},
 		NextPageToken: "", TotalSize: 4}
-	pgTopics3, err3 := Find("argo_uuid", "", "", "MA==", 1, store)
+	pgTopics3, err3 := Find("argo_uuid", "", "", "MA==", 1, false, store)
 
 	// invalid page token
-	_, err4 := Find("", "", "", "invalid", 0, store)
+	_, err4 := Find("", "", "", "invalid", 0, false, store)
 
 	// retrieve topics for a specific user
 	expPt5 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"},
-		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z"}},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", "", "", "argo_uuid.topic2", nil, nil, "", nil},
+		{"argo_uuid", "topic1", "/projects/ARGO/topics/topic1", time.Date(2019, 5, 6, 0, 0, 0, 0, time.Local), 10, "", "2020-11-22T00:00:00Z", "", "", "argo_uuid.topic1", nil, nil, "", nil}},
 		NextPageToken: "", TotalSize: 2}
-	pgTopics5, err5 := Find("argo_uuid", "uuid1", "", "", 2, store)
+	pgTopics5, err5 := Find("argo_uuid", "uuid1", "", "", 2, false, store)
 
 	// retrieve topics for a specific user with pagination
 	expPt6 := PaginatedTopics{Topics: []Topic{
-		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z"}},
+		{"argo_uuid", "topic2", "/projects/ARGO/topics/topic2", time.Date(2019, 5, 8, 0, 0, 0, 0, time.Local), 5.45, "projects/ARGO/schemas/schema-1", "2020-11-21T00:00:00Z", "", "", "argo_uuid.topic2", nil, nil, "", nil}},
 		NextPageToken: "MA==", TotalSize: 2}
-	pgTopics6, err6 := Find("argo_uuid", "uuid1", "", "", 1, store)
+	pgTopics6, err6 := Find("argo_uuid", "uuid1", "", "", 1, false, store)
 
 	suite.Equal(expPt1, pgTopics1)
 	suite.Equal(expPt2, pgTopics2)
@@ -130,11 +134,11 @@ func (suite *TopicTestSuite) TestCreateTopicStore() {
 
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
-	tp, err := CreateTopic("argo_uuid", "topic1", "", time.Time{}, store)
+	tp, err := CreateTopic("argo_uuid", "topic1", "", "", time.Time{}, store)
 	suite.Equal(Topic{}, tp)
 	suite.Equal("exists", err.Error())
 
-	tp2, err2 := CreateTopic("argo_uuid", "topicNew", "schema_uuid_1", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), store)
+	tp2, err2 := CreateTopic("argo_uuid", "topicNew", "schema_uuid_1", "", time.Date(2019, 5, 7, 0, 0, 0, 0, time.Local), store)
 	expTopic := New("argo_uuid", "ARGO", "topicNew")
 	expTopic.Schema = "projects/ARGO/schemas/schema-1"
 	expTopic.CreatedOn = "2019-05-07T00:00:00Z"
@@ -155,6 +159,135 @@ func (suite *TopicTestSuite) TestRemoveTopicStore() {
 	suite.Equal(false, HasTopic("argo_uuid", "topic1", store))
 }
 
+func (suite *TopicTestSuite) TestRemoveTopicInvalidatesACL() {
+
+	store := stores.NewMockStore("", "")
+	auth.SetACLCacheTTL(time.Minute)
+	defer auth.SetACLCacheTTL(0)
+
+	// the decisions cached for the removed topic don't survive it
+	suite.True(auth.PerResource("argo_uuid", "topics", "topic1", "uuid1", store))
+	store.ModACL("argo_uuid", "topics", "topic1", []string{})
+	suite.Nil(RemoveTopic("argo_uuid", "topic1", store))
+	suite.False(auth.PerResource("argo_uuid", "topics", "topic1", "uuid1", store))
+
+	suite.True(auth.PerResource("argo_uuid", "topics", "topic2", "uuid1", store))
+	store.ModACL("argo_uuid", "topics", "topic2", []string{})
+	suite.Nil(SoftDeleteTopic("argo_uuid", "topic2", time.Now(), store))
+	suite.False(auth.PerResource("argo_uuid", "topics", "topic2", "uuid1", store))
+
+	// neither the old nor the new name of a renamed topic keeps its cached decisions
+	suite.False(auth.PerResource("argo_uuid", "topics", "topicRenamed", "uuid1", store))
+	suite.True(auth.PerResource("argo_uuid", "topics", "topic3", "uuid3", store))
+	store.ModACL("argo_uuid", "topics", "topic3", []string{"uuid1"})
+	_, err := RenameTopic("argo_uuid", "topic3", "topicRenamed", store)
+	suite.Nil(err)
+	suite.True(auth.PerResource("argo_uuid", "topics", "topicRenamed", "uuid1", store))
+	suite.False(auth.PerResource("argo_uuid", "topics", "topic3", "uuid3", store))
+}
+
+func (suite *TopicTestSuite) TestSoftDeleteTopic() {
+	APIcfg := config.NewAPICfg()
+	APIcfg.LoadStrJSON(suite.cfgStr)
+
+	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
+	broker := brokers.MockBroker{}
+
+	deletedOn := time.Date(2020, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	suite.Equal("not found", SoftDeleteTopic("argo_uuid", "topicFoo", deletedOn, store).Error())
+	suite.Nil(SoftDeleteTopic("argo_uuid", "topic1", deletedOn, store))
+
+	// soft-deleted topics are hidden by default but keep their name reserved
+	suite.False(HasTopic("argo_uuid", "topic1", store))
+	suite.True(IsDeleted("argo_uuid", "topic1", store))
+	_, err := CreateTopic("argo_uuid", "topic1", "", "", time.Now(), store)
+	suite.Equal("exists", err.Error())
+
+	pt, _ := Find("argo_uuid", "", "", "", 0, false, store)
+	suite.Equal(int32(3), pt.TotalSize)
+	pt2, _ := Find("argo_uuid", "", "", "", 0, true, store)
+	suite.Equal(int32(4), pt2.TotalSize)
+	suite.Equal("2020-12-01T10:00:00Z", pt2.Topics[3].DeletedOn)
+
+	// restore it
+	tp, err := UndeleteTopic("argo_uuid", "topic1", store)
+	suite.Nil(err)
+	suite.Equal("/projects/ARGO/topics/topic1", tp.FullName)
+	suite.Equal("", tp.DeletedOn)
+	suite.True(HasTopic("argo_uuid", "topic1", store))
+
+	_, err = UndeleteTopic("argo_uuid", "topic1", store)
+	suite.Equal("not found", err.Error())
+
+	// purge only the topics whose grace period has expired
+	suite.Nil(SoftDeleteTopic("argo_uuid", "topic1", deletedOn, store))
+	suite.Nil(SoftDeleteTopic("argo_uuid", "topic2", deletedOn.Add(time.Hour), store))
+
+	purged, err := PurgeDeleted(time.Hour, deletedOn.Add(30*time.Minute), store, &broker)
+	suite.Nil(err)
+	suite.Equal(0, purged)
+
+	purged, err = PurgeDeleted(time.Hour, deletedOn.Add(90*time.Minute), store, &broker)
+	suite.Nil(err)
+	suite.Equal(1, purged)
+	suite.False(IsDeleted("argo_uuid", "topic1", store))
+	suite.True(IsDeleted("argo_uuid", "topic2", store))
+}
+
+func (suite *TopicTestSuite) TestRenameTopic() {
+
+	store := stores.NewMockStore("", "")
+	store.UpdateSubOffset("argo_uuid", "sub1", 5)
+
+	_, err := RenameTopic("argo_uuid", "topicFoo", "topicBar", store)
+	suite.Equal("not found", err.Error())
+
+	_, err = RenameTopic("argo_uuid", "topic1", "topic2", store)
+	suite.Equal("exists", err.Error())
+
+	tp, err := RenameTopic("argo_uuid", "topic1", "topicRenamed", store)
+	suite.Nil(err)
+	suite.Equal("/projects/ARGO/topics/topicRenamed", tp.FullName)
+	suite.Equal("argo_uuid.topic1", tp.BrokerTopic)
+	suite.False(HasTopic("argo_uuid", "topic1", store))
+	suite.Equal("argo_uuid.topic1", BrokerTopic("argo_uuid", "topicRenamed", store))
+
+	// the dependent subscription follows the topic and keeps its offset
+	qSub, _ := store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal("topicRenamed", qSub.Topic)
+	suite.Equal(int64(5), qSub.Offset)
+
+	// the original name can't be reused while the renamed topic holds its broker topic
+	_, err = CreateTopic("argo_uuid", "topic1", "", "", time.Now(), store)
+	suite.Equal("broker topic in use", err.Error())
+
+	// renaming it back restores the default broker topic
+	tp, err = RenameTopic("argo_uuid", "topicRenamed", "topic1", store)
+	suite.Nil(err)
+	suite.Equal("argo_uuid.topic1", tp.BrokerTopic)
+	qTopics, _, _, _ := store.QueryTopics("argo_uuid", "", "topic1", "", 0, false, nil, "")
+	suite.Equal("", qTopics[0].BrokerTopic)
+	qSub, _ = store.QueryOneSub("argo_uuid", "sub1")
+	suite.Equal("topic1", qSub.Topic)
+}
+
+func (suite *TopicTestSuite) TestBrokerTopicPrefix() {
+
+	store := stores.NewMockStore("", "")
+	brokers.SetTopicPrefix("devel")
+	defer brokers.SetTopicPrefix("")
+
+	suite.Equal("devel.argo_uuid.topic1", BrokerTopic("argo_uuid", "topic1", store))
+	suite.Equal("devel.argo_uuid.topicNew", New("argo_uuid", "ARGO", "topicNew").BrokerTopic)
+
+	// renamed topics keep the prefixed broker topic they were created with
+	tp, err := RenameTopic("argo_uuid", "topic1", "topicRenamed", store)
+	suite.Nil(err)
+	suite.Equal("devel.argo_uuid.topic1", tp.BrokerTopic)
+	suite.Equal("devel.argo_uuid.topic1", BrokerTopic("argo_uuid", "topicRenamed", store))
+}
+
 func (suite *TopicTestSuite) TestHasProjectTopic() {
 	APIcfg := config.NewAPICfg()
 	APIcfg.LoadStrJSON(suite.cfgStr)
@@ -165,13 +298,72 @@ func (suite *TopicTestSuite) TestHasProjectTopic() {
 	suite.Equal(true, HasTopic("argo_uuid", "topic1", store))
 }
 
+func (suite *TopicTestSuite) TestOffsetAt() {
+	store := stores.NewMockStore("", "")
+	broker := brokers.MockBroker{}
+	broker.TopicTimeIndices = map[string][]brokers.TimeToOffset{
+		"argo_uuid.topic1": {{Timestamp: time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC), Offset: 10}},
+	}
+
+	// nothing is kept while the broker has a time index
+	suite.Nil(RecordPublishTime("argo_uuid", "topic1", 2, time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC), store, &broker))
+	suite.Equal(0, len(store.OffsetTimes))
+
+	broker.NoTimeIndex = true
+	suite.Nil(RecordPublishTime("argo_uuid", "topic1", 3, time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC), store, &broker))
+	suite.Nil(RecordPublishTime("argo_uuid", "topic1", 4, time.Date(2019, 6, 12, 0, 0, 0, 0, time.UTC), store, &broker))
+	suite.Equal(2, len(store.OffsetTimes))
+
+	// the broker's time index is preferred
+	broker.NoTimeIndex = false
+	off, err := OffsetAt("argo_uuid", "topic1", time.Date(2019, 6, 9, 0, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	suite.Equal(int64(10), off)
+
+	// the store answers when the broker can't look up timestamps
+	broker.NoTimeIndex = true
+	off, err = OffsetAt("argo_uuid", "topic1", time.Date(2019, 6, 11, 0, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	suite.Equal(int64(4), off)
+
+	off, err = OffsetAt("argo_uuid", "topic1", time.Date(2019, 6, 13, 0, 0, 0, 0, time.UTC), store, &broker)
+	suite.Nil(err)
+	suite.Equal(int64(-1), off)
+}
+
+func (suite *TopicTestSuite) TestPruneOffsetTimes() {
+	store := stores.NewMockStore("", "")
+	broker := brokers.MockBroker{NoTimeIndex: true}
+	broker.Initialize([]string{"localhost"})
+
+	// the mock broker reports the number of its messages as the first offset of every topic
+	broker.Publish("argo_uuid.topic1", messages.Message{Data: "dGVzdA=="}, "", "")
+	broker.Publish("argo_uuid.topic1", messages.Message{Data: "dGVzdA=="}, "", "")
+
+	for off := int64(0); off < 3; off++ {
+		store.InsertOffsetTime("argo_uuid", "topic1", off, time.Date(2019, 6, 10, 0, 0, int(off), 0, time.UTC))
+	}
+	store.InsertOffsetTime("argo_uuid", "topic2", 3, time.Date(2019, 6, 10, 0, 0, 0, 0, time.UTC))
+
+	_, err := PruneOffsetTimes(store, &broker)
+	suite.Nil(err)
+
+	// only the offsets that the broker still holds are kept
+	kept := map[string][]int64{}
+	for _, item := range store.OffsetTimes {
+		kept[item.TopicName] = append(kept[item.TopicName], item.Offset)
+	}
+	suite.Equal([]int64{2}, kept["topic1"])
+	suite.Equal([]int64{3}, kept["topic2"])
+}
+
 func (suite *TopicTestSuite) TestExportJson() {
 	APIcfg := config.NewAPICfg()
 	APIcfg.LoadStrJSON(suite.cfgStr)
 
 	store := stores.NewMockStore(APIcfg.StoreHost, APIcfg.StoreDB)
 
-	topics, _ := Find("argo_uuid", "", "topic1", "", 0, store)
+	topics, _ := Find("argo_uuid", "", "topic1", "", 0, false, store)
 	outJSON, _ := topics.Topics[0].ExportJSON()
 	expJSON := `{
    "name": "/projects/ARGO/topics/topic1",
@@ -203,12 +395,173 @@ func (suite *TopicTestSuite) TestExportJson() {
    "nextPageToken": "",
    "totalSize": 4
 }`
-	topics2, _ := Find("argo_uuid", "", "", "", 0, store)
+	topics2, _ := Find("argo_uuid", "", "", "", 0, false, store)
 	outJSON2, _ := topics2.ExportJSON()
 	suite.Equal(expJSON2, outJSON2)
 
 }
 
+func (suite *TopicTestSuite) TestTopicLabels() {
+
+	store := stores.NewMockStore("", "")
+
+	suite.Equal("not found", UpdateTopicLabels("argo_uuid", "topicFoo", map[string]string{"team": "sre"}, store).Error())
+	suite.Nil(UpdateTopicLabels("argo_uuid", "topic1", map[string]string{"team": "sre", "domain": "metrics"}, store))
+	suite.Nil(UpdateTopicLabels("argo_uuid", "topic2", map[string]string{"team": "ops"}, store))
+
+	pt, err := FindByLabels("argo_uuid", "", "", "", 0, false, labels.Selector{"team": {"sre", "ops"}}, "", store)
+	suite.Nil(err)
+	suite.Equal(int32(2), pt.TotalSize)
+	suite.Equal("/projects/ARGO/topics/topic2", pt.Topics[0].FullName)
+	suite.Equal("/projects/ARGO/topics/topic1", pt.Topics[1].FullName)
+
+	pt, _ = FindByLabels("argo_uuid", "", "", "", 0, false, labels.Selector{"team": {"sre"}, "domain": {"metrics"}}, "", store)
+	suite.Equal(1, len(pt.Topics))
+
+	outJSON, _ := pt.Topics[0].ExportJSON()
+	expJSON := `{
+   "name": "/projects/ARGO/topics/topic1",
+   "created_on": "2020-11-22T00:00:00Z",
+   "labels": {
+      "domain": "metrics",
+      "team": "sre"
+   }
+}`
+	suite.Equal(expJSON, outJSON)
+
+	// an empty set of labels removes them
+	suite.Nil(UpdateTopicLabels("argo_uuid", "topic1", map[string]string{}, store))
+	pt, _ = Find("argo_uuid", "", "topic1", "", 0, false, store)
+	suite.Nil(pt.Topics[0].Labels)
+}
+
 func TestTopicsTestSuite(t *testing.T) {
 	suite.Run(t, new(TopicTestSuite))
 }
+
+func (suite *TopicTestSuite) TestComputeThroughput() {
+
+	store := stores.NewMockStore("", "")
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	windows := ParseThroughputWindows([]string{"1m", "5m", "invalid"})
+	suite.Equal([]ThroughputWindow{{"1m", time.Minute}, {"5m", 5 * time.Minute}}, windows)
+
+	// no samples, no data to compute the throughput from
+	res, err := ComputeThroughput("argo_uuid", "topic1", TopicMetrics{MsgNum: 100, TotalBytes: 1000}, now, windows, store)
+	suite.Nil(err)
+	suite.Nil(res[0].MessagesPerSecond)
+	suite.Nil(res[1].BytesPerSecond)
+
+	// the sampling stopped between two and four minutes ago, the 5m window covers the last four minutes
+	store.InsertTopicSample("argo_uuid", "topic1", now.Add(-6*time.Minute), 0, 0)
+	store.InsertTopicSample("argo_uuid", "topic1", now.Add(-4*time.Minute), 40, 400)
+	store.InsertTopicSample("argo_uuid", "topic1", now.Add(-30*time.Second), 70, 700)
+
+	res, err = ComputeThroughput("argo_uuid", "topic1", TopicMetrics{MsgNum: 100, TotalBytes: 1000}, now, windows, store)
+	suite.Nil(err)
+	suite.Equal(1.0, *res[0].MessagesPerSecond)
+	suite.Equal(10.0, *res[0].BytesPerSecond)
+	suite.Equal(0.25, *res[1].MessagesPerSecond)
+	suite.Equal(2.5, *res[1].BytesPerSecond)
+
+	// a counter that got smaller has been restarted
+	res, err = ComputeThroughput("argo_uuid", "topic1", TopicMetrics{MsgNum: 15, TotalBytes: 150}, now, windows[:1], store)
+	suite.Nil(err)
+	suite.Equal(0.5, *res[0].MessagesPerSecond)
+}
+
+func (suite *TopicTestSuite) TestSampleTopics() {
+
+	store := stores.NewMockStore("", "")
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.InsertTopicSample("argo_uuid", "topic1", now.Add(-2*time.Hour), 0, 0)
+
+	sampled, err := SampleTopics(now, time.Hour, store)
+	suite.Nil(err)
+	suite.Equal(len(store.TopicList), sampled)
+	suite.Equal(len(store.TopicList), len(store.TopicSamples))
+
+	samples, _ := store.QueryTopicSamples("argo_uuid", "topic1", now.Add(-time.Hour))
+	suite.Equal(1, len(samples))
+	suite.Equal(now, samples[0].Timestamp)
+}
+
+func (suite *TopicTestSuite) TestPartitionKey() {
+
+	store := stores.NewMockStore("", "")
+
+	msg := messages.Message{Attr: messages.Attributes{"host": "node1"}, Data: "YmFzZTY0ZW5jb2RlZA=="}
+
+	res, _ := Find("argo_uuid", "", "topic1", "", 0, false, store)
+	suite.Equal("", res.Topics[0].PartitionKey(msg))
+
+	suite.Equal("not found", UpdateTopicPartitionKey("argo_uuid", "topicFoo", "host", store).Error())
+	suite.Nil(UpdateTopicPartitionKey("argo_uuid", "topic1", "host", store))
+	res, _ = Find("argo_uuid", "", "topic1", "", 0, false, store)
+	suite.Equal("host", res.Topics[0].PartitionKeyAttribute)
+	suite.Equal("node1", res.Topics[0].PartitionKey(msg))
+	suite.Equal("", res.Topics[0].PartitionKey(messages.Message{Data: "YmFzZTY0ZW5jb2RlZA=="}))
+}
+
+func (suite *TopicTestSuite) TestRecordKey() {
+
+	tp := Topic{Name: "topic1"}
+	msg := messages.Message{Attr: messages.Attributes{"host": "node1"}, Data: "YmFzZTY0ZW5jb2RlZA=="}
+
+	suite.Equal("", tp.RecordKey(msg))
+
+	tp.PartitionKeyAttribute = "host"
+	suite.Equal("node1", tp.RecordKey(msg))
+
+	// the message key takes precedence over the partition key
+	msg.Key = "sensor-1"
+	suite.Equal("sensor-1", tp.RecordKey(msg))
+}
+
+func (suite *TopicTestSuite) TestRateLimiter() {
+
+	rl := NewRateLimiter()
+	now := time.Date(2020, 11, 25, 14, 30, 0, 0, time.UTC)
+	limits := PublishLimits{MessagesPerSecond: 10, BytesPerSecond: 100}
+
+	// a topic starts with a second's worth of quota
+	quota, retryAfter, ok := rl.Take("argo_uuid.topic1", limits, 4, 40, now)
+	suite.True(ok)
+	suite.Equal(time.Duration(0), retryAfter)
+	suite.Equal(PublishQuota{Limits: limits, RemainingMessages: 6, RemainingBytes: 60}, quota)
+
+	// the bytes run out first, and nothing is taken from the messages
+	quota, retryAfter, ok = rl.Take("argo_uuid.topic1", limits, 2, 80, now)
+	suite.False(ok)
+	suite.Equal(200*time.Millisecond, retryAfter)
+	suite.Equal(int64(6), quota.RemainingMessages)
+	suite.Equal(int64(60), quota.RemainingBytes)
+
+	// every topic has its own quota
+	_, _, ok = rl.Take("argo_uuid.topic2", limits, 2, 80, now)
+	suite.True(ok)
+
+	// the quota refills with time, up to a second's worth of it
+	quota, _, ok = rl.Take("argo_uuid.topic1", limits, 2, 80, now.Add(200*time.Millisecond))
+	suite.True(ok)
+	suite.Equal(int64(6), quota.RemainingMessages)
+	suite.Equal(int64(0), quota.RemainingBytes)
+
+	quota, _, ok = rl.Take("argo_uuid.topic1", limits, 0, 0, now.Add(time.Hour))
+	suite.True(ok)
+	suite.Equal(int64(10), quota.RemainingMessages)
+	suite.Equal(int64(100), quota.RemainingBytes)
+
+	// a full quota admits a publish larger than itself, which the following publishes wait for
+	_, _, ok = rl.Take("argo_uuid.topic1", limits, 20, 0, now.Add(time.Hour))
+	suite.True(ok)
+	_, retryAfter, ok = rl.Take("argo_uuid.topic1", limits, 1, 0, now.Add(time.Hour))
+	suite.False(ok)
+	suite.Equal(1100*time.Millisecond, retryAfter)
+
+	// unset limits bound nothing
+	_, _, ok = rl.Take("argo_uuid.topic3", PublishLimits{MessagesPerSecond: 1}, 1, 1000000, now)
+	suite.True(ok)
+	suite.True(PublishLimits{}.IsEmpty())
+	suite.False(limits.IsEmpty())
+}
@@ -12,6 +12,12 @@ func ValidName(name string) bool {
 	return r.Match([]byte(name))
 }
 
+// ValidOperationName checks the validity of an operation name, e.g. topics:publish
+func ValidOperationName(name string) bool {
+	r, _ := regexp.Compile("^[a-zA-Z0-9_-]+:[a-zA-Z0-9_-]+$")
+	return r.Match([]byte(name))
+}
+
 // ValidAckID checks the validity of an AckID string against a given project and subscription
 func ValidAckID(project string, sub string, ackID string) bool {
 
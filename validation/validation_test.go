@@ -31,6 +31,14 @@ func (suite *ValidationTestSuite) TestValidation() {
 	suite.Equal(false, ValidName("topic/A"))
 	suite.Equal(false, ValidName("topic/B"))
 
+	// operation name validations
+	suite.Equal(true, ValidOperationName("topics:publish"))
+	suite.Equal(true, ValidOperationName("subscriptions:list_all"))
+	suite.Equal(false, ValidOperationName("topics"))
+	suite.Equal(false, ValidOperationName("topics:publish:all"))
+	suite.Equal(false, ValidOperationName("topics/publish"))
+	suite.Equal(false, ValidOperationName(":publish"))
+
 	// ackID validations
 	suite.Equal(true, ValidAckID("ARGO", "sub101", "projects/ARGO/subscriptions/sub101:5"))
 	suite.Equal(false, ValidAckID("ARGO", "sub101", "projects/ARGO/subscriptions/sub101:aaa"))
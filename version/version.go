@@ -42,9 +42,19 @@ func LogInfo() {
 
 // Model struct holds version information about the binary build
 type Model struct {
-	BuildTime string `xml:"build_time" json:"build_time"`
-	GO        string `xml:"golang" json:"golang"`
-	Compiler  string `xml:"compiler" json:"compiler"`
-	OS        string `xml:"os" json:"os"`
-	Arch      string `xml:"architecture" json:"architecture"`
+	Release   string  `xml:"release" json:"release"`
+	Commit    string  `xml:"commit" json:"commit"`
+	BuildTime string  `xml:"build_time" json:"build_time"`
+	GO        string  `xml:"golang" json:"golang"`
+	Compiler  string  `xml:"compiler" json:"compiler"`
+	OS        string  `xml:"os" json:"os"`
+	Arch      string  `xml:"architecture" json:"architecture"`
+	Broker    Backend `xml:"broker" json:"broker"`
+	Store     Backend `xml:"store" json:"store"`
+}
+
+// Backend holds the type and, when available, the version of a backend the service depends on
+type Backend struct {
+	Type    string `xml:"type" json:"type"`
+	Version string `xml:"version,omitempty" json:"version,omitempty"`
 }
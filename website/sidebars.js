@@ -2,7 +2,7 @@ module.exports = {
   someSidebar: {
     'General Concepts': ['overview', 'msg_backend', 'msg_flow', 'auth', 'projects_users'],
     'Argo Messaging API': ['api_basic', 'api_errors'],
-    'API Calls' : ['api_auth', 'api_users', 'api_projects', 'api_topics', 'api_subscriptions', 'api_metrics', 'api_schemas', 'api_version', 'api_registrations'],
+    'API Calls' : ['api_auth', 'api_users', 'api_projects', 'api_topics', 'api_subscriptions', 'api_metrics', 'api_schemas', 'api_version', 'api_registrations', 'api_roles'],
     'Guides': ['publisher'],
     'Frequent Questions': ['qa', 'qa_ruby']
   },